@@ -3,15 +3,32 @@ package assets
 import "C"
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/broker"
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/cryptogateway/backend-envoys/assets/common/envelope"
+	"github.com/cryptogateway/backend-envoys/assets/common/geoip"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/i18n"
 	"github.com/cryptogateway/backend-envoys/assets/common/kycaid"
+	"github.com/cryptogateway/backend-envoys/assets/common/metrics"
+	"github.com/cryptogateway/backend-envoys/assets/common/notify"
+	"github.com/cryptogateway/backend-envoys/assets/common/queue"
+	"github.com/cryptogateway/backend-envoys/assets/common/report"
+	"github.com/cryptogateway/backend-envoys/assets/common/surveillance"
+	"github.com/cryptogateway/backend-envoys/assets/common/throttle"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
 	"io"
-	"io/ioutil"
 	"os"
 	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,14 +36,27 @@ import (
 	MQTT "github.com/eclipse/paho.mqtt.golang"
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v4"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+const (
+	// DecimalNumber, DecimalString and DecimalBoth - The formats of the floating point fields in the published events and in the
+	// http responses, see the DecimalFormat field of the Context.
+	DecimalNumber = "number"
+	DecimalString = "string"
+	DecimalBoth   = "both"
+)
+
+// failures - The metric of the messages the broker did not accept, by the topics and the channels of the messages.
+var failures = metrics.NewCounter("mqtt_publish_failures_total", "The number of the messages the broker did not accept.", "topic", "channel")
+
 // Kyc - The Kyc struct is used to store information related to Know Your Customer (KYC) processes. It contains an API key, as
 // well as three structs (S, P, and C) that store information related to the documents required to complete a KYC
 // process. These documents may include a passport, proof of address, and proof of identity. Each struct contains a key
@@ -60,6 +90,152 @@ type Smtp struct {
 	Port                   int
 }
 
+// Report - The type Report struct holds the schema of the end-of-day transaction report of one jurisdiction: the format of the
+// file, the mapping of the fields of the trades to the fields of the regulator, and the sftp server the reports are delivered to.
+type Report struct {
+	Jurisdiction, Format string
+	Fields               []report.Field
+	Sftp                 *report.Sftp
+}
+
+// Signer - The type Signer struct holds the external signing service or HSM gateway the withdrawals of one chain are signed
+// with: the endpoint the digests are posted to and the token the service authenticates the requests with.
+type Signer struct {
+	ChainId         int64
+	Endpoint, Token string
+}
+
+// Webhook - The type Webhook struct holds the third-party node provider that reports the transfers of one chain with signed
+// webhooks: the provider, such as alchemy, quicknode or blockdaemon, the secret the events are signed with, and whether
+// the scanner of the chain keeps reading the blocks. The chain that is not scanned relies on the webhooks for its
+// deposits, its scanner only follows the head of the chain for the confirmations.
+type Webhook struct {
+	ChainId          int64
+	Provider, Secret string
+	Scan             bool
+}
+
+// Gas - The type Gas struct holds the gas reserve of one chain: the address of the reserve of the exchange the hot wallets of the
+// tokens are topped up from with the coin of the chain, which pays the gas of Ethereum and the energy of Tron. The hot
+// wallet whose coin falls below the Threshold is topped up to the Target, and the operators are alerted when the coin of
+// the gas reserve falls below the Alert.
+type Gas struct {
+	ChainId                  int64
+	Reserve                  string
+	Threshold, Target, Alert float64
+}
+
+// Rebalance - The type Rebalance struct holds the rebalancing of the reserves of the withdrawals: the withdrawals that wait
+// longer than the Delay in minutes because no reserve of their platform holds their value are covered by moving the
+// reserves of the currency between the exchange addresses of the platform, or from another platform through the bridges
+// configured by the operators. The moves between the addresses are sent at once with Execute, otherwise they are proposed
+// to the operators, the moves through the bridges are always proposed.
+type Rebalance struct {
+	Delay   int
+	Execute bool
+}
+
+// Ramp - The type Ramp struct holds the automatic conversion of the deposits of the stablecoins: the Symbols are the stablecoins
+// the users may have converted into the currency of their balance once their deposits are credited, and the Slippage is
+// the largest distance in percent from the price of the pair the conversion is executed at, it is also the slippage of
+// the users who did not set their own.
+type Ramp struct {
+	Symbols  []string
+	Slippage float64
+}
+
+// Screening - The type Screening struct holds the screening service the incoming deposits are scored by: the endpoint, the
+// token the service authenticates the requests with, and the score from which the deposits are held for the review.
+type Screening struct {
+	Endpoint, Token string
+	Score           float64
+}
+
+// Lockout - The type Lockout struct holds the protection of the sign in against the guessing of the passwords and the codes: the
+// number of the failed attempts within the window in minutes after which the sign in of the email is locked for the
+// duration in minutes. The address the attempts come from is locked after five times as many failed attempts, since the
+// users behind the same address share it.
+type Lockout struct {
+	Attempts, Window, Duration int
+}
+
+// Sources - The type Sources struct holds the treatment of the deposits from the verified sources, the external addresses the
+// users proved to own by the signatures: the deposits from them are held for the review from the Multiplier times the
+// hold amount of the asset on the chain.
+type Sources struct {
+	Multiplier float64
+}
+
+// Sandbox - The type Sandbox struct holds the sandbox mode of the deployment on the testnets: the Chains are connected to the nodes
+// and the network ids of their testnets, the Faucet are the test amounts by the symbols the faucet credits to the users
+// at most once in the Interval in hours, and the Watermark marks the subjects and the texts of all the emails.
+type Sandbox struct {
+	Chains    []*SandboxChain
+	Faucet    map[string]float64
+	Interval  int
+	Watermark string
+}
+
+// SandboxChain - The type SandboxChain struct holds the testnet of the chain with the id: the rpc of its node and its network id.
+type SandboxChain struct {
+	ChainId, Network int64
+	Rpc              string
+}
+
+// Paper - The type Paper struct holds the paper trading of the users: the Balances are the simulated funds by the symbols every
+// account starts the paper trading with and gets back when the paper trading is reset.
+type Paper struct {
+	Balances map[string]float64
+}
+
+// Siwe - The type Siwe struct holds the sign in with the Ethereum wallets (EIP-4361): the Domains are the domains of the
+// frontends the messages of the wallets are accepted for, the message signed for another domain is a phishing site
+// relaying the sign in, and the Chains are the ids of the chains the messages may name, any chain when empty.
+type Siwe struct {
+	Domains []string
+	Chains  []int64
+}
+
+// Reconciliation - The type Reconciliation struct holds the thresholds of the reconciliation of the balances: the discrepancies up
+// to the epsilon are the rounding of the arithmetic and are ignored, the discrepancies up to the drift are corrected
+// automatically, the larger ones are raised to the operators. The drift of zero turns the automatic correction off.
+type Reconciliation struct {
+	Epsilon, Drift float64
+}
+
+// Entropy - The type Entropy struct holds the master keys the entropies of the accounts are sealed with: the keys by their ids,
+// hex encoded 256 bit keys, and the id of the primary key the new entropies are sealed with. The retired keys are kept
+// until the entropies sealed with them are rewrapped with the primary key by the rotate-entropy command.
+type Entropy struct {
+	Primary string
+	Keys    map[string]string
+}
+
+// Pool - The type Pool struct holds the limits of the pool of the connections of the database: the maximum number of the open
+// and of the idle connections, and the number of the seconds after which a connection is closed since it was opened or since
+// it was last used. The zero values keep the defaults of the database/sql package, which do not limit the pool.
+type Pool struct {
+	MaxOpen, MaxIdle   int
+	Lifetime, IdleTime int
+}
+
+// Partition - The type Partition struct holds the policies of a hypertable or of a continuous aggregate of the database by its
+// name: the number of the days of a chunk of the hypertable, and the number of the days after which the chunks are compressed
+// and after which they are dropped. The zero values keep the chunks as they are, uncompressed and forever.
+type Partition struct {
+	Table                   string
+	Chunk, Compress, Retain int
+}
+
+// Scanner - The type Scanner struct holds the catch-up of the scanners of the chains: the scanner that is at least the Catchup
+// blocks behind the head of the chain scans up to the Batch blocks in a row instead of one block per pass, and at most the
+// Rate blocks per second, so that the scanner that was down for hours catches up without flooding the node. The Workers is
+// the most blocks a scanner reads at the same time, whatever the workers of its chain. The zero values scan one block
+// per pass.
+type Scanner struct {
+	Catchup, Batch, Rate, Workers int
+}
+
 // Server - The type Server struct is a data structure in the Go programming language that holds two strings, Host and Proxy. It
 // is used to represent a server with both a host name and a proxy name. It can be used to store configuration details
 // for a server, such as host and proxy settings. It can also be used to store information about the server such as its
@@ -79,9 +255,13 @@ type Redis struct {
 // Rabbitmq - The type Rabbitmq struct is a data structure used to store the information needed to connect to a RabbitMQ server. It stores
 // the host URL, username, password, and a boolean value that indicates whether the connection should be
 // established with a clean session. This information is then used by clients to connect and interact with RabbitMQ.
+// The Secret signs the grants of the users to read their topics from the websocket bridge for the Lifetime in minutes,
+// the broker checks the grants with the backend, without the secret the grants are not issued.
 type Rabbitmq struct {
 	Host, Username, Password string
 	CleanSession             bool
+	Secret                   string
+	Lifetime                 int
 }
 
 // The Credentials struct is used to store authentication credentials such as a certificate, secret key, and override. It
@@ -97,11 +277,18 @@ type Context struct {
 	// environment used for production. It is used to ensure that certain features are enabled or disabled depending on the environment.
 	Development bool
 
+	// LogLevel is the level of the logger, such as "debug", "info", "warn" or "error". Without it the development logs at the
+	// debug level and the production at the warning level. The level is applied again when the configuration is reloaded.
+	LogLevel string
+
 	// Logger *logrus.Logger is a type of logger that is used to record events and errors in a program. It helps developers
 	// to track down issues, monitor application performance, and audit user activity. It can also be used to provide
 	// detailed debugging information, which is useful for troubleshooting issues.
 	Logger *logrus.Logger
 
+	// statements are the prepared statements of the hot path queries by their text, see the Statement function.
+	statements sync.Map
+
 	// A mutex is a synchronization mechanism used to control access to shared resources in a multi-threaded environment. It
 	// is used to ensure that only one thread can access a resource at a given time, thus preventing race conditions and
 	// data corruption. The sync.Mutex object allows threads to take ownership of the resource, thus ensuring that no other
@@ -120,10 +307,135 @@ type Context struct {
 	// typically contains information such as the server name, port, database name, and authentication credentials.
 	PostgresConnect string
 
+	// ReplicaConnect is the connection string of the read-only replica of the database, the candles, the histories of the
+	// orders and the reports are read from it, so that they do not compete with the settlement for the primary. Without it
+	// all the queries go to the primary.
+	ReplicaConnect string
+
 	// Timezones is a string that is used to store information about the different time zones that a person may need to
 	// interact with. It can be used to help convert between different time zones and to manage scheduling of events.
 	Timezones string
 
+	// DecimalFormat is the format of the floating point fields in the published events and in the http responses: "number"
+	// (the default) writes them as json numbers, "string" writes them as strings, and "both" keeps the numbers in the events
+	// while also publishing a copy of every event with strings, for the clients that are migrating to the new format.
+	DecimalFormat string
+
+	// PushRetention is the number of days the events pushed to the private channels of the users are kept, so that the
+	// clients which were offline can reconcile the events they have missed.
+	PushRetention int
+
+	// CandleThrottle is the number of the milliseconds the candles of a pair of an interval are published at most once per,
+	// the trades in the meantime are coalesced into the latest candle, and the candle that has just opened is published at
+	// once. Zero publishes the candles on every trade. candles are the candles that wait for their publishing.
+	CandleThrottle int
+	candles        throttle.Throttle
+
+	// ClosureCooling is the number of days between the request of the user to close the account and its anonymization, the
+	// user can cancel the closure within the period. ExportRetention is the number of days the archives of the personal data
+	// exported by the users are kept for the download.
+	ClosureCooling  int
+	ExportRetention int
+
+	// SupportSla is the number of minutes within which the operators have to respond to an alert of a priority account, when
+	// the time runs out the alert is escalated to the next level. SupportStuck is the number of minutes after which a pending
+	// deposit of a priority account is considered stuck.
+	SupportSla   int
+	SupportStuck int
+
+	// Reports are the schemas of the regulators the end-of-day transaction reports are generated for, one per jurisdiction.
+	Reports []*Report
+
+	// Canary enables the shadow mode of the matching engine: every incoming order is also matched by the candidate engine
+	// against the same book, without writing anything, and the fills which differ from the live engine are reported.
+	Canary bool
+
+	// Screening is the service the risk of the incoming deposits is scored by, the deposits scored at least the configured
+	// score are held until the operators release or return them. Without an endpoint the deposits are not screened.
+	Screening *Screening
+
+	// Sms is the gateway the security codes and the alerts are sent through by sms, and Telegram is the bot they are sent by
+	// to the chats of the users. The users choose the order of the channels and the email is the fallback of them, so
+	// without the gateway and the bot the notifications are sent by email only.
+	Sms      *notify.Sms
+	Telegram *notify.Telegram
+
+	// Geo is the geolocation service the addresses of the sign ins are located by, the sign in from a new country or from a
+	// location the user could not have traveled to since the previous sign in requires the step-up verification. Without
+	// an endpoint the sign ins are not located. Lockout is the protection of the sign in against the guessing.
+	Geo     *geoip.Client
+	Lockout *Lockout
+
+	// Siwe is the sign in with the Ethereum wallets, the users sign the message with the nonce of the server by the wallet
+	// instead of the password. Without it the wallets are neither signed in with nor linked to the accounts.
+	Siwe *Siwe
+
+	// Surveillance are the thresholds the trading is analyzed with for the wash trading, the spoofing and the pumps, the cases
+	// flagged are queued for the review of the compliance officers. Without them the trading is not analyzed.
+	Surveillance *surveillance.Thresholds
+
+	// Reconciliation are the thresholds the balances are reconciled with the history of the deposits, withdrawals and trades.
+	Reconciliation *Reconciliation
+
+	// Entropy are the master keys of the envelope encryption of the entropies of the accounts, without them the entropies
+	// are written in the clear, which is meant for the local development.
+	Entropy *Entropy
+
+	// Signers are the external signing services of the hot wallets, one per chain. The withdrawals of a chain without a
+	// signer are signed by the software signer with the keys derived in the process, which is meant for the local development.
+	Signers []*Signer
+
+	// Webhooks are the third-party node providers that report the deposits and the withdrawals of the chains with signed
+	// webhooks, one per chain, as an alternative or in addition to the scanners of the chains.
+	Webhooks []*Webhook
+
+	// Gas are the gas reserves of the chains, one per chain, the hot wallets of the tokens of the chains without one are
+	// topped up from any reserve of the coin when their withdrawals are short of the fees.
+	Gas []*Gas
+
+	// Rebalance is the rebalancing of the reserves the withdrawals are sent from, without it the withdrawals that no reserve
+	// covers wait until the operators move the reserves themselves.
+	Rebalance *Rebalance
+
+	// Sources is the treatment of the deposits from the external addresses the users proved to own, without it the deposits
+	// from them are marked as the deposits from the verified sources and are held as the other deposits.
+	Sources *Sources
+
+	// Ramp is the automatic conversion of the deposits of the stablecoins into the currencies the users chose, without it the
+	// deposits are credited as they are.
+	Ramp *Ramp
+
+	// Sandbox is the sandbox mode of the deployment on the testnets with the faucet of the test balances, without it the
+	// exchange runs on the chains as they are recorded. The sandbox is never applied by the reload of the configuration.
+	Sandbox *Sandbox
+
+	// Paper is the paper trading the users test their strategies with on the simulated balances, without it the paper trading
+	// is not available.
+	Paper *Paper
+
+	// Pool are the limits of the pool of the connections of the database, the settlement and the scanners share the pool
+	// with the requests, so the pool is sized to the connections the database allows the process to open.
+	Pool *Pool
+
+	// Partitions are the policies of the chunks of the hypertables of the trades, the transfers and the ticks, and of the
+	// continuous aggregates of the candles, they are applied by the provider when the service starts and after the reloads.
+	Partitions []*Partition
+
+	// Retry is the policy of the publishing of the events to the broker, the failed publishing is repeated after the backoff
+	// and the events the broker did not accept after all the attempts are written to the dead letters. Without it the event
+	// is published three times with a short backoff. deliveries are the events that wait for the broker, by their topics.
+	Retry      *Retry
+	deliveries queue.Queue
+
+	// Scanner is the catch-up of the scanners of the chains that fell behind the heads of the chains, the lag of the scanners
+	// is recorded by the metrics and with the chains. Without it the scanners read one block per pass.
+	Scanner *Scanner
+
+	// Tracing is the collector the spans of the requests, the queries, the published events and the calls of the nodes are
+	// exported to, so that the slow orders and deposits can be followed through the services. Without an endpoint the
+	// traces are only propagated from the callers, nothing is recorded.
+	Tracing *trace.Config
+
 	// KYC: KYC (Know Your Customer) is a process used by organizations to verify the identity of their customers and assess their suitability for doing business.
 	// SMTP: Simple Mail Transfer Protocol (SMTP) is a protocol for sending emails. It is used to transfer emails from one server to another over the Internet.
 	// Server: A server is a computer that provides services to other computers or clients on a network. It can host websites, applications and other services.
@@ -134,7 +446,9 @@ type Context struct {
 	// RedisClient: This is a Redis client which is used for caching and storing data in a NoSQL key-value store.
 	// GrpcClient: This is a gRPC client which is used for communicating with a remote server using a high-performance RPC protocol.
 	// Db: This is a SQL database which is used for storing and managing relational data.
+	// Replica: This is the read-only replica of the database, nil when no replica is configured, see the Reader function.
 	// KycProvider: This is a KYC provider which is used to verify the identity of users for compliance with anti-money laundering regulations.
+	// Locales: This is the catalogue of the translations of the messages returned to the users, read from the static/i18n directory.
 
 	Kyc            *Kyc
 	Smtp           *Smtp
@@ -146,7 +460,9 @@ type Context struct {
 	RedisClient    *redis.Client
 	GrpcClient     *grpc.ClientConn
 	Db             *sql.DB
+	Replica        *sql.DB
 	KycProvider    *kycaid.Api
+	Locales        *i18n.Catalogue
 }
 
 // This function is used to set up the application context. It locks the mutex, reads the configuration file, sets the
@@ -159,18 +475,13 @@ func (app *Context) Write() *Context {
 	// doing this, it ensures that data is not corrupted or overwritten by multiple threads accessing it simultaneously.
 	app.Mutex.Lock()
 
-	// This code is used to read a file located at the location stored in the app.ConfigPath() variable. It uses the
-	// ioutil.ReadFile() function to read the contents of the file as a byte array and store it in the serialize variable.
-	// If there is an error encountered while reading the file, to err variable will contain the error and the
-	// logrus.Fatal() function is used to log the error and terminate the program.
-	serialize, err := ioutil.ReadFile(app.ConfigPath())
-	if err != nil {
+	// The configuration file is read into the context, the secrets are overridden with the environment variables, and the
+	// configuration is validated before anything is connected, all the problems of the configuration are reported at once.
+	if err := app.read(app.ConfigPath()); err != nil {
 		logrus.Fatal(err)
 	}
 
-	// This code is used to convert the serialized data in the variable "serialize" into the "app" variable using the
-	// json.Unmarshal method. If an error occurs, it is logged using logrus.Fatal and the program will terminate.
-	if err = json.Unmarshal(serialize, &app); err != nil {
+	if err := app.validate(); err != nil {
 		logrus.Fatal(err)
 	}
 
@@ -204,28 +515,34 @@ func (app *Context) Write() *Context {
 	// messages will be printed to the standard output and written to the writer object.
 	app.Logger.SetOutput(io.MultiWriter(os.Stdout, writer))
 
-	// The purpose of app.Logger.SetLevel is to set the logging level for the application. This enables the application to
-	// log different types of messages depending on their severity. The different log levels are ErrorLevel, FatalLevel, and
-	// WarnLevel. ErrorLevel will log errors, FatalLevel will log fatal errors, and WarnLevel will log warnings.
-	app.Logger.SetLevel(logrus.ErrorLevel)
-	app.Logger.SetLevel(logrus.FatalLevel)
-	app.Logger.SetLevel(logrus.WarnLevel)
-
-	// The purpose of the code is to set the log level for the application depending on if it is in development or not. If
-	// the application is in development, the log level is set to InfoLevel and DebugLevel, otherwise it is set to whatever the default log level is.
-	if app.Development {
-		app.Logger.SetLevel(logrus.InfoLevel)
-		app.Logger.SetLevel(logrus.DebugLevel)
-	}
+	// The level of the logger is the configured level, or the debug level in the development and the warning level otherwise.
+	level, _ := app.level()
+	app.Logger.SetLevel(level)
 
 	// This code is used to open a connection to a PostgreSQL database using the app.PostgresConnect parameter for the
 	// connection string. The connection is stored in a variable called app.Db and is used for subsequent database
 	// operations. If an error occurs during the opening of the connection, it is logged using the logrus library and the
 	// program exits with a fatal error.
-	app.Db, err = sql.Open("postgres", app.PostgresConnect)
+	postgres, err := pq.NewConnector(app.PostgresConnect)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	app.Db = sql.OpenDB(connector{Connector: postgres})
+
+	// The replica is opened the same way as the primary, the reads that tolerate the lag of the replication are routed to it.
+	if len(app.ReplicaConnect) > 0 {
+		replica, err := pq.NewConnector(app.ReplicaConnect)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		app.Replica = sql.OpenDB(connector{Connector: replica})
+	}
+	app.pool()
+
+	// The exporter of the traces is started before the clients, so that their first calls are traced as well.
+	if app.Tracing != nil && len(app.Tracing.Endpoint) > 0 {
+		trace.Setup(*app.Tracing)
+	}
 
 	// Ping the database to ensure a connection is successful.
 	err = app.Db.Ping()
@@ -233,6 +550,20 @@ func (app *Context) Write() *Context {
 		logrus.Fatal(err)
 	}
 
+	if app.Replica != nil {
+		if err := app.Replica.Ping(); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	// The chains of the sandbox are connected to their testnets before any scanner starts, and the sandbox never starts
+	// against the chains of the main networks.
+	if app.Sandbox != nil {
+		if err := app.sandbox(); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
 	// The code above is creating a new redis client connection with the specified Redis host, password, and DB from the
 	// app. It allows the app to interact with Redis and perform operations such as retrieving or setting data.
 	app.RedisClient = redis.NewClient(&redis.Options{
@@ -262,6 +593,13 @@ func (app *Context) Write() *Context {
 		logrus.Fatal(err)
 	}
 
+	// The catalogue of the translations is read once, the messages of the locales without the translations are returned as
+	// they are written in the code.
+	app.Locales, err = i18n.Load("./static/i18n")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	// App.Mutex.Unlock() is a function that unlocks a mutex, which is a synchronization primitive that allows only one
 	// thread to access a shared resource at a time. It is used to ensure that multiple threads do not access a shared
 	// resource simultaneously, which can cause unexpected results.
@@ -270,11 +608,95 @@ func (app *Context) Write() *Context {
 	return app
 }
 
+// Signer - This function returns the external signer configured for the chain, or nil if the withdrawals of the chain are
+// signed by the software signer.
+func (app *Context) Signer(chainId int64) *Signer {
+	for _, signer := range app.Signers {
+		if signer.ChainId == chainId && len(signer.Endpoint) > 0 {
+			return signer
+		}
+	}
+	return nil
+}
+
+// Webhook - This function returns the provider of the webhooks configured for the chain, or nil if the chain is only scanned.
+func (app *Context) Webhook(chainId int64) *Webhook {
+	for _, webhook := range app.Webhooks {
+		if webhook.ChainId == chainId {
+			return webhook
+		}
+	}
+	return nil
+}
+
+// Keyring - This function decodes the master keys of the entropies from the configuration, by their ids.
+func (app *Context) Keyring() (map[string][]byte, error) {
+
+	keys := make(map[string][]byte)
+	if app.Entropy == nil {
+		return keys, nil
+	}
+
+	for id, key := range app.Entropy.Keys {
+		decode, err := hex.DecodeString(key)
+		if err != nil {
+			return keys, fmt.Errorf("the master key %v is not correct: %v", id, err)
+		}
+		keys[id] = decode
+	}
+
+	return keys, nil
+}
+
+// SealEntropy - This function seals the entropy of the account with a new data key wrapped by the primary master key, if no
+// master key is configured the entropy is returned as is.
+func (app *Context) SealEntropy(entropy []byte) ([]byte, error) {
+
+	if app.Entropy == nil || len(app.Entropy.Primary) == 0 {
+		return entropy, nil
+	}
+
+	keys, err := app.Keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	master, ok := keys[app.Entropy.Primary]
+	if !ok {
+		return nil, fmt.Errorf("the primary master key %v is not found", app.Entropy.Primary)
+	}
+
+	return envelope.Seal(app.Entropy.Primary, master, entropy)
+}
+
+// OpenEntropy - This function decrypts the sealed entropy of the account, the entropies written in the clear before the
+// encryption was enabled are returned as is.
+func (app *Context) OpenEntropy(entropy []byte) ([]byte, error) {
+
+	if !envelope.Sealed(entropy) {
+		return entropy, nil
+	}
+
+	keys, err := app.Keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	return envelope.Open(keys, entropy)
+}
+
 // Auth - This function is used to authenticate users in a context-based application. It uses JWT to parse the authorization
 // token from the incoming context and uses the secret key stored in the application's Secrets to validate the token.
-// Once the token is validated, it returns the user's personal data that was previously encoded.
+// Once the token is validated, it returns the user's personal data that was previously encoded. The sessions signed in
+// with the Ethereum wallets carry the address of the wallet, they are honored while the wallet is linked to the account.
 func (app *Context) Auth(ctx context.Context) (int64, error) {
 
+	// The requests served by the grpc server are authenticated once by the interceptor of the server, the result of the
+	// interceptor is returned, since the signature of a signed request can not be checked a second time.
+	if item, ok := ctx.Value(authenticated{}).(*authentication); ok {
+		return item.user, item.err
+	}
+
 	// The purpose of this code is to extract the metadata from the incoming context (ctx) and assign it to the meta
 	// variable. Metadata is a key-value map containing information about the context, such as details about the request, the user, etc.
 	meta, _ := metadata.FromIncomingContext(ctx)
@@ -289,7 +711,12 @@ func (app *Context) Auth(ctx context.Context) (int64, error) {
 	// This line of code is used to parse a JWT token from an authorization header. It takes the authorization header value
 	// and splits it into two parts, taking the second part as the token. It then uses the token and the app.Secrets[0] byte
 	// array to parse the token. If the token is valid, it will return the token, otherwise it will return an error.
-	token, err := jwt.Parse(strings.Split(meta["authorization"][0], "Bearer ")[1], func(token *jwt.Token) (interface{}, error) {
+	bearer, ok := strings.CutPrefix(meta["authorization"][0], "Bearer ")
+	if !ok {
+		return 0, status.Error(10010, "missing metadata")
+	}
+
+	token, err := jwt.Parse(bearer, func(token *jwt.Token) (interface{}, error) {
 		return []byte(app.Secrets[0]), nil
 	})
 	if err != nil {
@@ -301,18 +728,117 @@ func (app *Context) Auth(ctx context.Context) (int64, error) {
 	// The purpose of this code is to get a user's ID from the JWT so that the application can identify the user and grant
 	// them access to the appropriate resources.
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+
+		// The access token of the session that was logged out or whose refresh token was reused is rejected.
+		if err := app.revoked(ctx, claims); err != nil {
+			return 0, err
+		}
+
+		// The access token of the wallet sign in is rejected once the wallet is unlinked from the account.
+		if err := app.linked(ctx, claims); err != nil {
+			return 0, err
+		}
+
+		// The access token of the impersonation is rejected once the impersonation has expired or was ended by the operator.
+		if err := app.impersonated(ctx, claims); err != nil {
+			return 0, err
+		}
+
 		return int64(claims["sub"].(float64)), nil
 	}
 
 	return 0, nil
 }
 
+// signature - This function validates the signed request parameters passed in the metadata. If the request carries no
+// timestamp it is treated as unsigned and passes through without the checks of the receive window and of the replay, so
+// that the existing clients of the bearer tokens keep working, the requests of the api keys carry the timestamp always.
+// Otherwise, the timestamp must fall into the receive window, the signature must match the HMAC-SHA256 of the method,
+// the timestamp, the receive window and the sha256 of the serialized request, keyed with the signing secret of the
+// session or of the api key, which is never sent with the requests, and the same signature must not have been used
+// before within the window. The request is serialized deterministically, the streams are signed with the empty request,
+// since their messages are received by the handlers.
+func (app *Context) signature(ctx context.Context, meta metadata.MD, method string, req interface{}, secret string) error {
+
+	// The purpose of this code is to skip the validation for the requests that were not signed by the client, so that
+	// the existing clients keep working.
+	if len(meta["timestamp"]) == 0 {
+		return nil
+	}
+
+	// This code parses the timestamp of the request in milliseconds, the request is rejected if it is not a number.
+	timestamp, err := strconv.ParseInt(meta["timestamp"][0], 10, 64)
+	if err != nil {
+		return status.Error(10011, "invalid request timestamp")
+	}
+
+	// This code parses the receive window of the request, if it is missing the default window is used.
+	recvWindow := help.RecvWindowDefault
+	if len(meta["recv-window"]) > 0 {
+		if recvWindow, err = strconv.ParseInt(meta["recv-window"][0], 10, 64); err != nil {
+			return status.Error(10012, "invalid request recv window")
+		}
+	}
+
+	// This code checks that the timestamp of the request is within the receive window relative to the server time.
+	if !help.Window(timestamp, recvWindow, time.Now()) {
+		return status.Errorf(10013, "timestamp for this request is outside of the recv window, server time %v", time.Now().UnixMilli())
+	}
+
+	// This code serializes the request the way the client does before signing it, the signature covers the digest of the
+	// serialized request, so that neither the method nor the parameters of the request can be changed on the way.
+	var (
+		body []byte
+	)
+
+	if message, ok := req.(proto.Message); ok {
+		if body, err = (proto.MarshalOptions{Deterministic: true}).Marshal(message); err != nil {
+			return status.Error(10014, "invalid request signature")
+		}
+	}
+
+	digest := sha256.Sum256(body)
+
+	// This code compares the signature passed by the client with the one computed on the server side, the tokens without
+	// the signing secret can not sign the requests.
+	if len(secret) == 0 || len(meta["signature"]) == 0 || !hmac.Equal([]byte(meta["signature"][0]), []byte(help.SignatureHmac256(secret, method, timestamp, recvWindow, hex.EncodeToString(digest[:])))) {
+		return status.Error(10014, "invalid request signature")
+	}
+
+	// The purpose of this code is to remember the signature in redis for the duration of the window, so that the same
+	// signed request can not be sent a second time.
+	if ok, err := app.RedisClient.SetNX(ctx, fmt.Sprintf("signature:%v", meta["signature"][0]), timestamp, time.Duration(help.RecvWindowMax)*time.Millisecond).Result(); err != nil || !ok {
+		return status.Error(10015, "this signed request has already been processed")
+	}
+
+	return nil
+}
+
 // Publish - This function is used to publish data to a specific topic on a given channel.
 // It takes in a data interface, a topic string, and a variable list of channel strings.
 // It uses the json package to marshal the data interface into a string.
 // It then iterates through the channel list and uses the RabbitmqClient to publish the data string to the given topic on the given channel.
 // Finally, it returns nil if the publication is successful.
 func (app *Context) Publish(data interface{}, topic string, channel ...string) error {
+	return app.PublishContext(context.Background(), data, topic, channel...)
+}
+
+// Throttle - This function publishes the candle of the key, such as the pair and the interval, throttled by the CandleThrottle:
+// the flush function reads and publishes the candle, it is called at once for the new window of the candle and at most
+// once per the throttle for the same window, with the latest state of the candle. The errors of the flush are logged.
+func (app *Context) Throttle(key string, window int64, flush func() error) {
+	app.candles.Push(key, window, time.Duration(app.CandleThrottle)*time.Millisecond, func() {
+		app.Debug(flush())
+	})
+}
+
+// PublishContext - This function publishes the data like the Publish function, as a part of the trace of the context: the
+// publishing is recorded as a span, and the traceparent of the span is passed with the messages, so that the consumers
+// can join the trace.
+func (app *Context) PublishContext(ctx context.Context, data interface{}, topic string, channel ...string) (err error) {
+
+	ctx, span := trace.Start(ctx, "publish "+topic, trace.KindProducer, trace.String("messaging.system", "mqtt"), trace.String("messaging.destination", topic), trace.String("messaging.channels", strings.Join(channel, ",")))
+	defer func() { span.End(err) }()
 
 	// The Marshal struct is used to store data in a standardized format which is capable of being encoded and decoded as
 	// JSON. The structure contains two fields: Channel and Data. The Channel field is a string that identifies the source
@@ -321,11 +847,18 @@ func (app *Context) Publish(data interface{}, topic string, channel ...string) e
 	type Marshal struct {
 		Channel string `json:"channel"`
 		Data    string `json:"data"`
+		Trace   string `json:"trace,omitempty"`
 	}
 
 	// This code is used in a for loop to iterate through the elements of a channel. The for loop sets the variable 'i' to 0
 	// and then checks to see if 'i' is less than the length of the channel. If it is, it will then execute the code within
 	// the loop and then increment 'i' by 1. The loop continues to run until 'i' is no longer less than the length of the channel.
+	// The events of the users are published to their own topics, the events without a user are public.
+	var user int64
+	if owner, ok := data.(interface{ GetUserId() int64 }); ok {
+		user = owner.GetUserId()
+	}
+
 	for i := 0; i < len(channel); i++ {
 
 		// This code is attempting to marshal (convert) a data object into a JSON object. The json.Marshal function will return
@@ -336,30 +869,97 @@ func (app *Context) Publish(data interface{}, topic string, channel ...string) e
 			return err
 		}
 
-		// This code is serializing a struct of type Marshal, which contains two fields, Channel and Data. The Channel field is
-		// set to the value of the i-th element of the channel array, and the Data field is set to the value of the serialize
-		// variable. If any errors occur while serializing, the code returns an error.
-		serialize, err = json.Marshal(Marshal{
-			Channel: channel[i],
-			Data:    string(serialize),
-		})
-		if err != nil {
-			return err
+		// The floating point fields are written as strings if it is the configured format of the events, in the "both"
+		// format the event is published as it is, and a copy with strings goes to a separate channel with the ":decimal" suffix.
+		messages := map[string][]byte{channel[i]: serialize}
+		switch app.DecimalFormat {
+		case DecimalString:
+			if messages[channel[i]], err = stringify(data, serialize); err != nil {
+				return err
+			}
+		case DecimalBoth:
+			if messages[channel[i]+":decimal"], err = stringify(data, serialize); err != nil {
+				return err
+			}
+		}
+
+		for name, message := range messages {
+
+			// This code is serializing a struct of type Marshal, which contains two fields, Channel and Data. The Channel field is
+			// set to the name of the channel, and the Data field is set to the value of the serialized message. If any errors
+			// occur while serializing, the code returns an error.
+			serialize, err := json.Marshal(Marshal{
+				Channel: name,
+				Data:    string(message),
+				Trace:   trace.Traceparent(ctx),
+			})
+			if err != nil {
+				return err
+			}
+
+			// The message is delivered by the policy of the publishing by the worker of its topic, so that the publishing does not
+			// wait for the broker and the messages of a topic reach it in the order they were published, the failed attempts
+			// are repeated and the message that is not accepted goes to the dead letters. The events of a user are published
+			// to the topic of the user, which only the user is granted to read.
+			var (
+				route   = broker.Topic(topic, name, user)
+				qos     = broker.Qos(topic, route)
+				payload = string(serialize)
+			)
+
+			if !app.deliveries.Push(route, lane, func() { app.deliver(route, qos, name, payload) }) {
+				app.deliver(route, qos, name, payload)
+			}
 		}
 
-		// The purpose of this code is to publish a message to a given topic using the app.RabbitmqClient. The message is
-		// serialized as a byte(2) and the boolean false indicates that the message is not retained by the broker. The
-		// string(serialize) is used to indicate the serialized message that needs to be published.
-		app.RabbitmqClient.Publish(topic, byte(2), false, string(serialize))
+		// The events addressed to a user are also stored in the push history, so that they can be requested later by the
+		// clients that were offline when the event was published.
+		app.push(data, channel[i])
 	}
 
 	return nil
 }
 
+// stringify - This function writes the floating point fields of the serialized event as strings, if the event is a protobuf
+// message, any other event is returned as it is.
+func stringify(data interface{}, serialize []byte) ([]byte, error) {
+	if message, ok := data.(interface{ ProtoReflect() protoreflect.Message }); ok {
+		return decimal.Stringify(message.ProtoReflect(), serialize, "")
+	}
+	return serialize, nil
+}
+
+// push - This function stores the event in the push history if it belongs to a user, that is if the data carries the id of the
+// user it is addressed to. The errors are only logged, since the history must not break the publishing itself.
+func (app *Context) push(data interface{}, channel string) {
+
+	// This code checks that the data belongs to a user, the events without a user are public and are not stored.
+	owner, ok := data.(interface{ GetUserId() int64 })
+	if !ok || owner.GetUserId() == 0 {
+		return
+	}
+
+	// The support alerts are addressed to the operators and not to the user, so they are not kept in the history of the user.
+	if strings.HasPrefix(channel, "support/") {
+		return
+	}
+
+	// The data is stored in the same json format in which it is published.
+	serialize, err := json.Marshal(data)
+	if app.Debug(err) {
+		return
+	}
+
+	if _, err := app.Db.Exec("insert into pushes (user_id, channel, data) values ($1, $2, $3)", owner.GetUserId(), channel, serialize); app.Debug(err) {
+		return
+	}
+}
+
 // Recovery - This function is used to recover from unexpected errors in a Go application. It takes an interface as an argument and
 // returns an error. It generates an error message with the given expression included, which allows the application to
 // identify the source of the unexpected error.
 func (app *Context) Recovery(expr interface{}) error {
+	app.Logger.WithField("stack", string(debug.Stack())).Error(expr)
 	return status.Errorf(codes.Internal, "Unexpected error: (%+v)", expr)
 }
 
@@ -0,0 +1,49 @@
+package blockchain
+
+import (
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/pkg/errors"
+	"math/big"
+	"strings"
+)
+
+// BalanceAt - This function returns the balance of the native coin of the chain at the given address in its smallest units (wei
+// for Ethereum, sun for Tron). It is used to track the balances of the external addresses, which are not controlled by
+// the exchange and whose history is not known from the beginning.
+func (p *Params) BalanceAt(address string) (balance *big.Int, err error) {
+
+	balance = new(big.Int)
+
+	// The purpose of the switch statement is to set the query of the balance request depending on the platform.
+	switch p.platform {
+	case types.PlatformEthereum:
+		p.query = []string{"-X", "POST", "-H", "Content-Type:application/json", "-H", "Accept: application/json", "-d", fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getBalance","params":["%v", "latest"],"id":1}`, address), p.rpc}
+	case types.PlatformTron:
+		p.query = []string{"-X", "POST", fmt.Sprintf("%v/wallet/getaccount", p.rpc), "-d", fmt.Sprintf(`{"address": "%v", "visible": true}`, address)}
+	default:
+		return balance, errors.New("method not found!...")
+	}
+
+	if err := p.commit(); err != nil {
+		return balance, err
+	}
+
+	// The Ethereum node returns the balance as a hexadecimal string, the Tron node returns it as a number.
+	switch p.platform {
+	case types.PlatformEthereum:
+		result, ok := p.response["result"].(string)
+		if !ok {
+			return balance, errors.New("the balance was not found!...")
+		}
+		if _, ok := balance.SetString(strings.TrimPrefix(result, "0x"), 16); !ok && len(strings.TrimPrefix(result, "0x")) > 0 {
+			return balance, errors.New("the balance is malformed!...")
+		}
+	case types.PlatformTron:
+		if result, ok := p.response["balance"].(float64); ok {
+			balance.SetInt64(int64(result))
+		}
+	}
+
+	return balance, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/cryptogateway/backend-envoys/server/types"
 	"github.com/pkg/errors"
+	"strconv"
 	"strings"
 )
 
@@ -41,6 +42,104 @@ func (p *Params) BlockByNumber(number int64) (block *Block, err error) {
 	return p.block()
 }
 
+// Head - This function returns the number of the latest block of the chain, the head the scanners measure their lag from and the
+// endpoints of the chain are compared by: the eth_blockNumber of the json-rpc of Ethereum, or the number of the header of
+// the now block of the http api of Tron.
+func (p *Params) Head() (number int64, err error) {
+
+	switch p.platform {
+	case types.PlatformEthereum:
+		p.query = []string{"-X", "POST", "-H", "Content-Type:application/json", "-H", "Accept: application/json", "-d", `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`, p.rpc}
+	case types.PlatformTron:
+		p.query = []string{"-X", "POST", fmt.Sprintf("%v/wallet/getnowblock", p.rpc)}
+	default:
+		return 0, errors.New("method not found!...")
+	}
+
+	if err := p.commit(); err != nil {
+		return 0, err
+	}
+
+	switch p.platform {
+	case types.PlatformEthereum:
+
+		if result, ok := p.response["result"].(string); ok {
+			return strconv.ParseInt(strings.TrimPrefix(result, "0x"), 16, 64)
+		}
+
+	case types.PlatformTron:
+
+		if header, ok := p.response["block_header"].(map[string]interface{}); ok {
+			if raws, ok := header["raw_data"].(map[string]interface{}); ok {
+				if number, ok := raws["number"].(float64); ok {
+					return int64(number), nil
+				}
+			}
+		}
+	}
+
+	return 0, errors.New("the head of the chain was not found")
+}
+
+// Hash - This function returns the hash of the block of the canonical chain at the height, the block the node returns for the
+// number now, which is not the block the scanner read when the chain was reorganized in the meantime.
+func (p *Params) Hash(height int64) (string, error) {
+
+	block, err := p.BlockByNumber(height)
+	if err != nil {
+		return "", err
+	}
+
+	if len(block.Hash) == 0 {
+		return "", errors.New("the hash of the block was not found")
+	}
+
+	return block.Hash, nil
+}
+
+// Locate - This function returns the number of the block the transaction with the hash is mined in on the canonical chain: the
+// block of the receipt of eth_getTransactionReceipt of Ethereum, or of the info of gettransactioninfobyid of Tron. The
+// zero block is the transaction that is not mined, or that is no longer mined after the reorganization of the chain.
+func (p *Params) Locate(hash string) (int64, error) {
+
+	switch p.platform {
+	case types.PlatformEthereum:
+		p.query = []string{"-X", "POST", "-H", "Content-Type:application/json", "-H", "Accept:application/json", "-d", fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getTransactionReceipt","params":["%s"],"id":1}`, hash), p.rpc}
+	case types.PlatformTron:
+		p.query = []string{"-X", "POST", fmt.Sprintf("%v/wallet/gettransactioninfobyid", p.rpc), "-d", fmt.Sprintf(`{"value": "%s"}`, hash)}
+	default:
+		return 0, errors.New("method not found!...")
+	}
+
+	// The node of Tron answers the transaction it does not know with the empty object, the node that does not answer returns
+	// no object at all.
+	response, err := p.get()
+	if err != nil {
+		if response != nil && len(response) == 0 {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	switch p.platform {
+	case types.PlatformEthereum:
+
+		if result, ok := response["result"].(map[string]interface{}); ok {
+			if number, ok := result["blockNumber"].(string); ok {
+				return strconv.ParseInt(strings.TrimPrefix(number, "0x"), 16, 64)
+			}
+		}
+
+	case types.PlatformTron:
+
+		if number, ok := response["blockNumber"].(float64); ok {
+			return int64(number), nil
+		}
+	}
+
+	return 0, nil
+}
+
 // block - This function is used to process and parse the data received from a blockchain platform, such as Ethereum or Tron. It
 // uses a switch statement to determine which platform the data is from and then uses type assertions, type switches, and
 // looping to process the data accordingly. It then serializes the data into a byte array and converts it back into a
@@ -1,14 +1,20 @@
 package blockchain
 
 import (
-	"crypto/ecdsa"
+	"context"
 	"encoding/json"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
 	"github.com/pkg/errors"
 	"math/big"
 	"os/exec"
+	"path"
+	"regexp"
 )
 
+// method - The pattern of the method of the json-rpc requests of the nodes, the requests of the http api of tron are named by their paths.
+var method = regexp.MustCompile(`"method":"([a-zA-Z_]+)"`)
+
 // The purpose of these constants is to provide a way to distinguish between different types of data. By assigning each
 // type a unique numerical value, it makes it easier to identify and organize the data. For example, if you have a system
 // that stores information about both internal and contract data, you can use these constants to differentiate between them.
@@ -65,17 +71,19 @@ type Transaction struct {
 
 // Params - This is a struct used to store data related to a specific function. It is used to store data that will be used in the
 // function, as well as the results of the function. The data stored includes a rpc string, a platform, a response map, a
-// query array, a private key, a network, a success boolean and a stop boolean.
+// query array, the signer of the transactions, a network, a success boolean and a stop boolean. The context carries the trace
+// the requests of the node are recorded in.
 type Params struct {
-	rpc      string
-	platform string
-	response map[string]interface{}
-	query    []string
-	private  *ecdsa.PrivateKey
-	network  *big.Int
-	gas      uint64
-	success  bool
-	stop     bool
+	ctx       context.Context
+	rpc       string
+	platform  string
+	response  map[string]interface{}
+	query     []string
+	signatory Signer
+	network   *big.Int
+	gas       uint64
+	success   bool
+	stop      bool
 }
 
 // Dial - The purpose of the code is to test the connection to the blockchain and then create a Params struct using the given
@@ -99,6 +107,14 @@ func Dial(rpc, platform string) (*Params, error) {
 	}, nil
 }
 
+// WithContext - This function makes the next requests of the client as a part of the trace of the context, the requests of the
+// node are recorded as the spans of the trace and pass the traceparent header to the node. The client is returned, so that
+// the call can be chained.
+func (p *Params) WithContext(ctx context.Context) *Params {
+	p.ctx = ctx
+	return p
+}
+
 // commit - The purpose of this code is to make a get request with the p object, check for any errors, and store the response in
 // the p object if no errors are encountered.
 func (p *Params) commit() error {
@@ -128,9 +144,20 @@ func (p *Params) get() (response map[string]interface{}, err error) {
 		}
 	}()
 
+	// The request is recorded as the span of the trace of the context of the client, the header of the span is passed to the node.
+	query := p.query
+	if p.ctx != nil {
+		ctx, span := trace.Start(p.ctx, "chain "+p.method(), trace.KindClient, trace.String("rpc.system", p.platform), trace.String("rpc.method", p.method()))
+		defer func() { span.End(err) }()
+
+		if traceparent := trace.Traceparent(ctx); len(traceparent) > 0 {
+			query = append([]string{"-H", "traceparent:" + traceparent}, query...)
+		}
+	}
+
 	// This code is used to execute a command using the curl command and pass the values stored in the variable p.query as
 	// arguments. If the command fails, it returns an error.
-	cmd := exec.Command("curl", p.query...)
+	cmd := exec.Command("curl", query...)
 	if cmd.Err != nil {
 		return nil, cmd.Err
 	}
@@ -158,3 +185,19 @@ func (p *Params) get() (response map[string]interface{}, err error) {
 
 	return response, nil
 }
+
+// method - This function returns the name of the method of the request of the node, the method of the json-rpc request or the
+// last element of the path of the request of the http api of tron.
+func (p *Params) method() string {
+	for _, item := range p.query {
+		if match := method.FindStringSubmatch(item); match != nil {
+			return match[1]
+		}
+	}
+	for _, item := range p.query {
+		if len(item) > len(p.rpc) && item[:len(p.rpc)] == p.rpc {
+			return path.Base(item)
+		}
+	}
+	return "request"
+}
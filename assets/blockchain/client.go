@@ -0,0 +1,96 @@
+package blockchain
+
+import (
+	"context"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strings"
+)
+
+// Fees - This function returns the costs of a transfer of the coin and of a transfer of a token at the prices of the network
+// resources returned by the fee oracle, in the smallest units of the parent asset of the chain.
+func (p *Params) Fees() (coin, token *big.Int, err error) {
+
+	fee, err := p.Oracle()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return p.Cost(fee, false), p.Cost(fee, true), nil
+}
+
+// Estimate - This function returns the cost of the transfer of the amount to the address in the smallest units of the parent asset
+// of the chain, the transfer of the token of the contract or of the coin when the contract is empty. The signer of the
+// key is set on the client, the transfer is estimated from its address.
+func (p *Params) Estimate(key *platform.Key, to, contract string, amount *big.Int) (*big.Int, error) {
+
+	transfer, err := p.prepare(key, to, contract, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := p.EstimateGas(transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	return big.NewInt(fee), nil
+}
+
+// Send - This function signs the transfer of the amount to the address with the key, the transfer of the token of the contract or
+// of the coin when the contract is empty, broadcasts it and returns the hash of the transaction. The requests of the node
+// are recorded in the trace of the context.
+func (p *Params) Send(ctx context.Context, key *platform.Key, to, contract string, amount *big.Int) (hash string, err error) {
+
+	transfer, err := p.prepare(key, to, contract, amount)
+	if err != nil {
+		return hash, err
+	}
+	p.WithContext(ctx)
+
+	if hash, err = p.Transfer(transfer); err != nil {
+		return hash, err
+	}
+
+	if err := p.Transaction(); err != nil {
+		return hash, err
+	}
+
+	return hash, nil
+}
+
+// prepare - This function sets the signer of the key and the network of the chain on the client and returns the transfer of the
+// amount, with the data of the call of the token when the contract is not empty. The transfers of the key with the
+// external signer are signed by the signing service, the others with the private key in the memory of the process.
+func (p *Params) prepare(key *platform.Key, to, contract string, amount *big.Int) (*Transfer, error) {
+
+	if signer := key.Signer; signer != nil {
+
+		remote, err := NewRemote(signer.Endpoint, signer.Token, p.platform, key.Address, signer.Audit)
+		if err != nil {
+			return nil, err
+		}
+		p.Signatory(remote)
+
+	} else {
+
+		private, err := crypto.HexToECDSA(strings.TrimPrefix(key.Private, "0x"))
+		if err != nil {
+			return nil, err
+		}
+		p.Private(private)
+	}
+	p.Network(key.Network)
+
+	if len(contract) == 0 {
+		return &Transfer{To: to, Value: amount}, nil
+	}
+
+	data, err := p.Data(to, amount.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transfer{Contract: contract, Data: data}, nil
+}
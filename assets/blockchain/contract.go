@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/address"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"math/big"
+	"strings"
+)
+
+// Metadata - This function reads the symbol and the decimals of the token contract directly from the chain, by calling the
+// symbol() and decimals() methods of the contract. It is used to validate a contract before it is registered, so that a
+// wrong address or a wrong number of decimals does not end up crediting deposits with wrong amounts.
+func (p *Params) Metadata(contract string) (symbol string, decimals int32, err error) {
+
+	// The symbol is returned either as an abi encoded string, or by some older tokens as a fixed bytes32 value.
+	data, err := p.call(contract, "symbol()")
+	if err != nil {
+		return symbol, decimals, err
+	}
+
+	if symbol, err = unpack(data); err != nil {
+		return symbol, decimals, err
+	}
+
+	data, err = p.call(contract, "decimals()")
+	if err != nil {
+		return symbol, decimals, err
+	}
+
+	// The decimals are returned as an uint8 padded to 32 bytes, anything above 255 means the contract is not a token.
+	if len(data) != 32 || new(big.Int).SetBytes(data).Cmp(big.NewInt(255)) > 0 {
+		return symbol, decimals, errors.New("the decimals of the contract are malformed!...")
+	}
+
+	return symbol, int32(new(big.Int).SetBytes(data).Int64()), nil
+}
+
+// call - This function executes a read only call of the contract method without arguments and returns the raw result. For
+// Ethereum the eth_call method is used with the method selector, for Tron the triggerconstantcontract method is used.
+func (p *Params) call(contract, method string) (data []byte, err error) {
+
+	switch p.platform {
+	case types.PlatformEthereum:
+
+		// The data of the call is the first 4 bytes of the keccak hash of the method signature.
+		p.query = []string{"-X", "POST", "-H", "Content-Type:application/json", "-H", "Accept: application/json", "-d", fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_call","params":[{"to":"%v","data":"%v"}, "latest"],"id":1}`, contract, hexutil.Encode(crypto.Keccak256([]byte(method))[:4])), p.rpc}
+
+	case types.PlatformTron:
+
+		// The contract itself is used as the owner of the call, since a constant call does not spend any resources.
+		request := struct {
+			ContractAddress  string `json:"contract_address"`
+			FunctionSelector string `json:"function_selector"`
+			OwnerAddress     string `json:"owner_address"`
+		}{
+			ContractAddress:  address.New(contract).Hex(true),
+			FunctionSelector: method,
+			OwnerAddress:     address.New(contract).Hex(true),
+		}
+
+		marshal, err := json.Marshal(request)
+		if err != nil {
+			return data, err
+		}
+
+		p.query = []string{"-X", "POST", fmt.Sprintf("%v/wallet/triggerconstantcontract", p.rpc), "-d", string(marshal)}
+
+	default:
+		return data, errors.New("method not found!...")
+	}
+
+	if err := p.commit(); err != nil {
+		return data, err
+	}
+
+	switch p.platform {
+	case types.PlatformEthereum:
+		result, ok := p.response["result"].(string)
+		if !ok {
+			return data, errors.New("the contract did not respond!...")
+		}
+		if data, err = hexutil.Decode(result); err != nil {
+			return data, err
+		}
+	case types.PlatformTron:
+		result, ok := p.response["constant_result"].([]interface{})
+		if !ok || len(result) == 0 {
+			return data, errors.New("the contract did not respond!...")
+		}
+		if data, err = hex.DecodeString(fmt.Sprintf("%v", result[0])); err != nil {
+			return data, err
+		}
+	}
+
+	if len(data) == 0 {
+		return data, errors.New("the contract did not respond!...")
+	}
+
+	return data, nil
+}
+
+// unpack - This function decodes the abi encoded string returned by the contract: a 32 byte offset, a 32 byte length and
+// the string bytes. If the result is exactly 32 bytes long, it is treated as a bytes32 value padded with zeros.
+func unpack(data []byte) (string, error) {
+
+	if len(data) == 32 {
+		return strings.TrimSpace(string(bytes.TrimRight(data, "\x00"))), nil
+	}
+
+	if len(data) < 64 {
+		return "", errors.New("the symbol of the contract is malformed!...")
+	}
+
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsInt64() || offset.Int64()+32 > int64(len(data)) {
+		return "", errors.New("the symbol of the contract is malformed!...")
+	}
+
+	length := new(big.Int).SetBytes(data[offset.Int64() : offset.Int64()+32])
+	if !length.IsInt64() || offset.Int64()+32+length.Int64() > int64(len(data)) {
+		return "", errors.New("the symbol of the contract is malformed!...")
+	}
+
+	return strings.TrimSpace(string(data[offset.Int64()+32 : offset.Int64()+32+length.Int64()])), nil
+}
@@ -0,0 +1,154 @@
+package blockchain
+
+import (
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+const (
+	// tronBandwidth - The approximate size in bytes of the signed native and token transfers on Tron, every byte costs one
+	// point of the bandwidth, which is burned at the bandwidth price when the account has no free bandwidth left.
+	tronBandwidth      = 270
+	tronBandwidthToken = 345
+
+	// tronEnergy - The energy consumed by a trc20 transfer to an address that does not hold the token yet, it is the worst
+	// case of the transfer, the transfer to an address that holds the token consumes about half of it.
+	tronEnergy = 65000
+)
+
+// Fee - The Fee struct holds the current prices of the network resources returned by the fee oracle. For Ethereum it is the base
+// fee of the latest block, the priority fee suggested by the node and the legacy gas price in wei, for Tron it is the
+// price of the energy and of the bandwidth in sun.
+type Fee struct {
+	BaseFee     *big.Int
+	PriorityFee *big.Int
+	GasPrice    *big.Int
+	Energy      int64
+	Bandwidth   int64
+}
+
+// Oracle - This function requests the current prices of the network resources from the node. For Ethereum the base fee is taken
+// from the latest block (it is absent on the chains without EIP-1559) and the priority fee from eth_maxPriorityFeePerGas,
+// for Tron the energy and bandwidth prices are taken from the chain parameters.
+func (p *Params) Oracle() (fee *Fee, err error) {
+
+	fee = &Fee{
+		BaseFee:     new(big.Int),
+		PriorityFee: new(big.Int),
+		GasPrice:    new(big.Int),
+	}
+
+	switch p.platform {
+	case types.PlatformEthereum:
+
+		gasPrice, err := p.gasPrice()
+		if err != nil {
+			return fee, err
+		}
+		fee.GasPrice.SetInt64(gasPrice)
+
+		p.query = []string{"-X", "POST", "-H", "Content-Type:application/json", "-H", "Accept: application/json", "-d", `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest", false],"id":1}`, p.rpc}
+		if err := p.commit(); err != nil {
+			return fee, err
+		}
+
+		if result, ok := p.response["result"].(map[string]interface{}); ok {
+			if base, ok := result["baseFeePerGas"].(string); ok {
+				if fee.BaseFee, err = hexutil.DecodeBig(base); err != nil {
+					return fee, err
+				}
+			}
+		}
+
+		// The chains without EIP-1559 have no base fee, the whole legacy gas price is paid for the gas.
+		if fee.BaseFee.Sign() == 0 {
+			return fee, nil
+		}
+
+		// Not every node implements eth_maxPriorityFeePerGas, in this case the priority fee is what is left of the legacy gas
+		// price after the base fee.
+		p.query = []string{"-X", "POST", "-H", "Content-Type:application/json", "-H", "Accept: application/json", "-d", `{"jsonrpc":"2.0","method":"eth_maxPriorityFeePerGas","params":[],"id":1}`, p.rpc}
+		if err := p.commit(); err == nil {
+			if result, ok := p.response["result"].(string); ok {
+				if tip, err := hexutil.DecodeBig(result); err == nil {
+					fee.PriorityFee = tip
+				}
+			}
+		}
+
+		if fee.PriorityFee.Sign() == 0 && fee.GasPrice.Cmp(fee.BaseFee) > 0 {
+			fee.PriorityFee = new(big.Int).Sub(fee.GasPrice, fee.BaseFee)
+		}
+
+	case types.PlatformTron:
+
+		p.query = []string{"-X", "POST", fmt.Sprintf("%v/wallet/getchainparameters", p.rpc)}
+		if err := p.commit(); err != nil {
+			return fee, err
+		}
+
+		parameters, ok := p.response["chainParameter"].([]interface{})
+		if !ok {
+			return fee, errors.New("the chain parameters were not found!...")
+		}
+
+		for _, parameter := range parameters {
+			if parameter, ok := parameter.(map[string]interface{}); ok {
+				value, _ := parameter["value"].(float64)
+				switch parameter["key"] {
+				case "getEnergyFee":
+					fee.Energy = int64(value)
+				case "getTransactionFee":
+					fee.Bandwidth = int64(value)
+				}
+			}
+		}
+
+		if fee.Energy == 0 || fee.Bandwidth == 0 {
+			return fee, errors.New("the resource prices were not found!...")
+		}
+
+	default:
+		return fee, errors.New("method not found!...")
+	}
+
+	return fee, nil
+}
+
+// Price - This function returns the price of the gas in wei that a transaction built now pays on Ethereum: the base fee with
+// the priority fee on top of it, but never more than the legacy gas price, which is the price the fee of the transfer is
+// estimated with. On the chains without EIP-1559 it is the legacy gas price.
+func (f *Fee) Price() *big.Int {
+
+	if f.BaseFee.Sign() == 0 {
+		return new(big.Int).Set(f.GasPrice)
+	}
+
+	price := new(big.Int).Add(f.BaseFee, f.PriorityFee)
+	if f.GasPrice.Sign() > 0 && price.Cmp(f.GasPrice) > 0 {
+		return new(big.Int).Set(f.GasPrice)
+	}
+
+	return price
+}
+
+// Cost - This function returns the estimated cost of a native (contract is false) or token (contract is true) transfer in the
+// smallest units of the parent asset of the chain (wei for Ethereum, sun for Tron), at the prices of the network resources
+// returned by the fee oracle.
+func (p *Params) Cost(fee *Fee, contract bool) *big.Int {
+
+	switch p.platform {
+	case types.PlatformEthereum:
+		return new(big.Int).Mul(new(big.Int).SetUint64(p.gasUsed(contract)), fee.Price())
+	case types.PlatformTron:
+		if contract {
+			return big.NewInt(tronBandwidthToken*fee.Bandwidth + tronEnergy*fee.Energy)
+		}
+		return big.NewInt(tronBandwidth * fee.Bandwidth)
+	}
+
+	return new(big.Int)
+}
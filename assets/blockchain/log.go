@@ -4,7 +4,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/pkg/errors"
+	"math/big"
 	"strings"
 )
 
@@ -155,3 +157,24 @@ func (p *Params) log() (log *Log, err error) {
 
 	return log, nil
 }
+
+// Amount - This function returns the amount of the Transfer(address,address,uint256) event of the token in the data of the log,
+// the sender and the recipient of the transfer are its indexed topics.
+func (l *Log) Amount() (*big.Int, error) {
+
+	tokenAbi, err := abi.JSON(strings.NewReader(MainMetaData.ABI))
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := tokenAbi.Unpack("Transfer", l.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount, ok := instance[0].(*big.Int); ok {
+		return amount, nil
+	}
+
+	return nil, errors.New("the amount of the transfer is not a number")
+}
@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	"math/big"
+	"strings"
+)
+
+// pending - The pending struct is the Ethereum transaction as eth_getTransactionByHash returns it: the sender and the recipient,
+// the nonce, the value, the gas and the input of the transaction, the price of the gas it pays, the fee cap of the
+// dynamic fee transactions, and the block it is mined in, empty while it waits in the pool of the node.
+type pending struct {
+	From         string  `json:"from"`
+	To           *string `json:"to"`
+	Nonce        string  `json:"nonce"`
+	Value        string  `json:"value"`
+	Gas          string  `json:"gas"`
+	Input        string  `json:"input"`
+	GasPrice     string  `json:"gasPrice"`
+	MaxFeePerGas string  `json:"maxFeePerGas"`
+	BlockNumber  *string `json:"blockNumber"`
+}
+
+// transaction - This function requests the Ethereum transaction with the hash from the node, the transaction the node does not
+// know, dropped from its pool or never received, is returned with the error.
+func (p *Params) transaction(hash string) (*pending, error) {
+
+	if p.platform != types.PlatformEthereum {
+		return nil, errors.New("method not found!...")
+	}
+
+	p.query = []string{"-X", "POST", "-H", "Content-Type:application/json", "-H", "Accept: application/json", "-d", fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getTransactionByHash","params":["%s"],"id":1}`, hash), p.rpc}
+
+	response, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+
+	result, ok := response["result"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the transaction %v is not known to the node", hash)
+	}
+
+	marshal, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tx pending
+	)
+
+	if err := json.Unmarshal(marshal, &tx); err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+// Pending - This function returns the sender of the Ethereum transaction with the hash, the price of the gas it pays, the fee cap
+// of the dynamic fee transactions or the gas price of the legacy ones, and whether it still waits in the pool of the node.
+func (p *Params) Pending(hash string) (from string, price *big.Int, waiting bool, err error) {
+
+	tx, err := p.transaction(hash)
+	if err != nil {
+		return from, price, waiting, err
+	}
+
+	fee := tx.MaxFeePerGas
+	if len(fee) == 0 {
+		fee = tx.GasPrice
+	}
+
+	if price, err = hexutil.DecodeBig(fee); err != nil {
+		return from, price, waiting, err
+	}
+
+	return tx.From, price, tx.BlockNumber == nil, nil
+}
+
+// Reprice - This function signs the transaction with the nonce of the pending Ethereum transaction with the hash again at the price
+// of the gas, the same transfer when cancel is not set, or the transfer of nothing from the sender to itself, which takes
+// the place of the transfer, when it is. The transaction is broadcast and its hash is returned, the node accepts it in
+// place of the pending one when the price is at least a tenth higher. The key is the key of the sender of the transaction.
+func (p *Params) Reprice(ctx context.Context, key *platform.Key, hash string, price *big.Int, cancel bool) (string, error) {
+
+	tx, err := p.transaction(hash)
+	if err != nil {
+		return "", err
+	}
+
+	if tx.BlockNumber != nil {
+		return "", fmt.Errorf("the transaction %v is already mined", hash)
+	}
+
+	if _, err := p.prepare(key, key.Address, "", new(big.Int)); err != nil {
+		return "", err
+	}
+	p.WithContext(ctx)
+
+	owner := p.signatory.Address()
+	if !strings.EqualFold(owner.String(), tx.From) {
+		return "", fmt.Errorf("the transaction %v is not sent by the key %v", hash, owner.String())
+	}
+
+	nonce, err := hexutil.DecodeUint64(tx.Nonce)
+	if err != nil {
+		return "", err
+	}
+
+	// The base fee of the latest block is taken from the fee oracle, the whole price above it is offered to the validators, so
+	// that both the cap and the priority fee of the transaction are raised.
+	fee, err := p.Oracle()
+	if err != nil {
+		return "", err
+	}
+	fee.GasPrice, fee.PriorityFee = new(big.Int).Set(price), new(big.Int).Set(price)
+
+	var (
+		to    common.Address
+		value = new(big.Int)
+		gas   = p.gasUsed(false)
+		data  []byte
+	)
+
+	if cancel {
+		to = owner
+	} else {
+
+		if tx.To == nil {
+			return "", fmt.Errorf("the transaction %v creates a contract, it is not replaced", hash)
+		}
+		to = common.HexToAddress(*tx.To)
+
+		if value, err = hexutil.DecodeBig(tx.Value); err != nil {
+			return "", err
+		}
+
+		if gas, err = hexutil.DecodeUint64(tx.Gas); err != nil {
+			return "", err
+		}
+
+		if len(tx.Input) > 2 {
+			if data, err = hexutil.Decode(tx.Input); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	transfer, err := p.sign(p.signer(fee), p.txData(fee, nonce, &to, value, gas, data))
+	if err != nil {
+		return "", err
+	}
+
+	marshal, err := transfer.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	p.response = map[string]interface{}{
+		"transaction": hexutil.Encode(marshal),
+	}
+	p.success = true
+
+	if err := p.Transaction(); err != nil {
+		return "", err
+	}
+
+	return transfer.Hash().String(), nil
+}
@@ -0,0 +1,179 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/address"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Signer - The Signer interface hides where the private key of the hot wallet lives. Sign receives the 32 byte digest of the
+// transaction (the signing hash on Ethereum, the txID on Tron) and returns the 65 byte [R || S || V] secp256k1 signature,
+// Address returns the address the signatures recover to.
+type Signer interface {
+	Address() common.Address
+	Sign(digest []byte) ([]byte, error)
+}
+
+// Audit - The Audit function receives the raw request sent to the external signer and the raw response (or the error) returned
+// by it, so that every signature requested from the signing service can be recorded.
+type Audit func(request, response []byte, err error)
+
+// software - The software signer holds the private key in the memory of the process, it is used for the local development and
+// for the chains that have no external signer configured.
+type software struct {
+	private *ecdsa.PrivateKey
+}
+
+// NewSoftware - This function returns a signer that signs the digests with the given private key in the memory of the process.
+func NewSoftware(private *ecdsa.PrivateKey) Signer {
+	return &software{private: private}
+}
+
+// Address - This function returns the address of the public key of the private key.
+func (s *software) Address() common.Address {
+	return crypto.PubkeyToAddress(s.private.PublicKey)
+}
+
+// Sign - This function signs the digest with the private key.
+func (s *software) Sign(digest []byte) ([]byte, error) {
+	return crypto.Sign(digest, s.private)
+}
+
+// remote - The remote signer sends the digests to an external signing service or an HSM gateway, the private key never leaves
+// the signing service, only the address of the wallet the digest has to be signed with is sent along with it.
+type remote struct {
+	endpoint, token, platform, address string
+	owner                              common.Address
+	client                             *http.Client
+	audit                              Audit
+}
+
+// NewRemote - This function returns a signer that requests the signatures from the signing service at the endpoint, the token is
+// sent as a bearer token of every request. The owner is the address of the wallet in the format of the platform, the
+// signatures returned by the service are verified to recover to it.
+func NewRemote(endpoint, token, platform, owner string, audit Audit) (Signer, error) {
+
+	r := &remote{
+		endpoint: endpoint,
+		token:    token,
+		platform: platform,
+		address:  owner,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		audit:    audit,
+	}
+
+	switch platform {
+	case types.PlatformEthereum:
+		if !common.IsHexAddress(owner) {
+			return nil, errors.New("the address of the signer is not correct!...")
+		}
+		r.owner = common.HexToAddress(owner)
+	case types.PlatformTron:
+		decode := address.New(owner)
+		if len(decode) != 21 {
+			return nil, errors.New("the address of the signer is not correct!...")
+		}
+		r.owner = common.BytesToAddress(decode[1:])
+	default:
+		return nil, errors.New("method not found!...")
+	}
+
+	return r, nil
+}
+
+// Address - This function returns the address of the wallet the signing service signs with.
+func (r *remote) Address() common.Address {
+	return r.owner
+}
+
+// Sign - This function sends the digest to the signing service and returns the signature it responded with. The signature is
+// not trusted blindly: the public key is recovered from it and has to match the address of the wallet, otherwise a
+// misconfigured service would make the node reject the transaction at best, or sign for another wallet at worst.
+func (r *remote) Sign(digest []byte) (signature []byte, err error) {
+
+	var (
+		response []byte
+	)
+
+	request, err := json.Marshal(struct {
+		Platform string `json:"platform"`
+		Address  string `json:"address"`
+		Digest   string `json:"digest"`
+	}{
+		Platform: r.platform,
+		Address:  r.address,
+		Digest:   hexutil.Encode(digest),
+	})
+	if err != nil {
+		return signature, err
+	}
+
+	// The request and the response are handed to the audit whatever the result of the signing is.
+	defer func() {
+		if r.audit != nil {
+			r.audit(request, response, err)
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewBuffer(request))
+	if err != nil {
+		return signature, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", r.token))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return signature, err
+	}
+	defer resp.Body.Close()
+
+	if response, err = io.ReadAll(resp.Body); err != nil {
+		return signature, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return signature, errors.Errorf("the signer responded with the status %v!...", resp.StatusCode)
+	}
+
+	var result struct {
+		Signature string `json:"signature"`
+	}
+	if err = json.Unmarshal(response, &result); err != nil {
+		return signature, err
+	}
+
+	if signature, err = hexutil.Decode(result.Signature); err != nil {
+		return signature, err
+	}
+
+	if len(signature) != crypto.SignatureLength {
+		return signature, errors.New("the signature of the signer is malformed!...")
+	}
+
+	// Some signing services return the recovery id as 27/28, the way it is written into the legacy Ethereum transactions.
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+
+	public, err := crypto.SigToPub(digest, signature)
+	if err != nil {
+		return signature, err
+	}
+
+	if crypto.PubkeyToAddress(*public) != r.owner {
+		return signature, errors.New("the signature of the signer does not belong to the wallet!...")
+	}
+
+	return signature, nil
+}
@@ -20,10 +20,16 @@ import (
 )
 
 // Private - This function sets the private key on the Params struct, which is a type of struct used to store data related to an
-// operation. The function takes an ecdsa.PrivateKey as an argument and sets it to the p.private field of the Params
-// struct. This is used to ensure that the private key associated with the operation is secure and accessible only by the user.
+// operation. The transactions are signed with the key in the memory of the process by the software signer, it is the
+// fallback for the chains that have no external signer configured.
 func (p *Params) Private(private *ecdsa.PrivateKey) {
-	p.private = private
+	p.signatory = NewSoftware(private)
+}
+
+// Signatory - This function sets the signer the transactions are signed with, for example an external signing service or HSM
+// returned by NewRemote.
+func (p *Params) Signatory(signer Signer) {
+	p.signatory = signer
 }
 
 // Network - This function sets the "network" field of the Params struct to a big integer value, based on the given id int64. This
@@ -57,25 +63,18 @@ func (p *Params) gasUsed(c bool) uint64 {
 // the transaction in the p.response object, and then returns the transaction's hash and any error that may have occurred during the process.
 func (p *Params) Transfer(tx *Transfer) (hash string, err error) {
 
-	// This code is attempting to check if the `p.private.Public()` call returns a valid *ecdsa.PublicKey. If it does, it is
-	// stored in the `public` variable, and the code can proceed. If it does not, an error is returned.
-	public, ok := p.private.Public().(*ecdsa.PublicKey)
-	if !ok {
-		return hash, errors.New("error casting public key to ECDSA")
-	}
-
-	// Is used to convert a public key to an address. It takes the variable public
-	// (which is a pointer to a public key) as an argument and returns an address. This can be used to identify a user on
-	// the blockchain.
-	owner := crypto.PubkeyToAddress(*public)
+	// The owner is the address the signer of the transactions signs with, the key itself may live in an external signing
+	// service and is never read here.
+	owner := p.signatory.Address()
 
 	// The switch statement is a control structure used to execute different blocks of code depending on the value of a
 	// variable. In this example, the variable being tested is "p.platform", and depending on its value, a different block of code will be executed.
 	switch p.platform {
 	case types.PlatformEthereum:
 
-		// This code is attempting to get the gas price from the 'p' object. If there is an error, the hash and error are returned.
-		gasPrice, err := p.gasPrice()
+		// The prices of the gas are requested from the fee oracle, on the chains with EIP-1559 the transaction is built with the
+		// base and priority fees, so that only the actual price of the gas in the block is paid.
+		fee, err := p.Oracle()
 		if err != nil {
 			return hash, err
 		}
@@ -99,14 +98,7 @@ func (p *Params) Transfer(tx *Transfer) (hash string, err error) {
 			// This code is creating and signing a new Ethereum transaction with the given parameters. The parameters include the
 			// address to send the transaction to, the amount of gas to use, the gas price to use, and the transaction data. Once
 			// the transaction is created and signed, it is sent for processing.
-			transfer, err := core.SignNewTx(p.private, core.NewEIP155Signer(p.network), &core.LegacyTx{
-				Nonce:    nonce.Uint64(),
-				To:       &to,
-				Value:    big.NewInt(0),
-				Gas:      p.gasUsed(true),
-				GasPrice: big.NewInt(gasPrice),
-				Data:     tx.Data,
-			})
+			transfer, err := p.sign(p.signer(fee), p.txData(fee, nonce.Uint64(), &to, big.NewInt(0), p.gasUsed(true), tx.Data))
 			if err != nil {
 				return hash, err
 			}
@@ -136,17 +128,11 @@ func (p *Params) Transfer(tx *Transfer) (hash string, err error) {
 			// the address is needed for the transaction to be successful.
 			to := common.HexToAddress(tx.To)
 
-			// This code is signing a new transaction (transfer) with the private key of the user (p.private). It is also creating
-			// a new EIP155 signer (types.NewEIP155Signer(p.network)), and setting up the nonce, the address to transfer to (to),
+			// This code is signing a new transaction (transfer) with the signer of the hot wallet (p.signatory). It is also creating
+			// a new London or EIP155 signer (p.signer(fee)), and setting up the nonce, the address to transfer to (to),
 			// the amount to transfer (tx.Value), the amount of gas to pay (tx.Gas) and the gas price to pay (gasPrice). If an
 			// error occurs during the signing process, the code returns the hash and an error.
-			transfer, err := core.SignNewTx(p.private, core.NewEIP155Signer(p.network), &core.LegacyTx{
-				Nonce:    nonce.Uint64(),
-				To:       &to,
-				Value:    tx.Value,
-				Gas:      p.gasUsed(false),
-				GasPrice: big.NewInt(gasPrice),
-			})
+			transfer, err := p.sign(p.signer(fee), p.txData(fee, nonce.Uint64(), &to, tx.Value, p.gasUsed(false), nil))
 			if err != nil {
 				return hash, err
 			}
@@ -192,7 +178,7 @@ func (p *Params) Transfer(tx *Transfer) (hash string, err error) {
 				ContractAddress:  address.New(tx.Contract).Hex(true),
 				FunctionSelector: "transfer(address,uint256)",
 				Parameter:        strings.TrimPrefix(hexutil.Encode(tx.Data), "0x"),
-				FeeLimit:         p.gasUsed(true),
+				FeeLimit:         p.feeLimit(),
 				OwnerAddress:     address.New(owner.String()).Hex(true),
 			}
 
@@ -250,6 +236,83 @@ func (p *Params) Transfer(tx *Transfer) (hash string, err error) {
 	return p.buildTransaction()
 }
 
+// signer - This function returns the signer of the transaction for the prices returned by the fee oracle, the dynamic fee
+// transactions are signed with the London signer, which also accepts the legacy transactions.
+func (p *Params) signer(fee *Fee) core.Signer {
+	if fee.BaseFee.Sign() > 0 {
+		return core.NewLondonSigner(p.network)
+	}
+	return core.NewEIP155Signer(p.network)
+}
+
+// sign - This function builds the transaction from its data and signs its hash with the signer of the hot wallet, the
+// equivalent of core.SignNewTx for the keys that are not held in the memory of the process.
+func (p *Params) sign(signer core.Signer, data core.TxData) (*core.Transaction, error) {
+
+	tx := core.NewTx(data)
+
+	signature, err := p.signatory.Sign(signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.WithSignature(signer, signature)
+}
+
+// txData - This function builds the data of the Ethereum transaction. On the chains with EIP-1559 a dynamic fee transaction is
+// built, the cap of the fee is the legacy gas price the fee of the transfer was estimated with, so the transaction never
+// costs more than the estimate, and the priority fee is limited to what is left of the cap above the base fee. On the other
+// chains a legacy transaction with the gas price is built.
+func (p *Params) txData(fee *Fee, nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte) core.TxData {
+
+	if fee.BaseFee.Sign() > 0 {
+
+		tip := new(big.Int).Set(fee.PriorityFee)
+		if limit := new(big.Int).Sub(fee.GasPrice, fee.BaseFee); tip.Cmp(limit) > 0 {
+			tip = limit
+		}
+		if tip.Sign() < 0 {
+			tip = new(big.Int)
+		}
+
+		return &core.DynamicFeeTx{
+			ChainID:   p.network,
+			Nonce:     nonce,
+			To:        to,
+			Value:     value,
+			Gas:       gas,
+			GasTipCap: tip,
+			GasFeeCap: new(big.Int).Set(fee.GasPrice),
+			Data:      data,
+		}
+	}
+
+	return &core.LegacyTx{
+		Nonce:    nonce,
+		To:       to,
+		Value:    value,
+		Gas:      gas,
+		GasPrice: new(big.Int).Set(fee.GasPrice),
+		Data:     data,
+	}
+}
+
+// feeLimit - This function returns the limit of the fee in sun that a Tron contract call is allowed to burn. The default limit
+// is raised to one and a half of the cost estimated at the current energy price, so that the transfers do not run out of
+// energy when the price of the energy goes up.
+func (p *Params) feeLimit() uint64 {
+
+	limit := p.gasUsed(true)
+
+	if fee, err := p.Oracle(); err == nil {
+		if cost := new(big.Int).Div(new(big.Int).Mul(p.Cost(fee, true), big.NewInt(3)), big.NewInt(2)); cost.IsUint64() && cost.Uint64() > limit {
+			limit = cost.Uint64()
+		}
+	}
+
+	return limit
+}
+
 // GasPrice - The purpose of this function is to get the gas price from the Params struct. It first builds a query from the Params
 // struct and then attempts to get a resource using the p.get() function. The code then parses the result from the
 // resource map, strips the prefix "0x" from it, and parses it into an int64. Finally, it returns the gas price and any errors encountered.
@@ -305,24 +368,16 @@ func (p *Params) getNonce(address string) (nonce *big.Int, err error) {
 }
 
 // getResource - The purpose of this code is to get the total amount of energy available to a user from the blockchain. It does this by
-// casting the public key of a private key to an ECDSA public key, converting the public key to an address, creating a
+// taking the address of the signer of the hot wallet, creating a
 // request object in the form of a struct, converting the Go data structure into a JSON string, sending the request to a
 // server, and then checking for an error when attempting to get a resource from the server. It then checks for the keys
 // "freeNetLimit", "freeNetUsed", "NetLimit", and "NetUsed" in the resource map and assigns the associated values to
 // variables. The code then returns the total amount of energy available to the user.
 func (p *Params) getResource() (energy int64, err error) {
 
-	// This code is attempting to cast the public key of a private key of type "p.private" to an ECDSA public key type. The
-	// code will check if the cast is successful with the "ok" variable, and if not, it will return an error.
-	public, ok := p.private.Public().(*ecdsa.PublicKey)
-	if !ok {
-		return energy, errors.New("error casting public key to ECDSA")
-	}
-
-	// Is used to convert a public key to an address. It takes the variable public
-	// (which is a pointer to a public key) as an argument and returns an address. This can be used to identify a user on
-	// the blockchain.
-	owner := crypto.PubkeyToAddress(*public)
+	// The owner is the address the signer of the transactions signs with, the key itself may live in an external signing
+	// service and is never read here.
+	owner := p.signatory.Address()
 
 	// This code creates a request object in the form of a struct with a single field: Address, which is a string type. The
 	// value of this field is set to the hexadecimal representation of the owner's string. This is likely part of an API
@@ -390,17 +445,9 @@ func (p *Params) EstimateGas(tx *Transfer) (fee int64, err error) {
 		gas int
 	)
 
-	// This code is attempting to cast the public key of a private key of type "p.private" to an ECDSA public key type. The
-	// code will check if the cast is successful with the "ok" variable, and if not, it will return an error.
-	public, ok := p.private.Public().(*ecdsa.PublicKey)
-	if !ok {
-		return fee, errors.New("error casting public key to ECDSA")
-	}
-
-	// Is used to convert a public key to an address. It takes the variable public
-	// (which is a pointer to a public key) as an argument and returns an address. This can be used to identify a user on
-	// the blockchain.
-	owner := crypto.PubkeyToAddress(*public)
+	// The owner is the address the signer of the transactions signs with, the key itself may live in an external signing
+	// service and is never read here.
+	owner := p.signatory.Address()
 
 	// This switch statement is used to evaluate the value of the p.platform variable and take the appropriate action based
 	// on the value of the variable. This is a common way to control the flow of a program based on the value of a variable.
@@ -621,15 +668,6 @@ func (p *Params) EstimateGas(tx *Transfer) (fee int64, err error) {
 					return fee, err
 				}
 
-				// This code is signing a transaction with the private key of the user. The purpose of signing a transaction is to
-				// create a digital signature that is used to authenticate the sender of the transaction and to ensure that the
-				// transaction has not been modified. The signature is then sent along with the transaction to be verified by the
-				// network. If an error occurs during the signing process, the code returns an error.
-				signature, err := crypto.Sign(common.Hex2Bytes(transaction["txID"].(string)), p.private)
-				if err != nil {
-					return fee, err
-				}
-
 				// This if statement is checking to see if the constant map has a key called "raw_data_hex", and if it does, it sets the
 				// variable raw to the value associated with that key. The ok variable is a boolean that is true if the key exists in
 				// the map and false if it does not.
@@ -648,7 +686,9 @@ func (p *Params) EstimateGas(tx *Transfer) (fee int64, err error) {
 					// increase the value of the gas variable by the length of the raw variable.
 					gas += len(raw)
 				}
-				gas += len(signature)
+				// The signature only adds its length to the size of the transaction, so the estimate does not ask the signer of
+				// the hot wallet to sign a transaction that is never sent.
+				gas += crypto.SignatureLength
 
 				// The code checks if the key "energy_used" is present in the constant map. If it is, the value corresponding to that
 				// key is stored in the variable energy. The ok variable is a boolean which is set to true if the key is present in
@@ -744,10 +784,10 @@ func (p *Params) buildTransaction() (txID string, err error) {
 		// existence of the key "txID" in the map p.response. If the key exists, the statement returns true, otherwise it returns false.
 		if _, ok := p.response["txID"]; ok {
 
-			// The purpose of this code is to sign a transaction using a private key. The transaction ID is obtained from a
-			// response object and is converted to bytes using the common.Hex2Bytes() function. The crypto.Sign() function is then
-			// used to sign the transaction using the private key. If an error occurs during the signing process, an error is returned.
-			signature, err := crypto.Sign(common.Hex2Bytes(p.response["txID"].(string)), p.private)
+			// The purpose of this code is to sign a transaction with the signer of the hot wallet. The transaction ID is obtained
+			// from a response object and is converted to bytes using the common.Hex2Bytes() function, it is the digest the
+			// signer signs. If an error occurs during the signing process, an error is returned.
+			signature, err := p.signatory.Sign(common.Hex2Bytes(p.response["txID"].(string)))
 			if err != nil {
 				return txID, err
 			}
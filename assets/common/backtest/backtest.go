@@ -0,0 +1,368 @@
+package backtest
+
+import (
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/indicator"
+	"github.com/pkg/errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	OperatorAbove        = ">"
+	OperatorBelow        = "<"
+	OperatorAboveOrEqual = ">="
+	OperatorBelowOrEqual = "<="
+	OperatorCrossesAbove = "crosses_above"
+	OperatorCrossesBelow = "crosses_below"
+
+	ReasonExit       = "exit"
+	ReasonStopLoss   = "stop_loss"
+	ReasonTakeProfit = "take_profit"
+	ReasonEnd        = "end"
+)
+
+// Candle - The Candle struct is a candle of the pair in chronological order: the time of the bucket and its prices and volume.
+type Candle struct {
+	Time                           int64
+	Open, High, Low, Close, Volume float64
+}
+
+// Condition - The Condition struct compares two operands at every candle. An operand is a number, a price of the candle (open,
+// high, low, close or volume) or an indicator with its arguments: sma(20), ema(50), rsi(14), vwap, bollinger_upper(20, 2),
+// bollinger_middle(20, 2) or bollinger_lower(20, 2). The crosses operators hold at the candle the left operand crossed
+// the right one at, compared to the candle before.
+type Condition struct {
+	Left, Operator, Right string
+}
+
+// Rules - The Rules struct is the strategy that is tested: the position is opened with the whole capital at the close of the
+// candle all the conditions of the entry hold at, and closed at the close of the candle all the conditions of the exit
+// hold at, or at the stop loss and the take profit in percent of the price of the entry, zero turns them off. The fees
+// of the buy and of the sell are in percent of the asset received.
+type Rules struct {
+	Entry, Exit          []Condition
+	StopLoss, TakeProfit float64
+	Capital              float64
+	BuyFees, SellFees    float64
+}
+
+// Trade - The Trade struct is a position the strategy opened and closed, with the profit in the quote unit, the return in percent
+// and the reason the position was closed for.
+type Trade struct {
+	EntryTime, ExitTime                             int64
+	EntryPrice, ExitPrice, Quantity, Profit, Return float64
+	Reason                                          string
+}
+
+// Point - The Point struct is the value of the capital in the quote unit at the close of the candle.
+type Point struct {
+	Time   int64
+	Equity float64
+}
+
+// Summary - The Summary struct holds the statistics of the test: the number of the trades, the winning and the losing trades, the
+// share of the winning trades and the total return in percent, the largest fall of the equity from its peak in percent,
+// the ratio of the gross profit to the gross loss and the capital at the end.
+type Summary struct {
+	Trades, Wins, Losses                            int
+	WinRate, Return, Drawdown, ProfitFactor, Equity float64
+}
+
+// Result - The Result struct is the result of the test: the trades, the equity curve with a point per candle and the summary.
+type Result struct {
+	Trades  []*Trade
+	Equity  []*Point
+	Summary Summary
+}
+
+// series - The values of an operand per candle, the values before the start are not calculated yet and no condition holds there.
+type series struct {
+	values []float64
+	start  int
+}
+
+// Run - This function tests the rules against the candles in chronological order. The position is long only, it is opened and
+// closed at the prices of the candles: the stop loss and the take profit are checked against the low and the high of the
+// candle first, at their own prices, then the exit conditions at the close. The position still open after the last
+// candle is closed at its close.
+func Run(candles []Candle, rules Rules) (*Result, error) {
+
+	var (
+		result   Result
+		cache    = make(map[string]*series)
+		position *Trade
+		cash     = rules.Capital
+		spent    float64
+		peak     = rules.Capital
+		gains    float64
+		losses   float64
+	)
+
+	if len(candles) == 0 {
+		return nil, errors.New("there are no candles to test the rules against")
+	}
+
+	if len(rules.Entry) == 0 || len(rules.Exit) == 0 && rules.StopLoss <= 0 && rules.TakeProfit <= 0 {
+		return nil, errors.New("the rules need the conditions of the entry and the conditions of the exit, the stop loss or the take profit")
+	}
+
+	if rules.Capital <= 0 || rules.StopLoss < 0 || rules.TakeProfit < 0 || rules.BuyFees < 0 || rules.SellFees < 0 {
+		return nil, errors.New("the capital must be greater than zero, the stop loss, the take profit and the fees must not be negative")
+	}
+
+	for _, condition := range append(append([]Condition{}, rules.Entry...), rules.Exit...) {
+
+		switch condition.Operator {
+		case OperatorAbove, OperatorBelow, OperatorAboveOrEqual, OperatorBelowOrEqual, OperatorCrossesAbove, OperatorCrossesBelow:
+		default:
+			return nil, fmt.Errorf("the operator %q is not supported", condition.Operator)
+		}
+
+		for _, operand := range []string{condition.Left, condition.Right} {
+			if _, err := evaluate(candles, operand, cache); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	closing := func(price float64, i int, reason string) {
+
+		value := position.Quantity * price * (1 - rules.SellFees/100)
+
+		position.ExitTime, position.ExitPrice, position.Reason = candles[i].Time, price, reason
+		position.Profit = value - spent
+		position.Return = position.Profit / spent * 100
+
+		if position.Profit > 0 {
+			result.Summary.Wins++
+			gains += position.Profit
+		} else {
+			result.Summary.Losses++
+			losses -= position.Profit
+		}
+
+		cash += value
+		result.Trades = append(result.Trades, position)
+		position = nil
+	}
+
+	for i, candle := range candles {
+
+		if position != nil {
+
+			stop, take := position.EntryPrice*(1-rules.StopLoss/100), position.EntryPrice*(1+rules.TakeProfit/100)
+
+			switch {
+			case rules.StopLoss > 0 && candle.Low <= stop:
+				closing(math.Min(stop, candle.Open), i, ReasonStopLoss)
+			case rules.TakeProfit > 0 && candle.High >= take:
+				closing(math.Max(take, candle.Open), i, ReasonTakeProfit)
+			case len(rules.Exit) > 0 && holds(rules.Exit, i, cache):
+				closing(candle.Close, i, ReasonExit)
+			}
+
+		} else if holds(rules.Entry, i, cache) && candle.Close > 0 {
+
+			position = &Trade{EntryTime: candle.Time, EntryPrice: candle.Close, Quantity: cash / candle.Close * (1 - rules.BuyFees/100)}
+			cash, spent = 0, cash
+		}
+
+		equity := cash
+		if position != nil {
+			equity += position.Quantity * candle.Close
+		}
+		result.Equity = append(result.Equity, &Point{Time: candle.Time, Equity: equity})
+
+		if equity > peak {
+			peak = equity
+		}
+		if drawdown := (peak - equity) / peak * 100; drawdown > result.Summary.Drawdown {
+			result.Summary.Drawdown = drawdown
+		}
+	}
+
+	if position != nil {
+		closing(candles[len(candles)-1].Close, len(candles)-1, ReasonEnd)
+		result.Equity[len(result.Equity)-1].Equity = cash
+	}
+
+	result.Summary.Trades = len(result.Trades)
+	result.Summary.Equity = cash
+	result.Summary.Return = (cash - rules.Capital) / rules.Capital * 100
+
+	if result.Summary.Trades > 0 {
+		result.Summary.WinRate = float64(result.Summary.Wins) / float64(result.Summary.Trades) * 100
+	}
+
+	if losses > 0 {
+		result.Summary.ProfitFactor = gains / losses
+	}
+
+	return &result, nil
+}
+
+// holds - This function reports whether all the conditions hold at the candle with the index.
+func holds(conditions []Condition, i int, cache map[string]*series) bool {
+
+	for _, condition := range conditions {
+
+		left, right := cache[key(condition.Left)], cache[key(condition.Right)]
+		if i < left.start || i < right.start {
+			return false
+		}
+
+		var (
+			ok bool
+		)
+
+		switch condition.Operator {
+		case OperatorAbove:
+			ok = left.values[i] > right.values[i]
+		case OperatorBelow:
+			ok = left.values[i] < right.values[i]
+		case OperatorAboveOrEqual:
+			ok = left.values[i] >= right.values[i]
+		case OperatorBelowOrEqual:
+			ok = left.values[i] <= right.values[i]
+		case OperatorCrossesAbove:
+			ok = i > left.start && i > right.start && left.values[i] > right.values[i] && left.values[i-1] <= right.values[i-1]
+		case OperatorCrossesBelow:
+			ok = i > left.start && i > right.start && left.values[i] < right.values[i] && left.values[i-1] >= right.values[i-1]
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// key - This function returns the operand without the spaces and in the lower case, the operands written differently share it.
+func key(operand string) string {
+	return strings.ToLower(strings.ReplaceAll(operand, " ", ""))
+}
+
+// evaluate - This function calculates the values of the operand per candle once, the values are kept in the cache by the key of
+// the operand.
+func evaluate(candles []Candle, operand string, cache map[string]*series) (*series, error) {
+
+	operand = key(operand)
+	if item, ok := cache[operand]; ok {
+		return item, nil
+	}
+
+	var (
+		item   = series{values: make([]float64, len(candles))}
+		closes = make([]float64, len(candles))
+	)
+
+	for i, candle := range candles {
+		closes[i] = candle.Close
+	}
+
+	name, args, err := parse(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	period := func(count int) (int, error) {
+		if len(args) != count || args[0] < 1 || args[0] != math.Trunc(args[0]) {
+			return 0, fmt.Errorf("the operand %q needs the period and %v arguments in all", operand, count)
+		}
+		return int(args[0]), nil
+	}
+
+	switch name {
+	case "open", "high", "low", "close", "volume", "vwap":
+		if len(args) > 0 {
+			return nil, fmt.Errorf("the operand %q has no arguments", operand)
+		}
+	}
+
+	switch name {
+	case "":
+		for i := range item.values {
+			item.values[i] = args[0]
+		}
+	case "open", "high", "low", "close", "volume":
+		for i, candle := range candles {
+			item.values[i] = map[string]float64{"open": candle.Open, "high": candle.High, "low": candle.Low, "close": candle.Close, "volume": candle.Volume}[name]
+		}
+	case "sma", "ema", "rsi":
+
+		p, err := period(1)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "sma":
+			item.values, item.start = indicator.Sma(closes, p), p-1
+		case "ema":
+			item.values, item.start = indicator.Ema(closes, p), p-1
+		case "rsi":
+			item.values, item.start = indicator.Rsi(closes, p), p
+		}
+
+	case "vwap":
+
+		var (
+			highs, lows, volumes = make([]float64, len(candles)), make([]float64, len(candles)), make([]float64, len(candles))
+		)
+
+		for i, candle := range candles {
+			highs[i], lows[i], volumes[i] = candle.High, candle.Low, candle.Volume
+		}
+		item.values = indicator.Vwap(highs, lows, closes, volumes)
+
+	case "bollinger_upper", "bollinger_middle", "bollinger_lower":
+
+		p, err := period(2)
+		if err != nil {
+			return nil, err
+		}
+
+		upper, middle, lower := indicator.Bollinger(closes, p, args[1])
+		item.values, item.start = map[string][]float64{"bollinger_upper": upper, "bollinger_middle": middle, "bollinger_lower": lower}[name], p-1
+
+	default:
+		return nil, fmt.Errorf("the operand %q is not supported", operand)
+	}
+
+	cache[operand] = &item
+
+	return &item, nil
+}
+
+// parse - This function splits the operand into the name and the arguments in the parentheses, the number is the operand without
+// the name and with the number as the only argument.
+func parse(operand string) (name string, args []float64, err error) {
+
+	if number, err := strconv.ParseFloat(operand, 64); err == nil {
+		return "", []float64{number}, nil
+	}
+
+	name, rest, ok := strings.Cut(operand, "(")
+	if !ok {
+		return operand, nil, nil
+	}
+
+	rest, ok = strings.CutSuffix(rest, ")")
+	if !ok || len(name) == 0 {
+		return "", nil, fmt.Errorf("the operand %q is not correct", operand)
+	}
+
+	for _, arg := range strings.Split(rest, ",") {
+
+		number, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("the argument %q of the operand %q is not a number", arg, operand)
+		}
+		args = append(args, number)
+	}
+
+	return name, args, nil
+}
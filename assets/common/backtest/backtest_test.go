@@ -0,0 +1,80 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+)
+
+func candles(closes ...float64) (items []Candle) {
+	for i, price := range closes {
+		items = append(items, Candle{Time: int64(i), Open: price, High: price, Low: price, Close: price, Volume: 1})
+	}
+	return items
+}
+
+func TestRun(t *testing.T) {
+
+	result, err := Run(candles(10, 8, 12, 14, 11, 9), Rules{
+		Entry:   []Condition{{Left: "close", Operator: OperatorCrossesAbove, Right: "sma(2)"}},
+		Exit:    []Condition{{Left: "close", Operator: OperatorCrossesBelow, Right: "sma(2)"}},
+		Capital: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Trades) != 1 || len(result.Equity) != 6 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	trade := result.Trades[0]
+	if trade.EntryPrice != 12 || trade.ExitPrice != 11 || trade.Reason != ReasonExit {
+		t.Fatalf("unexpected trade %+v", trade)
+	}
+
+	if math.Abs(result.Summary.Equity-100*11.0/12) > 1e-9 || result.Summary.Losses != 1 || result.Summary.WinRate != 0 {
+		t.Fatalf("unexpected summary %+v", result.Summary)
+	}
+
+	if math.Abs(result.Summary.Drawdown-(14.0-11)/14*100) > 1e-9 {
+		t.Fatalf("unexpected drawdown %v", result.Summary.Drawdown)
+	}
+}
+
+func TestStops(t *testing.T) {
+
+	items := candles(10, 10, 10)
+	items[2].Low, items[2].Open = 7, 9
+
+	result, err := Run(items, Rules{
+		Entry:    []Condition{{Left: "close", Operator: OperatorAboveOrEqual, Right: "10"}},
+		StopLoss: 20,
+		Capital:  100,
+		BuyFees:  1,
+		SellFees: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Trades) != 1 || result.Trades[0].Reason != ReasonStopLoss || result.Trades[0].ExitPrice != 8 {
+		t.Fatalf("unexpected trades %+v", result.Trades)
+	}
+
+	if math.Abs(result.Summary.Equity-100*0.99/10*8*0.99) > 1e-9 {
+		t.Fatalf("unexpected equity %v", result.Summary.Equity)
+	}
+}
+
+func TestOperands(t *testing.T) {
+
+	for _, operand := range []string{"sma", "close(2)", "bollinger_upper(20)", "macd(12)", "sma(x)", "sma(0)"} {
+		if _, err := Run(candles(1, 2, 3), Rules{Entry: []Condition{{Left: operand, Operator: OperatorAbove, Right: "0"}}, StopLoss: 1, Capital: 100}); err == nil {
+			t.Errorf("expected the error of the operand %v", operand)
+		}
+	}
+
+	if _, err := Run(candles(1, 2, 3), Rules{Entry: []Condition{{Left: "Bollinger_Lower(2, 2)", Operator: "=", Right: "0"}}, StopLoss: 1, Capital: 100}); err == nil {
+		t.Error("expected the error of the operator")
+	}
+}
@@ -0,0 +1,155 @@
+package broker
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+)
+
+const (
+	// QosPublic - The quality of the service of the public events, the tickers and the books are replaced by the next events soon,
+	// so they are delivered at most once.
+	QosPublic = byte(0)
+
+	// QosPrivate - The quality of the service of the events of the users and of the support, they are delivered at least once.
+	QosPrivate = byte(1)
+
+	// prefix - The prefix of the names of the users of the broker the grants are issued to, the name of the user of the broker is
+	// the prefix and the id of the account.
+	prefix = "user:"
+)
+
+// Topic - This function returns the topic the event of the root topic is published to: the public events are published to the
+// root topic, the events of a user to the topic of the user under the root, and the alerts of the support to the topic of
+// the support, which is read only by the backend.
+func Topic(root, channel string, user int64) string {
+
+	if strings.HasPrefix(channel, "support/") {
+		return root + "/support"
+	}
+
+	if user > 0 {
+		return fmt.Sprintf("%v/user/%v", root, user)
+	}
+
+	return root
+}
+
+// Users - This function returns the filter of the topics of all the users under the root, the backend reads the events of all the
+// users by it.
+func Users(root string) string {
+	return root + "/user/+"
+}
+
+// Root - This function returns the root topic of the topic of a user or of the support, the metrics of the topics are labelled by
+// their roots, so that the topics of the users do not make a label each.
+func Root(topic string) string {
+
+	if i := strings.LastIndex(topic, "/user/"); i > 0 {
+		return topic[:i]
+	}
+
+	return strings.TrimSuffix(topic, "/support")
+}
+
+// Qos - This function returns the quality of the service of the topic, the topics of the users and of the support are private.
+func Qos(root, topic string) byte {
+	if topic == root {
+		return QosPublic
+	}
+	return QosPrivate
+}
+
+// Topics - This function returns the topics the user may subscribe to: the public topic and the own topic of the user.
+func Topics(root string, user int64) []string {
+	return []string{root, Topic(root, "", user)}
+}
+
+// Grant - The Grant struct is the signed permission of the user to subscribe to the topics of the broker from the websocket bridge,
+// the name and the password the client connects to the broker with. The broker asks the backend whether the password is
+// valid and which topics the user may read, the grant expires at the time in the unix seconds.
+type Grant struct {
+	Username, Password string
+	Topics             []string
+	ExpireAt           int64
+}
+
+// Sign - This function issues the grant of the user for the lifetime, the password is the expiry and the signature of the name of
+// the user and of the expiry by the secret.
+func Sign(secret, root string, user int64, lifetime time.Duration, now time.Time) Grant {
+
+	var (
+		username = fmt.Sprintf("%v%v", prefix, user)
+		expire   = now.Add(lifetime).Unix()
+	)
+
+	return Grant{
+		Username: username,
+		Password: fmt.Sprintf("%v.%v", expire, help.SignatureHmac256(secret, username, expire)),
+		Topics:   Topics(root, user),
+		ExpireAt: expire,
+	}
+}
+
+// Verify - This function returns the id of the user of the grant when the password is signed by the secret and has not expired,
+// zero otherwise.
+func Verify(secret, username, password string, now time.Time) int64 {
+
+	user := User(username)
+	if user == 0 || len(secret) == 0 {
+		return 0
+	}
+
+	parts := strings.SplitN(password, ".", 2)
+	if len(parts) != 2 {
+		return 0
+	}
+
+	expire, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.Unix() > expire {
+		return 0
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(help.SignatureHmac256(secret, username, expire))) {
+		return 0
+	}
+
+	return user
+}
+
+// User - This function returns the id of the user of the name of the user of the broker, zero if the name is not of a grant.
+func User(username string) int64 {
+
+	if !strings.HasPrefix(username, prefix) {
+		return 0
+	}
+
+	user, err := strconv.ParseInt(strings.TrimPrefix(username, prefix), 10, 64)
+	if err != nil || user <= 0 {
+		return 0
+	}
+
+	return user
+}
+
+// Readable - This function reports whether the user may read the messages of the routing key of the broker, the routing keys are
+// the topics of mqtt with the dots in the place of the slashes. The user reads the public topic and the own topic only,
+// the users never publish.
+func Readable(root string, user int64, key string) bool {
+
+	if user == 0 {
+		return false
+	}
+
+	for _, topic := range Topics(root, user) {
+		if key == strings.ReplaceAll(topic, "/", ".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package broker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopic(t *testing.T) {
+
+	tests := []struct {
+		channel string
+		user    int64
+		want    string
+		qos     byte
+	}{
+		{channel: "trade/ticker", want: "exchange", qos: QosPublic},
+		{channel: "order/status", user: 7, want: "exchange/user/7", qos: QosPrivate},
+		{channel: "support/priority", user: 7, want: "exchange/support", qos: QosPrivate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			got := Topic("exchange", tt.channel, tt.user)
+			if got != tt.want {
+				t.Errorf("Topic() = %v, want %v", got, tt.want)
+			}
+			if root := Root(got); root != "exchange" {
+				t.Errorf("Root(%v) = %v", got, root)
+			}
+			if qos := Qos("exchange", got); qos != tt.qos {
+				t.Errorf("Qos(%v) = %v, want %v", got, qos, tt.qos)
+			}
+		})
+	}
+}
+
+func TestGrant(t *testing.T) {
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	grant := Sign("secret", "exchange", 7, time.Hour, now)
+
+	if grant.Username != "user:7" || len(grant.Topics) != 2 || grant.Topics[1] != "exchange/user/7" {
+		t.Fatalf("Sign() = %+v", grant)
+	}
+
+	if user := Verify("secret", grant.Username, grant.Password, now.Add(time.Minute)); user != 7 {
+		t.Errorf("Verify() = %v, want 7", user)
+	}
+
+	if user := Verify("secret", grant.Username, grant.Password, now.Add(2*time.Hour)); user != 0 {
+		t.Errorf("Verify() of the expired grant = %v", user)
+	}
+
+	if user := Verify("other", grant.Username, grant.Password, now); user != 0 {
+		t.Errorf("Verify() with another secret = %v", user)
+	}
+
+	if user := Verify("secret", "user:8", grant.Password, now); user != 0 {
+		t.Errorf("Verify() of the grant of another user = %v", user)
+	}
+
+	if user := Verify("", grant.Username, grant.Password, now); user != 0 {
+		t.Errorf("Verify() without the secret = %v", user)
+	}
+}
+
+func TestReadable(t *testing.T) {
+
+	for key, want := range map[string]bool{
+		"exchange":          true,
+		"exchange.user.7":   true,
+		"exchange.user.8":   false,
+		"exchange.support":  false,
+		"exchange.user.7.x": false,
+		"#":                 false,
+	} {
+		if got := Readable("exchange", 7, key); got != want {
+			t.Errorf("Readable(%v) = %v, want %v", key, got, want)
+		}
+	}
+
+	if Readable("exchange", 0, "exchange") {
+		t.Errorf("Readable() without the user must be false")
+	}
+}
@@ -10,58 +10,81 @@ type Float struct {
 	decimal.Decimal
 }
 
-// New - This function creates a new Float object from an interface, which could be a float64, int64, int, or *big.Int. It
-// takes the value from the interface and converts it to a decimal.NewFromFloat, decimal.NewFromInt, or
-// decimal.NewFromString, and then stores it in the Float object.
+// init - The quotients are kept with the 18 decimal places of the balances and the amounts of the tokens, the default of the
+// decimal library is 16 places.
+func init() {
+	decimal.DivisionPrecision = 18
+}
+
+// New - This function creates a new Float object from an interface, which could be a float64, an integer, a *big.Int, a string
+// or another decimal. It takes the value from the interface and converts it with decimal.NewFromFloat, decimal.NewFromInt,
+// or decimal.NewFromString, and then stores it in the Float object. The strings and the decimals are taken exactly as
+// they are, so the amounts read from the numeric columns of the database keep all their places.
 func New(value interface{}) *Float {
+	return &Float{
+		number(value),
+	}
+}
 
-	// This code is used to create a new decimal object with a value of 0.0. The decimal object can then be used to store
-	// and manipulate decimal values in an efficient way.
-	number := decimal.NewFromFloat(0)
+// number - This function converts the value of a supported type into a decimal, the values of the other types are zero.
+func number(value interface{}) decimal.Decimal {
 
 	// This switch statement is used to convert different types of values into a decimal. The statement will check the type
 	// of the value, then use the corresponding function to convert it into decimal. For example, if the value is a float64,
 	// then decimal.NewFromFloat will be used.
 	switch v := value.(type) {
 	case float64:
-		number = decimal.NewFromFloat(v)
+		return decimal.NewFromFloat(v)
+	case float32:
+		return decimal.NewFromFloat32(v)
 	case int64:
-		number = decimal.NewFromInt(v)
+		return decimal.NewFromInt(v)
 	case int:
-		number = decimal.NewFromInt(int64(v))
+		return decimal.NewFromInt(int64(v))
+	case int32:
+		return decimal.NewFromInt32(v)
+	case uint32:
+		return decimal.NewFromInt(int64(v))
 	case *big.Int:
-		number, _ = decimal.NewFromString(v.String())
+		number, _ := decimal.NewFromString(v.String())
+		return number
+	case string:
+		number, _ := decimal.NewFromString(v)
+		return number
+	case decimal.Decimal:
+		return v
+	case *Float:
+		return v.Decimal
 	}
 
-	return &Float{
-		number,
-	}
+	return decimal.Zero
 }
 
-// Mul - This function is used to multiply a float value with another float value and return a new float value. The function
-// takes a pointer to a float type and a float64 value as parameters and returns a pointer to a new float type containing
-// the result of the multiplication.
-func (p *Float) Mul(value float64) *Float {
-	return &Float{p.Decimal.Mul(decimal.NewFromFloat(value))}
+// Mul - This function is used to multiply a float value with another value and return a new float value. The function takes a
+// pointer to a float type and a value of any type supported by New as parameters and returns a pointer to a new float
+// type containing the result of the multiplication, the products of two decimals are exact.
+func (p *Float) Mul(value interface{}) *Float {
+	return &Float{p.Decimal.Mul(number(value))}
 }
 
-// Div - This function is used to divide a float value by another float value and return the result as a Float type. The
-// 'Div()' function is a method of the decimal package, and it takes in a float64 value as an argument. The function
-// returns the result as a Float type instead of a float64 type to ensure precision and accuracy of the computation.
-func (p *Float) Div(value float64) *Float {
-	return &Float{p.Decimal.Div(decimal.NewFromFloat(value))}
+// Div - This function is used to divide a float value by another value and return the result as a Float type. The 'Div()'
+// function is a method of the decimal package, and it takes in a value of any type supported by New as an argument. The
+// function returns the result as a Float type instead of a float64 type to ensure precision and accuracy of the
+// computation, the quotient is rounded to 18 decimal places.
+func (p *Float) Div(value interface{}) *Float {
+	return &Float{p.Decimal.Div(number(value))}
 }
 
-// Sub - This is a method of a Float type in Go. The purpose of this method is to subtract a given float64 value from the Float
-// type value and then return the resulting Float type.
-func (p *Float) Sub(value float64) *Float {
-	return &Float{p.Decimal.Sub(decimal.NewFromFloat(value))}
+// Sub - This is a method of a Float type in Go. The purpose of this method is to subtract a given value of any type supported by
+// New from the Float type value and then return the resulting Float type.
+func (p *Float) Sub(value interface{}) *Float {
+	return &Float{p.Decimal.Sub(number(value))}
 }
 
-// Add - This function adds a float64 value to a Float type which is a struct containing a decimal.Decimal struct. The function
-// returns a pointer to a new Float struct with the new decimal.Decimal value.
-func (p *Float) Add(value float64) *Float {
-	return &Float{p.Decimal.Add(decimal.NewFromFloat(value))}
+// Add - This function adds a value of any type supported by New to a Float type which is a struct containing a decimal.Decimal
+// struct. The function returns a pointer to a new Float struct with the new decimal.Decimal value.
+func (p *Float) Add(value interface{}) *Float {
+	return &Float{p.Decimal.Add(number(value))}
 }
 
 // Float - This is a method of the Float type. It is used to convert a Float to a float64 value by returning the float64
@@ -0,0 +1,28 @@
+package decimal
+
+import (
+	"testing"
+)
+
+func TestExact(t *testing.T) {
+	tests := []struct {
+		name string
+		got  *Float
+		want string
+	}{
+		{name: "sum", got: New(0.1).Add(0.2), want: "0.3"},
+		{name: "string", got: New("123456789.123456789012345678").Add("0.000000000000000001"), want: "123456789.123456789012345679"},
+		{name: "product", got: New("0.000000000000000003").Mul(New("1000")), want: "0.000000000000003"},
+		{name: "fee", got: New("1.000000000000000001").Mul(0.1).Div(100), want: "0.001000000000000000"},
+		{name: "quotient", got: New(1).Div(3), want: "0.333333333333333333"},
+		{name: "integer", got: New(int32(7)).Sub(uint32(2)), want: "5"},
+		{name: "unsupported", got: New(struct{}{}), want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.got.Equal(New(tt.want).Decimal) {
+				t.Errorf("got %v, want %v", tt.got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package decimal
+
+import (
+	"bytes"
+	"encoding/json"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strconv"
+)
+
+// Stringify - This function rewrites the json document of the given message so that all the floating point fields (the prices,
+// amounts, fees and the like) are encoded as strings instead of numbers. The numbers of 18-decimal tokens lose their
+// precision in the clients that parse json numbers into doubles, while the strings can be parsed by a decimal library.
+// The fields are found through the reflection of the message, so the integer fields, like ids and counts, stay numbers.
+func Stringify(message protoreflect.Message, data []byte, indent string) ([]byte, error) {
+
+	var (
+		document interface{}
+	)
+
+	// The document is decoded with the numbers kept as they are written, so that no precision is lost on the way.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	stringify(message, document)
+
+	if len(indent) > 0 {
+		return json.MarshalIndent(document, "", indent)
+	}
+
+	return json.Marshal(document)
+}
+
+// stringify - This function walks the decoded json object together with the descriptor of the message, and replaces the numbers
+// of the floating point fields with strings, the nested messages and the lists of messages are walked recursively.
+func stringify(message protoreflect.Message, document interface{}) {
+
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+
+		field := fields.Get(i)
+
+		// The field can be written either with its original name or with its json name, depending on the marshaler.
+		key := string(field.Name())
+		if _, ok := object[key]; !ok {
+			key = field.JSONName()
+		}
+		value, ok := object[key]
+		if !ok || field.IsMap() {
+			continue
+		}
+
+		switch field.Kind() {
+		case protoreflect.DoubleKind, protoreflect.FloatKind:
+
+			// The floating point fields are replaced with strings, the lists of numbers are replaced element by element.
+			if list, ok := value.([]interface{}); ok {
+				for j := range list {
+					list[j] = str(list[j])
+				}
+			} else {
+				object[key] = str(value)
+			}
+
+		case protoreflect.MessageKind:
+
+			// The nested messages are walked with their own descriptors, an element of a list is walked with the message
+			// of the same index.
+			if field.IsList() {
+				list, ok := value.([]interface{})
+				if !ok {
+					continue
+				}
+				for j := 0; j < len(list) && j < message.Get(field).List().Len(); j++ {
+					stringify(message.Get(field).List().Get(j).Message(), list[j])
+				}
+			} else if message.Has(field) {
+				stringify(message.Get(field).Message(), value)
+			}
+		}
+	}
+}
+
+// str - This function converts a json number into a string in the plain decimal notation, without the exponent, the values that
+// are not numbers (for example "NaN" written as a string) are returned as they are.
+func str(value interface{}) interface{} {
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+
+	float, err := strconv.ParseFloat(number.String(), 64)
+	if err != nil {
+		return number.String()
+	}
+
+	return strconv.FormatFloat(float, 'f', -1, 64)
+}
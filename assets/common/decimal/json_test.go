@@ -0,0 +1,35 @@
+package decimal
+
+import (
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+func TestStringify(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "exponent", data: `{"value":1e-7}`, want: `{"value":"0.0000001"}`},
+		{name: "plain", data: `{"value":0.123456789012345678}`, want: `{"value":"0.12345678901234568"}`},
+		{name: "missing", data: `{}`, want: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Stringify((&wrapperspb.DoubleValue{}).ProtoReflect(), []byte(tt.data), "")
+			if err != nil {
+				t.Fatalf("Stringify() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Stringify() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// The integer fields are not monetary, so they stay numbers.
+	got, err := Stringify((&wrapperspb.Int64Value{}).ProtoReflect(), []byte(`{"value":42}`), "")
+	if err != nil || string(got) != `{"value":42}` {
+		t.Errorf("Stringify() = %s, %v", got, err)
+	}
+}
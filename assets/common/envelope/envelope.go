@@ -0,0 +1,187 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"github.com/pkg/errors"
+	"io"
+)
+
+const (
+	// sizeKey - The size of the data keys and of the master keys, the records are encrypted with AES-256-GCM.
+	sizeKey = 32
+
+	// sizeWrapped - The size of the wrapped data key: the nonce, the encrypted data key and the authentication tag.
+	sizeWrapped = 12 + sizeKey + 16
+)
+
+// magic - The prefix of the sealed records, the records without it are the plain values written before the encryption was
+// introduced.
+var magic = []byte("ENV1")
+
+// Seal - This function encrypts the plain value with a new random data key, and wraps the data key with the master key. The
+// result holds the id of the master key, the wrapped data key and the encrypted value, so that the master key can be
+// rotated by rewrapping only the data key, without touching the value.
+func Seal(id string, master, plain []byte) ([]byte, error) {
+
+	if len(id) == 0 || len(id) > 255 {
+		return nil, errors.New("the id of the master key is not correct")
+	}
+
+	key := make([]byte, sizeKey)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := encrypt(master, key, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := encrypt(key, plain, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return compose(id, wrapped, value), nil
+}
+
+// Open - This function unwraps the data key of the sealed record with the master key it was sealed with, found in the keys by
+// its id, and decrypts the value with it.
+func Open(keys map[string][]byte, sealed []byte) ([]byte, error) {
+
+	id, wrapped, value, err := split(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	master, ok := keys[id]
+	if !ok {
+		return nil, errors.Errorf("the master key %v is not found", id)
+	}
+
+	key, err := decrypt(master, wrapped, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(key, value, nil)
+}
+
+// Rewrap - This function unwraps the data key of the sealed record with its current master key and wraps it again with the
+// master key of the id, the encrypted value itself stays the same.
+func Rewrap(keys map[string][]byte, id string, sealed []byte) ([]byte, error) {
+
+	current, wrapped, value, err := split(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	master, ok := keys[current]
+	if !ok {
+		return nil, errors.Errorf("the master key %v is not found", current)
+	}
+
+	key, err := decrypt(master, wrapped, []byte(current))
+	if err != nil {
+		return nil, err
+	}
+
+	if master, ok = keys[id]; !ok || len(id) > 255 {
+		return nil, errors.Errorf("the master key %v is not found", id)
+	}
+
+	if wrapped, err = encrypt(master, key, []byte(id)); err != nil {
+		return nil, err
+	}
+
+	return compose(id, wrapped, value), nil
+}
+
+// Sealed - This function reports whether the record is sealed, the plain values are shorter than any sealed record.
+func Sealed(data []byte) bool {
+	return len(data) > len(magic)+1+sizeWrapped && bytes.HasPrefix(data, magic)
+}
+
+// Id - This function returns the id of the master key the record is sealed with, or an empty string if it is not sealed.
+func Id(sealed []byte) string {
+	id, _, _, err := split(sealed)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// compose - This function writes the sealed record: the prefix, the length and the id of the master key, the wrapped data key
+// and the encrypted value.
+func compose(id string, wrapped, value []byte) []byte {
+
+	sealed := append(append([]byte{}, magic...), byte(len(id)))
+	sealed = append(sealed, id...)
+	sealed = append(sealed, wrapped...)
+
+	return append(sealed, value...)
+}
+
+// split - This function splits the sealed record into the id of the master key, the wrapped data key and the encrypted value.
+func split(sealed []byte) (id string, wrapped, value []byte, err error) {
+
+	if !Sealed(sealed) {
+		return id, wrapped, value, errors.New("the record is not sealed")
+	}
+
+	offset := len(magic) + 1 + int(sealed[len(magic)])
+	if len(sealed) < offset+sizeWrapped {
+		return id, wrapped, value, errors.New("the sealed record is malformed")
+	}
+
+	return string(sealed[len(magic)+1 : offset]), sealed[offset : offset+sizeWrapped], sealed[offset+sizeWrapped:], nil
+}
+
+// encrypt - This function encrypts the plain text with AES-GCM, the random nonce is written in front of the cipher text.
+func encrypt(key, plain, data []byte) ([]byte, error) {
+
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plain, data), nil
+}
+
+// decrypt - This function decrypts the cipher text written by encrypt.
+func decrypt(key, sealed, data []byte) ([]byte, error) {
+
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("the sealed record is malformed")
+	}
+
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], data)
+}
+
+// gcm - This function returns the AES-GCM cipher of the 256 bit key.
+func gcm(key []byte) (cipher.AEAD, error) {
+
+	if len(key) != sizeKey {
+		return nil, errors.New("the key must be 32 bytes long")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,65 @@
+package envelope
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSeal(t *testing.T) {
+
+	keys := map[string][]byte{
+		"2023-01": bytes.Repeat([]byte{1}, 32),
+		"2023-06": bytes.Repeat([]byte{2}, 32),
+	}
+
+	plain := []byte{0xd1, 0x86, 0xb2, 0xba, 0x71, 0x72, 0x27, 0x42, 0x6e, 0x3d, 0x17, 0xbb, 0xe3, 0x45, 0xff, 0xbe}
+
+	sealed, err := Seal("2023-01", keys["2023-01"], plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Sealed(sealed) || Sealed(plain) {
+		t.Fatalf("Sealed() is not correct")
+	}
+
+	if id := Id(sealed); id != "2023-01" {
+		t.Fatalf("Id() = %v, want %v", id, "2023-01")
+	}
+
+	opened, err := Open(keys, sealed)
+	if err != nil || !bytes.Equal(opened, plain) {
+		t.Fatalf("Open() = %x, %v, want %x", opened, err, plain)
+	}
+
+	rewrapped, err := Rewrap(keys, "2023-06", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := Id(rewrapped); id != "2023-06" {
+		t.Fatalf("Id() = %v, want %v", id, "2023-06")
+	}
+
+	// The encrypted value stays the same, only the wrapped data key changes.
+	if !bytes.Equal(rewrapped[len(rewrapped)-len(plain)-28:], sealed[len(sealed)-len(plain)-28:]) {
+		t.Fatalf("Rewrap() changed the encrypted value")
+	}
+
+	if opened, err = Open(map[string][]byte{"2023-06": keys["2023-06"]}, rewrapped); err != nil || !bytes.Equal(opened, plain) {
+		t.Fatalf("Open() = %x, %v, want %x", opened, err, plain)
+	}
+
+	if _, err = Open(map[string][]byte{"2023-01": keys["2023-06"]}, sealed); err == nil {
+		t.Fatalf("Open() with a wrong master key must fail")
+	}
+
+	if _, err = Open(map[string][]byte{"2023-06": keys["2023-06"]}, sealed); err == nil {
+		t.Fatalf("Open() without the master key must fail")
+	}
+
+	sealed[len(sealed)-1] ^= 0xff
+	if _, err = Open(keys, sealed); err == nil {
+		t.Fatalf("Open() of a modified record must fail")
+	}
+}
@@ -0,0 +1,107 @@
+package geoip
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxSpeed - The highest speed in kilometres per hour a user can travel between two sign ins, the speed of an airliner, the
+	// sign ins that are farther apart than the user could fly in the time between them are impossible travel.
+	MaxSpeed = 1000
+
+	// Tolerance - The distance in kilometres two locations are treated as the same within, the locations of the addresses are
+	// approximate, and the addresses of the same provider are often located in the different cities.
+	Tolerance = 300
+
+	// radius - The mean radius of the earth in kilometres.
+	radius = 6371
+)
+
+var (
+	// client - The http client the addresses are located with, the sign in is not delayed by a lookup that does not answer in time.
+	client = &http.Client{Timeout: 3 * time.Second}
+)
+
+// Client - The Client struct is the geolocation service the addresses are located by, the service answers the address appended to
+// the endpoint with the country code and the coordinates of the address, the token is sent as the bearer authentication.
+type Client struct {
+	Endpoint, Token string
+}
+
+// Location - The Location struct is the location of an address: the two-letter code of the country and the coordinates.
+type Location struct {
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Locate - This function returns the location of the address. The private and the loopback addresses have no location, the empty
+// location is returned for them without a request.
+func (c *Client) Locate(ip string) (location Location, err error) {
+
+	address := net.ParseIP(ip)
+	if address == nil || address.IsLoopback() || address.IsPrivate() || address.IsUnspecified() {
+		return location, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%v/%v", strings.TrimSuffix(c.Endpoint, "/"), url.PathEscape(address.String())), nil)
+	if err != nil {
+		return location, err
+	}
+	if len(c.Token) > 0 {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", c.Token))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return location, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return location, fmt.Errorf("the geolocation service responded with the status %v", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&location); err != nil {
+		return location, err
+	}
+	location.Country = strings.ToUpper(location.Country)
+
+	return location, nil
+}
+
+// Distance - This function returns the great-circle distance between the locations in kilometres.
+func Distance(from, to Location) float64 {
+
+	var (
+		lat1, lat2 = from.Latitude * math.Pi / 180, to.Latitude * math.Pi / 180
+		dlat, dlon = lat2 - lat1, (to.Longitude - from.Longitude) * math.Pi / 180
+	)
+
+	h := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dlon/2)*math.Sin(dlon/2)
+
+	return 2 * radius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Impossible - This function reports whether the user could not travel from the location to the other one in the elapsed time.
+// The locations without a country are unknown and never reported.
+func Impossible(from, to Location, elapsed time.Duration) bool {
+
+	if len(from.Country) == 0 || len(to.Country) == 0 {
+		return false
+	}
+
+	distance := Distance(from, to)
+	if distance <= Tolerance {
+		return false
+	}
+
+	return elapsed <= 0 || distance/elapsed.Hours() > MaxSpeed
+}
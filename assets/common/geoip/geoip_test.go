@@ -0,0 +1,79 @@
+package geoip
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	berlin  = Location{Country: "DE", Latitude: 52.52, Longitude: 13.405}
+	potsdam = Location{Country: "DE", Latitude: 52.39, Longitude: 13.065}
+	newYork = Location{Country: "US", Latitude: 40.7128, Longitude: -74.006}
+)
+
+func TestDistance(t *testing.T) {
+	if distance := Distance(berlin, newYork); math.Abs(distance-6385) > 20 {
+		t.Fatalf("unexpected distance %v", distance)
+	}
+	if distance := Distance(berlin, berlin); distance != 0 {
+		t.Fatalf("unexpected distance %v", distance)
+	}
+}
+
+func TestImpossible(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		from, to Location
+		elapsed  time.Duration
+		want     bool
+	}{
+		{name: "ocean within an hour", from: berlin, to: newYork, elapsed: time.Hour, want: true},
+		{name: "ocean within a day", from: berlin, to: newYork, elapsed: 24 * time.Hour},
+		{name: "nearby city at once", from: berlin, to: potsdam},
+		{name: "unknown location", from: Location{}, to: newYork, elapsed: time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Impossible(test.from, test.to, test.elapsed); got != test.want {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path != "/8.8.8.8" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected authorization %v", r.Header.Get("Authorization"))
+		}
+
+		_, _ = w.Write([]byte(`{"country": "us", "latitude": 37.751, "longitude": -97.822}`))
+	}))
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, Token: "secret"}
+
+	location, err := c.Locate("8.8.8.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if location.Country != "US" || location.Latitude != 37.751 || location.Longitude != -97.822 {
+		t.Fatalf("unexpected location %+v", location)
+	}
+
+	if location, err := c.Locate("192.168.1.1"); err != nil || len(location.Country) > 0 {
+		t.Fatalf("the private address is located %+v %v", location, err)
+	}
+}
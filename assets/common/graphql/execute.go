@@ -0,0 +1,564 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Resolver - The Resolver function resolves the value of a field from its arguments. The value is a protobuf message, a struct,
+// a map, a slice or a scalar, the maps and the Objects can hold the resolvers of their own fields, which are called only
+// when the fields are selected.
+type Resolver func(ctx context.Context, args map[string]interface{}) (interface{}, error)
+
+// Subscriber - The Subscriber function returns the channel of the events of a subscription field, the channel is closed by the
+// subscriber when the context is done.
+type Subscriber func(ctx context.Context, args map[string]interface{}) (<-chan interface{}, error)
+
+// Object - The Object type is the value of an object, its values are the values of the fields or their resolvers.
+type Object map[string]interface{}
+
+// Schema - The Schema struct holds the root fields of the api. The mutations are not supported, the writes go through the
+// existing rest and grpc apis. The depth limits the nesting of the selection sets of the operations, zero is unlimited.
+type Schema struct {
+	Query        map[string]Resolver
+	Subscription map[string]Subscriber
+	Depth        int
+}
+
+// Request - The Request struct holds the query document, the name of the operation to execute and its variables, in the format
+// of the graphql requests over http.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response - The Response struct holds the data of the executed operation and the errors of the fields that failed.
+type Response struct {
+	Data   *Ordered `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error - The Error struct holds the error of a field and the path of the field in the data.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Ordered - The Ordered struct is an object of the data, the fields are written in the order they are selected in.
+type Ordered struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// Get - This function returns the value of the field of the object.
+func (o *Ordered) Get(key string) interface{} {
+	return o.values[key]
+}
+
+// MarshalJSON - This function writes the fields of the object in their order.
+func (o *Ordered) MarshalJSON() ([]byte, error) {
+
+	var (
+		buffer bytes.Buffer
+	)
+
+	buffer.WriteByte('{')
+	for i, key := range o.keys {
+
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
+// executor - The executor struct holds the state of the execution of one operation: the fragments and the variables of the
+// document, and the errors of the fields, which are collected from the fields resolved concurrently.
+type executor struct {
+	fragments map[string]*Fragment
+	variables map[string]interface{}
+	mu        sync.Mutex
+	errors    []*Error
+}
+
+// Execute - This function executes the query operation of the request. The root fields are resolved concurrently, as are the
+// items of the lists and the fields of the objects, so that the loaders called by them are batched together.
+func (s *Schema) Execute(ctx context.Context, request *Request) *Response {
+
+	e, operation, err := s.prepare(request)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	if operation.Type != "query" {
+		return &Response{Errors: []*Error{{Message: fmt.Sprintf("the %v operations are not supported over this transport", operation.Type)}}}
+	}
+
+	data := e.object(ctx, Object(nil), operation.Selections, nil, func(name string) (Resolver, bool) {
+		resolver, ok := s.Query[name]
+		return resolver, ok
+	})
+
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// Subscribe - This function subscribes to the subscription operation of the request, the operation has exactly one root field,
+// every event of the field is sent as a response with the fields selected from the event.
+func (s *Schema) Subscribe(ctx context.Context, request *Request) (<-chan *Response, error) {
+
+	e, operation, err := s.prepare(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if operation.Type != "subscription" {
+		return nil, errors.Errorf("the operation is a %v, not a subscription", operation.Type)
+	}
+
+	fields := e.collect(operation.Selections)
+	if len(fields) != 1 {
+		return nil, errors.New("the subscription must select exactly one root field")
+	}
+	field := fields[0]
+
+	subscriber, ok := s.Subscription[field.Name]
+	if !ok {
+		return nil, errors.Errorf("cannot subscribe to the field %v", field.Name)
+	}
+
+	events, err := subscriber(ctx, e.arguments(field.Arguments))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(chan *Response)
+	go func() {
+		defer close(responses)
+
+		for event := range events {
+
+			run := executor{fragments: e.fragments, variables: e.variables}
+			data := run.object(ctx, Object{field.Name: event}, operation.Selections, nil, nil)
+
+			select {
+			case responses <- &Response{Data: data, Errors: run.errors}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return responses, nil
+}
+
+// prepare - This function parses the document of the request, finds the operation to execute and sets the values of its variables.
+func (s *Schema) prepare(request *Request) (*executor, *Operation, error) {
+
+	document, err := Parse(request.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		operation *Operation
+	)
+
+	for _, item := range document.Operations {
+		if len(request.OperationName) == 0 || item.Name == request.OperationName {
+			if operation != nil {
+				return nil, nil, errors.New("the document has several operations, the name of the operation to execute is required")
+			}
+			operation = item
+		}
+	}
+
+	if operation == nil {
+		return nil, nil, errors.Errorf("the operation %v is not found", request.OperationName)
+	}
+
+	e := executor{fragments: document.Fragments, variables: make(map[string]interface{})}
+	for _, variable := range operation.Variables {
+		if value, ok := request.Variables[variable.Name]; ok {
+			e.variables[variable.Name] = value
+		} else {
+			e.variables[variable.Name] = variable.Default
+		}
+	}
+
+	// The depth is checked for every operation, since it also finds the cycles of the fragments, which would never end.
+	depth, err := e.depth(operation.Selections, 0, make(map[string]bool))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.Depth > 0 && depth > s.Depth {
+		return nil, nil, errors.Errorf("the selection sets are nested %d levels deep, at most %d levels are allowed", depth, s.Depth)
+	}
+
+	return &e, operation, nil
+}
+
+// depth - This function returns the depth of the nesting of the selection sets, the fragments are counted at the level they are
+// spread at, and the fragments which spread each other in a cycle are an error.
+func (e *executor) depth(selections []Selection, level int, visiting map[string]bool) (int, error) {
+
+	depth := level
+	for _, selection := range selections {
+
+		var (
+			d   int
+			err error
+		)
+
+		switch item := selection.(type) {
+		case *Field:
+			d, err = e.depth(item.Selections, level+1, visiting)
+		case *Inline:
+			d, err = e.depth(item.Selections, level, visiting)
+		case *Spread:
+
+			fragment, ok := e.fragments[item.Name]
+			if !ok {
+				return depth, errors.Errorf("the fragment %v is not defined", item.Name)
+			}
+
+			if visiting[item.Name] {
+				return depth, errors.Errorf("the fragment %v spreads itself", item.Name)
+			}
+
+			visiting[item.Name] = true
+			d, err = e.depth(fragment.Selections, level, visiting)
+			delete(visiting, item.Name)
+		}
+		if err != nil {
+			return depth, err
+		}
+
+		if d > depth {
+			depth = d
+		}
+	}
+
+	return depth, nil
+}
+
+// collect - This function flattens the fragments of the selection set into its fields, and drops the selections skipped by the
+// @skip and @include directives.
+func (e *executor) collect(selections []Selection) (fields []*Field) {
+
+	for _, selection := range selections {
+
+		if !e.included(selection.directives()) {
+			continue
+		}
+
+		switch item := selection.(type) {
+		case *Field:
+			fields = append(fields, item)
+		case *Inline:
+			fields = append(fields, e.collect(item.Selections)...)
+		case *Spread:
+			if fragment, ok := e.fragments[item.Name]; ok {
+				fields = append(fields, e.collect(fragment.Selections)...)
+			}
+		}
+	}
+
+	return fields
+}
+
+// included - This function executes the @skip and @include directives of the selection.
+func (e *executor) included(directives []*Directive) bool {
+
+	for _, directive := range directives {
+
+		condition, _ := e.value(directive.Arguments["if"]).(bool)
+
+		switch directive.Name {
+		case "skip":
+			if condition {
+				return false
+			}
+		case "include":
+			if !condition {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// object - This function resolves the selected fields of the object concurrently. The fields that are not found in the object
+// are looked up by the root function, which is how the root fields of the schema are resolved.
+func (e *executor) object(ctx context.Context, object Object, selections []Selection, path []interface{}, root func(name string) (Resolver, bool)) *Ordered {
+
+	var (
+		fields = e.collect(selections)
+		result = Ordered{values: make(map[string]interface{}, len(fields))}
+		values = make([]interface{}, len(fields))
+		wg     sync.WaitGroup
+	)
+
+	for _, field := range fields {
+
+		key := field.Alias
+		if len(key) == 0 {
+			key = field.Name
+		}
+
+		// The same key can be selected several times, for example by the fragments, the value is resolved only once.
+		if _, ok := result.values[key]; ok {
+			continue
+		}
+		result.values[key] = nil
+		result.keys = append(result.keys, key)
+
+		if field.Name == "__typename" {
+			values[len(result.keys)-1] = "Object"
+			continue
+		}
+
+		value, ok := object[field.Name]
+		if !ok && root != nil {
+			resolver, ok := root(field.Name)
+			if !ok {
+				e.fail(append(append([]interface{}{}, path...), key), errors.Errorf("cannot query the field %v", field.Name))
+				continue
+			}
+			value = resolver
+		}
+
+		// Every field writes only its own item of the values, so the fields need no lock between them.
+		wg.Add(1)
+		go func(i int, field *Field, key string, value interface{}) {
+			defer wg.Done()
+
+			path := append(append([]interface{}{}, path...), key)
+
+			if resolver, ok := value.(Resolver); ok {
+
+				resolved, err := e.resolve(ctx, resolver, e.arguments(field.Arguments))
+				if err != nil {
+					e.fail(path, err)
+					return
+				}
+				value = resolved
+			}
+
+			values[i] = e.complete(ctx, value, field.Selections, path)
+		}(len(result.keys)-1, field, key, value)
+	}
+
+	wg.Wait()
+
+	for i, key := range result.keys {
+		result.values[key] = values[i]
+	}
+
+	return &result
+}
+
+// resolve - This function calls the resolver, a panic of the resolver is returned as the error of the field.
+func (e *executor) resolve(ctx context.Context, resolver Resolver, args map[string]interface{}) (value interface{}, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("the field failed: %v", r)
+		}
+	}()
+
+	return resolver(ctx, args)
+}
+
+// complete - This function completes the resolved value: the objects are narrowed down to the selected fields, the items of the
+// lists are completed concurrently, and the protobuf messages and the structs are converted into the objects first.
+func (e *executor) complete(ctx context.Context, value interface{}, selections []Selection, path []interface{}) interface{} {
+
+	value, err := Value(value)
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+
+	switch item := value.(type) {
+	case Object:
+		if len(selections) == 0 {
+			return item.plain()
+		}
+		return e.object(ctx, item, selections, path, nil)
+	case []interface{}:
+
+		var (
+			list = make([]interface{}, len(item))
+			wg   sync.WaitGroup
+		)
+
+		for i := range item {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				list[i] = e.complete(ctx, item[i], selections, append(append([]interface{}{}, path...), i))
+			}(i)
+		}
+		wg.Wait()
+
+		return list
+	}
+
+	return value
+}
+
+// arguments - This function replaces the references to the variables in the arguments of the field with their values.
+func (e *executor) arguments(arguments map[string]interface{}) map[string]interface{} {
+
+	args := make(map[string]interface{}, len(arguments))
+	for name, argument := range arguments {
+		args[name] = e.value(argument)
+	}
+
+	return args
+}
+
+// value - This function replaces the references to the variables in the value with their values.
+func (e *executor) value(value interface{}) interface{} {
+
+	switch item := value.(type) {
+	case reference:
+		return e.variables[string(item)]
+	case []interface{}:
+		list := make([]interface{}, len(item))
+		for i := range item {
+			list[i] = e.value(item[i])
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(item))
+		for name := range item {
+			object[name] = e.value(item[name])
+		}
+		return object
+	}
+
+	return value
+}
+
+// fail - This function records the error of the field at the path.
+func (e *executor) fail(path []interface{}, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: path})
+}
+
+// plain - This function returns the object without the resolvers of its fields, it is returned as it is when no fields of it
+// are selected.
+func (o Object) plain() map[string]interface{} {
+
+	plain := make(map[string]interface{}, len(o))
+	for name, value := range o {
+		if _, ok := value.(Resolver); !ok {
+			plain[name] = value
+		}
+	}
+
+	return plain
+}
+
+// Value - This function converts the value into the values the executor works with: the protobuf messages, the structs and the
+// maps into the Objects, the slices into the lists, the scalars stay as they are. The protobuf messages are written with
+// the original names of their fields, in the same way as the rest api writes them.
+func Value(value interface{}) (interface{}, error) {
+
+	switch item := value.(type) {
+	case nil, Object, []interface{}, string, bool, float64, int64, int32, int, json.Number:
+		return value, nil
+	case map[string]interface{}:
+		return Object(item), nil
+	case proto.Message:
+
+		if reflect.ValueOf(item).IsNil() {
+			return nil, nil
+		}
+
+		serialize, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			object map[string]interface{}
+		)
+
+		if err := json.Unmarshal(serialize, &object); err != nil {
+			return nil, err
+		}
+
+		return Object(object), nil
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Ptr:
+		if reflected.IsNil() {
+			return nil, nil
+		}
+	case reflect.Slice, reflect.Array:
+
+		if reflected.Kind() == reflect.Slice && reflected.IsNil() {
+			return nil, nil
+		}
+
+		list := make([]interface{}, reflected.Len())
+		for i := range list {
+			list[i] = reflected.Index(i).Interface()
+		}
+
+		return list, nil
+	}
+
+	// The other values, such as the structs and the typed maps, are converted through their json format.
+	serialize, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		decode interface{}
+	)
+
+	if err := json.Unmarshal(serialize, &decode); err != nil {
+		return nil, err
+	}
+
+	if object, ok := decode.(map[string]interface{}); ok {
+		return Object(object), nil
+	}
+
+	return decode, nil
+}
@@ -0,0 +1,188 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParse(t *testing.T) {
+
+	document, err := Parse(`
+		# The dashboard of the pair.
+		query Dashboard($symbol: String! = "btc", $limit: Int) {
+			pair(base_unit: $symbol, quote_unit: "usdt") { ...price }
+			candles: ticker(limit: $limit, resolution: "1h", sides: [BUY, "sell"], range: {from: -1.5e2}) @include(if: true) {
+				fields { close }
+			}
+		}
+		fragment price on Pair { price ... on Pair { ratio } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(document.Operations) != 1 || len(document.Fragments) != 1 {
+		t.Fatalf("Parse() = %d operations and %d fragments, want 1 and 1", len(document.Operations), len(document.Fragments))
+	}
+
+	operation := document.Operations[0]
+	if operation.Type != "query" || operation.Name != "Dashboard" || len(operation.Variables) != 2 || operation.Variables[0].Default != "btc" {
+		t.Errorf("Parse() operation = %+v", operation)
+	}
+
+	candles := operation.Selections[1].(*Field)
+	if candles.Alias != "candles" || candles.Name != "ticker" || len(candles.Directives) != 1 {
+		t.Errorf("Parse() field = %+v", candles)
+	}
+
+	if candles.Arguments["limit"] != reference("limit") || candles.Arguments["resolution"] != "1h" {
+		t.Errorf("Parse() arguments = %+v", candles.Arguments)
+	}
+
+	if sides := candles.Arguments["sides"].([]interface{}); len(sides) != 2 || sides[0] != "BUY" {
+		t.Errorf("Parse() list = %+v", sides)
+	}
+
+	if from := candles.Arguments["range"].(map[string]interface{})["from"]; from != -150.0 {
+		t.Errorf("Parse() object = %+v", from)
+	}
+
+	for _, source := range []string{
+		``,
+		`{ pair }}`,
+		`{ pair(symbol: "btc) }`,
+		`{ pair(symbol: ) }`,
+		`{ }`,
+		`query ($symbol: String = $other) { pair }`,
+		`fragment a on A { b } fragment a on A { c } { a }`,
+	} {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("Parse(%q) must fail", source)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+
+	var (
+		fetches int32
+	)
+
+	schema := Schema{
+		Depth: 3,
+		Query: map[string]Resolver{
+			"pairs": func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+
+				// The assets of the pairs are loaded by one fetch, the loads of the items resolved concurrently are batched.
+				loader := NewLoader(5*time.Millisecond, func(keys []string) (map[string]interface{}, error) {
+					atomic.AddInt32(&fetches, 1)
+					values := make(map[string]interface{})
+					for _, key := range keys {
+						values[key] = map[string]interface{}{"symbol": key, "name": "name of " + key}
+					}
+					return values, nil
+				})
+
+				var pairs []interface{}
+				for _, symbol := range []string{"btc", "eth", "btc"} {
+					symbol := symbol
+					pairs = append(pairs, Object{
+						"base_unit":  symbol,
+						"quote_unit": args["quote_unit"],
+						"base": Resolver(func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+							return loader.Load(symbol)
+						}),
+					})
+				}
+
+				return pairs, nil
+			},
+			"balance": func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+				return nil, errors.New("the request is not authorized")
+			},
+			"time": func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+				return struct {
+					Unix int64 `json:"unix"`
+				}{Unix: 1690000000}, nil
+			},
+		},
+	}
+
+	response := schema.Execute(context.Background(), &Request{
+		Query: `query ($quote: String, $skip: Boolean!) {
+			time { unix }
+			pairs(quote_unit: $quote) { base_unit quote: quote_unit base { name } }
+			balance @skip(if: $skip)
+			other: balance
+		}`,
+		Variables: map[string]interface{}{"quote": "usdt", "skip": true},
+	})
+
+	serialize, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"data":{"time":{"unix":1690000000},"pairs":[{"base_unit":"btc","quote":"usdt","base":{"name":"name of btc"}},{"base_unit":"eth","quote":"usdt","base":{"name":"name of eth"}},{"base_unit":"btc","quote":"usdt","base":{"name":"name of btc"}}],"other":null},"errors":[{"message":"the request is not authorized","path":["other"]}]}`; string(serialize) != want {
+		t.Errorf("Execute() = %s, want %s", serialize, want)
+	}
+
+	if fetches != 1 {
+		t.Errorf("Execute() fetched the assets %d times, want 1", fetches)
+	}
+
+	for _, query := range []string{
+		`{ unknown }`,
+		`{ pairs { base { name { deeper } } } }`,
+		`mutation { pairs }`,
+		`{ ...a } fragment a on Query { ...a }`,
+	} {
+		if response := schema.Execute(context.Background(), &Request{Query: query}); len(response.Errors) == 0 {
+			t.Errorf("Execute(%q) must fail", query)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+
+	schema := Schema{
+		Subscription: map[string]Subscriber{
+			"event": func(ctx context.Context, args map[string]interface{}) (<-chan interface{}, error) {
+				events := make(chan interface{}, 2)
+				events <- map[string]interface{}{"channel": args["channel"], "data": map[string]interface{}{"price": 1.5, "hidden": true}}
+				events <- map[string]interface{}{"channel": args["channel"], "data": map[string]interface{}{"price": 2.5}}
+				close(events)
+				return events, nil
+			},
+		},
+	}
+
+	responses, err := schema.Subscribe(context.Background(), &Request{Query: `subscription { ticker: event(channel: "trade/ticker") { data { price } } }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prices []string
+	for response := range responses {
+		serialize, err := json.Marshal(response.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prices = append(prices, string(serialize))
+	}
+	sort.Strings(prices)
+
+	if len(prices) != 2 || prices[0] != `{"ticker":{"data":{"price":1.5}}}` || prices[1] != `{"ticker":{"data":{"price":2.5}}}` {
+		t.Errorf("Subscribe() = %v", prices)
+	}
+
+	if _, err := schema.Subscribe(context.Background(), &Request{Query: `{ event }`}); err == nil {
+		t.Errorf("Subscribe() with a query must fail")
+	}
+}
@@ -0,0 +1,77 @@
+package graphql
+
+import (
+	"sync"
+	"time"
+)
+
+// Loader - The Loader struct batches the loads of the values by their keys: the keys requested within the wait of the first of
+// them are fetched together by one call of the fetch function, and every key is fetched only once for the life of the
+// loader. The loaders are created per request, so the values are never cached across the requests.
+type Loader[K comparable, V any] struct {
+	wait  time.Duration
+	fetch func(keys []K) (map[K]V, error)
+	mu    sync.Mutex
+	cache map[K]*entry[V]
+	batch []K
+}
+
+// entry - The entry struct holds the value of a key, the done channel is closed when the value is fetched.
+type entry[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// NewLoader - This function returns the loader of the fetch function, the keys the fetch function does not return a value of
+// are loaded as the zero values.
+func NewLoader[K comparable, V any](wait time.Duration, fetch func(keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{
+		wait:  wait,
+		fetch: fetch,
+		cache: make(map[K]*entry[V]),
+	}
+}
+
+// Load - This function returns the value of the key, it waits until the batch of the key is fetched.
+func (l *Loader[K, V]) Load(key K) (V, error) {
+
+	l.mu.Lock()
+	item, ok := l.cache[key]
+	if !ok {
+
+		item = &entry[V]{done: make(chan struct{})}
+		l.cache[key] = item
+
+		// The first key of the batch schedules the fetch of the batch.
+		l.batch = append(l.batch, key)
+		if len(l.batch) == 1 {
+			time.AfterFunc(l.wait, l.dispatch)
+		}
+	}
+	l.mu.Unlock()
+
+	<-item.done
+
+	return item.value, item.err
+}
+
+// dispatch - This function fetches the values of the keys of the batch and hands them to the loads waiting for them.
+func (l *Loader[K, V]) dispatch() {
+
+	l.mu.Lock()
+	keys := l.batch
+	l.batch = nil
+	l.mu.Unlock()
+
+	values, err := l.fetch(keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		item := l.cache[key]
+		item.value, item.err = values[key], err
+		close(item.done)
+	}
+}
@@ -0,0 +1,782 @@
+package graphql
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Document - The Document struct holds the parsed query document: the operations and the named fragments they spread.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation - The Operation struct holds an operation of the document, the type is "query", "mutation" or "subscription".
+type Operation struct {
+	Type, Name string
+	Variables  []*Variable
+	Selections []Selection
+}
+
+// Variable - The Variable struct holds the definition of a variable of the operation and its default value.
+type Variable struct {
+	Name    string
+	Default interface{}
+}
+
+// Fragment - The Fragment struct holds a named fragment of the document.
+type Fragment struct {
+	Name, On   string
+	Selections []Selection
+}
+
+// Selection - The Selection interface is implemented by the fields, the fragment spreads and the inline fragments of the
+// selection sets.
+type Selection interface {
+	directives() []*Directive
+}
+
+// Field - The Field struct holds a field of the selection set, the alias is empty if the field is not aliased.
+type Field struct {
+	Alias, Name string
+	Arguments   map[string]interface{}
+	Directives  []*Directive
+	Selections  []Selection
+}
+
+// Spread - The Spread struct holds a spread of a named fragment.
+type Spread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// Inline - The Inline struct holds an inline fragment, the type condition is empty if it is not given.
+type Inline struct {
+	On         string
+	Directives []*Directive
+	Selections []Selection
+}
+
+// Directive - The Directive struct holds a directive of the selection, only @skip and @include are executed.
+type Directive struct {
+	Name      string
+	Arguments map[string]interface{}
+}
+
+func (f *Field) directives() []*Directive  { return f.Directives }
+func (s *Spread) directives() []*Directive { return s.Directives }
+func (i *Inline) directives() []*Directive { return i.Directives }
+
+// reference - The reference type is the value of an argument that refers to a variable of the operation.
+type reference string
+
+const (
+	tokenEOF = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+// token - The token struct holds a lexical token of the document.
+type token struct {
+	kind  int
+	value string
+	at    int
+}
+
+// parser - The parser struct reads the tokens of the document one by one, the current token is the one to be consumed next.
+type parser struct {
+	source string
+	offset int
+	token  token
+}
+
+// Parse - This function parses the query document, the type system definitions are not supported, the schema of the api is
+// defined by the resolvers.
+func Parse(source string) (document *Document, err error) {
+
+	p := parser{source: strings.TrimPrefix(source, "\ufeff")}
+	document = &Document{Fragments: make(map[string]*Fragment)}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	for p.token.kind != tokenEOF {
+
+		switch {
+		case p.peek("{"):
+
+			selections, err := p.selections()
+			if err != nil {
+				return nil, err
+			}
+			document.Operations = append(document.Operations, &Operation{Type: "query", Selections: selections})
+
+		case p.peek("query"), p.peek("mutation"), p.peek("subscription"):
+
+			operation, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			document.Operations = append(document.Operations, operation)
+
+		case p.peek("fragment"):
+
+			fragment, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := document.Fragments[fragment.Name]; ok {
+				return nil, errors.Errorf("the fragment %v is defined more than once", fragment.Name)
+			}
+			document.Fragments[fragment.Name] = fragment
+
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(document.Operations) == 0 {
+		return nil, errors.New("the document has no operations")
+	}
+
+	return document, nil
+}
+
+// operation - This function parses the operation with its type, its name and the definitions of its variables.
+func (p *parser) operation() (*Operation, error) {
+
+	operation := Operation{Type: p.token.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.token.kind == tokenName {
+		operation.Name = p.token.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		for !p.peek(")") {
+
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+
+			if err := p.kind(); err != nil {
+				return nil, err
+			}
+
+			variable := Variable{Name: name}
+			if p.peek("=") {
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				if variable.Default, err = p.value(true); err != nil {
+					return nil, err
+				}
+			}
+
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+
+			operation.Variables = append(operation.Variables, &variable)
+		}
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.selections()
+	if err != nil {
+		return nil, err
+	}
+	operation.Selections = selections
+
+	return &operation, nil
+}
+
+// fragment - This function parses the named fragment with its type condition.
+func (p *parser) fragment() (*Fragment, error) {
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.peek("on") {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	on, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.selections()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Fragment{Name: name, On: on, Selections: selections}, nil
+}
+
+// selections - This function parses the selection set in the braces.
+func (p *parser) selections() (selections []Selection, err error) {
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for !p.peek("}") {
+
+		if p.token.kind == tokenEOF {
+			return nil, p.unexpected()
+		}
+
+		var (
+			selection Selection
+		)
+
+		if p.peek("...") {
+			selection, err = p.spread()
+		} else {
+			selection, err = p.field()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		selections = append(selections, selection)
+	}
+
+	if len(selections) == 0 {
+		return nil, errors.Errorf("the selection set at %d is empty", p.token.at)
+	}
+
+	return selections, p.next()
+}
+
+// field - This function parses the field with its alias, its arguments, its directives and its selection set.
+func (p *parser) field() (*Field, error) {
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	field := Field{Name: name}
+	if p.peek(":") {
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		if field.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		field.Alias = name
+	}
+
+	if field.Arguments, err = p.arguments(); err != nil {
+		return nil, err
+	}
+
+	if field.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+
+	if p.peek("{") {
+		if field.Selections, err = p.selections(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &field, nil
+}
+
+// spread - This function parses the spread of a named fragment or an inline fragment.
+func (p *parser) spread() (Selection, error) {
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.token.kind == tokenName && p.token.value != "on" {
+
+		spread := Spread{Name: p.token.value}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		directives, err := p.directives()
+		if err != nil {
+			return nil, err
+		}
+		spread.Directives = directives
+
+		return &spread, nil
+	}
+
+	var (
+		inline Inline
+		err    error
+	)
+
+	if p.peek("on") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if inline.On, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if inline.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+
+	if inline.Selections, err = p.selections(); err != nil {
+		return nil, err
+	}
+
+	return &inline, nil
+}
+
+// arguments - This function parses the arguments in the parentheses, if there are any.
+func (p *parser) arguments() (map[string]interface{}, error) {
+
+	if !p.peek("(") {
+		return nil, nil
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	arguments := make(map[string]interface{})
+	for !p.peek(")") {
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		if arguments[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+
+	return arguments, p.next()
+}
+
+// directives - This function parses the directives of the selection, if there are any.
+func (p *parser) directives() (directives []*Directive, err error) {
+
+	for p.peek("@") {
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		var (
+			directive Directive
+		)
+
+		if directive.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+
+		if directive.Arguments, err = p.arguments(); err != nil {
+			return nil, err
+		}
+
+		directives = append(directives, &directive)
+	}
+
+	return directives, nil
+}
+
+// kind - This function skips the type of the variable definition, the types of the variables are not checked, the values are
+// checked by the resolvers the arguments are passed to.
+func (p *parser) kind() error {
+
+	if p.peek("[") {
+
+		if err := p.next(); err != nil {
+			return err
+		}
+
+		if err := p.kind(); err != nil {
+			return err
+		}
+
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+
+	if p.peek("!") {
+		return p.next()
+	}
+
+	return nil
+}
+
+// value - This function parses the value of the argument, the constant values, such as the default values of the variables,
+// can not refer to the variables. The enum values are returned as strings.
+func (p *parser) value(constant bool) (value interface{}, err error) {
+
+	switch p.token.kind {
+	case tokenInt:
+		if value, err = strconv.ParseInt(p.token.value, 10, 64); err != nil {
+			return nil, errors.Errorf("the integer %v at %d is out of range", p.token.value, p.token.at)
+		}
+	case tokenFloat:
+		if value, err = strconv.ParseFloat(p.token.value, 64); err != nil {
+			return nil, errors.Errorf("the float %v at %d is not correct", p.token.value, p.token.at)
+		}
+	case tokenString:
+		value = p.token.value
+	case tokenName:
+		switch p.token.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = p.token.value
+		}
+	case tokenPunctuator:
+		switch p.token.value {
+		case "$":
+
+			if constant {
+				return nil, p.unexpected()
+			}
+
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+
+			return reference(name), nil
+
+		case "[":
+
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+
+			list := make([]interface{}, 0)
+			for !p.peek("]") {
+
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+
+				list = append(list, item)
+			}
+
+			return list, p.next()
+
+		case "{":
+
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+
+			object := make(map[string]interface{})
+			for !p.peek("}") {
+
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+
+				if object[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+
+			return object, p.next()
+
+		default:
+			return nil, p.unexpected()
+		}
+	default:
+		return nil, p.unexpected()
+	}
+
+	return value, p.next()
+}
+
+// name - This function consumes the name token and returns its value.
+func (p *parser) name() (string, error) {
+
+	if p.token.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.token.value
+
+	return name, p.next()
+}
+
+// expect - This function consumes the punctuator token, any other token is an error.
+func (p *parser) expect(punctuator string) error {
+
+	if !p.peek(punctuator) {
+		return p.unexpected()
+	}
+
+	return p.next()
+}
+
+// peek - This function reports whether the current token is the punctuator or the name of the value.
+func (p *parser) peek(value string) bool {
+	return (p.token.kind == tokenPunctuator || p.token.kind == tokenName) && p.token.value == value
+}
+
+// unexpected - This function returns the error of the current token.
+func (p *parser) unexpected() error {
+
+	if p.token.kind == tokenEOF {
+		return errors.New("the document ends unexpectedly")
+	}
+
+	return errors.Errorf("unexpected %q at %d", p.token.value, p.token.at)
+}
+
+// next - This function reads the next token of the document, the white space, the commas and the comments are skipped.
+func (p *parser) next() error {
+
+	for p.offset < len(p.source) {
+
+		c := p.source[p.offset]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.offset++
+			continue
+		}
+
+		if c == '#' {
+			for p.offset < len(p.source) && p.source[p.offset] != '\n' && p.source[p.offset] != '\r' {
+				p.offset++
+			}
+			continue
+		}
+
+		break
+	}
+
+	at := p.offset
+	if at >= len(p.source) {
+		p.token = token{kind: tokenEOF, at: at}
+		return nil
+	}
+
+	c := p.source[at]
+	switch {
+	case strings.HasPrefix(p.source[at:], "..."):
+		p.offset += 3
+		p.token = token{kind: tokenPunctuator, value: "...", at: at}
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.offset++
+		p.token = token{kind: tokenPunctuator, value: string(c), at: at}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.offset < len(p.source) && (p.source[p.offset] == '_' || p.source[p.offset] >= 'a' && p.source[p.offset] <= 'z' || p.source[p.offset] >= 'A' && p.source[p.offset] <= 'Z' || p.source[p.offset] >= '0' && p.source[p.offset] <= '9') {
+			p.offset++
+		}
+		p.token = token{kind: tokenName, value: p.source[at:p.offset], at: at}
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	case c == '"':
+		return p.string()
+	default:
+		return errors.Errorf("unexpected character %q at %d", c, at)
+	}
+
+	return nil
+}
+
+// number - This function reads the integer or the float token.
+func (p *parser) number() error {
+
+	at, kind := p.offset, tokenInt
+
+	if p.source[p.offset] == '-' {
+		p.offset++
+	}
+
+	digits := func() int {
+		start := p.offset
+		for p.offset < len(p.source) && p.source[p.offset] >= '0' && p.source[p.offset] <= '9' {
+			p.offset++
+		}
+		return p.offset - start
+	}
+
+	if digits() == 0 {
+		return errors.Errorf("the number at %d has no digits", at)
+	}
+
+	if p.offset < len(p.source) && p.source[p.offset] == '.' {
+		p.offset++
+		kind = tokenFloat
+		if digits() == 0 {
+			return errors.Errorf("the number at %d has no digits after the point", at)
+		}
+	}
+
+	if p.offset < len(p.source) && (p.source[p.offset] == 'e' || p.source[p.offset] == 'E') {
+		p.offset++
+		kind = tokenFloat
+		if p.offset < len(p.source) && (p.source[p.offset] == '+' || p.source[p.offset] == '-') {
+			p.offset++
+		}
+		if digits() == 0 {
+			return errors.Errorf("the number at %d has no digits in the exponent", at)
+		}
+	}
+
+	p.token = token{kind: kind, value: p.source[at:p.offset], at: at}
+
+	return nil
+}
+
+// string - This function reads the string token, the escape sequences of the quoted strings are decoded, the block strings are
+// taken as they are, without the common indentation removed.
+func (p *parser) string() error {
+
+	at := p.offset
+
+	if strings.HasPrefix(p.source[at:], `"""`) {
+
+		end := strings.Index(p.source[at+3:], `"""`)
+		if end < 0 {
+			return errors.Errorf("the block string at %d is not terminated", at)
+		}
+
+		p.offset = at + 3 + end + 3
+		p.token = token{kind: tokenString, value: strings.TrimSpace(p.source[at+3 : at+3+end]), at: at}
+
+		return nil
+	}
+
+	var (
+		value strings.Builder
+	)
+
+	p.offset++
+	for {
+
+		if p.offset >= len(p.source) || p.source[p.offset] == '\n' || p.source[p.offset] == '\r' {
+			return errors.Errorf("the string at %d is not terminated", at)
+		}
+
+		c := p.source[p.offset]
+		if c == '"' {
+			p.offset++
+			break
+		}
+
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.source[p.offset:])
+			value.WriteRune(r)
+			p.offset += size
+			continue
+		}
+
+		if p.offset+1 >= len(p.source) {
+			return errors.Errorf("the string at %d is not terminated", at)
+		}
+
+		switch e := p.source[p.offset+1]; e {
+		case '"', '\\', '/':
+			value.WriteByte(e)
+		case 'b':
+			value.WriteByte('\b')
+		case 'f':
+			value.WriteByte('\f')
+		case 'n':
+			value.WriteByte('\n')
+		case 'r':
+			value.WriteByte('\r')
+		case 't':
+			value.WriteByte('\t')
+		case 'u':
+			if p.offset+6 > len(p.source) {
+				return errors.Errorf("the escape sequence at %d is not correct", p.offset)
+			}
+			code, err := strconv.ParseUint(p.source[p.offset+2:p.offset+6], 16, 32)
+			if err != nil {
+				return errors.Errorf("the escape sequence at %d is not correct", p.offset)
+			}
+			value.WriteRune(rune(code))
+			p.offset += 4
+		default:
+			return errors.Errorf("the escape sequence at %d is not correct", p.offset)
+		}
+		p.offset += 2
+	}
+
+	p.token = token{kind: tokenString, value: value.String(), at: at}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package help
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarEvent - The CalendarEvent struct holds the fields of an event that are exported to the iCalendar format, the end of
+// the event is optional.
+type CalendarEvent struct {
+	Uid, Summary, Description string
+	Start, End                time.Time
+}
+
+// Calendar - This function encodes the events into an iCalendar (RFC 5545) document, so that the calendar can be imported or
+// subscribed to by the calendar applications. The lines are separated by CRLF, the texts are escaped, and the lines longer
+// than 75 octets are folded as required by the format.
+func Calendar(name, domain string, events []CalendarEvent) string {
+
+	var (
+		lines []string
+	)
+
+	lines = append(lines, "BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//"+calendarEscape(name)+"//Calendar//EN", "CALSCALE:GREGORIAN", "X-WR-CALNAME:"+calendarEscape(name))
+
+	for _, event := range events {
+		lines = append(lines, "BEGIN:VEVENT", fmt.Sprintf("UID:%s@%s", event.Uid, domain), "DTSTAMP:"+calendarTime(event.Start), "DTSTART:"+calendarTime(event.Start))
+		if !event.End.IsZero() {
+			lines = append(lines, "DTEND:"+calendarTime(event.End))
+		}
+		lines = append(lines, "SUMMARY:"+calendarEscape(event.Summary))
+		if len(event.Description) > 0 {
+			lines = append(lines, "DESCRIPTION:"+calendarEscape(event.Description))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	for i := range lines {
+		lines[i] = calendarFold(lines[i])
+	}
+
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// calendarTime - This function formats the time in the UTC form of the iCalendar format.
+func calendarTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// calendarEscape - This function escapes the characters that have a special meaning in the texts of the iCalendar format.
+func calendarEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// calendarFold - This function folds the line into the lines of at most 75 octets, every continuation line starts with a space.
+// The line is never cut inside of a multibyte character.
+func calendarFold(line string) string {
+
+	var (
+		builder strings.Builder
+		size    int
+	)
+
+	for _, r := range line {
+		if n := len(string(r)); size+n > 75 {
+			builder.WriteString("\r\n ")
+			size = 1
+		}
+		builder.WriteRune(r)
+		size += len(string(r))
+	}
+
+	return builder.String()
+}
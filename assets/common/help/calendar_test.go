@@ -0,0 +1,45 @@
+package help
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendar(t *testing.T) {
+
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	got := Calendar("Exchange", "example.com", []CalendarEvent{
+		{Uid: "event-1", Summary: "Listing: btc, usd", Description: "line\nnext", Start: start, End: start.Add(time.Hour)},
+		{Uid: "event-2", Summary: "Maintenance", Start: start},
+	})
+
+	want := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Exchange//Calendar//EN\r\nCALSCALE:GREGORIAN\r\nX-WR-CALNAME:Exchange\r\n" +
+		"BEGIN:VEVENT\r\nUID:event-1@example.com\r\nDTSTAMP:20230501T120000Z\r\nDTSTART:20230501T120000Z\r\nDTEND:20230501T130000Z\r\nSUMMARY:Listing: btc\\, usd\r\nDESCRIPTION:line\\nnext\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:event-2@example.com\r\nDTSTAMP:20230501T120000Z\r\nDTSTART:20230501T120000Z\r\nSUMMARY:Maintenance\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	if got != want {
+		t.Errorf("Calendar() = %q, want %q", got, want)
+	}
+}
+
+func Test_calendarFold(t *testing.T) {
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "short", line: "SUMMARY:short", want: "SUMMARY:short"},
+		{name: "long", line: strings.Repeat("a", 80), want: strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 5)},
+		{name: "multibyte", line: strings.Repeat("a", 74) + "ёa", want: strings.Repeat("a", 74) + "\r\n ёa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendarFold(tt.line); got != tt.want {
+				t.Errorf("calendarFold() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,29 @@
 package help
 
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// resolutions - The units of the custom resolutions, the resolution is written as a number with an optional suffix: "m" for
+// minutes (the default), "h" for hours, "d" for days, "w" for weeks and "M" for months, for example "3m", "2h", "3d", "1w" or "1M".
+var resolutions = map[string]string{
+	"":  "minute",
+	"m": "minute",
+	"h": "hour",
+	"H": "hour",
+	"d": "day",
+	"D": "day",
+	"w": "week",
+	"W": "week",
+	"M": "month",
+}
+
+// resolutionRegexp - The pattern of a custom resolution, the number is limited to four digits to keep the intervals sensible.
+var resolutionRegexp = regexp.MustCompile(`^([1-9][0-9]{0,3})([mhHdDwWM]?)$`)
+
 // Depth - This function creates an array of strings that represent different depths of data. The depths are 0, 60, 300, 900,
 // 1800, 3600, and 86400. This list could be used to set the depth of data requested from a particular source.
 func Depth() []string {
@@ -34,5 +58,47 @@ func Resolution(depth string) string {
 		return "1 day"
 	}
 
+	// Any other resolution is parsed as a custom one, so that the candles can be aggregated into arbitrary intervals like
+	// 3 minutes, 2 hours, 3 days, 1 week or 1 month.
+	if match := resolutionRegexp.FindStringSubmatch(depth); match != nil {
+
+		value, _ := strconv.Atoi(match[1])
+		if value == 1 {
+			return fmt.Sprintf("%d %s", value, resolutions[match[2]])
+		}
+
+		return fmt.Sprintf("%d %ss", value, resolutions[match[2]])
+	}
+
 	return "15 minutes"
 }
+
+// Period - This function returns the approximate length of the given resolution, a month is counted as thirty days. The length is
+// used to decide how expensive the aggregation of the candles is, and for how long its result may be cached.
+func Period(depth string) time.Duration {
+
+	var (
+		value int
+		unit  string
+	)
+
+	// The resolution is first translated into the interval, so that the standard and the custom resolutions are measured the same way.
+	if _, err := fmt.Sscanf(Resolution(depth), "%d %s", &value, &unit); err != nil {
+		return 0
+	}
+
+	switch unit {
+	case "minute", "minutes":
+		return time.Duration(value) * time.Minute
+	case "hour", "hours":
+		return time.Duration(value) * time.Hour
+	case "day", "days":
+		return time.Duration(value) * 24 * time.Hour
+	case "week", "weeks":
+		return time.Duration(value) * 7 * 24 * time.Hour
+	case "month", "months":
+		return time.Duration(value) * 30 * 24 * time.Hour
+	}
+
+	return 0
+}
@@ -0,0 +1,52 @@
+package help
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolution(t *testing.T) {
+	tests := []struct {
+		depth string
+		want  string
+	}{
+		{depth: "60", want: "1 minute"},
+		{depth: "1h", want: "1 hour"},
+		{depth: "1D", want: "1 day"},
+		{depth: "3m", want: "3 minutes"},
+		{depth: "2h", want: "2 hours"},
+		{depth: "3d", want: "3 days"},
+		{depth: "1w", want: "1 week"},
+		{depth: "1M", want: "1 month"},
+		{depth: "240", want: "240 minutes"},
+		{depth: "0", want: "15 minutes"},
+		{depth: "1y", want: "15 minutes"},
+		{depth: "1'; drop table ohlcv; --", want: "15 minutes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.depth, func(t *testing.T) {
+			if got := Resolution(tt.depth); got != tt.want {
+				t.Errorf("Resolution() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	tests := []struct {
+		depth string
+		want  time.Duration
+	}{
+		{depth: "300", want: 5 * time.Minute},
+		{depth: "2h", want: 2 * time.Hour},
+		{depth: "1w", want: 7 * 24 * time.Hour},
+		{depth: "1M", want: 30 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.depth, func(t *testing.T) {
+			if got := Period(tt.depth); got != tt.want {
+				t.Errorf("Period() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
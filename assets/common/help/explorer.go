@@ -0,0 +1,21 @@
+package help
+
+import (
+	"strings"
+)
+
+// Explorer - This function returns the link to the transaction of the hash in the block explorer of the chain. The explorer link of
+// the chain is either the address the hash is appended to, such as "https://etherscan.io/tx", or a template with the
+// "{hash}" placeholder, for the explorers that take the hash in the query. The empty link or hash returns no link.
+func Explorer(link, hash string) string {
+
+	if len(link) == 0 || len(hash) == 0 {
+		return ""
+	}
+
+	if strings.Contains(link, "{hash}") {
+		return strings.ReplaceAll(link, "{hash}", hash)
+	}
+
+	return strings.TrimRight(link, "/") + "/" + hash
+}
@@ -0,0 +1,26 @@
+package help
+
+import "testing"
+
+func TestExplorer(t *testing.T) {
+
+	tests := []struct {
+		name string
+		link string
+		hash string
+		want string
+	}{
+		{name: "appended", link: "https://etherscan.io/tx", hash: "0xabc", want: "https://etherscan.io/tx/0xabc"},
+		{name: "trailing slash", link: "https://tronscan.org/#/transaction/", hash: "abc", want: "https://tronscan.org/#/transaction/abc"},
+		{name: "template", link: "https://explorer.example/search?q={hash}&kind=tx", hash: "abc", want: "https://explorer.example/search?q=abc&kind=tx"},
+		{name: "no link", link: "", hash: "abc", want: ""},
+		{name: "no hash", link: "https://etherscan.io/tx", hash: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Explorer(tt.link, tt.hash); got != tt.want {
+				t.Errorf("Explorer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package help
+
+import (
+	"github.com/pkg/errors"
+	"time"
+
+	// The locations of the market zones are resolved from the database embedded into the binary, so that the trading hours
+	// do not depend on the time zone database of the host.
+	_ "time/tzdata"
+)
+
+const (
+	SessionClosed  = "closed"
+	SessionPre     = "pre"
+	SessionRegular = "regular"
+	SessionPost    = "post"
+)
+
+// Hours - The Hours struct holds the trading hours of a market zone in the local time of the zone, in the "15:04" format: the
+// pre-market from the pre open to the open, the regular session from the open to the close and the post-market from the
+// close to the post close. The pre open and the post close are optional. The days are the trading weekdays, Sunday is 0.
+type Hours struct {
+	Location                        *time.Location
+	PreOpen, Open, Close, PostClose string
+	Days                            []int32
+}
+
+// Holiday - The Holiday struct holds a holiday of a market zone, the date is in the "2006-01-02" format. The market is closed the
+// whole day, unless the early close of the half-day is set, in this case the regular session ends at the early close and
+// there is no post-market.
+type Holiday struct {
+	Date, Close string
+}
+
+// Session - This function returns the session of the market zone at the given time: the pre-market, the regular session, the
+// post-market, or closed.
+func Session(now time.Time, hours Hours, holidays []Holiday) (string, error) {
+
+	local := now.In(hours.Location)
+
+	open, close, pre, post, ok, err := sessionDay(local, hours, holidays)
+	if err != nil || !ok {
+		return SessionClosed, err
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+
+	switch {
+	case minute >= open && minute < close:
+		return SessionRegular, nil
+	case minute >= pre && minute < open:
+		return SessionPre, nil
+	case minute >= close && minute < post:
+		return SessionPost, nil
+	}
+
+	return SessionClosed, nil
+}
+
+// NextOpen - This function returns the time of the next opening of the regular session of the market zone after the given time,
+// the two weeks ahead are looked through.
+func NextOpen(now time.Time, hours Hours, holidays []Holiday) (time.Time, error) {
+
+	local := now.In(hours.Location)
+
+	for i := 0; i < 14; i++ {
+
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, 0, 0, 0, 0, hours.Location)
+
+		open, _, _, _, ok, err := sessionDay(day, hours, holidays)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if at := day.Add(time.Duration(open) * time.Minute); ok && at.After(now) {
+			return at, nil
+		}
+	}
+
+	return time.Time{}, errors.New("the market does not open in the next two weeks")
+}
+
+// sessionDay - This function returns the minutes of the day at which the sessions of the market zone start and end on the day
+// of the given local time, and whether the market trades on that day at all.
+func sessionDay(local time.Time, hours Hours, holidays []Holiday) (open, close, pre, post int, ok bool, err error) {
+
+	if !IndexOf(hours.Days, int32(local.Weekday())) {
+		return open, close, pre, post, false, nil
+	}
+
+	if open, err = sessionMinute(hours.Open); err != nil {
+		return open, close, pre, post, false, err
+	}
+
+	if close, err = sessionMinute(hours.Close); err != nil {
+		return open, close, pre, post, false, err
+	}
+
+	pre, post = open, close
+
+	if len(hours.PreOpen) > 0 {
+		if pre, err = sessionMinute(hours.PreOpen); err != nil {
+			return open, close, pre, post, false, err
+		}
+	}
+
+	if len(hours.PostClose) > 0 {
+		if post, err = sessionMinute(hours.PostClose); err != nil {
+			return open, close, pre, post, false, err
+		}
+	}
+
+	date := local.Format("2006-01-02")
+	for _, holiday := range holidays {
+
+		if holiday.Date != date {
+			continue
+		}
+
+		if len(holiday.Close) == 0 {
+			return open, close, pre, post, false, nil
+		}
+
+		if close, err = sessionMinute(holiday.Close); err != nil {
+			return open, close, pre, post, false, err
+		}
+		post = close
+	}
+
+	return open, close, pre, post, true, nil
+}
+
+// sessionMinute - This function converts the time of the day in the "15:04" format into the minute of the day.
+func sessionMinute(value string) (int, error) {
+
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.Errorf("the time %v must be in the 15:04 format", value)
+	}
+
+	return clock.Hour()*60 + clock.Minute(), nil
+}
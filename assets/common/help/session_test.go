@@ -0,0 +1,96 @@
+package help
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+
+	location := time.FixedZone("EST", -5*60*60)
+
+	hours := Hours{
+		Location:  location,
+		PreOpen:   "04:00",
+		Open:      "09:30",
+		Close:     "16:00",
+		PostClose: "20:00",
+		Days:      []int32{1, 2, 3, 4, 5},
+	}
+
+	holidays := []Holiday{
+		{Date: "2023-07-04"},
+		{Date: "2023-07-03", Close: "13:00"},
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{name: "night", now: time.Date(2023, 7, 5, 3, 59, 0, 0, location), want: SessionClosed},
+		{name: "pre", now: time.Date(2023, 7, 5, 4, 0, 0, 0, location), want: SessionPre},
+		{name: "open", now: time.Date(2023, 7, 5, 9, 30, 0, 0, location), want: SessionRegular},
+		{name: "close", now: time.Date(2023, 7, 5, 16, 0, 0, 0, location), want: SessionPost},
+		{name: "after post", now: time.Date(2023, 7, 5, 20, 0, 0, 0, location), want: SessionClosed},
+		{name: "utc", now: time.Date(2023, 7, 5, 15, 0, 0, 0, time.UTC), want: SessionRegular},
+		{name: "weekend", now: time.Date(2023, 7, 8, 12, 0, 0, 0, location), want: SessionClosed},
+		{name: "holiday", now: time.Date(2023, 7, 4, 12, 0, 0, 0, location), want: SessionClosed},
+		{name: "half-day", now: time.Date(2023, 7, 3, 12, 59, 0, 0, location), want: SessionRegular},
+		{name: "half-day close", now: time.Date(2023, 7, 3, 13, 0, 0, 0, location), want: SessionClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Session(tt.now, hours, holidays)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Session() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Session(time.Date(2023, 7, 5, 12, 0, 0, 0, location), Hours{Location: location, Open: "9:30am", Close: "16:00", Days: []int32{3}}, nil); err == nil {
+		t.Errorf("Session() with a malformed time must fail")
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+
+	location := time.FixedZone("EST", -5*60*60)
+
+	hours := Hours{
+		Location: location,
+		Open:     "09:30",
+		Close:    "16:00",
+		Days:     []int32{1, 2, 3, 4, 5},
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		holidays []Holiday
+		want     time.Time
+	}{
+		{name: "before open", now: time.Date(2023, 7, 5, 8, 0, 0, 0, location), want: time.Date(2023, 7, 5, 9, 30, 0, 0, location)},
+		{name: "after open", now: time.Date(2023, 7, 5, 10, 0, 0, 0, location), want: time.Date(2023, 7, 6, 9, 30, 0, 0, location)},
+		{name: "weekend", now: time.Date(2023, 7, 7, 17, 0, 0, 0, location), want: time.Date(2023, 7, 10, 9, 30, 0, 0, location)},
+		{name: "holiday", now: time.Date(2023, 7, 3, 17, 0, 0, 0, location), holidays: []Holiday{{Date: "2023-07-04"}}, want: time.Date(2023, 7, 5, 9, 30, 0, 0, location)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextOpen(tt.now, hours, tt.holidays)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextOpen() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NextOpen(time.Date(2023, 7, 5, 8, 0, 0, 0, location), Hours{Location: location, Open: "09:30", Close: "16:00"}, nil); err == nil {
+		t.Errorf("NextOpen() without the trading days must fail")
+	}
+}
@@ -0,0 +1,18 @@
+package help
+
+import (
+	"math"
+)
+
+// Step - This function checks that the value is a multiple of the step, for example that the price of an order follows the
+// tick size of the pair. A zero step accepts any value. The values are compared with a relative tolerance, because the
+// prices and the quantities are floating point numbers and 0.3 is not an exact multiple of 0.1 in binary.
+func Step(value, step float64) bool {
+
+	if step <= 0 {
+		return true
+	}
+
+	ratio := value / step
+	return math.Abs(ratio-math.Round(ratio)) <= 1e-9*math.Max(1, math.Abs(ratio))
+}
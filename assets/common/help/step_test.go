@@ -0,0 +1,27 @@
+package help
+
+import "testing"
+
+func TestStep(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		value float64
+		step  float64
+		want  bool
+	}{
+		{name: "no step", value: 1.2345, step: 0, want: true},
+		{name: "multiple", value: 0.3, step: 0.1, want: true},
+		{name: "large multiple", value: 22689.05, step: 0.01, want: true},
+		{name: "not multiple", value: 0.35, step: 0.1, want: false},
+		{name: "below step", value: 0.001, step: 0.01, want: false},
+		{name: "integer step", value: 1500, step: 100, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Step(tt.value, tt.step); got != tt.want {
+				t.Errorf("Step() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package help
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	// RecvWindowDefault - The default receive window in milliseconds applied to a signed request when the client does not
+	// send its own value, it matches the window most trading bots expect out of the box.
+	RecvWindowDefault int64 = 5000
+
+	// RecvWindowMax - The upper limit of a receive window in milliseconds, larger values would make captured requests
+	// replayable for too long.
+	RecvWindowMax int64 = 60000
+)
+
+// Window - This function checks if the timestamp of a signed request (in milliseconds) falls into the receive window relative to
+// the server time. A request from the future is tolerated only by one second of clock skew, a request from the past is
+// tolerated by the size of the receive window itself.
+func Window(timestamp, recvWindow int64, now time.Time) bool {
+
+	// The purpose of this code is to bring the receive window into the allowed boundaries, a zero or negative window falls
+	// back to the default value and a window above the maximum is cut down to the maximum.
+	if recvWindow <= 0 {
+		recvWindow = RecvWindowDefault
+	}
+	if recvWindow > RecvWindowMax {
+		recvWindow = RecvWindowMax
+	}
+
+	// The server time is taken in milliseconds so that it can be compared with the timestamp sent by the client.
+	server := now.UnixMilli()
+
+	return timestamp < server+1000 && server-timestamp <= recvWindow
+}
+
+// SignatureHmac256 - This function computes the hex encoded HMAC-SHA256 signature of the request parameters joined in the order they
+// were passed by the new line, with the given secret as a key. The delimiter keeps the parameters apart, so that the
+// values "1", "23" and "12", "3" are not signed alike. The client builds the same string on its side, so the two values
+// can be compared.
+func SignatureHmac256(secret string, params ...interface{}) string {
+
+	// The purpose of this code is to create a new HMAC hash with the SHA-256 algorithm, and write all the request
+	// parameters into it one after another, separated by the new line.
+	mac := hmac.New(sha256.New, []byte(secret))
+	for i, param := range params {
+		if i > 0 {
+			mac.Write([]byte("\n"))
+		}
+		mac.Write([]byte(fmt.Sprintf("%v", param)))
+	}
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,46 @@
+package help
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		timestamp  int64
+		recvWindow int64
+		want       bool
+	}{
+		{name: "in window", timestamp: now.UnixMilli() - 1000, recvWindow: 5000, want: true},
+		{name: "default window", timestamp: now.UnixMilli() - 4000, recvWindow: 0, want: true},
+		{name: "expired", timestamp: now.UnixMilli() - 6000, recvWindow: 5000, want: false},
+		{name: "max window", timestamp: now.UnixMilli() - 61000, recvWindow: 120000, want: false},
+		{name: "small skew", timestamp: now.UnixMilli() + 500, recvWindow: 5000, want: true},
+		{name: "future", timestamp: now.UnixMilli() + 2000, recvWindow: 5000, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Window(tt.timestamp, tt.recvWindow, now); got != tt.want {
+				t.Errorf("Window() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureHmac256(t *testing.T) {
+	a := SignatureHmac256("secret", 1700000000000, 5000)
+	if b := SignatureHmac256("secret", 1700000000000, 5000); a != b {
+		t.Errorf("SignatureHmac256() is not deterministic: %v != %v", a, b)
+	}
+	if b := SignatureHmac256("other", 1700000000000, 5000); a == b {
+		t.Errorf("SignatureHmac256() does not depend on the secret")
+	}
+	if SignatureHmac256("secret", "1", "23") == SignatureHmac256("secret", "12", "3") {
+		t.Errorf("SignatureHmac256() does not separate the parameters")
+	}
+	if len(a) != 64 {
+		t.Errorf("SignatureHmac256() length = %v, want 64", len(a))
+	}
+}
@@ -0,0 +1,229 @@
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Default - The locale of the messages as they are written in the code, the messages that are not translated to the locale of
+	// the user are returned in it.
+	Default = "en"
+)
+
+// locale - The key of the locale of the request in its context.
+type locale struct{}
+
+// Catalogue - The Catalogue struct holds the translations of the messages by their locales and their ids, the translations of a
+// locale are read from the json file named after the locale, such as "ru.json" or "pt-br.json", which maps the ids of the
+// messages to their translations. The translations keep the verbs of the messages they translate, in the same order or
+// indexed, such as "%[2]v".
+type Catalogue struct {
+	messages map[string]map[string]string
+}
+
+// Load - This function reads the catalogue from the json files of the directory, one file per locale. The directory that does not
+// exist is an empty catalogue, all the messages are returned as they are written in the code.
+func Load(dir string) (*Catalogue, error) {
+
+	var (
+		catalogue = Catalogue{messages: make(map[string]map[string]string)}
+	)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+
+		var (
+			messages map[string]string
+		)
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("the translations of %v: %v", filepath.Base(file), err)
+		}
+
+		catalogue.messages[strings.ToLower(strings.TrimSuffix(filepath.Base(file), ".json"))] = messages
+	}
+
+	return &catalogue, nil
+}
+
+// Locales - This function returns the locales of the catalogue, the default locale among them.
+func (c *Catalogue) Locales() (locales []string) {
+
+	locales = append(locales, Default)
+	if c != nil {
+		for name := range c.messages {
+			if name != Default {
+				locales = append(locales, name)
+			}
+		}
+	}
+	sort.Strings(locales[1:])
+
+	return locales
+}
+
+// Supported - This function returns the locale of the catalogue the locale is served with: the locale itself, or its language
+// when only the language is translated, such as "pt" for "pt-BR". The empty string is returned for the locales that are
+// not translated, the default locale is always supported.
+func (c *Catalogue) Supported(name string) string {
+
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", "-"))
+	if len(name) == 0 {
+		return ""
+	}
+
+	language := strings.SplitN(name, "-", 2)[0]
+	if name == Default || language == Default {
+		return Default
+	}
+
+	if c == nil {
+		return ""
+	}
+
+	for _, candidate := range []string{name, language} {
+		if _, ok := c.messages[candidate]; ok {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// Negotiate - This function returns the locale of the catalogue preferred by the value of the Accept-Language header, the locales
+// of the header are tried by their weights, the empty string is returned when none of them is supported.
+func (c *Catalogue) Negotiate(accept string) string {
+
+	type preference struct {
+		name   string
+		weight float64
+	}
+
+	var (
+		preferences []preference
+	)
+
+	for _, part := range strings.Split(accept, ",") {
+
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		item := preference{name: strings.TrimSpace(fields[0]), weight: 1}
+
+		for _, field := range fields[1:] {
+			if value := strings.TrimSpace(field); strings.HasPrefix(value, "q=") {
+				if weight, err := strconv.ParseFloat(strings.TrimPrefix(value, "q="), 64); err == nil {
+					item.weight = weight
+				}
+			}
+		}
+
+		if len(item.name) > 0 && item.name != "*" && item.weight > 0 {
+			preferences = append(preferences, item)
+		}
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].weight > preferences[j].weight })
+
+	for _, item := range preferences {
+		if name := c.Supported(item.name); len(name) > 0 {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Translate - This function returns the message with the id in the locale, formatted with the arguments. The message that is not
+// translated to the locale is the fallback, the message as it is written in the code.
+func (c *Catalogue) Translate(name, id, fallback string, args ...interface{}) string {
+
+	format := fallback
+	if c != nil {
+		if translation, ok := c.messages[c.Supported(name)][id]; ok && len(translation) > 0 {
+			format = translation
+		}
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+// WithLocale - This function returns the context of the request with the locale the messages are returned in.
+func WithLocale(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, locale{}, name)
+}
+
+// Locale - This function returns the locale of the context of the request, the default locale when it is not set.
+func Locale(ctx context.Context) string {
+	if name, ok := ctx.Value(locale{}).(string); ok && len(name) > 0 {
+		return name
+	}
+	return Default
+}
+
+// Message - The Message struct is the error of the grpc status that is translated to the locale of the user: the id the message
+// is translated by, the code of the status and the message as it is written in the code with its arguments. Until it
+// is translated, it is the error of the status with the message in the default locale.
+type Message struct {
+	Id     string
+	Code   codes.Code
+	Format string
+	Args   []interface{}
+}
+
+// Error - This function returns the message in the default locale.
+func (m *Message) Error() string {
+	if len(m.Args) == 0 {
+		return m.Format
+	}
+	return fmt.Sprintf(m.Format, m.Args...)
+}
+
+// GRPCStatus - This function returns the status of the message in the default locale, so that the message is the error of the
+// status wherever it is not translated.
+func (m *Message) GRPCStatus() *status.Status {
+	return status.New(m.Code, m.Error())
+}
+
+// Status - This function returns the error of the status with the message translated to the locale by the catalogue.
+func (m *Message) Status(c *Catalogue, name string) error {
+	return status.Error(m.Code, c.Translate(name, m.Id, m.Format, m.Args...))
+}
+
+// Error - This function returns the error of the status with the message translated to the locale of the user, the code of the
+// status is the id of the message. It takes the place of the status.Error function.
+func Error(code codes.Code, message string) error {
+	return &Message{Id: strconv.FormatUint(uint64(code), 10), Code: code, Format: message}
+}
+
+// Errorf - This function returns the error of the status with the formatted message translated to the locale of the user, the
+// code of the status is the id of the message. It takes the place of the status.Errorf function.
+func Errorf(code codes.Code, format string, args ...interface{}) error {
+	return &Message{Id: strconv.FormatUint(uint64(code), 10), Code: code, Format: format, Args: args}
+}
+
+// Messagef - This function returns the error of the status like the Errorf function, with the id of the message given, for the
+// codes that are returned with more than one message.
+func Messagef(id string, code codes.Code, format string, args ...interface{}) error {
+	return &Message{Id: id, Code: code, Format: format, Args: args}
+}
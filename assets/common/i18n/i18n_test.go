@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func catalogue(t *testing.T) *Catalogue {
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"ru.json":    `{"11597": "нельзя отслеживать больше %d адресов", "11655": "сеть %[2]v: цепочка %[1]v"}`,
+		"pt-br.json": `{"11597": "não é possível monitorar mais de %d endereços"}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestNegotiate(t *testing.T) {
+
+	c := catalogue(t)
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "ru-RU,ru;q=0.9,en;q=0.8", want: "ru"},
+		{accept: "de-DE, en;q=0.5", want: "en"},
+		{accept: "en;q=0.4, pt-BR;q=0.8", want: "pt-br"},
+		{accept: "de, fr;q=0.7", want: ""},
+		{accept: "ru;q=0, en", want: "en"},
+		{accept: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := c.Negotiate(tt.accept); got != tt.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+
+	if got := c.Locales(); !reflect.DeepEqual(got, []string{"en", "pt-br", "ru"}) {
+		t.Errorf("Locales() = %v", got)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+
+	c := catalogue(t)
+
+	if got := c.Translate("ru-RU", "11597", "you cannot watch more than %d addresses", 5); got != "нельзя отслеживать больше 5 адресов" {
+		t.Errorf("Translate() = %q", got)
+	}
+
+	if got := c.Translate("ru", "11655", "the chain %v belongs to the %v network", "bsc", "ethereum"); got != "сеть ethereum: цепочка bsc" {
+		t.Errorf("Translate() with the indexed verbs = %q", got)
+	}
+
+	if got := c.Translate("de", "11597", "you cannot watch more than %d addresses", 5); got != "you cannot watch more than 5 addresses" {
+		t.Errorf("Translate() of the locale that is not translated = %q", got)
+	}
+
+	var empty *Catalogue
+	if got := empty.Translate("ru", "11597", "you cannot watch more than %d addresses", 5); got != "you cannot watch more than 5 addresses" {
+		t.Errorf("Translate() without the catalogue = %q", got)
+	}
+}
+
+func TestMessage(t *testing.T) {
+
+	c := catalogue(t)
+	err := Errorf(11597, "you cannot watch more than %d addresses", 5)
+
+	// The message is the error of the status in the default locale until it is translated.
+	if s, ok := status.FromError(err); !ok || s.Code() != codes.Code(11597) || s.Message() != "you cannot watch more than 5 addresses" {
+		t.Errorf("status.FromError() = %v, %v", s, ok)
+	}
+
+	var message *Message
+	if !errors.As(err, &message) {
+		t.Fatal("errors.As() did not find the message")
+	}
+
+	if s, _ := status.FromError(message.Status(c, Locale(WithLocale(context.Background(), "ru")))); s.Code() != codes.Code(11597) || s.Message() != "нельзя отслеживать больше 5 адресов" {
+		t.Errorf("Status() = %v", s)
+	}
+
+	if got := Locale(context.Background()); got != Default {
+		t.Errorf("Locale() = %q, want the default locale", got)
+	}
+}
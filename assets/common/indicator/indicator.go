@@ -0,0 +1,162 @@
+package indicator
+
+import (
+	"math"
+)
+
+// Sma - This function calculates the simple moving average of the given values over the given period. The values are expected
+// in chronological order, the result has the same length as the values, and the first period-1 points, for which the
+// average cannot be calculated yet, are equal to zero.
+func Sma(values []float64, period int) []float64 {
+
+	var (
+		result = make([]float64, len(values))
+		sum    float64
+	)
+
+	// This code checks that the period is valid, a period longer than the values leaves the whole result at zero.
+	if period <= 0 {
+		return result
+	}
+
+	// The purpose of this loop is to keep a running sum of the last period values, the value that leaves the window is
+	// subtracted, so that each point is calculated in constant time.
+	for i := range values {
+		sum += values[i]
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			result[i] = sum / float64(period)
+		}
+	}
+
+	return result
+}
+
+// Ema - This function calculates the exponential moving average of the given values over the given period. The average is seeded
+// with the simple moving average of the first period values, the points before the seed are equal to zero.
+func Ema(values []float64, period int) []float64 {
+
+	var (
+		result = make([]float64, len(values))
+	)
+
+	// This code checks that there are enough values to seed the average.
+	if period <= 0 || len(values) < period {
+		return result
+	}
+
+	// The smoothing factor gives the weight of the last value, the longer the period, the smoother the average.
+	k := 2 / float64(period+1)
+
+	result[period-1] = Sma(values[:period], period)[period-1]
+	for i := period; i < len(values); i++ {
+		result[i] = values[i]*k + result[i-1]*(1-k)
+	}
+
+	return result
+}
+
+// Vwap - This function calculates the volume weighted average price of the given candles, the typical price of each candle is the
+// average of its high, low and close prices. The average is cumulative from the first candle, so each point is the
+// average price of all the volume traded up to it.
+func Vwap(high, low, close, volume []float64) []float64 {
+
+	var (
+		result = make([]float64, len(close))
+		amount float64
+		total  float64
+	)
+
+	for i := range close {
+
+		// This code accumulates the traded amount and the traded volume, the candles without volume keep the previous average.
+		amount += (high[i] + low[i] + close[i]) / 3 * volume[i]
+		total += volume[i]
+
+		if total > 0 {
+			result[i] = amount / total
+		}
+	}
+
+	return result
+}
+
+// Rsi - This function calculates the relative strength index of the given values over the given period, using the smoothing of
+// Wilder. The index is in the range from 0 to 100, the first period points, for which it cannot be calculated yet, are
+// equal to zero.
+func Rsi(values []float64, period int) []float64 {
+
+	var (
+		result = make([]float64, len(values))
+		gain   float64
+		loss   float64
+	)
+
+	// This code checks that there are enough values to calculate the first average gain and loss.
+	if period <= 0 || len(values) <= period {
+		return result
+	}
+
+	// The purpose of this loop is to calculate the first average gain and loss as a simple average of the first period changes.
+	for i := 1; i <= period; i++ {
+		if change := values[i] - values[i-1]; change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	result[period] = rsi(gain, loss)
+
+	// The next averages are smoothed, each new change has the weight of one period.
+	for i := period + 1; i < len(values); i++ {
+
+		change := values[i] - values[i-1]
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+
+		result[i] = rsi(gain, loss)
+	}
+
+	return result
+}
+
+// rsi - This function converts the average gain and loss into the relative strength index, a period without losses has the index of 100.
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// Bollinger - This function calculates the bollinger bands of the given values over the given period. The middle band is the simple
+// moving average, the upper and lower bands are shifted from it by the given number of standard deviations. The points
+// before the first full period are equal to zero.
+func Bollinger(values []float64, period int, deviation float64) (upper, middle, lower []float64) {
+
+	upper = make([]float64, len(values))
+	lower = make([]float64, len(values))
+	middle = Sma(values, period)
+
+	if period <= 0 {
+		return upper, middle, lower
+	}
+
+	for i := period - 1; i < len(values); i++ {
+
+		// This code calculates the standard deviation of the values in the window from their average.
+		var variance float64
+		for _, value := range values[i-period+1 : i+1] {
+			variance += (value - middle[i]) * (value - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+
+		upper[i] = middle[i] + deviation*sd
+		lower[i] = middle[i] - deviation*sd
+	}
+
+	return upper, middle, lower
+}
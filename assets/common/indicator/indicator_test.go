@@ -0,0 +1,56 @@
+package indicator
+
+import (
+	"math"
+	"testing"
+)
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSma(t *testing.T) {
+	if got := Sma([]float64{1, 2, 3, 4, 5}, 3); !equal(got, []float64{0, 0, 2, 3, 4}) {
+		t.Errorf("Sma() = %v", got)
+	}
+	if got := Sma([]float64{1, 2}, 3); !equal(got, []float64{0, 0}) {
+		t.Errorf("Sma() = %v", got)
+	}
+}
+
+func TestEma(t *testing.T) {
+	if got := Ema([]float64{1, 2, 3, 4}, 3); !equal(got, []float64{0, 0, 2, 3}) {
+		t.Errorf("Ema() = %v", got)
+	}
+}
+
+func TestVwap(t *testing.T) {
+	got := Vwap([]float64{3, 6}, []float64{3, 6}, []float64{3, 6}, []float64{1, 2})
+	if !equal(got, []float64{3, 5}) {
+		t.Errorf("Vwap() = %v", got)
+	}
+}
+
+func TestRsi(t *testing.T) {
+	if got := Rsi([]float64{1, 2, 3, 4}, 2); !equal(got, []float64{0, 0, 100, 100}) {
+		t.Errorf("Rsi() = %v", got)
+	}
+	if got := Rsi([]float64{1, 2, 1}, 2); !equal(got, []float64{0, 0, 50}) {
+		t.Errorf("Rsi() = %v", got)
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	upper, middle, lower := Bollinger([]float64{1, 3, 1, 3}, 2, 2)
+	if !equal(middle, []float64{0, 2, 2, 2}) || !equal(upper, []float64{0, 4, 4, 4}) || !equal(lower, []float64{0, 0, 0, 0}) {
+		t.Errorf("Bollinger() = %v, %v, %v", upper, middle, lower)
+	}
+}
@@ -1,7 +1,11 @@
 package keypair
 
 import (
+	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/btcutil/base58"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
@@ -26,6 +30,13 @@ type CrossChain struct {
 // any errors that may occur. It uses the BIP39 standard to generate the seed and then applies the seed to the chosen
 // platform to generate the address and private key.
 func (s *CrossChain) New(secret string, bytea []byte, platform string) (a, p string, err error) {
+	return s.Derive(secret, bytea, platform, 0)
+}
+
+// Derive - This function generates the address and the private key of the platform in the same way as New, but with the given
+// index of the address in the last element of the derivation path. The index 0 is the address returned by New, the
+// following indexes are the additional deposit addresses of the same user, all of them are derived from the same seed.
+func (s *CrossChain) Derive(secret string, bytea []byte, platform string, index uint32) (a, p string, err error) {
 
 	// This is an if statement that checks the length of the variable bytea. If the length is equal to 0, then a certain
 	// action is taken. This is used to ensure that an empty variable doesn't cause an error.
@@ -54,7 +65,7 @@ func (s *CrossChain) New(secret string, bytea []byte, platform string) (a, p str
 
 		// This code is checking for an error when calling a function called "master" with the given parameters. If there is an
 		// error, it returns an error instead of the values "a" and "p".
-		private, err := s.master(seed, 44, 0, 0, 0, 0)
+		private, err := s.master(seed, 44, 0, 0, 0, index)
 		if err != nil {
 			return a, p, err
 		}
@@ -76,10 +87,10 @@ func (s *CrossChain) New(secret string, bytea []byte, platform string) (a, p str
 	case types.PlatformEthereum:
 
 		// This code snippet is part of a function that is attempting to generate a master seed for an application. The
-		// private, err := s.master(seed, 44, 60, 0, 0, 0) line is used to call the master function with the seed and other
+		// private, err := s.master(seed, 44, 60, 0, 0, index) line is used to call the master function with the seed and other
 		// parameters needed for the function to generate a master seed for the application. If an error occurs, the function
 		// will return the error and halt any further execution.
-		private, err := s.master(seed, 44, 60, 0, 0, 0)
+		private, err := s.master(seed, 44, 60, 0, 0, index)
 		if err != nil {
 			return a, p, err
 		}
@@ -96,7 +107,7 @@ func (s *CrossChain) New(secret string, bytea []byte, platform string) (a, p str
 		// This code is used to generate a master key from a given seed. The private variable is set to the result of the
 		// s.master() function, which takes 6 parameters as input - seed, 44, 195, 0, 0, 0. If an error occurs, the function
 		// returns the error and stops executing. Otherwise, the private variable is set to the result of the s.master() function.
-		private, err := s.master(seed, 44, 195, 0, 0, 0)
+		private, err := s.master(seed, 44, 195, 0, 0, index)
 		if err != nil {
 			return a, p, err
 		}
@@ -135,6 +146,15 @@ func (s *CrossChain) New(secret string, bytea []byte, platform string) (a, p str
 		privateKeyBytes := crypto.FromECDSA(private.ToECDSA())
 
 		return base58.Encode(append(bytes, replay[:4]...)), hexutil.Encode(privateKeyBytes), nil
+
+	case types.PlatformSolana:
+
+		// The keys of Solana are the ed25519 keys derived by SLIP-0010 with the path m/44'/501'/index'/0', the path of the
+		// wallets of Solana, the address is the public key in Base58 and the private key is the 64 bytes of the seed and of
+		// the public key, the form the transactions are signed with.
+		private := s.slip10(seed, 44, 501, index, 0)
+
+		return base58.Encode(private.Public().(ed25519.PublicKey)), hexutil.Encode(private), nil
 	}
 
 	return a, p, nil
@@ -208,6 +228,29 @@ func (s *CrossChain) master(seed []byte, paths ...uint32) (*btcec.PrivateKey, er
 	return (*btcec.PrivateKey)(btcecPrivKey.ToECDSA()), nil
 }
 
+// slip10 - This function derives the ed25519 private key from the seed with the path by SLIP-0010. The ed25519 keys have no public
+// derivation, every element of the path is hardened.
+func (s *CrossChain) slip10(seed []byte, paths ...uint32) ed25519.PrivateKey {
+
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	for _, path := range paths {
+
+		// The child key is the HMAC-SHA512 of the zero byte, the parent key and the hardened index, with the chain code of
+		// the parent as the key.
+		data := append([]byte{0x00}, sum[:32]...)
+		data = binary.BigEndian.AppendUint32(data, hdkeychain.HardenedKeyStart+path)
+
+		mac := hmac.New(sha512.New, sum[32:])
+		mac.Write(data)
+		sum = mac.Sum(nil)
+	}
+
+	return ed25519.NewKeyFromSeed(sum[:32])
+}
+
 // This function is used to generate a seed from entropy bytes, a user-defined secret, and a mnemonic phrase. The purpose
 // of this function is to generate a cryptographically secure seed that can be used to generate private keys, wallets,
 // and accounts. It takes in entropy bytes, which is used to generate a mnemonic phrase, and a user-defined secret that
@@ -0,0 +1,57 @@
+package keypair
+
+import (
+	"encoding/hex"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"testing"
+)
+
+func TestSlip10(t *testing.T) {
+
+	// The first test vector of SLIP-0010 for ed25519.
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	var cross CrossChain
+
+	tests := []struct {
+		name  string
+		paths []uint32
+		want  string
+	}{
+		{name: "m", want: "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+		{name: "m/0'", paths: []uint32{0}, want: "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+		{name: "m/0'/1'/2'/2'/1000000000'", paths: []uint32{0, 1, 2, 2, 1000000000}, want: "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(cross.slip10(seed, tt.paths...).Seed()); got != tt.want {
+				t.Errorf("slip10() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeriveSolana(t *testing.T) {
+
+	var cross CrossChain
+
+	entropy := make([]byte, 32)
+
+	first, private, err := cross.Derive("secret", entropy, types.PlatformSolana, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateCryptoAddress(first, types.PlatformSolana); err != nil {
+		t.Errorf("Derive() = %v, %v", first, err)
+	}
+
+	if len(private) != 2+128 {
+		t.Errorf("Derive() private key of %v characters", len(private))
+	}
+
+	second, _, err := cross.Derive("secret", entropy, types.PlatformSolana, 1)
+	if err != nil || second == first {
+		t.Errorf("Derive() of the index 1 = %v, %v", second, err)
+	}
+}
@@ -1,23 +1,34 @@
 package keypair
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/common"
 	"google.golang.org/grpc/status"
 	"regexp"
+	"strings"
 )
 
 // The purpose of these variables is to define regular expressions that can be used to validate Bitcoin, Tron, and
 // Ethereum addresses. These regular expressions will ensure that the addresses entered are valid, and that they meet the
 // correct address format for each cryptocurrency.
 var (
-	bitcoinRegex  = "^(bc1|[1b])[a-zA-HJ-NP-Z0-9]{25,39}$"
+	bitcoinRegex  = "^(bc1|[13])[a-zA-HJ-NP-Z0-9]{25,87}$"
 	tronRegex     = "^([T])[a-zA-HJ-NP-Z0-9]{33}$"
-	ethereumRegex = "^(0x)[a-zA-Z0-9]{40}$"
+	ethereumRegex = "^(0x)[a-fA-F0-9]{40}$"
+	solanaRegex   = "^[1-9A-HJ-NP-Za-km-z]{32,44}$"
 )
 
-// ValidateCryptoAddress - This function is used to validate a cryptocurrency address depending on the platform (Bitcoin, Ethereum, or Tron). It
+// ValidateCryptoAddress - This function is used to validate a cryptocurrency address depending on the platform (Bitcoin, Ethereum, Tron or Solana). It
 // checks to see if the address given matches the regular expression of the platform provided. If there is no match, it
-// returns an error.
+// returns an error. An address of another platform is reported with its own error, so that the users who picked the
+// wrong network see it, and the checksum of the address is verified: EIP-55 for the mixed case Ethereum addresses,
+// Base58Check for Tron and the legacy Bitcoin addresses, bech32 for the segwit Bitcoin addresses and the length of the
+// public key for Solana.
 func ValidateCryptoAddress(address string, platform string) error {
 	var regex string
 
@@ -32,6 +43,8 @@ func ValidateCryptoAddress(address string, platform string) error {
 		regex = tronRegex
 	case types.PlatformEthereum:
 		regex = ethereumRegex
+	case types.PlatformSolana:
+		regex = solanaRegex
 	default:
 		return status.Errorf(10789, "cryptocurrency not available: %s ", platform)
 	}
@@ -39,8 +52,97 @@ func ValidateCryptoAddress(address string, platform string) error {
 	// This code is checking if a given address matches a regular expression. If the address does not match the regular
 	// expression, it will return an error with a message indicating that the address is not correct.
 	if !regexp.MustCompile(regex).MatchString(address) {
+
+		// The address of another platform most often means that the user picked the wrong network of the withdrawal.
+		if detect := DetectPlatform(address); len(detect) > 0 && detect != platform {
+			return status.Errorf(90590, "the address %v belongs to the %s network, not to the %s network", address, detect, platform)
+		}
+
 		return status.Errorf(90589, "the %s address you provided is not correct, %v", platform, address)
 	}
 
+	if !checksum(address, platform) {
+
+		// The Base58 addresses of the other platforms match the format of the Solana addresses too, they are told by the
+		// length of the decoded address.
+		if detect := DetectPlatform(address); len(detect) > 0 && detect != platform {
+			return status.Errorf(90590, "the address %v belongs to the %s network, not to the %s network", address, detect, platform)
+		}
+
+		return status.Errorf(90591, "the checksum of the %s address %v is not correct, please check the address", platform, address)
+	}
+
 	return nil
 }
+
+// DetectPlatform - This function returns the platform whose address format the address matches, or an empty string when the
+// address matches none of them.
+func DetectPlatform(address string) string {
+
+	for _, platform := range []string{types.PlatformEthereum, types.PlatformTron, types.PlatformBitcoin, types.PlatformSolana} {
+
+		var regex string
+
+		switch platform {
+		case types.PlatformBitcoin:
+			regex = bitcoinRegex
+		case types.PlatformTron:
+			regex = tronRegex
+		case types.PlatformEthereum:
+			regex = ethereumRegex
+		case types.PlatformSolana:
+			regex = solanaRegex
+		}
+
+		// The format of the Solana addresses is loose, the address is also decoded to the public key.
+		if regexp.MustCompile(regex).MatchString(address) && (platform != types.PlatformSolana || checksum(address, platform)) {
+			return platform
+		}
+	}
+
+	return ""
+}
+
+// checksum - This function verifies the checksum of the address of the platform. The Ethereum addresses written in one case
+// carry no checksum, the mixed case addresses have to match the EIP-55 checksum. The Tron addresses are Base58Check
+// encoded with the 0x41 prefix, the Bitcoin addresses are decoded for the main network.
+func checksum(address, platform string) bool {
+
+	switch platform {
+	case types.PlatformEthereum:
+
+		hex := strings.TrimPrefix(address, "0x")
+		if hex == strings.ToLower(hex) || hex == strings.ToUpper(hex) {
+			return true
+		}
+
+		return common.HexToAddress(address).Hex() == address
+
+	case types.PlatformTron:
+
+		decode := base58.Decode(address)
+		if len(decode) != 25 || decode[0] != 0x41 {
+			return false
+		}
+
+		summary := sha256.Sum256(decode[:21])
+		replay := sha256.Sum256(summary[:])
+
+		return bytes.Equal(replay[:4], decode[21:])
+
+	case types.PlatformBitcoin:
+
+		if _, err := btcutil.DecodeAddress(address, &chaincfg.MainNetParams); err != nil {
+			return false
+		}
+
+		return true
+
+	case types.PlatformSolana:
+
+		// The Solana addresses are the ed25519 public keys in Base58, they carry no checksum.
+		return len(base58.Decode(address)) == 32
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package keypair
+
+import (
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestValidateCryptoAddress(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		address  string
+		platform string
+		code     uint32
+	}{
+		{name: "ethereum checksum", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", platform: types.PlatformEthereum},
+		{name: "ethereum lower case", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", platform: types.PlatformEthereum},
+		{name: "ethereum bad checksum", address: "0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", platform: types.PlatformEthereum, code: 90591},
+		{name: "ethereum not hex", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaez", platform: types.PlatformEthereum, code: 90589},
+		{name: "tron", address: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeL", platform: types.PlatformTron},
+		{name: "tron bad checksum", address: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeM", platform: types.PlatformTron, code: 90591},
+		{name: "tron address on ethereum", address: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeL", platform: types.PlatformEthereum, code: 90590},
+		{name: "ethereum address on tron", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", platform: types.PlatformTron, code: 90590},
+		{name: "bitcoin legacy", address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", platform: types.PlatformBitcoin},
+		{name: "bitcoin bech32", address: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", platform: types.PlatformBitcoin},
+		{name: "bitcoin bad bech32", address: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdw", platform: types.PlatformBitcoin, code: 90591},
+		{name: "solana", address: "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM", platform: types.PlatformSolana},
+		{name: "solana short key", address: "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGY", platform: types.PlatformSolana, code: 90591},
+		{name: "tron address on solana", address: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeL", platform: types.PlatformSolana, code: 90590},
+		{name: "solana address on ethereum", address: "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM", platform: types.PlatformEthereum, code: 90590},
+		{name: "unknown platform", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", platform: "ripple", code: 10789},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCryptoAddress(tt.address, tt.platform)
+			if tt.code == 0 && err != nil {
+				t.Errorf("ValidateCryptoAddress() error = %v, want nil", err)
+			}
+			if tt.code > 0 && uint32(status.Code(err)) != tt.code {
+				t.Errorf("ValidateCryptoAddress() error = %v, want code %v", err, tt.code)
+			}
+		})
+	}
+}
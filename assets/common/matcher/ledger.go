@@ -0,0 +1,183 @@
+package matcher
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+)
+
+// Position - The state of an order rebuilt from the journal of the book: the order with its remaining value, its side, whether
+// it is queued outside of the book until the session opens, whether it was cancelled, and its fills in the order they were
+// executed. The order with no remaining value which was not cancelled is filled.
+type Position struct {
+	Order
+	Buy               bool
+	Queued, Cancelled bool
+	Fills             []Fill
+}
+
+// Open - This function reports whether the order is still in the book or queued for it.
+func (p *Position) Open() bool {
+	return !p.Cancelled && p.Value > 0
+}
+
+// Ledger - The Ledger struct rebuilds the state of the orders by applying the commands of the journal of the book in the order they
+// were written: the placements, the releases of the queued orders, the cancellations, the adjustments of the corporate
+// actions and the matches. The matches are applied as they were executed, not matched again, so that the state is the same
+// whatever engine executed them, and every command is checked against the state, so that a journal which contradicts
+// itself, such as a fill of a cancelled order or a fill larger than the order, is reported at the command that breaks it.
+type Ledger struct {
+	positions map[int64]*Position
+}
+
+// NewLedger - This function returns the empty ledger, the commands are applied to it from the beginning of the journal.
+func NewLedger() *Ledger {
+	return &Ledger{positions: make(map[int64]*Position)}
+}
+
+// Place - This function applies the placement of the order, the queued orders wait for their release before they are matched.
+func (l *Ledger) Place(id int64, buy bool, price, value float64, queued bool) error {
+
+	if _, ok := l.positions[id]; ok {
+		return fmt.Errorf("the order %d is placed twice", id)
+	}
+
+	if price <= 0 || value <= 0 {
+		return fmt.Errorf("the order %d is placed with the price %v and the value %v", id, price, value)
+	}
+
+	l.positions[id] = &Position{Order: Order{Id: id, Price: price, Value: value}, Buy: buy, Queued: queued}
+
+	return nil
+}
+
+// Release - This function applies the release of the queued order into the book.
+func (l *Ledger) Release(id int64) error {
+
+	position, err := l.open(id)
+	if err != nil {
+		return err
+	}
+
+	if !position.Queued {
+		return fmt.Errorf("the order %d is released but it was not queued", id)
+	}
+	position.Queued = false
+
+	return nil
+}
+
+// Cancel - This function applies the cancellation of the order, the orders in the book and the queued orders can be cancelled.
+func (l *Ledger) Cancel(id int64) error {
+
+	position, err := l.open(id)
+	if err != nil {
+		return err
+	}
+	position.Cancelled = true
+
+	return nil
+}
+
+// Adjust - This function applies the new price and remaining value of the order set by a corporate action, such as a split of the
+// stock, the fills executed before keep their prices and values.
+func (l *Ledger) Adjust(id int64, price, value float64) error {
+
+	position, err := l.open(id)
+	if err != nil {
+		return err
+	}
+
+	if price <= 0 || value <= 0 {
+		return fmt.Errorf("the order %d is adjusted to the price %v and the value %v", id, price, value)
+	}
+	position.Price, position.Value = price, value
+
+	return nil
+}
+
+// Match - This function applies the execution of the incoming order against the resting order at the price and of the value they
+// were executed with. Both orders must be in the book and on the opposite sides, the value must be covered by both of them
+// and the price must be within the limits of both of them, the buy order pays at most its price and the sell order receives
+// at least its price.
+func (l *Ledger) Match(id, counter int64, price, value float64) error {
+
+	incoming, err := l.open(id)
+	if err != nil {
+		return err
+	}
+
+	resting, err := l.open(counter)
+	if err != nil {
+		return err
+	}
+
+	if incoming.Queued || resting.Queued {
+		return fmt.Errorf("the orders %d and %d are matched while one of them is queued", id, counter)
+	}
+
+	if incoming.Buy == resting.Buy {
+		return fmt.Errorf("the orders %d and %d are matched on the same side", id, counter)
+	}
+
+	if value <= 0 || (value > incoming.Value && !equal(value, incoming.Value)) || (value > resting.Value && !equal(value, resting.Value)) {
+		return fmt.Errorf("the orders %d and %d with the values %v and %v are matched with the value %v", id, counter, incoming.Value, resting.Value, value)
+	}
+
+	buy, sell := incoming, resting
+	if !incoming.Buy {
+		buy, sell = resting, incoming
+	}
+
+	if (price > buy.Price && !equal(price, buy.Price)) || (price < sell.Price && !equal(price, sell.Price)) {
+		return fmt.Errorf("the orders %d and %d with the prices %v and %v are matched at the price %v", buy.Id, sell.Id, buy.Price, sell.Price, price)
+	}
+
+	for _, position := range []*Position{incoming, resting} {
+		if position.Value = decimal.New(position.Value).Sub(value).Float(); equal(position.Value, 0) {
+			position.Value = 0
+		}
+	}
+
+	incoming.Fills = append(incoming.Fills, Fill{Id: counter, Price: price, Value: value})
+	resting.Fills = append(resting.Fills, Fill{Id: id, Price: price, Value: value})
+
+	return nil
+}
+
+// Position - This function returns the state of the order, the second value is false if the order was never placed.
+func (l *Ledger) Position(id int64) (*Position, bool) {
+	position, ok := l.positions[id]
+	return position, ok
+}
+
+// Positions - This function returns the states of all the orders of the ledger by the order of their ids.
+func (l *Ledger) Positions() []*Position {
+
+	positions := make([]*Position, 0, len(l.positions))
+	for _, position := range l.positions {
+		positions = append(positions, position)
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Id < positions[j].Id
+	})
+
+	return positions
+}
+
+// open - This function returns the state of the order which is still in the book or queued for it.
+func (l *Ledger) open(id int64) (*Position, error) {
+
+	position, ok := l.positions[id]
+	if !ok {
+		return nil, fmt.Errorf("the order %d was not placed", id)
+	}
+
+	if !position.Open() {
+		return nil, fmt.Errorf("the order %d is already closed", id)
+	}
+
+	return position, nil
+}
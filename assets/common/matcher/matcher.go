@@ -0,0 +1,102 @@
+package matcher
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+)
+
+// Order - The resting order of the book as seen by the matcher: the id of the order, its limit price and its remaining value.
+type Order struct {
+	Id    int64   `json:"id"`
+	Price float64 `json:"price"`
+	Value float64 `json:"value"`
+}
+
+// Fill - The execution of the incoming order against a resting order: the id of the resting order, the execution price and
+// the executed value.
+type Fill struct {
+	Id    int64   `json:"id"`
+	Price float64 `json:"price"`
+	Value float64 `json:"value"`
+}
+
+// Match - This function computes the fills of an incoming order against the book without writing anything, it is the candidate
+// matching engine which runs in shadow mode next to the live one. It starts as a pure implementation of the rules of the
+// live engine, the changes to the matching are made here first and compared with the live engine before they are shipped:
+// the resting orders are taken in the order they were placed, a buy order crosses the sell orders with a price at or above
+// its own and is executed at its own price, a sell order crosses the buy orders with a price at or below its own and is
+// executed at the price of the resting order.
+func Match(buy bool, price, value float64, book []Order) (fills []Fill) {
+
+	// The book is copied before sorting, so that the slice of the caller is not reordered.
+	orders := make([]Order, len(book))
+	copy(orders, book)
+
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].Id < orders[j].Id
+	})
+
+	for _, order := range orders {
+
+		if value <= 0 {
+			break
+		}
+
+		fill := Fill{Id: order.Id, Price: price, Value: math.Min(value, order.Value)}
+		switch {
+		case buy && price <= order.Price:
+		case !buy && price >= order.Price:
+			fill.Price = order.Price
+		default:
+			continue
+		}
+
+		value = decimal.New(value).Sub(fill.Value).Float()
+		fills = append(fills, fill)
+	}
+
+	return fills
+}
+
+// Diff - This function compares the fills of the live engine with the fills of the shadow engine and returns the description of
+// every divergence: a resting order matched by only one of the engines, or matched by both with a different price or value.
+// The fills are compared by the id of the resting order, the order in which they were executed is not taken into account.
+func Diff(live, shadow []Fill) (divergences []string) {
+
+	fills := make(map[int64]Fill, len(shadow))
+	for _, fill := range shadow {
+		fills[fill.Id] = fill
+	}
+
+	for _, fill := range live {
+
+		candidate, ok := fills[fill.Id]
+		if !ok {
+			divergences = append(divergences, fmt.Sprintf("order %v: filled %v at %v by the live engine only", fill.Id, fill.Value, fill.Price))
+			continue
+		}
+		delete(fills, fill.Id)
+
+		if !equal(fill.Price, candidate.Price) || !equal(fill.Value, candidate.Value) {
+			divergences = append(divergences, fmt.Sprintf("order %v: filled %v at %v by the live engine, %v at %v by the shadow engine", fill.Id, fill.Value, fill.Price, candidate.Value, candidate.Price))
+		}
+	}
+
+	// The remaining fills were computed by the shadow engine only, they are reported in the order of execution.
+	for _, fill := range shadow {
+		if _, ok := fills[fill.Id]; ok {
+			divergences = append(divergences, fmt.Sprintf("order %v: filled %v at %v by the shadow engine only", fill.Id, fill.Value, fill.Price))
+		}
+	}
+
+	return divergences
+}
+
+// equal - This function compares two amounts with a relative tolerance, so that the rounding of the floating point arithmetic
+// of the live engine is not reported as a divergence.
+func equal(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
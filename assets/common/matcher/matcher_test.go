@@ -0,0 +1,106 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+
+	book := []Order{
+		{Id: 1, Price: 102, Value: 1},
+		{Id: 2, Price: 100, Value: 2},
+		{Id: 3, Price: 100, Value: 1},
+		{Id: 4, Price: 105, Value: 3},
+	}
+
+	tests := []struct {
+		name  string
+		buy   bool
+		price float64
+		value float64
+		want  []Fill
+	}{
+		{name: "buy in time order", buy: true, price: 100, value: 3.5, want: []Fill{{Id: 1, Price: 100, Value: 1}, {Id: 2, Price: 100, Value: 2}, {Id: 3, Price: 100, Value: 0.5}}},
+		{name: "buy above the book", buy: true, price: 106, value: 1, want: nil},
+		{name: "sell at the resting price", buy: false, price: 101, value: 5, want: []Fill{{Id: 2, Price: 100, Value: 2}, {Id: 3, Price: 100, Value: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.buy, tt.price, tt.value, book); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if book[0].Id != 1 || book[3].Id != 4 {
+		t.Errorf("Match() reordered the book of the caller: %v", book)
+	}
+}
+
+func TestDiff(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		live   []Fill
+		shadow []Fill
+		want   int
+	}{
+		{name: "same fills", live: []Fill{{Id: 1, Price: 10, Value: 0.3}}, shadow: []Fill{{Id: 1, Price: 10, Value: 0.1 + 0.2}}, want: 0},
+		{name: "different value", live: []Fill{{Id: 1, Price: 10, Value: 1}}, shadow: []Fill{{Id: 1, Price: 10, Value: 2}}, want: 1},
+		{name: "different orders", live: []Fill{{Id: 1, Price: 10, Value: 1}}, shadow: []Fill{{Id: 2, Price: 9, Value: 1}}, want: 2},
+		{name: "no fills", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.live, tt.shadow); len(got) != tt.want {
+				t.Errorf("Diff() = %v, want %v divergences", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedger(t *testing.T) {
+
+	ledger := NewLedger()
+
+	steps := []struct {
+		name  string
+		apply func() error
+		fail  bool
+	}{
+		{name: "place the sell order", apply: func() error { return ledger.Place(1, false, 100, 2, false) }},
+		{name: "place the buy order", apply: func() error { return ledger.Place(2, true, 101, 1.5, false) }},
+		{name: "place the queued order", apply: func() error { return ledger.Place(3, true, 102, 1, true) }},
+		{name: "place the order twice", apply: func() error { return ledger.Place(1, false, 100, 2, false) }, fail: true},
+		{name: "match above the limit of the buyer", apply: func() error { return ledger.Match(2, 1, 102, 1) }, fail: true},
+		{name: "match more than the order", apply: func() error { return ledger.Match(2, 1, 100, 2) }, fail: true},
+		{name: "match the queued order", apply: func() error { return ledger.Match(3, 1, 100, 0.5) }, fail: true},
+		{name: "match at the resting price", apply: func() error { return ledger.Match(2, 1, 100, 1.5) }},
+		{name: "match the filled order", apply: func() error { return ledger.Match(2, 1, 100, 0.1) }, fail: true},
+		{name: "release the queued order", apply: func() error { return ledger.Release(3) }},
+		{name: "release the order twice", apply: func() error { return ledger.Release(3) }, fail: true},
+		{name: "adjust the order by a split", apply: func() error { return ledger.Adjust(3, 51, 2) }},
+		{name: "cancel the order", apply: func() error { return ledger.Cancel(1) }},
+		{name: "match the cancelled order", apply: func() error { return ledger.Match(3, 1, 100, 0.5) }, fail: true},
+		{name: "cancel the unknown order", apply: func() error { return ledger.Cancel(9) }, fail: true},
+	}
+	for _, step := range steps {
+		if err := step.apply(); (err != nil) != step.fail {
+			t.Fatalf("%v: error = %v, want the failure %v", step.name, err, step.fail)
+		}
+	}
+
+	want := []*Position{
+		{Order: Order{Id: 1, Price: 100, Value: 0.5}, Cancelled: true, Fills: []Fill{{Id: 2, Price: 100, Value: 1.5}}},
+		{Order: Order{Id: 2, Price: 101, Value: 0}, Buy: true, Fills: []Fill{{Id: 1, Price: 100, Value: 1.5}}},
+		{Order: Order{Id: 3, Price: 51, Value: 2}, Buy: true},
+	}
+	if got := ledger.Positions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Positions() = %+v, want %+v", got, want)
+	}
+
+	if position, _ := ledger.Position(2); position.Open() {
+		t.Errorf("Open() of the filled order = true, want false")
+	}
+}
@@ -0,0 +1,296 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Buckets - The default upper bounds of the buckets of the histograms of the latencies in seconds, from one millisecond to ten seconds.
+var Buckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector - The collector interface is implemented by the metrics of the registry, every metric writes its own samples in the
+// text format of prometheus.
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// registry - The registry holds the metrics of the process by their names, the metrics are written in the order of the names.
+var registry = struct {
+	mu      sync.Mutex
+	metrics map[string]collector
+}{metrics: make(map[string]collector)}
+
+// register - This function adds the metric to the registry, the names of the metrics are unique, a second metric with the same
+// name is a mistake of the program and panics at the start.
+func register(name string, metric collector) {
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: the metric %v is already registered", name))
+	}
+	registry.metrics[name] = metric
+}
+
+// Write - This function writes all the metrics of the registry in the text exposition format of prometheus, the format the
+// metrics endpoint is scraped in.
+func Write(w io.Writer) error {
+
+	registry.mu.Lock()
+	names := make([]string, 0, len(registry.metrics))
+	for name := range registry.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, registry.metrics[name])
+	}
+	registry.mu.Unlock()
+
+	buffer := bufio.NewWriter(w)
+	for _, metric := range metrics {
+		metric.write(buffer)
+	}
+
+	return buffer.Flush()
+}
+
+// vector - The vector struct holds the label names of a metric and the series of the metric by the values of the labels.
+type vector[T any] struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	series     map[string]*T
+	keys       map[string][]string
+}
+
+// get - This function returns the series of the label values, the series is created by the first observation of the values.
+// The number of the values must match the number of the labels of the metric.
+func (v *vector[T]) get(create func() *T, values ...string) *T {
+
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: the metric %v has %d labels, not %d", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	item, ok := v.series[key]
+	if !ok {
+		item = create()
+		v.series[key] = item
+		v.keys[key] = append([]string(nil), values...)
+	}
+
+	return item
+}
+
+// sorted - This function returns the keys of the series in order, so that the output does not change between the scrapes.
+func (v *vector[T]) sorted() []string {
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// pairs - This function returns the labels of the series in the text format, the extra pairs are appended after the labels of
+// the metric, such as the le label of the buckets.
+func (v *vector[T]) pairs(key string, extra ...string) string {
+
+	var (
+		pairs []string
+	)
+
+	for i, value := range v.keys[key] {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", v.labels[i], escape(value)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", extra[i], extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// header - This function writes the help and the type lines of the metric.
+func (v *vector[T]) header(w *bufio.Writer, _type string) {
+	_, _ = fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", v.name, strings.ReplaceAll(v.help, "\n", " "), v.name, _type)
+}
+
+// Counter - The Counter struct is a metric that only grows, such as the number of the handled requests, by the values of its labels.
+type Counter struct {
+	vector[float64]
+}
+
+// NewCounter - This function returns the counter with the name, the help text and the names of the labels, and adds it to the registry.
+func NewCounter(name, help string, labels ...string) *Counter {
+
+	counter := Counter{vector[float64]{name: name, help: help, labels: labels, series: make(map[string]*float64), keys: make(map[string][]string)}}
+	register(name, &counter)
+
+	return &counter
+}
+
+// Add - This function adds the value to the series of the label values, the negative values are ignored since a counter never decreases.
+func (c *Counter) Add(value float64, values ...string) {
+
+	if value < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	*c.get(func() *float64 { return new(float64) }, values...) += value
+}
+
+// Inc - This function adds one to the series of the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// write - This function writes the series of the counter.
+func (c *Counter) write(w *bufio.Writer) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+	for _, key := range c.sorted() {
+		_, _ = fmt.Fprintf(w, "%v%v %v\n", c.name, c.pairs(key), format(*c.series[key]))
+	}
+}
+
+// Gauge - The Gauge struct is a metric that is set to the current value, such as the number of the open connections.
+type Gauge struct {
+	vector[float64]
+}
+
+// NewGauge - This function returns the gauge with the name, the help text and the names of the labels, and adds it to the registry.
+func NewGauge(name, help string, labels ...string) *Gauge {
+
+	gauge := Gauge{vector[float64]{name: name, help: help, labels: labels, series: make(map[string]*float64), keys: make(map[string][]string)}}
+	register(name, &gauge)
+
+	return &gauge
+}
+
+// Set - This function sets the series of the label values to the value.
+func (g *Gauge) Set(value float64, values ...string) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	*g.get(func() *float64 { return new(float64) }, values...) = value
+}
+
+// Add - This function adds the value to the series of the label values, the negative values decrease the gauge.
+func (g *Gauge) Add(value float64, values ...string) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	*g.get(func() *float64 { return new(float64) }, values...) += value
+}
+
+// write - This function writes the series of the gauge.
+func (g *Gauge) write(w *bufio.Writer) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header(w, "gauge")
+	for _, key := range g.sorted() {
+		_, _ = fmt.Fprintf(w, "%v%v %v\n", g.name, g.pairs(key), format(*g.series[key]))
+	}
+}
+
+// distribution - The distribution struct holds the counts of the observations of one series of a histogram by the buckets.
+type distribution struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram - The Histogram struct is a metric that counts the observations by the buckets of their values, such as the
+// latencies of the requests, so that the quantiles can be estimated by the queries of prometheus.
+type Histogram struct {
+	vector[distribution]
+	buckets []float64
+}
+
+// NewHistogram - This function returns the histogram with the name, the help text, the upper bounds of the buckets in ascending
+// order and the names of the labels, and adds it to the registry. The bucket of the infinity is always added.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+
+	histogram := Histogram{vector: vector[distribution]{name: name, help: help, labels: labels, series: make(map[string]*distribution), keys: make(map[string][]string)}, buckets: append([]float64(nil), buckets...)}
+	sort.Float64s(histogram.buckets)
+	register(name, &histogram)
+
+	return &histogram
+}
+
+// Observe - This function counts the value in the series of the label values.
+func (h *Histogram) Observe(value float64, values ...string) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	item := h.get(func() *distribution { return &distribution{counts: make([]uint64, len(h.buckets))} }, values...)
+	for i, bound := range h.buckets {
+		if value <= bound {
+			item.counts[i]++
+		}
+	}
+	item.count++
+	item.sum += value
+}
+
+// write - This function writes the cumulative buckets, the sum and the count of the series of the histogram.
+func (h *Histogram) write(w *bufio.Writer) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+	for _, key := range h.sorted() {
+
+		item := h.series[key]
+		for i, bound := range h.buckets {
+			_, _ = fmt.Fprintf(w, "%v_bucket%v %v\n", h.name, h.pairs(key, "le", format(bound)), item.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%v_bucket%v %v\n", h.name, h.pairs(key, "le", "+Inf"), item.count)
+		_, _ = fmt.Fprintf(w, "%v_sum%v %v\n", h.name, h.pairs(key), format(item.sum))
+		_, _ = fmt.Fprintf(w, "%v_count%v %v\n", h.name, h.pairs(key), item.count)
+	}
+}
+
+// format - This function formats the value of a sample the way prometheus parses it.
+func format(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escape - This function escapes the backslashes, the quotes and the line breaks of the label values.
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+
+	counter := NewCounter("test_requests_total", "The number of the requests.", "method", "code")
+	counter.Inc("/pb.index.Api/GetServerTime", "OK")
+	counter.Add(2, "/pb.index.Api/GetServerTime", "OK")
+	counter.Inc("/pb.spot.Api/SetOrder", `bad "code"`)
+	counter.Add(-1, "/pb.spot.Api/SetOrder", `bad "code"`)
+
+	histogram := NewHistogram("test_latency_seconds", "The latency of the requests.", []float64{0.1, 0.01}, "method")
+	histogram.Observe(0.005, "get")
+	histogram.Observe(0.05, "get")
+	histogram.Observe(5, "get")
+
+	gauge := NewGauge("test_connections", "The number of the connections.")
+	gauge.Set(3)
+	gauge.Add(-1)
+
+	var buffer bytes.Buffer
+	if err := Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		`# HELP test_connections The number of the connections.`,
+		`# TYPE test_connections gauge`,
+		`test_connections 2`,
+		`# HELP test_latency_seconds The latency of the requests.`,
+		`# TYPE test_latency_seconds histogram`,
+		`test_latency_seconds_bucket{method="get",le="0.01"} 1`,
+		`test_latency_seconds_bucket{method="get",le="0.1"} 2`,
+		`test_latency_seconds_bucket{method="get",le="+Inf"} 3`,
+		`test_latency_seconds_sum{method="get"} 5.055`,
+		`test_latency_seconds_count{method="get"} 3`,
+		`# HELP test_requests_total The number of the requests.`,
+		`# TYPE test_requests_total counter`,
+		`test_requests_total{method="/pb.index.Api/GetServerTime",code="OK"} 3`,
+		`test_requests_total{method="/pb.spot.Api/SetOrder",code="bad \"code\""} 1`,
+	}, "\n") + "\n"
+
+	if buffer.String() != want {
+		t.Errorf("Write() = %s, want %s", buffer.String(), want)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Observe() with the wrong number of the labels must panic")
+			}
+		}()
+		histogram.Observe(1)
+	}()
+}
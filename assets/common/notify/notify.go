@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// client - The http client the messages are delivered with, the providers that do not answer in time are reported as failed,
+	// so that the next channel of the user is tried.
+	client = &http.Client{Timeout: 10 * time.Second}
+
+	// tags - The html tags of the texts of the notifications, they are removed from the texts of the channels without markup.
+	tags = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Channel - The Channel interface is a delivery channel of the notifications other than the email, such as the sms or a messenger
+// bot. The recipient is the address of the user in the channel: the phone number, the id of the chat and so on.
+type Channel interface {
+	Send(recipient, subject, text string) error
+}
+
+// Sms - The Sms struct is the gateway the sms are sent through, the api follows the one of Twilio: the message is posted as a form
+// to the messages of the account, with the account and the token as the basic authentication.
+type Sms struct {
+	Endpoint, Account, Token, Sender string
+}
+
+// Telegram - The Telegram struct is the bot the messages are sent by, the recipient is the id of the chat the user started with
+// the bot. The endpoint defaults to the api of Telegram.
+type Telegram struct {
+	Endpoint, Token string
+}
+
+// Plain - This function returns the text of the notification without the html markup, for the channels that show the text as it is.
+func Plain(text string) string {
+	return html.UnescapeString(tags.ReplaceAllString(text, ""))
+}
+
+// Send - This function sends the sms to the phone number, the subject is the beginning of the message, since the sms has no subject.
+func (s *Sms) Send(recipient, subject, text string) error {
+
+	form := url.Values{}
+	form.Set("To", recipient)
+	form.Set("From", s.Sender)
+	form.Set("Body", strings.TrimSpace(fmt.Sprintf("%v: %v", subject, Plain(text))))
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%v/Accounts/%v/Messages.json", strings.TrimSuffix(s.Endpoint, "/"), s.Account), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.Account, s.Token)
+
+	return deliver(req)
+}
+
+// Send - This function sends the message to the chat of the user, the subject is written in bold above the text, the markup of the
+// text is kept, since the bot api accepts the same html tags the notifications are written with.
+func (t *Telegram) Send(recipient, subject, text string) error {
+
+	endpoint := t.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "https://api.telegram.org"
+	}
+
+	serialize, err := json.Marshal(map[string]interface{}{
+		"chat_id":    recipient,
+		"text":       fmt.Sprintf("<b>%v</b>\n%v", html.EscapeString(subject), text),
+		"parse_mode": "HTML",
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%v/bot%v/sendMessage", strings.TrimSuffix(endpoint, "/"), t.Token), bytes.NewBuffer(serialize))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return deliver(req)
+}
+
+// deliver - This function makes the request to the provider, the answers other than success are returned as the errors together
+// with the beginning of the body, which carries the reason given by the provider.
+func deliver(req *http.Request) error {
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("the provider responded with the status %v: %v", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
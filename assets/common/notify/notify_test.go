@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPlain(t *testing.T) {
+	if text := Plain("Your secret code <b>123456</b>, do not give it to &quot;anyone&quot;"); text != `Your secret code 123456, do not give it to "anyone"` {
+		t.Fatalf("unexpected text %q", text)
+	}
+}
+
+func TestSms(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path != "/Accounts/AC1/Messages.json" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+
+		if account, token, ok := r.BasicAuth(); !ok || account != "AC1" || token != "secret" {
+			t.Errorf("unexpected authentication %v %v", account, token)
+		}
+
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+
+		if r.Form.Get("To") != "+10000000000" || r.Form.Get("From") != "Envoys" || r.Form.Get("Body") != "Secure code: Your code 123456" {
+			t.Errorf("unexpected form %v", r.Form)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sms := &Sms{Endpoint: server.URL, Account: "AC1", Token: "secret", Sender: "Envoys"}
+	if err := sms.Send("+10000000000", "Secure code", "Your code <b>123456</b>"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTelegram(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path != "/botTOKEN/sendMessage" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+
+		var (
+			message map[string]string
+		)
+
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Error(err)
+		}
+
+		if message["chat_id"] != "42" || message["text"] != "<b>Secure code</b>\nYour code <b>123456</b>" || message["parse_mode"] != "HTML" {
+			t.Errorf("unexpected message %v", message)
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"chat not found"}`))
+	}))
+	defer server.Close()
+
+	telegram := &Telegram{Endpoint: server.URL, Token: "TOKEN"}
+	if err := telegram.Send("42", "Secure code", "Your code <b>123456</b>"); err == nil {
+		t.Fatal("the failure of the provider is not reported")
+	}
+}
@@ -0,0 +1,95 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Builder - The Builder struct builds the conditions of the queries the same way for all the services: the conditions are written
+// by the code with the question marks in the place of the values, and the values are always bound as the parameters, so
+// that the symbols, the searches and the other values given by the callers never become a part of the text of the query.
+type Builder struct {
+	where  []string
+	params []interface{}
+}
+
+// NewBuilder - This function returns the builder of the conditions, the params are the parameters of the fixed part of the query,
+// such as $1 and $2, the placeholders of the conditions continue their numbering.
+func NewBuilder(params ...interface{}) *Builder {
+	return &Builder{params: params}
+}
+
+// Where - This function adds the condition to the conditions of the query, the question marks of the condition are replaced with
+// the placeholders of the parameters in their order.
+func (b *Builder) Where(condition string, params ...interface{}) {
+
+	for _, param := range params {
+		condition = strings.Replace(condition, "?", b.Bind(param), 1)
+	}
+
+	b.where = append(b.where, condition)
+}
+
+// Range - This function adds the date range of the column to the conditions. The dates are in the "2006-01-02" or the RFC3339
+// format, the day of the end date given without the time is included.
+func (b *Builder) Range(column, from, to string) error {
+
+	if len(from) > 0 {
+
+		start, _, err := pageDate(from)
+		if err != nil {
+			return err
+		}
+
+		b.Where(fmt.Sprintf("%s >= ?", column), start)
+	}
+
+	if len(to) > 0 {
+
+		end, day, err := pageDate(to)
+		if err != nil {
+			return err
+		}
+
+		if day {
+			end = end.AddDate(0, 0, 1)
+		}
+
+		b.Where(fmt.Sprintf("%s < ?", column), end)
+	}
+
+	return nil
+}
+
+// Bind - This function adds the value to the parameters and returns its placeholder, it is used for the values outside of the
+// conditions, such as the interval of the buckets or the limit of the rows.
+func (b *Builder) Bind(value interface{}) string {
+	b.params = append(b.params, value)
+	return fmt.Sprintf("$%d", len(b.params))
+}
+
+// Clause - This function returns the where clause of the conditions, or an empty string when there are no conditions.
+func (b *Builder) Clause() string {
+
+	if len(b.where) == 0 {
+		return ""
+	}
+
+	return "where " + strings.Join(b.where, " and ")
+}
+
+// And - This function returns the conditions joined to the where clause of the fixed part of the query, or an empty string when
+// there are no conditions.
+func (b *Builder) And() string {
+
+	if len(b.where) == 0 {
+		return ""
+	}
+
+	return "and " + strings.Join(b.where, " and ")
+}
+
+// Params - This function returns the parameters of the query, the parameters of the fixed part first.
+func (b *Builder) Params() []interface{} {
+	return b.params
+}
@@ -0,0 +1,72 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+
+	builder := NewBuilder(int64(7))
+	builder.Where("status = ?", true)
+	builder.Where("(base_unit = ? or quote_unit = ?)", "btc", "usdt")
+
+	if want := "and status = $2 and (base_unit = $3 or quote_unit = $4)"; builder.And() != want {
+		t.Errorf("And() = %v, want %v", builder.And(), want)
+	}
+
+	if want := "where status = $2 and (base_unit = $3 or quote_unit = $4)"; builder.Clause() != want {
+		t.Errorf("Clause() = %v, want %v", builder.Clause(), want)
+	}
+
+	if placeholder := builder.Bind(30); placeholder != "$5" {
+		t.Errorf("Bind() = %v, want $5", placeholder)
+	}
+
+	if want := []interface{}{int64(7), true, "btc", "usdt", 30}; !reflect.DeepEqual(builder.Params(), want) {
+		t.Errorf("Params() = %v, want %v", builder.Params(), want)
+	}
+
+	if empty := NewBuilder(); len(empty.Clause()) > 0 || len(empty.And()) > 0 {
+		t.Errorf("Clause() and And() without the conditions = %q, %q, want empty", empty.Clause(), empty.And())
+	}
+}
+
+// FuzzBuilder - The symbols of the callers must never change the text of the query, whatever they contain: the quotes, the
+// comments, the question marks of the placeholders or the dollar signs of the parameters.
+func FuzzBuilder(f *testing.F) {
+
+	for _, symbol := range []string{
+		"btc",
+		"",
+		"btc' or '1'='1",
+		"usdt'; drop table assets; --",
+		"eth/*",
+		"? or 1 = 1",
+		"$1",
+		`\' or true --`,
+		"%_",
+		"btc\x00",
+	} {
+		f.Add(symbol)
+	}
+
+	reference := NewBuilder(int64(1))
+	reference.Where("symbol = ?", "btc")
+	reference.Where("(symbol like ? or name like ?)", Like("btc"), Like("btc"))
+
+	f.Fuzz(func(t *testing.T, symbol string) {
+
+		builder := NewBuilder(int64(1))
+		builder.Where("symbol = ?", symbol)
+		builder.Where("(symbol like ? or name like ?)", Like(symbol), Like(symbol))
+
+		if builder.Clause() != reference.Clause() {
+			t.Fatalf("Clause() with the symbol %q = %v, want %v", symbol, builder.Clause(), reference.Clause())
+		}
+
+		if params := builder.Params(); len(params) != 4 || params[1] != symbol {
+			t.Fatalf("Params() with the symbol %q = %v", symbol, params)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package query
+
+import (
+	"time"
+)
+
+// Candles - This function returns the source the candles of the period are aggregated from and the columns of the candles: the
+// candles of the whole days are aggregated from the daily continuous aggregate, the candles of the whole hours from the
+// hourly one, and the shorter ones from the ticks themselves. Every source has the create_at, base_unit and quote_unit
+// columns, so the conditions of the queries are the same for all of them.
+func Candles(period time.Duration) (source, columns string) {
+
+	switch {
+	case period >= 24*time.Hour && period%(24*time.Hour) == 0:
+		source = "ohlcv_1d"
+	case period >= time.Hour && period%time.Hour == 0:
+		source = "ohlcv_1h"
+	default:
+		return "ohlcv", "first(o.price, o.create_at) as open, last(o.price, o.create_at) as close, first(o.price, o.price) as low, last(o.price, o.price) as high, sum(o.quantity) as volume, avg(o.price) as avg_price"
+	}
+
+	return source, "first(o.open, o.create_at) as open, last(o.close, o.create_at) as close, min(o.low) as low, max(o.high) as high, sum(o.volume) as volume, sum(o.total) / sum(o.count) as avg_price"
+}
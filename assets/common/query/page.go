@@ -0,0 +1,154 @@
+package query
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page - The Page struct builds the list queries of the services the same way: the filters are written with the bound
+// parameters, the rows are sorted by one of the allowed columns with the id as the tie breaker, and the next page is
+// read after the row of the cursor instead of an offset, so the pages do not shift while the new rows are added. The filters
+// are added with the Where function of the Builder.
+type Page struct {
+	Builder
+	limit, offset int64
+	sort, order   string
+	after         int64
+}
+
+// Identifier - The Identifier interface is implemented by the items of the lists, the cursor of the next page is the id of the
+// last item of the page.
+type Identifier interface {
+	GetId() int64
+}
+
+// NewPage - This function returns the page of the list. The limit is 30 by default and 100 at most, the sort is one of the
+// columns, the first of them by default, the order is "desc" by default or "asc", and the cursor is the one returned
+// with the previous page, the first page is read without it.
+func NewPage(limit int64, sort, order, cursor string, columns ...string) (*Page, error) {
+
+	page := Page{
+		limit: limit,
+		sort:  sort,
+		order: strings.ToLower(order),
+	}
+
+	if page.limit <= 0 {
+		page.limit = 30
+	}
+
+	if page.limit > 100 {
+		page.limit = 100
+	}
+
+	if len(page.sort) == 0 && len(columns) > 0 {
+		page.sort = columns[0]
+	}
+
+	if !help.IndexOf(columns, page.sort) {
+		return nil, status.Errorf(11715, "the list can not be sorted by %v, the columns are: %v", page.sort, strings.Join(columns, ", "))
+	}
+
+	switch page.order {
+	case "":
+		page.order = "desc"
+	case "asc", "desc":
+	default:
+		return nil, status.Errorf(11716, "the order of the list must be asc or desc, not %v", order)
+	}
+
+	if len(cursor) > 0 {
+
+		decode, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, status.Error(11717, "the cursor of the page is not correct")
+		}
+
+		if page.after, err = strconv.ParseInt(string(decode), 10, 64); err != nil || page.after <= 0 {
+			return nil, status.Error(11717, "the cursor of the page is not correct")
+		}
+	}
+
+	return &page, nil
+}
+
+// Offset - This function reads the page by its number instead of the cursor, it is kept for the clients that still page by the
+// numbers, the cursor takes precedence over it.
+func (p *Page) Offset(number int64) {
+	if number > 1 && p.after == 0 {
+		p.offset = p.limit * (number - 1)
+	}
+}
+
+// Filter - This function returns the where clause of the filters and their parameters, the counts of the lists are read with it.
+func (p *Page) Filter() (string, []interface{}) {
+	return p.Clause(), p.params
+}
+
+// Query - This function returns the query of the columns of the table read by the page and its parameters. One row more than the
+// limit is read, so that Cut knows whether there is a next page.
+func (p *Page) Query(columns, table string) (string, []interface{}) {
+
+	var (
+		where  = append([]string{}, p.where...)
+		params = append([]interface{}{}, p.params...)
+	)
+
+	// The rows after the cursor are the rows that follow the row of the cursor in the order of the sort column and the id.
+	if p.after > 0 {
+
+		compare := "<"
+		if p.order == "asc" {
+			compare = ">"
+		}
+
+		params = append(params, p.after)
+		where = append(where, fmt.Sprintf("(%[1]s, id) %[2]s (select %[1]s, id from %[3]s where id = $%[4]d)", p.sort, compare, table, len(params)))
+	}
+
+	clause := ""
+	if len(where) > 0 {
+		clause = "where " + strings.Join(where, " and ")
+	}
+
+	return fmt.Sprintf("select %s from %s %s order by %s %s, id %s limit %d offset %d", columns, table, clause, p.sort, p.order, p.order, p.limit+1, p.offset), params
+}
+
+// Cut - This function cuts the rows read by the query of the page to the limit, and returns the cursor of the next page, or an
+// empty cursor when the page is the last one.
+func Cut[T Identifier](p *Page, items []T) ([]T, string) {
+
+	if int64(len(items)) <= p.limit {
+		return items, ""
+	}
+
+	items = items[:p.limit]
+
+	return items, base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(items[len(items)-1].GetId(), 10)))
+}
+
+// pageDate - This function parses the date of the range, and reports whether it was given without the time.
+func pageDate(value string) (time.Time, bool, error) {
+
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return date, false, status.Errorf(11718, "the date %v must be in the 2006-01-02 or the RFC3339 format", value)
+	}
+
+	return date, false, nil
+}
+
+// Like - This function returns the pattern of the like condition that matches the value anywhere in the column, the wildcards of
+// the value are escaped, so that the percent and the underscore of the searched value are matched as they are.
+func Like(value string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value) + "%"
+}
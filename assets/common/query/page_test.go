@@ -0,0 +1,94 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type item struct {
+	id int64
+}
+
+func (i item) GetId() int64 {
+	return i.id
+}
+
+func TestPage(t *testing.T) {
+
+	page, err := NewPage(2, "", "", "", "id", "price")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page.Where("status = ?", "pending")
+	page.Where("(base_unit = ? or quote_unit = ?)", "btc", "btc")
+
+	if err := page.Range("create_at", "2023-07-01", "2023-07-02"); err != nil {
+		t.Fatal(err)
+	}
+
+	query, params := page.Query("id, price", "orders")
+	if want := "select id, price from orders where status = $1 and (base_unit = $2 or quote_unit = $3) and create_at >= $4 and create_at < $5 order by id desc, id desc limit 3 offset 0"; query != want {
+		t.Errorf("Query() = %v, want %v", query, want)
+	}
+
+	if want := []interface{}{"pending", "btc", "btc", time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)}; !reflect.DeepEqual(params, want) {
+		t.Errorf("Query() params = %v, want %v", params, want)
+	}
+
+	items, cursor := Cut(page, []item{{id: 9}, {id: 8}, {id: 7}})
+	if len(items) != 2 || len(cursor) == 0 {
+		t.Fatalf("Cut() = %v, %v", items, cursor)
+	}
+
+	if _, last := Cut(page, []item{{id: 9}}); len(last) > 0 {
+		t.Errorf("Cut() of the last page = %v, want no cursor", last)
+	}
+
+	next, err := NewPage(2, "price", "asc", cursor, "id", "price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.Where("status = ?", "pending")
+	next.Offset(5)
+
+	query, params = next.Query("id, price", "orders")
+	if want := "select id, price from orders where status = $1 and (price, id) > (select price, id from orders where id = $2) order by price asc, id asc limit 3 offset 0"; query != want {
+		t.Errorf("Query() = %v, want %v", query, want)
+	}
+
+	if want := []interface{}{"pending", int64(8)}; !reflect.DeepEqual(params, want) {
+		t.Errorf("Query() params = %v, want %v", params, want)
+	}
+
+	for _, tt := range []struct {
+		name, sort, order, cursor string
+	}{
+		{name: "sort", sort: "user_id"},
+		{name: "order", order: "up"},
+		{name: "cursor", cursor: "%%%"},
+	} {
+		if _, err := NewPage(0, tt.sort, tt.order, tt.cursor, "id", "price"); err == nil {
+			t.Errorf("NewPage() with a wrong %v must fail", tt.name)
+		}
+	}
+
+	if err := page.Range("create_at", "yesterday", ""); err == nil {
+		t.Errorf("Range() with a malformed date must fail")
+	}
+}
+
+func TestLike(t *testing.T) {
+	for _, tt := range []struct {
+		value, want string
+	}{
+		{value: "gmail", want: "%gmail%"},
+		{value: "100%_off", want: `%100\%\_off%`},
+		{value: `a\b`, want: `%a\\b%`},
+	} {
+		if got := Like(tt.value); got != tt.want {
+			t.Errorf("Like(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
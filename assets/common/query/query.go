@@ -9,6 +9,7 @@ import (
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
 	"github.com/cryptogateway/backend-envoys/server/types"
 	"github.com/disintegration/imaging"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/status"
 	"gopkg.in/gomail.v2"
@@ -36,6 +37,8 @@ const (
 type Query struct {
 	Id                                       int64
 	Email, Subject, Text, Name, Type, Symbol string
+	Phone, Telegram                          string
+	Channels                                 []string
 	Sample, Rules                            []byte
 	Buffer                                   bytes.Buffer
 }
@@ -226,12 +229,14 @@ func (m *Migrate) SendMail(userId int64, name string, params ...interface{}) {
 	var (
 		response Query
 		buffer   bytes.Buffer
+		locale   string
 	)
 
 	// This code is used to query a database for information related to a user with a given ID (userId). The code uses the
 	// Scan() method to assign the values returned from the query to response.Name, response.Sample, and response.Email. If
-	// there is an error, it is logged and the function returns.
-	if err := m.Context.Db.QueryRow("select name, sample, email from accounts where id = $1", userId).Scan(&response.Name, &response.Sample, &response.Email); m.Context.Debug(err) {
+	// there is an error, it is logged and the function returns. The locale of the account is the locale the notification is
+	// written in, the notification is written in the default locale when the user has not chosen one.
+	if err := m.Context.Db.QueryRow("select name, sample, email, phone, telegram, channels, locale from accounts where id = $1", userId).Scan(&response.Name, &response.Sample, &response.Email, &response.Phone, &response.Telegram, pq.Array(&response.Channels), &locale); m.Context.Debug(err) {
 		return
 	}
 
@@ -264,11 +269,55 @@ func (m *Migrate) SendMail(userId int64, name string, params ...interface{}) {
 			response.Symbol = strings.ToUpper(params[2].(string))
 		}
 
-		response.Text = fmt.Sprintf("Order ID: %d, Quantit: %v<b>%v</b>, Pair: <b>%v/%s</b>", params[0].(int64), params[1].(float64), response.Symbol, strings.ToUpper(params[2].(string)), strings.ToUpper(params[3].(string)))
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Order ID: %d, Quantit: %v<b>%v</b>, Pair: <b>%v/%s</b>", params[0].(int64), params[1].(float64), response.Symbol, strings.ToUpper(params[2].(string)), strings.ToUpper(params[3].(string)))
 		break
 	case "withdrawal":
 		response.Subject = "Withdrawal Successful"
-		response.Text = fmt.Sprintf("You've successfully withdrawn %v <b>%s</b>.", params[0].(float64), strings.ToUpper(params[1].(string)))
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "You've successfully withdrawn %v <b>%s</b>.", params[0].(float64), strings.ToUpper(params[1].(string)))
+		break
+	case "deposit_hold":
+		response.Subject = "Your deposit is under review"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Your deposit of %v <b>%s</b> has been confirmed and is held for a compliance review, it will be credited to your balance as soon as the review is completed.", params[0].(float64), strings.ToUpper(params[1].(string)))
+		break
+	case "deposit_release":
+		response.Subject = "Your deposit has been credited"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "The review of your deposit of %v <b>%s</b> is completed, the deposit has been credited to your balance.", params[0].(float64), strings.ToUpper(params[1].(string)))
+		break
+	case "deposit_return":
+		response.Subject = "Your deposit has been returned"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Your deposit of %v <b>%s</b> could not be accepted and is being returned to the sender address %s.", params[0].(float64), strings.ToUpper(params[1].(string)), params[2].(string))
+		break
+	case "export_ready":
+		response.Subject = "Your data export is ready"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "The archive of your personal data is ready, you can download it from your account within %v days.", params[0].(int))
+		break
+	case "closure_scheduled":
+		response.Subject = "Your account is scheduled for closure"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Your account will be closed on <b>%v</b>, you can cancel the closure from your account until then.", params[0].(string))
+		break
+	case "closure_cancel":
+		response.Subject = "The closure of your account is cancelled"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "The closure of your account has been cancelled: %v", params[0].(string))
+		break
+	case "signin_location":
+		response.Subject = "Sign in from a new location"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Your secret code <b>%v</b>, the sign in was requested from %v. Do not give the code to anyone, and change your password if it was not you.", params[0], params[1].(string))
+		break
+	case "signin_lock":
+		response.Subject = "The sign in to your account is locked"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "The sign in to your account is locked for %v minutes after too many failed attempts from the address %v. If it was not you, change your password and enable the two-factor authentication.", params[0].(int), params[1].(string))
+		break
+	case "restriction":
+		response.Subject = "Your account has been restricted"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Your account has been restricted (<b>%v</b>) for the reason <b>%v</b>%v. Please contact technical support for any questions.", params[0].(string), params[1].(string), params[2].(string))
+		break
+	case "restriction_lift":
+		response.Subject = "The restriction of your account has been lifted"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "The restriction of your account (<b>%v</b>) has been lifted.", params[0].(string))
+		break
+	case "ticket_reply":
+		response.Subject = "The support has answered your ticket"
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "The support has answered your ticket <b>#%v</b> (%v). Please sign in to read the answer and reply.", params[0].(int64), params[1].(string))
 		break
 	case "login":
 		response.Subject = "You just logged in Envoys"
@@ -278,14 +327,24 @@ func (m *Migrate) SendMail(userId int64, name string, params ...interface{}) {
 		break
 	case "secure":
 		response.Subject = "Secure code Envoys"
-		response.Text = fmt.Sprintf("Your secret code <b>%v</b>, do not give it to anyone", params[0].(string))
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Your secret code <b>%v</b>, do not give it to anyone", params[0].(string))
 		break
 	case "new_password":
 		response.Subject = "Reset password"
-		response.Text = fmt.Sprintf("Your new password <b>%v</b>", params[0].(string))
+		response.Text = m.Context.Locales.Translate(locale, "mail."+name+".text", "Your new password <b>%v</b>", params[0].(string))
 		break
 	}
 
+	// The subject of the notification is translated to the locale of the user, like its text, by the id "mail.<name>" of the
+	// catalogue.
+	response.Subject = m.Context.Locales.Translate(locale, "mail."+name, response.Subject)
+
+	// The notifications of the sandbox are watermarked, so that they are never taken for the notifications of the exchange.
+	if m.Context.Sandbox != nil {
+		response.Subject = fmt.Sprintf("%v %v", m.Context.Sandbox.Watermark, response.Subject)
+		response.Text = fmt.Sprintf("<b>%v</b> %v", m.Context.Sandbox.Watermark, response.Text)
+	}
+
 	// The code is likely part of a program that generates an HTML response to a client. The first line executes a template
 	// (likely an HTML file) and stores the resulting HTML in a buffer. The second line checks if an error has occurred
 	// while executing the template. If an error has occurred, the debug method is used to log the error and the program
@@ -297,37 +356,105 @@ func (m *Migrate) SendMail(userId int64, name string, params ...interface{}) {
 
 	// This if statement is checking if the response.Sample, name, "secure", and "new_password" parameters are comparable.
 	// If they are comparable, the statement will evaluate to true and the code inside the block will be executed. If not,
-	// the statement will evaluate to false and the code inside the block will not be executed.
-	if help.Comparable(response.Sample, name, "secure", "new_password") {
-
-		// The purpose of the line of code "g := gomail.NewMessage()" is to create a new instance of a gomail message, which is
-		// used to send emails. The "g" is a variable that holds the reference to the newly created message.
-		g := gomail.NewMessage()
-
-		// The purpose of this line of code is to set the "From" header in an email message. The m.Context.Smtp.Sender value is
-		// used to specify the sender of the email message.
-		g.SetHeader("From", m.Context.Smtp.Sender)
-
-		// The purpose of the following is to set the "To" header of an email to the response.Email address. This will ensure
-		// that the email is delivered to the intended recipient.
-		g.SetHeader("To", response.Email)
-
-		// The purpose of this code is to set the Subject header of an email message to the response subject of an email.
-		g.SetHeader("Subject", response.Subject)
-
-		// The purpose of g.SetBody("text/html", buffer.String()) is to set the response body of an HTTP request. The
-		// "text/html" argument is the content type and the buffer.String() argument is the data being sent in the response
-		// body. This allows the server to respond with an HTML page to the client.
-		g.SetBody("text/html", buffer.String())
-
-		// This code snippet is creating a new dialer and using it to dial and send an email using the gomail library. The
-		// dialer is initialized with the SMTP host, port, sender, and password from the m.Context object. If an error occurs
-		// while dialing and sending the email, the m.Context.Debug() method is used to log the error, then the function returns.
-		d := gomail.NewDialer(m.Context.Smtp.Host, m.Context.Smtp.Port, m.Context.Smtp.Sender, m.Context.Smtp.Password)
-		if err := d.DialAndSend(g); m.Context.Debug(err) {
-			return
-		}
+	// the statement will evaluate to false and the code inside the block will not be executed. The notices of the held
+	// deposits are sent whatever the subscriptions of the user are, like the secure codes.
+	if help.Comparable(response.Sample, name, "secure", "new_password", "deposit_hold", "deposit_release", "deposit_return") {
+
+		// The notification is delivered by the channels of the user in their order, the next channel is tried when the
+		// delivery by the previous one fails.
+		m.deliver(userId, name, &response, buffer.String())
 	}
 
 	return
 }
+
+// deliver - This function delivers the notification by the channels the user has chosen, in their order, and by the email after
+// them when the user has not chosen it. The first channel that delivers the notification ends the delivery, the channels
+// that are not configured or that the user has no address in are skipped. Every attempt is recorded with its status, so
+// that the user and the support can see how the security codes and the alerts were delivered.
+func (m *Migrate) deliver(userId int64, name string, response *Query, body string) {
+
+	var (
+		channels []string
+	)
+
+	for _, channel := range append(response.Channels, types.ChannelEmail) {
+		if !help.IndexOf(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+
+	for _, channel := range channels {
+
+		var (
+			err error
+		)
+
+		switch channel {
+		case types.ChannelSms:
+
+			if m.Context.Sms == nil || len(m.Context.Sms.Token) == 0 || len(response.Phone) == 0 {
+				m.writeDelivery(userId, name, channel, types.DeliverySkipped, "the channel is not configured")
+				continue
+			}
+			err = m.Context.Sms.Send(response.Phone, response.Subject, response.Text)
+
+		case types.ChannelTelegram:
+
+			if m.Context.Telegram == nil || len(m.Context.Telegram.Token) == 0 || len(response.Telegram) == 0 {
+				m.writeDelivery(userId, name, channel, types.DeliverySkipped, "the channel is not configured")
+				continue
+			}
+			err = m.Context.Telegram.Send(response.Telegram, response.Subject, response.Text)
+
+		case types.ChannelEmail:
+			err = m.sendMail(response, body)
+
+		default:
+			continue
+		}
+
+		if m.Context.Debug(err) {
+			m.writeDelivery(userId, name, channel, types.DeliveryFailed, err.Error())
+			continue
+		}
+
+		m.writeDelivery(userId, name, channel, types.DeliverySent, "")
+		return
+	}
+}
+
+// sendMail - This function sends the notification by email, the body is the html of the template of the notification.
+func (m *Migrate) sendMail(response *Query, body string) error {
+
+	// The purpose of the line of code "g := gomail.NewMessage()" is to create a new instance of a gomail message, which is
+	// used to send emails. The "g" is a variable that holds the reference to the newly created message.
+	g := gomail.NewMessage()
+
+	// The purpose of this line of code is to set the "From" header in an email message. The m.Context.Smtp.Sender value is
+	// used to specify the sender of the email message.
+	g.SetHeader("From", m.Context.Smtp.Sender)
+
+	// The purpose of the following is to set the "To" header of an email to the response.Email address. This will ensure
+	// that the email is delivered to the intended recipient.
+	g.SetHeader("To", response.Email)
+
+	// The purpose of this code is to set the Subject header of an email message to the response subject of an email.
+	g.SetHeader("Subject", response.Subject)
+
+	// The purpose of g.SetBody("text/html", body) is to set the body of the email to the html of the template.
+	g.SetBody("text/html", body)
+
+	// This code snippet is creating a new dialer and using it to dial and send an email using the gomail library. The
+	// dialer is initialized with the SMTP host, port, sender, and password from the m.Context object.
+	d := gomail.NewDialer(m.Context.Smtp.Host, m.Context.Smtp.Port, m.Context.Smtp.Sender, m.Context.Smtp.Password)
+
+	return d.DialAndSend(g)
+}
+
+// writeDelivery - This function records the attempt to deliver the notification by the channel, the errors are only logged, since
+// the record must not stop the delivery itself.
+func (m *Migrate) writeDelivery(userId int64, name, channel, status, reason string) {
+	_, err := m.Context.Db.Exec("insert into deliveries (user_id, name, channel, status, error) values ($1, $2, $3, $4, $5)", userId, name, channel, status, reason)
+	m.Context.Debug(err)
+}
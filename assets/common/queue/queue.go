@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"sync"
+)
+
+// Queue - The Queue struct runs the jobs of the keys, such as the messages of the topics of the broker, one after another in the
+// order they were pushed, each key by a worker of its own: the jobs of a key are held by a bounded lane, the push waits
+// while the lane of its key is full, and the worker of the key exits once its lane is empty, so that only the keys with
+// the jobs waiting hold a goroutine. The queue is drained by the Close function. The zero value is ready to use.
+type Queue struct {
+	mu     sync.Mutex
+	lanes  map[string]*lane
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// lane - The lane struct holds the jobs of a key that wait for its worker, and the number of the jobs pushed to it and not yet run.
+type lane struct {
+	jobs  chan func()
+	count int
+}
+
+// Push - This function pushes the job of the key, the job is run after the jobs pushed for the key before it. The lane of the key
+// holds at most the size of the jobs, the push waits while it is full. The job is not taken, and false is returned, when
+// the queue is closed, the caller runs it itself.
+func (q *Queue) Push(key string, size int, job func()) bool {
+
+	q.mu.Lock()
+
+	if q.closed {
+		q.mu.Unlock()
+		return false
+	}
+
+	if q.lanes == nil {
+		q.lanes = make(map[string]*lane)
+	}
+
+	item, ok := q.lanes[key]
+	if !ok {
+
+		if size <= 0 {
+			size = 1
+		}
+
+		item = &lane{jobs: make(chan func(), size)}
+		q.lanes[key] = item
+
+		q.wg.Add(1)
+		go q.work(key, item)
+	}
+
+	// The job is counted before it is sent, so that the worker does not exit while the job is on its way to the lane.
+	item.count++
+	q.mu.Unlock()
+
+	item.jobs <- job
+
+	return true
+}
+
+// Pending - This function returns the number of the jobs which were pushed and have not been run yet.
+func (q *Queue) Pending() (count int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, item := range q.lanes {
+		count += item.count
+	}
+
+	return count
+}
+
+// Close - This function closes the queue and waits until the jobs pushed before are run, the jobs pushed afterwards are not
+// taken by the queue.
+func (q *Queue) Close() {
+
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+// work - This function runs the jobs of the lane of the key one by one, and removes the lane once all the jobs pushed to it are run.
+func (q *Queue) work(key string, item *lane) {
+	defer q.wg.Done()
+
+	for job := range item.jobs {
+
+		job()
+
+		q.mu.Lock()
+		if item.count--; item.count == 0 {
+			delete(q.lanes, key)
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPush(t *testing.T) {
+
+	var (
+		queue Queue
+		mu    sync.Mutex
+		run   = make(map[string][]int)
+	)
+
+	push := func(key string, value int) {
+		if !queue.Push(key, 2, func() {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			run[key] = append(run[key], value)
+			mu.Unlock()
+		}) {
+			t.Fatalf("Push(%v, %v) = false, want true", key, value)
+		}
+	}
+
+	// The jobs of a key are run in the order they were pushed, the lane of two jobs makes the pushes wait for the worker.
+	for i := 0; i < 10; i++ {
+		push("exchange", i)
+		push("exchange/user/7", i)
+	}
+
+	// The closed queue runs the jobs pushed before and takes no more.
+	queue.Close()
+
+	if queue.Push("exchange", 2, func() {}) {
+		t.Errorf("Push() = true after Close(), want false")
+	}
+
+	if queue.Pending() != 0 {
+		t.Errorf("Pending() = %v, want 0", queue.Pending())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, key := range []string{"exchange", "exchange/user/7"} {
+		if len(run[key]) != 10 {
+			t.Fatalf("run[%v] = %v, want 10 jobs", key, run[key])
+		}
+		for i, value := range run[key] {
+			if value != i {
+				t.Errorf("run[%v] = %v, want the jobs in the order they were pushed", key, run[key])
+				break
+			}
+		}
+	}
+}
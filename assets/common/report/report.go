@@ -0,0 +1,118 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatCsv and FormatXml - The formats in which the reports are generated, the format is chosen by the schema of the regulator.
+	FormatCsv = "csv"
+	FormatXml = "xml"
+
+	// ActionNew and ActionCorrection - The actions of the records of a report, the first version of the report of a day is made of
+	// new records, and every resubmission of the same day replaces them with corrections.
+	ActionNew        = "NEWT"
+	ActionCorrection = "CORR"
+)
+
+// Field - The Field struct maps an internal field of a trade (the source) to the name of the field in the schema of the
+// regulator, the order of the fields in the schema is the order of the columns of the report.
+type Field struct {
+	Name, Source string
+}
+
+// Encode - This function encodes the records into a report of the given format. Each record maps the internal field names to their
+// values, the fields of the schema select and rename them, and a source that is missing in the record is written empty.
+func Encode(format string, fields []Field, records []map[string]string) ([]byte, error) {
+
+	var (
+		buffer bytes.Buffer
+	)
+
+	if len(fields) == 0 {
+		return nil, errors.New("the schema of the report has no fields")
+	}
+
+	switch format {
+	case FormatCsv:
+
+		// The first row of the csv report is the header with the names of the fields of the schema.
+		writer := csv.NewWriter(&buffer)
+
+		row := make([]string, len(fields))
+		for i := range fields {
+			row[i] = fields[i].Name
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+
+		for i := range records {
+			for j := range fields {
+				row[j] = records[i][fields[j].Source]
+			}
+			if err := writer.Write(row); err != nil {
+				return nil, err
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+
+	case FormatXml:
+
+		// The xml report is a list of the records, each field of the schema is a separate element of the record, the values
+		// are escaped by the encoder.
+		buffer.WriteString(xml.Header)
+
+		encoder := xml.NewEncoder(&buffer)
+		encoder.Indent("", "  ")
+
+		report := xml.StartElement{Name: xml.Name{Local: "Report"}}
+		if err := encoder.EncodeToken(report); err != nil {
+			return nil, err
+		}
+
+		for i := range records {
+
+			record := xml.StartElement{Name: xml.Name{Local: "Record"}}
+			if err := encoder.EncodeToken(record); err != nil {
+				return nil, err
+			}
+
+			for j := range fields {
+				if err := encoder.EncodeElement(records[i][fields[j].Source], xml.StartElement{Name: xml.Name{Local: fields[j].Name}}); err != nil {
+					return nil, err
+				}
+			}
+
+			if err := encoder.EncodeToken(record.End()); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := encoder.EncodeToken(report.End()); err != nil {
+			return nil, err
+		}
+		if err := encoder.Flush(); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.New("Invalid report format")
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Name - This function returns the name of the file of the report, the version is a part of the name, so that a correction
+// does not overwrite the report that has already been delivered to the regulator.
+func Name(jurisdiction, day string, version int32, format string) string {
+	return fmt.Sprintf("%s_%s_v%d.%s", jurisdiction, day, version, format)
+}
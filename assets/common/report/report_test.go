@@ -0,0 +1,52 @@
+package report
+
+import (
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+
+	fields := []Field{{Name: "TradeId", Source: "uid"}, {Name: "Price", Source: "price"}, {Name: "Venue", Source: "venue"}}
+	records := []map[string]string{{"uid": "a1", "price": "10.5"}, {"uid": "b<2>", "price": "1,5"}}
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: FormatCsv,
+			want:   "TradeId,Price,Venue\na1,10.5,\nb<2>,\"1,5\",\n",
+		},
+		{
+			name:   "xml",
+			format: FormatXml,
+			want:   "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Report>\n  <Record>\n    <TradeId>a1</TradeId>\n    <Price>10.5</Price>\n    <Venue></Venue>\n  </Record>\n  <Record>\n    <TradeId>b&lt;2&gt;</TradeId>\n    <Price>1,5</Price>\n    <Venue></Venue>\n  </Record>\n</Report>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Encode(tt.format, fields, records)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Encode("json", fields, records); err == nil {
+		t.Errorf("Encode() expected an error for an unknown format")
+	}
+	if _, err := Encode(FormatCsv, nil, records); err == nil {
+		t.Errorf("Encode() expected an error for an empty schema")
+	}
+}
+
+func TestName(t *testing.T) {
+	if got := Name("eu", "2023-05-01", 2, FormatXml); got != "eu_2023-05-01_v2.xml" {
+		t.Errorf("Name() = %v", got)
+	}
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"path"
+	"time"
+)
+
+// Sftp - The Sftp struct holds the connection details of the sftp server of the regulator, the path is the directory the
+// reports are uploaded to.
+type Sftp struct {
+	Host, User, Password, Path string
+}
+
+// Deliver - This function uploads the report to the sftp server of the regulator under the given name. The file is written
+// under a temporary name first and renamed when it is complete, so that the regulator never picks up a partial report.
+func (s *Sftp) Deliver(name string, data []byte) error {
+
+	// This code connects to the server with the password of the account, the host key is not pinned by the configuration.
+	client, err := ssh.Dial("tcp", s.Host, &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            []ssh.AuthMethod{ssh.Password(s.Password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := sftp.NewClient(client)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var (
+		temporary = path.Join(s.Path, name+".part")
+	)
+
+	file, err := session.Create(temporary)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// The complete file is moved to its final name, an existing file with the same name is replaced.
+	return session.PosixRename(temporary, path.Join(s.Path, name))
+}
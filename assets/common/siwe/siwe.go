@@ -0,0 +1,225 @@
+package siwe
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// header - The end of the first line of the message, the line starts with the domain the user signs in to.
+	header = " wants you to sign in with your Ethereum account:"
+
+	// Skew - The difference of the clocks of the wallet and of the server the times of the message are accepted within.
+	Skew = time.Minute
+)
+
+// Message - The Message struct is the message of the Sign-In with Ethereum (EIP-4361) the wallet signs: the domain and the uri
+// the user signs in to, the address of the account, the chain, the nonce issued by the server, the times the message
+// is valid within and the optional statement, the id of the request and the resources.
+type Message struct {
+	Domain, Address, Statement, URI, Version, Nonce, RequestID string
+	ChainID                                                    int64
+	IssuedAt, ExpirationTime, NotBefore                        time.Time
+	Resources                                                  []string
+}
+
+// Nonce - This function returns a new random nonce of the message, 32 hexadecimal characters of the random source of the system.
+func Nonce() (string, error) {
+
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buffer), nil
+}
+
+// Parse - This function parses the message the wallet signed. The first line is the domain, the second the address, the
+// statement is the line of its own between the address and the fields, and the resources are the lines of the list
+// after the field of the resources. The times are in RFC 3339, the optional fields are zero when they are absent.
+func Parse(message string) (*Message, error) {
+
+	var (
+		m         Message
+		resources bool
+		fields    bool
+	)
+
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 2 {
+		return nil, errors.New("the message is not a sign in with ethereum")
+	}
+
+	domain, ok := strings.CutSuffix(lines[0], header)
+	if !ok || len(domain) == 0 {
+		return nil, errors.New("the message is not a sign in with ethereum")
+	}
+	m.Domain, m.Address = domain, lines[1]
+
+	for _, line := range lines[2:] {
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if resources {
+			if resource, ok := strings.CutPrefix(line, "- "); ok {
+				m.Resources = append(m.Resources, resource)
+				continue
+			}
+			return nil, fmt.Errorf("the resource %q of the message is not correct", line)
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if line == "Resources:" {
+			key, ok = "Resources", true
+		}
+
+		if !ok || !field(key) {
+
+			// The statement is the only line that is not a field, it comes before the fields.
+			if fields || len(m.Statement) > 0 {
+				return nil, fmt.Errorf("the line %q of the message is not correct", line)
+			}
+			m.Statement = line
+
+			continue
+		}
+		fields = true
+
+		var (
+			err error
+		)
+
+		switch key {
+		case "URI":
+			m.URI = value
+		case "Version":
+			m.Version = value
+		case "Chain ID":
+			m.ChainID, err = strconv.ParseInt(value, 10, 64)
+		case "Nonce":
+			m.Nonce = value
+		case "Issued At":
+			m.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			m.ExpirationTime, err = time.Parse(time.RFC3339, value)
+		case "Not Before":
+			m.NotBefore, err = time.Parse(time.RFC3339, value)
+		case "Request ID":
+			m.RequestID = value
+		case "Resources":
+			resources = true
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("the field %v of the message is not correct: %v", key, err)
+		}
+	}
+
+	if len(m.URI) == 0 || len(m.Version) == 0 || m.ChainID == 0 || len(m.Nonce) == 0 || m.IssuedAt.IsZero() {
+		return nil, errors.New("the message misses the uri, the version, the chain id, the nonce or the time it was issued at")
+	}
+
+	return &m, nil
+}
+
+// field - This function reports whether the key is one of the fields of the message.
+func field(key string) bool {
+	switch key {
+	case "URI", "Version", "Chain ID", "Nonce", "Issued At", "Expiration Time", "Not Before", "Request ID", "Resources":
+		return true
+	}
+	return false
+}
+
+// Verify - This function checks that the message is signed for one of the domains at the time: the version is 1, the address is
+// in the checksum form of EIP-55, the nonce has at least 8 alphanumeric characters, the message was issued before the
+// time and is neither expired nor not valid yet, the times are compared within the skew of the clocks.
+func (m *Message) Verify(domains []string, now time.Time) error {
+
+	if m.Version != "1" {
+		return fmt.Errorf("the version %v of the message is not supported", m.Version)
+	}
+
+	if !common.IsHexAddress(m.Address) || common.HexToAddress(m.Address).Hex() != m.Address {
+		return errors.New("the address of the message is not in the checksum form")
+	}
+
+	if len(m.Nonce) < 8 || strings.IndexFunc(m.Nonce, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	}) >= 0 {
+		return errors.New("the nonce of the message is not correct")
+	}
+
+	allowed := false
+	for _, domain := range domains {
+		if strings.EqualFold(domain, m.Domain) {
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		return fmt.Errorf("the domain %v of the message is not allowed", m.Domain)
+	}
+
+	if m.IssuedAt.After(now.Add(Skew)) {
+		return errors.New("the message is issued in the future")
+	}
+
+	if !m.ExpirationTime.IsZero() && !now.Before(m.ExpirationTime.Add(Skew)) {
+		return errors.New("the message is expired")
+	}
+
+	if !m.NotBefore.IsZero() && now.Add(Skew).Before(m.NotBefore) {
+		return errors.New("the message is not valid yet")
+	}
+
+	return nil
+}
+
+// Hash - This function returns the hash the wallets sign the message with, the personal message of EIP-191: the keccak256 of the
+// prefix, the length of the message and the message.
+func Hash(message string) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%v", len(message), message)))
+}
+
+// Recover - This function returns the address in the checksum form that signed the message with the signature, the signature is
+// the hex of the 65 bytes r, s and v, with v of 27 or 28 as the wallets return it, or of 0 or 1.
+func Recover(message, signature string) (string, error) {
+
+	public, err := RecoverPublic(Hash(message), signature)
+	if err != nil {
+		return "", err
+	}
+
+	return crypto.PubkeyToAddress(*public).Hex(), nil
+}
+
+// RecoverPublic - This function returns the public key that signed the hash with the signature in the form of Recover, the
+// platforms that prefix the signed messages otherwise, such as Tron, recover the keys of their own hashes with it.
+func RecoverPublic(hash []byte, signature string) (*ecdsa.PublicKey, error) {
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return nil, errors.New("the signature is not correct")
+	}
+
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	if sig[crypto.RecoveryIDOffset] > 1 {
+		return nil, errors.New("the recovery id of the signature is not correct")
+	}
+
+	return crypto.SigToPub(hash, sig)
+}
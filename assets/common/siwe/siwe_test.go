@@ -0,0 +1,124 @@
+package siwe
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"testing"
+	"time"
+)
+
+var (
+	issued = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func message(address, statement string) string {
+
+	text := fmt.Sprintf("envoys.exchange wants you to sign in with your Ethereum account:\n%v\n\n", address)
+	if len(statement) > 0 {
+		text += statement + "\n"
+	}
+
+	return text + "\nURI: https://envoys.exchange\nVersion: 1\nChain ID: 1\nNonce: 5f2b8a9c0d1e3f47\nIssued At: 2026-10-01T12:00:00Z\nExpiration Time: 2026-10-01T12:10:00Z\nResources:\n- https://envoys.exchange/terms"
+}
+
+func TestParse(t *testing.T) {
+
+	m, err := Parse(message("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "Sign in to Envoys."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Domain != "envoys.exchange" || m.Address != "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed" || m.Statement != "Sign in to Envoys." {
+		t.Fatalf("unexpected header %+v", m)
+	}
+
+	if m.ChainID != 1 || m.Nonce != "5f2b8a9c0d1e3f47" || !m.IssuedAt.Equal(issued) || !m.ExpirationTime.Equal(issued.Add(10*time.Minute)) {
+		t.Fatalf("unexpected fields %+v", m)
+	}
+
+	if len(m.Resources) != 1 || m.Resources[0] != "https://envoys.exchange/terms" {
+		t.Fatalf("unexpected resources %v", m.Resources)
+	}
+
+	if m, err := Parse(message("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "")); err != nil || len(m.Statement) > 0 {
+		t.Fatalf("unexpected message without the statement %+v, %v", m, err)
+	}
+
+	if _, err := Parse("envoys.exchange wants you to sign in\n0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"); err == nil {
+		t.Fatal("expected the error of the header")
+	}
+}
+
+func TestVerify(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		address string
+		domains []string
+		now     time.Time
+		fail    bool
+	}{
+		{name: "valid", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", domains: []string{"envoys.exchange"}, now: issued.Add(time.Minute)},
+		{name: "other domain", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", domains: []string{"example.com"}, now: issued, fail: true},
+		{name: "expired", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", domains: []string{"envoys.exchange"}, now: issued.Add(time.Hour), fail: true},
+		{name: "issued in the future", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", domains: []string{"envoys.exchange"}, now: issued.Add(-time.Hour), fail: true},
+		{name: "lower case address", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", domains: []string{"envoys.exchange"}, now: issued, fail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			m, err := Parse(message(test.address, ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := m.Verify(test.domains, test.now); (err != nil) != test.fail {
+				t.Fatalf("expected the failure %v, got %v", test.fail, err)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	text := message(address, "Sign in to Envoys.")
+
+	sig, err := crypto.Sign(Hash(text), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+
+	if recovered, err := Recover(text, "0x"+hex.EncodeToString(sig)); err != nil || recovered != address {
+		t.Fatalf("expected %v, got %v, %v", address, recovered, err)
+	}
+
+	if recovered, err := Recover(text+" ", "0x"+hex.EncodeToString(sig)); err == nil && recovered == address {
+		t.Fatal("expected another address of the changed message")
+	}
+
+	if _, err := Recover(text, "0x1234"); err == nil {
+		t.Fatal("expected the error of the signature")
+	}
+}
+
+func TestNonce(t *testing.T) {
+
+	first, err := Nonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := Nonce()
+	if len(first) != 32 || first == second {
+		t.Fatalf("unexpected nonces %v and %v", first, second)
+	}
+}
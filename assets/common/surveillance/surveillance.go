@@ -0,0 +1,352 @@
+package surveillance
+
+import (
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Thresholds - The Thresholds struct holds the thresholds the patterns of the trading are flagged by: the window in minutes the
+// trading is analyzed over and the days the accounts signed in from the same address are linked for, the lifetime in
+// seconds of the orders cancelled without a fill that are counted as fleeting, the number of the price levels of the
+// fleeting orders from which the spoofing is the layering, the size of the fleeting orders as a multiple of the median
+// order of the pair, and the move of the price and the share of the buying of the few accounts the pump is flagged from.
+type Thresholds struct {
+	Window, Linkage, Lifetime, Layers int
+	Size, Move, Share                 float64
+}
+
+// Fill - The Fill struct is the match of the incoming order against the resting order: the incoming order and its user, the
+// resting order and its user, the side of the incoming order, the pair and the price and the value of the match.
+type Fill struct {
+	OrderId     int64     `json:"order_id"`
+	CounterId   int64     `json:"counter_id"`
+	UserId      int64     `json:"user_id"`
+	CounterUser int64     `json:"counter_user"`
+	Assigning   string    `json:"assigning"`
+	BaseUnit    string    `json:"base_unit"`
+	QuoteUnit   string    `json:"quote_unit"`
+	Price       float64   `json:"price"`
+	Value       float64   `json:"value"`
+	CreateAt    time.Time `json:"create_at"`
+}
+
+// Order - The Order struct is the order placed in the window: the quantity it was placed with and the part of it filled, and
+// the time it was cancelled at, the zero time for the orders that were not cancelled.
+type Order struct {
+	Id        int64     `json:"id"`
+	UserId    int64     `json:"user_id"`
+	Assigning string    `json:"assigning"`
+	BaseUnit  string    `json:"base_unit"`
+	QuoteUnit string    `json:"quote_unit"`
+	Price     float64   `json:"price"`
+	Quantity  float64   `json:"quantity"`
+	Filled    float64   `json:"filled"`
+	CreateAt  time.Time `json:"create_at"`
+	CancelAt  time.Time `json:"cancel_at"`
+}
+
+// Evidence - The Evidence struct is the snapshot of the trading the case was flagged from, kept with the case for the review of
+// the compliance officers: the matches and the orders, and for the pumps the prices and the share of the buying.
+type Evidence struct {
+	Fills  []Fill  `json:"fills,omitempty"`
+	Orders []Order `json:"orders,omitempty"`
+	Open   float64 `json:"open,omitempty"`
+	High   float64 `json:"high,omitempty"`
+	Share  float64 `json:"share,omitempty"`
+}
+
+// Case - The Case struct is the pattern flagged for the review: the kind of the pattern, the account it is flagged on and the
+// accounts related to it, the pair, the score the cases are ranked by and the evidence.
+type Case struct {
+	Kind      string
+	UserId    int64
+	Related   []int64
+	BaseUnit  string
+	QuoteUnit string
+	Score     float64
+	Evidence  Evidence
+}
+
+// Links - The Links type holds the accounts linked to each other, the accounts signed in from the same address.
+type Links map[int64]map[int64]bool
+
+// Link - This function links the two accounts to each other.
+func (l Links) Link(a, b int64) {
+	if a == b {
+		return
+	}
+	for _, pair := range [][2]int64{{a, b}, {b, a}} {
+		if l[pair[0]] == nil {
+			l[pair[0]] = make(map[int64]bool)
+		}
+		l[pair[0]][pair[1]] = true
+	}
+}
+
+// Linked - This function reports whether the two accounts are the same account or are linked to each other.
+func (l Links) Linked(a, b int64) bool {
+	return a == b || l[a][b]
+}
+
+// Key - This function returns the fingerprint of the case, the same pattern flagged again by the overlapping windows has the
+// same fingerprint and is written once: the kind, the pair, the accounts and the first match or order of the evidence.
+func (c *Case) Key() string {
+
+	users := append([]int64{c.UserId}, c.Related...)
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+
+	var first int64
+	switch {
+	case len(c.Evidence.Orders) > 0:
+		first = c.Evidence.Orders[0].Id
+	case len(c.Evidence.Fills) > 0:
+		first = c.Evidence.Fills[0].OrderId
+	}
+
+	return strings.Join([]string{c.Kind, c.BaseUnit, c.QuoteUnit, strings.Trim(fmt.Sprint(users), "[]"), fmt.Sprint(first)}, ":")
+}
+
+// Wash - This function flags the self-matching: the matches of the orders of the same account or of the linked accounts against
+// each other, which move no risk and only print the volume. The matches are grouped by the pair of the accounts and the
+// pair of the market, the score is the matched volume in the quote unit.
+func Wash(fills []Fill, links Links) (cases []*Case) {
+
+	var (
+		index = make(map[string]*Case)
+		order []string
+	)
+
+	for _, fill := range fills {
+
+		if !links.Linked(fill.UserId, fill.CounterUser) {
+			continue
+		}
+
+		a, b := fill.UserId, fill.CounterUser
+		if a > b {
+			a, b = b, a
+		}
+
+		key := fmt.Sprintf("%v:%v:%v:%v", a, b, fill.BaseUnit, fill.QuoteUnit)
+		item, ok := index[key]
+		if !ok {
+			item = &Case{Kind: types.SurveillanceWash, UserId: a, BaseUnit: fill.BaseUnit, QuoteUnit: fill.QuoteUnit}
+			if a != b {
+				item.Related = []int64{b}
+			}
+			index[key], order = item, append(order, key)
+		}
+
+		item.Score += fill.Price * fill.Value
+		item.Evidence.Fills = append(item.Evidence.Fills, fill)
+	}
+
+	for _, key := range order {
+		cases = append(cases, index[key])
+	}
+
+	return cases
+}
+
+// Spoof - This function flags the spoofing and the layering: the large orders cancelled within the lifetime without a fill while
+// the same account trades on the other side of the book, the orders that were never meant to be filled and only moved the
+// price for the trades of the account. The fleeting orders at as many price levels as the layers are the layering. The
+// score is the size of the fleeting orders as a multiple of the median order of the pair.
+func Spoof(orders []Order, fills []Fill, thresholds Thresholds) (cases []*Case) {
+
+	var (
+		sizes    = make(map[string][]float64)
+		fleeting = make(map[string][]Order)
+		order    []string
+		lifetime = time.Duration(thresholds.Lifetime) * time.Second
+	)
+
+	for _, item := range orders {
+		market := item.BaseUnit + ":" + item.QuoteUnit
+		sizes[market] = append(sizes[market], item.Quantity)
+	}
+
+	for _, item := range orders {
+
+		if item.CancelAt.IsZero() || item.Filled > 0 || item.CancelAt.Sub(item.CreateAt) > lifetime {
+			continue
+		}
+
+		if median := Median(sizes[item.BaseUnit+":"+item.QuoteUnit]); median <= 0 || item.Quantity < median*thresholds.Size {
+			continue
+		}
+
+		key := fmt.Sprintf("%v:%v:%v:%v", item.UserId, item.BaseUnit, item.QuoteUnit, item.Assigning)
+		if _, ok := fleeting[key]; !ok {
+			order = append(order, key)
+		}
+		fleeting[key] = append(fleeting[key], item)
+	}
+
+	for _, key := range order {
+
+		var (
+			items  = fleeting[key]
+			first  = items[0]
+			from   = first.CreateAt
+			to     = first.CancelAt
+			levels = make(map[float64]bool)
+			trades []Fill
+			size   float64
+		)
+
+		for _, item := range items {
+			if item.CreateAt.Before(from) {
+				from = item.CreateAt
+			}
+			if item.CancelAt.After(to) {
+				to = item.CancelAt
+			}
+			levels[item.Price] = true
+			size += item.Quantity
+		}
+
+		// The account is spoofing only when it trades on the other side of the book while the fleeting orders rest in it,
+		// or within the lifetime after they are cancelled.
+		for _, fill := range fills {
+			if fill.BaseUnit != first.BaseUnit || fill.QuoteUnit != first.QuoteUnit || fill.CreateAt.Before(from) || fill.CreateAt.After(to.Add(lifetime)) {
+				continue
+			}
+			if side, ok := Side(fill, first.UserId); ok && side != first.Assigning {
+				trades = append(trades, fill)
+			}
+		}
+
+		if len(trades) == 0 {
+			continue
+		}
+
+		item := &Case{Kind: types.SurveillanceSpoof, UserId: first.UserId, BaseUnit: first.BaseUnit, QuoteUnit: first.QuoteUnit, Evidence: Evidence{Fills: trades, Orders: items}}
+		if len(levels) >= thresholds.Layers && thresholds.Layers > 1 {
+			item.Kind = types.SurveillanceLayer
+		}
+		item.Score = size / Median(sizes[first.BaseUnit+":"+first.QuoteUnit])
+
+		cases = append(cases, item)
+	}
+
+	return cases
+}
+
+// Pump - This function flags the pumps: the price of the pair that rose by at least the move within the window while the few
+// accounts did most of the buying. The buying of the three largest buyers is their share of all the bought value, the
+// case is flagged on the largest buyer with the others related to it. The score is the move weighted by the share.
+func Pump(fills []Fill, thresholds Thresholds) (cases []*Case) {
+
+	var (
+		markets = make(map[string][]Fill)
+		order   []string
+	)
+
+	for _, fill := range fills {
+		market := fill.BaseUnit + ":" + fill.QuoteUnit
+		if _, ok := markets[market]; !ok {
+			order = append(order, market)
+		}
+		markets[market] = append(markets[market], fill)
+	}
+
+	for _, market := range order {
+
+		var (
+			items  = markets[market]
+			bought = make(map[int64]float64)
+			buyers []int64
+			total  float64
+			top    float64
+		)
+
+		sort.SliceStable(items, func(i, j int) bool { return items[i].CreateAt.Before(items[j].CreateAt) })
+
+		open, high := items[0].Price, items[0].Price
+		for _, fill := range items {
+			if fill.Price > high {
+				high = fill.Price
+			}
+
+			buyer := fill.UserId
+			if fill.Assigning != types.AssigningBuy {
+				buyer = fill.CounterUser
+			}
+			if _, ok := bought[buyer]; !ok {
+				buyers = append(buyers, buyer)
+			}
+			bought[buyer] += fill.Price * fill.Value
+			total += fill.Price * fill.Value
+		}
+
+		if open <= 0 || total <= 0 || (high-open)/open < thresholds.Move {
+			continue
+		}
+
+		sort.SliceStable(buyers, func(i, j int) bool { return bought[buyers[i]] > bought[buyers[j]] })
+		if len(buyers) > 3 {
+			buyers = buyers[:3]
+		}
+
+		for _, buyer := range buyers {
+			top += bought[buyer]
+		}
+
+		share := top / total
+		if share < thresholds.Share {
+			continue
+		}
+
+		cases = append(cases, &Case{
+			Kind:      types.SurveillancePump,
+			UserId:    buyers[0],
+			Related:   buyers[1:],
+			BaseUnit:  items[0].BaseUnit,
+			QuoteUnit: items[0].QuoteUnit,
+			Score:     (high - open) / open * share,
+			Evidence:  Evidence{Fills: items, Open: open, High: high, Share: share},
+		})
+	}
+
+	return cases
+}
+
+// Side - This function returns the side the account took in the match, the side of the incoming order for its account and
+// the other side for the account of the resting order, and false when the account took no part in the match.
+func Side(fill Fill, userId int64) (string, bool) {
+
+	opposite := types.AssigningSell
+	if fill.Assigning == types.AssigningSell {
+		opposite = types.AssigningBuy
+	}
+
+	switch userId {
+	case fill.UserId:
+		return fill.Assigning, true
+	case fill.CounterUser:
+		return opposite, true
+	}
+
+	return "", false
+}
+
+// Median - This function returns the median of the values, zero for no values.
+func Median(values []float64) float64 {
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	if middle := len(sorted) / 2; len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	} else {
+		return sorted[middle]
+	}
+}
@@ -0,0 +1,118 @@
+package surveillance
+
+import (
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"testing"
+	"time"
+)
+
+func TestWash(t *testing.T) {
+
+	var (
+		at    = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		links = make(Links)
+	)
+	links.Link(1, 2)
+
+	fills := []Fill{
+		{OrderId: 10, CounterId: 11, UserId: 1, CounterUser: 2, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 100, Value: 1, CreateAt: at},
+		{OrderId: 12, CounterId: 13, UserId: 2, CounterUser: 1, Assigning: types.AssigningSell, BaseUnit: "btc", QuoteUnit: "usdt", Price: 100, Value: 2, CreateAt: at},
+		{OrderId: 14, CounterId: 15, UserId: 3, CounterUser: 3, Assigning: types.AssigningBuy, BaseUnit: "eth", QuoteUnit: "usdt", Price: 10, Value: 1, CreateAt: at},
+		{OrderId: 16, CounterId: 17, UserId: 1, CounterUser: 4, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 100, Value: 5, CreateAt: at},
+	}
+
+	cases := Wash(fills, links)
+	if len(cases) != 2 {
+		t.Fatalf("Wash() = %v cases, want 2", len(cases))
+	}
+
+	if item := cases[0]; item.UserId != 1 || len(item.Related) != 1 || item.Related[0] != 2 || item.Score != 300 || len(item.Evidence.Fills) != 2 {
+		t.Errorf("Wash() linked case = %+v", item)
+	}
+
+	if item := cases[1]; item.UserId != 3 || len(item.Related) != 0 || item.Score != 10 {
+		t.Errorf("Wash() self-matching case = %+v", item)
+	}
+}
+
+func TestSpoof(t *testing.T) {
+
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	thresholds := Thresholds{Lifetime: 10, Layers: 3, Size: 5}
+
+	orders := []Order{
+		{Id: 20, UserId: 9, Assigning: types.AssigningSell, BaseUnit: "btc", QuoteUnit: "usdt", Price: 102, Quantity: 1, CreateAt: at},
+		{Id: 21, UserId: 8, Assigning: types.AssigningSell, BaseUnit: "btc", QuoteUnit: "usdt", Price: 103, Quantity: 2, CreateAt: at},
+		{Id: 22, UserId: 8, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 97, Quantity: 1, CreateAt: at},
+		{Id: 1, UserId: 9, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 100, Quantity: 1, CreateAt: at},
+		{Id: 2, UserId: 9, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 100, Quantity: 1, CreateAt: at},
+		{Id: 3, UserId: 8, Assigning: types.AssigningSell, BaseUnit: "btc", QuoteUnit: "usdt", Price: 101, Quantity: 1, CreateAt: at},
+		{Id: 4, UserId: 1, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 99, Quantity: 10, CreateAt: at, CancelAt: at.Add(2 * time.Second)},
+		{Id: 5, UserId: 1, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 98, Quantity: 10, CreateAt: at, CancelAt: at.Add(3 * time.Second)},
+		{Id: 6, UserId: 2, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 99, Quantity: 10, CreateAt: at, CancelAt: at.Add(time.Minute)},
+	}
+
+	tests := []struct {
+		name  string
+		fills []Fill
+		kind  string
+		want  int
+	}{
+		{name: "sold into the fleeting bids", fills: []Fill{{OrderId: 7, CounterId: 1, UserId: 1, CounterUser: 9, Assigning: types.AssigningSell, BaseUnit: "btc", QuoteUnit: "usdt", Price: 100, Value: 1, CreateAt: at.Add(time.Second)}}, kind: types.SurveillanceSpoof, want: 1},
+		{name: "bought on the side of the bids", fills: []Fill{{OrderId: 7, CounterId: 3, UserId: 1, CounterUser: 8, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 101, Value: 1, CreateAt: at.Add(time.Second)}}, want: 0},
+		{name: "sold long after the cancellation", fills: []Fill{{OrderId: 7, CounterId: 1, UserId: 1, CounterUser: 9, Assigning: types.AssigningSell, BaseUnit: "btc", QuoteUnit: "usdt", Price: 100, Value: 1, CreateAt: at.Add(time.Hour)}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cases := Spoof(orders, tt.fills, thresholds)
+			if len(cases) != tt.want {
+				t.Fatalf("Spoof() = %v cases, want %v", len(cases), tt.want)
+			}
+			if tt.want > 0 && (cases[0].Kind != tt.kind || cases[0].UserId != 1 || len(cases[0].Evidence.Orders) != 2) {
+				t.Errorf("Spoof() case = %+v", cases[0])
+			}
+		})
+	}
+
+	// The fleeting orders at as many price levels as the layers are the layering.
+	layered := append(orders, Order{Id: 8, UserId: 1, Assigning: types.AssigningBuy, BaseUnit: "btc", QuoteUnit: "usdt", Price: 97, Quantity: 10, CreateAt: at, CancelAt: at.Add(time.Second)})
+	if cases := Spoof(layered, tests[0].fills, thresholds); len(cases) != 1 || cases[0].Kind != types.SurveillanceLayer {
+		t.Errorf("Spoof() layering = %+v", cases)
+	}
+}
+
+func TestPump(t *testing.T) {
+
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	thresholds := Thresholds{Move: 0.1, Share: 0.7}
+
+	fills := []Fill{
+		{UserId: 1, CounterUser: 5, Assigning: types.AssigningBuy, BaseUnit: "abc", QuoteUnit: "usdt", Price: 10, Value: 10, CreateAt: at},
+		{UserId: 6, CounterUser: 1, Assigning: types.AssigningSell, BaseUnit: "abc", QuoteUnit: "usdt", Price: 11, Value: 10, CreateAt: at.Add(time.Minute)},
+		{UserId: 2, CounterUser: 5, Assigning: types.AssigningBuy, BaseUnit: "abc", QuoteUnit: "usdt", Price: 12, Value: 5, CreateAt: at.Add(2 * time.Minute)},
+		{UserId: 7, CounterUser: 5, Assigning: types.AssigningBuy, BaseUnit: "abc", QuoteUnit: "usdt", Price: 12, Value: 1, CreateAt: at.Add(3 * time.Minute)},
+		{UserId: 3, CounterUser: 4, Assigning: types.AssigningBuy, BaseUnit: "xyz", QuoteUnit: "usdt", Price: 10, Value: 1, CreateAt: at},
+		{UserId: 3, CounterUser: 4, Assigning: types.AssigningBuy, BaseUnit: "xyz", QuoteUnit: "usdt", Price: 10.5, Value: 1, CreateAt: at.Add(time.Minute)},
+	}
+
+	cases := Pump(fills, thresholds)
+	if len(cases) != 1 {
+		t.Fatalf("Pump() = %v cases, want 1", len(cases))
+	}
+
+	if item := cases[0]; item.UserId != 1 || item.BaseUnit != "abc" || len(item.Related) != 2 || item.Evidence.Open != 10 || item.Evidence.High != 12 {
+		t.Errorf("Pump() case = %+v", item)
+	}
+}
+
+func TestKey(t *testing.T) {
+
+	a := Case{Kind: types.SurveillanceWash, UserId: 2, Related: []int64{1}, BaseUnit: "btc", QuoteUnit: "usdt", Evidence: Evidence{Fills: []Fill{{OrderId: 10}}}}
+	b := Case{Kind: types.SurveillanceWash, UserId: 1, Related: []int64{2}, BaseUnit: "btc", QuoteUnit: "usdt", Evidence: Evidence{Fills: []Fill{{OrderId: 10}, {OrderId: 12}}}}
+
+	if a.Key() != b.Key() || a.Key() != "wash:btc:usdt:1 2:10" {
+		t.Errorf("Key() = %v and %v", a.Key(), b.Key())
+	}
+}
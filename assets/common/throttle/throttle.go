@@ -0,0 +1,88 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle - The Throttle struct coalesces the updates of the keys, such as the candles of a pair of an interval, into at most one
+// flush per key in the interval of the throttle: the first update of a key is held for the interval, the updates that
+// come in the meantime replace it, and the latest one is flushed when the interval runs out. The update of the new
+// window of the key, such as the candle that has just opened, is flushed at once, so that the closed window is never
+// held. The zero value is ready to use.
+type Throttle struct {
+	mu      sync.Mutex
+	pending map[string]*pending
+	windows map[string]int64
+}
+
+// pending - The pending struct holds the latest update of a key that waits for the interval of the throttle.
+type pending struct {
+	flush func()
+	timer *time.Timer
+}
+
+// Push - This function pushes the update of the key in the window, the flush function publishes the update. The update is
+// flushed at once when the interval is not positive or the window of the key has changed, otherwise it replaces the
+// pending update of the key, which is flushed when the interval runs out. The flush functions are called outside of the
+// lock, the flush of the interval on its own goroutine.
+func (t *Throttle) Push(key string, window int64, interval time.Duration, flush func()) {
+
+	t.mu.Lock()
+
+	if t.pending == nil {
+		t.pending, t.windows = make(map[string]*pending), make(map[string]int64)
+	}
+
+	// The new window closes the previous one, the pending update of the key is dropped, since the update of the new window
+	// is the later state of the key and is flushed at once.
+	if previous, ok := t.windows[key]; interval <= 0 || !ok || previous != window {
+
+		if item, ok := t.pending[key]; ok {
+			item.timer.Stop()
+			delete(t.pending, key)
+		}
+		t.windows[key] = window
+
+		t.mu.Unlock()
+		flush()
+
+		return
+	}
+
+	if item, ok := t.pending[key]; ok {
+		item.flush = flush
+		t.mu.Unlock()
+		return
+	}
+
+	item := &pending{flush: flush}
+	item.timer = time.AfterFunc(interval, func() { t.fire(key, item) })
+	t.pending[key] = item
+
+	t.mu.Unlock()
+}
+
+// Pending - This function returns the number of the keys whose updates wait for the interval of the throttle.
+func (t *Throttle) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.pending)
+}
+
+// fire - This function flushes the latest update of the key when the interval of the throttle runs out, unless the update has
+// been flushed by the new window of the key in the meantime.
+func (t *Throttle) fire(key string, item *pending) {
+
+	t.mu.Lock()
+	if t.pending[key] != item {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.pending, key)
+	flush := item.flush
+	t.mu.Unlock()
+
+	flush()
+}
@@ -0,0 +1,68 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPush(t *testing.T) {
+
+	var (
+		throttle Throttle
+		mu       sync.Mutex
+		flushed  []int
+	)
+
+	push := func(window int64, value int) {
+		throttle.Push("btc:usdt:60", window, 50*time.Millisecond, func() {
+			mu.Lock()
+			flushed = append(flushed, value)
+			mu.Unlock()
+		})
+	}
+
+	// The first update of the key is flushed at once, the next ones of the same window are coalesced into the latest one.
+	push(1, 1)
+	push(1, 2)
+	push(1, 3)
+
+	if throttle.Pending() != 1 {
+		t.Fatalf("Pending() = %v, want 1", throttle.Pending())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The update of the new window is flushed at once and drops the pending update of the closed window.
+	push(1, 4)
+	push(2, 5)
+
+	if throttle.Pending() != 0 {
+		t.Fatalf("Pending() = %v, want 0", throttle.Pending())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if want := []int{1, 3, 5}; len(flushed) != len(want) || flushed[0] != want[0] || flushed[1] != want[1] || flushed[2] != want[2] {
+		t.Errorf("flushed = %v, want %v", flushed, want)
+	}
+}
+
+func TestPushWithoutInterval(t *testing.T) {
+
+	var (
+		throttle Throttle
+		count    int
+	)
+
+	for i := 0; i < 3; i++ {
+		throttle.Push("btc:usdt:60", 1, 0, func() { count++ })
+	}
+
+	if count != 3 {
+		t.Errorf("count = %v, want 3", count)
+	}
+}
@@ -0,0 +1,244 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// batchSize - The number of the spans exported by one request, the batch is exported when it is full or by the interval.
+	batchSize = 512
+
+	// queueSize - The number of the spans waiting for the export, the spans beyond it are dropped while the collector is slow.
+	queueSize = 4096
+
+	// interval - The interval the spans are exported by when the batch is not full.
+	interval = 5 * time.Second
+)
+
+// Config - The Config struct is the configuration of the exporter: the otlp/http endpoint of the collector the spans are posted
+// to, such as "http://localhost:4318/v1/traces", the name of the service in the traces, the share of the traces that
+// are sampled, and the headers of the requests to the collector, such as the authorization of a hosted collector.
+type Config struct {
+	Endpoint, Service string
+	Ratio             float64
+	Headers           map[string]string
+}
+
+// Exporter - The Exporter struct collects the ended spans and posts them to the collector in batches, in the json encoding of
+// the otlp protocol.
+type Exporter struct {
+	config Config
+	client *http.Client
+	queue  chan *Span
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// exporter - The exporter of the process, nil when the tracing is not configured.
+var exporter atomic.Pointer[Exporter]
+
+// current - This function returns the exporter of the process.
+func current() *Exporter {
+	return exporter.Load()
+}
+
+// Setup - This function starts the exporter of the process with the configuration, the spans are started from then on. The
+// returned function stops the exporter and exports the spans that are still queued.
+func Setup(config Config) (stop func()) {
+
+	if len(config.Service) == 0 {
+		config.Service = "envoys"
+	}
+
+	e := Exporter{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan *Span, queueSize),
+		done:   make(chan struct{}),
+	}
+
+	e.wg.Add(1)
+	go e.run()
+
+	if previous := exporter.Swap(&e); previous != nil {
+		previous.stop()
+	}
+
+	return func() {
+		exporter.CompareAndSwap(&e, nil)
+		e.stop()
+	}
+}
+
+// add - This function queues the span for the export, the span is dropped if the queue is full.
+func (e *Exporter) add(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+// stop - This function stops the exporter after the queued spans are exported.
+func (e *Exporter) stop() {
+	close(e.done)
+	e.wg.Wait()
+}
+
+// run - This function exports the queued spans in batches, by the size of the batch or by the interval.
+func (e *Exporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		batch []*Span
+	)
+
+	flush := func() {
+		if len(batch) > 0 {
+			_ = e.export(batch)
+			batch = nil
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			if batch = append(batch, span); len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export - This function posts the spans to the collector in the json encoding of the otlp protocol.
+func (e *Exporter) export(spans []*Span) error {
+
+	serialize, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, e.config.Endpoint, bytes.NewReader(serialize))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range e.config.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("trace: the collector answered %v", response.Status)
+	}
+
+	return nil
+}
+
+// encode - This function returns the request of the otlp protocol with the spans of the service.
+func (e *Exporter) encode(spans []*Span) map[string]interface{} {
+
+	var (
+		items []map[string]interface{}
+	)
+
+	for _, span := range spans {
+
+		item := map[string]interface{}{
+			"traceId":           hex.EncodeToString(span.trace[:]),
+			"spanId":            hex.EncodeToString(span.id[:]),
+			"name":              span.name,
+			"kind":              int(span.kind),
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        attributes(span.attributes),
+		}
+
+		if span.parent != [8]byte{} {
+			item["parentSpanId"] = hex.EncodeToString(span.parent[:])
+		}
+
+		if len(span.err) > 0 {
+			item["status"] = map[string]interface{}{"code": 2, "message": span.err}
+		}
+
+		items = append(items, item)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": attributes([]Attribute{String("service.name", e.config.Service)}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "github.com/cryptogateway/backend-envoys"},
+						"spans": items,
+					},
+				},
+			},
+		},
+	}
+}
+
+// attributes - This function returns the attributes in the json encoding of the otlp protocol, the long values are cut, such as
+// the statements of the queries.
+func attributes(items []Attribute) []interface{} {
+
+	var (
+		values []interface{}
+	)
+
+	for _, item := range items {
+
+		var value map[string]interface{}
+		switch v := item.Value.(type) {
+		case string:
+			if len(v) > 1024 {
+				v = strings.ToValidUTF8(v[:1024], "")
+			}
+			value = map[string]interface{}{"stringValue": v}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+
+		values = append(values, map[string]interface{}{"key": item.Key, "value": value})
+	}
+
+	return values
+}
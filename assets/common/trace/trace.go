@@ -0,0 +1,168 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind - The kinds of the spans in the numbering of the otlp protocol: the spans of the requests served by the process, of the
+// requests the process sends, of the messages it publishes and of the work inside the process.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+	KindProducer Kind = 4
+)
+
+// Attribute - The Attribute struct is a key and a value describing a span, such as the statement of a query or the id of an order.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String - This function returns the attribute with the string value.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int - This function returns the attribute with the integer value.
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span - The Span struct is one operation of a trace: its trace, its own id and the id of the span it was started from, the time
+// of the operation and its result. The spans of the traces that are not sampled are only propagated, they are not exported.
+type Span struct {
+	trace      [16]byte
+	id, parent [8]byte
+	sampled    bool
+	name       string
+	kind       Kind
+	start, end time.Time
+	attributes []Attribute
+	err        string
+	once       sync.Once
+}
+
+// key - The key type is the key of the span in the context.
+type key struct{}
+
+// Start - This function starts the span of the operation as the child of the span of the context, or as the root of a new trace.
+// The spans are started only when the exporter is configured, otherwise the span is nil and its methods do nothing. The
+// returned context carries the span to the operations started by the operation.
+func Start(ctx context.Context, name string, kind Kind, attributes ...Attribute) (context.Context, *Span) {
+
+	exporter := current()
+	if exporter == nil {
+		return ctx, nil
+	}
+
+	span := Span{name: name, kind: kind, start: time.Now(), attributes: attributes}
+	if parent := FromContext(ctx); parent != nil {
+		span.trace, span.parent, span.sampled = parent.trace, parent.id, parent.sampled
+	} else {
+		_, _ = rand.Read(span.trace[:])
+		span.sampled = exporter.sample(span.trace)
+	}
+	_, _ = rand.Read(span.id[:])
+
+	return context.WithValue(ctx, key{}, &span), &span
+}
+
+// FromContext - This function returns the span of the context, nil if the context has no span.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(key{}).(*Span)
+	return span
+}
+
+// Set - This function adds the attributes to the span.
+func (s *Span) Set(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.attributes = append(s.attributes, attributes...)
+}
+
+// End - This function ends the span with the result of the operation and hands the sampled span to the exporter, the span is
+// ended once, the later calls do nothing.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.once.Do(func() {
+		s.end = time.Now()
+		if err != nil {
+			s.err = err.Error()
+		}
+
+		if exporter := current(); exporter != nil && s.sampled && s.name != "" {
+			exporter.add(s)
+		}
+	})
+}
+
+// Traceparent - This function returns the traceparent header of the w3c trace context of the span of the context, the header is
+// passed with the outgoing requests and the published messages, so that their spans join the trace. The context without a
+// span has no header.
+func Traceparent(ctx context.Context) string {
+
+	span := FromContext(ctx)
+	if span == nil {
+		return ""
+	}
+
+	flags := "00"
+	if span.sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%x-%x-%v", span.trace, span.id, flags)
+}
+
+// Extract - This function returns the context with the remote parent of the traceparent header, the spans started from the
+// context join the trace of the caller. The header that is not valid is ignored and the spans start a new trace.
+func Extract(ctx context.Context, traceparent string) context.Context {
+
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+
+	var (
+		remote Span
+	)
+
+	if _, err := hex.Decode(remote.trace[:], []byte(parts[1])); err != nil || remote.trace == [16]byte{} {
+		return ctx
+	}
+	if _, err := hex.Decode(remote.id[:], []byte(parts[2])); err != nil || remote.id == [8]byte{} {
+		return ctx
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return ctx
+	}
+	remote.sampled = flags[0]&1 == 1
+
+	// The remote span is never ended, it is only the parent of the spans of the process.
+	return context.WithValue(ctx, key{}, &remote)
+}
+
+// sample - This function decides if the new trace is sampled by the ratio of the exporter, by the trace id, so that the decision
+// is the same for the whole trace. The ratio of zero is not configured, all the traces are sampled then.
+func (e *Exporter) sample(trace [16]byte) bool {
+	if e.config.Ratio <= 0 || e.config.Ratio >= 1 {
+		return true
+	}
+	return float64(binary.BigEndian.Uint64(trace[8:])>>11)/float64(1<<53) < e.config.Ratio
+}
@@ -0,0 +1,118 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStartWithoutExporter(t *testing.T) {
+
+	ctx, span := Start(context.Background(), "order.place", KindInternal)
+	if span != nil {
+		t.Fatalf("the span is started without the exporter")
+	}
+
+	span.Set(String("symbol", "btc/usdt"))
+	span.End(errors.New("failed"))
+
+	if header := Traceparent(ctx); header != "" {
+		t.Fatalf("the traceparent is %q without a span", header)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+
+	stop := Setup(Config{Endpoint: "http://127.0.0.1:0/v1/traces"})
+	defer stop()
+
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	ctx, span := Start(Extract(context.Background(), header), "/pb.provider.Api/SetOrder", KindServer)
+	defer span.End(nil)
+
+	traceparent := Traceparent(ctx)
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || !strings.HasSuffix(traceparent, "-01") {
+		t.Fatalf("the traceparent %q does not continue the trace", traceparent)
+	}
+
+	if strings.Contains(traceparent, "00f067aa0ba902b7") {
+		t.Fatalf("the traceparent %q has the id of the parent", traceparent)
+	}
+
+	if _, span := Start(Extract(context.Background(), strings.TrimSuffix(header, "1")+"0"), "unsampled", KindServer); span.sampled {
+		t.Fatalf("the span of the unsampled trace is sampled")
+	}
+}
+
+func TestExtractInvalid(t *testing.T) {
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		if FromContext(Extract(context.Background(), header)) != nil {
+			t.Errorf("the header %q is extracted", header)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	stop := Setup(Config{Endpoint: server.URL + "/v1/traces", Service: "envoys-test"})
+
+	ctx, parent := Start(context.Background(), "order.place", KindInternal, String("symbol", "btc/usdt"))
+	_, child := Start(ctx, "db.exec", KindClient, Int("rows", 1))
+	child.End(errors.New("deadlock detected"))
+	parent.End(nil)
+
+	stop()
+
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceId, SpanId, ParentSpanId, Name string
+					Kind                                int
+					Status                              struct {
+						Code    int
+						Message string
+					}
+				}
+			}
+		}
+	}
+
+	if err := json.Unmarshal(<-bodies, &request); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("%v spans are exported, 2 are expected", len(spans))
+	}
+
+	if spans[0].Name != "db.exec" || spans[0].Kind != int(KindClient) || spans[0].Status.Code != 2 || spans[0].Status.Message != "deadlock detected" {
+		t.Errorf("the child span is exported as %+v", spans[0])
+	}
+
+	if spans[0].TraceId != spans[1].TraceId || spans[0].ParentSpanId != spans[1].SpanId || spans[1].ParentSpanId != "" {
+		t.Errorf("the spans are not of the same trace: %+v", spans)
+	}
+}
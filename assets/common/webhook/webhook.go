@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Alchemy - The provider whose address activity webhooks sign the body with the signing key of the webhook, in the
+	// X-Alchemy-Signature header.
+	Alchemy = "alchemy"
+
+	// QuickNode - The provider whose streams sign the nonce, the timestamp and the body with the security token of the stream, in
+	// the X-QN-Signature header.
+	QuickNode = "quicknode"
+
+	// Blockdaemon - The provider whose notifications sign the body with the secret of the webhook, in the X-Signature header.
+	Blockdaemon = "blockdaemon"
+)
+
+var (
+	// ErrSignature - The error of the event whose signature is missing or does not match the body, the event is not accepted.
+	ErrSignature = errors.New("the signature of the webhook does not match")
+)
+
+// Transfer - The transfer of a chain reported by the webhook of a provider, the same transfer the scanners read from the blocks.
+type Transfer = platform.Transfer
+
+// Supported - This function reports whether the webhooks of the provider are accepted.
+func Supported(provider string) bool {
+	switch provider {
+	case Alchemy, QuickNode, Blockdaemon:
+		return true
+	}
+	return false
+}
+
+// Verify - This function verifies the signature of the event of the provider: the HMAC-SHA256 of the signed content with the
+// secret of the webhook, in hex, compared in constant time. The signed content is the body, preceded by the nonce and
+// the timestamp of the headers for QuickNode.
+func Verify(provider, secret string, header http.Header, body []byte) error {
+
+	var (
+		signature string
+		content   = body
+	)
+
+	if len(secret) == 0 {
+		return ErrSignature
+	}
+
+	switch provider {
+	case Alchemy:
+		signature = header.Get("X-Alchemy-Signature")
+	case QuickNode:
+		signature = header.Get("X-QN-Signature")
+		content = append([]byte(header.Get("X-QN-Nonce")+header.Get("X-QN-Timestamp")), body...)
+	case Blockdaemon:
+		signature = header.Get("X-Signature")
+	default:
+		return fmt.Errorf("the webhooks of the provider %v are not supported", provider)
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(expected) == 0 {
+		return ErrSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(content)
+
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrSignature
+	}
+
+	return nil
+}
+
+// Parse - This function reads the transfers from the event of the provider. The address activity of Alchemy is read as it is
+// sent, the transfers of the coin and of the tokens only. QuickNode and Blockdaemon send what the filter of the stream
+// or of the notification returns, which is expected to be the transfers in the form of the object {"transfers": [{"hash",
+// "from", "to", "contract", "amount", "block"}]}, the amounts and the blocks in decimal or in hex.
+func Parse(provider string, body []byte) ([]*Transfer, error) {
+
+	var (
+		transfers []*Transfer
+	)
+
+	switch provider {
+	case Alchemy:
+
+		var (
+			event struct {
+				Event struct {
+					Activity []struct {
+						Category    string `json:"category"`
+						FromAddress string `json:"fromAddress"`
+						ToAddress   string `json:"toAddress"`
+						BlockNum    string `json:"blockNum"`
+						Hash        string `json:"hash"`
+						RawContract struct {
+							RawValue string `json:"rawValue"`
+							Address  string `json:"address"`
+						} `json:"rawContract"`
+					} `json:"activity"`
+				} `json:"event"`
+			}
+		)
+
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, err
+		}
+
+		for _, activity := range event.Event.Activity {
+
+			// The transfers of the nfts are not deposits, the internal transfers of the coin are not credited by the scanner either.
+			var contract string
+			switch activity.Category {
+			case "external":
+			case "token", "erc20":
+				contract = activity.RawContract.Address
+			default:
+				continue
+			}
+
+			transfer, err := transferOf(activity.Hash, activity.FromAddress, activity.ToAddress, contract, activity.RawContract.RawValue, activity.BlockNum)
+			if err != nil {
+				return nil, err
+			}
+			transfers = append(transfers, transfer)
+		}
+
+	case QuickNode, Blockdaemon:
+
+		var (
+			event struct {
+				Transfers []struct {
+					Hash     string  `json:"hash"`
+					From     string  `json:"from"`
+					To       string  `json:"to"`
+					Contract string  `json:"contract"`
+					Amount   numeric `json:"amount"`
+					Block    numeric `json:"block"`
+				} `json:"transfers"`
+			}
+		)
+
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, err
+		}
+
+		for _, item := range event.Transfers {
+			transfer, err := transferOf(item.Hash, item.From, item.To, item.Contract, string(item.Amount), string(item.Block))
+			if err != nil {
+				return nil, err
+			}
+			transfers = append(transfers, transfer)
+		}
+
+	default:
+		return nil, fmt.Errorf("the webhooks of the provider %v are not supported", provider)
+	}
+
+	return transfers, nil
+}
+
+// numeric - The numeric type is the amount or the block of the transfer of the filter, sent as a json number or as a string.
+type numeric string
+
+// UnmarshalJSON - This function reads the number or the string of the number as it is written.
+func (n *numeric) UnmarshalJSON(data []byte) error {
+
+	var (
+		value string
+	)
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return err
+		}
+		value = number.String()
+	}
+	*n = numeric(value)
+
+	return nil
+}
+
+// transferOf - This function returns the transfer of the event with the amount and the block read from their decimal or hex form.
+func transferOf(hash, from, to, contract, amount, block string) (*Transfer, error) {
+
+	if len(hash) == 0 || len(to) == 0 {
+		return nil, errors.New("the transfer of the webhook has no hash or no recipient")
+	}
+
+	value, ok := number(amount)
+	if !ok {
+		return nil, fmt.Errorf("the amount %v of the transfer %v is not a number", amount, hash)
+	}
+
+	height, ok := number(block)
+	if !ok || !height.IsInt64() {
+		return nil, fmt.Errorf("the block %v of the transfer %v is not a number", block, hash)
+	}
+
+	return &Transfer{Hash: hash, From: from, To: to, Contract: contract, Amount: value, Block: height.Int64()}, nil
+}
+
+// number - This function reads the non-negative integer in decimal or in hex with the 0x prefix.
+func number(src string) (*big.Int, bool) {
+
+	base := 10
+	if strings.HasPrefix(src, "0x") || strings.HasPrefix(src, "0X") {
+		src, base = src[2:], 16
+	}
+
+	if len(src) == 0 {
+		return nil, false
+	}
+
+	value, ok := new(big.Int).SetString(src, base)
+	if !ok || value.Sign() < 0 {
+		return nil, false
+	}
+
+	return value, true
+}
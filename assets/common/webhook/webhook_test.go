@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func sign(secret string, content []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+
+	body := []byte(`{"event":{}}`)
+
+	alchemy := http.Header{}
+	alchemy.Set("X-Alchemy-Signature", sign("key", body))
+
+	quicknode := http.Header{}
+	quicknode.Set("X-QN-Nonce", "n1")
+	quicknode.Set("X-QN-Timestamp", "1700000000")
+	quicknode.Set("X-QN-Signature", sign("token", append([]byte("n11700000000"), body...)))
+
+	tests := []struct {
+		name     string
+		provider string
+		secret   string
+		header   http.Header
+		want     error
+	}{
+		{name: "alchemy", provider: Alchemy, secret: "key", header: alchemy},
+		{name: "alchemy with the other key", provider: Alchemy, secret: "other", header: alchemy, want: ErrSignature},
+		{name: "quicknode", provider: QuickNode, secret: "token", header: quicknode},
+		{name: "quicknode without the nonce", provider: QuickNode, secret: "token", header: http.Header{"X-Qn-Signature": quicknode["X-Qn-Signature"]}, want: ErrSignature},
+		{name: "without the secret", provider: Alchemy, secret: "", header: alchemy, want: ErrSignature},
+		{name: "without the signature", provider: Blockdaemon, secret: "key", header: http.Header{}, want: ErrSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.provider, tt.secret, tt.header, body); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := Verify("unknown", "key", alchemy, body); err == nil {
+		t.Error("Verify() of the provider that is not supported did not fail")
+	}
+}
+
+func TestParse(t *testing.T) {
+
+	transfers, err := Parse(Alchemy, []byte(`{"type":"ADDRESS_ACTIVITY","event":{"network":"ETH_MAINNET","activity":[
+		{"category":"external","fromAddress":"0xa1","toAddress":"0xb2","blockNum":"0x10","hash":"0x01","rawContract":{"rawValue":"0xde0b6b3a7640000","decimals":18}},
+		{"category":"token","fromAddress":"0xa1","toAddress":"0xb3","blockNum":"0x11","hash":"0x02","rawContract":{"rawValue":"0xf4240","address":"0xc4","decimals":6}},
+		{"category":"erc721","fromAddress":"0xa1","toAddress":"0xb3","blockNum":"0x11","hash":"0x03","rawContract":{"rawValue":"0x","address":"0xc5"}}
+	]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transfers) != 2 {
+		t.Fatalf("Parse() = %v transfers, want 2", len(transfers))
+	}
+
+	if item := transfers[0]; item.Hash != "0x01" || item.To != "0xb2" || len(item.Contract) > 0 || item.Amount.String() != "1000000000000000000" || item.Block != 16 {
+		t.Errorf("Parse() coin = %+v", item)
+	}
+
+	if item := transfers[1]; item.Contract != "0xc4" || item.Amount.String() != "1000000" || item.Block != 17 {
+		t.Errorf("Parse() token = %+v", item)
+	}
+
+	transfers, err = Parse(QuickNode, []byte(`{"transfers":[{"hash":"0x04","from":"TA","to":"TB","contract":"","amount":1500000,"block":"0x20"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transfers) != 1 || transfers[0].Amount.Int64() != 1500000 || transfers[0].Block != 32 {
+		t.Errorf("Parse() stream = %+v", transfers)
+	}
+
+	if _, err := Parse(Blockdaemon, []byte(`{"transfers":[{"hash":"0x05","to":"TB","amount":"-1","block":1}]}`)); err == nil {
+		t.Error("Parse() of the negative amount did not fail")
+	}
+}
@@ -0,0 +1,549 @@
+package assets
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/geoip"
+	"github.com/cryptogateway/backend-envoys/assets/common/notify"
+	"github.com/cryptogateway/backend-envoys/assets/common/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// watchInterval - The interval the modification time of the configuration file is checked by, the reload is also triggered by SIGHUP.
+const watchInterval = 5 * time.Second
+
+// hypertables - The hypertables and the continuous aggregates the Partitions of the configuration can set the policies of, the
+// continuous aggregates have no chunks of their own to size and are not compressed, only their old buckets are dropped.
+var hypertables = map[string]bool{
+	"trades":           true,
+	"transfer":         true,
+	"wallet_transfers": true,
+	"ohlcv":            true,
+	"ohlcv_1h":         false,
+	"ohlcv_1d":         false,
+}
+
+// environment - The environment variables the secrets of the configuration are overridden with, so that the secrets are kept out
+// of the configuration file. The variables that are not set or are empty keep the values of the file.
+var environment = map[string]func(app *Context, value string){
+	"ENVOYS_POSTGRES_CONNECT": func(app *Context, value string) { app.PostgresConnect = value },
+	"ENVOYS_REPLICA_CONNECT":  func(app *Context, value string) { app.ReplicaConnect = value },
+	"ENVOYS_SECRETS":          func(app *Context, value string) { app.Secrets = strings.Split(value, ",") },
+	"ENVOYS_SMTP_PASSWORD": func(app *Context, value string) {
+		if app.Smtp == nil {
+			app.Smtp = new(Smtp)
+		}
+		app.Smtp.Password = value
+	},
+	"ENVOYS_REDIS_PASSWORD": func(app *Context, value string) {
+		if app.Redis == nil {
+			app.Redis = new(Redis)
+		}
+		app.Redis.Password = value
+	},
+	"ENVOYS_RABBITMQ_PASSWORD": func(app *Context, value string) {
+		if app.Rabbitmq == nil {
+			app.Rabbitmq = new(Rabbitmq)
+		}
+		app.Rabbitmq.Password = value
+	},
+	"ENVOYS_RABBITMQ_SECRET": func(app *Context, value string) {
+		if app.Rabbitmq == nil {
+			app.Rabbitmq = new(Rabbitmq)
+		}
+		app.Rabbitmq.Secret = value
+	},
+	"ENVOYS_KYC_API_KEY": func(app *Context, value string) {
+		if app.Kyc == nil {
+			app.Kyc = new(Kyc)
+		}
+		app.Kyc.ApiKey = value
+	},
+	"ENVOYS_SCREENING_TOKEN": func(app *Context, value string) {
+		if app.Screening == nil {
+			app.Screening = new(Screening)
+		}
+		app.Screening.Token = value
+	},
+	"ENVOYS_SMS_TOKEN": func(app *Context, value string) {
+		if app.Sms == nil {
+			app.Sms = new(notify.Sms)
+		}
+		app.Sms.Token = value
+	},
+	"ENVOYS_TELEGRAM_TOKEN": func(app *Context, value string) {
+		if app.Telegram == nil {
+			app.Telegram = new(notify.Telegram)
+		}
+		app.Telegram.Token = value
+	},
+	"ENVOYS_GEOIP_TOKEN": func(app *Context, value string) {
+		if app.Geo == nil {
+			app.Geo = new(geoip.Client)
+		}
+		app.Geo.Token = value
+	},
+	"ENVOYS_ENTROPY_KEYS": func(app *Context, value string) {
+		if app.Entropy == nil {
+			app.Entropy = new(Entropy)
+		}
+		app.Entropy.Keys = make(map[string]string)
+		for _, item := range strings.Split(value, ",") {
+			if id, key, ok := strings.Cut(item, ":"); ok {
+				app.Entropy.Keys[strings.TrimSpace(id)] = strings.TrimSpace(key)
+			}
+		}
+	},
+}
+
+// read - This function reads the configuration file into the context and overrides its secrets with the environment variables.
+// The errors name the field of the file that does not match the type of the setting.
+func (app *Context) read(path string) error {
+
+	serialize, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(serialize, app); err != nil {
+
+		var typed *json.UnmarshalTypeError
+		if errors.As(err, &typed) {
+			return fmt.Errorf("%v: the setting %v must be %v, not %v", path, typed.Field, typed.Type, typed.Value)
+		}
+
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return fmt.Errorf("%v: the file is not valid json at the offset %v: %v", path, syntax.Offset, err)
+		}
+
+		return fmt.Errorf("%v: %v", path, err)
+	}
+
+	app.override()
+
+	return nil
+}
+
+// override - This function overrides the secrets of the configuration with the environment variables that are set.
+func (app *Context) override() {
+
+	for name, apply := range environment {
+		if value := os.Getenv(name); len(value) > 0 {
+			apply(app, value)
+		}
+	}
+
+	// The tokens of the signers are overridden by the ids of their chains, such as ENVOYS_SIGNER_TOKEN_1.
+	for _, signer := range app.Signers {
+		if value := os.Getenv(fmt.Sprintf("ENVOYS_SIGNER_TOKEN_%d", signer.ChainId)); len(value) > 0 {
+			signer.Token = value
+		}
+	}
+
+	// The secrets of the webhooks are overridden by the ids of their chains, such as ENVOYS_WEBHOOK_SECRET_1.
+	for _, item := range app.Webhooks {
+		if value := os.Getenv(fmt.Sprintf("ENVOYS_WEBHOOK_SECRET_%d", item.ChainId)); len(value) > 0 {
+			item.Secret = value
+		}
+	}
+}
+
+// validate - This function checks the configuration before anything is connected, all the problems are reported at once, so
+// that the configuration can be fixed in one pass.
+func (app *Context) validate() error {
+
+	var (
+		problems []string
+	)
+
+	require := func(value, name string) {
+		if len(strings.TrimSpace(value)) == 0 {
+			problems = append(problems, fmt.Sprintf("%v is required", name))
+		}
+	}
+
+	require(app.PostgresConnect, "PostgresConnect")
+	if _, err := url.Parse(app.PostgresConnect); err != nil {
+		problems = append(problems, "PostgresConnect is not a valid connection url")
+	}
+	if _, err := url.Parse(app.ReplicaConnect); len(app.ReplicaConnect) > 0 && err != nil {
+		problems = append(problems, "ReplicaConnect is not a valid connection url")
+	}
+
+	if len(app.Secrets) == 0 {
+		problems = append(problems, "Secrets must have at least one secret")
+	}
+	for i, secret := range app.Secrets {
+		if len(secret) < 16 {
+			problems = append(problems, fmt.Sprintf("Secrets[%d] must be at least 16 characters long", i))
+		}
+	}
+
+	if _, err := time.LoadLocation(app.Timezones); err != nil {
+		problems = append(problems, fmt.Sprintf("Timezones %q is not a known time zone", app.Timezones))
+	}
+
+	if _, err := app.level(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	switch app.DecimalFormat {
+	case "", DecimalNumber, DecimalString, DecimalBoth:
+	default:
+		problems = append(problems, fmt.Sprintf("DecimalFormat must be %q, %q or %q", DecimalNumber, DecimalString, DecimalBoth))
+	}
+
+	if app.PushRetention < 0 || app.SupportSla < 0 || app.SupportStuck < 0 || app.CandleThrottle < 0 {
+		problems = append(problems, "PushRetention, SupportSla, SupportStuck and CandleThrottle must not be negative")
+	}
+
+	if app.ClosureCooling < 0 || app.ExportRetention < 0 {
+		problems = append(problems, "ClosureCooling and ExportRetention must not be negative")
+	}
+
+	if app.Server == nil {
+		problems = append(problems, "Server is required")
+	} else {
+		require(app.Server.Host, "Server.Host")
+		require(app.Server.Proxy, "Server.Proxy")
+	}
+
+	if app.Redis == nil {
+		problems = append(problems, "Redis is required")
+	} else {
+		require(app.Redis.Host, "Redis.Host")
+	}
+
+	if app.Rabbitmq == nil {
+		problems = append(problems, "Rabbitmq is required")
+	} else {
+		require(app.Rabbitmq.Host, "Rabbitmq.Host")
+		if len(app.Rabbitmq.Secret) > 0 && app.Rabbitmq.Lifetime <= 0 {
+			problems = append(problems, "Rabbitmq.Lifetime must be positive together with Rabbitmq.Secret")
+		}
+	}
+
+	if app.Credentials == nil {
+		problems = append(problems, "Credentials is required")
+	} else {
+		for name, file := range map[string]string{"Credentials.Crt": app.Credentials.Crt, "Credentials.Key": app.Credentials.Key} {
+			if _, err := os.Stat(file); err != nil {
+				problems = append(problems, fmt.Sprintf("%v %q can not be read", name, file))
+			}
+		}
+	}
+
+	if app.Kyc == nil {
+		problems = append(problems, "Kyc is required")
+	}
+
+	if app.Smtp != nil && len(app.Smtp.Host) > 0 && (app.Smtp.Port <= 0 || app.Smtp.Port > 65535) {
+		problems = append(problems, "Smtp.Port must be a valid port")
+	}
+
+	if app.Screening != nil && app.Screening.Score < 0 {
+		problems = append(problems, "Screening.Score must not be negative")
+	}
+
+	if app.Sms != nil && len(app.Sms.Token) > 0 && (len(app.Sms.Endpoint) == 0 || len(app.Sms.Account) == 0 || len(app.Sms.Sender) == 0) {
+		problems = append(problems, "Sms.Endpoint, Sms.Account and Sms.Sender must be set together with Sms.Token")
+	}
+
+	if app.Lockout != nil && (app.Lockout.Attempts < 0 || app.Lockout.Window < 0 || app.Lockout.Duration < 0) {
+		problems = append(problems, "Lockout.Attempts, Lockout.Window and Lockout.Duration must not be negative")
+	}
+
+	if app.Sources != nil && app.Sources.Multiplier < 1 {
+		problems = append(problems, "Sources.Multiplier must be at least 1")
+	}
+
+	if app.Sandbox != nil {
+
+		if len(app.Sandbox.Chains) == 0 || len(app.Sandbox.Faucet) == 0 || len(app.Sandbox.Watermark) == 0 || app.Sandbox.Interval < 0 {
+			problems = append(problems, "Sandbox.Chains, Sandbox.Faucet and Sandbox.Watermark are required and Sandbox.Interval must not be negative")
+		}
+
+		for _, chain := range app.Sandbox.Chains {
+			if chain.ChainId <= 0 || len(chain.Rpc) == 0 {
+				problems = append(problems, "Sandbox.Chains need the ChainId and the Rpc of the testnet")
+			}
+			if name, ok := Production(chain.Network, chain.Rpc); ok {
+				problems = append(problems, fmt.Sprintf("Sandbox.Chains must not connect the chain %v to the main network of %v", chain.ChainId, name))
+			}
+		}
+
+		for symbol, value := range app.Sandbox.Faucet {
+			if value <= 0 {
+				problems = append(problems, fmt.Sprintf("Sandbox.Faucet of %v must be greater than 0", symbol))
+			}
+		}
+	}
+
+	if app.Paper != nil {
+
+		if len(app.Paper.Balances) == 0 {
+			problems = append(problems, "Paper.Balances are required")
+		}
+
+		for symbol, value := range app.Paper.Balances {
+			if value < 0 {
+				problems = append(problems, fmt.Sprintf("Paper.Balances of %v must not be negative", symbol))
+			}
+		}
+	}
+
+	if app.Siwe != nil && len(app.Siwe.Domains) == 0 {
+		problems = append(problems, "Siwe.Domains are required")
+	}
+
+	if app.Surveillance != nil && (app.Surveillance.Window <= 0 || app.Surveillance.Linkage < 0 || app.Surveillance.Lifetime <= 0 || app.Surveillance.Size <= 0 || app.Surveillance.Move <= 0 || app.Surveillance.Share <= 0 || app.Surveillance.Share > 1) {
+		problems = append(problems, "Surveillance.Window, Surveillance.Lifetime, Surveillance.Size and Surveillance.Move must be positive, Surveillance.Linkage must not be negative and Surveillance.Share must be within (0, 1]")
+	}
+
+	if app.Retry != nil && (app.Retry.Attempts < 0 || app.Retry.Backoff < 0 || app.Retry.Timeout < 0 || app.Retry.Attempts > 16) {
+		problems = append(problems, "Retry.Attempts, Retry.Backoff and Retry.Timeout must not be negative and Retry.Attempts must be at most 16")
+	}
+
+	if app.Scanner != nil && (app.Scanner.Catchup < 0 || app.Scanner.Batch < 0 || app.Scanner.Rate < 0 || app.Scanner.Workers < 0) {
+		problems = append(problems, "Scanner.Catchup, Scanner.Batch, Scanner.Rate and Scanner.Workers must not be negative")
+	}
+
+	if app.Reconciliation != nil && (app.Reconciliation.Epsilon < 0 || app.Reconciliation.Drift < 0) {
+		problems = append(problems, "Reconciliation.Epsilon and Reconciliation.Drift must not be negative")
+	}
+
+	if app.Entropy != nil {
+		for id, key := range app.Entropy.Keys {
+			if decode, err := hex.DecodeString(key); err != nil || len(decode) != 32 {
+				problems = append(problems, fmt.Sprintf("Entropy.Keys[%v] must be a hex encoded 256 bit key", id))
+			}
+		}
+		if _, ok := app.Entropy.Keys[app.Entropy.Primary]; len(app.Entropy.Primary) > 0 && !ok {
+			problems = append(problems, fmt.Sprintf("Entropy.Primary %q is not one of Entropy.Keys", app.Entropy.Primary))
+		}
+	}
+
+	for i, signer := range app.Signers {
+		if signer.ChainId <= 0 {
+			problems = append(problems, fmt.Sprintf("Signers[%d].ChainId is required", i))
+		}
+		if _, err := url.ParseRequestURI(signer.Endpoint); len(signer.Endpoint) > 0 && err != nil {
+			problems = append(problems, fmt.Sprintf("Signers[%d].Endpoint is not a valid url", i))
+		}
+	}
+
+	for i, item := range app.Webhooks {
+		if item.ChainId <= 0 {
+			problems = append(problems, fmt.Sprintf("Webhooks[%d].ChainId is required", i))
+		}
+		if !webhook.Supported(item.Provider) {
+			problems = append(problems, fmt.Sprintf("Webhooks[%d].Provider must be alchemy, quicknode or blockdaemon", i))
+		}
+		if len(item.Secret) == 0 {
+			problems = append(problems, fmt.Sprintf("Webhooks[%d].Secret is required", i))
+		}
+	}
+
+	for i, item := range app.Gas {
+		if item.ChainId <= 0 || len(item.Reserve) == 0 {
+			problems = append(problems, fmt.Sprintf("Gas[%d].ChainId and Gas[%d].Reserve are required", i, i))
+		}
+		if item.Threshold <= 0 || item.Target <= item.Threshold || item.Alert < 0 {
+			problems = append(problems, fmt.Sprintf("Gas[%d].Threshold must be positive, Gas[%d].Target must be above it and Gas[%d].Alert must not be negative", i, i, i))
+		}
+	}
+
+	if app.Rebalance != nil && app.Rebalance.Delay < 0 {
+		problems = append(problems, "Rebalance.Delay must not be negative")
+	}
+
+	if app.Ramp != nil && (len(app.Ramp.Symbols) == 0 || app.Ramp.Slippage <= 0 || app.Ramp.Slippage >= 100) {
+		problems = append(problems, "Ramp.Symbols are required and Ramp.Slippage must be greater than 0 and less than 100")
+	}
+
+	if app.Pool != nil {
+		if app.Pool.MaxOpen < 0 || app.Pool.MaxIdle < 0 || app.Pool.Lifetime < 0 || app.Pool.IdleTime < 0 {
+			problems = append(problems, "Pool.MaxOpen, Pool.MaxIdle, Pool.Lifetime and Pool.IdleTime must not be negative")
+		}
+		if app.Pool.MaxOpen > 0 && app.Pool.MaxIdle > app.Pool.MaxOpen {
+			problems = append(problems, "Pool.MaxIdle must not be greater than Pool.MaxOpen")
+		}
+	}
+
+	for i, partition := range app.Partitions {
+		hypertable, ok := hypertables[partition.Table]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("Partitions[%d].Table %q is not a partitioned table", i, partition.Table))
+			continue
+		}
+		if partition.Chunk < 0 || partition.Compress < 0 || partition.Retain < 0 {
+			problems = append(problems, fmt.Sprintf("Partitions[%d].Chunk, Compress and Retain must not be negative", i))
+		}
+		if !hypertable && (partition.Chunk > 0 || partition.Compress > 0) {
+			problems = append(problems, fmt.Sprintf("Partitions[%d] of the continuous aggregate %q can only set Retain", i, partition.Table))
+		}
+		if partition.Compress > 0 && partition.Retain > 0 && partition.Compress >= partition.Retain {
+			problems = append(problems, fmt.Sprintf("Partitions[%d].Compress must be less than Retain", i))
+		}
+	}
+
+	if app.Tracing != nil && (app.Tracing.Ratio < 0 || app.Tracing.Ratio > 1) {
+		problems = append(problems, "Tracing.Ratio must be between 0 and 1")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the configuration is not valid:\n - %v", strings.Join(problems, "\n - "))
+	}
+
+	return nil
+}
+
+// level - This function returns the level of the logger: the configured level, or the debug level in the development and the
+// warning level otherwise.
+func (app *Context) level() (logrus.Level, error) {
+
+	if len(app.LogLevel) == 0 {
+		if app.Development {
+			return logrus.DebugLevel, nil
+		}
+		return logrus.WarnLevel, nil
+	}
+
+	level, err := logrus.ParseLevel(app.LogLevel)
+	if err != nil {
+		return level, fmt.Errorf("LogLevel %q is not a known level", app.LogLevel)
+	}
+
+	return level, nil
+}
+
+// Reload - This function reads the configuration again and applies the settings that can be changed while the process runs: the
+// level of the logger, the format of the decimals, the retention of the pushes, the support timers, the screening, the
+// thresholds of the reconciliation, the limits of the pool of the connections and the policies of the partitions. The
+// configuration that is not valid is not applied, the other settings, such as the connections and the secrets, are applied
+// by the restart of the process.
+func (app *Context) Reload() error {
+
+	var (
+		next Context
+	)
+
+	if err := next.read(app.config()); err != nil {
+		return err
+	}
+
+	if err := next.validate(); err != nil {
+		return err
+	}
+
+	level, _ := next.level()
+
+	app.Mutex.Lock()
+	defer app.Mutex.Unlock()
+
+	if next.PostgresConnect != app.PostgresConnect || next.ReplicaConnect != app.ReplicaConnect || *next.Server != *app.Server || *next.Redis != *app.Redis || *next.Rabbitmq != *app.Rabbitmq || strings.Join(next.Secrets, ",") != strings.Join(app.Secrets, ",") {
+		app.Logger.Warn("the connections and the secrets of the configuration were changed, they are applied by the restart of the process")
+	}
+
+	if (next.Sandbox == nil) != (app.Sandbox == nil) {
+		app.Logger.Warn("the sandbox mode of the configuration was changed, it is applied by the restart of the process")
+	}
+
+	app.LogLevel, app.Development = next.LogLevel, next.Development
+	app.Logger.SetLevel(level)
+
+	app.DecimalFormat = next.DecimalFormat
+	app.PushRetention = next.PushRetention
+	app.CandleThrottle = next.CandleThrottle
+	app.ClosureCooling, app.ExportRetention = next.ClosureCooling, next.ExportRetention
+	app.SupportSla, app.SupportStuck = next.SupportSla, next.SupportStuck
+	app.Screening = next.Screening
+	app.Sms, app.Telegram = next.Sms, next.Telegram
+	app.Geo, app.Lockout = next.Geo, next.Lockout
+	app.Siwe = next.Siwe
+	app.Surveillance = next.Surveillance
+	app.Reconciliation = next.Reconciliation
+	app.Retry = next.Retry
+	app.Scanner = next.Scanner
+	app.Gas = next.Gas
+	app.Rebalance = next.Rebalance
+	app.Ramp = next.Ramp
+	app.Sources = next.Sources
+	app.Paper = next.Paper
+
+	app.Pool = next.Pool
+	app.pool()
+
+	app.Partitions = next.Partitions
+
+	app.Logger.WithField("level", level).Info("the configuration was reloaded")
+
+	return nil
+}
+
+// Watch - This function reloads the configuration on SIGHUP and when the configuration file is modified, the errors of the reload
+// are logged and the previous settings stay in effect.
+func (app *Context) Watch() {
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	modified := app.modified()
+	for {
+		select {
+		case <-hangup:
+		case <-ticker.C:
+			if current := app.modified(); current.Equal(modified) {
+				continue
+			} else {
+				modified = current
+			}
+		}
+
+		if err := app.Reload(); err != nil {
+			app.Logger.WithField("config", app.config()).Error(err)
+		}
+	}
+}
+
+// Terminate - This function waits for SIGINT or SIGTERM, drains the events which wait for the broker by the Drain function, so
+// that none of them is lost with the process, and exits.
+func (app *Context) Terminate() {
+
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGINT, syscall.SIGTERM)
+
+	received := <-terminate
+	app.Logger.WithField("signal", received.String()).Info("the events waiting for the broker are drained before the exit")
+
+	app.Drain()
+	os.Exit(0)
+}
+
+// config - This function returns the path of the configuration file, the file that was removed while the process runs fails
+// the reload instead of the process.
+func (app *Context) config() string {
+	return fmt.Sprintf("%v/%v", app.StoragePath, "config.json")
+}
+
+// modified - This function returns the modification time of the configuration file, zero if the file can not be read.
+func (app *Context) modified() time.Time {
+	info, err := os.Stat(app.config())
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
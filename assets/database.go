@@ -0,0 +1,268 @@
+package assets
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/metrics"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
+)
+
+// queries - The metric of the duration of the queries of the database by their operations: the queries, the executions, the
+// preparations of the statements and the beginnings of the transactions.
+var queries = metrics.NewHistogram("db_query_duration_seconds", "The duration of the queries of the database.", metrics.Buckets, "operation")
+
+// connector - The connector struct opens the connections of the database through the connector of the driver, the connections
+// are wrapped so that the duration of their queries is observed, and the queries made with the context of a trace are
+// recorded as the spans of the trace.
+type connector struct {
+	driver.Connector
+}
+
+// Connect - This function opens the connection of the driver and wraps it.
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &observed{Conn: conn}, nil
+}
+
+// observed - The observed struct is a connection of the database that observes the duration of its queries. The methods the
+// connection of the driver does not implement fall back to the generic paths of the database/sql package.
+type observed struct {
+	driver.Conn
+}
+
+// QueryContext - This function runs the query on the connection of the driver, the rows are read after the query returns, the
+// duration is the time to the first row.
+func (c *observed) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer measure("query", time.Now())
+
+	span := start(ctx, "query", query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	span.End(err)
+
+	return rows, err
+}
+
+// ExecContext - This function runs the statement on the connection of the driver.
+func (c *observed) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer measure("exec", time.Now())
+
+	span := start(ctx, "exec", query)
+	result, err := execer.ExecContext(ctx, query, args)
+	span.End(err)
+
+	return result, err
+}
+
+// PrepareContext - This function prepares the statement on the connection of the driver, the statement is wrapped so that the
+// duration of its executions is observed as well.
+func (c *observed) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	defer measure("prepare", time.Now())
+
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &prepared{Stmt: stmt, query: query}, nil
+}
+
+// BeginTx - This function begins the transaction on the connection of the driver.
+func (c *observed) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	defer measure("begin", time.Now())
+
+	span := start(ctx, "begin", "begin")
+	defer span.End(nil)
+
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+// Ping - This function checks the connection of the driver, the connections of the drivers that can not be pinged are alive.
+func (c *observed) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// prepared - The prepared struct is a statement of the connection of the driver that observes the duration of its executions.
+type prepared struct {
+	driver.Stmt
+	query string
+}
+
+// QueryContext - This function runs the prepared query, the statements of the drivers without the context are run with the
+// plain values of the arguments.
+func (s *prepared) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer measure("query", time.Now())
+
+	var (
+		rows driver.Rows
+		err  error
+	)
+
+	span := start(ctx, "query", s.query)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else if values, ok := plain(args); ok {
+		rows, err = s.Stmt.Query(values)
+	} else {
+		err = errors.New("the driver does not support the named parameters")
+	}
+	span.End(err)
+
+	return rows, err
+}
+
+// ExecContext - This function runs the prepared statement, the statements of the drivers without the context are run with the
+// plain values of the arguments.
+func (s *prepared) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer measure("exec", time.Now())
+
+	var (
+		result driver.Result
+		err    error
+	)
+
+	span := start(ctx, "exec", s.query)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else if values, ok := plain(args); ok {
+		result, err = s.Stmt.Exec(values)
+	} else {
+		err = errors.New("the driver does not support the named parameters")
+	}
+	span.End(err)
+
+	return result, err
+}
+
+// plain - This function returns the values of the arguments, false if any of the arguments is named.
+func plain(args []driver.NamedValue) ([]driver.Value, bool) {
+
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if len(arg.Name) > 0 {
+			return nil, false
+		}
+		values[i] = arg.Value
+	}
+
+	return values, true
+}
+
+// Statement - This function returns the prepared statement of the query, the statements of the hot paths, such as the orders, the
+// prices and the trades, are prepared once and reused by all the calls instead of being parsed by the database every time.
+// The statements are prepared again by the database/sql package on the connections they were not prepared on yet.
+func (app *Context) Statement(query string) (*sql.Stmt, error) {
+
+	if stmt, ok := app.statements.Load(query); ok {
+		return stmt.(*sql.Stmt), nil
+	}
+
+	stmt, err := app.Db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The statement prepared by a concurrent call at the same time is kept, the duplicate is closed.
+	if actual, loaded := app.statements.LoadOrStore(query, stmt); loaded {
+		_ = stmt.Close()
+		return actual.(*sql.Stmt), nil
+	}
+
+	return stmt, nil
+}
+
+// Reader - This function returns the handle of the database the reads that tolerate the lag of the replication are made with: the
+// candles, the histories of the orders and the trades, and the reports. It is the replica when one is configured, and the
+// primary otherwise. The reads that are followed by the writes, such as the balances and the orders being matched, stay
+// on the primary.
+func (app *Context) Reader() *sql.DB {
+
+	if app.Replica != nil {
+		return app.Replica
+	}
+
+	return app.Db
+}
+
+// pool - This function applies the limits of the pool of the connections, the limits are applied again when the configuration is
+// reloaded, the connections over the new limits are closed as they are released. The zero values keep the current limits.
+func (app *Context) pool() {
+
+	if app.Pool == nil {
+		return
+	}
+
+	// The limits are applied to the primary and to the replica alike, each of them has its own pool.
+	for _, db := range []*sql.DB{app.Db, app.Replica} {
+
+		if db == nil {
+			continue
+		}
+
+		// The maximum of the idle connections of zero would close every released connection, so only the limits that are set are applied.
+		if app.Pool.MaxOpen > 0 {
+			db.SetMaxOpenConns(app.Pool.MaxOpen)
+		}
+		if app.Pool.MaxIdle > 0 {
+			db.SetMaxIdleConns(app.Pool.MaxIdle)
+		}
+		if app.Pool.Lifetime > 0 {
+			db.SetConnMaxLifetime(time.Duration(app.Pool.Lifetime) * time.Second)
+		}
+		if app.Pool.IdleTime > 0 {
+			db.SetConnMaxIdleTime(time.Duration(app.Pool.IdleTime) * time.Second)
+		}
+	}
+}
+
+// measure - This function observes the duration of the operation of the database from its start.
+func measure(operation string, start time.Time) {
+	queries.Observe(time.Since(start).Seconds(), operation)
+}
+
+// start - This function starts the span of the query if the query is made with the context of a trace, the queries of the
+// background work without a trace do not start the traces of their own. The span is named by the operation, the statement
+// is an attribute, the arguments are not recorded since they carry the data of the users.
+func start(ctx context.Context, operation, query string) *trace.Span {
+
+	if trace.FromContext(ctx) == nil {
+		return nil
+	}
+
+	_, span := trace.Start(ctx, "db."+operation, trace.KindClient, trace.String("db.system", "postgresql"), trace.String("db.statement", query))
+	return span
+}
@@ -0,0 +1,100 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// FeatureKey - The key of redis the suspended switches of the features are cached under, the key is removed by the operators
+// every time they change a switch, so that the change is applied at once by all the instances.
+const FeatureKey = "features:suspended"
+
+// Scope - The Scope struct is the part of the exchange a feature is checked for: the kind of the scope, such as a currency, a pair
+// or a chain, and its target, such as the symbol of the currency or the id of the chain.
+type Scope struct {
+	Kind, Target string
+}
+
+// feature - The feature struct is a suspended switch of a feature, as it is cached.
+type feature struct {
+	Feature, Scope, Target, Reason string
+}
+
+// Feature - This function checks whether the feature is enabled for all the given scopes, the feature is suspended when its switch
+// is off for the whole exchange or for any of the scopes. The error of the suspended feature carries the reason given by
+// the operators. The switches are read from redis, and from the database when they are not cached, a failure to read
+// them leaves the feature enabled, so that an outage of the cache does not stop the exchange.
+func (app *Context) Feature(name string, scopes ...Scope) error {
+
+	for _, item := range app.features() {
+
+		if item.Feature != name {
+			continue
+		}
+
+		suspended := len(item.Target) == 0
+		for _, scope := range scopes {
+			if scope.Kind == item.Scope && scope.Target == item.Target {
+				suspended = true
+			}
+		}
+
+		if !suspended {
+			continue
+		}
+
+		message := fmt.Sprintf("the %v is suspended", name)
+		if len(item.Target) > 0 {
+			message = fmt.Sprintf("the %v is suspended for the %v %v", name, item.Scope, item.Target)
+		}
+		if len(item.Reason) > 0 {
+			message = fmt.Sprintf("%v: %v", message, item.Reason)
+		}
+
+		return status.Error(11734, message)
+	}
+
+	return nil
+}
+
+// features - This function returns the suspended switches of the features, they are cached for a minute, the switches changed by
+// the operators remove the cache themselves.
+func (app *Context) features() (features []feature) {
+
+	if data, err := app.RedisClient.Get(context.Background(), FeatureKey).Bytes(); err == nil && json.Unmarshal(data, &features) == nil {
+		return features
+	}
+
+	rows, err := app.Db.Query("select feature, scope, target, reason from features where status = $1", false)
+	if app.Debug(err) {
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item feature
+		)
+
+		if err := rows.Scan(&item.Feature, &item.Scope, &item.Target, &item.Reason); app.Debug(err) {
+			return nil
+		}
+
+		features = append(features, item)
+	}
+
+	if app.Debug(rows.Err()) {
+		return nil
+	}
+
+	if data, err := json.Marshal(features); err == nil {
+		app.Debug(app.RedisClient.Set(context.Background(), FeatureKey, data, time.Minute).Err())
+	}
+
+	return features
+}
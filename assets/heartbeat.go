@@ -0,0 +1,18 @@
+package assets
+
+// Heartbeat - This function reports the health of a component of the platform to the status page: the name of the component, its
+// kind, its status, the detail shown next to the status and, for the scanners of the chains, the last block scanned, the
+// zero block keeps the block reported before. The components report themselves on every round of their work, the status
+// page shows the component that stopped reporting as down, so the errors of the report are only logged.
+func (app *Context) Heartbeat(name, kind, status, detail string, block int64) {
+
+	statement, err := app.Statement(`insert into components (name, kind, status, detail, block, heartbeat_at) values ($1, $2, $3, $4, $5, now()) on conflict (name) do update set kind = excluded.kind, status = excluded.status, detail = excluded.detail, block = case when excluded.block > 0 then excluded.block else components.block end, heartbeat_at = excluded.heartbeat_at`)
+	if err != nil {
+		app.Logger.Error(err)
+		return
+	}
+
+	if _, err := statement.Exec(name, kind, status, detail, block); err != nil {
+		app.Logger.Error(err)
+	}
+}
@@ -0,0 +1,140 @@
+package assets
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ImpersonationLifetime - The lifetime of the token of the impersonation, the token is not refreshed, the operator issues a new
+	// impersonation with the reason when the token has expired.
+	ImpersonationLifetime = 30 * time.Minute
+)
+
+// Impersonate - This function issues the access token of the impersonation of the account: the token of the user with the id of
+// the impersonation in the "imp" claim and the operator in the "opr" claim. The token has no session family and no
+// refresh token, it is accepted while the impersonation is active and only by the read-only methods.
+func (app *Context) Impersonate(id, userId, operatorId int64, expireAt time.Time) (string, error) {
+
+	signing := jwt.New(jwt.SigningMethodHS256)
+
+	claims := signing.Claims.(jwt.MapClaims)
+	claims["sub"] = userId
+	claims["imp"] = id
+	claims["opr"] = operatorId
+	claims["jti"] = uuid.NewV4().String()
+	claims["exp"] = expireAt.Unix()
+	claims["iat"] = time.Now().Unix()
+
+	return signing.SignedString([]byte(app.Secrets[0]))
+}
+
+// impersonated - This function checks that the impersonation the access token was issued for is still active, the impersonation
+// ends when it expires or when the operator ends it. The tokens of the users carry no impersonation.
+func (app *Context) impersonated(ctx context.Context, claims jwt.MapClaims) error {
+
+	id, ok := claims["imp"].(float64)
+	if !ok {
+		return nil
+	}
+
+	subject, _ := claims["sub"].(float64)
+
+	var (
+		exist bool
+	)
+
+	if err := app.Db.QueryRowContext(ctx, "select exists(select 1 from impersonations where id = $1 and user_id = $2 and status = true and expire_at > now())", int64(id), int64(subject)).Scan(&exist); err != nil {
+		return err
+	}
+
+	if !exist {
+		return status.Error(10021, "the impersonation has ended")
+	}
+
+	return nil
+}
+
+// impersonation - This function returns the impersonation and the operator of the bearer token, zero for the tokens of the users.
+// The token is parsed without the verification, it is called once the token was verified by the Auth function.
+func impersonation(bearer string) (id, operatorId int64) {
+
+	token, _, err := jwt.NewParser().ParseUnverified(bearer, jwt.MapClaims{})
+	if err != nil {
+		return 0, 0
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	imp, _ := claims["imp"].(float64)
+	opr, _ := claims["opr"].(float64)
+
+	return int64(imp), int64(opr)
+}
+
+// Impersonation - This function returns the id of the impersonation and the operator the request was made by, zero for the
+// requests the users made themselves.
+func (app *Context) Impersonation(ctx context.Context) (id, operatorId int64) {
+	if item, ok := ctx.Value(authenticated{}).(*authentication); ok && item.err == nil {
+		return item.impersonation, item.operator
+	}
+	return 0, 0
+}
+
+// UnaryImpersonate - This function returns the interceptor that keeps the impersonations read-only: the requests made with the
+// token of an impersonation are allowed to the methods of the scope and to the public methods that read, such as the
+// market data, the rest are refused. Every request of the impersonation is audited with the method, and the response is
+// flagged with the impersonation and the operator in its headers.
+func (app *Context) UnaryImpersonate(scope, public map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if err := app.impersonate(ctx, info.FullMethod, scope, public); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamImpersonate - This function returns the interceptor that keeps the impersonations read-only for the streams, in the same
+// way as for the unary requests.
+func (app *Context) StreamImpersonate(scope, public map[string]bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if err := app.impersonate(stream.Context(), info.FullMethod, scope, public); err != nil {
+			return err
+		}
+
+		return handler(srv, stream)
+	}
+}
+
+// impersonate - This function audits the request of the impersonation and refuses it when the method is not read-only, the request
+// is not made when it can not be audited.
+func (app *Context) impersonate(ctx context.Context, method string, scope, public map[string]bool) error {
+
+	id, operatorId := app.Impersonation(ctx)
+	if id == 0 {
+		return nil
+	}
+
+	allowed := scope[method] || public[method] && strings.HasPrefix(path.Base(method), "Get")
+
+	if _, err := app.Db.ExecContext(ctx, "with audit as (insert into impersonation_requests (impersonation_id, method, allowed) values ($1, $2, $3)) update impersonations set requests = requests + 1 where id = $1", id, method, allowed); err != nil {
+		return err
+	}
+
+	if !allowed {
+		return status.Errorf(10022, "the impersonation is read-only, the method %v is not available to it", method)
+	}
+
+	return grpc.SetHeader(ctx, metadata.Pairs("impersonation", fmt.Sprint(id), "impersonated-by", fmt.Sprint(operatorId)))
+}
@@ -0,0 +1,241 @@
+package assets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/metrics"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpcctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// handled and handling - The metrics of the grpc server: the number of the handled requests by their codes and the latency of
+	// the requests, by the services and the methods.
+	handled  = metrics.NewCounter("grpc_server_handled_total", "The number of the requests handled by the grpc server.", "grpc_service", "grpc_method", "grpc_code")
+	handling = metrics.NewHistogram("grpc_server_handling_seconds", "The latency of the requests handled by the grpc server.", metrics.Buckets, "grpc_service", "grpc_method")
+)
+
+// authenticated - The authenticated type is the key of the authentication of the request in the context of the request.
+type authenticated struct{}
+
+// authentication - The authentication struct holds the result of the authentication of the request: the id of the user, the id
+// of the api key if the request was authenticated by a key, the impersonation and the operator if the request was made
+// by the support staff with the token of an impersonation, or the error of the authentication.
+type authentication struct {
+	user, key               int64
+	impersonation, operator int64
+	err                     error
+}
+
+// UnaryAuth - This function returns the interceptor that authenticates the unary requests of the grpc server. The requests are
+// authenticated by the bearer token or by the api key, the methods that are not public are not called without the
+// user, so the handlers take the user from the context with the User function instead of authenticating the requests themselves.
+func (app *Context) UnaryAuth(public map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		ctx, err := app.authenticate(ctx, info.FullMethod, req, public[info.FullMethod])
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuth - This function returns the interceptor that authenticates the streams of the grpc server, in the same way as the
+// unary requests.
+func (app *Context) StreamAuth(public map[string]bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		ctx, err := app.authenticate(stream.Context(), info.FullMethod, nil, public[info.FullMethod])
+		if err != nil {
+			return err
+		}
+
+		wrapped := grpcmiddleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+
+		return handler(srv, wrapped)
+	}
+}
+
+// authenticate - This function authenticates the request and returns the context with the result of the authentication. The
+// public methods are called with the error of the authentication, for them the user is optional. The signed requests are
+// checked against the signing secret of the session, a captured signed request can not be replayed once it has fallen out
+// of the window or was already used once. The id of the user is added to the tags of the request, so that the logs of the
+// request carry it.
+func (app *Context) authenticate(ctx context.Context, method string, req interface{}, public bool) (context.Context, error) {
+
+	var (
+		item authentication
+	)
+
+	meta, _ := metadata.FromIncomingContext(ctx)
+	switch {
+	case len(meta["authorization"]) > 0:
+		if item.user, item.err = app.Auth(ctx); item.err == nil {
+			bearer := strings.TrimPrefix(meta["authorization"][0], "Bearer ")
+			item.impersonation, item.operator = impersonation(bearer)
+			item.err = app.signature(ctx, meta, method, req, app.SigningSecret(family(bearer)))
+		}
+	case len(meta["api-key"]) > 0:
+		var signing string
+		if item.user, item.key, signing, item.err = app.key(ctx, meta["api-key"][0], method); item.err == nil {
+
+			// The api key is sent with every request, the requests made with it are signed with its signing secret, so that a
+			// request seen on the way can not be changed or sent again.
+			if len(meta["timestamp"]) == 0 {
+				item.err = status.Error(10023, "the requests of the api keys must be signed with the timestamp")
+			} else {
+				item.err = app.signature(ctx, meta, method, req, signing)
+			}
+		}
+	default:
+		item.err = status.Error(10010, "missing metadata")
+	}
+
+	if item.err != nil && !public {
+		return ctx, item.err
+	}
+
+	if item.err == nil {
+		grpcctxtags.Extract(ctx).Set("auth.user_id", item.user)
+		if item.impersonation > 0 {
+			grpcctxtags.Extract(ctx).Set("auth.impersonation_id", item.impersonation).Set("auth.operator_id", item.operator)
+		}
+		trace.FromContext(ctx).Set(trace.Int("enduser.id", item.user))
+	}
+
+	return context.WithValue(ctx, authenticated{}, &item), nil
+}
+
+// key - This function returns the user, the id and the signing secret of the api key, the keys are stored as their sha256 hashes.
+// The api keys are not accepted by the admin services, the staff uses the bearer tokens only.
+func (app *Context) key(ctx context.Context, key, method string) (user, id int64, signing string, err error) {
+
+	if strings.HasPrefix(method, "/admin.") {
+		return 0, 0, "", status.Error(10016, "the api keys are not accepted by the admin services")
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	if err := app.Db.QueryRowContext(ctx, "select id, user_id, signing from api_keys where secret = $1 and status = true", hex.EncodeToString(hash[:])).Scan(&id, &user, &signing); err != nil {
+		return 0, 0, "", status.Error(10017, "the api key is not correct")
+	}
+
+	return user, id, signing, nil
+}
+
+// User - This function returns the id of the user the request was authenticated for by the interceptor of the server, the methods
+// that are not public are not called without the user. The requests that were not authenticated return zero.
+func (app *Context) User(ctx context.Context) int64 {
+	if item, ok := ctx.Value(authenticated{}).(*authentication); ok && item.err == nil {
+		return item.user
+	}
+	return 0
+}
+
+// Key - This function returns the id of the api key the request was authenticated by, zero if the request was authenticated by
+// the bearer token. The keys themselves are managed with the bearer tokens only.
+func (app *Context) Key(ctx context.Context) int64 {
+	if item, ok := ctx.Value(authenticated{}).(*authentication); ok && item.err == nil {
+		return item.key
+	}
+	return 0
+}
+
+// UnaryTrace - This function returns the interceptor that records the unary requests of the grpc server as the spans of their
+// traces, the trace of the caller is continued from the traceparent of the metadata of the request. The queries, the
+// published events and the calls of the nodes made by the handler are recorded as the children of the span.
+func (app *Context) UnaryTrace() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+
+		ctx, span := serve(ctx, info.FullMethod)
+		defer func() { span.Set(trace.String("rpc.grpc.status_code", status.Code(err).String())); span.End(err) }()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamTrace - This function returns the interceptor that records the streams of the grpc server as the spans of their traces,
+// in the same way as the unary requests.
+func (app *Context) StreamTrace() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+
+		ctx, span := serve(stream.Context(), info.FullMethod)
+		defer func() { span.Set(trace.String("rpc.grpc.status_code", status.Code(err).String())); span.End(err) }()
+
+		wrapped := grpcmiddleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+
+		return handler(srv, wrapped)
+	}
+}
+
+// UnaryPropagate - This function returns the interceptor of the grpc clients that passes the traceparent of the span of the
+// context with the request, so that the request served by another service joins the trace of the caller.
+func (app *Context) UnaryPropagate() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if traceparent := trace.Traceparent(ctx); len(traceparent) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, "traceparent", traceparent)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// serve - This function starts the span of the request of the method, as the child of the traceparent of the metadata of the
+// request if the caller passed one.
+func serve(ctx context.Context, method string) (context.Context, *trace.Span) {
+
+	if meta, ok := metadata.FromIncomingContext(ctx); ok && len(meta["traceparent"]) > 0 {
+		ctx = trace.Extract(ctx, meta["traceparent"][0])
+	}
+
+	service, name := path.Split(method)
+	return trace.Start(ctx, method, trace.KindServer, trace.String("rpc.system", "grpc"), trace.String("rpc.service", strings.Trim(service, "/")), trace.String("rpc.method", name))
+}
+
+// UnaryMetrics - This function returns the interceptor that counts the unary requests of the grpc server by their codes and
+// observes their latency.
+func (app *Context) UnaryMetrics() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observe(info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamMetrics - This function returns the interceptor that counts the streams of the grpc server by their codes and observes
+// their duration.
+func (app *Context) StreamMetrics() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		start := time.Now()
+		err := handler(srv, stream)
+		observe(info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+// observe - This function records the request of the method in the metrics of the grpc server, the full name of the method is
+// split into the service and the method, such as "pb.spot.Api" and "SetOrder".
+func observe(method string, start time.Time, err error) {
+
+	service, name := path.Split(method)
+	service = strings.Trim(service, "/")
+
+	handled.Inc(service, name, status.Code(err).String())
+	handling.Observe(time.Since(start).Seconds(), service, name)
+}
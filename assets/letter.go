@@ -0,0 +1,138 @@
+package assets
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/broker"
+	"github.com/cryptogateway/backend-envoys/assets/common/metrics"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+)
+
+// lane - The number of the messages of a topic which wait for the broker, the publishing of the topic waits while they are as many.
+const lane = 1024
+
+// letters - The metric of the messages the broker did not accept after all the attempts, which were written to the dead letters.
+var letters = metrics.NewCounter("mqtt_dead_letters_total", "The number of the messages written to the dead letters.", "topic", "channel")
+
+// Retry - The type Retry struct holds the policy of the publishing of the messages to the broker: the number of the attempts, the
+// backoff before the second attempt in milliseconds, which doubles with every next attempt, and the timeout in seconds
+// the answer of the broker is waited for. The message the broker did not accept after all the attempts is written to the
+// dead letters, where the operators can inspect and re-drive it.
+type Retry struct {
+	Attempts, Backoff, Timeout int
+}
+
+// retry - This function returns the policy of the publishing, the fields which are not configured are taken from the default
+// policy: the message is published three times, after the backoff of 200 milliseconds and then of 400, and the broker is
+// waited for ten seconds.
+func (app *Context) retry() Retry {
+
+	policy := Retry{Attempts: 3, Backoff: 200, Timeout: 10}
+	if app.Retry != nil {
+		policy = *app.Retry
+	}
+
+	if policy.Attempts <= 0 {
+		policy.Attempts = 3
+	}
+
+	if policy.Backoff <= 0 {
+		policy.Backoff = 200
+	}
+
+	if policy.Timeout <= 0 {
+		policy.Timeout = 10
+	}
+
+	return policy
+}
+
+// send - This function publishes the message to the topic with the quality of the service and waits for the answer of the broker,
+// the broker that did not answer within the timeout did not accept the message.
+func (app *Context) send(topic string, qos byte, message string, timeout int) error {
+
+	token := app.RabbitmqClient.Publish(topic, qos, false, message)
+	if !token.WaitTimeout(time.Duration(timeout) * time.Second) {
+		return errors.New("the broker did not answer in time")
+	}
+
+	return token.Error()
+}
+
+// deliver - This function delivers the message of the channel to the topic by the policy of the publishing: the failed attempts
+// are counted and repeated after the backoff, and the message the broker did not accept after all the attempts is written
+// to the dead letters with the quality of the service of the topic. It is called by the worker of the topic, so that the
+// publishing does not wait for the broker and the messages of the topic are delivered in order.
+func (app *Context) deliver(topic string, qos byte, channel, message string) {
+
+	var (
+		policy = app.retry()
+		err    error
+	)
+
+	for attempt := 1; attempt <= policy.Attempts; attempt++ {
+
+		if err = app.send(topic, qos, message, policy.Timeout); err == nil {
+			return
+		}
+
+		failures.Inc(broker.Root(topic), channel)
+		app.Logger.WithField("channel", channel).WithField("attempt", attempt).Error(err)
+
+		if attempt < policy.Attempts {
+			time.Sleep(time.Duration(policy.Backoff<<(attempt-1)) * time.Millisecond)
+		}
+	}
+
+	letters.Inc(broker.Root(topic), channel)
+
+	if _, err := app.Db.Exec("insert into dead_letters (topic, channel, message, error, attempts, status, qos) values ($1, $2, $3, $4, $5, $6, $7)", topic, channel, message, err.Error(), policy.Attempts, types.StatusFailed, qos); err != nil {
+		app.Logger.WithField("channel", channel).Error(err)
+	}
+}
+
+// Redrive - This function publishes the dead letter to its topic again, once and with the quality of the service it was published
+// with, and marks it as re-driven when the broker accepts it. The letter the broker did not accept again stays failed
+// with the error and the number of the attempts updated.
+func (app *Context) Redrive(id int64) error {
+
+	var (
+		topic, channel, message string
+		qos                     byte
+	)
+
+	if err := app.Db.QueryRow("select topic, channel, message, qos from dead_letters where id = $1 and status = $2", id, types.StatusFailed).Scan(&topic, &channel, &message, &qos); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return status.Errorf(11764, "the dead letter %v is not found or has already been re-driven", id)
+		}
+		return err
+	}
+
+	if err := app.send(topic, qos, message, app.retry().Timeout); err != nil {
+
+		failures.Inc(broker.Root(topic), channel)
+
+		if _, err := app.Db.Exec("update dead_letters set error = $2, attempts = attempts + 1 where id = $1", id, err.Error()); err != nil {
+			return err
+		}
+
+		return status.Errorf(11765, "the dead letter %v was not accepted by the broker: %v", id, err)
+	}
+
+	if _, err := app.Db.Exec("update dead_letters set status = $2, attempts = attempts + 1, redrive_at = now() where id = $1", id, types.LetterRedriven); err != nil {
+		return err
+	}
+
+	app.Logger.WithField("channel", channel).Infof("the dead letter %v of the topic %v is re-driven", id, topic)
+
+	return nil
+}
+
+// Drain - This function stops the workers of the topics from taking the new messages and waits until the messages which wait for
+// the broker are delivered or written to the dead letters, the messages published afterwards are delivered at once.
+func (app *Context) Drain() {
+	app.deliveries.Close()
+}
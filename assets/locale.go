@@ -0,0 +1,72 @@
+package assets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/i18n"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// LocaleKey - This function returns the key of redis the locale of the account is cached under, the key is removed when the user
+// changes the locale in the settings of the account.
+func LocaleKey(userId int64) string {
+	return fmt.Sprintf("locale:%v", userId)
+}
+
+// Locale - This function returns the locale the messages of the request are returned in: the locale chosen in the settings of the
+// account of the user, or the locale preferred by the Accept-Language header of the request, or the default locale. The
+// locale of the account is cached in redis for an hour.
+func (app *Context) Locale(ctx context.Context) string {
+
+	if user := app.User(ctx); user > 0 {
+
+		name, err := app.RedisClient.Get(context.Background(), LocaleKey(user)).Result()
+		if err != nil {
+			if err := app.Db.QueryRow("select locale from accounts where id = $1", user).Scan(&name); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				app.Debug(err)
+			}
+			app.Debug(app.RedisClient.Set(context.Background(), LocaleKey(user), name, time.Hour).Err())
+		}
+
+		if name = app.Locales.Supported(name); len(name) > 0 {
+			return name
+		}
+	}
+
+	if meta, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := meta.Get("accept-language"); len(values) > 0 {
+			if name := app.Locales.Negotiate(values[0]); len(name) > 0 {
+				return name
+			}
+		}
+	}
+
+	return i18n.Default
+}
+
+// UnaryLocale - This function returns the interceptor that resolves the locale of the request after the user is authenticated, the
+// handlers take it from the context of the request, and that translates the messages of the errors the handlers return to
+// it. The errors that are not the messages of the catalogue are returned as they are.
+func (app *Context) UnaryLocale() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		name := app.Locale(ctx)
+
+		resp, err := handler(i18n.WithLocale(ctx, name), req)
+
+		var (
+			message *i18n.Message
+		)
+
+		if errors.As(err, &message) {
+			return resp, message.Status(app.Locales, name)
+		}
+
+		return resp, err
+	}
+}
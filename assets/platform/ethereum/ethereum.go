@@ -0,0 +1,157 @@
+// Package ethereum is the adapter of the Ethereum platform, the chains compatible with the Ethereum virtual machine, such as
+// the BNB Smart Chain or Polygon, are the chains of this platform with their own rpc and network.
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/blockchain"
+	"github.com/cryptogateway/backend-envoys/assets/common/address"
+	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
+	"github.com/cryptogateway/backend-envoys/assets/common/siwe"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strings"
+)
+
+// transfer - The topic of the Transfer(address,address,uint256) event the tokens emit on their transfers.
+var transfer = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex()
+
+func init() {
+	platform.Register(adapter{})
+}
+
+// adapter - The adapter of the Ethereum platform, the addresses are recorded in lower case hex with the 0x prefix.
+type adapter struct{}
+
+// client - The client of the node of a chain of the platform, the requests of the node are those of the blockchain package.
+type client struct {
+	*blockchain.Params
+}
+
+// Name - This function returns the name of the platform.
+func (adapter) Name() string {
+	return types.PlatformEthereum
+}
+
+// Derive - This function derives the address and the private key of the user with the path m/44'/60'/0'/0/index.
+func (adapter) Derive(secret string, entropy []byte, index uint32) (string, string, error) {
+	var cross keypair.CrossChain
+	return cross.Derive(secret, entropy, types.PlatformEthereum, index)
+}
+
+// Validate - This function validates the address and its EIP-55 checksum.
+func (adapter) Validate(address string) error {
+	return keypair.ValidateCryptoAddress(address, types.PlatformEthereum)
+}
+
+// Format - This function returns the address in lower case hex.
+func (adapter) Format(src string) string {
+	return address.New(src).Hex()
+}
+
+// Verify - This function checks that the personal message of EIP-191 was signed by the key of the address, the signature is the
+// hex of the 65 bytes the wallets return for personal_sign.
+func (a adapter) Verify(address, message, signature string) error {
+
+	signer, err := siwe.Recover(message, signature)
+	if err != nil {
+		return err
+	}
+
+	if a.Format(signer) != a.Format(address) {
+		return errors.New("the message is not signed by the address")
+	}
+
+	return nil
+}
+
+// Dial - This function returns the client of the json-rpc of the node.
+func (adapter) Dial(rpc string) (platform.Client, error) {
+
+	params, err := blockchain.Dial(rpc, types.PlatformEthereum)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{params}, nil
+}
+
+// Replace - This function sends the pending transaction with the hash again at the price of the gas with the same nonce, or the
+// transfer of nothing to the sender in its place when cancel is set.
+func (c *client) Replace(ctx context.Context, key *platform.Key, hash string, price *big.Int, cancel bool) (string, error) {
+	return c.Reprice(ctx, key, hash, price, cancel)
+}
+
+// Scan - This function reads the block at the height and returns the transfers of the coin and the Transfer events of the tokens
+// of its transactions. The block that is not mined yet, or whose logs are not read, is returned with the error, so that
+// it is read again.
+func (c *client) Scan(height int64) (transfers []*platform.Transfer, err error) {
+
+	// The panic of the parsing of the block is returned as the error, the block is read again.
+	defer func() {
+		if r := recover(); r != nil {
+			transfers, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+
+	block, err := c.BlockByNumber(height)
+	if err != nil { // No debug....
+		return nil, fmt.Errorf("waiting for the block %v", height)
+	}
+
+	for _, tx := range block.Transactions {
+
+		switch tx.Type {
+		case blockchain.TypeInternal:
+
+			// The value of the transfer of the coin is the hex string of the wei with the 0x prefix.
+			amount, ok := new(big.Int).SetString(strings.TrimPrefix(tx.Value, "0x"), 16)
+			if !ok || amount.Sign() <= 0 {
+				continue
+			}
+
+			transfers = append(transfers, &platform.Transfer{
+				Hash:      tx.Hash,
+				From:      address.New(tx.From).Hex(),
+				To:        address.New(tx.To).Hex(),
+				Amount:    amount,
+				Block:     height,
+				BlockHash: block.Hash,
+			})
+
+		case blockchain.TypeContract:
+
+			logs, err := c.LogByTx(tx.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			// The transfer of the token is the Transfer event with the sender and the recipient in the indexed topics and the
+			// amount in the data of the log.
+			if logs.Data == nil || len(logs.Topics) != 3 || logs.Topics[0] != transfer {
+				continue
+			}
+
+			amount, err := logs.Amount()
+			if err != nil || amount.Sign() <= 0 {
+				continue
+			}
+
+			transfers = append(transfers, &platform.Transfer{
+				Hash:      tx.Hash,
+				From:      address.New(logs.Topics[1].(string)).Hex(),
+				To:        address.New(logs.Topics[2].(string)).Hex(),
+				Contract:  address.New(tx.To).Hex(),
+				Amount:    amount,
+				Block:     height,
+				BlockHash: block.Hash,
+			})
+		}
+	}
+
+	return transfers, nil
+}
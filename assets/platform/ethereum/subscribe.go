@@ -0,0 +1,93 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/gorilla/websocket"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// silence - The time the subscription waits for the next head of the chain, the connection that stays silent longer is taken for
+// lost, even when the node did not close it.
+const silence = 2 * time.Minute
+
+// Subscribe - This function subscribes to the newHeads of the node with the websocket endpoint, the eth_subscribe of the json-rpc,
+// and sends the heads of the chain to the channel. It returns the error when the connection is lost, the node refuses
+// the subscription or stays silent, and the error of the context when the context is done.
+func (adapter) Subscribe(ctx context.Context, endpoint string, heads chan<- *platform.Head) error {
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The connection is closed when the context is done, so that the reading of the next head returns.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "eth_subscribe", "params": []string{"newHeads"}}); err != nil {
+		return err
+	}
+
+	for {
+
+		var (
+			message struct {
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+				Params struct {
+					Result struct {
+						Number     string `json:"number"`
+						Hash       string `json:"hash"`
+						ParentHash string `json:"parentHash"`
+					} `json:"result"`
+				} `json:"params"`
+			}
+		)
+
+		if err := conn.SetReadDeadline(time.Now().Add(silence)); err != nil {
+			return err
+		}
+
+		if err := conn.ReadJSON(&message); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		if message.Error != nil {
+			return errors.New(message.Error.Message)
+		}
+
+		// The answer to the subscription carries the id of the subscription only, the notifications carry the heads.
+		result := message.Params.Result
+		if len(result.Number) == 0 {
+			continue
+		}
+
+		number, err := strconv.ParseInt(strings.TrimPrefix(result.Number, "0x"), 16, 64)
+		if err != nil {
+			continue
+		}
+
+		select {
+		case heads <- &platform.Head{Number: number, Hash: result.Hash, Parent: result.ParentHash}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
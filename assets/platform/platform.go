@@ -0,0 +1,227 @@
+// Package platform defines the adapters of the blockchain platforms the exchange works with. Every platform, such as
+// Ethereum with the chains compatible with it or Tron, is an adapter registered under the name of the platform the chains
+// are recorded with, the services of the exchange look the adapter of a chain up and never switch on its platform.
+//
+// Adding a new chain of a known platform takes a record of the chain only. Adding a new platform, such as Polkadot, takes a
+// package of its own next to the ethereum, the tron and the solana packages:
+//
+//  1. Implement Platform: the name of the platform, the derivation of the deposit addresses from the entropy of the
+//     users (for example the ed25519 keys of Solana with the path m/44'/501'/index'/0', or the sr25519 keys of Polkadot
+//     with the SS58 format of the network), the validation of the addresses the users withdraw to and the form the
+//     addresses are recorded in, and Dial, which returns the Client of the node of the chain.
+//  2. Implement Client against the api of the node: Scan returns the transfers of the coin and of the tokens (the SPL
+//     tokens of Solana, the assets of the pallets of Polkadot) of a block, or of a slot, with the amounts in the smallest
+//     units. Send builds, signs and broadcasts the transfer, the key of the Key is derived by Derive, Estimate and Fees
+//     return the cost of the transfers in the smallest units of the coin of the chain.
+//  3. Register the adapter in the init function of the package and import the package for its side effect where the
+//     services start, the platform is then added to the platforms the chains and the contracts are recorded with.
+//
+// The platforms whose blocks are too large to be read whole, such as Solana, find the transfers by the accounts they touch:
+// their clients implement Tracker, the scanner hands them the deposit addresses and the contracts of the tokens, and
+// Scan reports the transfers of those accounts only, the scanner does not need the other transfers of the block.
+//
+// The clients of the platforms whose chains reorganize implement Canonical, the deposits are recorded with the hashes of their
+// blocks and are checked against the canonical chain until they are final, the deposits of the replaced blocks are
+// located again or reverted.
+//
+// The adapters of the platforms whose nodes push the new blocks over the websocket, such as the newHeads subscription of
+// Ethereum, implement Subscriber: the scanner of the chain with the websocket endpoint follows the heads of the chain and
+// reads the block as soon as it is announced, without asking the node for the head on every pass. The chains of the
+// other platforms, such as Tron whose nodes have no subscriptions, and the chains whose subscription is lost, are polled.
+//
+// The clients of the platforms whose pending transactions wait in the pools of the nodes implement Replacer, the withdrawals
+// that wait longer than the chain allows are sent again at a higher price of the gas with the same nonce, or cancelled.
+//
+// The adapters of the platforms whose wallets sign the messages implement Verifier, the users prove with it that they own
+// the external addresses they deposit from. The deposits from such addresses are the deposits from the verified sources.
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrPlatform - The error of the platform that has no adapter registered.
+	ErrPlatform = errors.New("the platform is not supported")
+
+	// mutex - The lock of the registry, the adapters are registered by the init functions and read by the services.
+	mutex sync.RWMutex
+
+	// platforms - The registry of the adapters by the names of their platforms.
+	platforms = make(map[string]Platform)
+)
+
+// Platform - The Platform interface is the adapter of a blockchain platform: the derivation of the deposit addresses, the
+// validation and the form of the addresses, and the client of the nodes of its chains.
+type Platform interface {
+
+	// Name - The name of the platform the chains, the wallets and the contracts are recorded with.
+	Name() string
+
+	// Derive - The address and the private key with the index of the deposit address of the user, derived from the secret of the
+	// exchange and the entropy of the user. The index 0 is the main address of the user.
+	Derive(secret string, entropy []byte, index uint32) (address, private string, err error)
+
+	// Validate - The error of the address that is not an address of the platform, the error is returned to the users as it is.
+	Validate(address string) error
+
+	// Format - The address in the form the wallets and the contracts of the platform are recorded in.
+	Format(address string) string
+
+	// Dial - The client of the node of the chain with the rpc, the error when the node does not answer.
+	Dial(rpc string) (Client, error)
+}
+
+// Client - The Client interface is the client of the node of a chain. A client is used by one goroutine, every caller dials the
+// node of its own.
+type Client interface {
+
+	// Head - The number of the latest block of the chain.
+	Head() (int64, error)
+
+	// Scan - The transfers of the coin and of the tokens of the block with the height, the error when the block is not read yet.
+	Scan(height int64) ([]*Transfer, error)
+
+	// Status - Whether the transaction with the hash is confirmed by the chain and succeeded.
+	Status(hash string) bool
+
+	// BalanceAt - The balance of the coin of the chain at the address, in its smallest units.
+	BalanceAt(address string) (*big.Int, error)
+
+	// Metadata - The symbol and the decimals of the token of the contract.
+	Metadata(contract string) (symbol string, decimals int32, err error)
+
+	// Fees - The costs of a transfer of the coin and of a transfer of a token at the current prices of the network, in the
+	// smallest units of the coin of the chain.
+	Fees() (coin, token *big.Int, err error)
+
+	// Estimate - The cost of the transfer of the amount to the address, of the token of the contract or of the coin when the
+	// contract is empty, in the smallest units of the coin of the chain. The key the transfer is signed with is set first.
+	Estimate(key *Key, to, contract string, amount *big.Int) (*big.Int, error)
+
+	// Send - This function signs the transfer with the key, broadcasts it and returns the hash of the transaction. The requests of
+	// the node are recorded in the trace of the context.
+	Send(ctx context.Context, key *Key, to, contract string, amount *big.Int) (hash string, err error)
+}
+
+// Tracker - The Tracker interface is the client of the platform whose transfers are found by the accounts they touch instead of
+// by reading the whole blocks, such as Solana. The scanner hands the deposit addresses of the platform and the contracts
+// of the tokens of the chain to the client before Scan, the transfers of the other accounts are not reported.
+type Tracker interface {
+	Track(addresses, contracts []string)
+}
+
+// Subscriber - The Subscriber interface is the adapter of the platform whose nodes push the new blocks to their subscribers over
+// the websocket. Subscribe sends the heads of the chain to the channel until the context is done or the connection is
+// lost, the error is returned then, the scanner of the chain polls the head of the chain while it is not subscribed.
+type Subscriber interface {
+	Subscribe(ctx context.Context, endpoint string, heads chan<- *Head) error
+}
+
+// Canonical - The Canonical interface is the client of the platform whose chains reorganize, such as Ethereum or Tron, the blocks
+// the scanner read may be replaced by the other blocks of the same heights. Hash returns the hash of the block of the
+// canonical chain at the height, Locate returns the height of the block the transaction with the hash is mined in on the
+// canonical chain, zero when the transaction is not mined.
+type Canonical interface {
+	Hash(height int64) (string, error)
+	Locate(hash string) (int64, error)
+}
+
+// Replacer - The Replacer interface is the client of the platform whose pending transactions are replaced by the transactions of
+// the same nonce at a higher price of the gas, such as Ethereum. Pending returns the sender of the transaction with the
+// hash, the price of the gas it pays and whether it still waits to be mined. Replace signs the transaction again at the
+// price, or the transfer of nothing to the sender when cancel is set, broadcasts it and returns its hash. The transactions
+// of Tron expire instead of waiting, they are not replaced.
+type Replacer interface {
+	Pending(hash string) (from string, price *big.Int, waiting bool, err error)
+	Replace(ctx context.Context, key *Key, hash string, price *big.Int, cancel bool) (string, error)
+}
+
+// Verifier - The Verifier interface is the adapter of the platform whose wallets sign the messages, such as the personal messages
+// of Ethereum and Tron or the messages of the Solana wallets. Verify returns the error when the signature of the message
+// was not made by the key of the address.
+type Verifier interface {
+	Verify(address, message, signature string) error
+}
+
+// Transfer - The Transfer struct is a transfer of a chain: the hash of the transaction, the sender and the recipient, the address
+// of the contract of the token, empty for the coin of the chain, the amount in the smallest units of the coin or of the
+// token, the block the transfer was mined in and the hash of the block, empty for the platforms whose blocks are read
+// once they are final.
+type Transfer struct {
+	Hash, From, To, Contract string
+	Amount                   *big.Int
+	Block                    int64
+	BlockHash                string
+}
+
+// Head - The Head struct is the header of the new block the node announced to its subscribers: the number of the block, its hash
+// and the hash of its parent.
+type Head struct {
+	Number       int64
+	Hash, Parent string
+}
+
+// Key - The Key struct is the key the transfers are signed with: the address and the private key returned by Derive and the id
+// of the network of the chain. The transfers of the key with the signer are signed by the external signing service
+// instead, the private key is not used then.
+type Key struct {
+	Address, Private string
+	Network          int64
+	Signer           *Signer
+}
+
+// Signer - The Signer struct is the external signing service of a chain, the audit receives the request and the response of
+// every signature.
+type Signer struct {
+	Endpoint, Token string
+	Audit           func(request, response []byte, err error)
+}
+
+// Register - This function registers the adapter under the name of its platform, the adapter registered later replaces the
+// earlier one.
+func Register(p Platform) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	platforms[p.Name()] = p
+}
+
+// Lookup - This function returns the adapter of the platform with the name, false when the platform is not registered.
+func Lookup(name string) (Platform, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	p, ok := platforms[name]
+	return p, ok
+}
+
+// Dial - This function returns the client of the node of the chain with the rpc from the adapter of the platform with the
+// name, the error wraps ErrPlatform when the platform is not registered.
+func Dial(rpc, name string) (Client, error) {
+
+	p, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrPlatform, name)
+	}
+
+	return p.Dial(rpc)
+}
+
+// Platforms - This function returns the names of the registered platforms in order.
+func Platforms() (names []string) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for name := range platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
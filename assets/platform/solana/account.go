@@ -0,0 +1,99 @@
+package solana
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"math/big"
+)
+
+var (
+	// The prime of the field and the constant d of the twisted Edwards curve of ed25519, the program addresses have to be off
+	// the curve, so that no private key signs for them.
+	prime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	curve = func() *big.Int {
+		d := new(big.Int).ModInverse(big.NewInt(121666), prime)
+		d.Mul(d, big.NewInt(-121665))
+		return d.Mod(d, prime)
+	}()
+)
+
+// decode - This function returns the public key of the Base58 address, nil when the address is not a key of 32 bytes.
+func decode(address string) []byte {
+	if key := base58.Decode(address); len(key) == 32 {
+		return key
+	}
+	return nil
+}
+
+// encode - This function returns the Base58 address of the public key.
+func encode(key []byte) string {
+	return base58.Encode(key)
+}
+
+// associated - This function returns the associated token account of the owner for the token of the mint, the account the
+// wallets send the tokens of the mint to.
+func associated(owner, mint []byte) ([]byte, error) {
+	return program([][]byte{owner, tokenProgram, mint}, associatedProgram)
+}
+
+// program - This function finds the program address of the seeds: the hash of the seeds, the bump seed, the program and the
+// marker, with the highest bump seed the hash of which is off the curve.
+func program(seeds [][]byte, id []byte) ([]byte, error) {
+
+	for bump := 255; bump >= 0; bump-- {
+
+		hash := sha256.New()
+		for _, seed := range seeds {
+			hash.Write(seed)
+		}
+		hash.Write([]byte{byte(bump)})
+		hash.Write(id)
+		hash.Write([]byte("ProgramDerivedAddress"))
+
+		if address := hash.Sum(nil); !oncurve(address) {
+			return address, nil
+		}
+	}
+
+	return nil, errors.New("the program address of the seeds is not found")
+}
+
+// oncurve - This function reports whether the 32 bytes are the compressed point of the curve: the coordinate y in little
+// endian with the sign of x in the top bit, the point exists when (y² - 1) / (d·y² + 1) is a square of the field.
+func oncurve(point []byte) bool {
+
+	le := make([]byte, 32)
+	for i := range point {
+		le[31-i] = point[i]
+	}
+	le[0] &= 0x7f
+
+	y := new(big.Int).SetBytes(le)
+	y.Mod(y, prime)
+
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, prime)
+
+	u := new(big.Int).Sub(y2, big.NewInt(1))
+	u.Mod(u, prime)
+
+	v := new(big.Int).Mul(curve, y2)
+	v.Add(v, big.NewInt(1))
+	v.Mod(v, prime)
+
+	if v.Sign() == 0 {
+		return false
+	}
+
+	x2 := new(big.Int).Mul(u, new(big.Int).ModInverse(v, prime))
+	x2.Mod(x2, prime)
+
+	if x2.Sign() == 0 {
+		return true
+	}
+
+	// The Euler criterion: the square of the field raised to (p - 1) / 2 is one.
+	exp := new(big.Int).Rsh(new(big.Int).Sub(prime, big.NewInt(1)), 1)
+	return new(big.Int).Exp(x2, exp, prime).Cmp(big.NewInt(1)) == 0
+}
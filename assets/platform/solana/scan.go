@@ -0,0 +1,247 @@
+package solana
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"sort"
+)
+
+const (
+	// page - The number of the signatures of an account getSignaturesForAddress returns at most.
+	page = 1000
+)
+
+type (
+	// signature - The signature of a transaction that touched a tracked account and the slot of the transaction.
+	signature struct {
+		Signature string      `json:"signature"`
+		Slot      int64       `json:"slot"`
+		Err       interface{} `json:"err"`
+	}
+
+	// instruction - The instruction of a transaction in the parsed encoding, the parsed is a string for the programs whose
+	// instructions the node does not parse into objects, such as the memo.
+	instruction struct {
+		Program string          `json:"program"`
+		Parsed  json.RawMessage `json:"parsed"`
+	}
+
+	// balance - The balance of a token account before or after the transaction, with the owner and the mint of the account.
+	balance struct {
+		AccountIndex int    `json:"accountIndex"`
+		Mint         string `json:"mint"`
+		Owner        string `json:"owner"`
+	}
+
+	// transaction - The transaction in the parsed encoding.
+	transaction struct {
+		Slot int64 `json:"slot"`
+		Meta *struct {
+			Err               interface{} `json:"err"`
+			PreTokenBalances  []balance   `json:"preTokenBalances"`
+			PostTokenBalances []balance   `json:"postTokenBalances"`
+			InnerInstructions []struct {
+				Instructions []instruction `json:"instructions"`
+			} `json:"innerInstructions"`
+		} `json:"meta"`
+		Transaction struct {
+			Message struct {
+				AccountKeys []struct {
+					Pubkey string `json:"pubkey"`
+				} `json:"accountKeys"`
+				Instructions []instruction `json:"instructions"`
+			} `json:"message"`
+		} `json:"transaction"`
+	}
+)
+
+// Scan - This function returns the transfers of SOL and of the tokens to the tracked accounts in the window of the slots with the
+// height. The signatures of the transactions of every account are read back from the newest until the window, the
+// transactions of the window are read in the order of the slots. The window that is not finalized yet is returned with the
+// error, so that it is read again.
+func (c *client) Scan(height int64) (transfers []*platform.Transfer, err error) {
+
+	// The panic of the parsing of the transactions is returned as the error, the window is read again.
+	defer func() {
+		if r := recover(); r != nil {
+			transfers, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+
+	var (
+		start, end = height * window, (height+1)*window - 1
+		seen       = make(map[string]bool)
+		signatures []signature
+	)
+
+	finalized, err := c.slot()
+	if err != nil || end > finalized {
+		return nil, fmt.Errorf("waiting for the block %v", height)
+	}
+
+	for _, address := range c.accounts {
+
+		var (
+			before string
+		)
+
+		for {
+
+			options := map[string]interface{}{"limit": page, "commitment": "finalized"}
+			if len(before) > 0 {
+				options["before"] = before
+			}
+
+			var (
+				items []signature
+			)
+
+			if err := c.call("getSignaturesForAddress", []interface{}{address, options}, &items); err != nil {
+				return nil, err
+			}
+
+			for _, item := range items {
+				if item.Slot >= start && item.Slot <= end && item.Err == nil && !seen[item.Signature] {
+					seen[item.Signature] = true
+					signatures = append(signatures, item)
+				}
+			}
+
+			// The signatures are returned from the newest, the account is read when the oldest of the page is before the window.
+			if len(items) < page || items[len(items)-1].Slot < start {
+				break
+			}
+			before = items[len(items)-1].Signature
+		}
+	}
+
+	sort.SliceStable(signatures, func(i, j int) bool { return signatures[i].Slot < signatures[j].Slot })
+
+	for _, item := range signatures {
+
+		var (
+			tx *transaction
+		)
+
+		if err := c.call("getTransaction", []interface{}{item.Signature, map[string]interface{}{"encoding": "jsonParsed", "commitment": "finalized", "maxSupportedTransactionVersion": 0}}, &tx); err != nil {
+			return nil, err
+		}
+
+		if tx == nil {
+			return nil, fmt.Errorf("waiting for the block %v", height)
+		}
+
+		transfers = append(transfers, tx.transfers(item.Signature, height)...)
+	}
+
+	return transfers, nil
+}
+
+// transfers - This function returns the transfers of SOL of the system program and of the tokens of the token program of the
+// instructions of the transaction and of the instructions the programs it called invoked. The recipient of the token is
+// the owner of the token account the token is sent to, the owners of the token accounts are those of their balances.
+func (tx *transaction) transfers(hash string, height int64) (transfers []*platform.Transfer) {
+
+	if tx.Meta == nil || tx.Meta.Err != nil {
+		return nil
+	}
+
+	var (
+		accounts     = make(map[string]balance)
+		instructions = tx.Transaction.Message.Instructions
+	)
+
+	for _, item := range append(tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances...) {
+		if item.AccountIndex < len(tx.Transaction.Message.AccountKeys) {
+			accounts[tx.Transaction.Message.AccountKeys[item.AccountIndex].Pubkey] = item
+		}
+	}
+
+	for _, inner := range tx.Meta.InnerInstructions {
+		instructions = append(instructions, inner.Instructions...)
+	}
+
+	for _, item := range instructions {
+
+		var (
+			parsed struct {
+				Type string `json:"type"`
+				Info struct {
+					Source      string      `json:"source"`
+					Destination string      `json:"destination"`
+					Authority   string      `json:"authority"`
+					Mint        string      `json:"mint"`
+					Lamports    json.Number `json:"lamports"`
+					Amount      string      `json:"amount"`
+					TokenAmount struct {
+						Amount string `json:"amount"`
+					} `json:"tokenAmount"`
+				} `json:"info"`
+			}
+		)
+
+		if len(item.Parsed) == 0 || item.Parsed[0] != '{' {
+			continue
+		}
+
+		if err := json.Unmarshal(item.Parsed, &parsed); err != nil {
+			continue
+		}
+
+		switch {
+		case item.Program == "system" && parsed.Type == "transfer":
+
+			value, err := amount(string(parsed.Info.Lamports))
+			if err != nil || value.Sign() <= 0 {
+				continue
+			}
+
+			transfers = append(transfers, &platform.Transfer{
+				Hash:   hash,
+				From:   parsed.Info.Source,
+				To:     parsed.Info.Destination,
+				Amount: value,
+				Block:  height,
+			})
+
+		case item.Program == "spl-token" && (parsed.Type == "transfer" || parsed.Type == "transferChecked"):
+
+			destination, ok := accounts[parsed.Info.Destination]
+			if !ok || len(destination.Owner) == 0 {
+				continue
+			}
+
+			mint := parsed.Info.Mint
+			if len(mint) == 0 {
+				mint = destination.Mint
+			}
+
+			src := parsed.Info.Amount
+			if len(src) == 0 {
+				src = parsed.Info.TokenAmount.Amount
+			}
+
+			value, err := amount(src)
+			if err != nil || value.Sign() <= 0 {
+				continue
+			}
+
+			from := parsed.Info.Authority
+			if source, ok := accounts[parsed.Info.Source]; ok && len(source.Owner) > 0 {
+				from = source.Owner
+			}
+
+			transfers = append(transfers, &platform.Transfer{
+				Hash:     hash,
+				From:     from,
+				To:       destination.Owner,
+				Contract: mint,
+				Amount:   value,
+				Block:    height,
+			})
+		}
+	}
+
+	return transfers
+}
@@ -0,0 +1,449 @@
+// Package solana is the adapter of the Solana platform, the SPL tokens of the token program are the tokens of its chains. The
+// blocks of Solana are the slots, they are produced every 400 milliseconds and are too large to be read whole, the client
+// finds the transfers by the accounts they touch: the deposit addresses for the transfers of SOL and the associated token
+// accounts of the deposit addresses for the transfers of the tokens, with getSignaturesForAddress. The block of the scanner
+// of a Solana chain is the window of the slots, the number of the block is the slot divided by the window, so that the
+// block of the chain is set to the finalized slot divided by the window when the chain is added.
+package solana
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/pkg/errors"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// window - The number of the slots the scanner reads as one block, about 40 seconds of the chain.
+	window = 100
+
+	// base - The base fee of every signature of a transaction in lamports, the transfers are signed by the sender only.
+	base = 5000
+
+	// coinUnits and tokenUnits - The compute units the transfers of SOL and of the tokens are limited to, the transfer of the
+	// token creates the associated token account of the recipient when it does not exist yet.
+	coinUnits  = 1000
+	tokenUnits = 50000
+
+	// percentile and maxPrice - The priority fee of the transfers is the percentile of the recent priority fees of the accounts
+	// of the transfer, in micro-lamports per compute unit, no more than the max price.
+	percentile = 75
+	maxPrice   = 1000000
+
+	// account - The size of the data of the token account, the rent of a new associated token account is paid by the sender.
+	account = 165
+)
+
+var (
+	// The programs of the chain the transfers are built with.
+	systemProgram     = decode("11111111111111111111111111111111")
+	tokenProgram      = decode("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
+	associatedProgram = decode("ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL")
+	computeProgram    = decode("ComputeBudget111111111111111111111111111111")
+	metadataProgram   = decode("metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bt518x1s")
+)
+
+func init() {
+	platform.Register(adapter{})
+}
+
+// adapter - The adapter of the Solana platform, the addresses are the ed25519 public keys in Base58.
+type adapter struct{}
+
+// client - The client of the json-rpc of a node of the platform. The accounts are the accounts the transfers are found by, the
+// price is the priority fee of the last estimate, the transfer that is sent after it pays the estimated fee.
+type client struct {
+	ctx      context.Context
+	rpc      string
+	http     *http.Client
+	accounts []string
+	price    *big.Int
+}
+
+// Name - This function returns the name of the platform.
+func (adapter) Name() string {
+	return types.PlatformSolana
+}
+
+// Derive - This function derives the address and the private key of the user with the path m/44'/501'/index'/0'.
+func (adapter) Derive(secret string, entropy []byte, index uint32) (string, string, error) {
+	var cross keypair.CrossChain
+	return cross.Derive(secret, entropy, types.PlatformSolana, index)
+}
+
+// Validate - This function validates the address, the Base58 of the public key of 32 bytes.
+func (adapter) Validate(address string) error {
+	return keypair.ValidateCryptoAddress(address, types.PlatformSolana)
+}
+
+// Format - This function returns the address as it is, the Base58 addresses are case sensitive.
+func (adapter) Format(src string) string {
+	return strings.TrimSpace(src)
+}
+
+// Verify - This function checks that the message was signed by the key of the address with signMessage of the Solana wallets,
+// the ed25519 signature of the bytes of the message in Base58, or in hex with the 0x prefix.
+func (adapter) Verify(address, message, signature string) error {
+
+	public := decode(address)
+	if public == nil {
+		return errors.New("the address is not correct")
+	}
+
+	sig := base58.Decode(signature)
+	if hexed, ok := strings.CutPrefix(signature, "0x"); ok {
+		sig, _ = hex.DecodeString(hexed)
+	}
+
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(public, []byte(message), sig) {
+		return errors.New("the message is not signed by the address")
+	}
+
+	return nil
+}
+
+// Dial - This function returns the client of the json-rpc of the node.
+func (adapter) Dial(rpc string) (platform.Client, error) {
+
+	if ok := help.Ping(rpc); !ok {
+		return nil, errors.New("connect error to blockchain")
+	}
+
+	return &client{
+		rpc:  rpc,
+		http: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Track - This function sets the accounts the transfers are found by: the addresses and their associated token accounts of the
+// tokens of the contracts.
+func (c *client) Track(addresses, contracts []string) {
+
+	var (
+		seen = make(map[string]bool)
+	)
+
+	c.accounts = nil
+	for _, address := range addresses {
+
+		owner := decode(address)
+		if owner == nil {
+			continue
+		}
+
+		items := []string{address}
+		for _, contract := range contracts {
+			if mint := decode(contract); mint != nil {
+				if ata, err := associated(owner, mint); err == nil {
+					items = append(items, encode(ata))
+				}
+			}
+		}
+
+		for _, item := range items {
+			if !seen[item] {
+				seen[item] = true
+				c.accounts = append(c.accounts, item)
+			}
+		}
+	}
+}
+
+// Head - This function returns the number of the last window of the slots that is finalized.
+func (c *client) Head() (int64, error) {
+
+	slot, err := c.slot()
+	if err != nil {
+		return 0, err
+	}
+
+	return slot/window - 1, nil
+}
+
+// Status - This function reports whether the transaction with the signature is finalized and succeeded.
+func (c *client) Status(hash string) bool {
+
+	var (
+		result struct {
+			Value []*struct {
+				ConfirmationStatus string      `json:"confirmationStatus"`
+				Err                interface{} `json:"err"`
+			} `json:"value"`
+		}
+	)
+
+	if err := c.call("getSignatureStatuses", []interface{}{[]string{hash}, map[string]interface{}{"searchTransactionHistory": true}}, &result); err != nil {
+		return false
+	}
+
+	if len(result.Value) == 0 || result.Value[0] == nil {
+		return false
+	}
+
+	return result.Value[0].ConfirmationStatus == "finalized" && result.Value[0].Err == nil
+}
+
+// BalanceAt - This function returns the balance of SOL at the address in lamports.
+func (c *client) BalanceAt(address string) (*big.Int, error) {
+
+	var (
+		result struct {
+			Value json.Number `json:"value"`
+		}
+	)
+
+	if err := c.call("getBalance", []interface{}{address, map[string]interface{}{"commitment": "finalized"}}, &result); err != nil {
+		return new(big.Int), err
+	}
+
+	return amount(string(result.Value))
+}
+
+// Metadata - This function returns the symbol of the token from the account of its Metaplex metadata and the decimals from the
+// account of its mint.
+func (c *client) Metadata(contract string) (symbol string, decimals int32, err error) {
+
+	if decimals, err = c.decimals(contract); err != nil {
+		return symbol, decimals, err
+	}
+
+	mint := decode(contract)
+	if mint == nil {
+		return symbol, decimals, errors.New("the address of the mint is not correct")
+	}
+
+	address, err := program([][]byte{[]byte("metadata"), metadataProgram, mint}, metadataProgram)
+	if err != nil {
+		return symbol, decimals, err
+	}
+
+	data, err := c.data(encode(address))
+	if err != nil {
+		return symbol, decimals, err
+	}
+
+	// The metadata is the key, the update authority and the mint, followed by the name and the symbol as the strings of borsh,
+	// the length of four bytes and the bytes padded with zeros.
+	offset := 1 + 32 + 32
+	for i := 0; i < 2; i++ {
+
+		if len(data) < offset+4 {
+			return symbol, decimals, errors.New("the metadata of the token is not correct")
+		}
+
+		size := int(uint32(data[offset]) | uint32(data[offset+1])<<8 | uint32(data[offset+2])<<16 | uint32(data[offset+3])<<24)
+		if len(data) < offset+4+size {
+			return symbol, decimals, errors.New("the metadata of the token is not correct")
+		}
+
+		symbol = strings.TrimSpace(strings.TrimRight(string(data[offset+4:offset+4+size]), "\x00"))
+		offset += 4 + size
+	}
+
+	return symbol, decimals, nil
+}
+
+// Fees - This function returns the costs of a transfer of SOL and of a transfer of a token at the recent priority fees, in
+// lamports. The rent of the associated token account of the recipient is not included.
+func (c *client) Fees() (coin, token *big.Int, err error) {
+
+	price, err := c.priority(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cost(price, coinUnits), cost(price, tokenUnits), nil
+}
+
+// slot - This function returns the last finalized slot of the chain.
+func (c *client) slot() (slot int64, err error) {
+	err = c.call("getSlot", []interface{}{map[string]interface{}{"commitment": "finalized"}}, &slot)
+	return slot, err
+}
+
+// decimals - This function returns the decimals of the token from the account of its mint.
+func (c *client) decimals(mint string) (int32, error) {
+
+	var (
+		result struct {
+			Value *struct {
+				Data struct {
+					Parsed struct {
+						Info struct {
+							Decimals int32 `json:"decimals"`
+						} `json:"info"`
+						Type string `json:"type"`
+					} `json:"parsed"`
+				} `json:"data"`
+			} `json:"value"`
+		}
+	)
+
+	if err := c.call("getAccountInfo", []interface{}{mint, map[string]interface{}{"encoding": "jsonParsed"}}, &result); err != nil {
+		return 0, err
+	}
+
+	if result.Value == nil || result.Value.Data.Parsed.Type != "mint" {
+		return 0, fmt.Errorf("the account %v is not the mint of a token", mint)
+	}
+
+	return result.Value.Data.Parsed.Info.Decimals, nil
+}
+
+// data - This function returns the data of the account, nil when the account does not exist.
+func (c *client) data(address string) ([]byte, error) {
+
+	var (
+		result struct {
+			Value *struct {
+				Data []string `json:"data"`
+			} `json:"value"`
+		}
+	)
+
+	if err := c.call("getAccountInfo", []interface{}{address, map[string]interface{}{"encoding": "base64"}}, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Value == nil || len(result.Value.Data) == 0 {
+		return nil, nil
+	}
+
+	return base64.StdEncoding.DecodeString(result.Value.Data[0])
+}
+
+// priority - This function returns the priority fee of the transfer in micro-lamports per compute unit: the percentile of the
+// priority fees paid by the recent transactions that locked the accounts, no more than the max price.
+func (c *client) priority(accounts []string) (*big.Int, error) {
+
+	var (
+		result []struct {
+			PrioritizationFee int64 `json:"prioritizationFee"`
+		}
+		params []interface{}
+	)
+
+	if len(accounts) > 0 {
+		params = append(params, accounts)
+	}
+
+	if err := c.call("getRecentPrioritizationFees", params, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return new(big.Int), nil
+	}
+
+	fees := make([]int64, len(result))
+	for i, item := range result {
+		fees[i] = item.PrioritizationFee
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+
+	price := fees[(len(fees)-1)*percentile/100]
+	if price > maxPrice {
+		price = maxPrice
+	}
+
+	return big.NewInt(price), nil
+}
+
+// call - This function sends the request of the json-rpc with the method and the params to the node and reads the result into
+// the result. The request is recorded as the span of the trace of the context of the client, the header of the span is
+// passed to the node.
+func (c *client) call(method string, params []interface{}, result interface{}) (err error) {
+
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	request, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.rpc, bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.ctx != nil {
+		ctx, span := trace.Start(c.ctx, "chain "+method, trace.KindClient, trace.String("rpc.system", types.PlatformSolana), trace.String("rpc.method", method))
+		defer func() { span.End(err) }()
+
+		if traceparent := trace.Traceparent(ctx); len(traceparent) > 0 {
+			req.Header.Set("traceparent", traceparent)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var (
+		response struct {
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+	)
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return errors.Errorf("the node responded with the status %v!...", resp.StatusCode)
+	}
+
+	if response.Error != nil {
+		return errors.Errorf("%v (%v)", response.Error.Message, response.Error.Code)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
+
+// cost - This function returns the cost of the transaction with the price of the compute units and the limit of them in lamports,
+// the base fee of the signature and the priority fee rounded up.
+func cost(price *big.Int, units int64) *big.Int {
+	fee := new(big.Int).Mul(price, big.NewInt(units))
+	fee.Add(fee, big.NewInt(999999))
+	fee.Div(fee, big.NewInt(1000000))
+	return fee.Add(fee, big.NewInt(base))
+}
+
+// amount - This function reads the non-negative amount of the smallest units written in decimal.
+func amount(src string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(src, 10)
+	if !ok || value.Sign() < 0 {
+		return new(big.Int), fmt.Errorf("the amount %v is not a number", src)
+	}
+	return value, nil
+}
@@ -0,0 +1,396 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/pkg/errors"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+type (
+	// meta - The account of an instruction, whether the account signs the transaction and whether the instruction writes it.
+	meta struct {
+		key              []byte
+		signer, writable bool
+	}
+
+	// call - The instruction of the transaction: the program, the accounts and the data the program is called with.
+	call struct {
+		program  []byte
+		accounts []meta
+		data     []byte
+	}
+)
+
+// Estimate - This function returns the cost of the transfer of the amount to the address in lamports: the base fee, the priority
+// fee of the compute units of the transfer and, for the token of the contract, the rent of the associated token account
+// of the recipient when the account does not exist yet. The priority fee is kept for the transfer that is sent next.
+func (c *client) Estimate(key *platform.Key, to, contract string, amount *big.Int) (*big.Int, error) {
+
+	instructions, units, err := c.instructions(key, to, contract, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.price, err = c.priority(writable(instructions)); err != nil {
+		return nil, err
+	}
+
+	fee := cost(c.price, units)
+	if len(contract) == 0 {
+		return fee, nil
+	}
+
+	ata, err := associated(decode(to), decode(contract))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.data(encode(ata))
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+
+		var (
+			rent int64
+		)
+
+		if err := c.call("getMinimumBalanceForRentExemption", []interface{}{account}, &rent); err != nil {
+			return nil, err
+		}
+		fee.Add(fee, big.NewInt(rent))
+	}
+
+	return fee, nil
+}
+
+// Send - This function builds the transaction of the transfer of the amount to the address with the limit and the price of the
+// compute units, signs it with the key, broadcasts it and returns its signature. The transfer of the token creates the
+// associated token account of the recipient when it does not exist yet. The requests of the node are recorded in the
+// trace of the context.
+func (c *client) Send(ctx context.Context, key *platform.Key, to, contract string, amount *big.Int) (hash string, err error) {
+
+	c.ctx = ctx
+	defer func() { c.ctx = nil }()
+
+	instructions, units, err := c.instructions(key, to, contract, amount)
+	if err != nil {
+		return hash, err
+	}
+
+	price := c.price
+	if price == nil {
+		if price, err = c.priority(writable(instructions)); err != nil {
+			return hash, err
+		}
+	}
+
+	var (
+		latest struct {
+			Value struct {
+				Blockhash string `json:"blockhash"`
+			} `json:"value"`
+		}
+	)
+
+	if err := c.call("getLatestBlockhash", []interface{}{map[string]interface{}{"commitment": "finalized"}}, &latest); err != nil {
+		return hash, err
+	}
+
+	blockhash := decode(latest.Value.Blockhash)
+	if blockhash == nil {
+		return hash, errors.New("the blockhash of the chain is not correct")
+	}
+
+	limit, fee := make([]byte, 5), make([]byte, 9)
+	limit[0], fee[0] = 2, 3
+	binary.LittleEndian.PutUint32(limit[1:], uint32(units))
+	binary.LittleEndian.PutUint64(fee[1:], price.Uint64())
+
+	message := compile(decode(key.Address), blockhash, append([]call{
+		{program: computeProgram, data: limit},
+		{program: computeProgram, data: fee},
+	}, instructions...))
+
+	sign, err := c.sign(key, message)
+	if err != nil {
+		return hash, err
+	}
+
+	// The transaction is the list of the signatures, one of the payer, followed by the message.
+	tx := append(append(shortvec(1), sign...), message...)
+
+	if err := c.call("sendTransaction", []interface{}{base64.StdEncoding.EncodeToString(tx), map[string]interface{}{"encoding": "base64", "preflightCommitment": "confirmed"}}, &hash); err != nil {
+		return hash, err
+	}
+	c.price = nil
+
+	return hash, nil
+}
+
+// instructions - This function returns the instructions of the transfer of the amount from the address of the key to the address
+// and the compute units the transfer is limited to: the transfer of the system program for SOL, the creation of the
+// associated token account of the recipient, which does nothing when the account exists, and the checked transfer of
+// the token program between the associated token accounts for the token of the contract.
+func (c *client) instructions(key *platform.Key, to, contract string, amount *big.Int) ([]call, int64, error) {
+
+	owner, recipient := decode(key.Address), decode(to)
+	if owner == nil || recipient == nil {
+		return nil, 0, errors.New("the address of the transfer is not correct")
+	}
+
+	if !amount.IsUint64() {
+		return nil, 0, errors.New("the amount of the transfer is not correct")
+	}
+
+	if len(contract) == 0 {
+
+		data := make([]byte, 12)
+		binary.LittleEndian.PutUint32(data, 2)
+		binary.LittleEndian.PutUint64(data[4:], amount.Uint64())
+
+		return []call{
+			{program: systemProgram, accounts: []meta{{key: owner, signer: true, writable: true}, {key: recipient, writable: true}}, data: data},
+		}, coinUnits, nil
+	}
+
+	mint := decode(contract)
+	if mint == nil {
+		return nil, 0, errors.New("the address of the mint is not correct")
+	}
+
+	decimals, err := c.decimals(contract)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	source, err := associated(owner, mint)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	destination, err := associated(recipient, mint)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data := make([]byte, 10)
+	data[0] = 12
+	binary.LittleEndian.PutUint64(data[1:], amount.Uint64())
+	data[9] = byte(decimals)
+
+	return []call{
+		{program: associatedProgram, accounts: []meta{
+			{key: owner, signer: true, writable: true},
+			{key: destination, writable: true},
+			{key: recipient},
+			{key: mint},
+			{key: systemProgram},
+			{key: tokenProgram},
+		}, data: []byte{1}},
+		{program: tokenProgram, accounts: []meta{
+			{key: source, writable: true},
+			{key: mint},
+			{key: destination, writable: true},
+			{key: owner, signer: true},
+		}, data: data},
+	}, tokenUnits, nil
+}
+
+// sign - This function signs the message with the private key of the key, or with the external signing service of the key. The
+// signature of the service is verified with the address of the key before it is used.
+func (c *client) sign(key *platform.Key, message []byte) (signature []byte, err error) {
+
+	public := ed25519.PublicKey(decode(key.Address))
+
+	if key.Signer == nil {
+
+		private, err := hex.DecodeString(strings.TrimPrefix(key.Private, "0x"))
+		if err != nil || len(private) != ed25519.PrivateKeySize {
+			return nil, errors.New("the private key of the transfer is not correct")
+		}
+
+		if !bytes.Equal(ed25519.PrivateKey(private).Public().(ed25519.PublicKey), public) {
+			return nil, errors.New("the private key does not match the address of the transfer")
+		}
+
+		return ed25519.Sign(private, message), nil
+	}
+
+	var (
+		response []byte
+	)
+
+	request, err := json.Marshal(struct {
+		Platform string `json:"platform"`
+		Address  string `json:"address"`
+		Digest   string `json:"digest"`
+	}{
+		Platform: types.PlatformSolana,
+		Address:  key.Address,
+		Digest:   "0x" + hex.EncodeToString(message),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The request and the response are handed to the audit whatever the result of the signing is.
+	defer func() {
+		if key.Signer.Audit != nil {
+			key.Signer.Audit(request, response, err)
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, key.Signer.Endpoint, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key.Signer.Token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if response, err = io.ReadAll(resp.Body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("the signing service responded with the status %v!...", resp.StatusCode)
+	}
+
+	var (
+		result struct {
+			Signature string `json:"signature"`
+		}
+	)
+
+	if err = json.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	if signature, err = hex.DecodeString(strings.TrimPrefix(result.Signature, "0x")); err != nil {
+		return nil, err
+	}
+
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(public, message, signature) {
+		return nil, errors.New("the signature of the signing service does not match the address!...")
+	}
+
+	return signature, nil
+}
+
+// compile - This function compiles the legacy message of the transaction: the header, the accounts of the instructions with the
+// payer first, then the other signers, the writable accounts and the read-only accounts, the recent blockhash and the
+// instructions with the indexes of their programs and accounts.
+func compile(payer, blockhash []byte, instructions []call) []byte {
+
+	var (
+		keys    []meta
+		indexes = make(map[string]int)
+	)
+
+	add := func(item meta) {
+		if i, ok := indexes[string(item.key)]; ok {
+			keys[i].signer = keys[i].signer || item.signer
+			keys[i].writable = keys[i].writable || item.writable
+			return
+		}
+		indexes[string(item.key)] = len(keys)
+		keys = append(keys, item)
+	}
+
+	add(meta{key: payer, signer: true, writable: true})
+	for _, instruction := range instructions {
+		for _, item := range instruction.accounts {
+			add(item)
+		}
+		add(meta{key: instruction.program})
+	}
+
+	var (
+		ordered []meta
+		header  [3]byte
+	)
+
+	for _, group := range [][2]bool{{true, true}, {true, false}, {false, true}, {false, false}} {
+		for _, item := range keys {
+			if item.signer == group[0] && item.writable == group[1] {
+				ordered = append(ordered, item)
+			}
+		}
+	}
+
+	for i, item := range ordered {
+		indexes[string(item.key)] = i
+		switch {
+		case item.signer && item.writable:
+			header[0]++
+		case item.signer:
+			header[0]++
+			header[1]++
+		case !item.writable:
+			header[2]++
+		}
+	}
+
+	message := append(header[:], shortvec(len(ordered))...)
+	for _, item := range ordered {
+		message = append(message, item.key...)
+	}
+	message = append(message, blockhash...)
+
+	message = append(message, shortvec(len(instructions))...)
+	for _, instruction := range instructions {
+		message = append(message, byte(indexes[string(instruction.program)]))
+		message = append(message, shortvec(len(instruction.accounts))...)
+		for _, item := range instruction.accounts {
+			message = append(message, byte(indexes[string(item.key)]))
+		}
+		message = append(message, shortvec(len(instruction.data))...)
+		message = append(message, instruction.data...)
+	}
+
+	return message
+}
+
+// writable - This function returns the addresses of the accounts the instructions write, the priority fees are those paid for
+// the locks of these accounts.
+func writable(instructions []call) (addresses []string) {
+	for _, instruction := range instructions {
+		for _, item := range instruction.accounts {
+			if item.writable {
+				addresses = append(addresses, encode(item.key))
+			}
+		}
+	}
+	return addresses
+}
+
+// shortvec - This function encodes the length in the compact form of the messages, seven bits in a byte with the high bit set
+// when more bytes follow.
+func shortvec(length int) (data []byte) {
+	for {
+		item := byte(length & 0x7f)
+		length >>= 7
+		if length == 0 {
+			return append(data, item)
+		}
+		data = append(data, item|0x80)
+	}
+}
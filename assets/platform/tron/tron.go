@@ -0,0 +1,156 @@
+// Package tron is the adapter of the Tron platform, the TRC-20 tokens are the tokens of its chains.
+package tron
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/blockchain"
+	"github.com/cryptogateway/backend-envoys/assets/common/address"
+	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
+	"github.com/cryptogateway/backend-envoys/assets/common/siwe"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// transfer - The topic of the Transfer(address,address,uint256) event the tokens emit on their transfers, the http api of Tron
+// returns the topics without the 0x prefix.
+var transfer = strings.TrimPrefix(crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex(), "0x")
+
+func init() {
+	platform.Register(adapter{})
+}
+
+// adapter - The adapter of the Tron platform, the addresses are recorded in Base58Check.
+type adapter struct{}
+
+// client - The client of the http api of a node of the platform, the requests of the node are those of the blockchain package.
+type client struct {
+	*blockchain.Params
+}
+
+// Name - This function returns the name of the platform.
+func (adapter) Name() string {
+	return types.PlatformTron
+}
+
+// Derive - This function derives the address and the private key of the user with the path m/44'/195'/0'/0/index.
+func (adapter) Derive(secret string, entropy []byte, index uint32) (string, string, error) {
+	var cross keypair.CrossChain
+	return cross.Derive(secret, entropy, types.PlatformTron, index)
+}
+
+// Validate - This function validates the address and its Base58Check checksum.
+func (adapter) Validate(address string) error {
+	return keypair.ValidateCryptoAddress(address, types.PlatformTron)
+}
+
+// Format - This function returns the address in Base58Check.
+func (adapter) Format(src string) string {
+	return address.New(src).Base58()
+}
+
+// Verify - This function checks that the message was signed by the key of the address with signMessageV2 of the Tron wallets,
+// the keccak256 of the prefix of TIP-191, the length of the message and the message.
+func (adapter) Verify(src, message, signature string) error {
+
+	public, err := siwe.RecoverPublic(crypto.Keccak256([]byte(fmt.Sprintf("\x19TRON Signed Message:\n%d%v", len(message), message))), signature)
+	if err != nil {
+		return err
+	}
+
+	if address.New(crypto.PubkeyToAddress(*public).Bytes()).Base58() != address.New(src).Base58() {
+		return errors.New("the message is not signed by the address")
+	}
+
+	return nil
+}
+
+// Dial - This function returns the client of the http api of the node.
+func (adapter) Dial(rpc string) (platform.Client, error) {
+
+	params, err := blockchain.Dial(rpc, types.PlatformTron)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{params}, nil
+}
+
+// Scan - This function reads the block at the height and returns the transfers of TRX of its TransferContract transactions and
+// the Transfer events of the TRC-20 tokens of its TriggerSmartContract transactions. The block that is not produced
+// yet, or whose logs are not read, is returned with the error, so that it is read again.
+func (c *client) Scan(height int64) (transfers []*platform.Transfer, err error) {
+
+	// The panic of the parsing of the block is returned as the error, the block is read again.
+	defer func() {
+		if r := recover(); r != nil {
+			transfers, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+
+	block, err := c.BlockByNumber(height)
+	if err != nil { // No debug....
+		return nil, fmt.Errorf("waiting for the block %v", height)
+	}
+
+	for _, tx := range block.Transactions {
+
+		switch tx.Type {
+		case blockchain.TypeInternal: // TRX parse transfer coin.
+
+			// The amount of the transfer of the coin is the number of the sun, the api returns it as a json number.
+			value, err := strconv.ParseFloat(tx.Value, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			amount, _ := new(big.Float).SetFloat64(value).Int(nil)
+			if amount.Sign() <= 0 {
+				continue
+			}
+
+			transfers = append(transfers, &platform.Transfer{
+				Hash:      tx.Hash,
+				From:      address.New(tx.From).Base58(),
+				To:        address.New(tx.To).Base58(),
+				Amount:    amount,
+				Block:     height,
+				BlockHash: block.Hash,
+			})
+
+		case blockchain.TypeContract: // Smart contract trigger transfer token.
+
+			logs, err := c.LogByTx(tx.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			// The transfer of the token is the Transfer event with the sender and the recipient in the indexed topics and the
+			// amount in the data of the log.
+			if logs.Data == nil || len(logs.Topics) != 3 || logs.Topics[0] != transfer {
+				continue
+			}
+
+			amount, err := logs.Amount()
+			if err != nil || amount.Sign() <= 0 {
+				continue
+			}
+
+			transfers = append(transfers, &platform.Transfer{
+				Hash:      tx.Hash,
+				From:      address.New(logs.Topics[1].(string)).Base58(),
+				To:        address.New(logs.Topics[2].(string)).Base58(),
+				Contract:  address.New(tx.To).Base58(),
+				Amount:    amount,
+				Block:     height,
+				BlockHash: block.Hash,
+			})
+		}
+	}
+
+	return transfers, nil
+}
@@ -0,0 +1,118 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/i18n"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+)
+
+// restriction - The restriction struct is an active restriction of an account, as it is cached.
+type restriction struct {
+	Kind, Reason string
+}
+
+// RestrictionKey - This function returns the key of redis the active restrictions of the account are cached under, the key is
+// removed by the compliance officers every time they apply or lift a restriction of the account.
+func RestrictionKey(userId int64) string {
+	return fmt.Sprintf("restrictions:%v", userId)
+}
+
+// KillKey - This function returns the key of redis the kill switch of the account is kept under, the key holds the time the kill
+// switch expires at and lives until then, the user removes it to turn the kill switch off earlier.
+func KillKey(userId int64) string {
+	return fmt.Sprintf("kill:%v", userId)
+}
+
+// Restricted - This function checks whether the account is allowed the action, such as the trading or the withdrawal, by its
+// active restrictions: the trade ban forbids the trading, the withdraw ban the withdrawals, the deposit-only account can
+// only deposit and the frozen account can do nothing. The kill switch of the user forbids the trading as well. The error
+// carries the kind and the reason code of the restriction. The restrictions are read from redis, and from the database
+// when they are not cached, a failure to read them forbids the action, since a restriction is a compliance obligation of
+// the exchange.
+func (app *Context) Restricted(userId int64, action string) error {
+
+	// The kill switch the user turned on stops the trading of the account in the same way as the trade ban, until it expires.
+	if action == types.ActionTrade {
+		if expire, err := app.RedisClient.Get(context.Background(), KillKey(userId)).Result(); err == nil {
+			return i18n.Errorf(11808, "the trading is stopped by the kill switch of your account until %v", expire)
+		} else if !errors.Is(err, redis.Nil) {
+			return i18n.Messagef("11752.unavailable", 11752, "the %v can not be checked against the restrictions of your account, please try again later", action)
+		}
+	}
+
+	restrictions, err := app.restrictions(userId)
+	if err != nil {
+		return i18n.Messagef("11752.unavailable", 11752, "the %v can not be checked against the restrictions of your account, please try again later", action)
+	}
+
+	for _, item := range restrictions {
+		if types.Restricts(item.Kind, action) {
+			return i18n.Errorf(11752, "the %v is not allowed for your account, it is restricted (%v, %v), please contact technical support for any questions", action, item.Kind, item.Reason)
+		}
+	}
+
+	return nil
+}
+
+// restrictions - This function returns the active restrictions of the account, they are cached for a minute, the restrictions
+// applied or lifted by the officers remove the cache themselves.
+func (app *Context) restrictions(userId int64) (restrictions []restriction, err error) {
+
+	if data, err := app.RedisClient.Get(context.Background(), RestrictionKey(userId)).Bytes(); err == nil && json.Unmarshal(data, &restrictions) == nil {
+		return restrictions, nil
+	}
+
+	rows, err := app.Db.Query("select kind, reason from restrictions where user_id = $1 and status = true and (expire_at is null or expire_at > now())", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item restriction
+		)
+
+		if err := rows.Scan(&item.Kind, &item.Reason); err != nil {
+			return nil, err
+		}
+
+		restrictions = append(restrictions, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(restrictions); err == nil {
+		app.Debug(app.RedisClient.Set(context.Background(), RestrictionKey(userId), data, time.Minute).Err())
+	}
+
+	return restrictions, nil
+}
+
+// UnaryRestrict - This function returns the interceptor that checks the restrictions of the account before the methods that trade,
+// withdraw, transfer or deposit the funds, the actions of the methods are given by their full names. The requests without
+// the user are left to the handlers, the public methods among them refuse the requests that need the user themselves.
+func (app *Context) UnaryRestrict(actions map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if action, ok := actions[info.FullMethod]; ok {
+			if user := app.User(ctx); user > 0 {
+				if err := app.Restricted(user, action); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,104 @@
+package assets
+
+import (
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"net/url"
+	"strings"
+)
+
+var (
+	// production - The network ids of the main networks of the chains compatible with Ethereum, the sandbox is never connected to them.
+	production = map[int64]string{
+		1:         "Ethereum",
+		10:        "Optimism",
+		56:        "BNB Smart Chain",
+		100:       "Gnosis",
+		137:       "Polygon",
+		250:       "Fantom",
+		324:       "zkSync Era",
+		8453:      "Base",
+		42161:     "Arbitrum One",
+		42220:     "Celo",
+		43114:     "Avalanche",
+		59144:     "Linea",
+		728126428: "Tron",
+	}
+
+	// hosts - The hosts of the public nodes of the main networks, the chains of Tron and Solana are not told apart by the network id.
+	hosts = map[string]string{
+		"api.trongrid.io":             "Tron",
+		"api.mainnet-beta.solana.com": "Solana",
+		"bsc-dataseed.binance.org":    "BNB Smart Chain",
+		"mainnet.infura.io":           "Ethereum",
+		"cloudflare-eth.com":          "Ethereum",
+	}
+)
+
+// Production - This function reports whether the network id or the node of the chain is that of a main network and returns the
+// name of the network.
+func Production(network int64, rpc string) (string, bool) {
+
+	if name, ok := production[network]; ok {
+		return name, ok
+	}
+
+	if u, err := url.Parse(rpc); err == nil {
+		if name, ok := hosts[strings.ToLower(u.Hostname())]; ok {
+			return name, ok
+		}
+	}
+
+	return "", false
+}
+
+// sandbox - This function connects the chains of the sandbox to the nodes and the network ids of their testnets, and guards the
+// sandbox against the production chains: the error is returned when an enabled chain of a blockchain platform is not
+// connected to a testnet by the configuration or runs on a main network, the process does not start then.
+func (app *Context) sandbox() error {
+
+	var (
+		chains = make(map[int64]bool)
+	)
+
+	for _, chain := range app.Sandbox.Chains {
+
+		if _, err := app.Db.Exec("update chains set rpc = $2, network = $3 where id = $1", chain.ChainId, chain.Rpc, chain.Network); err != nil {
+			return err
+		}
+		chains[chain.ChainId] = true
+	}
+
+	rows, err := app.Db.Query("select id, network, platform, rpc from chains where status")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			id, network int64
+			name, rpc   string
+		)
+
+		if err := rows.Scan(&id, &network, &name, &rpc); err != nil {
+			return err
+		}
+
+		// The chains of the card gateways and of the platforms without the adapter are not dialed by the exchange.
+		if _, ok := platform.Lookup(name); !ok {
+			continue
+		}
+
+		if !chains[id] {
+			return fmt.Errorf("the sandbox does not start: the chain %v is enabled but not connected to a testnet by Sandbox.Chains", id)
+		}
+
+		if main, ok := Production(network, rpc); ok {
+			return fmt.Errorf("the sandbox does not start: the chain %v runs on the main network of %v", id, main)
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,107 @@
+package assets
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// AccessLifetime and RefreshLifetime - The lifetimes of the tokens of the sessions: the access token is short, so that a revoked
+	// session ends soon even for the services which do not check the revocation, and the refresh token is replaced by a new
+	// one every time it is used.
+	AccessLifetime  = 15 * time.Minute
+	RefreshLifetime = 24 * time.Hour
+)
+
+// RevokeKey - This function returns the key of redis the revocation of the session family is kept under. The family is the chain of
+// the refresh tokens issued by the rotation since the sign in, the revocation is kept as long as a token of the family
+// can live, so that neither the access tokens nor the refresh tokens of the family are accepted any longer.
+func RevokeKey(family string) string {
+	return fmt.Sprintf("session:revoke:%v", family)
+}
+
+// revoked - This function checks that the session family the access token was issued for is not revoked, the tokens issued
+// before the session families were introduced carry no family and live out their short lifetime.
+func (app *Context) revoked(ctx context.Context, claims jwt.MapClaims) error {
+
+	family, ok := claims["sid"].(string)
+	if !ok || len(family) == 0 {
+		return nil
+	}
+
+	if count, err := app.RedisClient.Exists(ctx, RevokeKey(family)).Result(); err == nil && count > 0 {
+		return status.Error(10018, "the session is revoked")
+	}
+
+	return nil
+}
+
+// SigningSecret - This function returns the secret the client signs the requests of the session family with. The secret is derived
+// from the secret of the server and the family, so it is not stored, it is returned to the client with the tokens of the
+// sign in and of the rotation, and it is never sent with the requests. The tokens without the family have no secret.
+func (app *Context) SigningSecret(family string) string {
+	if len(family) == 0 {
+		return ""
+	}
+	return help.SignatureHmac256(app.Secrets[0], "signing", family)
+}
+
+// family - This function returns the session family of the bearer token, the token is parsed without the verification, it is
+// called once the token was verified by the Auth function.
+func family(bearer string) string {
+
+	token, _, err := jwt.NewParser().ParseUnverified(bearer, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	sid, _ := claims["sid"].(string)
+
+	return sid
+}
+
+// WalletKey - This function returns the key of redis the account the external wallet with the address is linked to is cached under.
+// The key is removed when the wallet is unlinked, so that the sessions of the wallet end with the link.
+func WalletKey(address string) string {
+	return fmt.Sprintf("wallet:link:%v", strings.ToLower(address))
+}
+
+// linked - This function checks that the wallet the access token was signed in with is still linked to the account of the token,
+// the sessions of the wallet end when the wallet is unlinked or linked to another account. The tokens of the password
+// sign ins carry no wallet. The link is cached in redis, the database is asked when the cache has expired.
+func (app *Context) linked(ctx context.Context, claims jwt.MapClaims) error {
+
+	wallet, ok := claims["wal"].(string)
+	if !ok || len(wallet) == 0 {
+		return nil
+	}
+
+	subject, _ := claims["sub"].(float64)
+
+	user, err := app.RedisClient.Get(ctx, WalletKey(wallet)).Int64()
+	if err != nil {
+
+		if err := app.Db.QueryRow("select user_id from account_wallets where lower(address) = lower($1)", wallet).Scan(&user); err != nil {
+			if err == sql.ErrNoRows {
+				return status.Error(10020, "the wallet of the session is no longer linked to the account")
+			}
+			return err
+		}
+
+		app.Debug(app.RedisClient.Set(ctx, WalletKey(wallet), user, RefreshLifetime).Err())
+	}
+
+	if user != int64(subject) {
+		return status.Error(10020, "the wallet of the session is no longer linked to the account")
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+create table if not exists public.snapshots
+(
+    id         serial
+        constraint snapshots_pk
+            primary key,
+    user_id    integer,
+    value      numeric(32, 18)          default 0.000000000000000000       not null,
+    unit       varchar                  default 'usd'::character varying   not null,
+    resolution varchar                  default 'day'::character varying   not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP          not null
+);
+
+alter table public.snapshots
+    owner to envoys;
+
+create index if not exists snapshots_user_id_resolution_create_at_idx
+    on public.snapshots (user_id, resolution, create_at desc);
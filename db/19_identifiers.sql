@@ -0,0 +1,17 @@
+alter table public.orders
+    add column if not exists uid uuid default gen_random_uuid() not null;
+
+create unique index if not exists orders_uid_uindex
+    on public.orders (uid);
+
+alter table public.trades
+    add column if not exists uid uuid default gen_random_uuid() not null;
+
+create unique index if not exists trades_uid_uindex
+    on public.trades (uid);
+
+alter table public.transactions
+    add column if not exists uid uuid default gen_random_uuid() not null;
+
+create unique index if not exists transactions_uid_uindex
+    on public.transactions (uid);
@@ -0,0 +1,19 @@
+create table if not exists public.pushes
+(
+    id        serial
+        constraint pushes_pk
+            primary key,
+    user_id   integer,
+    channel   varchar,
+    data      jsonb                    default '{}'::jsonb   not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.pushes
+    owner to envoys;
+
+create index if not exists pushes_user_id_id_idx
+    on public.pushes (user_id, id);
+
+create index if not exists pushes_create_at_idx
+    on public.pushes (create_at);
@@ -0,0 +1,26 @@
+alter table public.accounts
+    add column if not exists priority boolean default false not null;
+
+create table if not exists public.support
+(
+    id         serial
+        constraint support_pk
+            primary key,
+    user_id    integer,
+    kind       varchar,
+    reference  integer                  default 0                             not null,
+    status     varchar                  default 'pending'::character varying not null,
+    level      integer                  default 0                             not null,
+    deadline   timestamp with time zone                                       not null,
+    resolve_at timestamp with time zone,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP             not null
+);
+
+alter table public.support
+    owner to envoys;
+
+create unique index if not exists support_kind_reference_uindex
+    on public.support (kind, reference);
+
+create index if not exists support_status_deadline_idx
+    on public.support (status, deadline);
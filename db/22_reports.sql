@@ -0,0 +1,21 @@
+create table if not exists public.reports
+(
+    id           serial
+        constraint reports_pk
+            primary key,
+    jurisdiction varchar                                                       not null,
+    day          date                                                          not null,
+    version      integer                  default 1                            not null,
+    name         varchar                  default ''::character varying        not null,
+    count        integer                  default 0                            not null,
+    data         bytea,
+    status       varchar                  default 'pending'::character varying not null,
+    error        varchar                  default ''::character varying        not null,
+    create_at    timestamp with time zone default CURRENT_TIMESTAMP            not null
+);
+
+alter table public.reports
+    owner to envoys;
+
+create unique index if not exists reports_jurisdiction_day_version_uindex
+    on public.reports (jurisdiction, day, version);
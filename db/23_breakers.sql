@@ -0,0 +1,17 @@
+alter table public.pairs
+    add column if not exists band numeric(8, 2) default 0 not null;
+
+alter table public.pairs
+    add column if not exists halt_percent numeric(8, 2) default 0 not null;
+
+alter table public.pairs
+    add column if not exists halt_window integer default 0 not null;
+
+alter table public.pairs
+    add column if not exists cooldown integer default 0 not null;
+
+alter table public.pairs
+    add column if not exists halted boolean default false not null;
+
+alter table public.pairs
+    add column if not exists halt_until timestamp with time zone;
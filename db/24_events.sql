@@ -0,0 +1,20 @@
+create table if not exists public.events
+(
+    id        serial
+        constraint events_pk
+            primary key,
+    kind      varchar                                                    not null,
+    title     varchar                  default ''::character varying     not null,
+    text      varchar                  default ''::character varying     not null,
+    symbol    varchar                  default ''::character varying     not null,
+    start_at  timestamp with time zone                                   not null,
+    end_at    timestamp with time zone,
+    status    boolean                  default false                     not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP         not null
+);
+
+alter table public.events
+    owner to envoys;
+
+create index if not exists events_start_at_idx
+    on public.events (start_at);
@@ -0,0 +1,46 @@
+create table if not exists public.watches
+(
+    id        serial
+        constraint watches_pk
+            primary key,
+    user_id   integer,
+    chain_id  integer,
+    address   varchar,
+    platform  varchar,
+    label     varchar                  default ''::character varying not null,
+    symbol    varchar                  default ''::character varying not null,
+    balance   numeric(32, 18)          default 0                     not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.watches
+    owner to envoys;
+
+create unique index if not exists watches_user_id_chain_id_address_uindex
+    on public.watches (user_id, chain_id, address);
+
+create index if not exists watches_address_platform_idx
+    on public.watches (address, platform);
+
+create table if not exists public.watch_transactions
+(
+    id         serial
+        constraint watch_transactions_pk
+            primary key,
+    watch_id   integer,
+    user_id    integer,
+    hash       varchar,
+    symbol     varchar,
+    "from"     varchar,
+    "to"       varchar,
+    value      numeric(32, 18)          default 0                     not null,
+    block      integer                  default 0                     not null,
+    assignment varchar                  default 'deposit'::character varying not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.watch_transactions
+    owner to envoys;
+
+create unique index if not exists watch_transactions_watch_id_hash_symbol_uindex
+    on public.watch_transactions (watch_id, hash, symbol);
@@ -0,0 +1,8 @@
+alter table public.pairs
+    add column if not exists min_notional numeric(32, 18) default 0 not null;
+
+alter table public.pairs
+    add column if not exists price_step numeric(32, 18) default 0 not null;
+
+alter table public.pairs
+    add column if not exists quantity_step numeric(32, 18) default 0 not null;
@@ -0,0 +1,23 @@
+create table if not exists public.divergences
+(
+    id         serial
+        constraint divergences_pk
+            primary key,
+    order_id   integer,
+    assigning  varchar,
+    base_unit  varchar,
+    quote_unit varchar,
+    type       varchar,
+    price      numeric(32, 18)          default 0                     not null,
+    value      numeric(32, 18)          default 0                     not null,
+    live       jsonb                    default '[]'::jsonb           not null,
+    shadow     jsonb                    default '[]'::jsonb           not null,
+    reason     text                     default ''::text              not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.divergences
+    owner to envoys;
+
+create index if not exists divergences_base_unit_quote_unit_index
+    on public.divergences (base_unit, quote_unit);
@@ -0,0 +1,41 @@
+create table if not exists public.delistings
+(
+    id             serial
+        constraint delistings_pk
+            primary key,
+    symbol         varchar                                                    not null,
+    quote_unit     varchar                                                    not null,
+    price          numeric(32, 18)          default 0                         not null,
+    cancel_at      timestamp with time zone                                   not null,
+    withdraw_until timestamp with time zone                                   not null,
+    status         varchar                  default 'scheduled'::character varying not null,
+    create_at      timestamp with time zone default CURRENT_TIMESTAMP         not null
+);
+
+alter table public.delistings
+    owner to envoys;
+
+create index if not exists delistings_symbol_index
+    on public.delistings (symbol);
+
+create table if not exists public.conversions
+(
+    id           serial
+        constraint conversions_pk
+            primary key,
+    delisting_id integer,
+    user_id      integer,
+    symbol       varchar,
+    type         varchar,
+    value        numeric(32, 18)          default 0                     not null,
+    quote_unit   varchar,
+    price        numeric(32, 18)          default 0                     not null,
+    converted    numeric(32, 18)          default 0                     not null,
+    create_at    timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.conversions
+    owner to envoys;
+
+create index if not exists conversions_delisting_id_index
+    on public.conversions (delisting_id);
@@ -0,0 +1,30 @@
+create table if not exists public.asset_chains
+(
+    id           serial
+        constraint asset_chains_pk
+            primary key,
+    symbol       varchar                                                not null,
+    chain_id     integer                                                not null,
+    deposit      boolean                  default true                  not null,
+    withdraw     boolean                  default true                  not null,
+    min_withdraw numeric(20, 8)           default 0                     not null,
+    fees         numeric(32, 18)          default 0                     not null,
+    create_at    timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.asset_chains
+    owner to envoys;
+
+create unique index if not exists asset_chains_symbol_chain_id_uindex
+    on public.asset_chains (symbol, chain_id);
+
+-- The chains of the assets were stored as a json array of the chain ids, they are moved into the table with the deposits
+-- and the withdrawals enabled and without overrides.
+insert into public.asset_chains (symbol, chain_id)
+select a.symbol, c.value::integer
+from public.assets a,
+     jsonb_array_elements_text(a.chains) c
+on conflict do nothing;
+
+alter table public.assets
+    drop column if exists chains;
@@ -0,0 +1,12 @@
+create table if not exists public.fee_estimates
+(
+    chain_id  integer
+        constraint fee_estimates_pk
+            primary key,
+    mainnet   numeric(32, 18)          default 0                 not null,
+    token     numeric(32, 18)          default 0                 not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.fee_estimates
+    owner to envoys;
@@ -0,0 +1,12 @@
+-- The users can have several deposit addresses per platform, every address is derived from the seed of the user with its own
+-- index, the archived addresses are not shown as the deposit address anymore, but the deposits to them are still credited.
+alter table public.wallets
+    add column if not exists derivation integer                  default 0                 not null,
+    add column if not exists archived   boolean                  default false             not null,
+    add column if not exists create_at  timestamp with time zone default CURRENT_TIMESTAMP not null;
+
+create unique index if not exists wallets_address_platform_uindex
+    on public.wallets (address, platform);
+
+create unique index if not exists wallets_user_id_platform_derivation_uindex
+    on public.wallets (user_id, platform, derivation);
@@ -0,0 +1,5 @@
+-- The stream of the chain is the websocket endpoint of its node, the scanner of the Ethereum chain subscribes to the heads of
+-- the chain with it and reads the blocks as soon as they are announced. The chains without the stream, and the chains whose
+-- subscription is lost, are polled with the rpc of the chain as before.
+alter table public.chains
+    add column if not exists stream varchar default ''::character varying not null;
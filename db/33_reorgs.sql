@@ -0,0 +1,37 @@
+-- The block hash of the deposit is the hash of the block the scanner read the deposit from, the deposits are checked against
+-- the canonical chain until they are final, since the chain may replace the block with another one of the same height.
+alter table public.transactions
+    add column if not exists block_hash varchar default ''::character varying not null;
+
+-- The reorganizations of the chains that replaced the blocks of the deposits: the block and its hash the deposit was read from,
+-- the hash of the canonical block of the same height, and the block the transaction of the deposit was located in again,
+-- zero when it is no longer mined. The status is located, the deposit waits for its confirmations from the new block,
+-- reverted, the pending deposit is no longer credited, or orphaned, the deposit was credited already and the operators
+-- were alerted.
+create table if not exists public.reorgs
+(
+    id             serial
+        constraint reorgs_pk
+            primary key,
+    chain_id       integer                                                 not null,
+    transaction_id integer                                                 not null,
+    user_id        integer                  default 0                      not null,
+    symbol         varchar                  default ''::character varying not null,
+    value          numeric(32, 18)          default 0                      not null,
+    block          bigint                                                  not null,
+    hash           varchar                                                 not null,
+    canonical      varchar                  default ''::character varying not null,
+    relocated      bigint                   default 0                      not null,
+    status         varchar                                                 not null,
+    create_at      timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.reorgs
+    owner to envoys;
+
+create index if not exists reorgs_chain_id_index
+    on public.reorgs (chain_id, status);
+
+create index if not exists transactions_block_hash_index
+    on public.transactions (chain_id, block)
+    where block_hash <> '';
@@ -0,0 +1,31 @@
+-- The endpoints of the chains are the pools of the rpc nodes of the chains: every endpoint is checked for its health, the head
+-- it reports and the latency of its answer, and the rpc of the chain is switched to the healthy endpoint with the lowest
+-- latency when the node the chain is served by fails or falls behind. The rpc of the chain is always one of its endpoints.
+create table if not exists public.chain_endpoints
+(
+    id        serial
+        constraint chain_endpoints_pk
+            primary key,
+    chain_id  integer                                                 not null,
+    rpc       varchar                                                 not null,
+    status    boolean                  default true                   not null,
+    healthy   boolean                  default false                  not null,
+    head      bigint                   default 0                      not null,
+    latency   integer                  default 0                      not null,
+    failures  integer                  default 0                      not null,
+    error     varchar                  default ''::character varying not null,
+    check_at  timestamp with time zone,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.chain_endpoints
+    owner to envoys;
+
+create unique index if not exists chain_endpoints_chain_id_rpc_uindex
+    on public.chain_endpoints (chain_id, rpc);
+
+insert into public.chain_endpoints (chain_id, rpc)
+select id, rpc
+from public.chains
+where coalesce(rpc, '') <> ''
+on conflict do nothing;
@@ -0,0 +1,45 @@
+-- The sla of the chain is the number of the minutes a withdrawal sent to the chain may wait to be mined, the withdrawal that
+-- waits longer is stuck, zero is a chain whose withdrawals are not watched. The price cap is the highest price of the gas in
+-- gwei the stuck withdrawals of the chain are sent again at, zero is a chain whose stuck withdrawals are only reported.
+alter table public.chains
+    add column if not exists sla       integer          default 0 not null,
+    add column if not exists price_cap double precision default 0 not null;
+
+-- The broadcast at is the time the withdrawal was last sent to the chain, it is cleared once the withdrawal is mined.
+alter table public.transactions
+    add column if not exists broadcast_at timestamp with time zone;
+
+create index if not exists transactions_broadcast_at_index
+    on public.transactions (chain_id, broadcast_at)
+    where broadcast_at is not null;
+
+-- The replacements of the stuck withdrawals: the transaction of the withdrawal replaced, the hash it waited with and the hash
+-- of the transaction sent in its place, the price of the gas in gwei, and the operator who asked for the replacement with
+-- the reason, zero for the replacements the exchange sent by itself. The kind is bump, the same withdrawal at a higher
+-- price sent by the exchange, replace, the same sent by an operator, or cancel, the transfer of nothing in its place. The
+-- status is pending, asked and not sent yet, processing, sent and waiting to be mined, filled, mined, cancel, another
+-- transaction of the nonce was mined, or failed, not sent with the error.
+create table if not exists public.replacements
+(
+    id             serial
+        constraint replacements_pk
+            primary key,
+    transaction_id integer                                                 not null,
+    chain_id       integer                                                 not null,
+    kind           varchar                                                 not null,
+    hash           varchar                  default ''::character varying not null,
+    replacement    varchar                  default ''::character varying not null,
+    price          double precision         default 0                      not null,
+    user_id        integer                  default 0                      not null,
+    reason         varchar                  default ''::character varying not null,
+    error          varchar                  default ''::character varying not null,
+    status         varchar                                                 not null,
+    update_at      timestamp with time zone default CURRENT_TIMESTAMP      not null,
+    create_at      timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.replacements
+    owner to envoys;
+
+create index if not exists replacements_transaction_id_index
+    on public.replacements (transaction_id, status);
@@ -0,0 +1,20 @@
+create table if not exists public.signatures
+(
+    id          serial
+        constraint signatures_pk
+            primary key,
+    chain_id    integer,
+    transfer_id integer                  default 0                     not null,
+    address     varchar,
+    endpoint    varchar                  default ''::character varying not null,
+    request     text                     default ''::text              not null,
+    response    text                     default ''::text              not null,
+    error       varchar                  default ''::character varying not null,
+    create_at   timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.signatures
+    owner to envoys;
+
+create index if not exists signatures_transfer_id_idx
+    on public.signatures (transfer_id);
@@ -0,0 +1,19 @@
+create table if not exists public.confirmations
+(
+    id           serial
+        constraint confirmations_pk
+            primary key,
+    symbol       varchar                                                not null,
+    chain_id     integer                  default 0                     not null,
+    value        numeric(32, 18)          default 0                     not null,
+    confirmation integer                                                not null,
+    create_at    timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.confirmations
+    owner to envoys;
+
+-- The tiers of the confirmations of the currency, the deposits of at least the value wait for the confirmation count, the
+-- tiers with the chain id 0 apply to every chain of the currency.
+create unique index if not exists confirmations_symbol_chain_id_value_uindex
+    on public.confirmations (symbol, chain_id, value);
@@ -0,0 +1,12 @@
+alter table public.transactions
+    add column if not exists "from" varchar default ''::character varying not null;
+
+alter table public.transactions
+    add column if not exists hold varchar default ''::character varying not null;
+
+-- The deposits of the asset on the chain of at least the hold value are held for the review of the operators, 0 disables it.
+alter table public.asset_chains
+    add column if not exists hold numeric(32, 18) default 0 not null;
+
+create index if not exists transactions_status_assignment_idx
+    on public.transactions (status, assignment);
@@ -0,0 +1,40 @@
+create table if not exists public.distributions
+(
+    id        serial
+        constraint distributions_pk
+            primary key,
+    symbol    varchar                                                not null,
+    value     numeric(32, 18)          default 0                     not null,
+    holder    varchar                  default ''::character varying not null,
+    minimum   numeric(32, 18)          default 0                     not null,
+    count     integer                  default 0                     not null,
+    processed integer                  default 0                     not null,
+    status    varchar                  default 'pending'::character varying not null,
+    user_id   integer                                                not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.distributions
+    owner to envoys;
+
+create table if not exists public.distribution_records
+(
+    id              serial
+        constraint distribution_records_pk
+            primary key,
+    distribution_id integer                                                not null,
+    user_id         integer                                                not null,
+    value           numeric(32, 18)                                        not null,
+    status          varchar                  default 'pending'::character varying not null,
+    create_at       timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.distribution_records
+    owner to envoys;
+
+-- Every user is credited at most once per distribution, whatever the csv file or the filter of the holders contains.
+create unique index if not exists distribution_records_distribution_id_user_id_uindex
+    on public.distribution_records (distribution_id, user_id);
+
+create index if not exists distribution_records_distribution_id_status_idx
+    on public.distribution_records (distribution_id, status);
@@ -0,0 +1,49 @@
+create table if not exists public.competitions
+(
+    id              serial
+        constraint competitions_pk
+            primary key,
+    title           varchar                                                not null,
+    metric          varchar                  default 'volume'::character varying not null,
+    pairs           integer[]                default '{}'::integer[]       not null,
+    quote           varchar                                                not null,
+    symbol          varchar                                                not null,
+    prize           numeric(32, 18)          default 0                     not null,
+    prizes          numeric[]                default '{}'::numeric[]       not null,
+    start_at        timestamp with time zone                               not null,
+    finish_at       timestamp with time zone                               not null,
+    status          varchar                  default 'pending'::character varying not null,
+    distribution_id integer                  default 0                     not null,
+    compute_at      timestamp with time zone,
+    create_at       timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.competitions
+    owner to envoys;
+
+create table if not exists public.rankings
+(
+    id             serial
+        constraint rankings_pk
+            primary key,
+    competition_id integer                                            not null,
+    user_id        integer                                            not null,
+    rank           integer                                            not null,
+    volume         numeric(32, 18)          default 0                 not null,
+    pnl            numeric(32, 18)          default 0                 not null,
+    prize          numeric(32, 18)          default 0                 not null,
+    create_at      timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.rankings
+    owner to envoys;
+
+create unique index if not exists rankings_competition_id_user_id_uindex
+    on public.rankings (competition_id, user_id);
+
+create index if not exists rankings_competition_id_rank_idx
+    on public.rankings (competition_id, rank);
+
+-- The rankings are computed from the trades of the users on the pairs of the competition within its period.
+create index if not exists trades_base_unit_quote_unit_create_at_idx
+    on public.trades (base_unit, quote_unit, create_at);
@@ -0,0 +1,42 @@
+create table if not exists public.rules
+(
+    id        serial
+        constraint rules_pk
+            primary key,
+    user_id   integer                                                not null,
+    kind      varchar                                                not null,
+    symbol    varchar                                                not null,
+    target    varchar                                                not null,
+    threshold numeric(32, 18)          default 0                     not null,
+    percent   numeric(8, 4)            default 0                     not null,
+    cursor    integer                  default 0                     not null,
+    status    boolean                  default true                  not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.rules
+    owner to envoys;
+
+create index if not exists rules_user_id_idx
+    on public.rules (user_id);
+
+create table if not exists public.rule_executions
+(
+    id        serial
+        constraint rule_executions_pk
+            primary key,
+    rule_id   integer                                                not null,
+    user_id   integer                                                not null,
+    order_id  integer                  default 0                     not null,
+    reference integer                  default 0                     not null,
+    value     numeric(32, 18)          default 0                     not null,
+    status    varchar                                                not null,
+    error     varchar                  default ''::character varying not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.rule_executions
+    owner to envoys;
+
+create index if not exists rule_executions_rule_id_idx
+    on public.rule_executions (rule_id, id);
@@ -0,0 +1,40 @@
+create table if not exists public.copies
+(
+    id        serial
+        constraint copies_pk
+            primary key,
+    lead_id   integer                                                not null,
+    user_id   integer                                                not null,
+    ratio     numeric(8, 4)            default 100                   not null,
+    maximum   numeric(8, 4)            default 10                    not null,
+    status    boolean                  default true                  not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.copies
+    owner to envoys;
+
+-- A follower follows the lead trader once, the ratio and the risk cap are updated on the same record.
+create unique index if not exists copies_lead_id_user_id_uindex
+    on public.copies (lead_id, user_id);
+
+create table if not exists public.copy_orders
+(
+    id            serial
+        constraint copy_orders_pk
+            primary key,
+    copy_id       integer                                                not null,
+    lead_order_id integer                                                not null,
+    order_id      integer                  default 0                     not null,
+    user_id       integer                                                not null,
+    value         numeric(32, 18)          default 0                     not null,
+    status        varchar                                                not null,
+    error         varchar                  default ''::character varying not null,
+    create_at     timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.copy_orders
+    owner to envoys;
+
+create index if not exists copy_orders_copy_id_idx
+    on public.copy_orders (copy_id, id);
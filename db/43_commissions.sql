@@ -0,0 +1,30 @@
+-- The share of the trade fees of the clients of the broker in percent, credited to the broker by the settlement job.
+alter table public.agents
+    add column if not exists commission numeric(8, 4) default 0 not null;
+
+create table if not exists public.commissions
+(
+    id        serial
+        constraint commissions_pk
+            primary key,
+    broker_id integer                                                not null,
+    user_id   integer                                                not null,
+    client_id integer                                                not null,
+    trade_id  integer                                                not null,
+    symbol    varchar                                                not null,
+    value     numeric(32, 18)                                        not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.commissions
+    owner to envoys;
+
+-- Every trade is settled once, the settlement job relies on it to be restarted safely.
+create unique index if not exists commissions_trade_id_uindex
+    on public.commissions (trade_id);
+
+create index if not exists commissions_broker_id_client_id_idx
+    on public.commissions (broker_id, client_id);
+
+create index if not exists trades_user_id_idx
+    on public.trades (user_id, id);
@@ -0,0 +1,45 @@
+alter table public.agents
+    add column if not exists details text default ''::text not null;
+
+alter table public.agents
+    add column if not exists reason varchar default ''::character varying not null;
+
+create table if not exists public.agent_documents
+(
+    id        serial
+        constraint agent_documents_pk
+            primary key,
+    agent_id  integer                                            not null,
+    kind      varchar                                            not null,
+    name      varchar                                            not null,
+    mime      varchar                                            not null,
+    data      bytea                                              not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.agent_documents
+    owner to envoys;
+
+create index if not exists agent_documents_agent_id_idx
+    on public.agent_documents (agent_id);
+
+-- Every change of the status of an agent is audited with the actor who made it.
+create table if not exists public.agent_transitions
+(
+    id        serial
+        constraint agent_transitions_pk
+            primary key,
+    agent_id  integer                                                not null,
+    previous  varchar                                                not null,
+    status    varchar                                                not null,
+    actor_id  integer                                                not null,
+    actor     varchar                                                not null,
+    reason    varchar                  default ''::character varying not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.agent_transitions
+    owner to envoys;
+
+create index if not exists agent_transitions_agent_id_idx
+    on public.agent_transitions (agent_id, id);
@@ -0,0 +1,47 @@
+create table if not exists public.corporate_actions
+(
+    id        serial
+        constraint corporate_actions_pk
+            primary key,
+    kind      varchar                                                    not null,
+    symbol    varchar                                                    not null,
+    currency  varchar                  default ''::character varying     not null,
+    value     numeric(32, 18)          default 0                         not null,
+    ratio     numeric(32, 18)          default 0                         not null,
+    target    varchar                  default ''::character varying     not null,
+    ex_at     timestamp with time zone                                   not null,
+    pay_at    timestamp with time zone                                   not null,
+    status    varchar                  default 'pending'::character varying not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP         not null
+);
+
+alter table public.corporate_actions
+    owner to envoys;
+
+create index if not exists corporate_actions_symbol_index
+    on public.corporate_actions (symbol);
+
+-- The positions of the holders of the stock at the ex date, and the cash or the shares every holder received for them.
+create table if not exists public.entitlements
+(
+    id        serial
+        constraint entitlements_pk
+            primary key,
+    action_id integer                                                    not null,
+    user_id   integer                                                    not null,
+    symbol    varchar                                                    not null,
+    quantity  numeric(32, 18)          default 0                         not null,
+    target    varchar                                                    not null,
+    value     numeric(32, 18)          default 0                         not null,
+    status    varchar                  default 'pending'::character varying not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP         not null
+);
+
+alter table public.entitlements
+    owner to envoys;
+
+create unique index if not exists entitlements_action_id_user_id_uindex
+    on public.entitlements (action_id, user_id);
+
+create index if not exists entitlements_user_id_index
+    on public.entitlements (user_id, id);
@@ -0,0 +1,49 @@
+alter table public.assets
+    add column if not exists zone varchar default ''::character varying not null;
+
+-- The trading hours of the market zones of the stocks in the local time of the zone, the stocks without a zone are traded around the clock.
+create table if not exists public.zones
+(
+    id         serial
+        constraint zones_pk
+            primary key,
+    name       varchar                                                not null,
+    timezone   varchar                                                not null,
+    pre_open   varchar                  default ''::character varying not null,
+    open       varchar                                                not null,
+    close      varchar                                                not null,
+    post_close varchar                  default ''::character varying not null,
+    days       integer[]                default '{1,2,3,4,5}'::integer[] not null,
+    queue      boolean                  default false                 not null,
+    status     boolean                  default true                  not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.zones
+    owner to envoys;
+
+create unique index if not exists zones_name_uindex
+    on public.zones (name);
+
+-- The holidays of the market zones, the half-days have the time of the early close.
+create table if not exists public.holidays
+(
+    id        serial
+        constraint holidays_pk
+            primary key,
+    zone      varchar                                                not null,
+    date      date                                                   not null,
+    close     varchar                  default ''::character varying not null,
+    title     varchar                  default ''::character varying not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.holidays
+    owner to envoys;
+
+create unique index if not exists holidays_zone_date_uindex
+    on public.holidays (zone, date);
+
+insert into public.zones (name, timezone, pre_open, open, close, post_close, days, queue)
+values ('nyse', 'America/New_York', '04:00', '09:30', '16:00', '20:00', '{1,2,3,4,5}', true)
+on conflict do nothing;
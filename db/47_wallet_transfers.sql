@@ -0,0 +1,19 @@
+-- The transfers of the funds of the users between their spot and stock sub-wallets.
+create table if not exists public.wallet_transfers
+(
+    id        serial
+        constraint wallet_transfers_pk
+            primary key,
+    user_id   integer                                            not null,
+    symbol    varchar                                            not null,
+    source    varchar                                            not null,
+    target    varchar                                            not null,
+    value     numeric(32, 18)          default 0                 not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.wallet_transfers
+    owner to envoys;
+
+create index if not exists wallet_transfers_user_id_index
+    on public.wallet_transfers (user_id, id);
@@ -0,0 +1,43 @@
+-- The funds of the users reserved by the open orders and the withdrawals which have not been sent yet, the available balance
+-- is the balance less the holds of it.
+create table if not exists public.holds
+(
+    id           serial
+        constraint holds_pk
+            primary key,
+    user_id      integer                                            not null,
+    symbol       varchar                                            not null,
+    type         varchar                                            not null,
+    value        numeric(32, 18)          default 0                 not null,
+    reference    varchar                                            not null,
+    reference_id integer                                            not null,
+    create_at    timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.holds
+    owner to envoys;
+
+create unique index if not exists holds_reference_uindex
+    on public.holds (reference, reference_id);
+
+create index if not exists holds_user_id_index
+    on public.holds (user_id, symbol, type);
+
+-- The funds of the open orders and the unsent withdrawals were taken from the balances, they are returned to the balances and
+-- held instead.
+insert into public.holds (user_id, symbol, type, value, reference, reference_id)
+select user_id, case when assigning = 'buy' then quote_unit else base_unit end, type, case when assigning = 'buy' then value * price else value end, 'order', id
+from public.orders
+where status in ('pending', 'queue') and type in ('spot', 'stock')
+on conflict do nothing;
+
+insert into public.holds (user_id, symbol, type, value, reference, reference_id)
+select user_id, symbol, 'spot', value, 'withdrawal', id
+from public.transactions
+where assignment = 'withdrawal' and allocation = 'external' and parent = 0 and status in ('pending', 'processing', 'failed')
+on conflict do nothing;
+
+update public.balances b
+set value = b.value + h.value
+from (select user_id, symbol, type, sum(value) as value from public.holds group by user_id, symbol, type) h
+where b.user_id = h.user_id and b.symbol = h.symbol and b.type = h.type;
@@ -0,0 +1,23 @@
+-- The discrepancies of the balances found by the reconciliation with the history of the deposits, withdrawals and trades, and
+-- the negative balances. The corrected drift is filled, the discrepancies raised to the operators are pending.
+create table if not exists public.reconciliations
+(
+    id         serial
+        constraint reconciliations_pk
+            primary key,
+    user_id    integer                                                 not null,
+    symbol     varchar                                                 not null,
+    type       varchar                                                 not null,
+    kind       varchar                                                 not null,
+    balance    numeric(32, 18)          default 0                      not null,
+    expected   numeric(32, 18)          default 0                      not null,
+    difference numeric(32, 18)          default 0                      not null,
+    status     varchar                  default 'pending'::character varying not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.reconciliations
+    owner to envoys;
+
+create index if not exists reconciliations_user_id_index
+    on public.reconciliations (user_id, symbol, type, kind, id);
@@ -0,0 +1,22 @@
+-- The amounts and the prices are kept in exact numeric columns with the 18 decimal places of the tokens, the floating point
+-- columns rounded the fees and the prices of the assets with small prices.
+alter table public.trades
+    alter column fees type numeric(32, 18) using coalesce(fees, 0)::numeric(32, 18),
+    alter column fees set default 0,
+    alter column price type numeric(32, 18);
+
+alter table public.transactions
+    alter column fees type numeric(32, 18),
+    alter column price type numeric(32, 18);
+
+alter table public.orders
+    alter column price type numeric(32, 18);
+
+alter table public.pairs
+    alter column price type numeric(32, 18);
+
+alter table public.chains
+    alter column fees type numeric(32, 18);
+
+alter table public.contracts
+    alter column fees type numeric(32, 18);
@@ -0,0 +1,30 @@
+-- The trigram indexes of the columns the support staff searches by parts of the value: the emails and the names of the users,
+-- the deposit addresses, the hashes and the destination addresses of the transactions. The client ids of the orders are the
+-- ids the users give to their orders themselves, they are unique per user.
+create extension if not exists pg_trgm;
+
+alter table public.orders
+    add column if not exists client_id varchar default ''::character varying not null;
+
+create unique index if not exists orders_user_id_client_id_uindex
+    on public.orders (user_id, client_id)
+    where client_id <> '';
+
+create index if not exists orders_client_id_index
+    on public.orders (client_id)
+    where client_id <> '';
+
+create index if not exists accounts_email_trgm_index
+    on public.accounts using gin (email gin_trgm_ops);
+
+create index if not exists accounts_name_trgm_index
+    on public.accounts using gin (name gin_trgm_ops);
+
+create index if not exists wallets_address_trgm_index
+    on public.wallets using gin (address gin_trgm_ops);
+
+create index if not exists transactions_hash_trgm_index
+    on public.transactions using gin (hash gin_trgm_ops);
+
+create index if not exists transactions_to_trgm_index
+    on public.transactions using gin ("to" gin_trgm_ops);
@@ -0,0 +1,22 @@
+-- The api keys the users authenticate the requests of their programs with instead of the bearer tokens. The keys are stored as
+-- their sha256 hashes, the key itself is shown to the user once, when it is created.
+create table if not exists public.api_keys
+(
+    id        serial
+        constraint api_keys_pk
+            primary key,
+    user_id   integer                                            not null,
+    name      varchar                  default ''::character varying not null,
+    secret    varchar                                            not null,
+    status    boolean                  default true              not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.api_keys
+    owner to envoys;
+
+create unique index if not exists api_keys_secret_uindex
+    on public.api_keys (secret);
+
+create index if not exists api_keys_user_id_index
+    on public.api_keys (user_id);
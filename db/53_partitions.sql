@@ -0,0 +1,102 @@
+-- The trades and the transfers are partitioned by the time of their creation into the hypertables of timescale, the chunks of the
+-- old periods are compressed and dropped by the policies of the Partitions of the configuration. The unique constraints of a
+-- hypertable must contain the column it is partitioned by, so the primary keys are extended with the time of the creation.
+alter table public.trades
+    drop constraint if exists trades_pk,
+    drop constraint if exists trades_id_key,
+    drop constraint if exists trades_id_key1,
+    drop constraint if exists trades_id_key2;
+
+drop index if exists public.trades_id_uindex;
+
+alter table public.trades
+    add constraint trades_pk
+        primary key (id, create_at);
+
+select create_hypertable('trades', 'create_at', chunk_time_interval => interval '7 days', migrate_data => true, if_not_exists => true);
+
+create index if not exists trades_base_unit_quote_unit_create_at_index
+    on public.trades (base_unit, quote_unit, create_at desc);
+
+alter table public.transfer
+    alter column create_at set not null,
+    drop constraint if exists transfer_pkey;
+
+alter table public.transfer
+    add constraint transfer_pkey
+        primary key (id, create_at);
+
+select create_hypertable('transfer', 'create_at', chunk_time_interval => interval '30 days', migrate_data => true, if_not_exists => true);
+
+alter table public.wallet_transfers
+    drop constraint if exists wallet_transfers_pk;
+
+alter table public.wallet_transfers
+    add constraint wallet_transfers_pk
+        primary key (id, create_at);
+
+select create_hypertable('wallet_transfers', 'create_at', chunk_time_interval => interval '30 days', migrate_data => true, if_not_exists => true);
+
+-- The compressed chunks are segmented by the columns the rows are read by, so that the reads of a pair or of a user
+-- decompress only their own segments.
+alter table public.ohlcv
+    set (timescaledb.compress, timescaledb.compress_segmentby = 'base_unit, quote_unit', timescaledb.compress_orderby = 'create_at desc');
+
+alter table public.trades
+    set (timescaledb.compress, timescaledb.compress_segmentby = 'base_unit, quote_unit', timescaledb.compress_orderby = 'create_at desc, id');
+
+alter table public.transfer
+    set (timescaledb.compress, timescaledb.compress_segmentby = 'user_id', timescaledb.compress_orderby = 'create_at desc, id');
+
+alter table public.wallet_transfers
+    set (timescaledb.compress, timescaledb.compress_segmentby = 'user_id', timescaledb.compress_orderby = 'create_at desc, id');
+
+-- The continuous aggregates of the ticks by the hour and by the day, the candles of the higher resolutions are aggregated from
+-- them instead of from the every tick. The sum and the count of the prices are kept so that the average price of a wider
+-- bucket is weighted the same way as from the ticks. The buckets that are not materialized yet are read from the ticks, and
+-- the last two days are refreshed again, since the gaps of the candles are backfilled for the last day.
+create materialized view if not exists public.ohlcv_1h
+    with (timescaledb.continuous, timescaledb.materialized_only = false) as
+select time_bucket('1 hour', create_at) as create_at,
+       base_unit,
+       quote_unit,
+       first(price, create_at)            as open,
+       last(price, create_at)             as close,
+       min(price)                         as low,
+       max(price)                         as high,
+       sum(quantity)                      as volume,
+       sum(price)                         as total,
+       count(*)                           as count
+from public.ohlcv
+group by 1, base_unit, quote_unit
+with no data;
+
+create materialized view if not exists public.ohlcv_1d
+    with (timescaledb.continuous, timescaledb.materialized_only = false) as
+select time_bucket('1 day', create_at) as create_at,
+       base_unit,
+       quote_unit,
+       first(price, create_at)           as open,
+       last(price, create_at)            as close,
+       min(price)                        as low,
+       max(price)                        as high,
+       sum(quantity)                     as volume,
+       sum(price)                        as total,
+       count(*)                          as count
+from public.ohlcv
+group by 1, base_unit, quote_unit
+with no data;
+
+alter materialized view public.ohlcv_1h
+    owner to envoys;
+
+alter materialized view public.ohlcv_1d
+    owner to envoys;
+
+select add_continuous_aggregate_policy('ohlcv_1h', start_offset => interval '2 days', end_offset => interval '1 hour', schedule_interval => interval '30 minutes', if_not_exists => true);
+
+select add_continuous_aggregate_policy('ohlcv_1d', start_offset => interval '3 days', end_offset => interval '1 day', schedule_interval => interval '1 hour', if_not_exists => true);
+
+call refresh_continuous_aggregate('ohlcv_1h', null, null);
+
+call refresh_continuous_aggregate('ohlcv_1d', null, null);
@@ -0,0 +1,56 @@
+-- The journal of the commands that change the book: the placements, the releases of the queued orders, the cancellations,
+-- the adjustments of the corporate actions and the matches, in the order they were executed. The state of the orders and
+-- of their fills is rebuilt from it by the replay-journal command, so the journal is append-only, its rows can not be
+-- changed or removed.
+create table if not exists public.journal
+(
+    id         bigserial
+        constraint journal_pk
+            primary key,
+    kind       varchar                                                  not null,
+    order_id   integer                                                  not null,
+    counter_id integer                  default 0                       not null,
+    assigning  varchar                  default ''::character varying  not null,
+    base_unit  varchar                  default ''::character varying  not null,
+    quote_unit varchar                  default ''::character varying  not null,
+    type       varchar                  default ''::character varying  not null,
+    user_id    integer                  default 0                       not null,
+    status     varchar                  default ''::character varying  not null,
+    price      numeric(32, 18)          default 0                       not null,
+    value      numeric(32, 18)          default 0                       not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP       not null
+);
+
+alter table public.journal
+    owner to envoys;
+
+create index if not exists journal_order_id_index
+    on public.journal (order_id);
+
+create index if not exists journal_counter_id_index
+    on public.journal (counter_id)
+    where counter_id > 0;
+
+create or replace function public.journal_append_only() returns trigger as
+$$
+begin
+    raise exception 'the journal of the book is append-only';
+end;
+$$ language plpgsql;
+
+drop trigger if exists journal_append_only on public.journal;
+
+create trigger journal_append_only
+    before update or delete or truncate
+    on public.journal
+    for each statement
+execute function public.journal_append_only();
+
+-- The orders that are in the book when the journal is created are placed in it with their remaining values, so that the
+-- replay starts from the book as it is and the later commands of these orders are applied to them.
+insert into public.journal (kind, order_id, assigning, base_unit, quote_unit, type, user_id, price, value, status)
+select 'place', id, assigning, base_unit, quote_unit, type, user_id, price, value, status
+from public.orders
+where status in ('pending', 'queue')
+  and not exists(select 1 from public.journal)
+order by id;
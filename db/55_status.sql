@@ -0,0 +1,37 @@
+-- The components of the platform report their health to the status page: the scanners of the chains with the last block they
+-- scanned, the processor of the withdrawals and the matching engine. A component that stops reporting is shown as down.
+create table if not exists public.components
+(
+    name         varchar                                                          not null
+        constraint components_pk
+            primary key,
+    kind         varchar                                                          not null,
+    status       varchar                  default 'operational'::character varying not null,
+    detail       varchar                  default ''::character varying           not null,
+    block        bigint                   default 0                               not null,
+    heartbeat_at timestamp with time zone default CURRENT_TIMESTAMP               not null
+);
+
+alter table public.components
+    owner to envoys;
+
+-- The incidents the operators attach to the status page, the active incidents are shown to the users until they are resolved.
+create table if not exists public.incidents
+(
+    id         serial
+        constraint incidents_pk
+            primary key,
+    component  varchar                  default ''::character varying     not null,
+    severity   varchar                  default 'degraded'::character varying not null,
+    title      varchar                  default ''::character varying     not null,
+    text       varchar                  default ''::character varying     not null,
+    status     boolean                  default true                      not null,
+    resolve_at timestamp with time zone,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP         not null
+);
+
+alter table public.incidents
+    owner to envoys;
+
+create index if not exists incidents_status_index
+    on public.incidents (status, id desc);
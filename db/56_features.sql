@@ -0,0 +1,22 @@
+-- The switches of the features of the exchange: the trading, the deposits, the withdrawals and the registrations can be
+-- suspended for the whole exchange or for a single currency, pair or chain, so that the operators can stop the withdrawals
+-- of one chain without a redeploy. A feature without a switch is enabled, the target of the global switches is empty, the
+-- target of the pairs is the base and the quote units joined by a slash and the target of the chains is the id of the chain.
+create table if not exists public.features
+(
+    id        serial
+        constraint features_pk
+            primary key,
+    feature   varchar                                                not null,
+    scope     varchar                  default 'global'::character varying not null,
+    target    varchar                  default ''::character varying not null,
+    status    boolean                  default false                 not null,
+    reason    varchar                  default ''::character varying not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.features
+    owner to envoys;
+
+create unique index if not exists features_feature_scope_target_uindex
+    on public.features (feature, scope, target);
@@ -0,0 +1,42 @@
+-- The announcements of the operators shown to the users as the banners, such as the notices of the maintenance and of the new
+-- listings. An announcement is shown from its start to its end, or until it is dismissed by the user, to the audience it
+-- is targeted at: all the visitors, the verified or the unverified users, the priority users or the listed users. The
+-- announcement is pushed over the broker once when it starts.
+create table if not exists public.announcements
+(
+    id        serial
+        constraint announcements_pk
+            primary key,
+    kind      varchar                                                 not null,
+    title     varchar                  default ''::character varying  not null,
+    text      varchar                  default ''::character varying  not null,
+    link      varchar                  default ''::character varying  not null,
+    audience  varchar                  default 'all'::character varying not null,
+    users     integer[]                default '{}'::integer[]        not null,
+    start_at  timestamp with time zone default CURRENT_TIMESTAMP      not null,
+    end_at    timestamp with time zone,
+    status    boolean                  default true                   not null,
+    published boolean                  default false                  not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.announcements
+    owner to envoys;
+
+create index if not exists announcements_status_start_at_index
+    on public.announcements (status, start_at desc);
+
+create table if not exists public.announcement_dismissals
+(
+    announcement_id integer                                             not null
+        constraint announcement_dismissals_announcement_id_fk
+            references public.announcements
+            on delete cascade,
+    user_id         integer                                             not null,
+    create_at       timestamp with time zone default CURRENT_TIMESTAMP not null,
+    constraint announcement_dismissals_pk
+        primary key (announcement_id, user_id)
+);
+
+alter table public.announcement_dismissals
+    owner to envoys;
@@ -0,0 +1,27 @@
+-- The delivery channels of the security codes and the alerts: the phone number the sms are sent to, the id of the chat the
+-- user started with the telegram bot, and the order the channels are tried in. The email is tried after the chosen channels
+-- when it is not chosen itself, so that a notification that can not be delivered by the other channels still reaches the user.
+alter table public.accounts
+    add column if not exists phone    varchar   default ''::character varying   not null,
+    add column if not exists telegram varchar   default ''::character varying   not null,
+    add column if not exists channels varchar[] default '{email}'::character varying[] not null;
+
+-- Every attempt to deliver a notification by a channel, the failed attempts are followed by the attempt of the next channel.
+create table if not exists public.deliveries
+(
+    id        serial
+        constraint deliveries_pk
+            primary key,
+    user_id   integer                                                not null,
+    name      varchar                                                not null,
+    channel   varchar                                                not null,
+    status    varchar                                                not null,
+    error     varchar                  default ''::character varying not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.deliveries
+    owner to envoys;
+
+create index if not exists deliveries_user_id_index
+    on public.deliveries (user_id, id desc);
@@ -0,0 +1,26 @@
+-- The requests of the users for the export of their personal data and for the closure of their accounts. The archive of the
+-- export is written to the storage and its path is kept until the archive expires. The closure is executed after the
+-- cooling-off period, when the personal data of the account is anonymized while its financial records are retained.
+create table if not exists public.privacy_requests
+(
+    id         serial
+        constraint privacy_requests_pk
+            primary key,
+    user_id    integer                                                not null,
+    kind       varchar                                                not null,
+    status     varchar                  default 'pending'::character varying not null,
+    path       varchar                  default ''::character varying not null,
+    reason     varchar                  default ''::character varying not null,
+    execute_at timestamp with time zone default CURRENT_TIMESTAMP     not null,
+    process_at timestamp with time zone,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.privacy_requests
+    owner to envoys;
+
+create index if not exists privacy_requests_user_id_index
+    on public.privacy_requests (user_id, id desc);
+
+create index if not exists privacy_requests_status_index
+    on public.privacy_requests (status, execute_at);
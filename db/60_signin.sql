@@ -0,0 +1,10 @@
+-- The location of the sign ins and the risk they were scored with: the country and the coordinates of the address, which the
+-- next sign ins of the user are compared with to detect a new country or an impossible travel.
+alter table public.actions
+    add column if not exists country   varchar          default ''::character varying not null,
+    add column if not exists latitude  double precision default 0                     not null,
+    add column if not exists longitude double precision default 0                     not null,
+    add column if not exists risk      integer          default 0                     not null;
+
+create index if not exists actions_user_id_index
+    on public.actions (user_id, id desc);
@@ -0,0 +1,23 @@
+-- The enforcement flags of the accounts applied by the compliance officers: the kind of the restriction, the reason code and the
+-- note of the officer, and the time the restriction expires at, the restrictions without it last until they are lifted.
+create table if not exists public.restrictions
+(
+    id          serial
+        constraint restrictions_pk
+            primary key,
+    user_id     integer                                                not null,
+    kind        varchar                                                not null,
+    reason      varchar                                                not null,
+    note        varchar                  default ''::character varying not null,
+    operator_id integer                  default 0                     not null,
+    status      boolean                  default true                  not null,
+    expire_at   timestamp with time zone,
+    lift_at     timestamp with time zone,
+    create_at   timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.restrictions
+    owner to envoys;
+
+create index if not exists restrictions_user_id_index
+    on public.restrictions (user_id, status);
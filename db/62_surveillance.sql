@@ -0,0 +1,34 @@
+-- The cases of the market surveillance queued for the review of the compliance officers: the kind of the pattern, the account it
+-- is flagged on and the accounts related to it, the pair, the score the cases are ranked by and the snapshot of the trades
+-- and the orders the pattern was flagged from. The fingerprint keeps the same pattern from being queued twice.
+create table if not exists public.surveillance_cases
+(
+    id          serial
+        constraint surveillance_cases_pk
+            primary key,
+    kind        varchar                                                 not null,
+    user_id     integer                                                 not null,
+    related     integer[]                default '{}'::integer[]        not null,
+    base_unit   varchar                                                 not null,
+    quote_unit  varchar                                                 not null,
+    score       numeric(32, 18)          default 0                      not null,
+    evidence    jsonb                    default '{}'::jsonb            not null,
+    fingerprint varchar                                                 not null,
+    status      varchar                  default 'review'::character varying not null,
+    note        varchar                  default ''::character varying  not null,
+    operator_id integer                  default 0                      not null,
+    review_at   timestamp with time zone,
+    create_at   timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.surveillance_cases
+    owner to envoys;
+
+create unique index if not exists surveillance_cases_fingerprint_uindex
+    on public.surveillance_cases (fingerprint);
+
+create index if not exists surveillance_cases_status_index
+    on public.surveillance_cases (status, score desc);
+
+create index if not exists journal_create_at_index
+    on public.journal (create_at, kind);
@@ -0,0 +1,70 @@
+-- The support tickets of the users: the category and the subject of the ticket, the status of its workflow and the snapshot of
+-- the account taken when the ticket was opened, the recent withdrawals and orders and the ones the user referred to, so
+-- that the staff does not have to ask for them. The messages of the user and of the staff follow the ticket.
+create table if not exists public.tickets
+(
+    id          serial
+        constraint tickets_pk
+            primary key,
+    user_id     integer                                                not null,
+    category    varchar                                                not null,
+    subject     varchar                                                not null,
+    status      varchar                  default 'open'::character varying not null,
+    context     jsonb                    default '{}'::jsonb           not null,
+    operator_id integer                  default 0                     not null,
+    update_at   timestamp with time zone default CURRENT_TIMESTAMP     not null,
+    close_at    timestamp with time zone,
+    create_at   timestamp with time zone default CURRENT_TIMESTAMP     not null
+);
+
+alter table public.tickets
+    owner to envoys;
+
+create index if not exists tickets_user_id_index
+    on public.tickets (user_id, id desc);
+
+create index if not exists tickets_status_index
+    on public.tickets (status, update_at);
+
+create table if not exists public.ticket_messages
+(
+    id        serial
+        constraint ticket_messages_pk
+            primary key,
+    ticket_id integer                                            not null
+        constraint ticket_messages_ticket_id_fk
+            references public.tickets
+            on delete cascade,
+    user_id   integer                                            not null,
+    staff     boolean                  default false             not null,
+    text      varchar                                            not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.ticket_messages
+    owner to envoys;
+
+create index if not exists ticket_messages_ticket_id_index
+    on public.ticket_messages (ticket_id, id);
+
+-- The files attached to the messages, the type of the file is detected from its content when it is uploaded.
+create table if not exists public.ticket_attachments
+(
+    id         serial
+        constraint ticket_attachments_pk
+            primary key,
+    message_id integer                                            not null
+        constraint ticket_attachments_message_id_fk
+            references public.ticket_messages
+            on delete cascade,
+    name       varchar                                            not null,
+    mime       varchar                                            not null,
+    data       bytea                                              not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.ticket_attachments
+    owner to envoys;
+
+create index if not exists ticket_attachments_message_id_index
+    on public.ticket_attachments (message_id);
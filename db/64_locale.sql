@@ -0,0 +1,3 @@
+-- The locale the messages of the backend are returned to the user in, chosen in the settings of the account. The empty locale
+-- is not chosen, the messages are returned in the locale preferred by the Accept-Language header of the request.
+alter table public.accounts add column if not exists locale varchar default ''::character varying not null;
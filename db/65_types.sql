@@ -0,0 +1,60 @@
+-- The values of the spot tables written while the spot service still used the enums of pbspot are the upper case names of the
+-- enums, such as 'BUY', 'PENDING' or 'WITHDRAWS', while the stock service writes the constants of the types package. The
+-- values are brought to the constants, so that the queries over the orders, the trades and the transactions of both
+-- types, such as the reports and the history, find all of them. The journal is append-only and is left as it was written.
+update public.orders
+set assigning = lower(assigning),
+    type      = lower(type),
+    trading   = lower(trading),
+    status    = lower(status)
+where assigning <> lower(assigning)
+   or type <> lower(type)
+   or trading <> lower(trading)
+   or status <> lower(status);
+
+update public.trades
+set assigning = lower(assigning)
+where assigning <> lower(assigning);
+
+update public.ohlcv
+set assigning = lower(assigning)
+where assigning <> lower(assigning);
+
+update public.balances
+set type = lower(type)
+where type <> lower(type);
+
+update public.transactions
+set assignment = case lower(assignment) when 'withdraws' then 'withdrawal' else lower(assignment) end,
+    "group"    = lower("group"),
+    platform   = lower(platform),
+    protocol   = lower(protocol),
+    allocation = lower(allocation),
+    status     = lower(status)
+where assignment <> lower(assignment)
+   or lower(assignment) = 'withdraws'
+   or "group" <> lower("group")
+   or platform <> lower(platform)
+   or protocol <> lower(protocol)
+   or allocation <> lower(allocation)
+   or status <> lower(status);
+
+update public.assets
+set "group" = lower("group"),
+    type    = lower(type)
+where "group" <> lower("group")
+   or type <> lower(type);
+
+-- The orders and the transactions take only the values of the constants from now on, the enums of pbspot can not be written
+-- to them again.
+alter table public.orders
+    drop constraint if exists orders_assigning_check,
+    add constraint orders_assigning_check check (assigning in ('buy', 'sell')),
+    drop constraint if exists orders_trading_check,
+    add constraint orders_trading_check check (trading in ('market', 'limit')),
+    drop constraint if exists orders_type_check,
+    add constraint orders_type_check check (type in ('spot', 'stock', 'cross'));
+
+alter table public.transactions
+    drop constraint if exists transactions_assignment_check,
+    add constraint transactions_assignment_check check (assignment in ('deposit', 'withdrawal'));
@@ -0,0 +1,4 @@
+-- The history of the orders and the trades of the user reads the orders of the user of both types, the spot and the stock ones,
+-- together with the trades of the user by the trades_user_id_idx index.
+create index if not exists orders_user_id_create_at_idx
+    on public.orders (user_id, create_at);
@@ -0,0 +1,23 @@
+-- The messages the broker did not accept after all the attempts of the policy of the publishing: the topic, the channel and the
+-- message as it was published, the last error of the broker and the number of the attempts. The operators inspect them and
+-- re-drive them to the broker, the re-driven letters are kept with the time they were re-driven.
+create table if not exists public.dead_letters
+(
+    id         bigserial
+        constraint dead_letters_pk
+            primary key,
+    topic      varchar                                                  not null,
+    channel    varchar                                                  not null,
+    message    text                                                     not null,
+    error      varchar                  default ''::character varying  not null,
+    attempts   integer                  default 0                       not null,
+    status     varchar                  default 'failed'::character varying not null,
+    redrive_at timestamp with time zone,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.dead_letters
+    owner to envoys;
+
+create index if not exists dead_letters_status_create_at_idx
+    on public.dead_letters (status, create_at);
@@ -0,0 +1,9 @@
+-- The head of the chain is the latest block reported by its node and the scan_at is the time the scanner last reported it, the
+-- block of the chain is the next block the scanner reads, so the scanner is as many blocks behind the head as the head minus
+-- the last block it has scanned. The block is the cursor of the scanner, it survives the restarts of the service.
+--
+-- The streamed is whether the head of the chain the scanner last reported was announced by the subscription or asked from the node.
+alter table public.chains
+    add column if not exists head     bigint  default 0     not null,
+    add column if not exists scan_at  timestamp with time zone,
+    add column if not exists streamed boolean default false not null;
@@ -0,0 +1,5 @@
+-- The workers of the chain are the number of the blocks its scanner reads at the same time, the blocks are read in parallel and
+-- their deposits are recorded in the order of the blocks, so the cursor of the scanner never passes a block that was not
+-- recorded. The chains that produce the blocks faster than one scanner reads them are given more workers.
+alter table public.chains
+    add column if not exists workers integer default 1 not null;
@@ -0,0 +1,59 @@
+-- The bridges the operators move the reserves of a currency through from one platform and protocol to another, the address is
+-- the deposit address of the bridge on the chain of the source.
+create table if not exists public.bridges
+(
+    id          serial
+        constraint bridges_pk
+            primary key,
+    name        varchar                  default ''::character varying not null,
+    symbol      varchar                                                 not null,
+    platform    varchar                                                 not null,
+    protocol    varchar                                                 not null,
+    to_platform varchar                                                 not null,
+    to_protocol varchar                                                 not null,
+    address     varchar                                                 not null,
+    fees        numeric(32, 18)          default 0                      not null,
+    status      boolean                  default true                   not null,
+    create_at   timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.bridges
+    owner to envoys;
+
+create unique index if not exists bridges_route_uindex
+    on public.bridges (symbol, platform, protocol, to_platform, to_protocol);
+
+-- The moves of the reserves of the currencies that cover the withdrawals waiting for a reserve holding their value: the
+-- transfers between the exchange addresses of a platform, sent by the withdrawal replay as the internal withdrawals, and
+-- the moves across the platforms through the bridges, carried out by the operators. The proposed moves are in review.
+create table if not exists public.rebalances
+(
+    id             serial
+        constraint rebalances_pk
+            primary key,
+    kind           varchar                                                 not null,
+    symbol         varchar                                                 not null,
+    platform       varchar                                                 not null,
+    protocol       varchar                                                 not null,
+    from_platform  varchar                                                 not null,
+    from_protocol  varchar                                                 not null,
+    chain_id       integer                  default 0                      not null,
+    user_id        integer                  default 0                      not null,
+    "from"         varchar                                                 not null,
+    "to"           varchar                  default ''::character varying not null,
+    value          numeric(32, 18)          default 0                      not null,
+    shortage       numeric(32, 18)          default 0                      not null,
+    bridge_id      integer                  default 0                      not null,
+    transaction_id integer                  default 0                      not null,
+    hash           varchar                  default ''::character varying not null,
+    error          varchar                  default ''::character varying not null,
+    status         varchar                  default 'review'::character varying not null,
+    update_at      timestamp with time zone default CURRENT_TIMESTAMP      not null,
+    create_at      timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.rebalances
+    owner to envoys;
+
+create index if not exists rebalances_status_index
+    on public.rebalances (status, symbol, platform, protocol);
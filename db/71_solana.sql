@@ -0,0 +1,8 @@
+-- The Solana chain, the block of the chain is the window of the 100 slots the scanner reads at once, so the block has to be
+-- set to the finalized slot divided by 100 before the chain is turned on, otherwise the scanner reads the chain from its
+-- first slot.
+insert into public.chains (id, name, rpc, block, network, explorer_link, platform, confirmation, time_withdraw, fees, tag, parent_symbol, decimals, status)
+values  (8, 'Solana Chain', 'https://api.mainnet-beta.solana.com', 0, 0, 'https://solscan.io/tx', 'solana', 1, 10, 0.000005, 'tag_solana', 'sol', 9, false)
+on conflict do nothing;
+
+select pg_catalog.setval('public.chains_id_seq', greatest(8, (select max(id) from public.chains)), true);
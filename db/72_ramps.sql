@@ -0,0 +1,49 @@
+-- The automatic conversion of the deposits of the stablecoins the users opted in to: the currency of the balance the deposits
+-- are converted into and the largest distance in percent from the price of the pair the conversion is executed at, zero
+-- is the slippage of the configuration.
+create table if not exists public.ramps
+(
+    user_id   integer                                                 not null
+        constraint ramps_pk
+            primary key,
+    target    varchar                                                 not null,
+    slippage  numeric(8, 4)            default 0                      not null,
+    status    boolean                  default true                   not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.ramps
+    owner to envoys;
+
+-- The conversions of the deposits, one per deposit: the market order placed for the conversion, the price of the pair and the
+-- limit the order was executed within, the value of the deposit that was converted at the average price with the fees of
+-- the order, the rest of the deposit stays on the balance in the stablecoin.
+create table if not exists public.ramp_conversions
+(
+    id         serial
+        constraint ramp_conversions_pk
+            primary key,
+    user_id    integer                                                 not null,
+    deposit_id integer                                                 not null,
+    order_id   integer                  default 0                      not null,
+    symbol     varchar                                                 not null,
+    target     varchar                                                 not null,
+    value      numeric(32, 18)          default 0                      not null,
+    reference  numeric(32, 18)          default 0                      not null,
+    "limit"    numeric(32, 18)          default 0                      not null,
+    filled     numeric(32, 18)          default 0                      not null,
+    price      numeric(32, 18)          default 0                      not null,
+    fees       numeric(32, 18)          default 0                      not null,
+    status     varchar                  default 'pending'::character varying not null,
+    error      varchar                  default ''::character varying not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.ramp_conversions
+    owner to envoys;
+
+create unique index if not exists ramp_conversions_deposit_id_uindex
+    on public.ramp_conversions (deposit_id);
+
+create index if not exists ramp_conversions_user_id_index
+    on public.ramp_conversions (user_id, id);
@@ -0,0 +1,22 @@
+-- The external wallets linked to the accounts by the sign in with Ethereum: the address in the checksum form the wallet signed
+-- the message with and the chain the message named. An address is linked to one account, the account signs in with any of
+-- its addresses.
+create table if not exists public.account_wallets
+(
+    id        serial
+        constraint account_wallets_pk
+            primary key,
+    user_id   integer                                                 not null,
+    address   varchar                                                 not null,
+    chain_id  integer                  default 1                      not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.account_wallets
+    owner to envoys;
+
+create unique index if not exists account_wallets_address_uindex
+    on public.account_wallets (lower(address));
+
+create index if not exists account_wallets_user_id_index
+    on public.account_wallets (user_id);
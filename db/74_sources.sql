@@ -0,0 +1,29 @@
+-- The external addresses the users proved to own by the signatures of the messages of the exchange, the verified sources of
+-- the deposits. The address of a platform is the source of one user, the compliance officers revoke the sources with the
+-- status. The deposits from the verified sources are marked, the mark is the record of the source for the travel rule.
+create table if not exists public.sources
+(
+    id        serial
+        constraint sources_pk
+            primary key,
+    user_id   integer                                                 not null,
+    chain_id  integer                                                 not null,
+    platform  varchar                                                 not null,
+    address   varchar                                                 not null,
+    message   text                     default ''::text               not null,
+    signature varchar                  default ''::character varying not null,
+    status    boolean                  default true                   not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.sources
+    owner to envoys;
+
+create unique index if not exists sources_platform_address_uindex
+    on public.sources (platform, address);
+
+create index if not exists sources_user_id_index
+    on public.sources (user_id);
+
+alter table public.transactions
+    add column if not exists verified boolean default false not null;
@@ -0,0 +1,45 @@
+-- The futures service wrote the assigning of its orders, open or close, as the type of the balances and as the side of its
+-- trades, the fourth variant of the concepts the spot and the stock services share since the types were normalized. The
+-- balances of the futures take the type future, the trades take the side of the order book the futures order took:
+-- opening the long and closing the short buy, opening the short and closing the long sell, as types.Side converts them.
+update public.futures
+set assigning = lower(assigning),
+    "position" = lower("position"),
+    trading   = lower(trading),
+    status    = lower(status)
+where assigning <> lower(assigning)
+   or "position" <> lower("position")
+   or trading <> lower(trading)
+   or status <> lower(status);
+
+update public.trades t
+set assigning = case when (f.assigning = 'open') = (f."position" = 'long') then 'buy' else 'sell' end
+from public.futures f
+where t.order_id = f.id
+  and t.assigning in ('open', 'close');
+
+update public.balances
+set type = 'future'
+where type in ('open', 'close');
+
+-- The check constraints are those of the enums of the registry of the types package, a new module registers its concept there
+-- and extends the constraint of its table instead of writing the values of its own.
+alter table public.trades
+    drop constraint if exists trades_assigning_check,
+    add constraint trades_assigning_check check (assigning in ('buy', 'sell'));
+
+alter table public.ohlcv
+    drop constraint if exists ohlcv_assigning_check,
+    add constraint ohlcv_assigning_check check (assigning in ('buy', 'sell', 'supply'));
+
+alter table public.balances
+    drop constraint if exists balances_type_check,
+    add constraint balances_type_check check (type in ('spot', 'stock', 'cross', 'future'));
+
+alter table public.futures
+    drop constraint if exists futures_assigning_check,
+    add constraint futures_assigning_check check (assigning in ('open', 'close')),
+    drop constraint if exists futures_position_check,
+    add constraint futures_position_check check ("position" in ('long', 'short')),
+    drop constraint if exists futures_trading_check,
+    add constraint futures_trading_check check (trading in ('market', 'limit'));
@@ -0,0 +1,64 @@
+-- The paper trading of the users: whether the orders of the user are simulated and when the simulated balances were last set
+-- to the balances of the configuration. The paper trading is a parallel set of the balances and of the orders, it never
+-- touches the balances, the holds, the order book or the reserves of the exchange.
+create table if not exists public.papers
+(
+    user_id   integer                                                 not null
+        constraint papers_pk
+            primary key,
+    status    boolean                  default true                   not null,
+    reset_at  timestamp with time zone default CURRENT_TIMESTAMP      not null,
+    create_at timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.papers
+    owner to envoys;
+
+create table if not exists public.paper_balances
+(
+    user_id integer                    not null,
+    symbol  varchar                    not null,
+    value   numeric(32, 18) default 0 not null,
+    constraint paper_balances_pk
+        primary key (user_id, symbol),
+    constraint paper_balances_value_check
+        check (value >= 0)
+);
+
+alter table public.paper_balances
+    owner to envoys;
+
+-- The simulated orders, the market orders are filled at the price of the pair when they are placed, the limit orders once
+-- the price of the pair crosses their price. The funds of the pending orders are taken from the simulated balances.
+create table if not exists public.paper_orders
+(
+    id         serial
+        constraint paper_orders_pk
+            primary key,
+    user_id    integer                                                 not null,
+    base_unit  varchar                                                 not null,
+    quote_unit varchar                                                 not null,
+    assigning  varchar                                                 not null
+        constraint paper_orders_assigning_check
+            check (assigning in ('buy', 'sell')),
+    trading    varchar                                                 not null
+        constraint paper_orders_trading_check
+            check (trading in ('market', 'limit')),
+    type       varchar                  default 'spot'::character varying not null,
+    price      numeric(32, 18)          default 0                      not null,
+    quantity   numeric(32, 18)          default 0                      not null,
+    value      numeric(32, 18)          default 0                      not null,
+    fees       numeric(32, 18)          default 0                      not null,
+    status     varchar                  default 'pending'::character varying not null,
+    create_at  timestamp with time zone default CURRENT_TIMESTAMP      not null
+);
+
+alter table public.paper_orders
+    owner to envoys;
+
+create index if not exists paper_orders_user_id_index
+    on public.paper_orders (user_id, id);
+
+create index if not exists paper_orders_status_index
+    on public.paper_orders (base_unit, quote_unit)
+    where status = 'pending';
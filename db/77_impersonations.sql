@@ -0,0 +1,41 @@
+-- The impersonations of the accounts by the support staff: the read-only "view as user" sessions issued by the operator for the
+-- account with the reason, and the time they expire at, the ended impersonations are kept with the time they ended at.
+create table if not exists public.impersonations
+(
+    id          serial
+        constraint impersonations_pk
+            primary key,
+    user_id     integer                                            not null,
+    operator_id integer                                            not null,
+    reason      varchar                                            not null,
+    status      boolean                  default true              not null,
+    requests    integer                  default 0                 not null,
+    expire_at   timestamp with time zone                           not null,
+    end_at      timestamp with time zone,
+    create_at   timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.impersonations
+    owner to envoys;
+
+create index if not exists impersonations_user_id_index
+    on public.impersonations (user_id, status);
+
+-- The audit of the impersonations: every request made with the token of the impersonation, with the method and whether it was
+-- allowed, the requests of the methods that are not read-only are refused and audited as well.
+create table if not exists public.impersonation_requests
+(
+    id               serial
+        constraint impersonation_requests_pk
+            primary key,
+    impersonation_id integer                                            not null,
+    method           varchar                                            not null,
+    allowed          boolean                                            not null,
+    create_at        timestamp with time zone default CURRENT_TIMESTAMP not null
+);
+
+alter table public.impersonation_requests
+    owner to envoys;
+
+create index if not exists impersonation_requests_impersonation_id_index
+    on public.impersonation_requests (impersonation_id, id);
@@ -0,0 +1,5 @@
+-- The signing secret of the api key is the key of the signatures of the requests made with it, it is shown to the user once,
+-- with the key, and is never sent with the requests. The requests of the api keys are signed, the keys created before the
+-- secret have none and are created again.
+alter table public.api_keys
+    add column if not exists signing varchar default ''::character varying not null;
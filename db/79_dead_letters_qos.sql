@@ -0,0 +1,4 @@
+-- The quality of the service the dead letter was published with, it is re-driven with the same one: the public events at most
+-- once, the events of the users and of the support at least once. The letters written before it were private.
+alter table public.dead_letters
+    add column if not exists qos smallint default 1 not null;
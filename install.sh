@@ -39,8 +39,10 @@ sudo sed -i "s|# host    .*|host all all all scram-sha-256|g" /etc/postgresql/14
 sudo service postgresql restart
 
 sudo -i -u postgres psql -X -c "create extension if not exists timescaledb cascade;"
+# The migrations are numbered with two digits, so that the glob sorts them in the order of their dependencies, the same
+# order the entrypoint of the postgres image applies them in. The install stops at the first migration that fails.
 for index in db/* ; do
-  sudo -i -u postgres psql envoys < "${index}"
+  sudo -i -u postgres psql -v ON_ERROR_STOP=1 envoys < "${index}" || exit 1
 done
 
 exit
\ No newline at end of file
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 
 	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/server"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
+
+	// The adapters of the blockchain platforms register themselves in the registry of the platforms.
+	_ "github.com/cryptogateway/backend-envoys/assets/platform/ethereum"
+	_ "github.com/cryptogateway/backend-envoys/assets/platform/solana"
+	_ "github.com/cryptogateway/backend-envoys/assets/platform/tron"
 )
 
 func init() {
@@ -22,6 +31,67 @@ func main() {
 		panic(err)
 	}
 
+	// The rotate-entropy command seals the entropies of the accounts that are still written in the clear, and rewraps the
+	// entropies sealed with the retired master keys with the primary master key of the configuration, then exits.
+	if len(os.Args) > 1 && os.Args[1] == "rotate-entropy" {
+
+		option := &assets.Context{
+			StoragePath: dir,
+		}
+		option.Write()
+
+		count, err := (&account.Service{Context: option}).RotateEntropy()
+		if err != nil {
+			option.Logger.Fatal(err)
+		}
+
+		fmt.Printf("%d account entropies were sealed with the master key %v\n", count, option.Entropy.Primary)
+		return
+	}
+
+	// The replay-journal command rebuilds the state of the orders from the journal of the book and prints the discrepancies
+	// with the orders and the trades of the database, then exits. With "repair" the remaining values and the statuses of
+	// the orders are corrected from the journal, with the id of an order the fills of the order are printed for its audit.
+	if len(os.Args) > 1 && os.Args[1] == "replay-journal" {
+
+		option := &assets.Context{
+			StoragePath: dir,
+		}
+		option.Write()
+
+		repair := len(os.Args) > 2 && os.Args[2] == "repair"
+
+		ledger, discrepancies, err := (&provider.Service{Context: option}).ReplayJournal(repair)
+		if err != nil {
+			option.Logger.Fatal(err)
+		}
+
+		if len(os.Args) > 2 && !repair {
+
+			id, err := strconv.ParseInt(os.Args[2], 10, 64)
+			if err != nil {
+				option.Logger.Fatal(err)
+			}
+
+			position, ok := ledger.Position(id)
+			if !ok {
+				option.Logger.Fatalf("the order %d is not in the journal", id)
+			}
+
+			fmt.Printf("order %d: price %v, remaining value %v, buy %v, queued %v, cancelled %v\n", position.Id, position.Price, position.Value, position.Buy, position.Queued, position.Cancelled)
+			for _, fill := range position.Fills {
+				fmt.Printf("  filled %v at %v against the order %d\n", fill.Value, fill.Price, fill.Id)
+			}
+		}
+
+		for _, discrepancy := range discrepancies {
+			fmt.Println(discrepancy)
+		}
+
+		fmt.Printf("%d orders were replayed, %d discrepancies were found\n", len(ledger.Positions()), len(discrepancies))
+		return
+	}
+
 	// The purpose of this code is to initiate a master instance of the server with a specific context. The context defines
 	// the environment and settings that the server should use when processing requests. This allows the server to customize
 	// its behavior for a given context.
@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/broker"
+)
+
+// acl - This function returns the handler of the checks of the broker, the http backend of the authentication and of the
+// authorization of rabbitmq: the broker asks whether the user may connect, enter the virtual host, use the resource and
+// read or write the routing key of the topic, and the backend answers "allow" or "deny". The backend itself is allowed
+// everything by its own credentials, the users connect with their grants, may bind their subscriptions to the topics
+// and may read the public topic and their own topic only, they never publish.
+func (o *Options) acl(check string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var (
+			username = r.FormValue("username")
+			backend  = len(username) > 0 && username == o.Context.Rabbitmq.Username
+			user     = broker.User(username)
+			allow    bool
+		)
+
+		switch check {
+		case "user":
+			allow = (backend && r.FormValue("password") == o.Context.Rabbitmq.Password) || broker.Verify(o.Context.Rabbitmq.Secret, username, r.FormValue("password"), time.Now()) > 0
+		case "vhost":
+			allow = backend || user > 0
+		case "resource":
+
+			// The subscriptions of mqtt are the queues of the broker named after the clients, they are bound to the exchange
+			// of the topics, which the users may only read.
+			switch r.FormValue("resource") {
+			case "queue":
+				allow = backend || (user > 0 && strings.HasPrefix(r.FormValue("name"), "mqtt-subscription-"))
+			case "exchange":
+				allow = backend || (user > 0 && r.FormValue("permission") == "read")
+			}
+
+		case "topic":
+			allow = backend || (r.FormValue("permission") == "read" && broker.Readable("exchange", user, r.FormValue("routing_key")))
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		if !allow {
+			_, _ = w.Write([]byte("deny"))
+			return
+		}
+		_, _ = w.Write([]byte("allow"))
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/cryptogateway/backend-envoys/assets"
 	admin_pbaccount "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbaccount"
 	admin_pbads "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbads"
+	admin_pbanalytics "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbanalytics"
 	admin_pbmarket "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbmarket"
+	admin_pbsearch "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbsearch"
 	admin_pbspot "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbspot"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbaccount"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbads"
@@ -23,6 +25,7 @@ import (
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbspot"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbstock"
+	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbticket"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"golang.org/x/net/http2"
 	"google.golang.org/grpc"
@@ -112,6 +115,10 @@ func Run(params Options) error {
 		// messages that the gRPC server can receive. This ensures that large messages do not overload the server and cause it
 		// to crash. It also helps to prevent malicious users from sending large messages in order to exhaust the server's resources.
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(4194304)),
+
+		// The traceparent of the trace of the caller is passed with the requests the services make to each other, so that the
+		// requests join the trace of the caller.
+		grpc.WithChainUnaryInterceptor(params.Context.UnaryPropagate()),
 	}
 
 	// This code is establishing a connection between a client and server using the gRPC protocol. The
@@ -196,6 +203,26 @@ func (o *Options) headers(ctx context.Context, conn *grpc.ClientConn) error {
 
 	}(conn))
 
+	// The metrics of the process are scraped by prometheus, the liveness and the readiness of the gateway are checked by the
+	// orchestrator: the process that answers is alive, and it is ready when its dependencies are available.
+	route.HandleFunc("/metrics", o.prometheus())
+	route.HandleFunc("/healthz", o.healthz())
+	route.HandleFunc("/readyz", o.readyz(conn))
+
+	// The checks of the http backend of the authentication of the broker, the topics of the users are read only by the users
+	// themselves with the grants issued by the backend.
+	for _, check := range []string{"user", "vhost", "resource", "topic"} {
+		route.HandleFunc("/v2/broker/"+check, o.acl(check))
+	}
+
+	// The webhooks of the third-party node providers report the transfers of the chains they are configured for, signed
+	// with the secrets of the chains.
+	route.HandleFunc("/v2/webhook/", o.webhook())
+
+	// The graphql api lets the frontend read the data of several services in one round trip, and subscribe to the events of
+	// the broker over the websocket.
+	route.HandleFunc("/v2/graphql", o.graphql(conn))
+
 	// This code is attempting to set up a gateway connection and will return an error if it fails. The gateway variable is
 	// being assigned the result of the o.gateway function which will be used to establish a connection. The context,
 	// connection and multiplexer are being passed to the function as parameters. If the gateway connection fails, the code
@@ -235,7 +262,7 @@ func (o *Options) headers(ctx context.Context, conn *grpc.ClientConn) error {
 					// list of allowed headers that the browser will accept when making a request to the server. This code will allow
 					// browsers to send requests with the specified headers to the server, which makes the process of requesting data
 					// more secure.
-					w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "Keep-Alive", "User-Agent", "X-Requested-With", "If-Modified-Since", "Cache-Control", "X-Accept-Content-Transfer-Encoding", "X-Accept-Response-Streaming", "X-User-Agent", "X-Grpc-Web", "Message-Encoding", "Message-Accept-Encoding", "Message-Type", "Timeout"}, ","))
+					w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "Keep-Alive", "User-Agent", "X-Requested-With", "If-Modified-Since", "Cache-Control", "X-Accept-Content-Transfer-Encoding", "X-Accept-Response-Streaming", "X-User-Agent", "X-Grpc-Web", "Message-Encoding", "Message-Accept-Encoding", "Message-Type", "Timeout", "Timestamp", "Recv-Window", "Signature"}, ","))
 
 					// The purpose of the code is to set the Access-Control-Allow-Methods header in an HTTP response to include the
 					// methods "GET", "OPTIONS", and "POST". This allows the server to specify which methods are allowed when making
@@ -368,11 +395,14 @@ func (o *Options) gateway(ctx context.Context, connect *grpc.ClientConn, opts []
 		pbkyc.RegisterApiHandler,
 		pbprovider.RegisterApiHandler,
 		pbfuture.RegisterApiHandler,
+		pbticket.RegisterApiHandler,
 		// V1 - Admin apis.
 		admin_pbaccount.RegisterApiHandler,
 		admin_pbspot.RegisterApiHandler,
 		admin_pbads.RegisterApiHandler,
 		admin_pbmarket.RegisterApiHandler,
+		admin_pbsearch.RegisterApiHandler,
+		admin_pbanalytics.RegisterApiHandler,
 	} {
 		if err := f(ctx, route, connect); err != nil {
 			return nil, err
@@ -0,0 +1,557 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/broker"
+	"github.com/cryptogateway/backend-envoys/assets/common/graphql"
+	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbindex"
+	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gorilla/websocket"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// graphqlWait - The time the loaders wait for the other keys of the batch before the batch is fetched from the database.
+	graphqlWait = 2 * time.Millisecond
+
+	// graphqlDepth - The deepest nesting of the selection sets of the operations, the deeper operations are rejected.
+	graphqlDepth = 8
+
+	// graphqlBuffer - The number of the events buffered per subscription, the events of the slow subscribers are dropped.
+	graphqlBuffer = 64
+)
+
+// balance - The balance type is the key of the balances loader: the symbol and the type of the balance.
+type balance struct {
+	symbol, _type string
+}
+
+// loaders - The loaders struct holds the loaders of one request, the values requested by the items of the lists are read from
+// the database by one query per batch instead of one query per item.
+type loaders struct {
+	assets   *graphql.Loader[string, *types.Asset]
+	balances *graphql.Loader[balance, float64]
+}
+
+// listener - The listener struct holds a subscription to a channel of the events, the events of the users are delivered only to
+// the subscriptions of the same user.
+type listener struct {
+	channel string
+	user    int64
+	events  chan interface{}
+}
+
+// fanout - The fanout struct fans the events published to the exchange topic of the broker out to the subscriptions of the
+// graphql api, the topic is subscribed once per gateway.
+type fanout struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners map[*listener]bool
+}
+
+var exchange = fanout{listeners: make(map[*listener]bool)}
+
+// graphql - This function returns the handler of the graphql api. The queries are posted in the format of the graphql requests
+// over http and are resolved by the existing grpc services, with the authorization header of the request. The
+// subscriptions are served over the websocket with the graphql-transport-ws protocol, bridged from the events of the broker.
+func (o *Options) graphql(conn *grpc.ClientConn) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if websocket.IsWebSocketUpgrade(r) {
+			o.socket(w, r, conn)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "the graphql queries are posted", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var (
+			request graphql.Request
+		)
+
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+			http.Error(w, "the graphql request is not correct", http.StatusBadRequest)
+			return
+		}
+
+		response := o.schema(conn, r.Header.Get("Authorization")).Execute(r.Context(), &request)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			o.Context.Logger.Error(err)
+		}
+	}
+}
+
+// schema - This function returns the schema of the graphql api for the authorization of the request. The fields are the calls of
+// the grpc services with the arguments of the fields as the requests, so that one query can read all the data of the
+// dashboard of the frontend in one round trip. The assets of the pairs and the balances of the assets are loaded in batches.
+func (o *Options) schema(conn *grpc.ClientConn, authorization string) *graphql.Schema {
+
+	var (
+		provider = pbprovider.NewApiClient(conn)
+		index    = pbindex.NewApiClient(conn)
+		load     = o.loaders(authorization)
+	)
+
+	return &graphql.Schema{
+		Depth: graphqlDepth,
+		Query: map[string]graphql.Resolver{
+			"asset":        call(authorization, provider.GetAsset, load.available),
+			"assets":       call(authorization, provider.GetAssets, load.available),
+			"pair":         call(authorization, provider.GetPair, load.units),
+			"pairs":        call(authorization, provider.GetPairs, load.units),
+			"price":        call(authorization, provider.GetPrice),
+			"ticker":       call(authorization, provider.GetTicker),
+			"ticker24h":    call(authorization, provider.GetTicker24H),
+			"markets":      call(authorization, provider.GetMarkets),
+			"wallet":       call(authorization, provider.GetWallet),
+			"orders":       call(authorization, provider.GetOrders),
+			"trades":       call(authorization, provider.GetTrades),
+			"history":      call(authorization, provider.GetHistory),
+			"transactions": call(authorization, provider.GetTransactions),
+			"server_time":  call(authorization, index.GetServerTime),
+		},
+		Subscription: map[string]graphql.Subscriber{
+			"event": o.event(authorization),
+		},
+	}
+}
+
+// call - This function returns the resolver of the field that calls the method of the grpc service. The arguments of the field
+// are decoded into the request of the method by their names, the unknown arguments are errors. The decorators add the
+// batched fields to the response.
+func call[Req any, Request interface {
+	*Req
+	proto.Message
+}, Response proto.Message](authorization string, method func(context.Context, Request, ...grpc.CallOption) (Response, error), decorators ...func(graphql.Object)) graphql.Resolver {
+	return func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+
+		request := Request(new(Req))
+
+		serialize, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := protojson.Unmarshal(serialize, request); err != nil {
+			return nil, errors.Errorf("the arguments are not correct: %v", err)
+		}
+
+		if len(authorization) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+		}
+
+		response, err := method(ctx, request)
+		if err != nil {
+			return nil, errors.New(status.Convert(err).Message())
+		}
+
+		value, err := graphql.Value(response)
+		if err != nil {
+			return nil, err
+		}
+
+		object, _ := value.(graphql.Object)
+		for _, decorator := range decorators {
+			decorator(object)
+		}
+
+		return object, nil
+	}
+}
+
+// loaders - This function returns the loaders of the request. The balances are the available balances of the user of the
+// authorization, the request without the authorization has no balances.
+func (o *Options) loaders(authorization string) *loaders {
+	return &loaders{
+		assets: graphql.NewLoader(graphqlWait, func(keys []string) (map[string]*types.Asset, error) {
+
+			rows, err := o.Context.Db.Query(`select id, name, symbol, min_withdraw, max_withdraw, min_trade, max_trade, fees_trade, fees_discount, fees_charges, fees_costs, marker, status, "group", type, zone, create_at from assets where symbol = any($1)`, pq.Array(keys))
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			assets := make(map[string]*types.Asset)
+			for rows.Next() {
+
+				var (
+					item types.Asset
+				)
+
+				if err := rows.Scan(&item.Id, &item.Name, &item.Symbol, &item.MinWithdraw, &item.MaxWithdraw, &item.MinTrade, &item.MaxTrade, &item.FeesTrade, &item.FeesDiscount, &item.FeesCharges, &item.FeesCosts, &item.Marker, &item.Status, &item.Group, &item.Type, &item.Zone, &item.CreateAt); err != nil {
+					return nil, err
+				}
+
+				assets[item.GetSymbol()] = &item
+			}
+
+			return assets, rows.Err()
+		}),
+		balances: graphql.NewLoader(graphqlWait, func(keys []balance) (map[balance]float64, error) {
+
+			user, err := o.auth(authorization)
+			if err != nil {
+				return nil, err
+			}
+
+			var (
+				symbols []string
+			)
+
+			for _, key := range keys {
+				symbols = append(symbols, key.symbol)
+			}
+
+			rows, err := o.Context.Db.Query("select b.symbol, b.type, b.value - coalesce((select sum(h.value) from holds h where h.symbol = b.symbol and h.user_id = b.user_id and h.type = b.type), 0) as balance from balances b where b.user_id = $1 and b.symbol = any($2)", user, pq.Array(symbols))
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			balances := make(map[balance]float64)
+			for rows.Next() {
+
+				var (
+					key   balance
+					value float64
+				)
+
+				if err := rows.Scan(&key.symbol, &key._type, &value); err != nil {
+					return nil, err
+				}
+
+				balances[key] = value
+			}
+
+			return balances, rows.Err()
+		}),
+	}
+}
+
+// units - This function adds the assets of the base and the quote units to the pairs of the response, as the base_asset and the
+// quote_asset fields.
+func (l *loaders) units(response graphql.Object) {
+	for _, field := range fields(response) {
+
+		base, _ := field["base_unit"].(string)
+		quote, _ := field["quote_unit"].(string)
+
+		field["base_asset"] = graphql.Resolver(func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+			return l.assets.Load(base)
+		})
+		field["quote_asset"] = graphql.Resolver(func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+			return l.assets.Load(quote)
+		})
+	}
+}
+
+// available - This function adds the available balance of the user to the assets of the response, as the available field.
+func (l *loaders) available(response graphql.Object) {
+	for _, field := range fields(response) {
+
+		key := balance{_type: types.TypeSpot}
+		key.symbol, _ = field["symbol"].(string)
+		if _type, ok := field["type"].(string); ok && len(_type) > 0 {
+			key._type = _type
+		}
+
+		field["available"] = graphql.Resolver(func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+			return l.balances.Load(key)
+		})
+	}
+}
+
+// fields - This function returns the items of the fields list of the response.
+func fields(response graphql.Object) (items []map[string]interface{}) {
+
+	list, _ := response["fields"].([]interface{})
+	for _, item := range list {
+		if field, ok := item.(map[string]interface{}); ok {
+			items = append(items, field)
+		}
+	}
+
+	return items
+}
+
+// auth - This function returns the id of the user of the authorization, in the same way the grpc services authenticate the requests.
+func (o *Options) auth(authorization string) (int64, error) {
+
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return 0, errors.New("the request is not authorized")
+	}
+
+	return o.Context.Auth(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", authorization)))
+}
+
+// event - This function returns the subscriber of the events of a channel of the broker, such as "trade/ticker" or "order/status".
+// The events of the users are delivered only to the subscriptions authorized by the same user, the alerts of the
+// support are not delivered at all.
+func (o *Options) event(authorization string) graphql.Subscriber {
+	return func(ctx context.Context, args map[string]interface{}) (<-chan interface{}, error) {
+
+		channel, _ := args["channel"].(string)
+		if len(channel) == 0 {
+			return nil, errors.New("the channel of the events is required")
+		}
+
+		if strings.HasPrefix(channel, "support/") {
+			return nil, errors.Errorf("the channel %v is not available", channel)
+		}
+
+		item := listener{channel: channel, events: make(chan interface{}, graphqlBuffer)}
+		if len(authorization) > 0 {
+
+			user, err := o.auth(authorization)
+			if err != nil {
+				return nil, err
+			}
+			item.user = user
+		}
+
+		if err := o.bridge(); err != nil {
+			return nil, err
+		}
+
+		exchange.mu.Lock()
+		exchange.listeners[&item] = true
+		exchange.mu.Unlock()
+
+		go func() {
+			<-ctx.Done()
+
+			exchange.mu.Lock()
+			delete(exchange.listeners, &item)
+			close(item.events)
+			exchange.mu.Unlock()
+		}()
+
+		return item.events, nil
+	}
+}
+
+// bridge - This function subscribes the gateway to the public exchange topic of the broker and to the topics of the users, the
+// events are fanned out to the listeners of their channels. The topics are subscribed by the first subscription of the
+// gateway, the topic of the support is not subscribed, its alerts are not delivered to the users.
+func (o *Options) bridge() (err error) {
+
+	exchange.once.Do(func() {
+		token := o.Context.RabbitmqClient.SubscribeMultiple(map[string]byte{"exchange": broker.QosPublic, broker.Users("exchange"): broker.QosPrivate}, func(_ MQTT.Client, message MQTT.Message) {
+
+			var (
+				event struct {
+					Channel string `json:"channel"`
+					Data    string `json:"data"`
+				}
+				data interface{}
+			)
+
+			if err := json.Unmarshal(message.Payload(), &event); err != nil {
+				return
+			}
+
+			if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+				return
+			}
+
+			user := owner(data)
+
+			exchange.mu.Lock()
+			defer exchange.mu.Unlock()
+
+			for item := range exchange.listeners {
+
+				if item.channel != event.Channel || (user != 0 && user != item.user) {
+					continue
+				}
+
+				select {
+				case item.events <- map[string]interface{}{"channel": event.Channel, "data": data}:
+				default:
+				}
+			}
+		})
+
+		if token.Wait() && token.Error() != nil {
+			err = token.Error()
+		}
+	})
+
+	return err
+}
+
+// owner - This function returns the id of the user the event is addressed to, zero if the event is public.
+func owner(data interface{}) int64 {
+
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+
+	switch id := object["user_id"].(type) {
+	case float64:
+		return int64(id)
+	case string:
+		var owner int64
+		_ = json.Unmarshal([]byte(id), &owner)
+		return owner
+	}
+
+	return 0
+}
+
+// socket - This function serves the subscriptions over the websocket with the graphql-transport-ws protocol. The authorization is
+// taken from the payload of the connection_init message, or from the header of the upgrade request.
+func (o *Options) socket(w http.ResponseWriter, r *http.Request, conn *grpc.ClientConn) {
+
+	type message struct {
+		Id      string          `json:"id,omitempty"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload,omitempty"`
+	}
+
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{"graphql-transport-ws"},
+		CheckOrigin:  func(*http.Request) bool { return true },
+	}
+
+	socket, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer socket.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var (
+		mu         sync.Mutex
+		operations = make(map[string]context.CancelFunc)
+		schema     *graphql.Schema
+	)
+
+	write := func(item message) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := socket.WriteJSON(item); err != nil {
+			cancel()
+		}
+	}
+
+	for {
+
+		var (
+			item message
+		)
+
+		if err := socket.ReadJSON(&item); err != nil {
+			return
+		}
+
+		switch item.Type {
+		case "connection_init":
+
+			var payload struct {
+				Authorization string `json:"authorization"`
+			}
+
+			if len(item.Payload) > 0 {
+				_ = json.Unmarshal(item.Payload, &payload)
+			}
+
+			if len(payload.Authorization) == 0 {
+				payload.Authorization = r.Header.Get("Authorization")
+			}
+
+			schema = o.schema(conn, payload.Authorization)
+			write(message{Type: "connection_ack"})
+
+		case "ping":
+			write(message{Type: "pong"})
+
+		case "subscribe":
+
+			if schema == nil {
+				_ = socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(4401, "Unauthorized"), time.Now().Add(time.Second))
+				return
+			}
+
+			var (
+				request graphql.Request
+			)
+
+			if err := json.Unmarshal(item.Payload, &request); err != nil {
+				_ = socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(4400, "the subscription request is not correct"), time.Now().Add(time.Second))
+				return
+			}
+
+			operation, stop := context.WithCancel(ctx)
+
+			mu.Lock()
+			if _, ok := operations[item.Id]; ok {
+				mu.Unlock()
+				stop()
+				_ = socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(4409, "Subscriber for "+item.Id+" already exists"), time.Now().Add(time.Second))
+				return
+			}
+			operations[item.Id] = stop
+			mu.Unlock()
+
+			go func(id string) {
+
+				defer func() {
+					mu.Lock()
+					delete(operations, id)
+					mu.Unlock()
+					stop()
+				}()
+
+				responses, err := schema.Subscribe(operation, &request)
+				if err != nil {
+					payload, _ := json.Marshal([]*graphql.Error{{Message: err.Error()}})
+					write(message{Id: id, Type: "error", Payload: payload})
+					return
+				}
+
+				for response := range responses {
+					payload, err := json.Marshal(response)
+					if err != nil {
+						continue
+					}
+					write(message{Id: id, Type: "next", Payload: payload})
+				}
+
+				if operation.Err() == nil {
+					write(message{Id: id, Type: "complete"})
+				}
+			}(item.Id)
+
+		case "complete":
+
+			mu.Lock()
+			if stop, ok := operations[item.Id]; ok {
+				stop()
+			}
+			mu.Unlock()
+		}
+	}
+}
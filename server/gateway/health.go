@@ -0,0 +1,199 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/metrics"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// healthTimeout - The time each component is given to answer the readiness check, the components are checked concurrently.
+const healthTimeout = 2 * time.Second
+
+var (
+	// connections - The metric of the connections of the pool of the database, by their states, updated by the scrapes.
+	connections = metrics.NewGauge("db_connections", "The number of the connections of the pool of the database.", "state")
+)
+
+// component - The component struct is the state of a component in the answer of the readiness check, the error is empty if the
+// component is available. The optional components do not fail the readiness of the gateway, such as the nodes of the chains.
+type component struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// prometheus - This function returns the handler of the metrics of the process in the text format of prometheus.
+func (o *Options) prometheus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		stats := o.Context.Db.Stats()
+		connections.Set(float64(stats.InUse), "in_use")
+		connections.Set(float64(stats.Idle), "idle")
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := metrics.Write(w); err != nil {
+			o.Context.Logger.Error(err)
+		}
+	}
+}
+
+// healthz - This function returns the handler of the liveness check, the process that answers it is alive. The dependencies are
+// checked by the readiness check, so that the process is not restarted when a dependency is down.
+func (o *Options) healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
+
+// readyz - This function returns the handler of the readiness check: the grpc server, postgres, redis, the broker and the nodes
+// of the active chains are checked concurrently. The gateway is ready when all the required components are available,
+// the nodes of the chains are reported but are optional, a node that is down stops only the deposits of its chain.
+func (o *Options) readyz(conn *grpc.ClientConn) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		defer cancel()
+
+		checks := map[string]func(ctx context.Context) error{
+			"grpc": func(ctx context.Context) error {
+				if state := conn.GetState(); state != connectivity.Ready && state != connectivity.Idle {
+					return errors.Errorf("the grpc server is %v", state)
+				}
+				return nil
+			},
+			"postgres": func(ctx context.Context) error {
+				return o.Context.Db.PingContext(ctx)
+			},
+			"redis": func(ctx context.Context) error {
+				return o.Context.RedisClient.Ping(ctx).Err()
+			},
+			"mqtt": func(ctx context.Context) error {
+				if !o.Context.RabbitmqClient.IsConnectionOpen() {
+					return errors.New("the connection to the broker is not open")
+				}
+				return nil
+			},
+		}
+
+		// The replica is checked when it is configured, the candles and the histories are read from it.
+		if o.Context.Replica != nil {
+			checks["replica"] = func(ctx context.Context) error {
+				return o.Context.Replica.PingContext(ctx)
+			}
+		}
+
+		// The chains are not checked if the database does not answer, the database itself fails the check then.
+		chains, _ := o.chains(ctx)
+
+		var (
+			mu         sync.Mutex
+			wg         sync.WaitGroup
+			components = make(map[string]*component)
+			ready      = true
+		)
+
+		run := func(name string, optional bool, check func(ctx context.Context) error) {
+			defer wg.Done()
+
+			item := component{Status: "ok", Optional: optional}
+			if err := check(ctx); err != nil {
+				item.Status, item.Error = "fail", err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			components[name] = &item
+			if item.Status != "ok" && !optional {
+				ready = false
+			}
+		}
+
+		for name, check := range checks {
+			wg.Add(1)
+			go run(name, false, check)
+		}
+
+		for name, rpc := range chains {
+			rpc := rpc
+			wg.Add(1)
+			go run("chain/"+name, true, func(ctx context.Context) error {
+				return dial(ctx, rpc)
+			})
+		}
+
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		if !ready {
+			status, code = "fail", http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "components": components})
+	}
+}
+
+// chains - This function returns the rpc addresses of the active chains by their names.
+func (o *Options) chains(ctx context.Context) (map[string]string, error) {
+
+	rows, err := o.Context.Db.QueryContext(ctx, "select coalesce(name, ''), coalesce(rpc, '') from chains where status = $1", true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chains := make(map[string]string)
+	for rows.Next() {
+
+		var (
+			name, rpc string
+		)
+
+		if err := rows.Scan(&name, &rpc); err != nil {
+			return nil, err
+		}
+		chains[name] = rpc
+	}
+
+	return chains, rows.Err()
+}
+
+// dial - This function checks that the node of the chain accepts the connections, the node is not asked for the blocks, since
+// the scanners of the chains do it all the time. The error does not name the address of the node, the answer of the check is public.
+func dial(ctx context.Context, rpc string) error {
+
+	address, err := url.Parse(rpc)
+	if err != nil {
+		return errors.New("the address of the node of the chain is not correct")
+	}
+
+	host := address.Host
+	if len(address.Port()) == 0 {
+		switch address.Scheme {
+		case "https", "wss":
+			host = net.JoinHostPort(address.Hostname(), "443")
+		default:
+			host = net.JoinHostPort(address.Hostname(), "80")
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return errors.New("the node of the chain does not accept the connections")
+	}
+
+	return conn.Close()
+}
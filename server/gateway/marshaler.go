@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"io"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// MIMEDecimalString - The media type a client sends in the Accept header to receive the floating point fields of the responses as
+	// strings, regardless of the default format of the server.
+	MIMEDecimalString = "application/vnd.decimal-string+json"
+
+	// MIMEDecimalNumber - The media type a client sends in the Accept header to keep receiving the floating point fields as numbers,
+	// it allows the old clients to keep working after the string format becomes the default one.
+	MIMEDecimalNumber = "application/vnd.decimal-number+json"
+)
+
+// DecimalMarshaler - The marshaler encodes the responses with the JSONPb marshaler of the gateway, and then rewrites the floating
+// point fields of the messages as strings, so that the amounts of 18-decimal tokens do not lose their precision in the clients.
+type DecimalMarshaler struct {
+	*runtime.JSONPb
+}
+
+// Marshal - This function encodes the value into json, the floating point fields of the protobuf messages are written as strings,
+// any other value is written as it is.
+func (m *DecimalMarshaler) Marshal(v interface{}) ([]byte, error) {
+
+	data, err := m.JSONPb.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fields are found through the reflection of the message, the values without reflection are left untouched.
+	if message, ok := v.(interface{ ProtoReflect() protoreflect.Message }); ok {
+		return decimal.Stringify(message.ProtoReflect(), data, m.Indent)
+	}
+
+	return data, nil
+}
+
+// NewEncoder - This function returns an encoder that writes the values with the Marshal function of the decimal marshaler.
+func (m *DecimalMarshaler) NewEncoder(w io.Writer) runtime.Encoder {
+	return runtime.EncoderFunc(func(v interface{}) error {
+
+		data, err := m.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(data)
+		return err
+	})
+}
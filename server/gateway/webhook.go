@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/webhook"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/spot"
+	"github.com/pkg/errors"
+)
+
+// webhookLimit - The largest body of the event of the webhook that is read, the larger events are rejected.
+const webhookLimit = 4 << 20
+
+// webhook - This function returns the handler of the webhooks of the third-party node providers, the path ends with the id of the
+// chain the provider is configured for, such as /v2/webhook/1. The event is accepted only with the signature of the
+// secret of the chain, its transfers are recorded as the scanner records them, so the transfers reported by both are
+// recorded once. The provider repeats the event that is not answered with the success.
+func (o *Options) webhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			o.error(w, nil, http.StatusMethodNotAllowed)
+			return
+		}
+
+		chainId, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/v2/webhook/"), 10, 64)
+		if err != nil {
+			o.error(w, err, http.StatusNotFound)
+			return
+		}
+
+		config := o.Context.Webhook(chainId)
+		if config == nil {
+			o.error(w, errors.Errorf("no webhook is configured for the chain %v", chainId), http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, webhookLimit+1))
+		if err != nil || len(body) > webhookLimit {
+			o.error(w, err, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if err := webhook.Verify(config.Provider, config.Secret, r.Header, body); err != nil {
+			o.error(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		transfers, err := webhook.Parse(config.Provider, body)
+		if err != nil {
+			o.error(w, err, http.StatusBadRequest)
+			return
+		}
+
+		_spot := spot.Service{
+			Context: o.Context,
+		}
+
+		if err := _spot.WriteWebhook(chainId, transfers); o.Context.Debug(err) {
+			o.error(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
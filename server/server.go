@@ -3,13 +3,16 @@ package server
 import (
 	"math"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/server/gateway"
 	admin_pbaccount "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbaccount"
 	admin_pbads "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbads"
+	admin_pbanalytics "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbanalytics"
 	admin_pbmarket "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbmarket"
+	admin_pbsearch "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbsearch"
 	admin_pbspot "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbspot"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbaccount"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbads"
@@ -20,9 +23,12 @@ import (
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbspot"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbstock"
+	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbticket"
 	admin_account "github.com/cryptogateway/backend-envoys/server/service/v1/admin.account"
 	admin_ads "github.com/cryptogateway/backend-envoys/server/service/v1/admin.ads"
+	admin_analytics "github.com/cryptogateway/backend-envoys/server/service/v1/admin.analytics"
 	admin_market "github.com/cryptogateway/backend-envoys/server/service/v1/admin.market"
+	admin_search "github.com/cryptogateway/backend-envoys/server/service/v1/admin.search"
 	admin_spot "github.com/cryptogateway/backend-envoys/server/service/v1/admin.spot"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/ads"
@@ -33,11 +39,12 @@ import (
 	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/spot"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/stock"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/ticket"
+	"github.com/cryptogateway/backend-envoys/server/types"
 	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpclogrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	grpcctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
-	grpcopentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
@@ -46,6 +53,94 @@ import (
 	"google.golang.org/grpc/reflection"
 )
 
+// public - The methods of the services that are called without the authentication: the sign in and the sign up, the market data
+// and the callbacks of the providers. The user of the request is optional for them, they take it with the Auth function of
+// the context, the rest of the methods are called with the user only.
+var public = map[string]bool{
+	"/pb.ads.Api/GetAdvertisements":  true,
+	"/pb.ads.Api/GetAdvertising":     true,
+	"/pb.ads.Api/GetAnnouncements":   true,
+	"/pb.auth.Api/ActionReset":       true,
+	"/pb.auth.Api/ActionSignin":      true,
+	"/pb.auth.Api/ActionSignup":      true,
+	"/pb.auth.Api/ActionWallet":      true,
+	"/pb.auth.Api/GetNonce":          true,
+	"/pb.auth.Api/GetRefresh":        true,
+	"/pb.auth.Api/GetSecure":         true,
+	"/pb.auth.Api/SetLogout":         true,
+	"/pb.future.Api/GetFutures":      true,
+	"/pb.future.Api/GetOrders":       true,
+	"/pb.future.Api/GetTicker":       true,
+	"/pb.index.Api/GetEvents":        true,
+	"/pb.index.Api/GetMarkets":       true,
+	"/pb.index.Api/GetServerTime":    true,
+	"/pb.index.Api/GetStatistic":     true,
+	"/pb.index.Api/GetStatus":        true,
+	"/pb.kyc.Api/GetApplicant":       true,
+	"/pb.kyc.Api/GetPrivilege":       true,
+	"/pb.kyc.Api/GetStatus":          true,
+	"/pb.kyc.Api/SetCallback":        true,
+	"/pb.provider.Api/GetAssets":     true,
+	"/pb.provider.Api/GetBootstrap":  true,
+	"/pb.provider.Api/GetDepth":      true,
+	"/pb.provider.Api/GetIndicators": true,
+	"/pb.provider.Api/GetMarkers":    true,
+	"/pb.provider.Api/GetMarkets":    true,
+	"/pb.provider.Api/GetOrders":     true,
+	"/pb.provider.Api/GetPair":       true,
+	"/pb.provider.Api/GetPairs":      true,
+	"/pb.provider.Api/GetPrice":      true,
+	"/pb.provider.Api/GetSymbol":     true,
+	"/pb.provider.Api/GetTape":       true,
+	"/pb.provider.Api/GetTicker":     true,
+	"/pb.provider.Api/GetTicker24H":  true,
+	"/pb.provider.Api/GetTrades":     true,
+	"/pb.provider.Api/SetTicker":     true,
+	"/pb.spot.Api/GetCompetitions":   true,
+	"/pb.spot.Api/GetLeaderboard":    true,
+	"/pb.spot.Api/GetWithdrawFee":    true,
+	"/pb.stock.Api/GetBrokers":       true,
+	"/pb.stock.Api/GetCalendar":      true,
+	"/pb.stock.Api/GetLeaders":       true,
+
+	// The reflection of the services is used by the grpc clients of the developers to list the methods.
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": true,
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      true,
+}
+
+// restricted - The methods of the services that trade, withdraw, transfer or deposit the funds of the user, by the actions they
+// are checked for against the restrictions of the account before they are called.
+var restricted = map[string]string{
+	"/pb.future.Api/SetOrder":            types.ActionTrade,
+	"/pb.provider.Api/AmendOrder":        types.ActionTrade,
+	"/pb.provider.Api/SetAddress":        types.ActionDeposit,
+	"/pb.provider.Api/SetOrder":          types.ActionTrade,
+	"/pb.provider.Api/SetRule":           types.ActionTrade,
+	"/pb.provider.Api/SetWalletTransfer": types.ActionTransfer,
+	"/pb.spot.Api/SetWithdraw":           types.ActionWithdraw,
+	"/pb.stock.Api/SetCopy":              types.ActionTrade,
+	"/pb.stock.Api/SetTransfer":          types.ActionWithdraw,
+}
+
+// impersonable - The methods of the services the support staff call with the token of an impersonation, the read-only methods of
+// the balances, the orders and the transactions of the account. The public methods that read, such as the market data,
+// are called with it as well, the rest of the methods are refused.
+var impersonable = map[string]bool{
+	"/pb.future.Api/GetFutures":        true,
+	"/pb.future.Api/GetOrders":         true,
+	"/pb.provider.Api/GetAddresses":    true,
+	"/pb.provider.Api/GetAsset":        true,
+	"/pb.provider.Api/GetAssets":       true,
+	"/pb.provider.Api/GetHistory":      true,
+	"/pb.provider.Api/GetKillSwitch":   true,
+	"/pb.provider.Api/GetOrders":       true,
+	"/pb.provider.Api/GetPaper":        true,
+	"/pb.provider.Api/GetTrades":       true,
+	"/pb.provider.Api/GetTransactions": true,
+	"/pb.provider.Api/GetWallet":       true,
+	"/pb.stock.Api/GetTransfers":       true,
+}
+
 // Register - The purpose of this function is to create a gRPC server with certain options and to define a gateway for it. It sets
 // up a channel to listen on, creates TLS credentials, adds an interceptor for all, creates an array of gRPC options with
 // the credentials, registers the handler object, runs a spot service, registers reflection, serves and listens, and sets
@@ -63,6 +158,12 @@ func Register(option *assets.Context) {
 	// made to an Option object to a file, so that the same changes can be accessed later.
 	option.Write()
 
+	// The settings that can be changed while the process runs are reloaded on SIGHUP and when the configuration file is modified.
+	go option.Watch()
+
+	// The events which wait for the broker are delivered before the process exits on SIGINT or SIGTERM.
+	go option.Terminate()
+
 	// This is an anonymous function that is being called. The purpose of this function is to execute code asynchronously
 	// with the main program. It takes in a pointer to an assets context as an argument, which can then be accessed by the
 	// code inside the function. This allows the code inside the function to access and modify data from the main program.
@@ -140,10 +241,13 @@ func Register(option *assets.Context) {
 					grpclogrus.WithLevels(grpclogrus.DefaultCodeToLevel),
 				}...),
 
-				// The grpcopentracing.UnaryServerInterceptor() is a function that adds tracing capabilities to a gRPC server. It allows
-				// developers to trace requests through the server-side of the application and view performance metrics. This
-				// function is particularly useful for debugging, troubleshooting, and optimizing applications.
-				grpcopentracing.UnaryServerInterceptor(),
+				// The option.UnaryTrace() interceptor records the requests as the spans of their traces, continuing the trace of
+				// the caller from the traceparent header, the queries and the events of the handlers are the children of the span.
+				option.UnaryTrace(),
+
+				// The option.UnaryMetrics() interceptor counts the requests of the server by their methods and codes and observes
+				// their latency, the metrics are written in the text format of prometheus.
+				option.UnaryMetrics(),
 
 				// The grpc_recovery.UnaryServerInterceptor is a gRPC interceptor that adds support for recovery from panics in gRPC
 				// server applications. It provides an option to specify a custom recovery handler, which is what the
@@ -152,6 +256,24 @@ func Register(option *assets.Context) {
 				grpc_recovery.UnaryServerInterceptor([]grpc_recovery.Option{
 					grpc_recovery.WithRecoveryHandler(option.Recovery),
 				}...),
+
+				// The option.UnaryAuth(public) interceptor authenticates the requests by the bearer token or by the api key once
+				// per request, the methods that are not public are not called without the user, the handlers take the user
+				// from the context of the request.
+				option.UnaryAuth(public),
+
+				// The option.UnaryImpersonate(impersonable, public) interceptor keeps the impersonations of the support staff
+				// read-only, audits their requests and flags the responses of them in the headers.
+				option.UnaryImpersonate(impersonable, public),
+
+				// The option.UnaryLocale() interceptor resolves the locale of the user from the settings of the account or from
+				// the Accept-Language header, and translates the messages of the errors returned by the interceptors and the
+				// handlers after it.
+				option.UnaryLocale(),
+
+				// The option.UnaryRestrict(restricted) interceptor refuses the trading, the withdrawals, the transfers and the
+				// deposits of the accounts restricted by the compliance officers, after the user of the request is authenticated.
+				option.UnaryRestrict(restricted),
 			),
 
 			// The grpcmiddleware.WithStreamServerChain(...) is used to create a server-side middleware chain that can be used to intercept and modify requests and responses on a gRPC server.
@@ -170,11 +292,11 @@ func Register(option *assets.Context) {
 					grpclogrus.WithLevels(grpclogrus.DefaultCodeToLevel),
 				}...),
 
-				// grpcopentracing.StreamServerInterceptor() is a function that is used to create a stream server interceptor for
-				// OpenTracing. The purpose of the interceptor is to provide distributed tracing capabilities for the gRPC server.
-				// The interceptor is responsible for automatically tracing incoming requests and generating trace spans for each
-				// request. This allows developers to better monitor and debug their gRPC applications.
-				grpcopentracing.StreamServerInterceptor(),
+				// The option.StreamTrace() interceptor records the streams as the spans of their traces, like the unary requests.
+				option.StreamTrace(),
+
+				// The option.StreamMetrics() interceptor counts the streams of the server and observes their duration.
+				option.StreamMetrics(),
 
 				// The grpc_recovery.StreamServerInterceptor is a middleware that allows for recovery from unexpected panics that
 				// occur during the handling of gRPC requests. The grpc_recovery.WithRecoveryHandler() option specifies a function
@@ -183,6 +305,12 @@ func Register(option *assets.Context) {
 				grpc_recovery.StreamServerInterceptor([]grpc_recovery.Option{
 					grpc_recovery.WithRecoveryHandler(option.Recovery),
 				}...),
+
+				// The option.StreamAuth(public) interceptor authenticates the streams in the same way as the unary requests.
+				option.StreamAuth(public),
+
+				// The option.StreamImpersonate(impersonable, public) interceptor keeps the impersonations read-only for the streams.
+				option.StreamImpersonate(impersonable, public),
 			),
 
 			// The purpose of grpc.MaxConcurrentStreams(math.MaxUint32) is to set the maximum number of concurrent streams to the
@@ -203,12 +331,19 @@ func Register(option *assets.Context) {
 		serviceProvider.Initialization()
 		pbprovider.RegisterApiServer(srv, &provider.Service{Context: option})
 
-		pbstock.RegisterApiServer(srv, &stock.Service{Context: option})
+		serviceStock := stock.Service{Context: option}
+		serviceStock.Initialization()
+		pbstock.RegisterApiServer(srv, &serviceStock)
 		pbindex.RegisterApiServer(srv, &index.Service{Context: option})
 		pbauth.RegisterApiServer(srv, &auth.Service{Context: option})
-		pbaccount.RegisterApiServer(srv, &account.Service{Context: option})
-		pbads.RegisterApiServer(srv, &ads.Service{Context: option})
+		serviceAccount := account.Service{Context: option}
+		serviceAccount.Initialization()
+		pbaccount.RegisterApiServer(srv, &serviceAccount)
+		serviceAds := ads.Service{Context: option}
+		serviceAds.Initialization()
+		pbads.RegisterApiServer(srv, &serviceAds)
 		pbkyc.RegisterApiServer(srv, &kyc.Service{Context: option})
+		pbticket.RegisterApiServer(srv, &ticket.Service{Context: option})
 		// serviceFuture := future.Service{Context: option}
 		pbfuture.RegisterApiServer(srv, &future.Service{Context: option})
 
@@ -216,6 +351,8 @@ func Register(option *assets.Context) {
 		admin_pbads.RegisterApiServer(srv, &admin_ads.Service{Context: option})
 		admin_pbspot.RegisterApiServer(srv, &admin_spot.Service{Context: option})
 		admin_pbmarket.RegisterApiServer(srv, &admin_market.Service{Context: option})
+		admin_pbsearch.RegisterApiServer(srv, &admin_search.Service{Context: option})
+		admin_pbanalytics.RegisterApiServer(srv, &admin_analytics.Service{Context: option})
 
 		// Reflection.Register is a method that registers a service with a gRPC server. This method is used to create a service
 		// endpoint to allow clients to communicate with the server. The method sets up a connection between the server and the
@@ -231,14 +368,37 @@ func Register(option *assets.Context) {
 
 	}(option)
 
-	// This code is used to append a JSONPb marshaler to the MuxOptions slice if option.Development is true. The JSONPb
-	// marshaler is used to encode and decode messages in the JSON format. This marshaler also allows for the original
-	// naming of fields to be preserved, as well as providing a more readable "indented" format for the output JSON.
+	// This code forwards the signed request headers (timestamp, recv window and signature), the api key, the traceparent of the
+	// trace context and the preferred languages from the http gateway into the grpc metadata, the rest of the headers are matched
+	// by the default rules of the gateway.
+	MuxOptions = append(MuxOptions, runtime.WithIncomingHeaderMatcher(func(key string) (string, bool) {
+		switch strings.ToLower(key) {
+		case "timestamp", "recv-window", "signature", "api-key", "traceparent", "accept-language":
+			return strings.ToLower(key), true
+		}
+		return runtime.DefaultHeaderMatcher(key)
+	}))
+
+	// This code is used to append a JSONPb marshaler to the MuxOptions slice. The JSONPb marshaler is used to encode and
+	// decode messages in the JSON format, it preserves the original naming of fields, and if option.Development is true,
+	// it also provides a more readable "indented" format for the output JSON.
+	marshaler := &runtime.JSONPb{
+		OrigName: true,
+	}
 	if option.Development {
-		MuxOptions = append(MuxOptions, runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
-			OrigName: true,
-			Indent:   "   ",
-		}))
+		marshaler.Indent = "   "
+	}
+
+	// The floating point fields of the responses can be written as strings, the default format is taken from the
+	// configuration, and during the migration a client can choose the format itself with the Accept header.
+	MuxOptions = append(MuxOptions,
+		runtime.WithMarshalerOption(gateway.MIMEDecimalString, &gateway.DecimalMarshaler{JSONPb: marshaler}),
+		runtime.WithMarshalerOption(gateway.MIMEDecimalNumber, marshaler),
+	)
+	if option.DecimalFormat == assets.DecimalString {
+		MuxOptions = append(MuxOptions, runtime.WithMarshalerOption(runtime.MIMEWildcard, &gateway.DecimalMarshaler{JSONPb: marshaler}))
+	} else {
+		MuxOptions = append(MuxOptions, runtime.WithMarshalerOption(runtime.MIMEWildcard, marshaler))
 	}
 
 	// This code is used to create an HTTP/2 gateway server which allows clients to connect and access a gRPC service. The
@@ -1,7 +1,78 @@
 package admin_account
 
-import "github.com/cryptogateway/backend-envoys/assets"
+import (
+	"database/sql"
+	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
+)
 
 type Service struct {
 	Context *assets.Context
 }
+
+// querySurveillance - This function returns the cases of the market surveillance selected by the query, the query selects the columns
+// of the cases in the order of the fields of the types.SurveillanceCase. The evidence is the json snapshot of the trades and
+// the orders, and the time of the review is empty while the case is in the review.
+func (a *Service) querySurveillance(query string, args ...interface{}) (fields []*types.SurveillanceCase, err error) {
+
+	rows, err := a.Context.Db.Query(query, args...)
+	if err != nil {
+		return fields, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item     types.SurveillanceCase
+			reviewAt sql.NullString
+		)
+
+		if err := rows.Scan(&item.Id, &item.Kind, &item.UserId, pq.Array(&item.Related), &item.BaseUnit, &item.QuoteUnit, &item.Score, &item.Evidence, &item.Status, &item.Note, &item.OperatorId, &reviewAt, &item.CreateAt); err != nil {
+			return fields, err
+		}
+		item.ReviewAt = reviewAt.String
+
+		fields = append(fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fields, err
+	}
+
+	return fields, nil
+}
+
+// queryImpersonations - This function returns the impersonations of the accounts selected by the query, the query selects the
+// columns of the impersonations in the order of the fields of the types.Impersonation. The time of the end is empty
+// while the impersonation is not ended by the operator.
+func (a *Service) queryImpersonations(query string, args ...interface{}) (fields []*types.Impersonation, err error) {
+
+	rows, err := a.Context.Db.Query(query, args...)
+	if err != nil {
+		return fields, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item  types.Impersonation
+			endAt sql.NullString
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.OperatorId, &item.Reason, &item.Status, &item.Requests, &item.ExpireAt, &endAt, &item.CreateAt); err != nil {
+			return fields, err
+		}
+		item.EndAt = endAt.String
+
+		fields = append(fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fields, err
+	}
+
+	return fields, nil
+}
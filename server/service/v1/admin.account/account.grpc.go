@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbaccount"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/stock"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/ticket"
 	"github.com/cryptogateway/backend-envoys/server/types"
 	"google.golang.org/grpc/status"
 	"strings"
+	"time"
 )
 
 // GetAccounts - This function is used to retrieve a list of users accounts from a database and the associated rules associated with
@@ -18,24 +23,18 @@ import (
 func (a *Service) GetAccounts(ctx context.Context, req *admin_pbaccount.GetRequestUsers) (*admin_pbaccount.ResponseUser, error) {
 
 	// The purpose of the above code is to declare a number of variables for use within the program. Var response is of type
-	// admin_pbaccount.ResponseUser, and var migrate is of type query.Migrate. Var maps is an empty slice of strings, and var
-	// rules is an empty slice of bytes. All of these variables are declared for use within the program.
+	// admin_pbaccount.ResponseUser, and var migrate is of type query.Migrate. Var builder holds the conditions of the query,
+	// and var rules is an empty slice of bytes. All of these variables are declared for use within the program.
 	var (
 		response admin_pbaccount.ResponseUser
 		migrate  = query.Migrate{
 			Context: a.Context,
 		}
-		maps  []string
-		rules []byte
+		builder = query.NewBuilder()
+		rules   []byte
 	)
 
-	// This code is authenticating a user for a specific context. The 'a.Context.Auth(ctx)' function returns the user's
-	// authentication details and the return an error if the authentication fails. The
-	// purpose of this code is to ensure that the user is authenticated before proceeding with the requested action.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This code is checking to see if the user has the necessary permissions to write and edit data. If they do not, an
 	// error is returned. To migrate.Rules() function is used to check if the user has the necessary rights to perform the
@@ -45,17 +44,17 @@ func (a *Service) GetAccounts(ctx context.Context, req *admin_pbaccount.GetReque
 		return &response, status.Error(12011, "you do not have rules for writing and editing data")
 	}
 
-	// This code is checking the length of a request's search query. If the request has a search query, the code appends a
-	// SQL WHERE clause to the maps variable to search for matches in the database. The %[1]s is a placeholder for the
-	// search query.
+	// This code is checking the length of a request's search query. If the request has a search query, the code adds the
+	// condition to search for matches in the database, the search query is bound as the parameter.
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("where name like %[1]s or email like %[1]s", "'%"+req.GetSearch()+"%'"))
+		like := query.Like(req.GetSearch())
+		builder.Where("(name like ? or email like ?)", like, like)
 	}
 
 	// This code is used to query a database and store the result of the query in the response.Count variable. The query is
-	// configured using a SQL query string that is generated by concatenating the maps variable with a " " string. The Scan
+	// configured using a SQL query string with the where clause of the builder. The Scan
 	// function is used to store the result of the query in the response.Count variable.
-	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from accounts %s", strings.Join(maps, " "))).Scan(&response.Count); response.GetCount() > 0 {
+	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from accounts %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
 
 		// This code is calculating an offset for a page of results. The page and limit of the results are being requested by
 		// the req object. The offset is calculated by multiplying the limit by the page. If the page is greater than 0, then
@@ -71,7 +70,7 @@ func (a *Service) GetAccounts(ctx context.Context, req *admin_pbaccount.GetReque
 		// strings.Join and fmt.Sprintf functions are used to produce a valid SQL query string. The rows variable is used to
 		// store the results of the query in a row iterator. To defer rows.Close() statement is used to ensure that the row
 		// iterator is closed after the query has been completed.
-		rows, err := a.Context.Db.Query(fmt.Sprintf("select id, name, email, status, create_at, rules from accounts %s order by id desc limit %d offset %d", strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := a.Context.Db.Query(fmt.Sprintf("select id, name, email, status, priority, create_at, rules from accounts %s order by id desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -96,6 +95,7 @@ func (a *Service) GetAccounts(ctx context.Context, req *admin_pbaccount.GetReque
 				&item.Name,
 				&item.Email,
 				&item.Status,
+				&item.Priority,
 				&item.CreateAt,
 				&rules,
 			); err != nil {
@@ -159,13 +159,7 @@ func (a *Service) GetAccount(ctx context.Context, req *admin_pbaccount.GetReques
 		rules []byte
 	)
 
-	// The purpose of the code snippet is to authenticate a user. The variable "a" is assumed to be an instance of an
-	// application. The function "Context.Auth" is called to authenticate the user with the given context "ctx". If an error
-	// occurs, then the function "Context.Error" is called to return an appropriate error.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This code is checking if the user has the necessary permissions to modify the data in the accounts table. If the user
 	// does not have the appropriate rules, the code returns an error message.
@@ -176,7 +170,7 @@ func (a *Service) GetAccount(ctx context.Context, req *admin_pbaccount.GetReques
 	// This code is attempting to query a database for a user's account information based on their ID. The query will return
 	// the user's id, name, email, status, and rules and store them in the variables passed to the Scan() function. If the
 	// query fails, an error is returned.
-	if err := a.Context.Db.QueryRow("select id, name, email, status, rules from accounts where id = $1", req.GetId()).Scan(&user.Id, &user.Name, &user.Email, &user.Status, &rules); err != nil {
+	if err := a.Context.Db.QueryRow("select id, name, email, status, priority, rules from accounts where id = $1", req.GetId()).Scan(&user.Id, &user.Name, &user.Email, &user.Status, &user.Priority, &rules); err != nil {
 		return &response, err
 	}
 
@@ -209,12 +203,7 @@ func (a *Service) SetAccount(ctx context.Context, req *admin_pbaccount.SetReques
 		}
 	)
 
-	// This code snippet is used to authenticate a user in a given context. It assigns the authentication details to the
-	// variable auth, and if an error is encountered, it returns an error response and the corresponding error.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This code is checking if a user has the correct permissions to write and edit data. If they do not have the correct
 	// permissions, an error is returned with the message "you do not have rules for writing and editing data".
@@ -231,12 +220,13 @@ func (a *Service) SetAccount(ctx context.Context, req *admin_pbaccount.SetReques
 	}
 
 	// This code is executing an update query to the "accounts" table in the database. The purpose of this code is to update
-	// the name, status, and rules of an account in the database by its ID. The parameters of the query are passed in as
+	// the name, status, priority and rules of an account in the database by its ID. The parameters of the query are passed in as
 	// variables. If there is an error with executing the query, an error is returned.
-	if _, err := a.Context.Db.Exec("update accounts set name = $1, status = $2, rules = $3 where id = $4;",
+	if _, err := a.Context.Db.Exec("update accounts set name = $1, status = $2, rules = $3, priority = $4 where id = $5;",
 		req.User.GetName(),
 		req.User.GetStatus(),
 		serialize,
+		req.User.GetPriority(),
 		req.GetId(),
 	); err != nil {
 		return &response, err
@@ -244,3 +234,954 @@ func (a *Service) SetAccount(ctx context.Context, req *admin_pbaccount.SetReques
 
 	return &response, nil
 }
+
+// GetSupports - This function returns the support alerts of the priority accounts together with the state of their service level
+// agreement, that is the seconds remaining until the deadline and whether the deadline has been breached. The alerts can
+// be filtered by the user, by the status and by the breach of the deadline.
+func (a *Service) GetSupports(ctx context.Context, req *admin_pbaccount.GetRequestSupports) (*admin_pbaccount.ResponseSupport, error) {
+
+	// The purpose of this code is to declare the variables used by the function, the response, the rules checker and the filters of the query.
+	var (
+		response admin_pbaccount.ResponseSupport
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	auth := a.Context.User(ctx)
+
+	// This code checks that the user has the rules to manage the accounts, the alerts are a part of them.
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// This code builds the filters of the query from the request, the status is validated against the known statuses.
+	if req.GetUserId() > 0 {
+		builder.Where("user_id = ?", req.GetUserId())
+	}
+	if len(req.GetStatus()) > 0 {
+		if err := types.Status(req.GetStatus()); err != nil {
+			return &response, err
+		}
+		builder.Where("status = ?", req.GetStatus())
+	}
+	if req.GetBreached() {
+		builder.Where("status = ? and deadline < now()", types.StatusPending)
+	}
+
+	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from support %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		// The remaining time is counted in seconds until the deadline, it is negative when the deadline has been breached.
+		rows, err := a.Context.Db.Query(fmt.Sprintf("select id, user_id, kind, reference, status, level, deadline, coalesce(resolve_at::text, ''), create_at, extract(epoch from deadline - now())::bigint from support %s order by id desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Support
+			)
+
+			if err := rows.Scan(&item.Id, &item.UserId, &item.Kind, &item.Reference, &item.Status, &item.Level, &item.Deadline, &item.ResolveAt, &item.CreateAt, &item.Remaining); err != nil {
+				return &response, err
+			}
+
+			// The deadline is breached only for the alerts that are still waiting for the operators.
+			item.Breached = item.GetStatus() == types.StatusPending && item.GetRemaining() < 0
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetSupport - This function resolves the support alert with the given id, so that it is no longer escalated. The time of the
+// resolution is stored, which allows to measure how the service level agreement is met.
+func (a *Service) SetSupport(ctx context.Context, req *admin_pbaccount.SetRequestSupport) (*admin_pbaccount.ResponseSupport, error) {
+
+	// The purpose of this code is to declare the response and the rules checker used by the function.
+	var (
+		response admin_pbaccount.ResponseSupport
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	// This code checks that the user has the rules to manage the accounts and that the records are not denied for him.
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// This code resolves the alert if it is still pending, an alert which is already resolved is left as it is.
+	if _, err := a.Context.Db.Exec("update support set status = $1, resolve_at = now() where id = $2 and status = $3;", types.StatusFilled, req.GetId(), types.StatusPending); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetCommission - This function sets the commission of the broker: the share of the trade fees of the clients of the broker in
+// percent, which the settlement job of the stock service credits to the broker.
+func (a *Service) SetCommission(ctx context.Context, req *admin_pbaccount.SetRequestCommission) (*admin_pbaccount.ResponseCommission, error) {
+
+	var (
+		response admin_pbaccount.ResponseCommission
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if req.GetCommission() < 0 || req.GetCommission() > 100 {
+		return &response, status.Error(11680, "the commission must not be negative and must not be greater than 100 percent")
+	}
+
+	result, err := a.Context.Db.Exec("update agents set commission = $2 where id = $1 and type = $3", req.GetId(), req.GetCommission(), types.UserTypeBroker)
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11681, "the broker %v is not found", req.GetId())
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetApplications - This function returns the applications of the agents and the brokers with the name and the email of the
+// applicants and the number of the uploaded documents, the applications waiting for the review are listed by default.
+func (a *Service) GetApplications(ctx context.Context, req *admin_pbaccount.GetRequestApplications) (*admin_pbaccount.ResponseApplication, error) {
+
+	var (
+		response admin_pbaccount.ResponseApplication
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	if len(req.GetStatus()) == 0 {
+		req.Status = types.StatusReview
+	}
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = a.Context.Db.QueryRow("select count(*) as count from agents where status = $1", req.GetStatus()).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := a.Context.Db.Query(`select a.id, a.user_id, a.broker_id, a.name, coalesce(b.email, ''), a.type, a.status, a.details, a.reason, (select count(*) from agent_documents d where d.agent_id = a.id), a.create_at from agents a left join accounts b on b.id = a.user_id where a.status = $1 order by a.id desc limit $2 offset $3`, req.GetStatus(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item admin_pbaccount.Application
+			)
+
+			if err = rows.Scan(&item.Id, &item.UserId, &item.BrokerId, &item.Name, &item.Email, &item.Type, &item.Status, &item.Details, &item.Reason, &item.Documents, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	return &response, nil
+}
+
+// GetApplicationDocuments - This function returns the documents uploaded with the application of the agent together with the
+// content of the files, for the review of the application.
+func (a *Service) GetApplicationDocuments(ctx context.Context, req *admin_pbaccount.GetRequestApplicationDocuments) (*admin_pbaccount.ResponseApplicationDocument, error) {
+
+	var (
+		response admin_pbaccount.ResponseApplicationDocument
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := a.Context.Db.Query("select id, agent_id, kind, name, mime, data, create_at from agent_documents where agent_id = $1 order by id", req.GetId())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.AgentDocument
+		)
+
+		if err = rows.Scan(&item.Id, &item.AgentId, &item.Kind, &item.Name, &item.Mime, &item.Data, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, nil
+}
+
+// SetApplication - This function approves or rejects the application of the agent, or blocks and unblocks the approved agent.
+// The change is validated against the transitions allowed to the administrators and audited with the reason, the audit of
+// the agent is returned along with the application.
+func (a *Service) SetApplication(ctx context.Context, req *admin_pbaccount.SetRequestApplication) (*admin_pbaccount.ResponseApplication, error) {
+
+	var (
+		response admin_pbaccount.ResponseApplication
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_stock = stock.Service{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item, err := _stock.WriteTransition(req.GetId(), req.GetStatus(), auth, types.ActorAdmin, req.GetReason())
+	if err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, &admin_pbaccount.Application{
+		Id:       item.GetId(),
+		UserId:   item.GetUserId(),
+		BrokerId: item.GetBrokerId(),
+		Type:     item.GetType(),
+		Status:   item.GetStatus(),
+		Reason:   item.GetReason(),
+	})
+
+	if response.Transitions, err = _stock.QueryTransitions(item.GetId()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetPrivacyRequests - This function returns the requests of the users for the export of the personal data and for the closure of
+// the accounts with the emails of the users, filtered by the kind, the status and the user. The pending requests are
+// listed by default, the closures in the order they are due, so that the operators see the accounts about to be anonymized.
+func (a *Service) GetPrivacyRequests(ctx context.Context, req *admin_pbaccount.GetRequestPrivacyRequests) (*admin_pbaccount.ResponsePrivacy, error) {
+
+	var (
+		response admin_pbaccount.ResponsePrivacy
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_account = account.Service{
+			Context: a.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	if len(req.GetStatus()) == 0 {
+		req.Status = types.StatusPending
+	}
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.Status(req.GetStatus()); err != nil {
+		return &response, err
+	}
+	builder.Where("p.status = ?", req.GetStatus())
+
+	if len(req.GetKind()) > 0 {
+		if err := types.PrivacyKind(req.GetKind()); err != nil {
+			return &response, err
+		}
+		builder.Where("p.kind = ?", req.GetKind())
+	}
+
+	if req.GetUserId() > 0 {
+		builder.Where("p.user_id = ?", req.GetUserId())
+	}
+
+	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from privacy_requests p %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		fields, err := _account.QueryPrivacy(fmt.Sprintf("select p.id, p.user_id, coalesce(a.email, ''), p.kind, p.status, p.reason, p.execute_at, p.process_at, p.create_at from privacy_requests p left join accounts a on a.id = p.user_id %s order by p.execute_at, p.id limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = fields
+	}
+
+	return &response, nil
+}
+
+// CancelPrivacyRequest - This function cancels the pending privacy request of the user with the reason, such as the closure of
+// an account under an investigation or a legal hold, the user is notified of the cancelled closure with the reason.
+func (a *Service) CancelPrivacyRequest(ctx context.Context, req *admin_pbaccount.CancelRequestPrivacyRequest) (*admin_pbaccount.ResponsePrivacy, error) {
+
+	var (
+		response admin_pbaccount.ResponsePrivacy
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_account = account.Service{
+			Context: a.Context,
+		}
+		err error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if len(req.GetReason()) == 0 {
+		return &response, status.Error(11749, "the reason of the cancellation is required")
+	}
+
+	if response.Fields, err = _account.QueryPrivacy("update privacy_requests set status = $2, reason = $3, process_at = now() where id = $1 and status = $4 returning id, user_id, '', kind, status, reason, execute_at, process_at, create_at", req.GetId(), types.StatusCancel, req.GetReason(), types.StatusPending); err != nil {
+		return &response, err
+	}
+
+	if len(response.GetFields()) == 0 {
+		return &response, status.Errorf(11748, "the pending privacy request %v is not found", req.GetId())
+	}
+
+	if item := response.GetFields()[0]; item.GetKind() == types.PrivacyClosure {
+		go migrate.SendMail(item.GetUserId(), "closure_cancel", req.GetReason())
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetRestrictions - This function returns the restrictions of the accounts applied by the compliance officers, of the user or of
+// all the users, the active ones only on request, the latest first.
+func (a *Service) GetRestrictions(ctx context.Context, req *admin_pbaccount.GetRequestRestrictions) (*admin_pbaccount.ResponseRestriction, error) {
+
+	var (
+		response admin_pbaccount.ResponseRestriction
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_account = account.Service{
+			Context: a.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if req.GetUserId() > 0 {
+		builder.Where("user_id = ?", req.GetUserId())
+	}
+
+	if req.GetActive() {
+		builder.Where("status = true and (expire_at is null or expire_at > now())")
+	}
+
+	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from restrictions %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		fields, err := _account.QueryRestrictions(fmt.Sprintf("select id, user_id, kind, reason, note, operator_id, status, expire_at, lift_at, create_at from restrictions %s order by id desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = fields
+	}
+
+	return &response, nil
+}
+
+// SetRestriction - This function applies the restriction to the account of the user: the trade ban, the withdraw ban, the
+// deposit-only account or the frozen account, with the reason code, the note of the officer and the optional time of the
+// expiry. The restriction applies at once on all the instances, and the user is notified of it with the reason code.
+func (a *Service) SetRestriction(ctx context.Context, req *admin_pbaccount.SetRequestRestriction) (*admin_pbaccount.ResponseRestriction, error) {
+
+	var (
+		response admin_pbaccount.ResponseRestriction
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_account = account.Service{
+			Context: a.Context,
+		}
+		expireAt interface{}
+		exist    bool
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.RestrictionKind(req.GetKind()); err != nil {
+		return &response, err
+	}
+
+	if err := types.RestrictionReason(req.GetReason()); err != nil {
+		return &response, err
+	}
+
+	if _ = a.Context.Db.QueryRow("select exists(select 1 from accounts where id = $1)", req.GetUserId()).Scan(&exist); !exist {
+		return &response, status.Errorf(11754, "the account %v is not found", req.GetUserId())
+	}
+
+	// The restriction without the time of the expiry lasts until it is lifted.
+	if len(req.GetExpireAt()) > 0 {
+
+		expire, err := time.Parse(time.RFC3339, req.GetExpireAt())
+		if err != nil || !expire.After(time.Now()) {
+			return &response, status.Errorf(11753, "the restriction must expire in the future, the time %v is not valid", req.GetExpireAt())
+		}
+		expireAt = expire
+	}
+
+	if response.Fields, err = _account.QueryRestrictions("insert into restrictions (user_id, kind, reason, note, operator_id, expire_at) values ($1, $2, $3, $4, $5, $6) returning id, user_id, kind, reason, note, operator_id, status, expire_at, lift_at, create_at", req.GetUserId(), req.GetKind(), req.GetReason(), req.GetNote(), auth, expireAt); err != nil {
+		return &response, err
+	}
+
+	if err := a.Context.RedisClient.Del(context.Background(), assets.RestrictionKey(req.GetUserId())).Err(); err != nil {
+		return &response, err
+	}
+
+	expiry := ""
+	if len(req.GetExpireAt()) > 0 {
+		expiry = fmt.Sprintf(" until %v", req.GetExpireAt())
+	}
+
+	go migrate.SendMail(req.GetUserId(), "restriction", req.GetKind(), req.GetReason(), expiry)
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteRestriction - This function lifts the active restriction of the account, the restriction is kept in the history with the
+// time it was lifted at and the note of the officer who lifted it, and the user is notified.
+func (a *Service) DeleteRestriction(ctx context.Context, req *admin_pbaccount.DeleteRequestRestriction) (*admin_pbaccount.ResponseRestriction, error) {
+
+	var (
+		response admin_pbaccount.ResponseRestriction
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_account = account.Service{
+			Context: a.Context,
+		}
+		err error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if response.Fields, err = _account.QueryRestrictions("update restrictions set status = false, lift_at = now(), note = case when $2 = '' then note else note || ' / ' || $2 end where id = $1 and status = true returning id, user_id, kind, reason, note, operator_id, status, expire_at, lift_at, create_at", req.GetId(), req.GetNote()); err != nil {
+		return &response, err
+	}
+
+	if len(response.GetFields()) == 0 {
+		return &response, status.Errorf(11754, "the active restriction %v is not found", req.GetId())
+	}
+
+	item := response.GetFields()[0]
+	if err := a.Context.RedisClient.Del(context.Background(), assets.RestrictionKey(item.GetUserId())).Err(); err != nil {
+		return &response, err
+	}
+
+	go migrate.SendMail(item.GetUserId(), "restriction_lift", item.GetKind())
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetImpersonations - This function returns the impersonations of the accounts by the support staff with the reasons and the number
+// of the requests made with them, of the user or of the operator, the active ones only on request, the latest first. The
+// requests themselves are audited in the impersonation_requests table.
+func (a *Service) GetImpersonations(ctx context.Context, req *admin_pbaccount.GetRequestImpersonations) (*admin_pbaccount.ResponseImpersonation, error) {
+
+	var (
+		response admin_pbaccount.ResponseImpersonation
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if req.GetUserId() > 0 {
+		builder.Where("user_id = ?", req.GetUserId())
+	}
+
+	if req.GetOperatorId() > 0 {
+		builder.Where("operator_id = ?", req.GetOperatorId())
+	}
+
+	if req.GetActive() {
+		builder.Where("status = true and expire_at > now()")
+	}
+
+	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from impersonations %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		fields, err := a.queryImpersonations(fmt.Sprintf("select id, user_id, operator_id, reason, status, requests, expire_at, end_at, create_at from impersonations %s order by id desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = fields
+	}
+
+	return &response, nil
+}
+
+// SetImpersonation - This function starts the read-only impersonation of the account by the operator of the support with the
+// reason, and returns the access token of it. The token shows the balances, the orders and the transactions of the user
+// as the user sees them, it can not change anything, every request made with it is audited and flagged in the headers
+// of the response, and it expires in half an hour.
+func (a *Service) SetImpersonation(ctx context.Context, req *admin_pbaccount.SetRequestImpersonation) (*admin_pbaccount.ResponseImpersonation, error) {
+
+	var (
+		response admin_pbaccount.ResponseImpersonation
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		expireAt = time.Now().Add(assets.ImpersonationLifetime)
+		exist    bool
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if len(strings.TrimSpace(req.GetReason())) == 0 {
+		return &response, status.Error(11801, "the reason of the impersonation is required")
+	}
+
+	if _ = a.Context.Db.QueryRow("select exists(select 1 from accounts where id = $1)", req.GetUserId()).Scan(&exist); !exist || req.GetUserId() == auth {
+		return &response, status.Errorf(11802, "the account %v can not be impersonated", req.GetUserId())
+	}
+
+	if response.Fields, err = a.queryImpersonations("insert into impersonations (user_id, operator_id, reason, expire_at) values ($1, $2, $3, $4) returning id, user_id, operator_id, reason, status, requests, expire_at, end_at, create_at", req.GetUserId(), auth, strings.TrimSpace(req.GetReason()), expireAt); err != nil {
+		return &response, err
+	}
+
+	item := response.GetFields()[0]
+	if response.AccessToken, err = a.Context.Impersonate(item.GetId(), item.GetUserId(), auth, expireAt); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteImpersonation - This function ends the active impersonation before it expires, the token of it is no longer accepted. The
+// impersonation is kept in the audit with the time it was ended at.
+func (a *Service) DeleteImpersonation(ctx context.Context, req *admin_pbaccount.DeleteRequestImpersonation) (*admin_pbaccount.ResponseImpersonation, error) {
+
+	var (
+		response admin_pbaccount.ResponseImpersonation
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		err error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if response.Fields, err = a.queryImpersonations("update impersonations set status = false, end_at = now() where id = $1 and status = true returning id, user_id, operator_id, reason, status, requests, expire_at, end_at, create_at", req.GetId()); err != nil {
+		return &response, err
+	}
+
+	if len(response.GetFields()) == 0 {
+		return &response, status.Errorf(11802, "the active impersonation %v is not found", req.GetId())
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetSurveillanceCases - This function returns the cases of the market surveillance queued for the review of the compliance
+// officers with the snapshot of the evidence, filtered by the kind, the status and the account, the highest scores first.
+func (a *Service) GetSurveillanceCases(ctx context.Context, req *admin_pbaccount.GetRequestSurveillanceCases) (*admin_pbaccount.ResponseSurveillance, error) {
+
+	var (
+		response admin_pbaccount.ResponseSurveillance
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if len(req.GetKind()) > 0 {
+		if err := types.SurveillanceKind(req.GetKind()); err != nil {
+			return &response, err
+		}
+		builder.Where("kind = ?", req.GetKind())
+	}
+
+	if len(req.GetStatus()) > 0 {
+		if err := types.CaseStatus(req.GetStatus()); err != nil {
+			return &response, err
+		}
+		builder.Where("status = ?", req.GetStatus())
+	}
+
+	if req.GetUserId() > 0 {
+		builder.Where("(user_id = ? or ? = any(related))", req.GetUserId(), req.GetUserId())
+	}
+
+	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from surveillance_cases %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		fields, err := a.querySurveillance(fmt.Sprintf("select id, kind, user_id, related, base_unit, quote_unit, score, evidence, status, note, operator_id, review_at, create_at from surveillance_cases %s order by score desc, id desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = fields
+	}
+
+	return &response, nil
+}
+
+// SetSurveillanceCase - This function records the decision of the compliance officer on the case of the market surveillance: the
+// case is confirmed or dismissed with the note of the officer, or is returned to the review. The confirmed case does not
+// restrict the account by itself, the officer applies the restriction the case calls for separately.
+func (a *Service) SetSurveillanceCase(ctx context.Context, req *admin_pbaccount.SetRequestSurveillanceCase) (*admin_pbaccount.ResponseSurveillance, error) {
+
+	var (
+		response admin_pbaccount.ResponseSurveillance
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		err error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.CaseStatus(req.GetStatus()); err != nil {
+		return &response, err
+	}
+
+	if req.GetStatus() != types.StatusReview && len(req.GetNote()) == 0 {
+		return &response, status.Error(11755, "the note of the decision on the case is required")
+	}
+
+	if response.Fields, err = a.querySurveillance("update surveillance_cases set status = $2, note = $3, operator_id = $4, review_at = case when $2 = $5 then null else now() end where id = $1 returning id, kind, user_id, related, base_unit, quote_unit, score, evidence, status, note, operator_id, review_at, create_at", req.GetId(), req.GetStatus(), req.GetNote(), auth, types.StatusReview); err != nil {
+		return &response, err
+	}
+
+	if len(response.GetFields()) == 0 {
+		return &response, status.Errorf(11756, "the surveillance case %v is not found", req.GetId())
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetTickets - This function returns the support tickets of the users for the staff without their messages, filtered by the status,
+// the category, the user and the member of the staff the ticket is assigned to. The tickets waiting the longest come first.
+func (a *Service) GetTickets(ctx context.Context, req *admin_pbaccount.GetRequestTickets) (*admin_pbaccount.ResponseTicket, error) {
+
+	var (
+		response admin_pbaccount.ResponseTicket
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_ticket = ticket.Service{
+			Context: a.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if len(req.GetStatus()) > 0 {
+		if err := types.TicketStatus(req.GetStatus()); err != nil {
+			return &response, err
+		}
+		builder.Where("status = ?", req.GetStatus())
+	}
+
+	if len(req.GetCategory()) > 0 {
+		if err := types.TicketCategory(req.GetCategory()); err != nil {
+			return &response, err
+		}
+		builder.Where("category = ?", req.GetCategory())
+	}
+
+	if req.GetUserId() > 0 {
+		builder.Where("user_id = ?", req.GetUserId())
+	}
+
+	if req.GetOperatorId() > 0 {
+		builder.Where("operator_id = ?", req.GetOperatorId())
+	}
+
+	if _ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from tickets %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		fields, err := _ticket.QueryTickets(fmt.Sprintf("select id, user_id, category, subject, status, context, operator_id, update_at, close_at, create_at from tickets %s order by update_at, id limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = fields
+	}
+
+	return &response, nil
+}
+
+// GetTicket - This function returns the support ticket for the staff with the snapshot of the account it was opened with, its
+// messages and the list of the files attached to them.
+func (a *Service) GetTicket(ctx context.Context, req *admin_pbaccount.GetRequestTicket) (*admin_pbaccount.ResponseTicket, error) {
+
+	var (
+		response admin_pbaccount.ResponseTicket
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_ticket = ticket.Service{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item, err := _ticket.QueryTicket(req.GetId(), 0)
+	if err != nil {
+		return &response, err
+	}
+	response.Fields = append(response.Fields, item)
+
+	return &response, nil
+}
+
+// SetTicketMessage - This function adds the reply of the staff to the support ticket with the files attached to it, the ticket is
+// answered and assigned to the member of the staff who replied. The user is notified of the reply by email as well as
+// over the broker.
+func (a *Service) SetTicketMessage(ctx context.Context, req *admin_pbaccount.SetRequestTicketMessage) (*admin_pbaccount.ResponseTicket, error) {
+
+	var (
+		response admin_pbaccount.ResponseTicket
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_ticket = ticket.Service{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item, err := _ticket.QueryTicket(req.GetTicketId(), 0)
+	if err != nil {
+		return &response, err
+	}
+
+	if _, err := _ticket.WriteMessage(item, auth, true, req.GetText(), req.GetAttachments()); err != nil {
+		return &response, err
+	}
+
+	go migrate.SendMail(item.GetUserId(), "ticket_reply", item.GetId(), item.GetSubject())
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetTicketStatus - This function moves the support ticket along its workflow on behalf of the staff: the ticket is resolved when
+// the answer solved the question, closed when it takes no more replies, or opened again.
+func (a *Service) SetTicketStatus(ctx context.Context, req *admin_pbaccount.SetRequestTicketStatus) (*admin_pbaccount.ResponseTicket, error) {
+
+	var (
+		response admin_pbaccount.ResponseTicket
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_ticket = ticket.Service{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.TicketStatus(req.GetStatus()); err != nil {
+		return &response, err
+	}
+
+	item, err := _ticket.QueryTicket(req.GetId(), 0)
+	if err != nil {
+		return &response, err
+	}
+
+	if err := _ticket.WriteStatus(item, req.GetStatus()); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetTicketAttachment - This function returns the file attached to the message of the support ticket with its content for the staff.
+func (a *Service) GetTicketAttachment(ctx context.Context, req *admin_pbaccount.GetRequestTicketAttachment) (*admin_pbaccount.ResponseTicketAttachment, error) {
+
+	var (
+		response admin_pbaccount.ResponseTicketAttachment
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		_ticket = ticket.Service{
+			Context: a.Context,
+		}
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item, err := _ticket.QueryAttachment(req.GetId(), 0)
+	if err != nil {
+		return &response, err
+	}
+	response.Fields = append(response.Fields, item)
+
+	return &response, nil
+}
@@ -5,8 +5,10 @@ import (
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	admin_pbads "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbads"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/status"
+	"time"
 )
 
 // SetAdvertising - This function is a service function that is used to set an advertisement rule for the application. It checks for
@@ -24,13 +26,7 @@ func (s *Service) SetAdvertising(ctx context.Context, req *admin_pbads.SetReques
 		}
 	)
 
-	// This code is checking for authentication. The purpose of this code is to ensure that the user is authenticated before
-	// continuing with the request. The s.Context.Auth() function is used to check the authentication and if there is an
-	// error it will be returned with the s.Context.Error() function. If the authentication check is successful, the request will continue.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code checks if a user has the appropriate permissions to write and edit data. If the user does not have the
 	// correct rules, then an error is returned.
@@ -117,13 +113,7 @@ func (s *Service) DeleteAdvertising(ctx context.Context, req *admin_pbads.Delete
 		}
 	)
 
-	// This code is used to authenticate a user. The s.Context.Auth(ctx) function attempts to authenticate the user using
-	// the ctx context. If it succeeds, it returns an auth object. If it fails, it returns an error. The if statement checks
-	// if an error occurred. If so, it returns an empty response and the error.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This if statement is checking the user's authorization to write and edit data. If the user does not have the
 	// necessary rules, the statement will return an error message indicating they do not have the appropriate permissions.
@@ -134,7 +124,7 @@ func (s *Service) DeleteAdvertising(ctx context.Context, req *admin_pbads.Delete
 	// This code is checking for an error when deleting an entry from the advertising table in a database. It is using the
 	// Exec() method to attempt to delete the entry with the ID specified in the req parameter. If there is an error, it
 	// will return &response and an error message.
-	if _, err = s.Context.Db.Exec("delete from advertising where id = $1", req.GetId()); err != nil {
+	if _, err := s.Context.Db.Exec("delete from advertising where id = $1", req.GetId()); err != nil {
 		return &response, err
 	}
 
@@ -148,3 +138,180 @@ func (s *Service) DeleteAdvertising(ctx context.Context, req *admin_pbads.Delete
 
 	return &response, nil
 }
+
+// GetAnnouncements - This function returns the announcements for the operators, including the scheduled, the ended and the disabled
+// ones, the latest announcements first. It checks the authentication of the user and the rules for the advertising.
+func (s *Service) GetAnnouncements(ctx context.Context, req *admin_pbads.GetRequestAnnouncements) (*admin_pbads.ResponseAnnouncement, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbads.ResponseAnnouncement
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := s.Context.User(ctx)
+
+	// This code checks if a user has the appropriate permissions to write and edit data.
+	if !migrate.Rules(auth, "advertising", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = s.Context.Db.QueryRow("select count(*) as count from announcements").Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := s.Context.Db.Query("select id, kind, title, text, link, audience, users, start_at, coalesce(end_at::text, ''), status, create_at from announcements order by id desc limit $1 offset $2", req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Announcement
+			)
+
+			if err := rows.Scan(&item.Id, &item.Kind, &item.Title, &item.Text, &item.Link, &item.Audience, pq.Array(&item.Users), &item.StartAt, &item.EndAt, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetAnnouncement - This function creates or updates an announcement. The kind and the audience are validated, the title is
+// required, the announcement of the listed users must list at least one user, and the end, if it is set, must be after
+// the start. An empty start starts the announcement at once. The times are passed in the RFC 3339 format. The changed
+// announcement is pushed to the users again if it is shown.
+func (s *Service) SetAnnouncement(ctx context.Context, req *admin_pbads.SetRequestAnnouncement) (*admin_pbads.ResponseAnnouncement, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbads.ResponseAnnouncement
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+	)
+
+	auth := s.Context.User(ctx)
+
+	// This code checks if a user has the appropriate permissions to write and edit data.
+	if !migrate.Rules(auth, "advertising", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.AnnouncementKind(req.Announcement.GetKind()); err != nil {
+		return &response, err
+	}
+
+	if err := types.Audience(req.Announcement.GetAudience()); err != nil {
+		return &response, err
+	}
+
+	if len(req.Announcement.GetTitle()) == 0 {
+		return &response, status.Error(11737, "the title of the announcement is required")
+	}
+
+	// The list of the users is kept for the announcements of the listed users only.
+	if req.Announcement.GetAudience() != types.AudienceUsers {
+		req.Announcement.Users = nil
+	} else if len(req.Announcement.GetUsers()) == 0 {
+		return &response, status.Error(11738, "the announcement of the listed users must list at least one user")
+	}
+
+	// This code checks the start and the end of the announcement, an empty start is the current time.
+	start := time.Now().UTC()
+	if len(req.Announcement.GetStartAt()) > 0 {
+		var err error
+		if start, err = time.Parse(time.RFC3339, req.Announcement.GetStartAt()); err != nil {
+			return &response, status.Error(11739, "the start of the announcement must be set in the RFC 3339 format")
+		}
+	}
+	if len(req.Announcement.GetEndAt()) > 0 {
+		if end, err := time.Parse(time.RFC3339, req.Announcement.GetEndAt()); err != nil || !end.After(start) {
+			return &response, status.Error(11740, "the end of the announcement must be after its start")
+		}
+	}
+
+	if req.GetId() > 0 {
+
+		// This code updates the announcement with the values of the request, an empty end removes the end of the announcement.
+		if _, err := s.Context.Db.Exec("update announcements set kind = $1, title = $2, text = $3, link = $4, audience = $5, users = $6, start_at = $7, end_at = nullif($8, '')::timestamptz, status = $9, published = false where id = $10;",
+			req.Announcement.GetKind(),
+			req.Announcement.GetTitle(),
+			req.Announcement.GetText(),
+			req.Announcement.GetLink(),
+			req.Announcement.GetAudience(),
+			pq.Array(req.Announcement.GetUsers()),
+			start,
+			req.Announcement.GetEndAt(),
+			req.Announcement.GetStatus(),
+			req.GetId(),
+		); err != nil {
+			return &response, err
+		}
+
+	} else {
+
+		if err := s.Context.Db.QueryRow("insert into announcements (kind, title, text, link, audience, users, start_at, end_at, status) values ($1, $2, $3, $4, $5, $6, $7, nullif($8, '')::timestamptz, $9) returning id;",
+			req.Announcement.GetKind(),
+			req.Announcement.GetTitle(),
+			req.Announcement.GetText(),
+			req.Announcement.GetLink(),
+			req.Announcement.GetAudience(),
+			pq.Array(req.Announcement.GetUsers()),
+			start,
+			req.Announcement.GetEndAt(),
+			req.Announcement.GetStatus(),
+		).Scan(&req.Id); err != nil {
+			return &response, err
+		}
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteAnnouncement - This function removes the announcement with the given id together with its dismissals.
+func (s *Service) DeleteAnnouncement(ctx context.Context, req *admin_pbads.DeleteRequestAnnouncement) (*admin_pbads.ResponseAnnouncement, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbads.ResponseAnnouncement
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+	)
+
+	auth := s.Context.User(ctx)
+
+	// This code checks if a user has the appropriate permissions to write and edit data.
+	if !migrate.Rules(auth, "advertising", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _, err := s.Context.Db.Exec("delete from announcements where id = $1", req.GetId()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
@@ -0,0 +1,40 @@
+package admin_analytics
+
+import (
+	"time"
+
+	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"google.golang.org/grpc/status"
+)
+
+// Service - The type Service struct is used to store a pointer to an assets.Context object. The analytics service aggregates the
+// metrics of the exchange for the dashboard of the administrators: the volumes of the pairs, the revenue of the fees, the
+// registrations of the users, the flows of the deposits and the withdrawals, and the reserves against the obligations.
+type Service struct {
+	Context *assets.Context
+}
+
+// queryInterval - This function checks the interval the metrics are grouped by, the metrics are grouped by the day by default.
+func (s *Service) queryInterval(interval string) (string, error) {
+
+	switch interval {
+	case "":
+		return "day", nil
+	case "day", "week", "month":
+		return interval, nil
+	}
+
+	return "", status.Errorf(11731, "the interval %v is not known, the intervals are: day, week, month", interval)
+}
+
+// queryRange - This function adds the date range of the metrics to the conditions, the metrics of the last thirty days are
+// aggregated when the start of the range is not given, so that the dashboard never reads the whole history by default.
+func (s *Service) queryRange(builder *query.Builder, column, from, to string) error {
+
+	if len(from) == 0 {
+		from = time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	return builder.Range(column, from, to)
+}
@@ -0,0 +1,310 @@
+package admin_analytics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	admin_pbanalytics "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbanalytics"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+)
+
+// GetVolumes - This function returns the trading volume of the pairs by the day, the week or the month: the volume in the base
+// unit, the turnover in the quote unit and the number of the trades. Every trade is written once for each of its orders,
+// so the trades are counted by their sell orders only. The volumes are read from the replica of the database.
+func (s *Service) GetVolumes(ctx context.Context, req *admin_pbanalytics.GetRequestVolumes) (*admin_pbanalytics.ResponseVolume, error) {
+
+	var (
+		response admin_pbanalytics.ResponseVolume
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	auth := s.Context.User(ctx)
+
+	if !migrate.Rules(auth, "analytics", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	interval, err := s.queryInterval(req.GetInterval())
+	if err != nil {
+		return &response, err
+	}
+
+	builder.Where("assigning = ?", types.AssigningSell)
+
+	if len(req.GetBaseUnit()) > 0 {
+		builder.Where("base_unit = ?", req.GetBaseUnit())
+	}
+
+	if len(req.GetQuoteUnit()) > 0 {
+		builder.Where("quote_unit = ?", req.GetQuoteUnit())
+	}
+
+	if err := s.queryRange(builder, "create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	rows, err := s.Context.Reader().Query(fmt.Sprintf(`select extract(epoch from date_trunc(%[2]s::text, create_at))::bigint as time, base_unit, quote_unit, coalesce(sum(quantity), 0), coalesce(sum(quantity * price), 0), count(*) from trades %[1]s group by time, base_unit, quote_unit order by time, base_unit, quote_unit`, builder.Clause(), builder.Bind(interval)), builder.Params()...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item admin_pbanalytics.Volume
+		)
+
+		if err := rows.Scan(&item.Time, &item.BaseUnit, &item.QuoteUnit, &item.Volume, &item.Turnover, &item.Count); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// GetFees - This function returns the revenue of the fees by the currency and by the day, the week or the month, it is the history
+// of the fees_charges of the assets. The fees of the trades are written in the base unit, the fees of the sell orders are
+// charged in the quote unit, so they are converted back by the price of the trade.
+func (s *Service) GetFees(ctx context.Context, req *admin_pbanalytics.GetRequestFees) (*admin_pbanalytics.ResponseFee, error) {
+
+	var (
+		response admin_pbanalytics.ResponseFee
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		builder = query.NewBuilder(types.AssigningBuy)
+	)
+
+	auth := s.Context.User(ctx)
+
+	if !migrate.Rules(auth, "analytics", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	interval, err := s.queryInterval(req.GetInterval())
+	if err != nil {
+		return &response, err
+	}
+
+	builder.Where("fees > ?", 0)
+
+	// The buy orders pay the fees in the base unit and the sell orders in the quote unit, the buy side is the first parameter.
+	if len(req.GetSymbol()) > 0 {
+		builder.Where("((assigning = $1 and base_unit = ?) or (assigning <> $1 and quote_unit = ?))", req.GetSymbol(), req.GetSymbol())
+	}
+
+	if err := s.queryRange(builder, "create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	rows, err := s.Context.Reader().Query(fmt.Sprintf(`select extract(epoch from date_trunc(%[2]s::text, create_at))::bigint as time, case when assigning = $1 then base_unit else quote_unit end as symbol, sum(case when assigning = $1 then fees else fees * price end) from trades %[1]s group by time, symbol order by time, symbol`, builder.Clause(), builder.Bind(interval)), builder.Params()...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item admin_pbanalytics.Fee
+		)
+
+		if err := rows.Scan(&item.Time, &item.Symbol, &item.Value); err != nil {
+			return &response, err
+		}
+
+		response.Total = decimal.New(response.GetTotal()).Add(item.GetValue()).Float()
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// GetRegistrations - This function returns the number of the users registered by the day, the week or the month, together with
+// the number of them whose accounts are active.
+func (s *Service) GetRegistrations(ctx context.Context, req *admin_pbanalytics.GetRequestRegistrations) (*admin_pbanalytics.ResponseRegistration, error) {
+
+	var (
+		response admin_pbanalytics.ResponseRegistration
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	auth := s.Context.User(ctx)
+
+	if !migrate.Rules(auth, "analytics", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	interval, err := s.queryInterval(req.GetInterval())
+	if err != nil {
+		return &response, err
+	}
+
+	if err := s.queryRange(builder, "create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	rows, err := s.Context.Reader().Query(fmt.Sprintf(`select extract(epoch from date_trunc(%[2]s::text, create_at))::bigint as time, count(*), count(*) filter (where status) from accounts %[1]s group by time order by time`, builder.Clause(), builder.Bind(interval)), builder.Params()...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item admin_pbanalytics.Registration
+		)
+
+		if err := rows.Scan(&item.Time, &item.Count, &item.Active); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// GetFlows - This function returns the deposits and the withdrawals of the currencies by the day, the week or the month, and their
+// net flow. Only the processed external transactions are counted, the internal transfers between the users do not move
+// the funds in or out of the exchange.
+func (s *Service) GetFlows(ctx context.Context, req *admin_pbanalytics.GetRequestFlows) (*admin_pbanalytics.ResponseFlow, error) {
+
+	var (
+		response admin_pbanalytics.ResponseFlow
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		builder = query.NewBuilder(types.AssignmentDeposit, types.AssignmentWithdrawal)
+	)
+
+	auth := s.Context.User(ctx)
+
+	if !migrate.Rules(auth, "analytics", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	interval, err := s.queryInterval(req.GetInterval())
+	if err != nil {
+		return &response, err
+	}
+
+	builder.Where("status = ?", types.StatusFilled)
+	builder.Where("allocation = ?", types.AllocationExternal)
+
+	if len(req.GetSymbol()) > 0 {
+		builder.Where("symbol = ?", req.GetSymbol())
+	}
+
+	if err := s.queryRange(builder, "create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	rows, err := s.Context.Reader().Query(fmt.Sprintf(`select extract(epoch from date_trunc(%[2]s::text, create_at))::bigint as time, symbol, coalesce(sum(value) filter (where assignment = $1), 0), coalesce(sum(value) filter (where assignment = $2), 0) from transactions %[1]s group by time, symbol order by time, symbol`, builder.Clause(), builder.Bind(interval)), builder.Params()...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item admin_pbanalytics.Flow
+		)
+
+		if err := rows.Scan(&item.Time, &item.Symbol, &item.Deposits, &item.Withdrawals); err != nil {
+			return &response, err
+		}
+		item.Net = decimal.New(item.GetDeposits()).Sub(item.GetWithdrawals()).Float()
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// GetSolvency - This function returns the funds held by the wallets of the exchange against the funds owed to the users for every
+// crypto currency: the reserves of the addresses, the balances of the users, which include the funds held by their orders
+// and withdrawals, the difference and the ratio of the coverage. The ratio below one means the balances are not covered.
+func (s *Service) GetSolvency(ctx context.Context, req *admin_pbanalytics.GetRequestSolvency) (*admin_pbanalytics.ResponseSolvency, error) {
+
+	var (
+		response admin_pbanalytics.ResponseSolvency
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	auth := s.Context.User(ctx)
+
+	if !migrate.Rules(auth, "analytics", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	builder.Where(`a."group" = ?`, types.GroupCrypto)
+
+	if len(req.GetSymbol()) > 0 {
+		builder.Where("a.symbol = ?", req.GetSymbol())
+	}
+
+	// The current balances are read from the primary database, the solvency is checked against the latest state.
+	rows, err := s.Context.Db.Query(fmt.Sprintf(`select a.symbol, coalesce(r.value, 0), coalesce(b.value, 0) from assets a left join (select symbol, sum(value) as value from reserves group by symbol) r on r.symbol = a.symbol left join (select symbol, sum(value) as value from balances group by symbol) b on b.symbol = a.symbol %s order by a.symbol`, builder.Clause()), builder.Params()...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item admin_pbanalytics.Solvency
+		)
+
+		if err := rows.Scan(&item.Symbol, &item.Reserves, &item.Obligations); err != nil {
+			return &response, err
+		}
+		item.Difference = decimal.New(item.GetReserves()).Sub(item.GetObligations()).Float()
+
+		if item.GetObligations() > 0 {
+			item.Ratio = decimal.New(item.GetReserves()).Div(item.GetObligations()).Float()
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
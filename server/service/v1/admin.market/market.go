@@ -2,6 +2,10 @@ package admin_market
 
 import (
 	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/status"
+	"time"
 )
 
 // Service - The type Service struct is used to store a pointer to an assets.Context object. This type is used to provide access to
@@ -9,3 +13,93 @@ import (
 type Service struct {
 	Context *assets.Context
 }
+
+// queryRange - This function brings the time range of a backfill request into the allowed boundaries. If the range is not passed,
+// the last day is used, if the end of the range is before its start, an error is returned.
+func (e *Service) queryRange(from, to int64) (int64, int64, error) {
+
+	// The end of the range defaults to the current time, and the start of the range defaults to one day before the end.
+	if to == 0 {
+		to = time.Now().Unix()
+	}
+	if from == 0 {
+		from = time.Unix(to, 0).Add(-24 * time.Hour).Unix()
+	}
+
+	// This code checks that the range is not empty, otherwise there is nothing to detect or repair.
+	if from >= to {
+		return from, to, status.Error(11563, "the start of the range must be before its end")
+	}
+
+	return from, to, nil
+}
+
+// queryOpen - This function returns the number of the pending orders placed on the pair, the configuration of such a pair can
+// only be changed in the ways that keep these orders valid.
+func (e *Service) queryOpen(pair *types.Pair) (count int) {
+	_ = e.Context.Db.QueryRow("select count(*) from orders where base_unit = $1 and quote_unit = $2 and type = $3 and status = $4", pair.GetBaseUnit(), pair.GetQuoteUnit(), pair.GetType(), types.StatusPending).Scan(&count)
+	return count
+}
+
+// queryConflict - This function checks whether the new configuration of the pair breaks its pending orders. The units and the
+// type of the pair cannot be changed and the pair cannot be disabled while it has pending orders, and the new decimals and
+// step sizes must be satisfied by the price and the remaining quantity of every pending limit order.
+func (e *Service) queryConflict(current, pair *types.Pair) error {
+
+	if e.queryOpen(current) == 0 {
+		return nil
+	}
+
+	if current.GetBaseUnit() != pair.GetBaseUnit() || current.GetQuoteUnit() != pair.GetQuoteUnit() || current.GetType() != pair.GetType() {
+		return status.Error(11629, "the pair has pending orders, its units and type cannot be changed")
+	}
+
+	if current.GetStatus() && !pair.GetStatus() {
+		return status.Error(11630, "the pair has pending orders, they must be cancelled before the pair is disabled")
+	}
+
+	var (
+		count int
+	)
+
+	// The market orders are executed immediately, so only the pending limit orders are checked against the new precision.
+	if err := e.Context.Db.QueryRow(`select count(*) from orders where base_unit = $1 and quote_unit = $2 and type = $3 and status = $4 and trading = $5 and (
+			round(price, $6::int) <> price or round(value, $7::int) <> value or ($8 > 0 and mod(price, $8) <> 0) or ($9 > 0 and mod(value, $9) <> 0)
+		)`,
+		current.GetBaseUnit(),
+		current.GetQuoteUnit(),
+		current.GetType(),
+		types.StatusPending,
+		types.TradingLimit,
+		int(pair.GetQuoteDecimal()),
+		int(pair.GetBaseDecimal()),
+		pair.GetPriceStep(),
+		pair.GetQuantityStep(),
+	).Scan(&count); err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return status.Errorf(11631, "%v pending orders of the pair do not match the new decimals or step sizes", count)
+	}
+
+	return nil
+}
+
+// writeChains - This function synchronizes the chains of the asset with the list of the chain ids set by the operators. The
+// chains added to the asset are enabled for the deposits and the withdrawals without overrides, the configuration of the
+// chains the asset already has is kept, and the chains removed from the list are deleted.
+func (e *Service) writeChains(symbol string, fields []int64) error {
+
+	if _, err := e.Context.Db.Exec("delete from asset_chains where symbol = $1 and not (chain_id = any($2))", symbol, pq.Array(fields)); err != nil {
+		return err
+	}
+
+	for _, id := range fields {
+		if _, err := e.Context.Db.Exec("insert into asset_chains (symbol, chain_id) values ($1, $2) on conflict do nothing", symbol, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
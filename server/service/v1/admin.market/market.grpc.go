@@ -2,15 +2,17 @@ package admin_market
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/assets/common/marketplace"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	admin_pbmarket "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbmarket"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
 	"google.golang.org/grpc/status"
 	"strings"
+	"time"
 )
 
 // GetPrice - This function is used to get the market price rule from the context. It first checks the authentication of the context
@@ -28,12 +30,7 @@ func (e *Service) GetPrice(ctx context.Context, req *admin_pbmarket.GetRequestPr
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking if the user has the necessary permissions to make changes to the data. If the user does not
 	// have the proper permissions, an error message is returned. The "migrate.Rules" function is used to check the user's
@@ -69,12 +66,7 @@ func (e *Service) SetAsset(ctx context.Context, req *admin_pbmarket.SetRequestAs
 		q query.Query
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking if the user has the proper permissions to access and edit data. If they do not have the
 	// required authorization, it returns an error message letting them know they are not allowed to access the data.
@@ -94,11 +86,16 @@ func (e *Service) SetAsset(ctx context.Context, req *admin_pbmarket.SetRequestAs
 		return &response, status.Error(17078, "asset symbol must not be less than < 2 characters")
 	}
 
-	// This code is using the json.Marshal function to convert a Go data structure req.Asset.GetFields() into JSON. If
-	// an error occurs, the error is returned with the Context.Error function.
-	serialize, err := json.Marshal(req.Asset.GetFields())
-	if err != nil {
-		return &response, err
+	// The market zone of the stock defines its trading hours, the stocks without a zone are traded around the clock.
+	if len(req.Asset.GetZone()) > 0 {
+
+		var (
+			count int
+		)
+
+		if _ = e.Context.Db.QueryRow("select count(*) from zones where name = $1", req.Asset.GetZone()).Scan(&count); count == 0 || req.Asset.GetGroup() != types.GroupAction {
+			return &response, status.Errorf(11701, "the market zone %v is not found, or the asset is not a stock security", req.Asset.GetZone())
+		}
 	}
 
 	// This line of code converts the symbol (which is a string) to lowercase letters. This is often used when doing string
@@ -128,7 +125,7 @@ func (e *Service) SetAsset(ctx context.Context, req *admin_pbmarket.SetRequestAs
 		// database. This statement is written in the Go programming language, and it uses the Exec method to execute a SQL
 		// query that updates the asset's name, symbol, min/max withdraw/deposit/trade, fees, marker, status, type, and
 		// chains based on the parameters passed in through the req object. The last parameter, req.GetSymbol(), is used to identify which record should be updated.
-		if _, err := e.Context.Db.Exec(`update assets set name = $1, symbol = $2, min_withdraw = $3, max_withdraw = $4, min_trade = $5, max_trade = $6, fees_trade = $7, fees_discount = $8, marker = $9, status = $10, "group" = $11, chains = $12 where symbol = $13;`,
+		if _, err := e.Context.Db.Exec(`update assets set name = $1, symbol = $2, min_withdraw = $3, max_withdraw = $4, min_trade = $5, max_trade = $6, fees_trade = $7, fees_discount = $8, marker = $9, status = $10, "group" = $11, zone = $13 where symbol = $12;`,
 			req.Asset.GetName(),
 			req.Asset.GetSymbol(),
 			req.Asset.GetMinWithdraw(),
@@ -140,8 +137,8 @@ func (e *Service) SetAsset(ctx context.Context, req *admin_pbmarket.SetRequestAs
 			req.Asset.GetMarker(),
 			req.Asset.GetStatus(),
 			req.Asset.GetGroup(),
-			serialize,
 			req.GetSymbol(),
+			req.Asset.GetZone(),
 		); err != nil {
 			return &response, err
 		}
@@ -151,10 +148,12 @@ func (e *Service) SetAsset(ctx context.Context, req *admin_pbmarket.SetRequestAs
 		// so, it updates the associated tables with the new symbol.
 		if req.GetSymbol() != req.Asset.GetSymbol() {
 			_, _ = e.Context.Db.Exec("update balances set symbol = $2 where symbol = $1 and type = $3", req.GetSymbol(), req.Asset.GetSymbol(), asset.GetType())
+			_, _ = e.Context.Db.Exec("update holds set symbol = $2 where symbol = $1 and type = $3", req.GetSymbol(), req.Asset.GetSymbol(), asset.GetType())
 			_, _ = e.Context.Db.Exec("update ohlcv set base_unit = coalesce(nullif(base_unit, $1), $2), quote_unit = coalesce(nullif(quote_unit, $1), $2) where base_unit = $1 or quote_unit = $1", req.GetSymbol(), req.Asset.GetSymbol())
 			_, _ = e.Context.Db.Exec("update trades set base_unit = coalesce(nullif(base_unit, $1), $2), quote_unit = coalesce(nullif(quote_unit, $1), $2) where base_unit = $1 or quote_unit = $1", req.GetSymbol(), req.Asset.GetSymbol())
 			_, _ = e.Context.Db.Exec("update orders set base_unit = coalesce(nullif(base_unit, $1), $2), quote_unit = coalesce(nullif(quote_unit, $1), $2) where base_unit = $1 and type = $3 or quote_unit = $1 and type = $3", req.GetSymbol(), req.Asset.GetSymbol(), asset.GetType())
 			_, _ = e.Context.Db.Exec("update reserves set symbol = $2 where symbol = $1", req.GetSymbol(), req.Asset.GetSymbol())
+			_, _ = e.Context.Db.Exec("update asset_chains set symbol = $2 where symbol = $1", req.GetSymbol(), req.Asset.GetSymbol())
 			_, _ = e.Context.Db.Exec("update assets set symbol = $2 where symbol = $1", req.GetSymbol(), req.Asset.GetSymbol())
 		}
 
@@ -163,7 +162,7 @@ func (e *Service) SetAsset(ctx context.Context, req *admin_pbmarket.SetRequestAs
 		// This code is inserting new information into a table called assets. The information being inserted is coming from
 		// the req.Asset object. The information is being inserted into a specific order, corresponding to the columns of
 		// the table. The purpose is to store the information about a currency in the currencies table.
-		if _, err := e.Context.Db.Exec(`insert into assets (name, symbol, min_withdraw, max_withdraw, min_trade, max_trade, fees_trade, fees_discount, marker, "group", status, type, chains) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		if _, err := e.Context.Db.Exec(`insert into assets (name, symbol, min_withdraw, max_withdraw, min_trade, max_trade, fees_trade, fees_discount, marker, "group", status, type, zone) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
 			req.Asset.GetName(),
 			req.Asset.GetSymbol(),
 			req.Asset.GetMinWithdraw(),
@@ -176,13 +175,18 @@ func (e *Service) SetAsset(ctx context.Context, req *admin_pbmarket.SetRequestAs
 			req.Asset.GetGroup(),
 			req.Asset.GetStatus(),
 			req.Asset.GetType(),
-			serialize,
+			req.Asset.GetZone(),
 		); err != nil {
 			return &response, err
 		}
 
 	}
 
+	// The chains of the asset are kept in their own table together with the configuration of the asset on every chain.
+	if err := e.writeChains(req.Asset.GetSymbol(), req.Asset.GetFields()); err != nil {
+		return &response, err
+	}
+
 	// This if statement is checking to see if the length of the "req.GetImage()" is greater than 0. If it is, then the code
 	// within the statement will execute. This could be used to check if the "req.GetImage()" contains any data before
 	// attempting to do something with it.
@@ -236,12 +240,7 @@ func (e *Service) GetAsset(ctx context.Context, req *admin_pbmarket.GetRequestAs
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking if the user has the correct permissions to access the data. The `migrate.Rules` function takes
 	// in the user's authorization, the data they are trying to access (in this case "currencies"), and their role (in this
@@ -269,15 +268,15 @@ func (e *Service) GetAsset(ctx context.Context, req *admin_pbmarket.GetRequestAs
 // errors encountered during the authentication and query processes.
 func (e *Service) GetAssets(ctx context.Context, req *admin_pbmarket.GetRequestAssets) (*admin_pbmarket.ResponseAsset, error) {
 
-	// The purpose of this code is to declare three variables: response, migrate, and maps. The variable response is of the
+	// The purpose of this code is to declare three variables: response, migrate, and builder. The variable response is of the
 	// type admin_pbmarket.ResponseAsset. The variable migrate is of the type query.Migrate, and has a field of type context. The
-	// variable maps is of the type string array.
+	// variable builder holds the conditions of the query.
 	var (
 		response admin_pbmarket.ResponseAsset
 		migrate  = query.Migrate{
 			Context: e.Context,
 		}
-		maps []string
+		builder = query.NewBuilder()
 	)
 
 	// The purpose of the above code is to set a default limit for the request if the limit is not specified by the user.
@@ -286,12 +285,7 @@ func (e *Service) GetAssets(ctx context.Context, req *admin_pbmarket.GetRequestA
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// The purpose of this if statement is to check if the user has the necessary permissions to perform the desired action.
 	// If the user does not have the correct permissions, an error is returned to the user.
@@ -299,20 +293,21 @@ func (e *Service) GetAssets(ctx context.Context, req *admin_pbmarket.GetRequestA
 		return &response, status.Error(12011, "you do not have rules for writing and editing data")
 	}
 
-	// This code is used to add a WHERE clause to a SQL query. If req.GetSearch() returns a non-zero length string, then a
-	// WHERE clause is appended to the maps array, checking if either the symbol or name field matches the search string.
-	// The %[1]s syntax is used to insert the value of req.GetSearch() in the same place twice in the string.
+	// This code is used to add the conditions to a SQL query. If req.GetType() returns a non-zero length string, the assets
+	// are filtered by the type, and if req.GetSearch() returns a non-zero length string, the condition checking if either
+	// the symbol or name field matches the search string is added. The values are bound as the parameters of the query.
+	if len(req.GetType()) > 0 {
+		builder.Where("type = ?", req.GetType())
+	}
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("where case when '%[1]v' is not null and '%[1]v' <> '' then type = '%[1]v' else true end and (symbol like %[2]s or name like %[2]s)", req.GetType(), "'%"+req.GetSearch()+"%'"))
-	} else if len(req.GetType()) > 0 {
-		maps = append(maps, fmt.Sprintf("where type = '%v'", req.GetType()))
+		like := query.Like(req.GetSearch())
+		builder.Where("(symbol like ? or name like ?)", like, like)
 	}
 
 	// This code is used to count the number of rows in the 'currencies' table in the database. The code uses the QueryRow()
 	// function to execute a statement which retrieves the count of the number of rows in the 'currencies' table. The result
-	// is then stored in the 'response.Count' variable. The 'strings.Join(maps, " ")' is used to join the elements of the
-	// 'maps' array with a space in between.
-	_ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from assets %s", strings.Join(maps, " "))).Scan(&response.Count)
+	// is then stored in the 'response.Count' variable.
+	_ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from assets %s", builder.Clause()), builder.Params()...).Scan(&response.Count)
 
 	// The purpose of this code is to check if the response object has a count value greater than 0. If it has a value
 	// greater than 0, it means that the response has been successfully processed and can be used.
@@ -327,9 +322,9 @@ func (e *Service) GetAssets(ctx context.Context, req *admin_pbmarket.GetRequestA
 			offset = req.GetLimit() * (req.GetPage() - 1)
 		}
 
-		// This code is used to query the database. It builds a query with the given parameters (maps, req.GetLimit(), offset).
+		// This code is used to query the database. It builds a query with the given parameters (builder, req.GetLimit(), offset).
 		// It then attempts to execute it and, if an error is encountered, the function returns the error. Finally, it closes the rows.
-		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, name, symbol, min_withdraw, max_withdraw, min_trade, max_trade, fees_trade, fees_discount, fees_charges, fees_costs, marker, status, "group", type, create_at from assets %s order by id desc limit %d offset %d`, strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, name, symbol, min_withdraw, max_withdraw, min_trade, max_trade, fees_trade, fees_discount, fees_charges, fees_costs, marker, status, "group", type, create_at from assets %s order by id desc limit %d offset %d`, builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -399,12 +394,7 @@ func (e *Service) DeleteAsset(ctx context.Context, req *admin_pbmarket.DeleteReq
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking whether the user has the correct permissions for writing and editing data. If the user does not
 	// have the necessary permissions, it will return an error message.
@@ -425,6 +415,7 @@ func (e *Service) DeleteAsset(ctx context.Context, req *admin_pbmarket.DeleteReq
 		_, _ = e.Context.Db.Exec("delete from balances where symbol = $1 and type = $2", row.GetSymbol(), row.GetType())
 		_, _ = e.Context.Db.Exec("delete from ohlcv where base_unit = $1 or quote_unit = $1", row.GetSymbol())
 		_, _ = e.Context.Db.Exec("delete from trades where base_unit = $1 or quote_unit = $1", row.GetSymbol())
+		_, _ = e.Context.Db.Exec("delete from holds where symbol = $1 and type = $2 or reference = $3 and reference_id in (select id from orders where base_unit = $1 and type = $2 or quote_unit = $1 and type = $2)", row.GetSymbol(), row.GetType(), types.HoldOrder)
 		_, _ = e.Context.Db.Exec("delete from orders where base_unit = $1 and type = $2 or quote_unit = $1 and type = $2", row.GetSymbol(), row.GetType())
 		_, _ = e.Context.Db.Exec("delete from reserves where symbol = $1", row.GetSymbol())
 		_, _ = e.Context.Db.Exec("delete from assets where symbol = $1", row.GetSymbol())
@@ -445,16 +436,16 @@ func (e *Service) DeleteAsset(ctx context.Context, req *admin_pbmarket.DeleteReq
 // to the caller. If any errors occur, they are returned to the caller.
 func (e *Service) GetPairs(ctx context.Context, req *admin_pbmarket.GetRequestPairs) (*admin_pbmarket.ResponsePair, error) {
 
-	// The code above creates three variables: response, migrate, and maps. The variable response is of type
-	// admin_pbmarket.ResponsePair and the variable migrate is of type query.Migrate. The variable maps is of type string and is
-	// initialized to an empty slice. The variable migrate is initialized to a query.Migrate object with a context field set
+	// The code above creates three variables: response, migrate, and builder. The variable response is of type
+	// admin_pbmarket.ResponsePair and the variable migrate is of type query.Migrate. The variable builder holds the conditions
+	// of the query. The variable migrate is initialized to a query.Migrate object with a context field set
 	// to the value of e.Context.
 	var (
 		response admin_pbmarket.ResponsePair
 		migrate  = query.Migrate{
 			Context: e.Context,
 		}
-		maps []string
+		builder = query.NewBuilder()
 	)
 
 	// The purpose of this code is to check if the value of the variable req.GetLimit() is equal to 0, and if it is, set the
@@ -463,12 +454,7 @@ func (e *Service) GetPairs(ctx context.Context, req *admin_pbmarket.GetRequestPa
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking if a user has permissions to perform a certain action. The migrate.Rules() function is used to
 	// check if the user has the necessary authorization to write and edit data, and if they do not, an error is returned
@@ -477,20 +463,21 @@ func (e *Service) GetPairs(ctx context.Context, req *admin_pbmarket.GetRequestPa
 		return &response, status.Error(12011, "you do not have rules for writing and editing data")
 	}
 
-	// This code is checking to see if the request includes a search term. If a search term is present, it appends a string
-	// to the maps variable that includes a "where" clause in a SQL query to search for the search term in the base_unit and
-	// quote_unit fields.
+	// This code is checking to see if the request includes a type or a search term. If a search term is present, the
+	// condition to search for the search term in the base_unit and quote_unit fields is added to the query, the search term
+	// is bound as the parameter.
+	if len(req.GetType()) > 0 {
+		builder.Where("type = ?", req.GetType())
+	}
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("where case when '%[1]v' is not null and '%[1]v' <> '' then type = '%[1]v' else true end and (base_unit like %[2]s or quote_unit like %[2]s)", req.GetType(), "'%"+req.GetSearch()+"%'"))
-	} else if len(req.GetType()) > 0 {
-		maps = append(maps, fmt.Sprintf("where type = '%v'", req.GetType()))
+		like := query.Like(req.GetSearch())
+		builder.Where("(base_unit like ? or quote_unit like ?)", like, like)
 	}
 
 	// The purpose of this code is to query the database for the total number of entries in the "pairs" table, and store the
-	// result in the response struct. The strings.Join() function is used to create a single string from the maps slice,
-	// which is then appended to the SQL query string to create the full request. Finally, the result of the query is stored
-	// in the "Count" field of the response struct using the Scan() function.
-	if _ = e.Context.Db.QueryRow(fmt.Sprintf(`select count(*) as count from pairs %s`, strings.Join(maps, " "))).Scan(&response.Count); response.GetCount() > 0 {
+	// result in the response struct. The where clause of the builder is appended to the SQL query string to create the full
+	// request. Finally, the result of the query is stored in the "Count" field of the response struct using the Scan() function.
+	if _ = e.Context.Db.QueryRow(fmt.Sprintf(`select count(*) as count from pairs %s`, builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
 
 		// The purpose of this code is to calculate the offset of results based on the limit and page that the user has
 		// requested. This offset is used in pagination to determine which set of results to retrieve from a database. The code
@@ -502,12 +489,12 @@ func (e *Service) GetPairs(ctx context.Context, req *admin_pbmarket.GetRequestPa
 		}
 
 		// This code is used to query the database for data. The specific query is retrieving rows from the table "pairs" with
-		// parameters specified by the variables strings.Join(maps, " "), req.GetLimit(), and offset. The query returns the
+		// parameters specified by the variables builder, req.GetLimit(), and offset. The query returns the
 		// data in columns named id, base_unit, quote_unit, price, base_decimal, quote_decimal, and status. The query is also
 		// ordered by the id column in descending order and limited to the req.GetLimit() number of rows with an offset of
 		// offset. If an error occurs, the code returns the response variable and an error. Finally, the rows.Close() statement
 		// is used to close the connection to the database when the query is complete.
-		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, base_unit, quote_unit, price, base_decimal, quote_decimal, type, status from pairs %[1]s order by id desc limit %[2]d offset %[3]d`, strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, base_unit, quote_unit, price, base_decimal, quote_decimal, type, status, min_notional, price_step, quantity_step, band, halted from pairs %[1]s order by id desc limit %[2]d offset %[3]d`, builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -533,6 +520,11 @@ func (e *Service) GetPairs(ctx context.Context, req *admin_pbmarket.GetRequestPa
 				&item.QuoteDecimal,
 				&item.Type,
 				&item.Status,
+				&item.MinNotional,
+				&item.PriceStep,
+				&item.QuantityStep,
+				&item.Band,
+				&item.Halted,
 			); err != nil {
 				return &response, err
 			}
@@ -567,12 +559,7 @@ func (e *Service) GetPair(ctx context.Context, req *admin_pbmarket.GetRequestPai
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// The purpose of the if statement is to check whether the user has the necessary permissions (defined by to
 	// migrate.Rules function) to write and edit data. If the user does not have the necessary permissions, an error message is returned.
@@ -610,12 +597,7 @@ func (e *Service) SetPair(ctx context.Context, req *admin_pbmarket.SetRequestPai
 		q query.Query
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking if the user has the appropriate permissions to write and edit data. If the user does not have
 	// the rules for writing and editing data, then the code will return an error message.
@@ -636,11 +618,31 @@ func (e *Service) SetPair(ctx context.Context, req *admin_pbmarket.SetRequestPai
 		return &response, status.Error(46517, "the price must be set")
 	}
 
+	// The trading filters of the pair are disabled with zero, negative values make no sense for them.
+	if req.Pair.GetMinNotional() < 0 || req.Pair.GetPriceStep() < 0 || req.Pair.GetQuantityStep() < 0 {
+		return &response, status.Error(11632, "the minimum notional and the step sizes cannot be negative")
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
 	// This is a conditional statement that checks if the value of req.GetId() is greater than 0. If the condition is true,
 	// then the code inside the curly braces will be executed. Otherwise, the code will be skipped. This conditional
 	// statement is usually used to determine if a certain condition is met before executing certain code.
 	if req.GetId() > 0 {
 
+		current, err := _provider.QueryPair(req.GetId(), types.TypeZero, false)
+		if err != nil {
+			return &response, status.Error(11592, "the pair was not found")
+		}
+
+		// This code rejects the changes that would break the pending orders of the pair.
+		if err := e.queryConflict(current, req.Pair); err != nil {
+			return &response, err
+		}
+
 		// This code is part of a larger program and its purpose is to delete trades from the database that have a base unit
 		// and quote unit that match the values provided in the request. The if statement is checking the value of
 		// GetGraphClear() before executing the delete statement.
@@ -649,10 +651,10 @@ func (e *Service) SetPair(ctx context.Context, req *admin_pbmarket.SetRequestPai
 		}
 
 		// This code is used to update an entry in the database table 'pairs', using the values in the 'req' struct. It updates
-		// the 'base_unit', 'quote_unit', 'price', 'base_decimal', 'quote_decimal' and 'status' fields of the database table,
-		// where the value of the 'id' field of the database table is equal to the value of the 'Id' field in the 'req' struct.
+		// the 'base_unit', 'quote_unit', 'price', 'base_decimal', 'quote_decimal', 'status' and the circuit breaker fields of the
+		// database table, where the value of the 'id' field of the database table is equal to the value of the 'Id' field in the 'req' struct.
 		// The code also includes an if statement to check for any errors in the process.
-		if _, err := e.Context.Db.Exec("update pairs set base_unit = $1, quote_unit = $2, price = $3, base_decimal = $4, quote_decimal = $5, type = $6, status = $7 where id = $8;",
+		if _, err := e.Context.Db.Exec("update pairs set base_unit = $1, quote_unit = $2, price = $3, base_decimal = $4, quote_decimal = $5, type = $6, status = $7, band = $8, halt_percent = $9, halt_window = $10, cooldown = $11, min_notional = $12, price_step = $13, quantity_step = $14 where id = $15;",
 			req.Pair.GetBaseUnit(),
 			req.Pair.GetQuoteUnit(),
 			req.Pair.GetPrice(),
@@ -660,10 +662,21 @@ func (e *Service) SetPair(ctx context.Context, req *admin_pbmarket.SetRequestPai
 			req.Pair.GetQuoteDecimal(),
 			req.Pair.GetType(),
 			req.Pair.GetStatus(),
+			req.Pair.GetBand(),
+			req.Pair.GetHaltPercent(),
+			req.Pair.GetHaltWindow(),
+			req.Pair.GetCooldown(),
+			req.Pair.GetMinNotional(),
+			req.Pair.GetPriceStep(),
+			req.Pair.GetQuantityStep(),
 			req.GetId(),
 		); err != nil {
 			return &response, err
 		}
+		req.Pair.Id = req.GetId()
+
+		// The cached summary and candles of the previous units are invalidated as well, when the pair has been renamed.
+		_provider.WriteInvalidate(current.GetBaseUnit(), current.GetQuoteUnit())
 
 	} else {
 
@@ -677,7 +690,7 @@ func (e *Service) SetPair(ctx context.Context, req *admin_pbmarket.SetRequestPai
 		// is using the 'Exec' function from the database context to execute an SQL statement for inserting the values into the
 		// table. The 'if _, err' statement is checking for any errors that may have occurred from the execution of the
 		// statement. If an error is detected, the code will return an error response.
-		if _, err := e.Context.Db.Exec("insert into pairs (base_unit, quote_unit, price, base_decimal, quote_decimal, type, status) values ($1, $2, $3, $4, $5, $6, $7)",
+		if err := e.Context.Db.QueryRow("insert into pairs (base_unit, quote_unit, price, base_decimal, quote_decimal, type, status, band, halt_percent, halt_window, cooldown, min_notional, price_step, quantity_step) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) returning id",
 			req.Pair.GetBaseUnit(),
 			req.Pair.GetQuoteUnit(),
 			req.Pair.GetPrice(),
@@ -685,11 +698,68 @@ func (e *Service) SetPair(ctx context.Context, req *admin_pbmarket.SetRequestPai
 			req.Pair.GetQuoteDecimal(),
 			req.Pair.GetType(),
 			req.Pair.GetStatus(),
-		); err != nil {
+			req.Pair.GetBand(),
+			req.Pair.GetHaltPercent(),
+			req.Pair.GetHaltWindow(),
+			req.Pair.GetCooldown(),
+			req.Pair.GetMinNotional(),
+			req.Pair.GetPriceStep(),
+			req.Pair.GetQuantityStep(),
+		).Scan(&req.Pair.Id); err != nil {
 			return &response, err
 		}
 
 	}
+
+	// The cached data of the pair is invalidated and the clients are notified about the new configuration of the pair, so
+	// that they can apply the new decimals and filters without reloading the list of pairs.
+	_provider.WriteInvalidate(req.Pair.GetBaseUnit(), req.Pair.GetQuoteUnit())
+
+	if err := e.Context.Publish(req.Pair, "exchange", "trade/pair"); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetHalt - This function is the manual override of the circuit breaker of a pair. The operators can halt the trading of the
+// pair for the given number of minutes, or until it is resumed manually when the minutes are not set, and they can resume
+// a pair halted by the circuit breaker before the end of its cool-down.
+func (e *Service) SetHalt(ctx context.Context, req *admin_pbmarket.SetRequestHalt) (*admin_pbmarket.ResponsePair, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponsePair
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		item types.Pair
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// The deadline of the halt is not set when the minutes are not passed, such a halt is not resumed by the circuit breaker.
+	if err := e.Context.Db.QueryRow("update pairs set halted = $1, halt_until = case when $1 and $2 > 0 then now() + make_interval(mins => $2) end where id = $3 returning id, base_unit, quote_unit, type, halted, coalesce(halt_until::text, '')", req.GetHalted(), req.GetMinutes(), req.GetId()).Scan(&item.Id, &item.BaseUnit, &item.QuoteUnit, &item.Type, &item.Halted, &item.HaltUntil); err != nil {
+		return &response, status.Error(11592, "the pair was not found")
+	}
+
+	// The clients are notified about the halt or the resumption of the trading, the same way as by the circuit breaker.
+	channel := "trade/resume"
+	if item.GetHalted() {
+		channel = "trade/halt"
+	}
+
+	if err := e.Context.Publish(&item, "exchange", channel); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, &item)
 	response.Success = true
 
 	return &response, nil
@@ -709,12 +779,7 @@ func (e *Service) DeletePair(ctx context.Context, req *admin_pbmarket.DeleteRequ
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking to see if the user has the necessary authorization to perform a write or edit operation on the
 	// data. If the user does not have the correct authorization to do so, an error is returned with a status code of 12011.
@@ -731,10 +796,1518 @@ func (e *Service) DeletePair(ctx context.Context, req *admin_pbmarket.DeleteRequ
 	// the database, based on the request's ID. If the ID is greater than zero, then the code proceeds to delete the pair
 	// from the database, as well as all associated trades, transfers, and orders.
 	if row, _ := _provider.QueryPair(req.GetId(), types.TypeZero, false); row.GetId() > 0 {
+
+		// The reserved balances of the pending orders would be lost together with the orders, so they must be cancelled first.
+		if e.queryOpen(row) > 0 {
+			return &response, status.Error(11633, "the pair has pending orders, they must be cancelled before the pair is deleted")
+		}
+
 		_, _ = e.Context.Db.Exec("delete from pairs where id = $1", row.GetId())
 		_, _ = e.Context.Db.Exec("delete from ohlcv where base_unit = $1 and quote_unit = $2", row.GetBaseUnit(), row.GetQuoteUnit())
 		_, _ = e.Context.Db.Exec("delete from trades where base_unit = $1 and quote_unit = $2", row.GetBaseUnit(), row.GetQuoteUnit())
 		_, _ = e.Context.Db.Exec("delete from orders where base_unit = $1 and quote_unit = $2 and type = $3", row.GetBaseUnit(), row.GetQuoteUnit(), row.GetType())
+
+		_provider.WriteInvalidate(row.GetBaseUnit(), row.GetQuoteUnit())
+
+		if err := e.Context.Publish(row, "exchange", "trade/pair-delete"); err != nil {
+			return &response, err
+		}
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetGaps - This function returns the gaps in the candles of the given pair, the periods of the given resolution in which trades
+// were executed but no ticks were written. It checks the authentication of the user and the rules for writing and
+// editing the pairs, the range defaults to the last day if it is not passed in the request.
+func (e *Service) GetGaps(ctx context.Context, req *admin_pbmarket.GetRequestGaps) (*admin_pbmarket.ResponseGaps, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseGaps
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// This code brings the range of the request into the allowed boundaries, the last day is used by default.
+	from, to, err := e.queryRange(req.GetFrom(), req.GetTo())
+	if err != nil {
+		return &response, err
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	// This code detects the gaps of the pair and appends them to the response.
+	response.Fields, err = _provider.QueryGaps(req.GetBaseUnit(), req.GetQuoteUnit(), req.GetResolution(), from, to)
+	if err != nil {
+		return &response, err
+	}
+	response.Count = int32(len(response.Fields))
+
+	return &response, nil
+}
+
+// SetBackfill - This function triggers the repair of the candles of the given pair, the ticks of every gap in the range are rebuilt
+// from the trades table. It checks the authentication of the user and the rules for writing and editing the pairs, and
+// returns the number of ticks written.
+func (e *Service) SetBackfill(ctx context.Context, req *admin_pbmarket.SetRequestBackfill) (*admin_pbmarket.ResponseBackfill, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseBackfill
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// This code brings the range of the request into the allowed boundaries, the last day is used by default.
+	from, to, err := e.queryRange(req.GetFrom(), req.GetTo())
+	if err != nil {
+		return &response, err
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	// This code rebuilds the ticks of the gaps of the pair from the trades table.
+	response.Count, err = _provider.WriteBackfill(req.GetBaseUnit(), req.GetQuoteUnit(), req.GetResolution(), from, to)
+	if err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetReports - This function returns the end-of-day transaction reports generated for the regulators, with their versions and the
+// status of their delivery. The reports can be filtered by the jurisdiction and by the day, the newest reports come first.
+func (e *Service) GetReports(ctx context.Context, req *admin_pbmarket.GetRequestReports) (*admin_pbmarket.ResponseReport, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseReport
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// The filters of the request are passed as parameters, an empty filter matches all the reports.
+	if _ = e.Context.Db.QueryRow("select count(*) as count from reports where ($1 = '' or jurisdiction = $1) and ($2 = '' or day = $2::date)", req.GetJurisdiction(), req.GetDay()).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, jurisdiction, to_char(day, 'YYYY-MM-DD'), version, name, count, status, error, create_at from reports where ($1 = '' or jurisdiction = $1) and ($2 = '' or day = $2::date) order by id desc limit $3 offset $4", req.GetJurisdiction(), req.GetDay(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Report
+			)
+
+			if err := rows.Scan(&item.Id, &item.Jurisdiction, &item.Day, &item.Version, &item.Name, &item.Count, &item.Status, &item.Error, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetReport - This function resubmits the report of the given jurisdiction and day, for example after the trades of the day have
+// been corrected. A new version of the report is generated with the correction records and delivered to the regulator,
+// the previous versions are kept as they were delivered.
+func (e *Service) SetReport(ctx context.Context, req *admin_pbmarket.SetRequestReport) (*admin_pbmarket.ResponseReport, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseReport
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	// This code generates and delivers the next version of the report, the status of the delivery is returned with it.
+	item, err := _provider.WriteReport(req.GetJurisdiction(), req.GetDay())
+	if err != nil {
+		return &response, err
+	}
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetEvents - This function returns the events of the calendar of the exchange for the operators, including the events that have
+// not been published yet, the newest events come first.
+func (e *Service) GetEvents(ctx context.Context, req *admin_pbmarket.GetRequestEvents) (*admin_pbmarket.ResponseEvent, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseEvent
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = e.Context.Db.QueryRow("select count(*) as count from events").Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, kind, title, text, symbol, start_at, coalesce(end_at::text, ''), status, create_at from events order by id desc limit $1 offset $2", req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Event
+			)
+
+			if err := rows.Scan(&item.Id, &item.Kind, &item.Title, &item.Text, &item.Symbol, &item.StartAt, &item.EndAt, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetEvent - This function creates or updates an event of the calendar of the exchange. The kind of the event is validated, the
+// start is required and the end, if it is set, must be after the start. The times are passed in the RFC 3339 format.
+func (e *Service) SetEvent(ctx context.Context, req *admin_pbmarket.SetRequestEvent) (*admin_pbmarket.ResponseEvent, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseEvent
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.EventKind(req.Event.GetKind()); err != nil {
+		return &response, err
+	}
+
+	// This code checks the start and the end of the event.
+	start, err := time.Parse(time.RFC3339, req.Event.GetStartAt())
+	if err != nil {
+		return &response, status.Error(11593, "the start of the event must be set in the RFC 3339 format")
+	}
+	if len(req.Event.GetEndAt()) > 0 {
+		if end, err := time.Parse(time.RFC3339, req.Event.GetEndAt()); err != nil || !end.After(start) {
+			return &response, status.Error(11594, "the end of the event must be after its start")
+		}
+	}
+
+	if req.GetId() > 0 {
+
+		// This code updates the event with the values of the request, an empty end removes the end of the event.
+		if _, err := e.Context.Db.Exec("update events set kind = $1, title = $2, text = $3, symbol = $4, start_at = $5, end_at = nullif($6, '')::timestamptz, status = $7 where id = $8;",
+			req.Event.GetKind(),
+			req.Event.GetTitle(),
+			req.Event.GetText(),
+			req.Event.GetSymbol(),
+			req.Event.GetStartAt(),
+			req.Event.GetEndAt(),
+			req.Event.GetStatus(),
+			req.GetId(),
+		); err != nil {
+			return &response, err
+		}
+
+	} else {
+
+		if _, err := e.Context.Db.Exec("insert into events (kind, title, text, symbol, start_at, end_at, status) values ($1, $2, $3, $4, $5, nullif($6, '')::timestamptz, $7)",
+			req.Event.GetKind(),
+			req.Event.GetTitle(),
+			req.Event.GetText(),
+			req.Event.GetSymbol(),
+			req.Event.GetStartAt(),
+			req.Event.GetEndAt(),
+			req.Event.GetStatus(),
+		); err != nil {
+			return &response, err
+		}
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteEvent - This function removes the event with the given id from the calendar of the exchange.
+func (e *Service) DeleteEvent(ctx context.Context, req *admin_pbmarket.DeleteRequestEvent) (*admin_pbmarket.ResponseEvent, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseEvent
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _, err := e.Context.Db.Exec("delete from events where id = $1", req.GetId()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetIncidents - This function returns the incidents of the status page for the operators, including the resolved ones, the latest
+// incidents first. It checks the authentication of the user and the rules for the pairs.
+func (e *Service) GetIncidents(ctx context.Context, req *admin_pbmarket.GetRequestIncidents) (*admin_pbmarket.ResponseIncident, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseIncident
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = e.Context.Db.QueryRow("select count(*) as count from incidents").Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, component, severity, title, text, status, coalesce(resolve_at::text, ''), create_at from incidents order by id desc limit $1 offset $2", req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Incident
+			)
+
+			if err := rows.Scan(&item.Id, &item.Component, &item.Severity, &item.Title, &item.Text, &item.Status, &item.ResolveAt, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetIncident - This function opens or updates an incident of the status page, the note of the operators shown to the users next
+// to the status of the components. The severity of the incident is validated and the title is required, the incident that
+// is set inactive is resolved at the time of the update and is no longer shown to the users.
+func (e *Service) SetIncident(ctx context.Context, req *admin_pbmarket.SetRequestIncident) (*admin_pbmarket.ResponseIncident, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseIncident
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.Severity(req.Incident.GetSeverity()); err != nil {
+		return &response, err
+	}
+
+	if len(req.Incident.GetTitle()) == 0 {
+		return &response, status.Error(11733, "the title of the incident is required")
+	}
+
+	if req.GetId() > 0 {
+
+		// This code updates the incident with the values of the request, the time of the resolution is kept while the incident
+		// stays resolved and is cleared when the incident is opened again.
+		if _, err := e.Context.Db.Exec("update incidents set component = $1, severity = $2, title = $3, text = $4, status = $5, resolve_at = case when $5 then null else coalesce(resolve_at, now()) end where id = $6;",
+			req.Incident.GetComponent(),
+			req.Incident.GetSeverity(),
+			req.Incident.GetTitle(),
+			req.Incident.GetText(),
+			req.Incident.GetStatus(),
+			req.GetId(),
+		); err != nil {
+			return &response, err
+		}
+
+	} else {
+
+		if _, err := e.Context.Db.Exec("insert into incidents (component, severity, title, text, status, resolve_at) values ($1, $2, $3, $4, $5, case when $5 then null else now() end)",
+			req.Incident.GetComponent(),
+			req.Incident.GetSeverity(),
+			req.Incident.GetTitle(),
+			req.Incident.GetText(),
+			req.Incident.GetStatus(),
+		); err != nil {
+			return &response, err
+		}
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteIncident - This function removes the incident with the given id from the status page.
+func (e *Service) DeleteIncident(ctx context.Context, req *admin_pbmarket.DeleteRequestIncident) (*admin_pbmarket.ResponseIncident, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseIncident
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _, err := e.Context.Db.Exec("delete from incidents where id = $1", req.GetId()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetDeadLetters - This function returns the dead letters, the messages the broker did not accept after all the attempts of the
+// policy of the publishing, the latest first. The letters can be filtered by the status, the failed ones wait to be
+// re-driven, and by the topic.
+func (e *Service) GetDeadLetters(ctx context.Context, req *admin_pbmarket.GetRequestDeadLetters) (*admin_pbmarket.ResponseDeadLetter, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseDeadLetter
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		builder = query.NewBuilder()
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if len(req.GetStatus()) > 0 {
+		if err := types.LetterStatus(req.GetStatus()); err != nil {
+			return &response, err
+		}
+		builder.Where("status = ?", req.GetStatus())
+	}
+
+	if len(req.GetTopic()) > 0 {
+		builder.Where("topic = ?", req.GetTopic())
+	}
+
+	if _ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from dead_letters %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query(fmt.Sprintf("select id, topic, channel, message, error, attempts, status, coalesce(redrive_at::text, ''), create_at from dead_letters %s order by id desc limit %s offset %s", builder.Clause(), builder.Bind(req.GetLimit()), builder.Bind(offset)), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.DeadLetter
+			)
+
+			if err := rows.Scan(&item.Id, &item.Topic, &item.Channel, &item.Message, &item.Error, &item.Attempts, &item.Status, &item.RedriveAt, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetRedrive - This function re-drives the failed dead letters to the broker, the letters are published once each in the given
+// order. The letters the broker accepted are marked as re-driven, the re-driving stops at the first letter the broker did
+// not accept again, which stays failed with the error of the broker.
+func (e *Service) SetRedrive(ctx context.Context, req *admin_pbmarket.SetRequestRedrive) (*admin_pbmarket.ResponseDeadLetter, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseDeadLetter
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	for _, id := range req.GetIds() {
+
+		if err := e.Context.Redrive(id); err != nil {
+			return &response, err
+		}
+		response.Count++
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetFeatures - This function returns the switches of the features set by the operators, the features without a switch are enabled.
+// It checks the authentication of the user and the rules for the pairs, the switches can be filtered by the feature and
+// by the scope.
+func (e *Service) GetFeatures(ctx context.Context, req *admin_pbmarket.GetRequestFeatures) (*admin_pbmarket.ResponseFeature, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseFeature
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := e.Context.Db.Query("select id, feature, scope, target, status, reason, create_at from features where ($1 = '' or feature = $1) and ($2 = '' or scope = $2) order by feature, scope, target", req.GetFeature(), req.GetScope())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Feature
+		)
+
+		if err := rows.Scan(&item.Id, &item.Feature, &item.Scope, &item.Target, &item.Status, &item.Reason, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// SetFeature - This function turns the feature on or off for the whole exchange, for a currency, for a pair or for a chain. The
+// switch of the same feature, scope and target is replaced, the target of the global switch is empty, the target of the
+// pair is its base and quote units joined by a slash and the target of the chain is its id. The cache of the switches is
+// removed, so that the change is applied at once by all the instances.
+func (e *Service) SetFeature(ctx context.Context, req *admin_pbmarket.SetRequestFeature) (*admin_pbmarket.ResponseFeature, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseFeature
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.FeatureName(req.Feature.GetFeature()); err != nil {
+		return &response, err
+	}
+
+	if err := types.FeatureScope(req.Feature.GetScope()); err != nil {
+		return &response, err
+	}
+
+	// The global switch has no target, the switches of the other scopes must name the currency, the pair or the chain.
+	target := strings.ToLower(strings.TrimSpace(req.Feature.GetTarget()))
+	if (req.Feature.GetScope() == types.ScopeGlobal) != (len(target) == 0) {
+		return &response, status.Errorf(11735, "the target of the %v scope is not valid: %v", req.Feature.GetScope(), req.Feature.GetTarget())
+	}
+
+	if req.Feature.GetFeature() == types.FeatureRegistration && req.Feature.GetScope() != types.ScopeGlobal {
+		return &response, status.Errorf(11735, "the %v can only be switched for the whole exchange", req.Feature.GetFeature())
+	}
+
+	if _, err := e.Context.Db.Exec("insert into features (feature, scope, target, status, reason) values ($1, $2, $3, $4, $5) on conflict (feature, scope, target) do update set status = excluded.status, reason = excluded.reason, create_at = now()",
+		req.Feature.GetFeature(),
+		req.Feature.GetScope(),
+		target,
+		req.Feature.GetStatus(),
+		req.Feature.GetReason(),
+	); err != nil {
+		return &response, err
+	}
+
+	if err := e.Context.RedisClient.Del(context.Background(), assets.FeatureKey).Err(); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteFeature - This function removes the switch with the given id, the feature is enabled again for its scope.
+func (e *Service) DeleteFeature(ctx context.Context, req *admin_pbmarket.DeleteRequestFeature) (*admin_pbmarket.ResponseFeature, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseFeature
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _, err := e.Context.Db.Exec("delete from features where id = $1", req.GetId()); err != nil {
+		return &response, err
+	}
+
+	if err := e.Context.RedisClient.Del(context.Background(), assets.FeatureKey).Err(); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetDivergences - This function returns the divergences reported by the shadow mode of the matching engine, the fills of the
+// incoming orders which the candidate engine computed differently from the live one. It checks the authentication of the
+// user and the rules for the pairs, the divergences can be filtered by the units of the pair.
+func (e *Service) GetDivergences(ctx context.Context, req *admin_pbmarket.GetRequestDivergences) (*admin_pbmarket.ResponseDivergence, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseDivergence
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// The filters of the request are passed as parameters, an empty filter matches all the divergences.
+	if _ = e.Context.Db.QueryRow("select count(*) as count from divergences where ($1 = '' or base_unit = $1) and ($2 = '' or quote_unit = $2)", req.GetBaseUnit(), req.GetQuoteUnit()).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, order_id, assigning, base_unit, quote_unit, type, price, value, live, shadow, reason, create_at from divergences where ($1 = '' or base_unit = $1) and ($2 = '' or quote_unit = $2) order by id desc limit $3 offset $4", req.GetBaseUnit(), req.GetQuoteUnit(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Divergence
+			)
+
+			if err := rows.Scan(&item.Id, &item.OrderId, &item.Assigning, &item.BaseUnit, &item.QuoteUnit, &item.Type, &item.Price, &item.Value, &item.Live, &item.Shadow, &item.Reason, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// GetDelistings - This function returns the delistings of the assets, the scheduled ones as well as the finished ones. It checks
+// the authentication of the user and the rules for the assets, the delistings can be filtered by the asset.
+func (e *Service) GetDelistings(ctx context.Context, req *admin_pbmarket.GetRequestDelistings) (*admin_pbmarket.ResponseDelisting, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseDelisting
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = e.Context.Db.QueryRow("select count(*) as count from delistings where ($1 = '' or symbol = $1)", req.GetSymbol()).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, symbol, quote_unit, price, cancel_at, withdraw_until, status, create_at from delistings where ($1 = '' or symbol = $1) order by id desc limit $2 offset $3", req.GetSymbol(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Delisting
+			)
+
+			if err := rows.Scan(&item.Id, &item.Symbol, &item.QuoteUnit, &item.Price, &item.CancelAt, &item.WithdrawUntil, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetDelisting - This function schedules the delisting of an asset. From the moment it is scheduled the new orders and the
+// deposits of the asset are blocked, at the time of the cancellation its pending orders are cancelled and its pairs are
+// disabled, the withdrawals are allowed until the end of the grace period, after which the residual balances are
+// converted into the quote asset. The delisting is also published in the calendar of the events.
+func (e *Service) SetDelisting(ctx context.Context, req *admin_pbmarket.SetRequestDelisting) (*admin_pbmarket.ResponseDelisting, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseDelisting
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		count int
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// This code checks that both the delisted asset and the asset its residual balances are converted into exist.
+	if _ = e.Context.Db.QueryRow("select count(*) from assets where symbol in ($1, $2)", req.Delisting.GetSymbol(), req.Delisting.GetQuoteUnit()).Scan(&count); count != 2 || req.Delisting.GetSymbol() == req.Delisting.GetQuoteUnit() {
+		return &response, status.Error(11638, "the delisted asset and the quote asset must exist and be different")
+	}
+
+	if req.Delisting.GetPrice() < 0 {
+		return &response, status.Error(11639, "the price of the conversion cannot be negative")
+	}
+
+	// This code checks the time of the cancellation of the orders and the end of the grace period for the withdrawals.
+	cancel, err := time.Parse(time.RFC3339, req.Delisting.GetCancelAt())
+	if err != nil || cancel.Before(time.Now()) {
+		return &response, status.Error(11641, "the time of the cancellation must be set in the RFC 3339 format and be in the future")
+	}
+	if until, err := time.Parse(time.RFC3339, req.Delisting.GetWithdrawUntil()); err != nil || !until.After(cancel) {
+		return &response, status.Error(11642, "the end of the grace period for the withdrawals must be after the time of the cancellation")
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if _, err := _provider.QueryDelisting(req.Delisting.GetSymbol()); err == nil {
+		return &response, status.Errorf(11643, "the asset %v is already being delisted", req.Delisting.GetSymbol())
+	}
+
+	if err := e.Context.Db.QueryRow("insert into delistings (symbol, quote_unit, price, cancel_at, withdraw_until, status) values ($1, $2, $3, $4, $5, $6) returning id, create_at",
+		req.Delisting.GetSymbol(),
+		req.Delisting.GetQuoteUnit(),
+		req.Delisting.GetPrice(),
+		req.Delisting.GetCancelAt(),
+		req.Delisting.GetWithdrawUntil(),
+		types.DelistingScheduled,
+	).Scan(&req.Delisting.Id, &req.Delisting.CreateAt); err != nil {
+		return &response, err
+	}
+	req.Delisting.Status = types.DelistingScheduled
+
+	// The delisting is announced in the calendar, from the cancellation of the orders to the end of the grace period.
+	if _, err := e.Context.Db.Exec("insert into events (kind, title, symbol, start_at, end_at, status) values ($1, $2, $3, $4, $5, $6)",
+		types.EventDelisting,
+		fmt.Sprintf("Delisting of %v", strings.ToUpper(req.Delisting.GetSymbol())),
+		req.Delisting.GetSymbol(),
+		req.Delisting.GetCancelAt(),
+		req.Delisting.GetWithdrawUntil(),
+		true,
+	); err != nil {
+		return &response, err
+	}
+
+	if err := e.Context.Publish(req.Delisting, "exchange", "asset/delisting"); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, req.Delisting)
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteDelisting - This function revokes a delisting which has not started yet, the orders and the deposits of the asset are
+// accepted again and the delisting is removed from the calendar. A delisting whose orders have been cancelled cannot be revoked.
+func (e *Service) DeleteDelisting(ctx context.Context, req *admin_pbmarket.DeleteRequestDelisting) (*admin_pbmarket.ResponseDelisting, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseDelisting
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		item types.Delisting
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := e.Context.Db.QueryRow("delete from delistings where id = $1 and status = $2 returning id, symbol, cancel_at", req.GetId(), types.DelistingScheduled).Scan(&item.Id, &item.Symbol, &item.CancelAt); err != nil {
+		return &response, status.Error(11644, "only a scheduled delisting can be revoked")
+	}
+
+	if _, err := e.Context.Db.Exec("delete from events where kind = $1 and symbol = $2 and start_at = $3", types.EventDelisting, item.GetSymbol(), item.GetCancelAt()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetConversions - This function returns the audit records of the conversions of the residual balances of a delisted asset, one
+// record per balance with the converted value, the price and the value credited in the quote asset.
+func (e *Service) GetConversions(ctx context.Context, req *admin_pbmarket.GetRequestConversions) (*admin_pbmarket.ResponseConversion, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseConversion
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = e.Context.Db.QueryRow("select count(*) as count from conversions where delisting_id = $1", req.GetDelistingId()).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, delisting_id, user_id, symbol, type, value, quote_unit, price, converted, create_at from conversions where delisting_id = $1 order by id limit $2 offset $3", req.GetDelistingId(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Conversion
+			)
+
+			if err := rows.Scan(&item.Id, &item.DelistingId, &item.UserId, &item.Symbol, &item.Type, &item.Value, &item.QuoteUnit, &item.Price, &item.Converted, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// GetAssetChains - This function returns the configuration of the asset on every chain it is available on: whether the deposits
+// and the withdrawals are enabled, and the overrides of the minimum withdrawal and of the withdrawal fee.
+func (e *Service) GetAssetChains(ctx context.Context, req *admin_pbmarket.GetRequestAssetChains) (*admin_pbmarket.ResponseAssetChain, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseAssetChain
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := e.Context.Db.Query("select id, symbol, chain_id, deposit, withdraw, min_withdraw, fees, hold, create_at from asset_chains where symbol = $1 order by id", req.GetSymbol())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.AssetChain
+		)
+
+		if err := rows.Scan(&item.Id, &item.Symbol, &item.ChainId, &item.Deposit, &item.Withdraw, &item.MinWithdraw, &item.Fees, &item.Hold, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// SetAssetChain - This function sets the configuration of the asset on the chain, the asset is added to the chain when it is
+// not available on it yet. The deposits and the withdrawals can be disabled separately, and a zero minimum withdrawal or
+// fee means that the minimum withdrawal of the asset and the fee of the chain are applied. The deposits of at least the
+// hold value are held for the review of the operators, a zero hold value disables it.
+func (e *Service) SetAssetChain(ctx context.Context, req *admin_pbmarket.SetRequestAssetChain) (*admin_pbmarket.ResponseAssetChain, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseAssetChain
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if _, err := _provider.QueryAsset(req.Chain.GetSymbol(), false); err != nil {
+		return &response, status.Errorf(10029, "the asset requested array by id %v is currently unavailable", req.Chain.GetSymbol())
+	}
+
+	if _, err := _provider.QueryChain(req.Chain.GetChainId(), false); err != nil {
+		return &response, status.Errorf(11584, "the chain array by id %v is currently unavailable", req.Chain.GetChainId())
+	}
+
+	if req.Chain.GetMinWithdraw() < 0 || req.Chain.GetFees() < 0 || req.Chain.GetHold() < 0 {
+		return &response, status.Error(11647, "the minimum withdrawal, the fee and the hold value cannot be negative")
+	}
+
+	if err := e.Context.Db.QueryRow("insert into asset_chains (symbol, chain_id, deposit, withdraw, min_withdraw, fees, hold) values ($1, $2, $3, $4, $5, $6, $7) on conflict (symbol, chain_id) do update set deposit = excluded.deposit, withdraw = excluded.withdraw, min_withdraw = excluded.min_withdraw, fees = excluded.fees, hold = excluded.hold returning id, create_at",
+		req.Chain.GetSymbol(),
+		req.Chain.GetChainId(),
+		req.Chain.GetDeposit(),
+		req.Chain.GetWithdraw(),
+		req.Chain.GetMinWithdraw(),
+		req.Chain.GetFees(),
+		req.Chain.GetHold(),
+	).Scan(&req.Chain.Id, &req.Chain.CreateAt); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, req.Chain)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetCorporateActions - This function returns the corporate actions of the stock securities, the scheduled ones as well as the
+// processed ones, the corporate actions can be filtered by the asset.
+func (e *Service) GetCorporateActions(ctx context.Context, req *admin_pbmarket.GetRequestCorporateActions) (*admin_pbmarket.ResponseCorporateAction, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseCorporateAction
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = e.Context.Db.QueryRow("select count(*) as count from corporate_actions where ($1 = '' or symbol = $1)", req.GetSymbol()).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, kind, symbol, currency, value, ratio, target, ex_at, pay_at, status, create_at from corporate_actions where ($1 = '' or symbol = $1) order by id desc limit $2 offset $3", req.GetSymbol(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.CorporateAction
+			)
+
+			if err := rows.Scan(&item.Id, &item.Kind, &item.Symbol, &item.Currency, &item.Value, &item.Ratio, &item.Target, &item.ExAt, &item.PayAt, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetCorporateAction - This function records a corporate action of a stock security: a dividend paid in cash per share, a split
+// of the shares by the ratio (a ratio below one is a reverse split), or a change of the symbol of the stock. The
+// positions of the holders are fixed at the ex date, when the splits and the changes of the symbol are applied, the
+// dividends are credited to the balances at the pay date.
+func (e *Service) SetCorporateAction(ctx context.Context, req *admin_pbmarket.SetRequestCorporateAction) (*admin_pbmarket.ResponseCorporateAction, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseCorporateAction
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		count int
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := types.Corporate(req.Action.GetKind()); err != nil {
+		return &response, status.Errorf(11690, "the kind of the corporate action %v is not supported", req.Action.GetKind())
+	}
+
+	if _ = e.Context.Db.QueryRow(`select count(*) from assets where symbol = $1 and "group" = $2`, req.Action.GetSymbol(), types.GroupAction).Scan(&count); count == 0 {
+		return &response, status.Errorf(11691, "the asset %v is not a stock security", req.Action.GetSymbol())
+	}
+
+	// The ex date fixes the positions of the holders, a pending corporate action of the stock would change them meanwhile.
+	if _ = e.Context.Db.QueryRow("select count(*) from corporate_actions where symbol = $1 and status = $2", req.Action.GetSymbol(), types.StatusPending).Scan(&count); count > 0 {
+		return &response, status.Errorf(11692, "the asset %v already has a scheduled corporate action", req.Action.GetSymbol())
+	}
+
+	ex, err := time.Parse(time.RFC3339, req.Action.GetExAt())
+	if err != nil || ex.Before(time.Now()) {
+		return &response, status.Error(11693, "the ex date must be set in the RFC 3339 format and be in the future")
+	}
+
+	// The fields that do not belong to the kind of the corporate action are cleared, the pay date of the splits and of the
+	// changes of the symbol is the ex date.
+	switch req.Action.GetKind() {
+	case types.CorporateDividend:
+
+		if req.Action.GetValue() <= 0 {
+			return &response, status.Error(11694, "the dividend per share must be greater than zero")
+		}
+
+		if _ = e.Context.Db.QueryRow("select count(*) from assets where symbol = $1", req.Action.GetCurrency()).Scan(&count); count == 0 {
+			return &response, status.Errorf(11695, "the currency of the dividend %v is not found", req.Action.GetCurrency())
+		}
+
+		if pay, err := time.Parse(time.RFC3339, req.Action.GetPayAt()); err != nil || pay.Before(ex) {
+			return &response, status.Error(11696, "the pay date must be set in the RFC 3339 format and not be before the ex date")
+		}
+		req.Action.Ratio, req.Action.Target = 0, ""
+
+	case types.CorporateSplit:
+
+		if req.Action.GetRatio() <= 0 || req.Action.GetRatio() == 1 {
+			return &response, status.Error(11697, "the ratio of the split must be greater than zero and different from one")
+		}
+		req.Action.Value, req.Action.Currency, req.Action.Target, req.Action.PayAt = 0, "", "", req.Action.GetExAt()
+
+	case types.CorporateSymbol:
+
+		req.Action.Target = strings.ToLower(req.Action.GetTarget())
+		if _ = e.Context.Db.QueryRow("select count(*) from assets where symbol = $1", req.Action.GetTarget()).Scan(&count); count > 0 || len(req.Action.GetTarget()) == 0 {
+			return &response, status.Errorf(11698, "the new symbol %v must be set and must not be taken by another asset", req.Action.GetTarget())
+		}
+		req.Action.Value, req.Action.Currency, req.Action.Ratio, req.Action.PayAt = 0, "", 0, req.Action.GetExAt()
+	}
+
+	if err := e.Context.Db.QueryRow("insert into corporate_actions (kind, symbol, currency, value, ratio, target, ex_at, pay_at, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id, create_at",
+		req.Action.GetKind(),
+		req.Action.GetSymbol(),
+		req.Action.GetCurrency(),
+		req.Action.GetValue(),
+		req.Action.GetRatio(),
+		req.Action.GetTarget(),
+		req.Action.GetExAt(),
+		req.Action.GetPayAt(),
+		types.StatusPending,
+	).Scan(&req.Action.Id, &req.Action.CreateAt); err != nil {
+		return &response, err
+	}
+	req.Action.Status = types.StatusPending
+
+	if err := e.Context.Publish(req.Action, "exchange", "stock/corporate"); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, req.Action)
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteCorporateAction - This function revokes a corporate action whose ex date has not come yet, the processed corporate
+// actions cannot be revoked.
+func (e *Service) DeleteCorporateAction(ctx context.Context, req *admin_pbmarket.DeleteRequestCorporateAction) (*admin_pbmarket.ResponseCorporateAction, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseCorporateAction
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if result, err := e.Context.Db.Exec("delete from corporate_actions where id = $1 and status = $2 and ex_at > now()", req.GetId(), types.StatusPending); err != nil {
+		return &response, err
+	} else if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Error(11699, "only a corporate action whose ex date has not come yet can be revoked")
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetZones - This function returns the market zones of the stocks with their trading hours, their coming holidays and the
+// session every zone is in at the moment.
+func (e *Service) GetZones(ctx context.Context, _ *admin_pbmarket.GetRequestZones) (*admin_pbmarket.ResponseZone, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseZone
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		names []string
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := e.Context.Db.Query("select name from zones order by id")
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			name string
+		)
+
+		if err := rows.Scan(&name); err != nil {
+			return &response, err
+		}
+
+		names = append(names, name)
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	for _, name := range names {
+
+		zone, err := _provider.QueryZone(name)
+		if err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, zone)
+	}
+
+	return &response, nil
+}
+
+// SetZone - This function creates or updates a market zone: its time zone, the pre-market, the regular session and the
+// post-market in the local time of the zone, the trading weekdays, and whether the orders placed outside of the trading
+// hours are queued until the opening of the market or rejected.
+func (e *Service) SetZone(ctx context.Context, req *admin_pbmarket.SetRequestZone) (*admin_pbmarket.ResponseZone, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseZone
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	req.Zone.Name = strings.ToLower(req.Zone.GetName())
+	if len(req.Zone.GetName()) == 0 {
+		return &response, status.Error(11702, "the name of the market zone must be set")
+	}
+
+	if _, err := time.LoadLocation(req.Zone.GetTimezone()); err != nil || len(req.Zone.GetTimezone()) == 0 {
+		return &response, status.Errorf(11703, "the time zone %v is not found", req.Zone.GetTimezone())
+	}
+
+	for _, day := range req.Zone.GetDays() {
+		if day < 0 || day > 6 {
+			return &response, status.Error(11704, "the trading days must be the weekdays from 0 (Sunday) to 6 (Saturday)")
+		}
+	}
+
+	if len(req.Zone.GetOpen()) == 0 || len(req.Zone.GetClose()) == 0 {
+		return &response, status.Error(11705, "the opening and the closing of the regular session must be set")
+	}
+
+	// The trading hours are brought to the "15:04" format, so that the sessions can be compared with each other, the
+	// pre-market and the post-market are optional.
+	for _, value := range []*string{&req.Zone.PreOpen, &req.Zone.Open, &req.Zone.Close, &req.Zone.PostClose} {
+
+		if len(*value) == 0 {
+			continue
+		}
+
+		clock, err := time.Parse("15:04", *value)
+		if err != nil {
+			return &response, status.Errorf(11705, "the time %v must be set in the 15:04 format", *value)
+		}
+		*value = clock.Format("15:04")
+	}
+
+	if (len(req.Zone.GetPreOpen()) > 0 && req.Zone.GetPreOpen() > req.Zone.GetOpen()) || req.Zone.GetOpen() >= req.Zone.GetClose() || (len(req.Zone.GetPostClose()) > 0 && req.Zone.GetPostClose() < req.Zone.GetClose()) {
+		return &response, status.Error(11705, "the pre-market, the regular session and the post-market must follow each other within the day")
+	}
+
+	if _, err := e.Context.Db.Exec(`insert into zones (name, timezone, pre_open, open, close, post_close, days, queue, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		on conflict (name) do update set timezone = excluded.timezone, pre_open = excluded.pre_open, open = excluded.open, close = excluded.close, post_close = excluded.post_close, days = excluded.days, queue = excluded.queue, status = excluded.status`,
+		req.Zone.GetName(),
+		req.Zone.GetTimezone(),
+		req.Zone.GetPreOpen(),
+		req.Zone.GetOpen(),
+		req.Zone.GetClose(),
+		req.Zone.GetPostClose(),
+		pq.Array(req.Zone.GetDays()),
+		req.Zone.GetQueue(),
+		req.Zone.GetStatus(),
+	); err != nil {
+		return &response, err
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	zone, err := _provider.QueryZone(req.Zone.GetName())
+	if err != nil {
+		return &response, err
+	}
+
+	if err := e.Context.Publish(zone, "exchange", "stock/zone"); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, zone)
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetHoliday - This function records a holiday of a market zone, the market is closed the whole day, or until the early close
+// on the half-days.
+func (e *Service) SetHoliday(ctx context.Context, req *admin_pbmarket.SetRequestHoliday) (*admin_pbmarket.ResponseZone, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseZone
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		count int
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _ = e.Context.Db.QueryRow("select count(*) from zones where name = $1", req.Holiday.GetZone()).Scan(&count); count == 0 {
+		return &response, status.Errorf(11706, "the market zone %v is not found", req.Holiday.GetZone())
+	}
+
+	if _, err := time.Parse("2006-01-02", req.Holiday.GetDate()); err != nil {
+		return &response, status.Error(11707, "the date of the holiday must be set in the 2006-01-02 format")
+	}
+
+	if _, err := time.Parse("15:04", req.Holiday.GetClose()); err != nil && len(req.Holiday.GetClose()) > 0 {
+		return &response, status.Error(11707, "the early close of the half-day must be set in the 15:04 format")
+	}
+
+	if _, err := e.Context.Db.Exec("insert into holidays (zone, date, close, title) values ($1, $2, $3, $4) on conflict (zone, date) do update set close = excluded.close, title = excluded.title", req.Holiday.GetZone(), req.Holiday.GetDate(), req.Holiday.GetClose(), req.Holiday.GetTitle()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteHoliday - This function removes a holiday of a market zone.
+func (e *Service) DeleteHoliday(ctx context.Context, req *admin_pbmarket.DeleteRequestHoliday) (*admin_pbmarket.ResponseZone, error) {
+
+	// The purpose of this code is to declare the response variable and the migrate variable, which is used to check the rules of the user.
+	var (
+		response admin_pbmarket.ResponseZone
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The purpose of the if statement is to check whether the user has the necessary permissions to write and edit data.
+	if !migrate.Rules(auth, "assets", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _, err := e.Context.Db.Exec("delete from holidays where id = $1", req.GetId()); err != nil {
+		return &response, err
 	}
 	response.Success = true
 
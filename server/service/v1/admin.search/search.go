@@ -0,0 +1,23 @@
+package admin_search
+
+import (
+	"github.com/cryptogateway/backend-envoys/assets"
+	"google.golang.org/grpc/status"
+	"unicode/utf8"
+)
+
+// Service - The type Service struct is used to store a pointer to an assets.Context object. The search service lets the support
+// staff find the users, the transactions and the orders by the values the users give them, instead of running the raw
+// queries against the database.
+type Service struct {
+	Context *assets.Context
+}
+
+// queryTerm - This function checks that the searched value is long enough to be looked up by the trigram indexes, the shorter
+// values would match most of the rows and make the database read the whole table.
+func (s *Service) queryTerm(value string) error {
+	if utf8.RuneCountInString(value) < 3 {
+		return status.Errorf(11721, "the searched value %v must be at least 3 characters long", value)
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+package admin_search
+
+import (
+	"context"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	admin_pbsearch "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbsearch"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+	"strconv"
+)
+
+// GetUsers - This function finds the users for the support staff. The search value is matched against the id, the email, the
+// name and the deposit addresses of the users at once, the other filters of the request narrow the result down: the part
+// of the email, the deposit address on the platform, the status of the account and the date range of the registration.
+// The users are returned by the pages of the cursor, sorted by the id, the email or the date of the registration.
+func (s *Service) GetUsers(ctx context.Context, req *admin_pbsearch.GetRequestUsers) (*admin_pbsearch.ResponseUser, error) {
+
+	var (
+		response admin_pbsearch.ResponseUser
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		users []*types.User
+	)
+
+	auth := s.Context.User(ctx)
+
+	// The users are searched by the support staff, the search is read only, so the rules of the accounts are enough.
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	page, err := query.NewPage(req.GetLimit(), req.GetSort(), req.GetOrder(), req.GetCursor(), "id", "email", "create_at")
+	if err != nil {
+		return &response, err
+	}
+
+	// The search value is looked up in all the columns the users are known by, the id is matched only when the value is a number.
+	if len(req.GetSearch()) > 0 {
+
+		if err := s.queryTerm(req.GetSearch()); err != nil {
+			return &response, err
+		}
+
+		like := query.Like(req.GetSearch())
+		if id, err := strconv.ParseInt(req.GetSearch(), 10, 64); err == nil {
+			page.Where("(id = ? or email ilike ? or name ilike ? or id in (select user_id from wallets where address ilike ?))", id, like, like, like)
+		} else {
+			page.Where("(email ilike ? or name ilike ? or id in (select user_id from wallets where address ilike ?))", like, like, like)
+		}
+	}
+
+	if len(req.GetEmail()) > 0 {
+
+		if err := s.queryTerm(req.GetEmail()); err != nil {
+			return &response, err
+		}
+
+		page.Where("email ilike ?", query.Like(req.GetEmail()))
+	}
+
+	// The deposit addresses are matched also when they are archived, the deposits to the archived addresses are still credited.
+	if len(req.GetAddress()) > 0 {
+
+		if err := s.queryTerm(req.GetAddress()); err != nil {
+			return &response, err
+		}
+
+		if len(req.GetPlatform()) > 0 {
+			page.Where("id in (select user_id from wallets where address ilike ? and platform = ?)", query.Like(req.GetAddress()), req.GetPlatform())
+		} else {
+			page.Where("id in (select user_id from wallets where address ilike ?)", query.Like(req.GetAddress()))
+		}
+	}
+
+	switch req.GetStatus() {
+	case "":
+	case "active":
+		page.Where("status = ?", true)
+	case "blocked":
+		page.Where("status = ?", false)
+	default:
+		return &response, status.Errorf(11722, "the status of the users must be active or blocked, not %v", req.GetStatus())
+	}
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	where, params := page.Filter()
+	_ = s.Context.Reader().QueryRow(fmt.Sprintf("select count(*) as count from accounts %s", where), params...).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		statement, params := page.Query("id, name, email, status, priority, create_at", "accounts")
+		rows, err := s.Context.Reader().Query(statement, params...)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.User
+			)
+
+			if err = rows.Scan(&item.Id, &item.Name, &item.Email, &item.Status, &item.Priority, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			users = append(users, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+
+		response.Fields, response.Cursor = query.Cut(page, users)
+	}
+
+	return &response, nil
+}
+
+// GetTransactions - This function finds the transactions for the support staff. The search value is matched against the hash,
+// the destination address and the uid of the transactions, the other filters of the request narrow the result down: the
+// part of the hash, the destination address, the user, the symbol, the assignment, the status and the date range. The
+// transactions are returned by the pages of the cursor, sorted by the id, the value or the date of the transaction.
+func (s *Service) GetTransactions(ctx context.Context, req *admin_pbsearch.GetRequestTransactions) (*admin_pbsearch.ResponseTransaction, error) {
+
+	var (
+		response admin_pbsearch.ResponseTransaction
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		transactions []*types.Transaction
+	)
+
+	auth := s.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	page, err := query.NewPage(req.GetLimit(), req.GetSort(), req.GetOrder(), req.GetCursor(), "id", "value", "create_at")
+	if err != nil {
+		return &response, err
+	}
+
+	// The search value is looked up in the hash and the destination address, the uid of the transaction is matched exactly.
+	if len(req.GetSearch()) > 0 {
+
+		if err := s.queryTerm(req.GetSearch()); err != nil {
+			return &response, err
+		}
+
+		like := query.Like(req.GetSearch())
+		page.Where(`(hash ilike ? or "to" ilike ? or uid::text = lower(?))`, like, like, req.GetSearch())
+	}
+
+	if len(req.GetHash()) > 0 {
+
+		if err := s.queryTerm(req.GetHash()); err != nil {
+			return &response, err
+		}
+
+		page.Where("hash ilike ?", query.Like(req.GetHash()))
+	}
+
+	if len(req.GetAddress()) > 0 {
+
+		if err := s.queryTerm(req.GetAddress()); err != nil {
+			return &response, err
+		}
+
+		page.Where(`"to" ilike ?`, query.Like(req.GetAddress()))
+	}
+
+	if req.GetUserId() > 0 {
+		page.Where("user_id = ?", req.GetUserId())
+	}
+
+	if len(req.GetSymbol()) > 0 {
+		page.Where("symbol = ?", req.GetSymbol())
+	}
+
+	switch req.GetAssignment() {
+	case "":
+	case types.AssignmentDeposit, types.AssignmentWithdrawal:
+		page.Where("assignment = ?", req.GetAssignment())
+	default:
+		return &response, status.Errorf(11723, "the assignment of the transactions must be %v or %v, not %v", types.AssignmentDeposit, types.AssignmentWithdrawal, req.GetAssignment())
+	}
+
+	if len(req.GetStatus()) > 0 {
+
+		if err := types.Status(req.GetStatus()); err != nil {
+			return &response, err
+		}
+
+		page.Where("status = ?", req.GetStatus())
+	}
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	where, params := page.Filter()
+	_ = s.Context.Reader().QueryRow(fmt.Sprintf("select count(*) as count from transactions %s", where), params...).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		statement, params := page.Query(`id, uid, symbol, hash, value, price, fees, confirmation, "to", chain_id, user_id, parent, assignment, "group", platform, protocol, allocation, status, error, create_at`, "transactions")
+		rows, err := s.Context.Reader().Query(statement, params...)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Transaction
+			)
+
+			if err = rows.Scan(
+				&item.Id,
+				&item.Uid,
+				&item.Symbol,
+				&item.Hash,
+				&item.Value,
+				&item.Price,
+				&item.Fees,
+				&item.Confirmation,
+				&item.To,
+				&item.ChainId,
+				&item.UserId,
+				&item.Parent,
+				&item.Assignment,
+				&item.Group,
+				&item.Platform,
+				&item.Protocol,
+				&item.Allocation,
+				&item.Status,
+				&item.Error,
+				&item.CreateAt,
+			); err != nil {
+				return &response, err
+			}
+
+			transactions = append(transactions, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+
+		response.Fields, response.Cursor = query.Cut(page, transactions)
+	}
+
+	return &response, nil
+}
+
+// GetOrders - This function finds the orders for the support staff. The orders are found by the client id the user gave to the
+// order or by its uid, the other filters of the request narrow the result down: the user, the pair, the side, the type,
+// the status and the date range. The orders are returned by the pages of the cursor, sorted by the id, the price, the
+// value or the date of the order.
+func (s *Service) GetOrders(ctx context.Context, req *admin_pbsearch.GetRequestOrders) (*admin_pbsearch.ResponseOrder, error) {
+
+	var (
+		response admin_pbsearch.ResponseOrder
+		migrate  = query.Migrate{
+			Context: s.Context,
+		}
+		orders []*types.Order
+	)
+
+	auth := s.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	page, err := query.NewPage(req.GetLimit(), req.GetSort(), req.GetOrder(), req.GetCursor(), "id", "price", "value", "create_at")
+	if err != nil {
+		return &response, err
+	}
+
+	// The client ids are matched exactly, they are unique only among the orders of one user, so the orders of several users
+	// can be returned when the user is not given.
+	if len(req.GetClientId()) > 0 {
+		page.Where("client_id = ?", req.GetClientId())
+	}
+
+	if len(req.GetUid()) > 0 {
+
+		_provider := provider.Service{
+			Context: s.Context,
+		}
+
+		id, err := _provider.QueryIdentifier("orders", req.GetUid())
+		if err != nil {
+			return &response, err
+		}
+
+		page.Where("id = ?", id)
+	}
+
+	if req.GetUserId() > 0 {
+		page.Where("user_id = ?", req.GetUserId())
+	}
+
+	if len(req.GetBaseUnit()) > 0 {
+		page.Where("base_unit = ?", req.GetBaseUnit())
+	}
+
+	if len(req.GetQuoteUnit()) > 0 {
+		page.Where("quote_unit = ?", req.GetQuoteUnit())
+	}
+
+	switch req.GetAssigning() {
+	case "":
+	case types.AssigningBuy, types.AssigningSell:
+		page.Where("assigning = ?", req.GetAssigning())
+	default:
+		return &response, status.Errorf(11724, "the side of the orders must be %v or %v, not %v", types.AssigningBuy, types.AssigningSell, req.GetAssigning())
+	}
+
+	if len(req.GetType()) > 0 {
+
+		if err := types.Type(req.GetType()); err != nil {
+			return &response, err
+		}
+
+		page.Where("type = ?", req.GetType())
+	}
+
+	if len(req.GetStatus()) > 0 {
+
+		if err := types.Status(req.GetStatus()); err != nil {
+			return &response, err
+		}
+
+		page.Where("status = ?", req.GetStatus())
+	}
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	where, params := page.Filter()
+	_ = s.Context.Reader().QueryRow(fmt.Sprintf("select count(*) as count from orders %s", where), params...).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		statement, params := page.Query("id, uid, client_id, assigning, price, value, quantity, base_unit, quote_unit, user_id, type, trading, status, create_at", "orders")
+		rows, err := s.Context.Reader().Query(statement, params...)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Order
+			)
+
+			if err = rows.Scan(&item.Id, &item.Uid, &item.ClientId, &item.Assigning, &item.Price, &item.Value, &item.Quantity, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.Type, &item.Trading, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			orders = append(orders, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+
+		response.Fields, response.Cursor = query.Cut(page, orders)
+	}
+
+	return &response, nil
+}
@@ -1,7 +1,15 @@
 package admin_spot
 
 import (
+	"bytes"
+	"encoding/csv"
 	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+	"io"
+	"strconv"
+	"strings"
 )
 
 // Service - The purpose of the Service struct is to store data related to a service, such as the Context, run and wait maps, and
@@ -11,3 +19,121 @@ import (
 type Service struct {
 	Context *assets.Context
 }
+
+// queryMetadata - This function reads the symbol and the decimals of the fungible token contract from the chain it is deployed
+// on. Only fungible protocols (erc20, bep20, trc20) expose both methods, for the other protocols an error is returned, so
+// that the metadata of the contract has to be filled in manually.
+func (e *Service) queryMetadata(chain *types.Chain, address, protocol string) (symbol string, decimals int32, err error) {
+
+	if !strings.HasSuffix(protocol, "20") && protocol != types.ProtocolSpl {
+		return symbol, decimals, status.Errorf(11648, "the metadata of the %v contract can not be read from the chain", protocol)
+	}
+
+	client, err := platform.Dial(chain.GetRpc(), chain.GetPlatform())
+	if err != nil {
+		return symbol, decimals, err
+	}
+
+	// The contract is considered invalid if it does not answer the symbol() and decimals() calls, or if it is not the mint of an
+	// SPL token, most often this means that the address belongs to an account or to a contract on another chain.
+	symbol, decimals, err = client.Metadata(address)
+	if err != nil || len(symbol) == 0 {
+		return symbol, decimals, status.Errorf(11649, "the address %v is not a token contract on the %v chain", address, chain.GetName())
+	}
+
+	return symbol, decimals, nil
+}
+
+// queryValidateAddress - This function validates the address of the contract or of the account with the adapter of the platform,
+// the contracts are recorded only on the platforms with an adapter.
+func (e *Service) queryValidateAddress(name, address string) error {
+
+	adapter, ok := platform.Lookup(name)
+	if !ok {
+		return status.Errorf(10789, "cryptocurrency not available: %s", name)
+	}
+
+	return adapter.Validate(address)
+}
+
+// queryDeposit - This function returns the crypto deposit by its id with the fields the review of the held deposits needs: the
+// sender, the deposit address, the value and the status of the deposit, the reason it is held and whether it came from a
+// verified source.
+func (e *Service) queryDeposit(id int64) (*types.Transaction, error) {
+
+	var (
+		item types.Transaction
+	)
+
+	if err := e.Context.Db.QueryRow(`select id, symbol, "from", "to", value, chain_id, user_id, platform, protocol, status, hold, verified, create_at from transactions where id = $1 and assignment = $2 and "group" = $3`, id, types.AssignmentDeposit, types.GroupCrypto).Scan(
+		&item.Id,
+		&item.Symbol,
+		&item.From,
+		&item.To,
+		&item.Value,
+		&item.ChainId,
+		&item.UserId,
+		&item.Platform,
+		&item.Protocol,
+		&item.Status,
+		&item.Hold,
+		&item.Verified,
+		&item.CreateAt,
+	); err != nil {
+		return &item, status.Errorf(11660, "the deposit %v is not found", id)
+	}
+
+	return &item, nil
+}
+
+// queryRecords - This function reads the recipients of the distribution from the csv file: every line holds the id of the user
+// and optionally the value credited to the user, the lines without the value are credited with the value of the
+// distribution. A first line that does not start with a number is treated as the header and skipped.
+func (e *Service) queryRecords(data []byte, value float64) (users []int64, values []float64, err error) {
+
+	var (
+		reader = csv.NewReader(bytes.NewReader(data))
+	)
+
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return users, values, status.Errorf(11664, "the csv file is malformed: %v", err)
+		}
+
+		if len(record) == 0 || len(strings.TrimSpace(record[0])) == 0 {
+			continue
+		}
+
+		userId, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return users, values, status.Errorf(11664, "the user id %v on the line %v of the csv file is not correct", record[0], line)
+		}
+
+		amount := value
+		if len(record) > 1 && len(strings.TrimSpace(record[1])) > 0 {
+			if amount, err = strconv.ParseFloat(strings.TrimSpace(record[1]), 64); err != nil {
+				return users, values, status.Errorf(11664, "the value %v on the line %v of the csv file is not correct", record[1], line)
+			}
+		}
+
+		if amount <= 0 {
+			return users, values, status.Errorf(11665, "the value on the line %v of the csv file must be greater than 0", line)
+		}
+
+		users = append(users, userId)
+		values = append(values, amount)
+	}
+
+	return users, values, nil
+}
@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
-	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	admin_pbspot "github.com/cryptogateway/backend-envoys/server/proto/v1/admin.pbspot"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
 	"google.golang.org/grpc/status"
 	"strings"
+	"time"
 )
 
 // GetChains - This code is a function to get the chains rule from the database. It authenticates the user and checks if they have
@@ -34,12 +35,7 @@ func (e *Service) GetChains(ctx context.Context, req *admin_pbspot.GetRequestCha
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This if statement is used to check if the given user has the necessary permissions to perform a certain operation. If
 	// the user does not have the permission, an error message is returned.
@@ -66,7 +62,7 @@ func (e *Service) GetChains(ctx context.Context, req *admin_pbspot.GetRequestCha
 		// This code is used to query a database and fetch data from the database. The query is selecting certain columns from
 		// the table "chains" and ordering them in descending order of id, with a limit and an offset set by the request. If
 		// there is an error, the error is returned. Finally, the rows object is closed.
-		rows, err := e.Context.Db.Query(`select id, name, rpc, block, network, explorer_link, platform, confirmation, time_withdraw, fees, tag, decimals, status from chains order by id desc limit $1 offset $2`, req.GetLimit(), offset)
+		rows, err := e.Context.Db.Query(`select id, name, rpc, block, network, explorer_link, platform, confirmation, time_withdraw, fees, tag, decimals, status, stream, sla, price_cap, workers from chains order by id desc limit $1 offset $2`, req.GetLimit(), offset)
 		if err != nil {
 			return &response, err
 		}
@@ -84,7 +80,7 @@ func (e *Service) GetChains(ctx context.Context, req *admin_pbspot.GetRequestCha
 			// This code is used to scan through a row of data and assign each column value to a variable. The variables are
 			// item.Id, item.Name, item.Rpc, etc. The if statement checks for any errors while scanning the row and returns an
 			// error if any occur.
-			if err = rows.Scan(&item.Id, &item.Name, &item.Rpc, &item.Block, &item.Network, &item.ExplorerLink, &item.Platform, &item.Confirmation, &item.TimeWithdraw, &item.Fees, &item.Tag, &item.Decimals, &item.Status); err != nil {
+			if err = rows.Scan(&item.Id, &item.Name, &item.Rpc, &item.Block, &item.Network, &item.ExplorerLink, &item.Platform, &item.Confirmation, &item.TimeWithdraw, &item.Fees, &item.Tag, &item.Decimals, &item.Status, &item.Stream, &item.Sla, &item.PriceCap, &item.Workers); err != nil {
 				return &response, err
 			}
 
@@ -103,6 +99,51 @@ func (e *Service) GetChains(ctx context.Context, req *admin_pbspot.GetRequestCha
 	return &response, nil
 }
 
+// GetScanners - This function returns the scanners of the chains with their lag: the next block the scanner reads, the head of the
+// chain reported by its node and the number of the blocks between the head and the last block scanned, the scanners most
+// behind first. The head and the lag are as the scanner last reported them, at the time of the report, the streamed
+// scanners took the head from the subscription of the chain.
+func (e *Service) GetScanners(ctx context.Context, _ *admin_pbspot.GetRequestScanners) (*admin_pbspot.ResponseScanner, error) {
+
+	var (
+		response admin_pbspot.ResponseScanner
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := e.Context.Db.Query(`select id, name, platform, block, head, greatest(head - (block - 1), 0) as lag, status, coalesce(scan_at::text, ''), streamed, workers from chains order by lag desc, id`)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item admin_pbspot.Scanner
+		)
+
+		if err = rows.Scan(&item.ChainId, &item.Name, &item.Platform, &item.Block, &item.Head, &item.Lag, &item.Status, &item.ScanAt, &item.Streamed, &item.Workers); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
 // GetChain - This code is part of an authentication process. Its purpose is to attempt to authenticate the user and retrieve the
 // authentication data. If there is an error, it is returned to the caller. It also checks the user's permissions to
 // ensure they have the appropriate rules for writing and editing data. Finally, if the request is valid, it retrieves
@@ -118,12 +159,7 @@ func (e *Service) GetChain(ctx context.Context, req *admin_pbspot.GetRequestChai
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking to see if the user has certain permissions (rules) to perform an action on a particular set of
 	// data (chains). If the user does not have the required permissions, an error message is returned.
@@ -161,12 +197,7 @@ func (e *Service) SetChain(ctx context.Context, req *admin_pbspot.SetRequestChai
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking the user's authorization for writing and editing data. The first part of the if statement
 	// checks is the user has the necessary rules to write and edit data in the chains. The second part of the if statement
@@ -196,6 +227,32 @@ func (e *Service) SetChain(ctx context.Context, req *admin_pbspot.SetRequestChai
 		return &response, status.Error(45601, "chain server address not available")
 	}
 
+	// The stream of the chain is the websocket endpoint of its node, the scanner follows the heads of the chain with it.
+	if stream := req.Chain.GetStream(); len(stream) > 0 && !strings.HasPrefix(stream, "ws://") && !strings.HasPrefix(stream, "wss://") {
+		return &response, status.Error(11811, "the stream of the chain must be the websocket endpoint, ws:// or wss://")
+	}
+
+	// The withdrawals of the chain without the sla are not watched, those of the chain without the price cap are reported
+	// stuck and are not sent again at a higher price.
+	if req.Chain.GetSla() < 0 || req.Chain.GetPriceCap() < 0 {
+		return &response, status.Error(11814, "the sla and the price cap of the chain must not be negative")
+	}
+
+	// The workers of the chain are the blocks its scanner reads at the same time, one when they are not given, and no more
+	// than the scanners of the configuration read at the same time.
+	if req.Chain.GetWorkers() == 0 {
+		req.Chain.Workers = 1
+	}
+
+	ceiling := 1
+	if e.Context.Scanner != nil && e.Context.Scanner.Workers > 0 {
+		ceiling = e.Context.Scanner.Workers
+	}
+
+	if req.Chain.GetWorkers() < 1 || int(req.Chain.GetWorkers()) > ceiling {
+		return &response, status.Errorf(11768, "the workers of the chain must be from 1 to %v", ceiling)
+	}
+
 	// This is a conditional statement that checks if the value of the req.GetId() function is greater than 0. If it is,
 	// then the code in the code block that follows will be executed. If it is not, then the code will be skipped.
 	if req.GetId() > 0 {
@@ -204,7 +261,7 @@ func (e *Service) SetChain(ctx context.Context, req *admin_pbspot.SetRequestChai
 		// of the database fields (name, rpc, network, block, explorer_link, platform, confirmation, time_withdraw,
 		// fees_withdraw, tag, parent_symbol, and status) to values passed in the request (req). The id of the entry
 		// to be updated is also passed in the request. The purpose of this code is to update the values of a particular database entry in the "chains" table.
-		if _, err := e.Context.Db.Exec("update chains set name = $1, rpc = $2, network = $3, block = $4, explorer_link = $5, platform = $6, confirmation = $7, time_withdraw = $8, fees = $9, tag = $10, parent_symbol = $11, decimals = $12, status = $13 where id = $14;",
+		if _, err := e.Context.Db.Exec("update chains set name = $1, rpc = $2, network = $3, block = $4, explorer_link = $5, platform = $6, confirmation = $7, time_withdraw = $8, fees = $9, tag = $10, parent_symbol = $11, decimals = $12, status = $13, stream = $14, sla = $15, price_cap = $16, workers = $17 where id = $18;",
 			req.Chain.GetName(),
 			req.Chain.GetRpc(),
 			req.Chain.GetNetwork(),
@@ -218,6 +275,10 @@ func (e *Service) SetChain(ctx context.Context, req *admin_pbspot.SetRequestChai
 			req.Chain.GetParentSymbol(),
 			req.Chain.GetDecimals(),
 			req.Chain.GetStatus(),
+			req.Chain.GetStream(),
+			req.Chain.GetSla(),
+			req.Chain.GetPriceCap(),
+			req.Chain.GetWorkers(),
 			req.GetId(),
 		); err != nil {
 			return &response, err
@@ -229,7 +290,7 @@ func (e *Service) SetChain(ctx context.Context, req *admin_pbspot.SetRequestChai
 		// values of the 'req.Chain' object into the specified fields of the 'chains' table. The variables that are being
 		// inserted are the name, RPC, network, block, explorer link, platform, confirmation, time withdraw, fees withdraw,
 		// tag, parent symbol, and status of the chain object.
-		if _, err := e.Context.Db.Exec("insert into chains (name, rpc, network, block, explorer_link, platform, confirmation, time_withdraw, fees, tag, parent_symbol, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		if _, err := e.Context.Db.Exec("insert into chains (name, rpc, network, block, explorer_link, platform, confirmation, time_withdraw, fees, tag, parent_symbol, status, stream, sla, price_cap, workers) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)",
 			req.Chain.GetName(),
 			req.Chain.GetRpc(),
 			req.Chain.GetNetwork(),
@@ -242,11 +303,20 @@ func (e *Service) SetChain(ctx context.Context, req *admin_pbspot.SetRequestChai
 			req.Chain.GetTag(),
 			req.Chain.GetParentSymbol(),
 			req.Chain.GetStatus(),
+			req.Chain.GetStream(),
+			req.Chain.GetSla(),
+			req.Chain.GetPriceCap(),
+			req.Chain.GetWorkers(),
 		); err != nil {
 			return &response, err
 		}
 
 	}
+
+	// The rpc of the chain is one of the endpoints of its pool, the failover switches the chain between them.
+	if _, err := e.Context.Db.Exec("insert into chain_endpoints (chain_id, rpc) select id, rpc from chains where name = $1 on conflict (chain_id, rpc) do nothing", req.Chain.GetName()); err != nil {
+		return &response, err
+	}
 	response.Success = true
 
 	return &response, nil
@@ -267,12 +337,7 @@ func (e *Service) DeleteChain(ctx context.Context, req *admin_pbspot.DeleteReque
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking the user's access privileges to determine if they are allowed to perform the requested
 	// operation. It checks to see if the user has the 'currencies' rule and if they have the 'deny-record' rule. If they do
@@ -290,7 +355,8 @@ func (e *Service) DeleteChain(ctx context.Context, req *admin_pbspot.DeleteReque
 	// The first line is getting the chain from the database, and the second line is removing it from the database. The
 	// third line is deleting the chain from the chains table.
 	if row, _ := _provider.QueryChain(req.GetId(), false); row.GetId() > 0 {
-		_, _ = e.Context.Db.Exec(fmt.Sprintf(`update assets set chains = jsonb_path_query_array(chains, '$[*] ? (@ != %[1]d)') where chains @> '%[1]d'`, row.GetId()))
+		_, _ = e.Context.Db.Exec("delete from asset_chains where chain_id = $1", row.GetId())
+		_, _ = e.Context.Db.Exec("delete from chain_endpoints where chain_id = $1", row.GetId())
 		_, _ = e.Context.Db.Exec("delete from chains where id = $1", row.GetId())
 	}
 	response.Success = true
@@ -304,15 +370,15 @@ func (e *Service) DeleteChain(ctx context.Context, req *admin_pbspot.DeleteReque
 // information. If there is an error, it is returned to the caller.
 func (e *Service) GetContracts(ctx context.Context, req *admin_pbspot.GetRequestContracts) (*admin_pbspot.ResponseContract, error) {
 
-	// The code snippet above is declaring three variables: response, migrate, and maps. The variable response is declared
-	// as type admin_pbspot.ResponseContract, migrate is declared as type query.Migrate and maps is declared as an array of
-	// strings. The purpose of this is to create three variables with the necessary types, so they can be used in the code. These variables can be used to store data and manipulate it, depending on the purpose of the code.
+	// The code snippet above is declaring three variables: response, migrate, and builder. The variable response is declared
+	// as type admin_pbspot.ResponseContract, migrate is declared as type query.Migrate and builder holds the conditions of
+	// the query. The purpose of this is to create three variables with the necessary types, so they can be used in the code. These variables can be used to store data and manipulate it, depending on the purpose of the code.
 	var (
 		response admin_pbspot.ResponseContract
 		migrate  = query.Migrate{
 			Context: e.Context,
 		}
-		maps []string
+		builder = query.NewBuilder()
 	)
 
 	// The purpose of this code is to check if the request limit is 0, and if so, set a default limit of 30. This ensures
@@ -321,12 +387,7 @@ func (e *Service) GetContracts(ctx context.Context, req *admin_pbspot.GetRequest
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This if statement checks is the user has the correct authorization for writing and editing data for the contracts
 	// query. If the user does not have the correct authorization, the statement returns an error message indicating the
@@ -337,15 +398,16 @@ func (e *Service) GetContracts(ctx context.Context, req *admin_pbspot.GetRequest
 
 	// The purpose of this code is to search for either the address or symbol of a customer using the GetSearch() method.
 	// The "%" symbol is used to indicate that the search should match any characters before and after the specified text in
-	// the req.GetSearch() method. The code will append the search query to the maps variable, which will then be used to
-	// search the customer database.
+	// the req.GetSearch() method. The code will add the search query to the conditions of the builder, the search query is
+	// bound as the parameter.
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("where c.address like %[1]s or c.symbol like %[1]s", "'%"+req.GetSearch()+"%'"))
+		like := query.Like(req.GetSearch())
+		builder.Where("(c.address like ? or c.symbol like ?)", like, like)
 	}
 
 	// The purpose of this code is to query a database and scan the result into the response.Count field. The query is
-	// constructed using a printf-style string formatting, as well as a strings.Join() function to combine elements of the maps variable.
-	_ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from contracts c %s", strings.Join(maps, " "))).Scan(&response.Count)
+	// constructed using a printf-style string formatting with the where clause of the builder.
+	_ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from contracts c %s", builder.Clause()), builder.Params()...).Scan(&response.Count)
 
 	// This code checks if the response has a count greater than 0. If it does, then some code is executed. This is used to
 	// make sure that the response contains data before the code is executed.
@@ -363,9 +425,9 @@ func (e *Service) GetContracts(ctx context.Context, req *admin_pbspot.GetRequest
 
 		// This code is used to query the database. Specifically, it is used to query the contracts table and the chains table,
 		// joining the two tables via the chain_id column. The query includes a limit and offset, which are specified in the
-		// 'req' object, as well as any additional conditions specified in the 'maps' object. The data returned is stored in
+		// 'req' object, as well as any additional conditions specified in the builder. The data returned is stored in
 		// the 'rows' object and is then used to construct a response. If an error occurs, it is logged and the response is returned.
-		rows, err := e.Context.Db.Query(fmt.Sprintf("select c.id, c.symbol, c.chain_id, c.address, c.fees, c.decimals, c.protocol, n.platform, n.parent_symbol from contracts c inner join chains n on n.id = c.chain_id %s order by c.id desc limit %d offset %d", strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := e.Context.Db.Query(fmt.Sprintf("select c.id, c.symbol, c.chain_id, c.address, c.fees, c.decimals, c.protocol, n.platform, n.parent_symbol from contracts c inner join chains n on n.id = c.chain_id %s order by c.id desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -435,12 +497,7 @@ func (e *Service) GetContract(ctx context.Context, req *admin_pbspot.GetRequestC
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking to see if the user has the correct permissions to modify data in the "contracts" table. If the
 	// user does not have the correct permissions (represented by the query.RoleSpot parameter), then an error is returned.
@@ -479,12 +536,7 @@ func (e *Service) SetContract(ctx context.Context, req *admin_pbspot.SetRequestC
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking the authorization of a user based on their role. The first expression uses the migrate.Rules()
 	// function to check if the user has permission to write or edit data in the contracts table. If the user does not have
@@ -504,7 +556,7 @@ func (e *Service) SetContract(ctx context.Context, req *admin_pbspot.SetRequestC
 
 	// This code checks to see if the address provided for the contract is valid for the given platform. If it is not valid,
 	// an error is returned.
-	if err := keypair.ValidateCryptoAddress(req.Contract.GetAddress(), req.Contract.GetPlatform()); err != nil {
+	if err := e.queryValidateAddress(req.Contract.GetPlatform(), req.Contract.GetAddress()); err != nil {
 		return &response, err
 	}
 
@@ -526,6 +578,22 @@ func (e *Service) SetContract(ctx context.Context, req *admin_pbspot.SetRequestC
 		return &response, status.Errorf(32798, "the fee of the contract must not be less than the fee of the network of the parent %v face value", chain.GetParentSymbol())
 	}
 
+	// Fungible token contracts are validated on the chain before they are registered: the contract has to answer the symbol()
+	// and decimals() calls, the symbol has to match the registered currency and the decimals are always taken from the chain,
+	// since a wrong number of decimals would credit deposits with wrong amounts.
+	if strings.HasSuffix(req.Contract.GetProtocol(), "20") {
+
+		symbol, decimals, err := e.queryMetadata(chain, req.Contract.GetAddress(), req.Contract.GetProtocol())
+		if err != nil {
+			return &response, err
+		}
+
+		if !strings.EqualFold(symbol, req.Contract.GetSymbol()) {
+			return &response, status.Errorf(11650, "the symbol of the contract on the chain is %v, not %v", symbol, req.Contract.GetSymbol())
+		}
+		req.Contract.Decimals = decimals
+	}
+
 	// This code is checking to see if the request ID is greater than 0. If the ID is greater than 0, then the code will
 	// execute whatever follows the if statement.
 	if req.GetId() > 0 {
@@ -564,6 +632,64 @@ func (e *Service) SetContract(ctx context.Context, req *admin_pbspot.SetRequestC
 	}
 	response.Success = true
 
+	// The deposit scanner looks the contract up by its address on every transfer it sees, so the new contract is picked up from
+	// the next block without a restart of the service.
+	if err := e.Context.Publish(req.Contract, "exchange", "contract/update"); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// GetContractMetadata - This method reads the symbol and the decimals of the token contract from the chain, so that the
+// administrator can check the contract before registering it. The chain is taken from the request and the contract has
+// to answer the symbol() and decimals() calls, otherwise an error is returned.
+func (e *Service) GetContractMetadata(ctx context.Context, req *admin_pbspot.GetRequestContractMetadata) (*admin_pbspot.ResponseContract, error) {
+
+	var (
+		response admin_pbspot.ResponseContract
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "contracts", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	chain, err := _provider.QueryChain(req.GetChainId(), false)
+	if err != nil {
+		return &response, err
+	}
+
+	if err := e.queryValidateAddress(chain.GetPlatform(), req.GetAddress()); err != nil {
+		return &response, err
+	}
+
+	symbol, decimals, err := e.queryMetadata(chain, req.GetAddress(), req.GetProtocol())
+	if err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, &types.Contract{
+		ChainId:      chain.GetId(),
+		ChainName:    chain.GetName(),
+		ParentSymbol: chain.GetParentSymbol(),
+		Symbol:       strings.ToLower(symbol),
+		Address:      req.GetAddress(),
+		Decimals:     decimals,
+		Platform:     chain.GetPlatform(),
+		Protocol:     req.GetProtocol(),
+		Fees:         chain.GetFees(),
+	})
+
 	return &response, nil
 }
 
@@ -583,12 +709,7 @@ func (e *Service) DeleteContract(ctx context.Context, req *admin_pbspot.DeleteRe
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code checks the user's authentication (auth) to see if they have the appropriate rules ("contracts" and
 	// "deny-record") to write and edit data. If they do not have the necessary rules, it returns an error message.
@@ -622,13 +743,13 @@ func (e *Service) GetTransactions(ctx context.Context, req *admin_pbspot.GetRequ
 
 	// The purpose of this code is to declare three variables. The first variable, response, is of type
 	// admin_pbspot.ResponseTransaction. The second variable, migrate, is of type query.Migrate, with a Context field set to the
-	// value of e.Context. The third variable, maps, is of type string slice.
+	// value of e.Context. The third variable, builder, holds the conditions of the query.
 	var (
 		response admin_pbspot.ResponseTransaction
 		migrate  = query.Migrate{
 			Context: e.Context,
 		}
-		maps []string
+		builder = query.NewBuilder()
 	)
 
 	// The purpose of this code is to set a limit on the request if no limit is specified. If req.GetLimit() returns 0, then
@@ -637,12 +758,7 @@ func (e *Service) GetTransactions(ctx context.Context, req *admin_pbspot.GetRequ
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking whether a user has the necessary permissions to write and edit data in the "accounts" table. If
 	// the user does not have the permissions, an error is returned with a status code and message.
@@ -650,33 +766,31 @@ func (e *Service) GetTransactions(ctx context.Context, req *admin_pbspot.GetRequ
 		return &response, status.Error(12011, "you do not have rules for writing and editing data")
 	}
 
-	// This switch statement is used to create an SQL query depending on the transaction type requested. Depending on the
-	// request, it will append different strings to the "maps" array, which can then be used in an SQL query. In the default
-	// case, it will append a string with both transaction types.
-	switch req.GetAssignment() {
-	case types.AssignmentDeposit:
-		maps = append(maps, fmt.Sprintf("where assignment = '%v'", types.AssignmentDeposit))
-	case types.AssignmentWithdrawal:
-		maps = append(maps, fmt.Sprintf("where assignment = '%v'", types.AssignmentWithdrawal))
-	default:
-		maps = append(maps, fmt.Sprintf("where (assignment = '%v' or assignment = '%v')", types.AssignmentWithdrawal, types.AssignmentDeposit))
+	// This code is used to create an SQL query depending on the transaction type requested, the type is brought to the constant
+	// of the enum of the registry, so that the aliases, such as "WITHDRAWS", are taken too. When the type stands for none of
+	// the constants, it will add a condition with both transaction types.
+	if assignment, err := types.Normalize(types.EnumAssignment, req.GetAssignment()); err == nil {
+		builder.Where("assignment = ?", assignment)
+	} else {
+		builder.Where("(assignment = ? or assignment = ?)", types.AssignmentWithdrawal, types.AssignmentDeposit)
 	}
 
 	// This code checks if the request (req) contains a search term (GetSearch()) that is longer than 0. If it does, it
-	// appends the search term to a maps slice, with the search term formatted as a string in the SQL 'like' syntax. This
+	// adds the search term to the builder in the SQL 'like' syntax, bound as the parameter. This
 	// allows for a search query to be performed with the search term.
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("and (symbol like %[1]s or id::text like %[1]s or hash like %[1]s)", "'%"+req.GetSearch()+"%'"))
+		like := query.Like(req.GetSearch())
+		builder.Where("(symbol like ? or id::text like ? or hash like ?)", like, like, like)
 	}
 
 	// The purpose of this code is to add a condition to a query. The additional condition is to check the
-	// user_id from the request (req.GetId()) and append it to the existing map of conditions (maps).
-	maps = append(maps, fmt.Sprintf("and user_id = %v", req.GetId()))
+	// user_id from the request (req.GetId()).
+	builder.Where("user_id = ?", req.GetId())
 
 	// This code is used to query a database for the number of transactions and store the result in the response.Count
-	// variable. The fmt.Sprintf function is used to construct the query from strings and maps, which are then used to
-	// execute the query.
-	_ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from transactions %s", strings.Join(maps, " "))).Scan(&response.Count)
+	// variable. The fmt.Sprintf function is used to construct the query with the where clause of the builder, whose
+	// parameters are then used to execute the query.
+	_ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from transactions %s", builder.Clause()), builder.Params()...).Scan(&response.Count)
 
 	// This if statement is used to check if the GetCount() function returns a value greater than 0. If it does, then the
 	// code inside the statement will be executed.
@@ -695,7 +809,7 @@ func (e *Service) GetTransactions(ctx context.Context, req *admin_pbspot.GetRequ
 		// ordering them by the 'id' column in descending order. The limit and offset parameters are supplied from the
 		// req.GetLimit() and offset variables. If an error occurs when running the query, it will return an error message. The
 		// rows.Close() function is being used to close the query and free up any resources used by it.
-		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, symbol, hash, value, price, fees, chain_id, confirmation, "to", user_id, assignment, "group", platform, protocol, status, error, create_at from transactions %s order by id desc limit %d offset %d`, strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, uid, symbol, hash, value, price, fees, chain_id, confirmation, "to", user_id, assignment, "group", platform, protocol, status, error, create_at from transactions %s order by id desc limit %d offset %d`, builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -721,6 +835,7 @@ func (e *Service) GetTransactions(ctx context.Context, req *admin_pbspot.GetRequ
 			// while scanning the rows, the code will return an error.
 			if err = rows.Scan(
 				&item.Id,
+				&item.Uid,
 				&item.Symbol,
 				&item.Hash,
 				&item.Value,
@@ -775,14 +890,14 @@ func (e *Service) GetTransactions(ctx context.Context, req *admin_pbspot.GetRequ
 // returns the response object along with an error, if applicable.
 func (e *Service) GetReserves(ctx context.Context, req *admin_pbspot.GetRequestReserves) (*admin_pbspot.ResponseReserve, error) {
 
-	// The purpose of this code is to declare three variables: response, migrate, and maps. The response variable is of type
-	// admin_pbspot.ResponseReserve, to migrate variable is of type query.Migrate, and the maps variable is of type string slice.
+	// The purpose of this code is to declare three variables: response, migrate, and builder. The response variable is of type
+	// admin_pbspot.ResponseReserve, to migrate variable is of type query.Migrate, and the builder holds the conditions of the query.
 	var (
 		response admin_pbspot.ResponseReserve
 		migrate  = query.Migrate{
 			Context: e.Context,
 		}
-		maps []string
+		builder = query.NewBuilder()
 	)
 
 	// The purpose of this code is to set a limit on the request if no limit is specified. If req.GetLimit() returns 0, then
@@ -791,12 +906,7 @@ func (e *Service) GetReserves(ctx context.Context, req *admin_pbspot.GetRequestR
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code is checking whether a user has the necessary permissions to write and edit data in the "accounts" table. If
 	// the user does not have the permissions, an error is returned with a status code and message.
@@ -804,18 +914,19 @@ func (e *Service) GetReserves(ctx context.Context, req *admin_pbspot.GetRequestR
 		return &response, status.Error(12011, "you do not have rules for writing and editing data")
 	}
 
-	// This code is checking the length of the request's search query. If it is greater than 0, it appends a formatted
-	// string to the maps variable. The formatted string is a SQL statement that will search for a given keyword in four
-	// different columns of a database (symbol, user_id, address, and symbol). The '%' signs are used as wildcards, so the
-	// search query will match any record that contains the keyword in any of these columns.
+	// This code is checking the length of the request's search query. If it is greater than 0, it adds the condition to
+	// the builder that will search for a given keyword in three different columns of a database (symbol, user_id and
+	// address). The keyword is bound as the parameter, so the search query will match any record that contains the keyword
+	// in any of these columns.
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("where (symbol like %[1]s or user_id::text like %[1]s or address like %[1]s or symbol like %[1]s)", "'%"+req.GetSearch()+"%'"))
+		like := query.Like(req.GetSearch())
+		builder.Where("(symbol like ? or user_id::text like ? or address like ?)", like, like, like)
 	}
 
 	// This code is used to check if a query result contains at least one row. The QueryRow function is used to query the
 	// database, and the Scan function is used to store the result in the variable response.GetCount(). The if statement
 	// checks if the result contains at least one row by comparing the value of response.GetCount() to 0.
-	if _ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from reserves %s", strings.Join(maps, " "))).Scan(&response.Count); response.GetCount() > 0 {
+	if _ = e.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from reserves %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
 
 		// This code is setting an offset for a Paginated request. The offset is used to determine the index of the first item
 		// that should be returned. This code is calculating the offset by multiplying the limit (the number of items per page)
@@ -826,11 +937,9 @@ func (e *Service) GetReserves(ctx context.Context, req *admin_pbspot.GetRequestR
 		}
 
 		// This code is used to query a database to select certain data. The fmt.Sprintf function is used to build a query
-		// string based on the passed in arguments. The strings.Join function is used to create a comma-separated list of items
-		// from a slice of strings (maps). The query string is then used to query the database using the Context.Db.Query
-		// function. The query result is then stored in the rows variable. The rows.Close function is used to close the
-		// database connection when the query is finished.
-		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, symbol, user_id, value, reverse, address, platform, protocol, lock from reserves %s order by id desc limit %d offset %d`, strings.Join(maps, " "), req.GetLimit(), offset))
+		// string with the where clause of the builder, whose values are passed as the parameters. The query result is then
+		// stored in the rows variable. The rows.Close function is used to close the database connection when the query is finished.
+		rows, err := e.Context.Db.Query(fmt.Sprintf(`select id, symbol, user_id, value, reverse, address, platform, protocol, lock from reserves %s order by id desc limit %d offset %d`, builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -898,12 +1007,7 @@ func (e *Service) SetReserveUnlock(ctx context.Context, req *admin_pbspot.SetReq
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code checks the user's authentication (auth) to see if they have the appropriate rules ("contracts" and
 	// "deny-record") to write and edit data. If they do not have the necessary rules, it returns an error message.
@@ -940,12 +1044,7 @@ func (e *Service) GetBalances(ctx context.Context, req *admin_pbspot.GetRequestB
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
 		return &response, status.Error(12011, "you do not have rules for writing and editing data")
@@ -1022,12 +1121,7 @@ func (e *Service) GetRepayments(ctx context.Context, req *admin_pbspot.GetReques
 		req.Limit = 30
 	}
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// The purpose of this code is to check if the user has the necessary authorization (i.e. migrate.Rules) to perform a
 	// certain action (i.e. writing and editing data) related to a specific resource (i.e. repayments). If the user does not
@@ -1125,12 +1219,7 @@ func (e *Service) SetRepayments(ctx context.Context, req *admin_pbspot.SetReques
 		}
 	)
 
-	// This code is part of an authentication process. The purpose of this code is to attempt to authenticate the user and
-	// retrieve the authentication data. If there is an error, it is returned to the caller.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// This code checks the user's authentication (auth) to see if they have the appropriate rules ("contracts" and
 	// "deny-record") to write and edit data. If they do not have the necessary rules, it returns an error message.
@@ -1202,3 +1291,1413 @@ func (e *Service) SetRepayments(ctx context.Context, req *admin_pbspot.SetReques
 
 	return &response, status.Error(865456, "no such transaction exists")
 }
+
+// GetReorgs - This function returns the reorganizations of the chains that replaced the blocks of the deposits, the newest first:
+// the deposits located again in the other blocks, the pending deposits reverted, and the credited deposits orphaned,
+// which the operators were alerted of, since the balance of the user may have to be corrected.
+func (e *Service) GetReorgs(ctx context.Context, req *admin_pbspot.GetRequestReorgs) (*admin_pbspot.ResponseReorg, error) {
+
+	var (
+		response admin_pbspot.ResponseReorg
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow("select count(*) as count from reorgs where ($1 = '' or status = $1) and ($2 = 0 or chain_id = $2)", req.GetStatus(), req.GetChainId()).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query(`select id, chain_id, transaction_id, user_id, symbol, value, block, hash, canonical, relocated, status, create_at from reorgs where ($1 = '' or status = $1) and ($2 = 0 or chain_id = $2) order by id desc limit $3 offset $4`, req.GetStatus(), req.GetChainId(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Reorg
+			)
+
+			if err = rows.Scan(&item.Id, &item.ChainId, &item.TransactionId, &item.UserId, &item.Symbol, &item.Value, &item.Block, &item.Hash, &item.Canonical, &item.Relocated, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// GetEndpoints - This function returns the endpoints of the pool of the chain with their health: the head each of them reported,
+// the latency of its answer, the failures in a row and the last error, as the failover last checked them. The active
+// endpoint is the one the chain is served by.
+func (e *Service) GetEndpoints(ctx context.Context, req *admin_pbspot.GetRequestEndpoints) (*admin_pbspot.ResponseEndpoint, error) {
+
+	var (
+		response admin_pbspot.ResponseEndpoint
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := e.Context.Db.Query(`select e.id, e.chain_id, e.rpc, e.status, e.healthy, e.rpc = c.rpc as active, e.head, e.latency, e.failures, e.error, coalesce(e.check_at, e.create_at), e.create_at from chain_endpoints e inner join chains c on c.id = e.chain_id where $1 = 0 or e.chain_id = $1 order by e.chain_id, e.latency, e.id`, req.GetChainId())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Endpoint
+		)
+
+		if err = rows.Scan(&item.Id, &item.ChainId, &item.Rpc, &item.Status, &item.Healthy, &item.Active, &item.Head, &item.Latency, &item.Failures, &item.Error, &item.CheckAt, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// SetEndpoint - This function adds the endpoint to the pool of the chain, or changes the address of the endpoint and whether the
+// failover may switch the chain to it. The endpoint that is disabled while the chain is served by it is left by the
+// failover at its next check.
+func (e *Service) SetEndpoint(ctx context.Context, req *admin_pbspot.SetRequestEndpoint) (*admin_pbspot.ResponseEndpoint, error) {
+
+	var (
+		response admin_pbspot.ResponseEndpoint
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if len(req.GetRpc()) < 10 {
+		return &response, status.Error(44511, "chain rpc address must be at least < 10 characters")
+	}
+
+	if req.GetId() > 0 {
+
+		// The address of the endpoint the chain is served by is not changed, the chain is switched to another endpoint first.
+		result, err := e.Context.Db.Exec("update chain_endpoints e set rpc = $2, status = $3, healthy = false, failures = 0, error = '' from chains c where e.id = $1 and c.id = e.chain_id and (e.rpc = $2 or e.rpc <> c.rpc)", req.GetId(), req.GetRpc(), req.GetStatus())
+		if err != nil {
+			return &response, err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return &response, status.Errorf(11812, "the endpoint %v is not found or the chain is served by it", req.GetId())
+		}
+
+	} else {
+
+		if _, err := e.Context.Db.Exec("insert into chain_endpoints (chain_id, rpc, status) select id, $2, $3 from chains where id = $1 on conflict (chain_id, rpc) do update set status = excluded.status", req.GetChainId(), req.GetRpc(), req.GetStatus()); err != nil {
+			return &response, err
+		}
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteEndpoint - This function removes the endpoint from the pool of its chain, the endpoint the chain is served by is not
+// removed, since the rpc of the chain is always one of its endpoints.
+func (e *Service) DeleteEndpoint(ctx context.Context, req *admin_pbspot.DeleteRequestEndpoint) (*admin_pbspot.ResponseEndpoint, error) {
+
+	var (
+		response admin_pbspot.ResponseEndpoint
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	result, err := e.Context.Db.Exec("delete from chain_endpoints e using chains c where e.id = $1 and c.id = e.chain_id and e.rpc <> c.rpc", req.GetId())
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11813, "the endpoint %v is not found or the chain is served by it", req.GetId())
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetReplacements - This function returns the audit of the replacements of the stuck withdrawals, the newest first: the bumps of
+// the price the exchange sent by itself and the replacements and the cancellations the operators asked for, with the
+// operator, the reason, the hashes of the transactions replaced and sent and the status of every replacement.
+func (e *Service) GetReplacements(ctx context.Context, req *admin_pbspot.GetRequestReplacements) (*admin_pbspot.ResponseReplacement, error) {
+
+	var (
+		response admin_pbspot.ResponseReplacement
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow("select count(*) as count from replacements where ($1 = '' or status = $1) and ($2 = 0 or transaction_id = $2)", req.GetStatus(), req.GetTransactionId()).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query(`select id, transaction_id, chain_id, kind, hash, replacement, price, user_id, reason, error, status, update_at, create_at from replacements where ($1 = '' or status = $1) and ($2 = 0 or transaction_id = $2) order by id desc limit $3 offset $4`, req.GetStatus(), req.GetTransactionId(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Replacement
+			)
+
+			if err = rows.Scan(&item.Id, &item.TransactionId, &item.ChainId, &item.Kind, &item.Hash, &item.Replacement, &item.Price, &item.UserId, &item.Reason, &item.Error, &item.Status, &item.UpdateAt, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetReplacement - This function asks for the replacement of the transaction of the withdrawal that was sent and is not mined yet:
+// the same withdrawal at the price of the gas in gwei, an eighth higher than the price it waits with when the price is
+// not given, or its cancellation, the transfer of nothing in its place. The replacement is sent by the exchange within a
+// minute and is recorded in the audit with the operator and the reason.
+func (e *Service) SetReplacement(ctx context.Context, req *admin_pbspot.SetRequestReplacement) (*admin_pbspot.ResponseReplacement, error) {
+
+	var (
+		response admin_pbspot.ResponseReplacement
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		item = types.Replacement{
+			Kind:   types.ReplacementReplace,
+			Price:  req.GetPrice(),
+			Reason: req.GetReason(),
+			Status: types.StatusPending,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if len(req.GetReason()) == 0 {
+		return &response, status.Error(11815, "the reason of the replacement must be given")
+	}
+
+	if req.GetPrice() < 0 {
+		return &response, status.Error(11816, "the price of the gas of the replacement must not be negative")
+	}
+
+	if req.GetCancel() {
+		item.Kind = types.ReplacementCancel
+	}
+
+	// The withdrawal is replaced while it waits to be mined, once per request: the replacement asked for is sent first.
+	if err := e.Context.Db.QueryRow("insert into replacements (transaction_id, chain_id, kind, hash, price, user_id, reason, status) select id, chain_id, $2, hash, $3, $4, $5, $6 from transactions where id = $1 and assignment = $7 and status = $8 and broadcast_at is not null and not exists(select 1 from replacements where transaction_id = $1 and status = $6) returning id, transaction_id, chain_id, hash, user_id, update_at, create_at", req.GetId(), item.GetKind(), item.GetPrice(), auth, item.GetReason(), item.GetStatus(), types.AssignmentWithdrawal, types.StatusFilled).Scan(&item.Id, &item.TransactionId, &item.ChainId, &item.Hash, &item.UserId, &item.UpdateAt, &item.CreateAt); err != nil {
+		return &response, status.Errorf(11817, "the withdrawal %v is not waiting to be mined, or its replacement was already asked for", req.GetId())
+	}
+
+	response.Fields = append(response.Fields, &item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetConfirmations - This function returns the tiers of the confirmation counts of the currency, the tiers of the chain and the
+// tiers that apply to every chain of the currency (the chain id 0), ordered by the chain and by the value of the tier.
+func (e *Service) GetConfirmations(ctx context.Context, req *admin_pbspot.GetRequestConfirmations) (*admin_pbspot.ResponseConfirmation, error) {
+
+	var (
+		response admin_pbspot.ResponseConfirmation
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := e.Context.Db.Query("select id, symbol, chain_id, value, confirmation, create_at from confirmations where symbol = $1 and ($2 = 0 or chain_id = $2 or chain_id = 0) order by chain_id, value", req.GetSymbol(), req.GetChainId())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Confirmation
+		)
+
+		if err := rows.Scan(&item.Id, &item.Symbol, &item.ChainId, &item.Value, &item.Confirmation, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, nil
+}
+
+// SetConfirmation - This function writes a tier of the confirmation count of the currency: the deposits of at least the value of
+// the tier wait for the confirmation count of the tier instead of the confirmation count of the chain. The tier with the
+// value 0 overrides the confirmation count of the chain for every deposit of the currency, the chain id 0 applies the
+// tier to every chain of the currency.
+func (e *Service) SetConfirmation(ctx context.Context, req *admin_pbspot.SetRequestConfirmation) (*admin_pbspot.ResponseConfirmation, error) {
+
+	var (
+		response admin_pbspot.ResponseConfirmation
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if req.Confirmation.GetConfirmation() <= 0 || req.Confirmation.GetValue() < 0 {
+		return &response, status.Error(11658, "the confirmation count and the value of the tier must not be negative")
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if _, err := _provider.QueryAsset(req.Confirmation.GetSymbol(), false); err != nil {
+		return &response, status.Errorf(11659, "the currency %v is not found", req.Confirmation.GetSymbol())
+	}
+
+	if req.Confirmation.GetChainId() > 0 {
+		if _, err := _provider.QueryChain(req.Confirmation.GetChainId(), false); err != nil {
+			return &response, err
+		}
+	}
+
+	var (
+		item = req.Confirmation
+	)
+
+	if err := e.Context.Db.QueryRow("insert into confirmations (symbol, chain_id, value, confirmation) values ($1, $2, $3, $4) on conflict (symbol, chain_id, value) do update set confirmation = excluded.confirmation returning id, create_at",
+		item.GetSymbol(),
+		item.GetChainId(),
+		item.GetValue(),
+		item.GetConfirmation(),
+	).Scan(&item.Id, &item.CreateAt); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteConfirmation - This function removes a tier of the confirmation count, the deposits of the tier wait for the confirmation
+// count of the lower tier, or of the chain if there is none.
+func (e *Service) DeleteConfirmation(ctx context.Context, req *admin_pbspot.DeleteRequestConfirmation) (*admin_pbspot.ResponseConfirmation, error) {
+
+	var (
+		response admin_pbspot.ResponseConfirmation
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "chains", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _, err := e.Context.Db.Exec("delete from confirmations where id = $1", req.GetId()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetHolds - This function returns the queue of the deposits held for the review of the operators, the oldest deposits first.
+func (e *Service) GetHolds(ctx context.Context, req *admin_pbspot.GetRequestHolds) (*admin_pbspot.ResponseHold, error) {
+
+	var (
+		response admin_pbspot.ResponseHold
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow(`select count(*) as count from transactions where status = $1 and assignment = $2 and ($3 = '' or symbol = $3)`, types.StatusHold, types.AssignmentDeposit, req.GetSymbol()).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query(`select id, uid, symbol, hash, "from", "to", value, chain_id, user_id, platform, protocol, status, hold, verified, create_at from transactions where status = $1 and assignment = $2 and ($3 = '' or symbol = $3) order by id limit $4 offset $5`, types.StatusHold, types.AssignmentDeposit, req.GetSymbol(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Transaction
+			)
+
+			if err = rows.Scan(&item.Id, &item.Uid, &item.Symbol, &item.Hash, &item.From, &item.To, &item.Value, &item.ChainId, &item.UserId, &item.Platform, &item.Protocol, &item.Status, &item.Hold, &item.Verified, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// GetSources - This function returns the verified sources of the deposits, the external addresses the users proved to own by the
+// signatures, of the user or of the address, with the messages and the signatures, so that the compliance officers can
+// rely on them for the travel rule.
+func (e *Service) GetSources(ctx context.Context, req *admin_pbspot.GetRequestSources) (*admin_pbspot.ResponseSource, error) {
+
+	var (
+		response admin_pbspot.ResponseSource
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow(`select count(*) as count from sources where ($1 = 0 or user_id = $1) and ($2 = '' or lower(address) = lower($2))`, req.GetUserId(), req.GetAddress()).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query(`select id, user_id, chain_id, platform, address, signature, status, create_at from sources where ($1 = 0 or user_id = $1) and ($2 = '' or lower(address) = lower($2)) order by id desc limit $3 offset $4`, req.GetUserId(), req.GetAddress(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Source
+			)
+
+			if err = rows.Scan(&item.Id, &item.UserId, &item.ChainId, &item.Platform, &item.Address, &item.Signature, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetSource - This function revokes the verified source or restores the revoked one, the deposits from the revoked source are no
+// longer marked and are held as the other deposits, the user can not claim the address again.
+func (e *Service) SetSource(ctx context.Context, req *admin_pbspot.SetRequestSource) (*admin_pbspot.ResponseSource, error) {
+
+	var (
+		response admin_pbspot.ResponseSource
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "accounts", query.RoleDefault) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	result, err := e.Context.Db.Exec("update sources set status = $2 where id = $1", req.GetId(), req.GetStatus())
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11792, "the source %v is not found", req.GetId())
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetHold - This function puts the pending deposit on hold: the deposit keeps waiting for its confirmations, but when it is
+// confirmed it is held for the review instead of being credited. The reason of the hold is shown in the queue.
+func (e *Service) SetHold(ctx context.Context, req *admin_pbspot.SetRequestHold) (*admin_pbspot.ResponseHold, error) {
+
+	var (
+		response admin_pbspot.ResponseHold
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item, err := e.queryDeposit(req.GetId())
+	if err != nil {
+		return &response, err
+	}
+
+	if item.GetStatus() != types.StatusPending && item.GetStatus() != types.StatusHold {
+		return &response, status.Errorf(11661, "the deposit %v has the status %v, only the pending deposits can be held", item.GetId(), item.GetStatus())
+	}
+
+	if item.Hold = req.GetReason(); len(item.GetHold()) == 0 {
+		item.Hold = types.HoldManual
+	}
+
+	if _, err := e.Context.Db.Exec("update transactions set hold = $2 where id = $1", item.GetId(), item.GetHold()); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetHoldRelease - This function releases the held deposit: the value of the deposit is credited to the balance of the user and
+// the funds are added to the reserve of the deposit address, the same way the confirmed deposits are credited.
+func (e *Service) SetHoldRelease(ctx context.Context, req *admin_pbspot.SetRequestHoldRelease) (*admin_pbspot.ResponseHold, error) {
+
+	var (
+		response admin_pbspot.ResponseHold
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item, err := e.queryDeposit(req.GetId())
+	if err != nil {
+		return &response, err
+	}
+
+	// The status is changed first and only if the deposit is still held, so the deposit is never credited twice.
+	result, err := e.Context.Db.Exec("update transactions set status = $3 where id = $1 and status = $2", item.GetId(), types.StatusHold, types.StatusFilled)
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11662, "the deposit %v is not held", item.GetId())
+	}
+
+	if _, err := e.Context.Db.Exec("update balances set value = value + $1 where symbol = $2 and user_id = $3 and type = $4;", item.GetValue(), item.GetSymbol(), item.GetUserId(), types.TypeSpot); err != nil {
+		return &response, err
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if err := _provider.WriteReserve(item.GetUserId(), item.GetTo(), item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), types.BalancePlus); err != nil {
+		return &response, err
+	}
+
+	item.Hook = true
+	item.Status = types.StatusFilled
+
+	if err := e.Context.Publish(item, "exchange", "deposit/open", "deposit/status"); err != nil {
+		return &response, err
+	}
+
+	// The released deposit of a stablecoin is converted by the ramp of the user like the deposits credited on the confirmation.
+	e.Context.Debug(_provider.WriteRamp(item))
+
+	go migrate.SendMail(item.GetUserId(), "deposit_release", item.GetValue(), item.GetSymbol())
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetHoldReturn - This function returns the held deposit to its sender: the funds are added to the reserve of the deposit address
+// and a withdrawal of the value to the sender is queued, it is sent by the withdrawal replay like any other withdrawal.
+// The value is never credited to the balance of the user.
+func (e *Service) SetHoldReturn(ctx context.Context, req *admin_pbspot.SetRequestHoldReturn) (*admin_pbspot.ResponseHold, error) {
+
+	var (
+		response admin_pbspot.ResponseHold
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item, err := e.queryDeposit(req.GetId())
+	if err != nil {
+		return &response, err
+	}
+
+	if len(item.GetFrom()) == 0 {
+		return &response, status.Errorf(11663, "the sender of the deposit %v is unknown, the deposit cannot be returned", item.GetId())
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	chain, err := _provider.QueryChain(item.GetChainId(), false)
+	if err != nil {
+		return &response, err
+	}
+
+	// The status is changed first and only if the deposit is still held, so the deposit is never returned twice.
+	result, err := e.Context.Db.Exec("update transactions set status = $3 where id = $1 and status = $2", item.GetId(), types.StatusHold, types.StatusCancel)
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11662, "the deposit %v is not held", item.GetId())
+	}
+
+	if err := _provider.WriteReserve(item.GetUserId(), item.GetTo(), item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), types.BalancePlus); err != nil {
+		return &response, err
+	}
+
+	if _, err := _provider.WriteTransaction(&types.Transaction{
+		Symbol:     item.GetSymbol(),
+		Value:      item.GetValue(),
+		Fees:       chain.GetFees(),
+		To:         item.GetFrom(),
+		ChainId:    item.GetChainId(),
+		UserId:     item.GetUserId(),
+		Parent:     item.GetId(),
+		Platform:   item.GetPlatform(),
+		Protocol:   item.GetProtocol(),
+		Allocation: types.AllocationExternal,
+		Assignment: types.AssignmentWithdrawal,
+		Group:      types.GroupCrypto,
+	}); err != nil {
+		return &response, err
+	}
+
+	item.Hook = true
+	item.Status = types.StatusCancel
+
+	if err := e.Context.Publish(item, "exchange", "deposit/status"); err != nil {
+		return &response, err
+	}
+
+	go migrate.SendMail(item.GetUserId(), "deposit_return", item.GetValue(), item.GetSymbol(), item.GetFrom())
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetDistributions - This function returns the distributions of the currencies to the users with the progress of every
+// distribution, the latest distributions first.
+func (e *Service) GetDistributions(ctx context.Context, req *admin_pbspot.GetRequestDistributions) (*admin_pbspot.ResponseDistribution, error) {
+
+	var (
+		response admin_pbspot.ResponseDistribution
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow("select count(*) as count from distributions").Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, symbol, value, holder, minimum, count, processed, status, user_id, create_at from distributions order by id desc limit $1 offset $2", req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Distribution
+			)
+
+			if err = rows.Scan(&item.Id, &item.Symbol, &item.Value, &item.Holder, &item.Minimum, &item.Count, &item.Processed, &item.Status, &item.UserId, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	return &response, nil
+}
+
+// GetDistributionRecords - This function returns the records of the distribution, one record per user with the value credited
+// to the user and the status of the credit.
+func (e *Service) GetDistributionRecords(ctx context.Context, req *admin_pbspot.GetRequestDistributionRecords) (*admin_pbspot.ResponseDistributionRecord, error) {
+
+	var (
+		response admin_pbspot.ResponseDistributionRecord
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow("select count(*) as count from distribution_records where distribution_id = $1", req.GetId()).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, distribution_id, user_id, value, status, create_at from distribution_records where distribution_id = $1 order by id limit $2 offset $3", req.GetId(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.DistributionRecord
+			)
+
+			if err = rows.Scan(&item.Id, &item.DistributionId, &item.UserId, &item.Value, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	return &response, nil
+}
+
+// SetDistribution - This function creates a distribution of the currency to the users. The recipients are either read from the
+// csv file, or selected by the filter: every holder of the holder currency with at least the minimum on the spot
+// balance receives the value. The distribution is only recorded here, the balances are credited by the background
+// job of the spot service in batches, its progress is returned by GetDistributions.
+func (e *Service) SetDistribution(ctx context.Context, req *admin_pbspot.SetRequestDistribution) (*admin_pbspot.ResponseDistribution, error) {
+
+	var (
+		response admin_pbspot.ResponseDistribution
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		users  []int64
+		values []float64
+		err    error
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if req.Distribution == nil {
+		req.Distribution = &types.Distribution{}
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if _, err := _provider.QueryAsset(req.Distribution.GetSymbol(), false); err != nil {
+		return &response, status.Errorf(11659, "the currency %v is not found", req.Distribution.GetSymbol())
+	}
+
+	switch {
+	case len(req.GetCsv()) > 0:
+
+		if users, values, err = e.queryRecords(req.GetCsv(), req.Distribution.GetValue()); err != nil {
+			return &response, err
+		}
+
+		if len(users) == 0 {
+			return &response, status.Error(11666, "the csv file does not contain any recipient")
+		}
+
+		// The recipients of the csv file are distributed to, not the holders of the filter.
+		req.Distribution.Holder, req.Distribution.Minimum = "", 0
+
+	case len(req.Distribution.GetHolder()) > 0:
+
+		if _, err := _provider.QueryAsset(req.Distribution.GetHolder(), false); err != nil {
+			return &response, status.Errorf(11659, "the currency %v is not found", req.Distribution.GetHolder())
+		}
+
+		if req.Distribution.GetValue() <= 0 || req.Distribution.GetMinimum() < 0 {
+			return &response, status.Error(11665, "the value of the distribution must be greater than 0 and the minimum must not be negative")
+		}
+
+	default:
+		return &response, status.Error(11666, "the recipients of the distribution are not set, upload the csv file or set the holder currency")
+	}
+
+	if err := e.Context.Db.QueryRow("insert into distributions (symbol, value, holder, minimum, status, user_id) values ($1, $2, $3, $4, $5, $6) returning id, create_at",
+		req.Distribution.GetSymbol(),
+		req.Distribution.GetValue(),
+		req.Distribution.GetHolder(),
+		req.Distribution.GetMinimum(),
+		types.StatusPending,
+		auth,
+	).Scan(&req.Distribution.Id, &req.Distribution.CreateAt); err != nil {
+		return &response, err
+	}
+
+	// The recipients of the csv file are recorded at once, the ids of the users that do not exist are skipped, and the users
+	// listed more than once receive only the first value. The holders of the filter are recorded by the background job.
+	if len(users) > 0 {
+		if _, err := e.Context.Db.Exec(`insert into distribution_records (distribution_id, user_id, value) select $1, r.user_id, r.value from unnest($2::integer[], $3::numeric[]) with ordinality as r(user_id, value, position) inner join accounts a on a.id = r.user_id order by r.position on conflict (distribution_id, user_id) do nothing`, req.Distribution.GetId(), pq.Array(users), pq.Array(values)); err != nil {
+			return &response, err
+		}
+	}
+
+	req.Distribution.Status = types.StatusPending
+	req.Distribution.UserId = auth
+
+	response.Fields = append(response.Fields, req.Distribution)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetCompetitions - This function returns the trading competitions with their periods, pairs and prize pools, the latest
+// competitions first.
+func (e *Service) GetCompetitions(ctx context.Context, req *admin_pbspot.GetRequestCompetitions) (*admin_pbspot.ResponseCompetition, error) {
+
+	var (
+		response admin_pbspot.ResponseCompetition
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow("select count(*) as count from competitions").Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select id, title, metric, pairs, quote, symbol, prize, prizes, start_at, finish_at, status, distribution_id, coalesce(compute_at::text, ''), create_at from competitions order by id desc limit $1 offset $2", req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Competition
+			)
+
+			if err = rows.Scan(&item.Id, &item.Title, &item.Metric, pq.Array(&item.Pairs), &item.Quote, &item.Symbol, &item.Prize, pq.Array(&item.Prizes), &item.StartAt, &item.FinishAt, &item.Status, &item.DistributionId, &item.ComputeAt, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	return &response, nil
+}
+
+// SetCompetition - This function creates or updates the trading competition. The users are ranked by the volume or by the
+// profit and loss of their trades on the pairs of the competition within its period, all the pairs have to be quoted in
+// the same currency so that the results are comparable. The prizes are the shares of the prize pool in percent, the
+// first share is paid to the first place and so on. A competition can only be changed before it has started.
+func (e *Service) SetCompetition(ctx context.Context, req *admin_pbspot.SetRequestCompetition) (*admin_pbspot.ResponseCompetition, error) {
+
+	var (
+		response admin_pbspot.ResponseCompetition
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		shares float64
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	item := req.GetCompetition()
+	if item == nil {
+		return &response, status.Error(11667, "the competition is not set")
+	}
+
+	if err := types.Metric(item.GetMetric()); err != nil {
+		return &response, status.Errorf(11667, "the metric %v of the competition is not correct", item.GetMetric())
+	}
+
+	start, err := time.Parse(time.RFC3339, item.GetStartAt())
+	if err != nil {
+		return &response, status.Errorf(11667, "the start of the competition %v is not correct", item.GetStartAt())
+	}
+
+	finish, err := time.Parse(time.RFC3339, item.GetFinishAt())
+	if err != nil || !finish.After(start) {
+		return &response, status.Errorf(11667, "the finish of the competition %v must be later than its start", item.GetFinishAt())
+	}
+
+	if len(item.GetPairs()) == 0 {
+		return &response, status.Error(11668, "the competition must have at least one pair")
+	}
+
+	// The volumes and the profits are summed over the pairs in the quote currency, so the pairs must share it.
+	rows, err := e.Context.Db.Query("select distinct quote_unit from pairs where id = any($1)", pq.Array(item.GetPairs()))
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	var (
+		quotes []string
+	)
+
+	for rows.Next() {
+
+		var (
+			quote string
+		)
+
+		if err := rows.Scan(&quote); err != nil {
+			return &response, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if len(quotes) != 1 {
+		return &response, status.Error(11668, "the pairs of the competition must exist and must be quoted in the same currency")
+	}
+	item.Quote = quotes[0]
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if _, err := _provider.QueryAsset(item.GetSymbol(), false); err != nil {
+		return &response, status.Errorf(11659, "the currency %v is not found", item.GetSymbol())
+	}
+
+	for _, share := range item.GetPrizes() {
+		if share <= 0 {
+			return &response, status.Error(11669, "the prize shares must be greater than 0")
+		}
+		shares += share
+	}
+
+	if item.GetPrize() <= 0 || len(item.GetPrizes()) == 0 || shares > 100 {
+		return &response, status.Error(11669, "the prize pool must be greater than 0 and the prize shares must not exceed 100 percent in total")
+	}
+
+	if item.GetId() > 0 {
+
+		result, err := e.Context.Db.Exec("update competitions set title = $2, metric = $3, pairs = $4, quote = $5, symbol = $6, prize = $7, prizes = $8, start_at = $9, finish_at = $10 where id = $1 and status = $11",
+			item.GetId(),
+			item.GetTitle(),
+			item.GetMetric(),
+			pq.Array(item.GetPairs()),
+			item.GetQuote(),
+			item.GetSymbol(),
+			item.GetPrize(),
+			pq.Array(item.GetPrizes()),
+			start,
+			finish,
+			types.StatusPending,
+		)
+		if err != nil {
+			return &response, err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return &response, status.Errorf(11670, "the competition %v is not found or has already started", item.GetId())
+		}
+
+	} else {
+
+		if err := e.Context.Db.QueryRow("insert into competitions (title, metric, pairs, quote, symbol, prize, prizes, start_at, finish_at) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id",
+			item.GetTitle(),
+			item.GetMetric(),
+			pq.Array(item.GetPairs()),
+			item.GetQuote(),
+			item.GetSymbol(),
+			item.GetPrize(),
+			pq.Array(item.GetPrizes()),
+			start,
+			finish,
+		).Scan(&item.Id); err != nil {
+			return &response, err
+		}
+	}
+
+	item.Status = types.StatusPending
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteCompetition - This function deletes the competition that has not started yet.
+func (e *Service) DeleteCompetition(ctx context.Context, req *admin_pbspot.DeleteRequestCompetition) (*admin_pbspot.ResponseCompetition, error) {
+
+	var (
+		response admin_pbspot.ResponseCompetition
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "pairs", query.RoleMarket) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	result, err := e.Context.Db.Exec("delete from competitions where id = $1 and status = $2", req.GetId(), types.StatusPending)
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11670, "the competition %v is not found or has already started", req.GetId())
+	}
+
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetBridges - This function returns the bridges the reserves of the currencies are moved through from one platform and protocol
+// to another, all of them or the bridges of the currency.
+func (e *Service) GetBridges(ctx context.Context, req *admin_pbspot.GetRequestBridges) (*admin_pbspot.ResponseBridge, error) {
+
+	var (
+		response admin_pbspot.ResponseBridge
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	rows, err := e.Context.Db.Query("select id, name, symbol, platform, protocol, to_platform, to_protocol, address, fees, status, create_at from bridges where $1 = '' or symbol = $1 order by symbol, id", req.GetSymbol())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Bridge
+		)
+
+		if err := rows.Scan(&item.Id, &item.Name, &item.Symbol, &item.Platform, &item.Protocol, &item.ToPlatform, &item.ToProtocol, &item.Address, &item.Fees, &item.Status, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, nil
+}
+
+// SetBridge - This function creates or updates the bridge of the currency. The rebalancing proposes the moves through the bridge
+// when the reserves of the platform and the protocol the bridge leads to cannot cover the withdrawals, from the reserve of
+// the source of the bridge that covers the shortage and the fees of the bridge; the operators send the move to the address
+// of the bridge. There is one bridge per currency and route, the disabled bridge is not proposed.
+func (e *Service) SetBridge(ctx context.Context, req *admin_pbspot.SetRequestBridge) (*admin_pbspot.ResponseBridge, error) {
+
+	var (
+		response admin_pbspot.ResponseBridge
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		item = req.GetBridge()
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if item == nil || len(item.GetAddress()) == 0 || item.GetFees() < 0 {
+		return &response, status.Error(11769, "the address of the bridge is required and the fees of the bridge must not be negative")
+	}
+
+	if len(item.GetPlatform()) == 0 || len(item.GetProtocol()) == 0 || len(item.GetToPlatform()) == 0 || len(item.GetToProtocol()) == 0 || item.GetPlatform() == item.GetToPlatform() && item.GetProtocol() == item.GetToProtocol() {
+		return &response, status.Error(11770, "the bridge must move the currency from one platform or protocol to another")
+	}
+
+	// Provider is used to create a Service instance with the given context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if _, err := _provider.QueryAsset(item.GetSymbol(), false); err != nil {
+		return &response, status.Errorf(11659, "the currency %v is not found", item.GetSymbol())
+	}
+
+	if item.GetId() > 0 {
+
+		if err := e.Context.Db.QueryRow("update bridges set name = $1, symbol = $2, platform = $3, protocol = $4, to_platform = $5, to_protocol = $6, address = $7, fees = $8, status = $9 where id = $10 returning create_at",
+			item.GetName(),
+			item.GetSymbol(),
+			item.GetPlatform(),
+			item.GetProtocol(),
+			item.GetToPlatform(),
+			item.GetToProtocol(),
+			item.GetAddress(),
+			item.GetFees(),
+			item.GetStatus(),
+			item.GetId(),
+		).Scan(&item.CreateAt); err != nil {
+			return &response, err
+		}
+
+	} else {
+
+		if err := e.Context.Db.QueryRow("insert into bridges (name, symbol, platform, protocol, to_platform, to_protocol, address, fees, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) on conflict (symbol, platform, protocol, to_platform, to_protocol) do update set name = excluded.name, address = excluded.address, fees = excluded.fees, status = excluded.status returning id, create_at",
+			item.GetName(),
+			item.GetSymbol(),
+			item.GetPlatform(),
+			item.GetProtocol(),
+			item.GetToPlatform(),
+			item.GetToProtocol(),
+			item.GetAddress(),
+			item.GetFees(),
+			item.GetStatus(),
+		).Scan(&item.Id, &item.CreateAt); err != nil {
+			return &response, err
+		}
+	}
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteBridge - This function removes the bridge, the moves through it that were proposed before are kept.
+func (e *Service) DeleteBridge(ctx context.Context, req *admin_pbspot.DeleteRequestBridge) (*admin_pbspot.ResponseBridge, error) {
+
+	var (
+		response admin_pbspot.ResponseBridge
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if _, err := e.Context.Db.Exec("delete from bridges where id = $1", req.GetId()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetRebalances - This function returns the moves of the reserves of the currencies recorded by the rebalancing, all of them or the
+// moves with the status, the latest moves first. The moves in review wait for the approval of the operators.
+func (e *Service) GetRebalances(ctx context.Context, req *admin_pbspot.GetRequestRebalances) (*admin_pbspot.ResponseRebalance, error) {
+
+	var (
+		response admin_pbspot.ResponseRebalance
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	_ = e.Context.Db.QueryRow("select count(*) as count from rebalances where $1 = '' or status = $1", req.GetStatus()).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query(`select id, kind, symbol, platform, protocol, from_platform, from_protocol, chain_id, user_id, "from", "to", value, shortage, bridge_id, transaction_id, hash, error, status, update_at, create_at from rebalances where $1 = '' or status = $1 order by id desc limit $2 offset $3`, req.GetStatus(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Rebalance
+			)
+
+			if err = rows.Scan(&item.Id, &item.Kind, &item.Symbol, &item.Platform, &item.Protocol, &item.FromPlatform, &item.FromProtocol, &item.ChainId, &item.UserId, &item.From, &item.To, &item.Value, &item.Shortage, &item.BridgeId, &item.TransactionId, &item.Hash, &item.Error, &item.Status, &item.UpdateAt, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetRebalance - This function changes the status of the move of the reserves proposed by the rebalancing: the move in review is
+// approved with the pending status, the move between the exchange addresses is then sent by the rebalancing, and the
+// move through a bridge is carried out by the operators, who close it with the filled status and the hash of its
+// transfer. The moves that were not sent are cancelled with the cancel status.
+func (e *Service) SetRebalance(ctx context.Context, req *admin_pbspot.SetRequestRebalance) (*admin_pbspot.ResponseRebalance, error) {
+
+	var (
+		response admin_pbspot.ResponseRebalance
+		migrate  = query.Migrate{
+			Context: e.Context,
+		}
+		item types.Rebalance
+		from []string
+	)
+
+	auth := e.Context.User(ctx)
+
+	if !migrate.Rules(auth, "reserves", query.RoleSpot) || migrate.Rules(auth, "deny-record", query.RoleDefault) {
+		return &response, status.Error(12011, "you do not have rules for writing and editing data")
+	}
+
+	if err := e.Context.Db.QueryRow("select id, kind, status from rebalances where id = $1", req.GetId()).Scan(&item.Id, &item.Kind, &item.Status); err != nil {
+		return &response, status.Errorf(11771, "the move %v is not found", req.GetId())
+	}
+
+	switch req.GetStatus() {
+	case types.StatusPending:
+		from = []string{types.StatusReview}
+	case types.StatusCancel:
+		from = []string{types.StatusReview, types.StatusPending}
+	case types.StatusFilled:
+
+		// The moves between the exchange addresses are filled by the rebalancing, when their transfers arrive.
+		if item.GetKind() != types.RebalanceBridge || len(req.GetHash()) == 0 {
+			return &response, status.Error(11772, "only the moves through the bridges are filled by the operators, with the hash of the transfer")
+		}
+		from = []string{types.StatusReview, types.StatusPending}
+	default:
+		return &response, status.Errorf(11773, "the status %v is not one of pending, filled and cancel", req.GetStatus())
+	}
+
+	// The status is changed only from the statuses it can be changed from, so that the move that was sent is not cancelled.
+	result, err := e.Context.Db.Exec("update rebalances set status = $2, hash = case when $3 = '' then hash else $3 end, update_at = now() where id = $1 and status = any($4)", item.GetId(), req.GetStatus(), req.GetHash(), pq.Array(from))
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11774, "the move %v is %v, it cannot be changed to %v", item.GetId(), item.GetStatus(), req.GetStatus())
+	}
+
+	item.Status = req.GetStatus()
+
+	response.Fields = append(response.Fields, &item)
+	response.Success = true
+
+	return &response, nil
+}
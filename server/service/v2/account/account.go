@@ -1,28 +1,50 @@
 package account
 
 import (
+	"archive/zip"
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/envelope"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbaccount"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc/status"
 	"hash"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+var (
+	// phone and chat - The formats of the addresses of the delivery channels: the phone number in the international format
+	// and the id of the telegram chat, which is negative for the groups.
+	phone = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	chat  = regexp.MustCompile(`^-?[0-9]{1,20}$`)
+)
+
 // Service - The purpose of this code is to declare a Service struct which contains a Context pointer. The Context pointer is of
 // type assets.Context, which likely contains parameters or other values that are relevant to the Service.
 type Service struct {
 	Context *assets.Context
 }
 
+// Initialization - The code initializes a Service object and runs the concurrent functions push() and privacy().
+func (a *Service) Initialization() {
+	go a.push()
+	go a.privacy()
+}
+
 // Modify - struct is a type of struct used to store two slices of bytes. The purpose of this struct is to store data
 // related to modifications, such as sample data and rules for the modifications. This data can then be used for various
 // purposes, such as for making changes to a system or for providing input to a program.
@@ -248,7 +270,8 @@ func (a *Service) QueryUser(id int64) (*types.User, error) {
 // QueryEntropy - This function is used to retrieve the entropy (a random string of characters) associated with a specific user account
 // from a database. It takes in a user ID as an argument and returns the associated entropy and an error if one occurs.
 // It first queries the database to check if the user ID and status (true) match an account in the database. If it does,
-// it returns the associated entropy. Otherwise, it returns an error.
+// it decrypts the sealed entropy with the master key and returns it. It is the only place the entropy is decrypted, it
+// is used only to derive the keys of the wallets of the user.
 func (a *Service) QueryEntropy(userId int64) (entropy []byte, err error) {
 
 	// This code is attempting to retrieve a value from the database. The specific value is entropy from a row in the
@@ -258,5 +281,292 @@ func (a *Service) QueryEntropy(userId int64) (entropy []byte, err error) {
 		return entropy, err
 	}
 
-	return entropy, nil
+	return a.Context.OpenEntropy(entropy)
+}
+
+// RotateEntropy - This function seals the entropies of the accounts that are still written in the clear with the primary master
+// key, and rewraps the data keys of the entropies sealed with the other master keys with the primary one, the encrypted
+// entropies themselves are not changed. It returns the number of the accounts that were updated, it is safe to run it again
+// after an interruption.
+func (a *Service) RotateEntropy() (count int, err error) {
+
+	if a.Context.Entropy == nil || len(a.Context.Entropy.Primary) == 0 {
+		return count, errors.New("the primary master key of the entropy is not configured")
+	}
+
+	keys, err := a.Context.Keyring()
+	if err != nil {
+		return count, err
+	}
+
+	rows, err := a.Context.Db.Query("select id, entropy from accounts where entropy is not null order by id")
+	if err != nil {
+		return count, err
+	}
+
+	var (
+		records = make(map[int64][]byte)
+	)
+
+	for rows.Next() {
+
+		var (
+			id      int64
+			entropy []byte
+		)
+
+		if err := rows.Scan(&id, &entropy); err != nil {
+			_ = rows.Close()
+			return count, err
+		}
+
+		records[id] = entropy
+	}
+	_ = rows.Close()
+
+	for id, entropy := range records {
+
+		var (
+			sealed []byte
+		)
+
+		switch {
+		case !envelope.Sealed(entropy):
+			sealed, err = a.Context.SealEntropy(entropy)
+		case envelope.Id(entropy) != a.Context.Entropy.Primary:
+			sealed, err = envelope.Rewrap(keys, a.Context.Entropy.Primary, entropy)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return count, fmt.Errorf("account %v: %v", id, err)
+		}
+
+		// The entropy is only replaced if it was not changed since it was read, so a concurrent run does not overwrite it.
+		result, err := a.Context.Db.Exec("update accounts set entropy = $1 where id = $2 and entropy = $3", sealed, id, entropy)
+		if err != nil {
+			return count, err
+		}
+
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// newKey - This function returns a new api key: 32 random bytes of the cryptographic generator, hex encoded.
+func (a *Service) newKey() (string, error) {
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+// queryClosure - This function checks that the account of the user can be closed: the balances are empty, no funds are held,
+// no orders or futures positions are open and no transactions or transfers are waiting to be completed. The account with
+// the funds or the obligations of the exchange can not be anonymized, the reason is returned in the error.
+func (a *Service) queryClosure(userId int64) error {
+
+	var (
+		reason string
+	)
+
+	if err := a.Context.Db.QueryRow(`select case
+			when exists(select 1 from balances where user_id = $1 and value > 0) then 'the balances are not empty'
+			when exists(select 1 from holds where user_id = $1 and value > 0) then 'the funds are held'
+			when exists(select 1 from orders where user_id = $1 and status = any($2)) then 'the orders are open'
+			when exists(select 1 from futures where user_id = $1 and status = any($2)) then 'the futures positions are open'
+			when exists(select 1 from transactions where user_id = $1 and status = any($3)) then 'the transactions are not completed'
+			when exists(select 1 from transfer where user_id = $1 and status = any($3)) then 'the transfers are not completed'
+			else '' end`,
+		userId,
+		pq.Array([]string{types.StatusPending, types.StatusQueue}),
+		pq.Array([]string{types.StatusPending, types.StatusProcessing, types.StatusReserve, types.StatusHold, types.StatusReview}),
+	).Scan(&reason); err != nil {
+		return err
+	}
+
+	if len(reason) > 0 {
+		return status.Errorf(11745, "the account can not be closed, %v", reason)
+	}
+
+	return nil
+}
+
+// writeExport - This function writes the archive of the personal data of the user for the export request: every file of the
+// archive is a json array of the records of the user, the account with its delivery channels, the sign-in actions, the
+// balances, the orders, the trades, the transactions and the other records. The archive is written to the storage outside
+// of the static files, so that it is available only by the request of its owner, and its path is returned.
+func (a *Service) writeExport(id, userId int64) (string, error) {
+
+	var (
+		sources = []struct {
+			name, query string
+		}{
+			{name: "account", query: "select id, name, email, phone, telegram, channels, factor_secure, status, create_at from accounts where id = $1"},
+			{name: "kyc", query: "select secure, level from kyc where user_id = $1"},
+			{name: "actions", query: "select id, os, device, ip, browser, country, risk, create_at from actions where user_id = $1 order by id"},
+			{name: "api_keys", query: "select id, name, status, create_at from api_keys where user_id = $1 order by id"},
+			{name: "balances", query: "select symbol, value, type from balances where user_id = $1 order by id"},
+			{name: "wallets", query: "select address, platform from wallets where user_id = $1 order by id"},
+			{name: "orders", query: "select id, assigning, price, value, quantity, base_unit, quote_unit, type, trading, status, create_at from orders where user_id = $1 order by id"},
+			{name: "trades", query: "select id, order_id, base_unit, quote_unit, price, quantity, assigning, fees, maker, create_at from trades where user_id = $1 order by id"},
+			{name: "futures", query: "select id, assigning, position, trading, base_unit, quote_unit, price, quantity, leverage, fees, status, create_at from futures where user_id = $1 order by id"},
+			{name: "transactions", query: `select id, symbol, hash, value, fees, "to", chain_id, assignment, platform, protocol, status, create_at from transactions where user_id = $1 order by id`},
+			{name: "transfers", query: "select id, quantity, name, symbol, status, create_at from transfer where user_id = $1 order by id"},
+			{name: "watches", query: "select id, chain_id, address, platform, label, symbol, create_at from watches where user_id = $1 order by id"},
+			{name: "deliveries", query: "select id, name, channel, status, error, create_at from deliveries where user_id = $1 order by id"},
+			{name: "tickets", query: "select id, category, subject, status, close_at, create_at from tickets where user_id = $1 order by id"},
+			{name: "ticket_messages", query: "select m.id, m.ticket_id, m.staff, m.text, m.create_at from ticket_messages m inner join tickets t on t.id = m.ticket_id where t.user_id = $1 order by m.id"},
+		}
+		storage = filepath.Join(a.Context.StoragePath, "exports")
+		path    = filepath.Join(storage, fmt.Sprintf("%v.zip", id))
+	)
+
+	if err := os.MkdirAll(storage, 0700); err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+
+	for _, source := range sources {
+
+		var (
+			data []byte
+		)
+
+		// The records are serialized by the database itself, so that every file keeps the columns of its table as they are.
+		if err := a.Context.Db.QueryRow(fmt.Sprintf("select coalesce(json_agg(t), '[]'::json) from (%v) t", source.query), userId).Scan(&data); err != nil {
+			return "", err
+		}
+
+		writer, err := archive.Create(fmt.Sprintf("%v.json", source.name))
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			return "", err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// writeClosure - This function closes the account of the user: the personal data of the account is anonymized, the email is
+// replaced by an address that can not receive mail, the password, the second factor and the delivery channels are
+// removed, and the records that exist only for the user, such as the sign-in actions, the api keys and the watched
+// addresses, are deleted. The financial records, the orders, the trades, the transactions, the balances and the kyc
+// level, are retained for the obligations of the exchange, as is the entropy the deposit addresses are derived from.
+func (a *Service) writeClosure(userId int64) error {
+
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`update accounts set name = '', email = 'deleted-' || id || '@closed.invalid', email_code = '', password = null, sample = '[]', rules = '{}', factor_secure = false, factor_secret = '', phone = '', telegram = '', channels = $2, status = false where id = $1`, userId, pq.Array([]string{types.ChannelEmail})); err != nil {
+		return err
+	}
+
+	for _, statement := range []string{
+		"delete from actions where user_id = $1",
+		"delete from api_keys where user_id = $1",
+		"delete from pushes where user_id = $1",
+		"delete from deliveries where user_id = $1",
+		"delete from watch_transactions where user_id = $1",
+		"delete from watches where user_id = $1",
+		"delete from announcement_dismissals where user_id = $1",
+		"delete from tickets where user_id = $1",
+		"update copies set status = false where user_id = $1 or lead_id = $1",
+	} {
+		if _, err := tx.Exec(statement, userId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// QueryPrivacy - This function returns the privacy requests selected by the query, the query selects the columns of the
+// requests in the order of the fields of the types.PrivacyRequest, with the email of the user, which is empty when it is
+// not joined. The time of the processing is empty while the request is not processed.
+func (a *Service) QueryPrivacy(query string, args ...interface{}) (fields []*types.PrivacyRequest, err error) {
+
+	rows, err := a.Context.Db.Query(query, args...)
+	if err != nil {
+		return fields, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item      types.PrivacyRequest
+			processAt sql.NullString
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.Email, &item.Kind, &item.Status, &item.Reason, &item.ExecuteAt, &processAt, &item.CreateAt); err != nil {
+			return fields, err
+		}
+		item.ProcessAt = processAt.String
+
+		fields = append(fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fields, err
+	}
+
+	return fields, nil
+}
+
+// QueryRestrictions - This function returns the restrictions of the accounts selected by the query, the query selects the columns
+// of the restrictions in the order of the fields of the types.Restriction. The time of the expiry is empty for the
+// restrictions that last until they are lifted, and the time of the lifting is empty while they are not lifted.
+func (a *Service) QueryRestrictions(query string, args ...interface{}) (fields []*types.Restriction, err error) {
+
+	rows, err := a.Context.Db.Query(query, args...)
+	if err != nil {
+		return fields, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item             types.Restriction
+			expireAt, liftAt sql.NullString
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.Kind, &item.Reason, &item.Note, &item.OperatorId, &item.Status, &expireAt, &liftAt, &item.CreateAt); err != nil {
+			return fields, err
+		}
+		item.ExpireAt, item.LiftAt = expireAt.String, liftAt.String
+
+		fields = append(fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fields, err
+	}
+
+	return fields, nil
 }
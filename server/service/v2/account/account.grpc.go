@@ -2,11 +2,21 @@ package account
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/broker"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbaccount"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
 	"github.com/pquerna/otp/totp"
 	"google.golang.org/grpc/status"
+	"os"
+	"strings"
+	"time"
 )
 
 // SetUser - This function is used to set a user's information manually. It takes in a context and a request containing the user's
@@ -22,12 +32,7 @@ func (a *Service) SetUser(ctx context.Context, req *pbaccount.SetRequestUser) (*
 		response pbaccount.ResponseUser
 	)
 
-	// This code is authenticating a user in order to access a resource. The 'a.Context.Auth(ctx)' function is used to
-	// authenticate the user, and if there is an error, the return an error response.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This if statement is used to check if the length of the request's sample is greater than 0. If it is, it will attempt
 	// to set the sample with the given authentication, and if an error occurs, it will return an error response.
@@ -76,13 +81,7 @@ func (a *Service) GetUser(ctx context.Context, _ *pbaccount.GetRequestUser) (*pb
 		err      error
 	)
 
-	// This code is used to authenticate a user within a given context (ctx). It creates a variable called auth which stores
-	// the authentication information, and an err variable which stores any errors that occur. If an error occurs, the error
-	// is returned and the function is exited.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This code is used to query a user based on an authentication parameter, and return the response to the user. If an
 	// error occurs while querying the user, the code returns an error response to the user.
@@ -118,13 +117,7 @@ func (a *Service) GetActions(ctx context.Context, req *pbaccount.GetRequestActio
 		browser  []byte
 	)
 
-	// This code is authenticating a user who is trying to access a resource. The variable 'auth' will contain the
-	// authentication information for the user, and the variable 'err' will contain any error that occurs during the
-	// authentication process. If an error occurs, the code will return an error response.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This code is used to query the database and check the number of actions associated with a given user. The QueryRow
 	// function runs a query and scans the results into the variable "response.Count". If an error occurs, the code returns
@@ -143,7 +136,7 @@ func (a *Service) GetActions(ctx context.Context, req *pbaccount.GetRequestActio
 		// This code is used to query a database for data. The query is executed with the given parameters (auth,
 		// req.GetLimit(), offset). If the query is successful, the rows are returned and stored for further use. If the query
 		// fails, an error is returned and the code exits. The rows.Close() function is used to close the connection to the database.
-		rows, err := a.Context.Db.Query("select id, os, device, ip, browser, create_at from actions where user_id = $1 order by id desc limit $2 offset $3", auth, req.GetLimit(), offset)
+		rows, err := a.Context.Db.Query("select id, os, device, ip, browser, create_at, country, risk from actions where user_id = $1 order by id desc limit $2 offset $3", auth, req.GetLimit(), offset)
 		if err != nil {
 			return &response, err
 		}
@@ -163,7 +156,7 @@ func (a *Service) GetActions(ctx context.Context, req *pbaccount.GetRequestActio
 			// This code is used to scan the rows of a database query and store their values into the variables item.Id, item.Os,
 			// item.Device, item.Ip, browser, and item.CreateAt. If the scan is successful, the function will return &response,
 			// otherwise it will return an error.
-			if err := rows.Scan(&item.Id, &item.Os, &item.Device, &item.Ip, &browser, &item.CreateAt); err != nil {
+			if err := rows.Scan(&item.Id, &item.Os, &item.Device, &item.Ip, &browser, &item.CreateAt, &item.Country, &item.Risk); err != nil {
 				return &response, err
 			}
 
@@ -188,6 +181,51 @@ func (a *Service) GetActions(ctx context.Context, req *pbaccount.GetRequestActio
 	return &response, nil
 }
 
+// GetPushHistory - This function returns the events pushed to the private channel of the user, starting after the given event id in
+// the order they were published. A client that was offline passes the id of the last event it has received, and gets
+// all the order, deposit and other events it has missed, within the retention period of the history.
+func (a *Service) GetPushHistory(ctx context.Context, req *pbaccount.GetRequestPushHistory) (*pbaccount.ResponsePushHistory, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponsePushHistory.
+	var (
+		response pbaccount.ResponsePushHistory
+	)
+
+	auth := a.Context.User(ctx)
+
+	// This code sets a sensible default limit on the number of events, the client requests the next page by the id of the last event.
+	if req.GetLimit() == 0 || req.GetLimit() > 500 {
+		req.Limit = 500
+	}
+
+	// This query selects the events of the user published after the given event, in the order they were published. The
+	// events can also be filtered by the channel, for example "order/status" or "deposit/open".
+	rows, err := a.Context.Db.Query("select id, channel, data, create_at from pushes where user_id = $1 and id > $2 and ($4 = '' or channel = $4) order by id limit $3", auth, req.GetAfterId(), req.GetLimit(), req.GetChannel())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item pbaccount.Push
+		)
+
+		if err := rows.Scan(&item.Id, &item.Channel, &item.Data, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
 // SetFactor - This function is used to set a user's secure factor. It takes in a context and request object and verifies the code
 // provided in the request. If the code is valid, it sets the secure factor to either true or false and updates the
 // user's secret if necessary. It then returns a response and any errors that might have occurred.
@@ -202,12 +240,7 @@ func (a *Service) SetFactor(ctx context.Context, req *pbaccount.SetRequestFactor
 		secure   bool
 	)
 
-	// This code is used to authenticate a user. The variable 'auth' stores the authentication information, and the 'err'
-	// variable stores any potential errors that might occur during authentication. If an error occurs, the code returns an error response.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This code attempts to query the user using the authentication (auth) provided. If there is an error during the
 	// querying process, the return statement will pass back an error to the calling code and the Context.Error() method
@@ -262,12 +295,7 @@ func (a *Service) GetFactor(ctx context.Context, _ *pbaccount.GetRequestFactor)
 		err      error
 	)
 
-	// This code checks for a valid authentication and if there is an error with the authentication it will return an error
-	// response. It is used to ensure that only authorized users can access the requested resource.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
 	// This code is used to query a user by authentication. The variable "user" is assigned the result of the query, and if
 	// there is an error, the "err" variable will be assigned the error and the function will return a response and the error.
@@ -304,3 +332,530 @@ func (a *Service) GetFactor(ctx context.Context, _ *pbaccount.GetRequestFactor)
 
 	return &response, nil
 }
+
+// SetApiKey - This function creates an api key of the user, the programs of the user authenticate their requests by the key in
+// the api-key header instead of the bearer token. The key is returned only once, it is stored as its hash. The requests of
+// the key are signed with its signing secret, which is returned with the key once and is never sent with the requests.
+func (a *Service) SetApiKey(ctx context.Context, req *pbaccount.SetRequestApiKey) (*pbaccount.ResponseApiKey, error) {
+
+	var (
+		response pbaccount.ResponseApiKey
+		item     pbaccount.ApiKey
+		count    int
+	)
+
+	auth := a.Context.User(ctx)
+
+	// The keys are managed with the bearer tokens only, a leaked key can not create more keys.
+	if a.Context.Key(ctx) > 0 {
+		return &response, status.Error(11725, "the api keys are managed with the bearer token only")
+	}
+
+	if len(req.GetName()) > 64 {
+		return &response, status.Error(11726, "the name of the api key is longer than 64 characters")
+	}
+
+	if _ = a.Context.Db.QueryRow("select count(*) from api_keys where user_id = $1 and status = true", auth).Scan(&count); count >= 10 {
+		return &response, status.Error(11727, "the user can have at most 10 api keys")
+	}
+
+	key, err := a.newKey()
+	if err != nil {
+		return &response, err
+	}
+
+	signing, err := a.newKey()
+	if err != nil {
+		return &response, err
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	if err := a.Context.Db.QueryRow("insert into api_keys (user_id, name, secret, signing) values ($1, $2, $3, $4) returning id, name, create_at", auth, req.GetName(), hex.EncodeToString(hash[:]), signing).Scan(&item.Id, &item.Name, &item.CreateAt); err != nil {
+		return &response, err
+	}
+	item.Key, item.SigningSecret = key, signing
+
+	response.Fields = append(response.Fields, &item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetApiKeys - This function returns the active api keys of the user, without the keys themselves.
+func (a *Service) GetApiKeys(ctx context.Context, _ *pbaccount.GetRequestApiKeys) (*pbaccount.ResponseApiKey, error) {
+
+	var (
+		response pbaccount.ResponseApiKey
+	)
+
+	auth := a.Context.User(ctx)
+
+	rows, err := a.Context.Db.Query("select id, name, create_at from api_keys where user_id = $1 and status = true order by id", auth)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item pbaccount.ApiKey
+		)
+
+		if err := rows.Scan(&item.Id, &item.Name, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// DeleteApiKey - This function revokes the api key of the user, the requests authenticated by the key are rejected from then on.
+func (a *Service) DeleteApiKey(ctx context.Context, req *pbaccount.DeleteRequestApiKey) (*pbaccount.ResponseApiKey, error) {
+
+	var (
+		response pbaccount.ResponseApiKey
+	)
+
+	auth := a.Context.User(ctx)
+
+	if a.Context.Key(ctx) > 0 {
+		return &response, status.Error(11725, "the api keys are managed with the bearer token only")
+	}
+
+	result, err := a.Context.Db.Exec("update api_keys set status = false where id = $1 and user_id = $2 and status = true", req.GetId(), auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11728, "the api key %v is not found", req.GetId())
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetChannels - This function returns the delivery channels of the security codes and the alerts of the user: the phone number,
+// the id of the telegram chat and the order the channels are tried in.
+func (a *Service) GetChannels(ctx context.Context, _ *pbaccount.GetRequestChannels) (*pbaccount.ResponseChannel, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseChannel.
+	var (
+		response pbaccount.ResponseChannel
+	)
+
+	auth := a.Context.User(ctx)
+
+	if err := a.Context.Db.QueryRow("select phone, telegram, channels from accounts where id = $1", auth).Scan(&response.Phone, &response.Telegram, pq.Array(&response.Channels)); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// SetChannels - This function sets the delivery channels of the security codes and the alerts of the user. The channels are
+// tried in the given order and the email after them, a channel can be chosen only with its address. Since the channels
+// receive the security codes, the change is confirmed by the security code, which is sent by the current channels of
+// the user on the refresh request.
+func (a *Service) SetChannels(ctx context.Context, req *pbaccount.SetRequestChannels) (*pbaccount.ResponseChannel, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseChannel.
+	var (
+		response pbaccount.ResponseChannel
+	)
+
+	auth := a.Context.User(ctx)
+
+	// The refresh request sends the security code the change is confirmed with.
+	if req.GetRefresh() {
+
+		if err := a.WriteSecure(ctx, false); err != nil {
+			return &response, err
+		}
+
+		return &response, nil
+	}
+
+	if len(req.GetPhone()) > 0 && !phone.MatchString(req.GetPhone()) {
+		return &response, status.Errorf(11741, "the phone number %v must be in the international format, such as +12025550123", req.GetPhone())
+	}
+
+	if len(req.GetTelegram()) > 0 && !chat.MatchString(req.GetTelegram()) {
+		return &response, status.Errorf(11742, "the telegram chat %v is not valid", req.GetTelegram())
+	}
+
+	// This code checks the channels, every channel is chosen once and only with its address.
+	for i, channel := range req.GetChannels() {
+
+		if err := types.Channel(channel); err != nil {
+			return &response, err
+		}
+
+		for _, previous := range req.GetChannels()[:i] {
+			if previous == channel {
+				return &response, status.Errorf(11743, "the channel %v is chosen twice", channel)
+			}
+		}
+
+		if (channel == types.ChannelSms && len(req.GetPhone()) == 0) || (channel == types.ChannelTelegram && len(req.GetTelegram()) == 0) {
+			return &response, status.Errorf(11744, "the channel %v is chosen without its address", channel)
+		}
+	}
+
+	secure, err := a.QuerySecure(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	// This if statement is used to check if the security code provided by the user matches the security code associated
+	// with the user's account. If the code is incorrect or empty, an error is returned.
+	if secure != req.GetEmailCode() || secure == "" {
+		return &response, status.Errorf(58990, "security code %v is incorrect", req.GetEmailCode())
+	}
+
+	if len(req.GetChannels()) == 0 {
+		req.Channels = []string{types.ChannelEmail}
+	}
+
+	if _, err := a.Context.Db.Exec("update accounts set phone = $2, telegram = $3, channels = $4 where id = $1", auth, req.GetPhone(), req.GetTelegram(), pq.Array(req.GetChannels())); err != nil {
+		return &response, err
+	}
+
+	// The security code is used once, it is removed after the change.
+	if err := a.WriteSecure(ctx, true); err != nil {
+		return &response, err
+	}
+
+	response.Phone, response.Telegram, response.Channels = req.GetPhone(), req.GetTelegram(), req.GetChannels()
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetLocale - This function returns the locale the messages of the backend are returned to the user in, chosen in the settings
+// of the account, and the locales the user can choose from. The empty locale is not chosen, the messages are returned in
+// the locale preferred by the Accept-Language header of the request.
+func (a *Service) GetLocale(ctx context.Context, _ *pbaccount.GetRequestLocale) (*pbaccount.ResponseLocale, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseLocale.
+	var (
+		response pbaccount.ResponseLocale
+	)
+
+	auth := a.Context.User(ctx)
+
+	if err := a.Context.Db.QueryRow("select locale from accounts where id = $1", auth).Scan(&response.Locale); err != nil {
+		return &response, err
+	}
+	response.Locales = a.Context.Locales.Locales()
+
+	return &response, nil
+}
+
+// SetLocale - This function sets the locale the messages of the backend are returned to the user in, the error messages, the
+// notifications and the subjects of the emails. The empty locale clears the choice, the messages are returned in the
+// locale preferred by the Accept-Language header of the request again.
+func (a *Service) SetLocale(ctx context.Context, req *pbaccount.SetRequestLocale) (*pbaccount.ResponseLocale, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseLocale.
+	var (
+		response pbaccount.ResponseLocale
+	)
+
+	auth := a.Context.User(ctx)
+
+	locale := a.Context.Locales.Supported(req.GetLocale())
+	if len(req.GetLocale()) > 0 && len(locale) == 0 {
+		return &response, status.Errorf(11763, "the locale %v is not supported, the supported locales are %v", req.GetLocale(), strings.Join(a.Context.Locales.Locales(), ", "))
+	}
+
+	if _, err := a.Context.Db.Exec("update accounts set locale = $2 where id = $1", auth, locale); err != nil {
+		return &response, err
+	}
+
+	// The cached locale of the account is removed, the next request is served in the new locale.
+	if err := a.Context.RedisClient.Del(context.Background(), assets.LocaleKey(auth)).Err(); err != nil {
+		return &response, err
+	}
+
+	response.Locale, response.Locales = locale, a.Context.Locales.Locales()
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetGrant - This function issues the grant the user subscribes to the topics of the broker with from the websocket bridge, the
+// public topic of the exchange and the topic of the user. The broker asks the backend whether the grant is valid, and the
+// grant is good for the lifetime of the configuration, the client asks for a new one before it expires.
+func (a *Service) GetGrant(ctx context.Context, _ *pbaccount.GetRequestGrant) (*pbaccount.ResponseGrant, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseGrant.
+	var (
+		response pbaccount.ResponseGrant
+	)
+
+	auth := a.Context.User(ctx)
+
+	if len(a.Context.Rabbitmq.Secret) == 0 {
+		return &response, status.Error(11766, "the grants of the broker are not issued, the secret of the broker is not configured")
+	}
+
+	grant := broker.Sign(a.Context.Rabbitmq.Secret, "exchange", auth, time.Duration(a.Context.Rabbitmq.Lifetime)*time.Minute, time.Now())
+	response.Username, response.Password, response.Topics, response.ExpireAt = grant.Username, grant.Password, grant.Topics, grant.ExpireAt
+
+	return &response, nil
+}
+
+// GetDeliveries - This function returns the attempts to deliver the security codes and the alerts to the user, the latest attempts
+// first, with the channel and the status of every attempt and the reason of the failed ones.
+func (a *Service) GetDeliveries(ctx context.Context, req *pbaccount.GetRequestDeliveries) (*pbaccount.ResponseDelivery, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseDelivery.
+	var (
+		response pbaccount.ResponseDelivery
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	if _ = a.Context.Db.QueryRow("select count(*) as count from deliveries where user_id = $1", auth).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := a.Context.Db.Query("select id, name, channel, status, error, create_at from deliveries where user_id = $1 order by id desc limit $2 offset $3", auth, req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Delivery
+			)
+
+			if err := rows.Scan(&item.Id, &item.Name, &item.Channel, &item.Status, &item.Error, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// GetPrivacyRequests - This function returns the requests of the user for the export of the personal data and for the closure
+// of the account, the latest requests first.
+func (a *Service) GetPrivacyRequests(ctx context.Context, req *pbaccount.GetRequestPrivacyRequests) (*pbaccount.ResponsePrivacy, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponsePrivacy.
+	var (
+		response pbaccount.ResponsePrivacy
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	if _ = a.Context.Db.QueryRow("select count(*) as count from privacy_requests where user_id = $1", auth).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		fields, err := a.QueryPrivacy("select id, user_id, '', kind, status, reason, execute_at, process_at, create_at from privacy_requests where user_id = $1 order by id desc limit $2 offset $3", auth, req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = fields
+	}
+
+	return &response, nil
+}
+
+// SetExport - This function requests the export of the personal data of the user. The archive is prepared in the background, the
+// user is notified when it is ready and downloads it by the id of the request. Only one export is prepared at a time.
+func (a *Service) SetExport(ctx context.Context, _ *pbaccount.SetRequestExport) (*pbaccount.ResponsePrivacy, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponsePrivacy.
+	var (
+		response pbaccount.ResponsePrivacy
+		exist    bool
+	)
+
+	auth, err := a.Context.Auth(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	if _ = a.Context.Db.QueryRow("select exists(select 1 from privacy_requests where user_id = $1 and kind = $2 and status = $3)", auth, types.PrivacyExport, types.StatusPending).Scan(&exist); exist {
+		return &response, status.Error(11746, "the export of your data is already being prepared")
+	}
+
+	if response.Fields, err = a.QueryPrivacy("insert into privacy_requests (user_id, kind) values ($1, $2) returning id, user_id, '', kind, status, reason, execute_at, process_at, create_at", auth, types.PrivacyExport); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetExport - This function returns the archive of the personal data of the user prepared for the export request, the archive is
+// available until it expires.
+func (a *Service) GetExport(ctx context.Context, req *pbaccount.GetRequestExport) (*pbaccount.ResponseExport, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseExport.
+	var (
+		response pbaccount.ResponseExport
+		path     string
+	)
+
+	auth := a.Context.User(ctx)
+
+	if err := a.Context.Db.QueryRow("select path from privacy_requests where id = $1 and user_id = $2 and kind = $3 and status = $4", req.GetId(), auth, types.PrivacyExport, types.StatusFilled).Scan(&path); err != nil || len(path) == 0 {
+		return &response, status.Errorf(11747, "the export %v is not ready or has expired", req.GetId())
+	}
+
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		return &response, err
+	}
+	response.Name, response.Archive = fmt.Sprintf("export-%v.zip", req.GetId()), archive
+
+	return &response, nil
+}
+
+// SetClosure - This function requests the closure of the account of the user. The account can be closed only when it holds no
+// funds and has no open orders and no transactions in progress, and the request is confirmed by the security code, which
+// is sent on the refresh request. The account is anonymized after the cooling-off period, the user can cancel the
+// closure until then.
+func (a *Service) SetClosure(ctx context.Context, req *pbaccount.SetRequestClosure) (*pbaccount.ResponsePrivacy, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponsePrivacy.
+	var (
+		response pbaccount.ResponsePrivacy
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		exist bool
+	)
+
+	auth, err := a.Context.Auth(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	if _ = a.Context.Db.QueryRow("select exists(select 1 from privacy_requests where user_id = $1 and kind = $2 and status = $3)", auth, types.PrivacyClosure, types.StatusPending).Scan(&exist); exist {
+		return &response, status.Error(11746, "the closure of your account is already scheduled")
+	}
+
+	if err := a.queryClosure(auth); err != nil {
+		return &response, err
+	}
+
+	// The refresh request sends the security code the closure is confirmed with.
+	if req.GetRefresh() {
+
+		if err := a.WriteSecure(ctx, false); err != nil {
+			return &response, err
+		}
+
+		return &response, nil
+	}
+
+	secure, err := a.QuerySecure(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	// This if statement is used to check if the security code provided by the user matches the security code associated
+	// with the user's account. If the code is incorrect or empty, an error is returned.
+	if secure != req.GetEmailCode() || secure == "" {
+		return &response, status.Errorf(58990, "security code %v is incorrect", req.GetEmailCode())
+	}
+
+	// The purpose of this code is to bring the cooling-off period into a sensible value, the closure is never executed at once.
+	cooling := a.Context.ClosureCooling
+	if cooling <= 0 {
+		cooling = 14
+	}
+
+	if response.Fields, err = a.QueryPrivacy("insert into privacy_requests (user_id, kind, execute_at) values ($1, $2, now() + make_interval(days => $3)) returning id, user_id, '', kind, status, reason, execute_at, process_at, create_at", auth, types.PrivacyClosure, cooling); err != nil {
+		return &response, err
+	}
+
+	// The security code is used once, it is removed after the request.
+	if err := a.WriteSecure(ctx, true); err != nil {
+		return &response, err
+	}
+
+	go migrate.SendMail(auth, "closure_scheduled", response.Fields[0].GetExecuteAt())
+	response.Success = true
+
+	return &response, nil
+}
+
+// CancelClosure - This function cancels the closure of the account of the user scheduled within the cooling-off period.
+func (a *Service) CancelClosure(ctx context.Context, _ *pbaccount.CancelRequestClosure) (*pbaccount.ResponsePrivacy, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponsePrivacy.
+	var (
+		response pbaccount.ResponsePrivacy
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if response.Fields, err = a.QueryPrivacy("update privacy_requests set status = $3, reason = $4, process_at = now() where user_id = $1 and kind = $2 and status = $5 returning id, user_id, '', kind, status, reason, execute_at, process_at, create_at", auth, types.PrivacyClosure, types.StatusCancel, "cancelled by the user", types.StatusPending); err != nil {
+		return &response, err
+	}
+
+	if len(response.GetFields()) == 0 {
+		return &response, status.Error(11748, "the closure of your account is not scheduled")
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetRestrictions - This function returns the active restrictions of the account of the user with their reason codes and the time
+// they expire at, the notes of the compliance officers are not shown to the user.
+func (a *Service) GetRestrictions(ctx context.Context, _ *pbaccount.GetRequestRestrictions) (*pbaccount.ResponseRestriction, error) {
+
+	// The purpose of this code is to declare the response variable of type pbaccount.ResponseRestriction.
+	var (
+		response pbaccount.ResponseRestriction
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if response.Fields, err = a.QueryRestrictions("select id, user_id, kind, reason, '', 0, status, expire_at, lift_at, create_at from restrictions where user_id = $1 and status = true and (expire_at is null or expire_at > now()) order by id desc", auth); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,123 @@
+package account
+
+import (
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+	"os"
+	"time"
+)
+
+// push - This function removes the events of the push history that are older than the retention period at a specific time
+// interval. The retention period is taken from the configuration in days, a week is used if it is not set.
+func (a *Service) push() {
+
+	// The code creates a ticker that triggers every hour and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Hour * 1)
+	for range ticker.C {
+
+		// The purpose of this code is to bring the retention period into a sensible value, the history is never kept for less than a day.
+		retention := a.Context.PushRetention
+		if retention <= 0 {
+			retention = 7
+		}
+
+		// This code removes the events of all the users that were published before the retention period.
+		if _, err := a.Context.Db.Exec("delete from pushes where create_at < now()::timestamp - make_interval(days => $1)", retention); a.Context.Debug(err) {
+			continue
+		}
+	}
+}
+
+// privacy - This function processes the privacy requests of the users at a specific time interval. The archives of the pending
+// exports are written and the users are notified, the archives older than the retention period are removed. The closures
+// whose cooling-off period is over are executed, unless the account holds funds or obligations again, in which case the
+// closure fails with the reason and the user is notified.
+func (a *Service) privacy() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		var (
+			migrate = query.Migrate{
+				Context: a.Context,
+			}
+		)
+
+		// The purpose of this code is to bring the retention period into a sensible value, the archives are never kept for less than a day.
+		retention := a.Context.ExportRetention
+		if retention <= 0 {
+			retention = 7
+		}
+
+		exports, err := a.QueryPrivacy("select id, user_id, '', kind, status, reason, execute_at, process_at, create_at from privacy_requests where kind = $1 and status = $2 order by id", types.PrivacyExport, types.StatusPending)
+		if a.Context.Debug(err) {
+			continue
+		}
+
+		for _, item := range exports {
+
+			path, err := a.writeExport(item.GetId(), item.GetUserId())
+			if err != nil {
+				a.Context.Debug(err)
+				_, err = a.Context.Db.Exec("update privacy_requests set status = $2, reason = $3, process_at = now() where id = $1", item.GetId(), types.StatusFailed, err.Error())
+				a.Context.Debug(err)
+				continue
+			}
+
+			if _, err := a.Context.Db.Exec("update privacy_requests set status = $2, path = $3, process_at = now() where id = $1", item.GetId(), types.StatusFilled, path); a.Context.Debug(err) {
+				continue
+			}
+
+			go migrate.SendMail(item.GetUserId(), "export_ready", retention)
+		}
+
+		// This code removes the archives of the exports processed before the retention period, the requests themselves are kept.
+		rows, err := a.Context.Db.Query("update privacy_requests set path = '' where kind = $1 and path != '' and process_at < now() - make_interval(days => $2) returning path", types.PrivacyExport, retention)
+		if a.Context.Debug(err) {
+			continue
+		}
+
+		for rows.Next() {
+
+			var (
+				path string
+			)
+
+			if err := rows.Scan(&path); a.Context.Debug(err) {
+				continue
+			}
+
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				a.Context.Debug(err)
+			}
+		}
+		rows.Close()
+
+		closures, err := a.QueryPrivacy("select id, user_id, '', kind, status, reason, execute_at, process_at, create_at from privacy_requests where kind = $1 and status = $2 and execute_at <= now() order by id", types.PrivacyClosure, types.StatusPending)
+		if a.Context.Debug(err) {
+			continue
+		}
+
+		for _, item := range closures {
+
+			// The account is checked again, since the user could receive a deposit or place an order within the cooling-off period.
+			if err := a.queryClosure(item.GetUserId()); err != nil {
+				if _, err := a.Context.Db.Exec("update privacy_requests set status = $2, reason = $3, process_at = now() where id = $1", item.GetId(), types.StatusFailed, status.Convert(err).Message()); a.Context.Debug(err) {
+					continue
+				}
+				go migrate.SendMail(item.GetUserId(), "closure_cancel", status.Convert(err).Message())
+				continue
+			}
+
+			if err := a.writeClosure(item.GetUserId()); a.Context.Debug(err) {
+				continue
+			}
+
+			if _, err := a.Context.Db.Exec("update privacy_requests set status = $2, process_at = now() where id = $1", item.GetId(), types.StatusFilled); a.Context.Debug(err) {
+				continue
+			}
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package ads
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
 )
 
 // Service - The type Service struct is used to store a pointer to an assets.Context object. This type is used to provide access to
@@ -9,3 +14,66 @@ import (
 type Service struct {
 	Context *assets.Context
 }
+
+// Initialization - The code initializes a Service object and runs the concurrent function announce().
+func (s *Service) Initialization() {
+	go s.announce()
+}
+
+// announce - This function pushes the announcements over the broker when they start, every announcement is pushed once, and again
+// when the operators change it while it is shown. The announcements of all the audiences but the listed users are pushed
+// to everybody, the clients request the announcements targeted at their user again when they receive one. The
+// announcements of the listed users are pushed to every user of the list, so that they are kept in their push history.
+func (s *Service) announce() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		var (
+			items []*types.Announcement
+		)
+
+		// The announcements are marked as published by the same statement that selects them, so that an announcement is not
+		// pushed twice by the instances of the service running at the same time.
+		rows, err := s.Context.Db.Query(`update announcements set published = $1 where status = $1 and published = $2 and start_at <= now() and (end_at is null or end_at > now()) returning id, kind, title, text, link, audience, users, start_at, end_at`, true, false)
+		if s.Context.Debug(err) {
+			continue
+		}
+
+		for rows.Next() {
+
+			var (
+				item types.Announcement
+				end  sql.NullTime
+				from time.Time
+			)
+
+			if err := rows.Scan(&item.Id, &item.Kind, &item.Title, &item.Text, &item.Link, &item.Audience, pq.Array(&item.Users), &from, &end); s.Context.Debug(err) {
+				continue
+			}
+
+			item.Status = true
+			item.StartAt = from.UTC().Format(time.RFC3339)
+			if end.Valid {
+				item.EndAt = end.Time.UTC().Format(time.RFC3339)
+			}
+
+			items = append(items, &item)
+		}
+		_ = rows.Close()
+
+		for _, item := range items {
+
+			if item.GetAudience() != types.AudienceUsers {
+				s.Context.Debug(s.Context.Publish(item, "exchange", "announcement/open"))
+				continue
+			}
+
+			// The copy of the announcement carries the user it is addressed to, the list of the users is not published.
+			for _, user := range item.GetUsers() {
+				s.Context.Debug(s.Context.Publish(&types.Announcement{Id: item.GetId(), Kind: item.GetKind(), Title: item.GetTitle(), Text: item.GetText(), Link: item.GetLink(), Audience: item.GetAudience(), StartAt: item.GetStartAt(), EndAt: item.GetEndAt(), Status: true, UserId: user}, "exchange", "announcement/open"))
+			}
+		}
+	}
+}
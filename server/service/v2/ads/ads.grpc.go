@@ -2,9 +2,12 @@ package ads
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbads"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+	"time"
 )
 
 // GetAdvertisements - This function is used to retrieve a list of advertisements from a database based on the specified parameters. It will
@@ -50,7 +53,7 @@ func (s *Service) GetAdvertisements(_ context.Context, req *pbads.GetRequestAdve
 		// request parameters (req) passed to it. The query is then executed using the Db.Query method, and the results are
 		// returned in the form of a rows object. The rows.Close() method is used to close the rows object, and is important in
 		// order to ensure all resources associated with the operation are properly released.
-		rows, err := s.Context.Db.Query(fmt.Sprintf("select id, title, text, link, pattern from advertising where pattern = '%v' order by %v desc limit %d offset %d", req.GetPattern(), by, req.GetLimit(), offset))
+		rows, err := s.Context.Db.Query(fmt.Sprintf("select id, title, text, link, pattern from advertising where pattern = $1 order by %v desc limit %d offset %d", by, req.GetLimit(), offset), req.GetPattern())
 		if err != nil {
 			return &response, err
 		}
@@ -119,3 +122,98 @@ func (s *Service) GetAdvertising(_ context.Context, req *pbads.GetRequestAdverti
 
 	return &response, nil
 }
+
+// GetAnnouncements - This function returns the announcements shown to the user right now: the announcements that have started and
+// have not ended yet, that are targeted at the audience of the user and that the user has not dismissed. The visitors
+// without an account receive the announcements of all the visitors only. The announcements can be filtered by their kind.
+func (s *Service) GetAnnouncements(ctx context.Context, req *pbads.GetRequestAnnouncements) (*pbads.ResponseAnnouncement, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbads.ResponseAnnouncement
+	)
+
+	if len(req.GetKind()) > 0 {
+		if err := types.AnnouncementKind(req.GetKind()); err != nil {
+			return &response, err
+		}
+	}
+
+	// The user of the request is optional, the announcements of the audiences other than all the visitors are selected only
+	// for the users that are signed in.
+	auth := s.Context.User(ctx)
+
+	rows, err := s.Context.Db.Query(`select a.id, a.kind, a.title, a.text, a.link, a.audience, a.start_at, a.end_at from announcements a where a.status = $1 and a.start_at <= now() and (a.end_at is null or a.end_at > now()) and ($2 = '' or a.kind = $2) and not exists(select 1 from announcement_dismissals d where d.announcement_id = a.id and d.user_id = $3) and (a.audience = $4 or ($3 > 0 and ((a.audience = $5 and exists(select 1 from kyc k where k.user_id = $3 and k.secure)) or (a.audience = $6 and not exists(select 1 from kyc k where k.user_id = $3 and k.secure)) or (a.audience = $7 and exists(select 1 from accounts c where c.id = $3 and c.priority)) or (a.audience = $8 and $3 = any(a.users))))) order by a.start_at desc`,
+		true,
+		req.GetKind(),
+		auth,
+		types.AudienceAll,
+		types.AudienceVerified,
+		types.AudienceUnverified,
+		types.AudiencePriority,
+		types.AudienceUsers,
+	)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Announcement
+			from time.Time
+			end  sql.NullTime
+		)
+
+		if err := rows.Scan(&item.Id, &item.Kind, &item.Title, &item.Text, &item.Link, &item.Audience, &from, &end); err != nil {
+			return &response, err
+		}
+
+		item.Status = true
+		item.StartAt = from.UTC().Format(time.RFC3339)
+		if end.Valid {
+			item.EndAt = end.Time.UTC().Format(time.RFC3339)
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// DismissAnnouncement - This function hides the announcement from the user, the dismissed announcement is not returned to the user
+// anymore, the other users still see it. Dismissing the same announcement again has no effect.
+func (s *Service) DismissAnnouncement(ctx context.Context, req *pbads.DismissRequestAnnouncement) (*pbads.ResponseAnnouncement, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbads.ResponseAnnouncement
+	)
+
+	auth := s.Context.User(ctx)
+
+	result, err := s.Context.Db.Exec("insert into announcement_dismissals (announcement_id, user_id) select id, $2 from announcements where id = $1 on conflict do nothing", req.GetId(), auth)
+	if err != nil {
+		return &response, err
+	}
+
+	// The announcement that does not exist is reported, the dismissal that was already recorded is not.
+	if affected, _ := result.RowsAffected(); affected == 0 {
+
+		var (
+			exist bool
+		)
+
+		if _ = s.Context.Db.QueryRow("select exists(select id from announcements where id = $1)", req.GetId()).Scan(&exist); !exist {
+			return &response, status.Errorf(11736, "the announcement %v was not found", req.GetId())
+		}
+	}
+	response.Success = true
+
+	return &response, nil
+}
@@ -1,14 +1,23 @@
 package auth
 
 import (
+	"fmt"
 	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/geoip"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"github.com/cryptogateway/backend-envoys/assets/common/siwe"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbauth"
 	"github.com/golang-jwt/jwt/v4"
 	uuid "github.com/satori/go.uuid"
 	"github.com/vmihailenco/msgpack/v5"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"strings"
 	"time"
 )
 
@@ -18,12 +27,16 @@ type Service struct {
 	Context *assets.Context
 }
 
-// ReplayToken - This function is used to create a new token and refresh token with a given subject ID. It first creates a new signing
-// object with a HS256 signing method. Then it sets the claims such as "sub", "exp", and "iat" with the given subject,
-// the expiration time 15 minutes from now, and the current time respectively. It then creates an access token and a
-// refresh token, and creates a session object with the access token and the subject. It then marshals the session object
-// and deletes any old refresh token with the same value. Finally, it sets the new refresh token with a 24-hour expiration time in the Redis client and returns the response.
-func (a *Service) ReplayToken(subject int64) (*pbauth.Response, error) {
+// ReplayToken - This function is used to create a new token and refresh token with a given subject ID for the session family. The
+// empty family starts a new one, which is the case of the sign in, the rotation of the refresh token continues the family
+// of the used token. It creates a new signing object with a HS256 signing method and sets the claims such as "sub", "sid",
+// "jti", "exp" and "iat" with the given subject, the family, the id of the token, the expiration time of the access token
+// and the current time respectively. It then creates a session object with the access token, the subject and the family,
+// stores it under the refresh token for the lifetime of the refresh token, and remembers the refresh token as the current
+// one of the family and the family as one of the sessions of the user, so that they can be revoked. The session signed in
+// with the Ethereum wallet carries the address of the wallet in the "wal" claim and in the session, the family is also
+// remembered as one of the sessions of the wallet, so that the sessions end when the wallet is unlinked.
+func (a *Service) ReplayToken(subject int64, family, wallet string) (*pbauth.Response, error) {
 
 	// The two variables, response and session, are both declared as types of pbauth.Response and pbauth.Response_Session,
 	// respectively. The purpose of this declaration is to create two variables that will be used to store data related to
@@ -33,17 +46,26 @@ func (a *Service) ReplayToken(subject int64) (*pbauth.Response, error) {
 		session  pbauth.Response_Session
 	)
 
+	if len(family) == 0 {
+		family = uuid.NewV4().String()
+	}
+
 	// The purpose of signing := jwt.New(jwt.SigningMethodHS256) is to create a new JWT object with the signing method HS256
 	// and assign it to the variable signing. HS256 is a secure hashing algorithm used for encrypting data.
 	signing := jwt.New(jwt.SigningMethodHS256)
 
-	// This code is setting up the JWT claims when creating a JWT token. The "sub" claim is the subject of the token, "exp"
-	// is the expiration time, and "iat" is the issued at time. This code is setting the expiration time to 15 minutes from
-	// the current time and the issued at time to the current time.
+	// This code is setting up the JWT claims when creating a JWT token. The "sub" claim is the subject of the token, "sid" is
+	// the session family the token is revoked with, "jti" is the id of the token, "exp" is the expiration time, and "iat" is
+	// the issued at time.
 	claims := signing.Claims.(jwt.MapClaims)
 	claims["sub"] = subject
-	claims["exp"] = time.Now().Add(15 * time.Minute).Unix()
+	claims["sid"] = family
+	claims["jti"] = uuid.NewV4().String()
+	claims["exp"] = time.Now().Add(assets.AccessLifetime).Unix()
 	claims["iat"] = time.Now().Unix()
+	if len(wallet) > 0 {
+		claims["wal"] = wallet
+	}
 
 	// This code is attempting to sign a string using the secret stored in the Context.Secrets[0] array. The access variable
 	// will store the signed string, and the if statement will return an error if the signing fails.
@@ -56,9 +78,11 @@ func (a *Service) ReplayToken(subject int64) (*pbauth.Response, error) {
 	// authenticate a user, while the RefreshToken is used to generate a new AccessToken when it expires.
 	response.AccessToken, response.RefreshToken = access, uuid.NewV4().String()
 
-	// The purpose of this code is to assign the value of the Access Token and Subject returned from the
-	// response.GetAccessToken() method to the session.AccessToken and session.Subject variables, respectively.
-	session.AccessToken, session.Subject = response.GetAccessToken(), subject
+	// The signing secret of the family is returned with the tokens, the client signs the requests of the session with it.
+	response.SigningSecret = a.Context.SigningSecret(family)
+
+	// The purpose of this code is to assign the access token, the subject and the family to the session stored under the refresh token.
+	session.AccessToken, session.Subject, session.Family, session.Wallet = response.GetAccessToken(), subject, family, wallet
 
 	// The purpose of this code is to Marshal the 'session' variable into the MessagePack format. If there is an error
 	// during the process, it will return an error as well as a response.
@@ -67,22 +91,174 @@ func (a *Service) ReplayToken(subject int64) (*pbauth.Response, error) {
 		return &response, err
 	}
 
-	// The purpose of this statement is to delete the refresh token stored in Redis. This statement is using the
-	// Context.RedisClient.Del() function to delete the refresh token based on the context and the response received.
-	a.Context.RedisClient.Del(context.Background(), response.GetRefreshToken())
-
 	// This code is checking for an error when setting a refresh token in a Redis database. If an error occurs, it is
 	// returned along with the response.
-	if err = a.Context.RedisClient.Set(context.Background(), response.GetRefreshToken(), marshal, 24*time.Hour).Err(); err != nil {
+	if err = a.Context.RedisClient.Set(context.Background(), response.GetRefreshToken(), marshal, assets.RefreshLifetime).Err(); err != nil {
 		return &response, err
 	}
 
-	return &response, err
+	// The refresh token becomes the current one of the family, and the family one of the sessions of the user.
+	if err = a.Context.RedisClient.Set(context.Background(), fmt.Sprintf("session:family:%v", family), response.GetRefreshToken(), assets.RefreshLifetime).Err(); err != nil {
+		return &response, err
+	}
+
+	if err = a.Context.RedisClient.SAdd(context.Background(), fmt.Sprintf("session:user:%v", subject), family).Err(); err != nil {
+		return &response, err
+	}
+
+	if err = a.Context.RedisClient.Expire(context.Background(), fmt.Sprintf("session:user:%v", subject), assets.RefreshLifetime).Err(); err != nil {
+		return &response, err
+	}
+
+	if len(wallet) > 0 {
+
+		if err = a.Context.RedisClient.SAdd(context.Background(), fmt.Sprintf("session:wallet:%v", strings.ToLower(wallet)), family).Err(); err != nil {
+			return &response, err
+		}
+
+		if err = a.Context.RedisClient.Expire(context.Background(), fmt.Sprintf("session:wallet:%v", strings.ToLower(wallet)), assets.RefreshLifetime).Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// writeRevoke - This function revokes the session family of the user: the revocation is written to the list the access tokens
+// are checked against, and the current refresh token of the family is removed, so that the session can not be refreshed.
+func (a *Service) writeRevoke(subject int64, family string) error {
+
+	if err := a.Context.RedisClient.Set(context.Background(), assets.RevokeKey(family), subject, assets.RefreshLifetime).Err(); err != nil {
+		return err
+	}
+
+	if current, err := a.Context.RedisClient.Get(context.Background(), fmt.Sprintf("session:family:%v", family)).Result(); err == nil {
+		a.Context.RedisClient.Del(context.Background(), current)
+	}
+
+	if err := a.Context.RedisClient.Del(context.Background(), fmt.Sprintf("session:family:%v", family)).Err(); err != nil {
+		return err
+	}
+
+	if err := a.Context.RedisClient.SRem(context.Background(), fmt.Sprintf("session:user:%v", subject), family).Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeNonce - This function issues the nonce of the message of the sign in with Ethereum, the nonce is kept for ten minutes and
+// is accepted once, so that a signed message can not be replayed.
+func (a *Service) writeNonce() (string, error) {
+
+	if a.Context.Siwe == nil {
+		return "", status.Error(11780, "the sign in with ethereum is not available")
+	}
+
+	nonce, err := siwe.Nonce()
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.Context.RedisClient.Set(context.Background(), fmt.Sprintf("siwe:nonce:%v", nonce), true, 10*time.Minute).Err(); err != nil {
+		return "", err
+	}
+
+	return nonce, nil
+}
+
+// queryMessage - This function returns the message of the sign in with Ethereum signed by the wallet. The message is parsed and
+// checked to be signed for one of the domains and the chains of the configuration at the current time, the address
+// recovered from the signature has to be the address of the message, and the nonce of the message is consumed, the
+// nonce that was not issued by the server or was already used is refused.
+func (a *Service) queryMessage(message, signature string) (*siwe.Message, error) {
+
+	if a.Context.Siwe == nil {
+		return nil, status.Error(11780, "the sign in with ethereum is not available")
+	}
+
+	m, err := siwe.Parse(message)
+	if err != nil {
+		return nil, status.Error(11781, err.Error())
+	}
+
+	if err := m.Verify(a.Context.Siwe.Domains, time.Now()); err != nil {
+		return nil, status.Error(11782, err.Error())
+	}
+
+	if len(a.Context.Siwe.Chains) > 0 {
+
+		allowed := false
+		for _, chain := range a.Context.Siwe.Chains {
+			if chain == m.ChainID {
+				allowed = true
+			}
+		}
+
+		if !allowed {
+			return nil, status.Errorf(11782, "the chain %v of the message is not allowed", m.ChainID)
+		}
+	}
+
+	if address, err := siwe.Recover(message, signature); err != nil || address != m.Address {
+		return nil, status.Error(11783, "the signature does not match the address of the message")
+	}
+
+	if count, err := a.Context.RedisClient.Del(context.Background(), fmt.Sprintf("siwe:nonce:%v", m.Nonce)).Result(); err != nil || count == 0 {
+		return nil, status.Error(11784, "the nonce of the message is unknown or was already used, request a new nonce")
+	}
+
+	return m, nil
+}
+
+// writeWallet - This function links the wallet of the message to the account, the wallet linked to the account already is left
+// as it is, and the wallet linked to another account is refused.
+func (a *Service) writeWallet(userId int64, m *siwe.Message) error {
+
+	var (
+		owner int64
+	)
+
+	if _, err := a.Context.Db.Exec("insert into account_wallets (user_id, address, chain_id) values ($1, $2, $3) on conflict do nothing", userId, m.Address, m.ChainID); err != nil {
+		return err
+	}
+
+	if err := a.Context.Db.QueryRow("select user_id from account_wallets where lower(address) = lower($1)", m.Address).Scan(&owner); err != nil {
+		return err
+	}
+
+	if owner != userId {
+		return status.Error(11785, "the wallet is linked to another account")
+	}
+
+	return a.Context.RedisClient.Set(context.Background(), assets.WalletKey(m.Address), userId, assets.RefreshLifetime).Err()
+}
+
+// writeUnlink - This function ends the sessions signed in with the wallet that was unlinked from the account of the user.
+func (a *Service) writeUnlink(subject int64, address string) error {
+
+	if err := a.Context.RedisClient.Del(context.Background(), assets.WalletKey(address)).Err(); err != nil {
+		return err
+	}
+
+	families, err := a.Context.RedisClient.SMembers(context.Background(), fmt.Sprintf("session:wallet:%v", strings.ToLower(address))).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		if err := a.writeRevoke(subject, family); err != nil {
+			return err
+		}
+	}
+
+	return a.Context.RedisClient.Del(context.Background(), fmt.Sprintf("session:wallet:%v", strings.ToLower(address))).Err()
 }
 
 // writeCode - This function sets a 6-character code for a given email address and sends an email containing that code for
-// verification. The GO statement at the end allows the code to be sent asynchronously.
-func (a *Service) writeCode(email string) (code interface{}, err error) {
+// verification. The name is the template of the email, the code is the first of its parameters and the given parameters
+// follow it. The GO statement at the end allows the code to be sent asynchronously.
+func (a *Service) writeCode(email, name string, params ...interface{}) (code interface{}, err error) {
 
 	// The purpose of the code is to initialize two variables: migrate and q. The first variable, migrate, is set to an
 	// instance of the Migrate type from the query package with the context set to the value of the a.Context variable. The
@@ -108,7 +284,167 @@ func (a *Service) writeCode(email string) (code interface{}, err error) {
 	// The purpose of this code is to email the user with a secure code for migration. The code takes three
 	// arguments, the first is the user's ID, the second is a string "secure" and the third is a code. The code then uses
 	// these arguments to email the user with the secure code for migration.
-	go migrate.SendMail(q.Id, "secure", code)
+	go migrate.SendMail(q.Id, name, append([]interface{}{code}, params...)...)
 
 	return code, err
 }
+
+// Risk - The Risk struct is the risk the sign in is scored with: the location of the address the sign in comes from, the score
+// and the reasons of it. The sign in scored at least the step-up score is verified by the code sent for it with the
+// location, and by the second factor of the users who enabled it.
+type Risk struct {
+	Location geoip.Location
+	Score    int
+	Reasons  []string
+}
+
+// StepUp - This function reports whether the sign in requires the step-up verification.
+func (r *Risk) StepUp() bool {
+	return r.Score >= 50
+}
+
+// queryAddress - This function returns the address of the client, the address forwarded by the gateway is preferred to the address
+// of the peer, since the requests of the http clients come to the server from the gateway.
+func (a *Service) queryAddress(ctx context.Context) string {
+
+	if meta, ok := metadata.FromIncomingContext(ctx); ok {
+		if forwarded := meta.Get("x-forwarded-for"); len(forwarded) > 0 {
+			if ip := strings.TrimSpace(strings.Split(forwarded[0], ",")[0]); len(ip) > 0 {
+				return ip
+			}
+		}
+	}
+
+	// This code is attempting to obtain the IP address of a peer from a given context. The first "if" statement checks
+	// if the peer is available in the context, and if so, assigns it to the variable "mp". The second "if" statement
+	// checks if the address of the peer is a TCP address, and if so, returns its IP address, otherwise the full address.
+	if mp, ok := peer.FromContext(ctx); ok {
+		if tcpAddr, ok := mp.Addr.(*net.TCPAddr); ok {
+			return tcpAddr.IP.String()
+		}
+		return mp.Addr.String()
+	}
+
+	return ""
+}
+
+// queryLockout - This function checks whether the sign in of the email or from the address is locked after too many failed
+// attempts, the error tells the user how long the lock lasts.
+func (a *Service) queryLockout(email, ip string) error {
+
+	for _, key := range []string{fmt.Sprintf("signin:lock:%v", strings.ToLower(email)), fmt.Sprintf("signin:lock:ip:%v", ip)} {
+		if ttl, err := a.Context.RedisClient.TTL(context.Background(), key).Result(); err == nil && ttl > 0 {
+			return status.Errorf(11750, "too many failed attempts, the sign in is locked for %v minutes", int(math.Ceil(ttl.Minutes())))
+		}
+	}
+
+	return nil
+}
+
+// writeFailure - This function counts the failed attempt of the sign in of the email from the address, the counters expire after
+// the window, and the email or the address that reaches the limit of the attempts is locked for the duration. The user
+// is notified of the lock of the email, since it means the password or the codes of the account are being guessed.
+func (a *Service) writeFailure(email, ip string) {
+
+	var (
+		lockout = assets.Lockout{Attempts: 5, Window: 15, Duration: 30}
+		migrate = query.Migrate{
+			Context: a.Context,
+		}
+		userId int64
+	)
+
+	// The purpose of this code is to bring the protection into sensible values, the values not set are taken by default.
+	if a.Context.Lockout != nil {
+		if a.Context.Lockout.Attempts > 0 {
+			lockout.Attempts = a.Context.Lockout.Attempts
+		}
+		if a.Context.Lockout.Window > 0 {
+			lockout.Window = a.Context.Lockout.Window
+		}
+		if a.Context.Lockout.Duration > 0 {
+			lockout.Duration = a.Context.Lockout.Duration
+		}
+	}
+
+	for _, counter := range []struct {
+		name     string
+		attempts int
+	}{
+		{name: strings.ToLower(email), attempts: lockout.Attempts},
+		{name: fmt.Sprintf("ip:%v", ip), attempts: lockout.Attempts * 5},
+	} {
+
+		key := fmt.Sprintf("signin:failed:%v", counter.name)
+
+		count, err := a.Context.RedisClient.Incr(context.Background(), key).Result()
+		if a.Context.Debug(err) {
+			continue
+		}
+
+		if count == 1 {
+			a.Context.Debug(a.Context.RedisClient.Expire(context.Background(), key, time.Duration(lockout.Window)*time.Minute).Err())
+		}
+
+		if count < int64(counter.attempts) {
+			continue
+		}
+
+		a.Context.Debug(a.Context.RedisClient.Set(context.Background(), fmt.Sprintf("signin:lock:%v", counter.name), count, time.Duration(lockout.Duration)*time.Minute).Err())
+		a.Context.Debug(a.Context.RedisClient.Del(context.Background(), key).Err())
+
+		if counter.name == strings.ToLower(email) {
+			if err := a.Context.Db.QueryRow("select id from accounts where email = $1", email).Scan(&userId); err == nil {
+				go migrate.SendMail(userId, "signin_lock", lockout.Duration, ip)
+			}
+		}
+	}
+}
+
+// clearFailure - This function removes the failed attempts of the email after the successful sign in.
+func (a *Service) clearFailure(email string) {
+	a.Context.Debug(a.Context.RedisClient.Del(context.Background(), fmt.Sprintf("signin:failed:%v", strings.ToLower(email))).Err())
+}
+
+// queryRisk - This function scores the risk of the sign in of the user from the address. The address is located and compared with
+// the previous sign ins of the user: the country the user never signed in from and the location the user could not have
+// traveled to since the last sign in raise the score, as do the recent failed attempts of the email. The sign in is not
+// located without the geolocation service, and a failure of the service leaves the location unknown.
+func (a *Service) queryRisk(userId int64, email, ip string) (risk Risk) {
+
+	var (
+		last     geoip.Location
+		createAt time.Time
+		known    bool
+		failed   int64
+	)
+
+	if a.Context.Geo != nil && len(a.Context.Geo.Endpoint) > 0 {
+		if location, err := a.Context.Geo.Locate(ip); !a.Context.Debug(err) {
+			risk.Location = location
+		}
+	}
+
+	if len(risk.Location.Country) > 0 {
+
+		// The first located sign in of the user has nothing to be compared with, it is not scored.
+		if err := a.Context.Db.QueryRow("select country, latitude, longitude, create_at from actions where user_id = $1 and country != '' order by id desc limit 1", userId).Scan(&last.Country, &last.Latitude, &last.Longitude, &createAt); err == nil {
+
+			if _ = a.Context.Db.QueryRow("select exists(select 1 from actions where user_id = $1 and country = $2)", userId, risk.Location.Country).Scan(&known); !known {
+				risk.Score += 50
+				risk.Reasons = append(risk.Reasons, fmt.Sprintf("a new country %v", risk.Location.Country))
+			}
+
+			if geoip.Impossible(last, risk.Location, time.Since(createAt)) {
+				risk.Score += 50
+				risk.Reasons = append(risk.Reasons, fmt.Sprintf("%v km away from the previous sign in %v ago", int(geoip.Distance(last, risk.Location)), time.Since(createAt).Round(time.Minute)))
+			}
+		}
+	}
+
+	if failed, _ = a.Context.RedisClient.Get(context.Background(), fmt.Sprintf("signin:failed:%v", strings.ToLower(email))).Int64(); failed > 0 {
+		risk.Score += int(math.Min(float64(failed)*10, 30))
+	}
+
+	return risk
+}
@@ -3,21 +3,23 @@ package auth
 import (
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbauth"
+	"github.com/cryptogateway/backend-envoys/server/types"
 	"github.com/pquerna/otp/totp"
 	"github.com/tyler-smith/go-bip39"
 	"github.com/vmihailenco/msgpack/v5"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
-	"net"
 	"net/mail"
 	"strings"
+	"time"
 )
 
 // ActionSignup - This function is a signup action for a service. It receives a context, a request and returns a response and an error.
@@ -46,6 +48,11 @@ func (a *Service) ActionSignup(ctx context.Context, req *pbauth.Request) (*pbaut
 	switch req.GetSignup() {
 	case pbauth.Signup_ActionSignupAccount:
 
+		// The registrations of the new accounts can be suspended by the operators.
+		if err := a.Context.Feature(types.FeatureRegistration); err != nil {
+			return &response, err
+		}
+
 		// This code is checking to make sure that the length of the name sent in the request (req.GetName()) is at least 5
 		// characters long. If the name is not at least 5 characters long, then it will return an error with status code 19522
 		// and a message saying "the name must be at least 5 characters long".
@@ -91,6 +98,13 @@ func (a *Service) ActionSignup(ctx context.Context, req *pbauth.Request) (*pbaut
 				return &response, err
 			}
 
+			// The entropy is sealed with a data key wrapped by the master key before it is written, it is decrypted only when
+			// the keys of the wallets of the user are derived.
+			entropy, err = a.Context.SealEntropy(entropy)
+			if err != nil {
+				return &response, err
+			}
+
 			// This code is used to check if a user has already been registered with the same email address. If the user has
 			// already been registered, an error is returned. Otherwise, the user is registered by inserting the name, email,
 			// password and entropy into the accounts table.
@@ -107,7 +121,7 @@ func (a *Service) ActionSignup(ctx context.Context, req *pbauth.Request) (*pbaut
 
 		// This code is used to set a code for the given email address. If there is an error while setting the code, the error
 		// is returned and the process is stopped.
-		code, err := a.writeCode(req.GetEmail())
+		code, err := a.writeCode(req.GetEmail(), "secure")
 		if err != nil {
 			return &response, err
 		}
@@ -188,47 +202,77 @@ func (a *Service) ActionSignin(ctx context.Context, req *pbauth.Request) (*pbaut
 	hashed := sha256.New()
 	hashed.Write([]byte(fmt.Sprintf("%v-%v", req.GetPassword(), a.Context.Secrets[0])))
 
+	// The address of the client the failed attempts are counted by and the sign in is located with.
+	ip := a.queryAddress(ctx)
+
 	// This code snippet is part of a function that is checking for a sign in. The switch statement is used to check the
 	// value of the "req.GetSignin()" request. Depending on the value, the code block following the switch statement will be
 	// executed. This allows the function to act differently based on the value of the "req.GetSignin()" request.
 	switch req.GetSignin() {
 	case pbauth.Signin_ActionSigninAccount:
 
-		// This code is used to query a database for a given email and password. The row variable is used to hold the results
-		// of the query. To err variable is used to check for any errors that occur when executing the query. If an error
-		// occurs, the function returns an error response. To defer row.Close() statement is used to close the database
-		// connection after the query has been executed.
-		row, err := a.Context.Db.Query("select id from accounts where email = $1 and password = $2", req.GetEmail(), base64.URLEncoding.EncodeToString(hashed.Sum(nil)))
-		if err != nil {
+		var (
+			params struct {
+				id     int64
+				secure bool
+			}
+		)
+
+		// The sign in of the email or from the address locked after too many failed attempts is refused before the password is checked.
+		if err := a.queryLockout(req.GetEmail(), ip); err != nil {
 			return &response, err
 		}
-		defer row.Close()
 
-		// This if statement checks to see if the row in the database has the next value. If it does not, then it will return a
-		// response with an error code of 48512 and a corresponding error message that states "the email address or password
-		// was entered incorrectly". This is useful in cases where the user has entered incorrect credentials and the
-		// application needs to inform them of that fact.
-		if !row.Next() {
+		// This code is used to query a database for a given email and password. If no account matches them, the failed attempt
+		// is counted and a response with an error code of 48512 is returned, which states "the email address or password was
+		// entered incorrectly". This is useful in cases where the user has entered incorrect credentials.
+		if err := a.Context.Db.QueryRow("select id, factor_secure from accounts where email = $1 and password = $2", req.GetEmail(), base64.URLEncoding.EncodeToString(hashed.Sum(nil))).Scan(&params.id, &params.secure); err != nil {
+			a.writeFailure(req.GetEmail(), ip)
 			return &response, status.Error(48512, "the email address or password was entered incorrectly")
 		}
 
+		// The client is told whether the sign in requires the step-up verification and the second factor, so that it asks the
+		// user for them before the code is requested.
+		risk := a.queryRisk(params.id, req.GetEmail(), ip)
+		response.StepUp, response.Country, response.FactorSecure = risk.StepUp(), risk.Location.Country, params.secure
+
 		break
 	case pbauth.Signin_ActionSigninCode:
 
+		var (
+			userId int64
+		)
+
+		if err := a.queryLockout(req.GetEmail(), ip); err != nil {
+			return &response, err
+		}
+
+		// The code is sent only to the account whose password is given, so that the codes can not be requested for any email.
+		if err := a.Context.Db.QueryRow("select id from accounts where email = $1 and password = $2", req.GetEmail(), base64.URLEncoding.EncodeToString(hashed.Sum(nil))).Scan(&userId); err != nil {
+			a.writeFailure(req.GetEmail(), ip)
+			return &response, status.Error(48512, "the email address or password was entered incorrectly")
+		}
+
+		// The code of the risky sign in is sent with the location and the reasons of the risk, so that the user confirms the sign
+		// in knowing where it comes from. The step-up code is marked for the confirmation, which requires it from the same risk.
+		name, params, risk := "secure", []interface{}{}, a.queryRisk(userId, req.GetEmail(), ip)
+		if risk.StepUp() {
+			name, params = "signin_location", []interface{}{strings.Join(risk.Reasons, ", ")}
+		}
+
 		// This code is setting a code for a given request. The "setCode" function is called with the email from the request,
 		// and if an error is returned, the error is handled and the response is returned.
-		code, err := a.writeCode(req.GetEmail())
+		code, err := a.writeCode(req.GetEmail(), name, params...)
 		if err != nil {
 			return &response, err
 		}
 
-		// This code is part of a function that is updating an account in a database. The code is specifically updating the
-		// email_code field in the accounts table of the database, where the email and password match the supplied parameters.
-		// The code is also using $3 to set the email_code field to the value of code. This could be used to verify a user's
-		// email address or to reset a user's password.
-		if _, err = a.Context.Db.Exec("update accounts set email_code = $3 where email = $1 and password = $2;", req.GetEmail(), base64.URLEncoding.EncodeToString(hashed.Sum(nil)), code); err != nil {
-			return &response, err
+		if risk.StepUp() {
+			if err := a.Context.RedisClient.Set(context.Background(), fmt.Sprintf("signin:step:%v", userId), code, 15*time.Minute).Err(); err != nil {
+				return &response, err
+			}
 		}
+		response.StepUp, response.Country = risk.StepUp(), risk.Location.Country
 
 		break
 	case pbauth.Signin_ActionSigninConfirm:
@@ -240,6 +284,10 @@ func (a *Service) ActionSignin(ctx context.Context, req *pbauth.Request) (*pbaut
 			return &response, status.Error(14773, "the email code must be 6 numbers")
 		}
 
+		if err := a.queryLockout(req.GetEmail(), ip); err != nil {
+			return &response, err
+		}
+
 		// This code is querying an account table in a database. It is attempting to find an account with the given email,
 		// email code, and a hashed password. The row variable holds the result of the query. If an error occurs it will return
 		// an error message. The defer statement will close the row when the function returns.
@@ -259,9 +307,9 @@ func (a *Service) ActionSignin(ctx context.Context, req *pbauth.Request) (*pbaut
 			// parameters and objects needed to make some sort of database query.
 			var (
 				params struct {
-					ip, secret string
-					id         int64
-					secure     bool
+					secret string
+					id     int64
+					secure bool
 				}
 				migrate = query.Migrate{
 					Context: a.Context,
@@ -279,13 +327,25 @@ func (a *Service) ActionSignin(ctx context.Context, req *pbauth.Request) (*pbaut
 			// totp.Validate() function to check if the provided code matches the secret. If the code does not match, an error is returned.
 			if params.secure {
 				if !totp.Validate(req.GetFactorCode(), params.secret) {
+					a.writeFailure(req.GetEmail(), ip)
 					return &response, status.Error(115654, "invalid 2fa secure code")
 				}
 			}
 
+			// The risky sign in is confirmed only by the step-up code, the code sent without the location, such as the one requested
+			// from a known location, does not confirm the sign in from the new one.
+			risk := a.queryRisk(params.id, req.GetEmail(), ip)
+			if risk.StepUp() {
+				if step, _ := a.Context.RedisClient.Get(context.Background(), fmt.Sprintf("signin:step:%v", params.id)).Result(); step != req.GetEmailCode() {
+					return &response, status.Errorf(11751, "the sign in from %v must be confirmed by the code sent for it, request a new code", strings.Join(risk.Reasons, ", "))
+				}
+			}
+			a.Context.RedisClient.Del(context.Background(), fmt.Sprintf("signin:step:%v", params.id))
+			a.clearFailure(req.GetEmail())
+
 			// This code is used to obtain a token and check for any errors that occurred while attempting to obtain it. If an
 			// error is found, the response is returned with the error.
-			token, err := a.ReplayToken(params.id)
+			token, err := a.ReplayToken(params.id, "", "")
 			if err != nil {
 				return &response, err
 			}
@@ -306,23 +366,11 @@ func (a *Service) ActionSignin(ctx context.Context, req *pbauth.Request) (*pbaut
 					return &response, err
 				}
 
-				// This code is attempting to obtain the IP address of a peer from a given context. The first "if" statement checks
-				// if the peer is available in the context, and if so, assigns it to the variable "mp". The second "if" statement
-				// checks if the address of the peer is a TCP address, and if so, assigns the IP address to the variable "params.ip".
-				// If the address is not a TCP address, the code assigns the full address to "params.ip".
-				if mp, ok := peer.FromContext(ctx); ok {
-					if tcpAddr, ok := mp.Addr.(*net.TCPAddr); ok {
-						params.ip = tcpAddr.IP.String()
-					} else {
-						params.ip = mp.Addr.String()
-					}
-				}
-
 				// This code is inserting data into the 'actions' table of a database. It is taking the user ID, operating system,
-				// device, browser, and IP address from the given parameters and inserting them into the database. The
+				// device, browser, IP address and the location and the risk of the sign in and inserting them into the database. The
 				// strings.ToLower() function ensures that the OS is stored in lowercase. The code also checks for any errors that
 				// may occur during the insertion and returns an error response if necessary.
-				if _, err = a.Context.Db.Exec("insert into actions (user_id, os, device, browser, ip) values ($1, $2, $3, $4, $5)", params.id, strings.ToLower(agent.OS), agent.Device, browser, params.ip); err != nil {
+				if _, err = a.Context.Db.Exec("insert into actions (user_id, os, device, browser, ip, country, latitude, longitude, risk) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)", params.id, strings.ToLower(agent.OS), agent.Device, browser, ip, risk.Location.Country, risk.Location.Latitude, risk.Location.Longitude, risk.Score); err != nil {
 					return &response, err
 				}
 			}
@@ -341,9 +389,10 @@ func (a *Service) ActionSignin(ctx context.Context, req *pbauth.Request) (*pbaut
 
 			// This code assigns the AccessToken and RefreshToken from the token object to the response object. This allows the
 			// AccessToken and RefreshToken to be stored in the response object for future use.
-			response.AccessToken, response.RefreshToken = token.AccessToken, token.RefreshToken
+			response.AccessToken, response.RefreshToken, response.SigningSecret = token.AccessToken, token.RefreshToken, token.SigningSecret
 
 		} else {
+			a.writeFailure(req.GetEmail(), ip)
 			return &response, status.Error(58042, "this code is invalid")
 		}
 
@@ -404,7 +453,7 @@ func (a *Service) ActionReset(ctx context.Context, req *pbauth.Request) (*pbauth
 
 		// This code is setting a code based on the email provided in the request (req.GetEmail()) and checking for an error.
 		// If an error is found, it is returned with the response and the context error is triggered.
-		code, err := a.writeCode(req.GetEmail())
+		code, err := a.writeCode(req.GetEmail(), "secure")
 		if err != nil {
 			return &response, err
 		}
@@ -479,15 +528,15 @@ func (a *Service) ActionReset(ctx context.Context, req *pbauth.Request) (*pbauth
 	return &response, nil
 }
 
-// SetLogout - This function is part of the Service struct and is used to log out a user from the system. It sets the email code in
-// the accounts table to an empty string and deletes the refresh token from the Redis Client. It also checks for
-// permission before executing the logout.
+// SetLogout - This function ends the session of the refresh token: the session family of the token is revoked, so that
+// neither its refresh token nor its access tokens are accepted any longer, and the security code of the email is cleared.
 func (a *Service) SetLogout(ctx context.Context, req *pbauth.Request) (*pbauth.Response, error) {
 
 	// This line of code declares a variable called response of type pbauth.Response. The purpose of this line is to create
 	// a variable that will store a value of type pbauth.Response.
 	var (
-		response pbauth.Response
+		response  pbauth.Response
+		serialize pbauth.Response_Session
 	)
 
 	// This code is used to check for authorization in an incoming context. It uses the FromIncomingContext() method from
@@ -498,6 +547,14 @@ func (a *Service) SetLogout(ctx context.Context, req *pbauth.Request) (*pbauth.R
 		return &response, status.Error(10004, "permission denied")
 	}
 
+	// The session family of the refresh token is revoked, the sessions issued before the families were introduced have none
+	// and end with the removal of their refresh token.
+	if session, err := a.Context.RedisClient.Get(context.Background(), req.GetRefresh()).Bytes(); err == nil && msgpack.Unmarshal(session, &serialize) == nil && len(serialize.GetFamily()) > 0 {
+		if err := a.writeRevoke(serialize.GetSubject(), serialize.GetFamily()); err != nil {
+			return &response, err
+		}
+	}
+
 	// The purpose of this code is to delete a key-value pair from a Redis database using the background context. The key is
 	// given by the req.GetRefresh() method.
 	a.Context.RedisClient.Del(context.Background(), req.GetRefresh())
@@ -508,15 +565,43 @@ func (a *Service) SetLogout(ctx context.Context, req *pbauth.Request) (*pbauth.R
 	if _, err := a.Context.Db.Exec("update accounts set email_code = $2 where email = $1;", req.GetEmail(), ""); err != nil {
 		return &response, err
 	}
+	response.Success = true
 
 	return &response, nil
 }
 
-// GetRefresh - This function is part of a Service struct and is used to get a refreshed Access Token and Refresh Token. It takes in a
-// context object and a Request object as parameters, and returns a Response object and an error if there is one.
-// The function first checks that the incoming context contains the necessary metadata. It then attempts to retrieve the session from Redis using the Refresh Token in the Request object.
-// It then unmarshals the session and compares the Access Token in the session to the Access Token in the context's metadata.
-// If they match, it calls the ReplayToken function to get a new Access Token and Refresh Token, which it sets in the Response object and returns.
+// SetLogoutAll - This function ends all the sessions of the user on all the devices, every session family of the user is revoked.
+// It is used when the user suspects that a device or a token was compromised.
+func (a *Service) SetLogoutAll(ctx context.Context, _ *pbauth.Request) (*pbauth.Response, error) {
+
+	var (
+		response pbauth.Response
+	)
+
+	auth, err := a.Context.Auth(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	families, err := a.Context.RedisClient.SMembers(context.Background(), fmt.Sprintf("session:user:%v", auth)).Result()
+	if err != nil {
+		return &response, err
+	}
+
+	for _, family := range families {
+		if err := a.writeRevoke(auth, family); err != nil {
+			return &response, err
+		}
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetRefresh - This function rotates the refresh token of the session: the session stored under the refresh token is checked to
+// be issued together with the access token of the request, and a new access token and a new refresh token of the same
+// session family are returned. Every refresh token is used once, the second use of a token means it was stolen by
+// someone who raced the owner with it, so the whole family is revoked and the owner has to sign in again.
 func (a *Service) GetRefresh(ctx context.Context, req *pbauth.Request) (*pbauth.Response, error) {
 
 	// The purpose of this code is to declare two variables, response and serialize, of type pbauth.Response and
@@ -531,16 +616,15 @@ func (a *Service) GetRefresh(ctx context.Context, req *pbauth.Request) (*pbauth.
 	// the caller (10411). If the metadata exists, it checks if the "authorization" key is present and has a length of 1. If
 	// either of these conditions are not met, an error is returned.
 	meta, ok := metadata.FromIncomingContext(ctx)
-	if !ok && len(meta["authorization"]) != 1 && meta["authorization"] == nil {
+	if !ok || len(meta["authorization"]) != 1 {
 		return &response, status.Error(10411, "missing metadata")
 	}
 
-	// This code is retrieving a session from a RedisClient using the Get() function. It is passing in the
-	// context.Background() as the first argument and the req.GetRefresh() as the second argument. If there is an error in
-	// retrieving the session, the code returns the response and an error.
+	// This code is retrieving a session from a RedisClient using the Get() function. The refresh token which is not found was
+	// never issued, has expired or belongs to a revoked family.
 	session, err := a.Context.RedisClient.Get(context.Background(), req.GetRefresh()).Bytes()
 	if err != nil {
-		return &response, err
+		return &response, status.Error(31754, "session not found")
 	}
 
 	// This code is attempting to unmarshal a session object from a msgpack-encoded byte array, and assign it to the
@@ -552,14 +636,40 @@ func (a *Service) GetRefresh(ctx context.Context, req *pbauth.Request) (*pbauth.
 
 	// This code is checking if the authorization token provided in the meta field matches the serialized access token. If
 	// the two tokens do not match, an error is returned.
-	token := strings.Split(meta["authorization"][0], "Bearer ")[1]
-	if serialize.AccessToken != token {
+	if token, ok := strings.CutPrefix(meta["authorization"][0], "Bearer "); !ok || serialize.GetAccessToken() != token {
 		return &response, status.Error(31754, "session not found")
 	}
 
-	// The purpose of this code is to generate a replay token associated with the given subject. If an error is encountered
-	// while generating the token, the function returns an error.
-	replayToken, err := a.ReplayToken(serialize.Subject)
+	if len(serialize.GetFamily()) > 0 {
+
+		if count, err := a.Context.RedisClient.Exists(context.Background(), assets.RevokeKey(serialize.GetFamily())).Result(); err != nil || count > 0 {
+			return &response, status.Error(10018, "the session is revoked")
+		}
+
+		// The refresh token is marked as used at once, so that of the two requests racing with the same token only one is
+		// served, and the other is detected as the reuse of the token.
+		fresh, err := a.Context.RedisClient.SetNX(context.Background(), fmt.Sprintf("session:used:%v", req.GetRefresh()), serialize.GetSubject(), assets.RefreshLifetime).Result()
+		if err != nil {
+			return &response, err
+		}
+
+		if !fresh {
+			if err := a.writeRevoke(serialize.GetSubject(), serialize.GetFamily()); err != nil {
+				return &response, err
+			}
+			return &response, status.Error(10019, "the refresh token was already used, the session is revoked")
+		}
+
+	} else {
+
+		// The session issued before the families were introduced is moved to a new family, its refresh token is removed.
+		a.Context.RedisClient.Del(context.Background(), req.GetRefresh())
+	}
+
+	// The purpose of this code is to generate a replay token associated with the given subject in the same session family,
+	// the session of the wallet stays the session of the wallet. If an error is encountered while generating the token, the
+	// function returns an error.
+	replayToken, err := a.ReplayToken(serialize.GetSubject(), serialize.GetFamily(), serialize.GetWallet())
 	if err != nil {
 		return nil, err
 	}
@@ -567,7 +677,7 @@ func (a *Service) GetRefresh(ctx context.Context, req *pbauth.Request) (*pbauth.
 	// This code is used to assign the values of the AccessToken and RefreshToken returned by the
 	// replayToken.GetAccessToken() and replayToken.GetRefreshToken() functions to the response object. This is necessary in
 	// order to store the tokens in the response object so they can be used by the application later.
-	response.AccessToken, response.RefreshToken = replayToken.GetAccessToken(), replayToken.GetRefreshToken()
+	response.AccessToken, response.RefreshToken, response.SigningSecret = replayToken.GetAccessToken(), replayToken.GetRefreshToken(), replayToken.GetSigningSecret()
 
 	return &response, nil
 }
@@ -608,3 +718,200 @@ func (a *Service) GetSecure(ctx context.Context, req *pbauth.Request) (*pbauth.R
 
 	return &response, nil
 }
+
+// GetNonce - This function issues the nonce the message of the sign in with Ethereum is signed with, the wallet signs the message
+// with the nonce for the sign in or for the link of the wallet to the account.
+func (a *Service) GetNonce(_ context.Context, _ *pbauth.Request) (*pbauth.Response, error) {
+
+	var (
+		response pbauth.Response
+		err      error
+	)
+
+	if response.Nonce, err = a.writeNonce(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// ActionWallet - This function signs the user in with the Ethereum wallet: the message of EIP-4361 with the nonce of the server is
+// verified with the signature of the wallet, and the access token and the refresh token of the account the wallet is
+// linked to are returned. The wallet that is not linked yet creates a new account, when the registrations are open,
+// the account has no password and is signed in with its wallets only. The sessions of the wallet carry its address and
+// end when the wallet is unlinked.
+func (a *Service) ActionWallet(ctx context.Context, req *pbauth.Request) (*pbauth.Response, error) {
+
+	var (
+		response pbauth.Response
+		migrate  = query.Migrate{
+			Context: a.Context,
+		}
+		userId int64
+	)
+
+	meta, ok := metadata.FromIncomingContext(ctx)
+	if ok && meta["authorization"] != nil {
+		return &response, status.Error(10004, "permission denied")
+	}
+
+	m, err := a.queryMessage(req.GetMessage(), req.GetSignature())
+	if err != nil {
+		return &response, err
+	}
+
+	if err := a.Context.Db.QueryRow("select user_id from account_wallets where lower(address) = lower($1)", m.Address).Scan(&userId); err != nil {
+
+		if err != sql.ErrNoRows {
+			return &response, err
+		}
+
+		// The registrations of the new accounts can be suspended by the operators.
+		if err := a.Context.Feature(types.FeatureRegistration); err != nil {
+			return &response, err
+		}
+
+		entropy, err := bip39.NewEntropy(128)
+		if err != nil {
+			return &response, err
+		}
+
+		entropy, err = a.Context.SealEntropy(entropy)
+		if err != nil {
+			return &response, err
+		}
+
+		// The account of the wallet has no email, the address in lower case stands in for it, so that the emails of the
+		// accounts stay unique, and the name is the shortened address.
+		if err := a.Context.Db.QueryRow("insert into accounts (name, email, entropy, status) values ($1, $2, $3, $4) returning id", fmt.Sprintf("%v...%v", m.Address[:6], m.Address[len(m.Address)-4:]), strings.ToLower(m.Address), entropy, true).Scan(&userId); err != nil {
+			return &response, status.Error(15316, "a user with this address has already been registered before")
+		}
+	}
+
+	if err := a.writeWallet(userId, m); err != nil {
+		return &response, err
+	}
+
+	token, err := a.ReplayToken(userId, "", m.Address)
+	if err != nil {
+		return &response, err
+	}
+
+	// The sign in of the wallet is recorded with the device and the location of the client, as the sign in with the password.
+	if ok && len(meta.Get("grpcgateway-user-agent")) > 0 {
+
+		ip := a.queryAddress(ctx)
+		risk := a.queryRisk(userId, m.Address, ip)
+		agent := help.MetaAgent(meta.Get("grpcgateway-user-agent")[0])
+
+		browser, err := json.Marshal([]string{strings.ToLower(agent.Name), agent.Version})
+		if err != nil {
+			return &response, err
+		}
+
+		if _, err = a.Context.Db.Exec("insert into actions (user_id, os, device, browser, ip, country, latitude, longitude, risk) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)", userId, strings.ToLower(agent.OS), agent.Device, browser, ip, risk.Location.Country, risk.Location.Latitude, risk.Location.Longitude, risk.Score); err != nil {
+			return &response, err
+		}
+	}
+
+	go migrate.SendMail(userId, "login", nil)
+
+	response.Id, response.AccessToken, response.RefreshToken, response.SigningSecret = userId, token.GetAccessToken(), token.GetRefreshToken(), token.GetSigningSecret()
+
+	return &response, nil
+}
+
+// SetWallet - This function links the Ethereum wallet that signed the message with the nonce of the server to the account of the
+// user, the user then signs in with the wallet as well.
+func (a *Service) SetWallet(ctx context.Context, req *pbauth.Request) (*pbauth.Response, error) {
+
+	var (
+		response pbauth.Response
+	)
+
+	auth, err := a.Context.Auth(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	m, err := a.queryMessage(req.GetMessage(), req.GetSignature())
+	if err != nil {
+		return &response, err
+	}
+
+	if err := a.writeWallet(auth, m); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteWallet - This function unlinks the wallet with the address from the account of the user and ends the sessions signed in
+// with it. The last wallet of the account without a password is kept, the account could not be signed in otherwise.
+func (a *Service) DeleteWallet(ctx context.Context, req *pbauth.Request) (*pbauth.Response, error) {
+
+	var (
+		response pbauth.Response
+		password sql.NullString
+		count    int
+	)
+
+	auth, err := a.Context.Auth(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	if err := a.Context.Db.QueryRow("select password, (select count(*) from account_wallets where user_id = accounts.id) from accounts where id = $1", auth).Scan(&password, &count); err != nil {
+		return &response, err
+	}
+
+	if (!password.Valid || len(password.String) == 0) && count <= 1 {
+		return &response, status.Error(11787, "the last wallet of the account without a password can not be unlinked")
+	}
+
+	if err := a.Context.Db.QueryRow("delete from account_wallets where user_id = $1 and lower(address) = lower($2) returning address", auth, req.GetAddress()).Scan(&req.Address); err != nil {
+		return &response, status.Error(11786, "the wallet is not linked to the account")
+	}
+
+	if err := a.writeUnlink(auth, req.GetAddress()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetWallets - This function returns the wallets linked to the account of the user.
+func (a *Service) GetWallets(ctx context.Context, _ *pbauth.Request) (*pbauth.Response, error) {
+
+	var (
+		response pbauth.Response
+	)
+
+	auth, err := a.Context.Auth(ctx)
+	if err != nil {
+		return &response, err
+	}
+
+	rows, err := a.Context.Db.Query("select id, address, chain_id, create_at from account_wallets where user_id = $1 order by id", auth)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item pbauth.Wallet
+		)
+
+		if err := rows.Scan(&item.Id, &item.Address, &item.ChainId, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Wallets = append(response.Wallets, &item)
+	}
+
+	return &response, nil
+}
@@ -76,7 +76,7 @@ func (a *Service) queryValidateOrder(order *types.Future) (summary float64, err
 			return 0, status.Errorf(11623, "[quote]: minimum trading amount: %v~%v, maximum trading amount: %v", min, strconv.FormatFloat(decimal.New(min).Mul(2).Float(), 'f', -1, 64), strconv.FormatFloat(max, 'f', -1, 64))
 		}
 
-		balance := a.QueryBalance(order.GetQuoteUnit(), "future", order.GetUserId())
+		balance := a.QueryBalance(order.GetQuoteUnit(), types.TypeFuture, order.GetUserId())
 
 		if decimal.New(quantity).Div(order.GetLeverage()).Float() > balance || order.GetQuantity() == 0 {
 			return 0, status.Error(11586, "[quote]: there is not enough funds on your asset balance to place an order")
@@ -95,7 +95,7 @@ func (a *Service) queryValidateOrder(order *types.Future) (summary float64, err
 			return 0, status.Errorf(11587, "[base]: minimum trading amount: %v~%v, maximum trading amount: %v", min, strconv.FormatFloat(decimal.New(min).Mul(2).Float(), 'f', -1, 64), strconv.FormatFloat(max, 'f', -1, 64))
 		}
 
-		// balance := a.QueryBalance(order.GetBaseUnit(), "future", order.GetUserId())
+		// balance := a.QueryBalance(order.GetBaseUnit(), types.TypeFuture, order.GetUserId())
 
 		if quantity > openQuantity || order.GetQuantity() == 0 {
 			return 0, status.Error(11624, "[base]: there is not enough funds locking in your orders")
@@ -138,18 +138,18 @@ func (a *Service) writeAsset(symbol, _type string, userId int64, error bool) err
 
 	return nil
 }
-func (a *Service) WriteBalance(symbol, _type string, userId int64, quantity float64, cross string) error {
+func (a *Service) WriteBalance(symbol, _type string, userId int64, quantity *decimal.Float, cross string) error {
 
 	switch cross {
 	case types.BalancePlus:
 
-		if _, err := a.Context.Db.Exec("update balances set value = value + $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity, userId, _type); err != nil {
+		if _, err := a.Context.Db.Exec("update balances set value = value + $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity.String(), userId, _type); err != nil {
 			return err
 		}
 		break
 	case types.BalanceMinus:
 
-		if _, err := a.Context.Db.Exec("update balances set value = value - $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity, userId, _type); err != nil {
+		if _, err := a.Context.Db.Exec("update balances set value = value - $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity.String(), userId, _type); err != nil {
 			return err
 		}
 		break
@@ -178,39 +178,46 @@ func (a *Service) QueryBalance(symbol, _type string, userId int64) (balance floa
 	return balance
 }
 
-func (a *Service) writeTrade(id int64, symbol string, value, price float64, convert bool) (float64, error) {
+func (a *Service) writeTrade(id int64, symbol string, value, price float64, convert bool) (*decimal.Float, error) {
 
 	order := a.queryOrder(id)
 	// order.Value = value
 
+	amount := decimal.New(value)
 	if convert {
-		value = decimal.New(value).Mul(price).Float()
+		amount = amount.Mul(price)
 	}
 
-	s, f, maker, err := a.querySum(id, symbol, value)
+	s, f, maker, err := a.querySum(id, symbol, amount)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
+	fees := f
 	if order.GetPosition() == types.PositionLong {
-		order.Fees = decimal.New(f).Div(price).Float()
-	} else {
-		order.Fees = f
+		fees = f.Div(price)
+	}
+	order.Fees = fees.Float()
+
+	// The trade is recorded with the side of the order book the order takes, the trades of the spot and of the futures share it.
+	side, err := types.Side(order.GetAssigning(), order.GetPosition())
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := a.Context.Db.Exec(`insert into trades (order_id, assigning, user_id, base_unit, quote_unit, quantity, fees, price, maker) values ($1, $2, $3, $4, $5, $6, $7, $8)`, order.GetId(), order.GetAssigning(), order.GetUserId(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetQuantity(), order.GetFees(), price, maker); err != nil {
-		return 0, err
+	if _, err := a.Context.Db.Exec(`insert into trades (order_id, assigning, user_id, base_unit, quote_unit, quantity, fees, price, maker) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`, order.GetId(), side, order.GetUserId(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetQuantity(), fees.String(), price, maker); err != nil {
+		return nil, err
 	}
 
-	if f > 0 {
+	if f.Sign() > 0 {
 
-		if _, err := a.Context.Db.Exec("update assets set fees_charges = fees_charges + $2 where symbol = $1;", symbol, f); err != nil {
-			return 0, err
+		if _, err := a.Context.Db.Exec("update assets set fees_charges = fees_charges + $2 where symbol = $1;", symbol, f.String()); err != nil {
+			return nil, err
 		}
 	}
 
 	if err := a.Context.Publish(a.queryOrder(order.GetId()), "exchange", "future/status"); err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	return s, nil
@@ -251,14 +258,13 @@ func (a *Service) queryOrders(userId int64, assigning string, position string, b
 	return orders, totalQuantity
 }
 
-func (a *Service) querySum(id int64, symbol string, value float64) (b, f float64, m bool, err error) {
+func (a *Service) querySum(id int64, symbol string, value *decimal.Float) (b, f *decimal.Float, m bool, err error) {
 
 	var (
-		d float64
-		s string
+		fees, discount, s string
 	)
 
-	if err := a.Context.Db.QueryRow("select fees_trade, fees_discount from assets where symbol = $1", symbol).Scan(&f, &d); err != nil {
+	if err := a.Context.Db.QueryRow("select fees_trade::text, fees_discount::text from assets where symbol = $1", symbol).Scan(&fees, &discount); err != nil {
 		return b, f, m, err
 	}
 
@@ -270,11 +276,13 @@ func (a *Service) querySum(id int64, symbol string, value float64) (b, f float64
 		m = true
 	}
 
+	rate := decimal.New(fees)
 	if m {
-		f = decimal.New(f).Sub(d).Float()
+		rate = rate.Sub(discount)
 	}
+	f = value.Mul(rate).Div(100)
 
-	return decimal.New(value).Sub(decimal.New(decimal.New(value).Mul(f).Float()).Div(100).Float()).Float(), decimal.New(value).Sub(decimal.New(value).Sub(decimal.New(decimal.New(value).Mul(f).Float()).Div(100).Float()).Float()).Float(), m, nil
+	return value.Sub(f), f, m, nil
 }
 
 func (a *Service) queryQuantity(assigning string, position string, quantity, price float64, cross bool) float64 {
@@ -3,11 +3,12 @@ package future
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbfuture"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
 	"github.com/cryptogateway/backend-envoys/server/types"
@@ -25,7 +26,7 @@ func (a *Service) GetFutures(_ context.Context, req *pbfuture.GetRequestFutures)
 func (a *Service) GetOrders(ctx context.Context, req *pbfuture.GetRequestOrders) (*pbfuture.ResponseOrder, error) {
 	var (
 		response pbfuture.ResponseOrder
-		maps     []string
+		builder  = query.NewBuilder()
 	)
 
 	if req.GetLimit() == 0 {
@@ -34,18 +35,18 @@ func (a *Service) GetOrders(ctx context.Context, req *pbfuture.GetRequestOrders)
 
 	switch req.GetAssigning() {
 	case types.AssigningOpen:
-		maps = append(maps, fmt.Sprintf("where assigning = '%v'", types.AssigningOpen))
+		builder.Where("assigning = ?", types.AssigningOpen)
 	case types.AssigningClose:
-		maps = append(maps, fmt.Sprintf("where assigning = '%v'", types.AssigningClose))
+		builder.Where("assigning = ?", types.AssigningClose)
 	default:
-		maps = append(maps, fmt.Sprintf("where assigning = '%v' or assigning = '%v'", types.AssigningClose, types.AssigningOpen))
+		builder.Where("(assigning = ? or assigning = ?)", types.AssigningClose, types.AssigningOpen)
 	}
 
 	if len(req.GetPosition()) > 0 {
 		if err := types.Position(req.GetPosition()); err != nil {
 			return &response, err
 		}
-		maps = append(maps, fmt.Sprintf("and position = '%v'", req.GetPosition()))
+		builder.Where("position = ?", req.GetPosition())
 	}
 	// check order type
 
@@ -56,24 +57,20 @@ func (a *Service) GetOrders(ctx context.Context, req *pbfuture.GetRequestOrders)
 			return &response, err
 		}
 
-		maps = append(maps, fmt.Sprintf("and user_id = '%v'", auth))
+		builder.Where("user_id = ?", auth)
 
 	} else if req.GetUserId() > 0 {
 
-		maps = append(maps, fmt.Sprintf("and user_id = '%v'", req.GetUserId()))
+		builder.Where("user_id = ?", req.GetUserId())
 	}
 	switch req.GetStatus() {
-	case types.StatusFilled:
-		maps = append(maps, fmt.Sprintf("and status = '%v'", types.StatusFilled))
-	case types.StatusPending:
-		maps = append(maps, fmt.Sprintf("and status = '%v'", types.StatusPending))
-	case types.StatusCancel:
-		maps = append(maps, fmt.Sprintf("and status = '%v'", types.StatusCancel))
+	case types.StatusFilled, types.StatusPending, types.StatusCancel:
+		builder.Where("status = ?", req.GetStatus())
 	}
 	if len(req.GetBaseUnit()) > 0 && len(req.GetQuoteUnit()) > 0 {
-		maps = append(maps, fmt.Sprintf("and base_unit = '%v' and quote_unit = '%v'", req.GetBaseUnit(), req.GetQuoteUnit()))
+		builder.Where("base_unit = ? and quote_unit = ?", req.GetBaseUnit(), req.GetQuoteUnit())
 	}
-	_ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count, sum(value) as volume from futures %s", strings.Join(maps, " "))).Scan(&response.Count, &response.Volume)
+	_ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count, sum(value) as volume from futures %s", builder.Clause()), builder.Params()...).Scan(&response.Count, &response.Volume)
 
 	if response.GetCount() > 0 {
 
@@ -82,7 +79,7 @@ func (a *Service) GetOrders(ctx context.Context, req *pbfuture.GetRequestOrders)
 			offset = req.GetLimit() * (req.GetPage() - 1)
 		}
 
-		rows, err := a.Context.Db.Query(fmt.Sprintf("select id, assigning, price, value, quantity, base_unit, quote_unit, user_id, create_at, Position, status from futures %s order by id desc limit %d offset %d", strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := a.Context.Db.Query(fmt.Sprintf("select id, assigning, price, value, quantity, base_unit, quote_unit, user_id, create_at, Position, status from futures %s order by id desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -116,13 +113,23 @@ func (a *Service) SetOrder(ctx context.Context, req *pbfuture.SetRequestOrder) (
 		order    types.Future
 	)
 
-	// auth, err := a.Context.Auth(ctx)
-	// if err != nil {
-	// 	return &response, err
-	// }
-	var auth int64 = 6
+	auth := a.Context.User(ctx)
 
-	if err := a.queryValidatePair(req.GetBaseUnit(), req.GetQuoteUnit(), "future"); err != nil {
+	if err := a.queryValidatePair(req.GetBaseUnit(), req.GetQuoteUnit(), types.TypeFuture); err != nil {
+		return &response, err
+	}
+
+	// The futures orders take the assigning and the position of the registry of the types, not the sides of the spot orders.
+	if err := types.Valid(types.EnumFuture, req.GetAssigning()); err != nil {
+		return &response, status.Error(11588, "invalid assigning trade position")
+	}
+
+	if err := types.Position(req.GetPosition()); err != nil {
+		return &response, err
+	}
+
+	// The trading can be suspended by the operators for the whole exchange, for the pair or for either of its currencies.
+	if err := a.Context.Feature(types.FeatureTrading, assets.Scope{Kind: types.ScopePair, Target: fmt.Sprintf("%v/%v", req.GetBaseUnit(), req.GetQuoteUnit())}, assets.Scope{Kind: types.ScopeCurrency, Target: req.GetBaseUnit()}, assets.Scope{Kind: types.ScopeCurrency, Target: req.GetQuoteUnit()}); err != nil {
 		return &response, err
 	}
 	_account := account.Service{
@@ -182,14 +189,14 @@ func (a *Service) SetOrder(ctx context.Context, req *pbfuture.SetRequestOrder) (
 	switch order.GetAssigning() {
 	case types.AssigningOpen:
 
-		if err := a.WriteBalance(order.GetQuoteUnit(), order.GetAssigning(), order.GetUserId(), margin, types.BalanceMinus); err != nil {
+		if err := a.WriteBalance(order.GetQuoteUnit(), types.TypeFuture, order.GetUserId(), decimal.New(margin), types.BalanceMinus); err != nil {
 			return &response, err
 		}
 
 		break
 	case types.AssigningClose:
 
-		if err := a.WriteBalance(order.GetBaseUnit(), order.GetAssigning(), order.GetUserId(), margin, types.BalancePlus); err != nil {
+		if err := a.WriteBalance(order.GetBaseUnit(), types.TypeFuture, order.GetUserId(), decimal.New(margin), types.BalancePlus); err != nil {
 			return &response, err
 		}
 
@@ -220,16 +227,20 @@ func (a *Service) SetTicker(_ context.Context, req *pbfuture.SetRequestTicker) (
 
 	for _, interval := range help.Depth() {
 
-		migrate, err := a.GetTicker(context.Background(), &pbfuture.GetRequestTicker{BaseUnit: req.GetBaseUnit(), QuoteUnit: req.GetQuoteUnit(), Limit: 2, Resolution: interval})
-		if err != nil {
-			return &response, err
-		}
+		var (
+			base, quote, resolution = req.GetBaseUnit(), req.GetQuoteUnit(), interval
+			window                  = time.Now().UTC().Truncate(help.Period(interval)).Unix()
+		)
 
-		if err := a.Context.Publish(migrate, "exchange", fmt.Sprintf("trade/ticker:%v", interval)); err != nil {
-			return &response, err
-		}
+		a.Context.Throttle(fmt.Sprintf("future:ticker:%v:%v:%v", base, quote, resolution), window, func() error {
+
+			migrate, err := a.GetTicker(context.Background(), &pbfuture.GetRequestTicker{BaseUnit: base, QuoteUnit: quote, Limit: 2, Resolution: resolution})
+			if err != nil {
+				return err
+			}
 
-		response.Fields = append(response.Fields, migrate.Fields...)
+			return a.Context.Publish(migrate, "exchange", fmt.Sprintf("trade/ticker:%v", resolution))
+		})
 	}
 
 	return &response, nil
@@ -239,7 +250,7 @@ func (a *Service) GetTicker(_ context.Context, req *pbfuture.GetRequestTicker) (
 	var (
 		response pbfuture.ResponseTicker
 		limit    string
-		maps     []string
+		builder  = query.NewBuilder(req.GetBaseUnit(), req.GetQuoteUnit())
 	)
 
 	if req.GetLimit() == 0 {
@@ -247,14 +258,16 @@ func (a *Service) GetTicker(_ context.Context, req *pbfuture.GetRequestTicker) (
 	}
 
 	if req.GetLimit() > 0 {
-		limit = fmt.Sprintf("limit %d", req.GetLimit())
+		limit = fmt.Sprintf("limit %s", builder.Bind(req.GetLimit()))
 	}
 
 	if req.GetTo() > 0 {
-		maps = append(maps, fmt.Sprintf(`and to_char(o.create_at::timestamp, 'yyyy-mm-dd hh24:mi:ss') < to_char(to_timestamp(%[1]d), 'yyyy-mm-dd hh24:mi:ss')`, req.GetTo()))
+		builder.Where("date_trunc('second', o.create_at) < to_timestamp(?)", req.GetTo())
 	}
 
-	rows, err := a.Context.Db.Query(fmt.Sprintf("select extract(epoch from time_bucket('%[4]s', o.create_at))::integer buckettime, first(o.price, o.create_at) as open, last(o.price, o.create_at) as close, first(o.price, o.price) as low, last(o.price, o.price) as high, sum(o.quantity) as volume, avg(o.price) as avg_price, o.base_unit, o.quote_unit from ohlcv as o where o.base_unit = '%[1]s' and o.quote_unit = '%[2]s' %[3]s group by buckettime, o.base_unit, o.quote_unit order by buckettime desc %[5]s", req.GetBaseUnit(), req.GetQuoteUnit(), strings.Join(maps, " "), help.Resolution(req.GetResolution()), limit))
+	source, columns := query.Candles(help.Period(req.GetResolution()))
+
+	rows, err := a.Context.Db.Query(fmt.Sprintf("select extract(epoch from time_bucket(%[3]s::interval, o.create_at))::integer buckettime, %[5]s, o.base_unit, o.quote_unit from %[4]s as o where o.base_unit = $1 and o.quote_unit = $2 %[1]s group by buckettime, o.base_unit, o.quote_unit order by buckettime desc %[2]s", builder.And(), limit, builder.Bind(help.Resolution(req.GetResolution())), source, columns), builder.Params()...)
 	if err != nil {
 		return &response, err
 	}
@@ -277,7 +290,7 @@ func (a *Service) GetTicker(_ context.Context, req *pbfuture.GetRequestTicker) (
 		stats types.Stats
 	)
 
-	_ = a.Context.Db.QueryRow(fmt.Sprintf(`select count(*) as count, sum(h24.quantity) as volume, first(h24.price, h24.price) as low, last(h24.price, h24.price) as high, first(h24.price, h24.create_at) as first, last(h24.price, h24.create_at) as last from ohlcv as h24 where h24.create_at > now()::timestamp - '24 hours'::interval and h24.base_unit = '%[1]s' and h24.quote_unit = '%[2]s'`, req.GetBaseUnit(), req.GetQuoteUnit())).Scan(&stats.Count, &stats.Volume, &stats.Low, &stats.High, &stats.First, &stats.Last)
+	_ = a.Context.Db.QueryRow(`select count(*) as count, sum(h24.quantity) as volume, first(h24.price, h24.price) as low, last(h24.price, h24.price) as high, first(h24.price, h24.create_at) as first, last(h24.price, h24.create_at) as last from ohlcv as h24 where h24.create_at > now()::timestamp - '24 hours'::interval and h24.base_unit = $1 and h24.quote_unit = $2`, req.GetBaseUnit(), req.GetQuoteUnit()).Scan(&stats.Count, &stats.Volume, &stats.Low, &stats.High, &stats.First, &stats.Last)
 
 	if len(response.Fields) > 1 {
 		stats.Previous = response.Fields[1].Close
@@ -26,7 +26,7 @@ func (a *Service) closePosition(order *types.Future) {
 	}
 
 	// get future orders by position
-	rows, err := a.Context.Db.Query(`select id, assigning, position, base_unit, quote_unit, quantity, price, user_id, status from futures where assigning = $1 and base_unit = $2 and quote_unit = $3 and user_id != $4 and status = $5 and position = $6 order by id`, "close", order.GetBaseUnit(), order.GetQuoteUnit(), order.GetUserId(), types.StatusPending, position)
+	rows, err := a.Context.Db.Query(`select id, assigning, position, base_unit, quote_unit, quantity, price, user_id, status from futures where assigning = $1 and base_unit = $2 and quote_unit = $3 and user_id != $4 and status = $5 and position = $6 order by id`, types.AssigningClose, order.GetBaseUnit(), order.GetQuoteUnit(), order.GetUserId(), types.StatusPending, position)
 
 	if a.Context.Debug(err) {
 		return
@@ -128,7 +128,7 @@ func (a *Service) handleFutureTrade(position string, params ...*types.Future) {
 				return
 			}
 
-			if err := a.WriteBalance(params[0].GetQuoteUnit(), "future", params[0].GetUserId(), quantity, types.BalancePlus); a.Context.Debug(err) {
+			if err := a.WriteBalance(params[0].GetQuoteUnit(), types.TypeFuture, params[0].GetUserId(), quantity, types.BalancePlus); a.Context.Debug(err) {
 				return
 			}
 
@@ -137,7 +137,7 @@ func (a *Service) handleFutureTrade(position string, params ...*types.Future) {
 				return
 			}
 
-			if err := a.WriteBalance(params[0].GetBaseUnit(), "future", params[1].GetUserId(), quantity, types.BalancePlus); err != nil {
+			if err := a.WriteBalance(params[0].GetBaseUnit(), types.TypeFuture, params[1].GetUserId(), quantity, types.BalancePlus); err != nil {
 				return
 			}
 
@@ -149,7 +149,7 @@ func (a *Service) handleFutureTrade(position string, params ...*types.Future) {
 				return
 			}
 
-			if err := a.WriteBalance(params[0].GetBaseUnit(), "future", params[0].GetUserId(), quantity, types.BalancePlus); a.Context.Debug(err) {
+			if err := a.WriteBalance(params[0].GetBaseUnit(), types.TypeFuture, params[0].GetUserId(), quantity, types.BalancePlus); a.Context.Debug(err) {
 				return
 			}
 
@@ -158,7 +158,7 @@ func (a *Service) handleFutureTrade(position string, params ...*types.Future) {
 				return
 			}
 
-			if err := a.WriteBalance(params[0].GetQuoteUnit(), "future", params[1].GetUserId(), quantity, types.BalancePlus); a.Context.Debug(err) {
+			if err := a.WriteBalance(params[0].GetQuoteUnit(), types.TypeFuture, params[1].GetUserId(), quantity, types.BalancePlus); a.Context.Debug(err) {
 				return
 			}
 
@@ -2,8 +2,13 @@ package index
 
 import (
 	"github.com/cryptogateway/backend-envoys/assets"
+	"time"
 )
 
+// heartbeatTimeout - The component that has not reported its heartbeat for longer than this is shown as down on the status page,
+// the components report themselves at least every minute.
+const heartbeatTimeout = 5 * time.Minute
+
 // Service - The Service struct is used to create a structure that holds a pointer to an assets.Context. This allows the Service
 // struct to access the assets.Context and all of its data, and to use that data to carry out tasks.
 type Service struct {
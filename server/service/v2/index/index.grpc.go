@@ -2,12 +2,16 @@ package index
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbindex"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/types"
 	"strings"
+	"time"
 )
 
 // GetStatistic - This function is used to get statistics from the database and return them in the form of a pbindex.ResponseStatistic.
@@ -32,33 +36,33 @@ func (i *Service) GetStatistic(_ context.Context, _ *pbindex.GetRequestStatistic
 
 	// The purpose of the code is to count the number of accounts with a status of 'true' and 'false' and store the values
 	// in the 'account.Enable' and 'account.Disable' variables.
-	_ = i.Context.Db.QueryRow("select count(*) from accounts where status = $1", true).Scan(&account.Enable)
-	_ = i.Context.Db.QueryRow("select count(*) from accounts where status = $1", false).Scan(&account.Disable)
+	_ = i.Context.Reader().QueryRow("select count(*) from accounts where status = $1", true).Scan(&account.Enable)
+	_ = i.Context.Reader().QueryRow("select count(*) from accounts where status = $1", false).Scan(&account.Disable)
 
 	// The purpose of the code is to query a database and count the number of records in the "pairs" table with a status of
 	// true and false. It then stores the results in the "pair.Enable" and "pair.Disable" variables.
-	_ = i.Context.Db.QueryRow("select count(*) from pairs where status = $1", true).Scan(&pair.Enable)
-	_ = i.Context.Db.QueryRow("select count(*) from pairs where status = $1", false).Scan(&pair.Disable)
+	_ = i.Context.Reader().QueryRow("select count(*) from pairs where status = $1", true).Scan(&pair.Enable)
+	_ = i.Context.Reader().QueryRow("select count(*) from pairs where status = $1", false).Scan(&pair.Disable)
 
 	// The purpose of the code snippet is to query the database for the number of chains with status set to true and false,
 	// respectively. It then stores the result of these queries in the variables chain.Enable and chain.Disable, respectively.
-	_ = i.Context.Db.QueryRow("select count(*) from chains where status = $1", true).Scan(&chain.Enable)
-	_ = i.Context.Db.QueryRow("select count(*) from chains where status = $1", false).Scan(&chain.Disable)
+	_ = i.Context.Reader().QueryRow("select count(*) from chains where status = $1", true).Scan(&chain.Enable)
+	_ = i.Context.Reader().QueryRow("select count(*) from chains where status = $1", false).Scan(&chain.Disable)
 
 	// The purpose of this code is to query a database for the number of currencies with a status of either true or false,
 	// and assign the result to the variables currency.Enable and currency.Disable.
-	_ = i.Context.Db.QueryRow("select count(*) from assets where status = $1", true).Scan(&currency.Enable)
-	_ = i.Context.Db.QueryRow("select count(*) from assets where status = $1", false).Scan(&currency.Disable)
+	_ = i.Context.Reader().QueryRow("select count(*) from assets where status = $1", true).Scan(&currency.Enable)
+	_ = i.Context.Reader().QueryRow("select count(*) from assets where status = $1", false).Scan(&currency.Disable)
 
 	// The purpose of the code is to query the database for the number of transactions with a status of FILLED and PENDING
 	// and store the results in the variables "transaction.Filled" and "transaction.Pending".
-	_ = i.Context.Db.QueryRow("select count(*) from transactions where status = $1", types.StatusFilled).Scan(&transaction.Filled)
-	_ = i.Context.Db.QueryRow("select count(*) from transactions where status = $1", types.StatusPending).Scan(&transaction.Pending)
+	_ = i.Context.Reader().QueryRow("select count(*) from transactions where status = $1", types.StatusFilled).Scan(&transaction.Filled)
+	_ = i.Context.Reader().QueryRow("select count(*) from transactions where status = $1", types.StatusPending).Scan(&transaction.Pending)
 
 	// The purpose of the two queries is to count the number of orders with a specific assigning and status. The assigning
 	// and status are passed in as parameters. The result of the query is stored in the order.Sell and order.Buy variables.
-	_ = i.Context.Db.QueryRow("select count(*) from orders where assigning = $1 and status = $2", types.AssigningSell, types.StatusPending).Scan(&order.Sell)
-	_ = i.Context.Db.QueryRow("select count(*) from orders where assigning = $1 and status = $2", types.AssigningBuy, types.StatusPending).Scan(&order.Buy)
+	_ = i.Context.Reader().QueryRow("select count(*) from orders where assigning = $1 and status = $2", types.AssigningSell, types.StatusPending).Scan(&order.Sell)
+	_ = i.Context.Reader().QueryRow("select count(*) from orders where assigning = $1 and status = $2", types.AssigningBuy, types.StatusPending).Scan(&order.Buy)
 
 	// The purpose of this code is to assign values to the fields of the statistic struct. The accounts, pairs, chains,
 	// currencies, transactions, and orders fields are assigned the memory address of the variables account, pair, chain, currency, transaction, and order, respectively.
@@ -70,11 +74,11 @@ func (i *Service) GetStatistic(_ context.Context, _ *pbindex.GetRequestStatistic
 	statistic.Orders = &order
 
 	// This code is used to query the database for a specific set of data. The query searches for the three fields symbol,
-	// fees_charges, and fees_costs from the currencies table. The query is executed using the i.Context.Db.Query()
+	// fees_charges, and fees_costs from the currencies table. The query is executed using the i.Context.Reader().Query()
 	// function, and the result is returned in the rows variable. If an error occurs during the query, the err variable is
 	// populated with the error. The defer statement ensures
 	// that the rows.Close() function is called after the query is complete, which closes the connection to the database.
-	rows, err := i.Context.Db.Query("select symbol, fees_charges, fees_costs from assets")
+	rows, err := i.Context.Reader().Query("select symbol, fees_charges, fees_costs from assets")
 	if err != nil {
 		return &response, err
 	}
@@ -100,7 +104,7 @@ func (i *Service) GetStatistic(_ context.Context, _ *pbindex.GetRequestStatistic
 		// This is a conditional statement checking to see if the sum of the 'value' field from the 'reserves' table is greater
 		// than 0, where the 'symbol' field is equal to the value stored in the 'reserve.Symbol' variable. If the sum is
 		// greater than 0, the code inside the curly braces will be executed.
-		if _ = i.Context.Db.QueryRow("select sum(value) from reserves where symbol = $1", reserve.Symbol).Scan(&reserve.Value); reserve.Value > 0 {
+		if _ = i.Context.Reader().QueryRow("select sum(value) from reserves where symbol = $1", reserve.Symbol).Scan(&reserve.Value); reserve.Value > 0 {
 
 			// The purpose of this code is to check if the symbol stored in the reserve variable is equal to "usdt". If the symbol
 			// is not equal to "usdt", then this code will execute a specific action.
@@ -109,7 +113,7 @@ func (i *Service) GetStatistic(_ context.Context, _ *pbindex.GetRequestStatistic
 				// This code is attempting to query a database for the price of a particular currency pair. The query is looking for
 				// the price of the currency pair where the base unit is the reserve.Symbol and the quote unit is "usd". If an error
 				// occurs while attempting to query the database, an error is returned.
-				if err := i.Context.Db.QueryRow("select price from pairs where base_unit = $1 and quote_unit = $2", reserve.Symbol, "usd").Scan(&price); err != nil {
+				if err := i.Context.Reader().QueryRow("select price from pairs where base_unit = $1 and quote_unit = $2", reserve.Symbol, "usd").Scan(&price); err != nil {
 					return &response, err
 				}
 
@@ -136,11 +140,11 @@ func (i *Service) GetStatistic(_ context.Context, _ *pbindex.GetRequestStatistic
 // GetRequestMarkets object as parameters, to limit the number of pairs returned and to filter the search.
 func (i *Service) GetMarkets(ctx context.Context, req *pbindex.GetRequestMarkets) (*pbindex.ResponseMarket, error) {
 
-	// The purpose of this code is to declare two variables, response and maps, both of which are of type
-	// pbindex.ResponseMarket and []string, respectively.
+	// The purpose of this code is to declare two variables, response and builder, the response of type pbindex.ResponseMarket
+	// and the builder of the conditions of the query.
 	var (
 		response pbindex.ResponseMarket
-		maps     []string
+		builder  = query.NewBuilder()
 	)
 
 	//The purpose of this code is to create a new API client for the pbprovider package using the existing gRPC client in the context.
@@ -154,16 +158,16 @@ func (i *Service) GetMarkets(ctx context.Context, req *pbindex.GetRequestMarkets
 	}
 
 	// This if statement checks if the request has a search query. If it does, it adds a WHERE clause to the SQL query that
-	// looks for any base units or quote units that match the search query. The WHERE clause is appended to a list of other
-	// clauses called 'maps'.
+	// looks for any base units or quote units that match the search query, the search is bound as the parameter.
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("where base_unit like %[1]s or quote_unit like %[1]s", "'%"+strings.ToLower(req.GetSearch())+"%'"))
+		like := query.Like(strings.ToLower(req.GetSearch()))
+		builder.Where("(base_unit like ? or quote_unit like ?)", like, like)
 	}
 
 	// This code is checking if the query returns any rows. The row count is stored in the variable response.Count, and the
 	// variable response.GetCount() is then compared to 0. If the count is greater than 0, the code will execute whatever is
 	// inside the if statement.
-	if _ = i.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from pairs %s", strings.Join(maps, " "))).Scan(&response.Count); response.GetCount() > 0 {
+	if _ = i.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from pairs %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
 
 		// This code is used to calculate the offset for a paginated request. The offset is used to indicate where the query
 		// should start, in order to return the desired number of records. If the page is greater than 0, then the offset
@@ -176,7 +180,7 @@ func (i *Service) GetMarkets(ctx context.Context, req *pbindex.GetRequestMarkets
 		// This code is querying the database for specific data from the "pairs" table. The query is using a limit and an
 		// offset to return a specific range, and it is ordered by the "status" column. The "rows" variable will contain the
 		// returned data. If there is an error it will be handled in the "if err" statement and the response will be returned with the error.
-		rows, err := i.Context.Db.Query(fmt.Sprintf("select id, base_unit, quote_unit, price, status from pairs %s order by status desc limit %d offset %d", strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := i.Context.Db.Query(fmt.Sprintf("select id, base_unit, quote_unit, price, status from pairs %s order by status desc limit %d offset %d", builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -240,3 +244,209 @@ func (i *Service) GetMarkets(ctx context.Context, req *pbindex.GetRequestMarkets
 
 	return &response, nil
 }
+
+// GetServerTime - This function returns the current server time in milliseconds together with the receive window bounds. Clients
+// that sign their requests use it to calculate the offset of their local clock, so that the timestamp of a signed
+// request falls into the receive window even if the client clock is skewed.
+func (i *Service) GetServerTime(_ context.Context, _ *pbindex.GetRequestServerTime) (*pbindex.ResponseServerTime, error) {
+
+	// The purpose of this code is to declare a variable called response of type pbindex.ResponseServerTime which will be
+	// returned to the client.
+	var (
+		response pbindex.ResponseServerTime
+	)
+
+	response.ServerTime = time.Now().UTC().UnixMilli()
+	response.RecvWindow = help.RecvWindowDefault
+	response.RecvWindowMax = help.RecvWindowMax
+
+	return &response, nil
+}
+
+// GetEvents - This function returns the calendar of the exchange: the scheduled listings, delistings, maintenance windows and
+// airdrops published by the operators, together with the trading halts of the pairs that have a scheduled end. The events
+// are returned in chronological order, and the calendar is also exported in the iCalendar format on request, so that the
+// partners and the bots can prepare for the changes of the state of the exchange.
+func (i *Service) GetEvents(_ context.Context, req *pbindex.GetRequestEvents) (*pbindex.ResponseEvent, error) {
+
+	// The purpose of this code is to declare the response of the function and the events of the iCalendar export.
+	var (
+		response pbindex.ResponseEvent
+		calendar []help.CalendarEvent
+	)
+
+	// This code checks the kind of the requested events, an empty kind returns the events of all the kinds.
+	if len(req.GetKind()) > 0 {
+		if err := types.EventKind(req.GetKind()); err != nil {
+			return &response, err
+		}
+	}
+
+	// The range of the calendar defaults to the events from a week ago to three months ahead.
+	from, to := req.GetFrom(), req.GetTo()
+	if from == 0 {
+		from = time.Now().AddDate(0, 0, -7).Unix()
+	}
+	if to == 0 {
+		to = time.Now().AddDate(0, 3, 0).Unix()
+	}
+
+	// This code queries the published events which overlap the range, an event without an end lasts for its start only.
+	rows, err := i.Context.Db.Query(`select id, kind, title, text, symbol, start_at, end_at from events where status = $1 and ($2 = '' or kind = $2) and start_at < to_timestamp($4) and coalesce(end_at, start_at) >= to_timestamp($3) order by start_at`, true, req.GetKind(), from, to)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item  types.Event
+			start time.Time
+			end   sql.NullTime
+		)
+
+		if err := rows.Scan(&item.Id, &item.Kind, &item.Title, &item.Text, &item.Symbol, &start, &end); err != nil {
+			return &response, err
+		}
+
+		item.Status = true
+		item.StartAt = start.UTC().Format(time.RFC3339)
+		if end.Valid {
+			item.EndAt = end.Time.UTC().Format(time.RFC3339)
+		}
+
+		response.Fields = append(response.Fields, &item)
+		calendar = append(calendar, help.CalendarEvent{Uid: fmt.Sprintf("event-%d", item.GetId()), Summary: item.GetTitle(), Description: item.GetText(), Start: start, End: end.Time})
+	}
+
+	if err := rows.Err(); err != nil {
+		return &response, err
+	}
+
+	// The trading halts with a scheduled end are maintenance windows of the pairs, the halts without an end are not scheduled
+	// and are announced by the events of the halt and the resumption instead.
+	if len(req.GetKind()) == 0 || req.GetKind() == types.EventMaintenance {
+
+		rows, err := i.Context.Db.Query(`select id, base_unit, quote_unit, halt_until from pairs where halted = $1 and halt_until is not null and halt_until >= to_timestamp($2) and now() < to_timestamp($3) order by halt_until`, true, from, to)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				pair types.Pair
+				end  time.Time
+			)
+
+			if err := rows.Scan(&pair.Id, &pair.BaseUnit, &pair.QuoteUnit, &end); err != nil {
+				return &response, err
+			}
+
+			item := types.Event{
+				Kind:    types.EventMaintenance,
+				Title:   fmt.Sprintf("Trading halt %s/%s", strings.ToUpper(pair.GetBaseUnit()), strings.ToUpper(pair.GetQuoteUnit())),
+				Symbol:  pair.GetBaseUnit(),
+				StartAt: time.Now().UTC().Format(time.RFC3339),
+				EndAt:   end.UTC().Format(time.RFC3339),
+				Status:  true,
+			}
+
+			response.Fields = append(response.Fields, &item)
+			calendar = append(calendar, help.CalendarEvent{Uid: fmt.Sprintf("halt-%d-%d", pair.GetId(), end.Unix()), Summary: item.GetTitle(), Start: time.Now(), End: end})
+		}
+
+		if err := rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	// The calendar is exported in the iCalendar format only on request, the format is used for the subscriptions of the calendar applications.
+	if req.GetIcal() {
+		response.Ical = help.Calendar("Exchange events", "envoys", calendar)
+	}
+
+	return &response, nil
+}
+
+// GetStatus - This function returns the status page of the exchange: the health of every component reported by its heartbeat, the
+// last block scanned of every chain, and the incidents of the operators that are not resolved yet. The component that has
+// not reported for longer than its heartbeat timeout is shown as down, since a stopped scanner or processor can not report
+// itself, and the status of the whole exchange is the worst status of its components and of its open incidents.
+func (i *Service) GetStatus(_ context.Context, _ *pbindex.GetRequestStatus) (*pbindex.ResponseStatus, error) {
+
+	// The purpose of this code is to declare the response of the function and the order of the statuses from the best to the worst.
+	var (
+		response pbindex.ResponseStatus
+		severity = map[string]int{types.ComponentOperational: 0, types.ComponentDegraded: 1, types.ComponentDown: 2}
+	)
+	response.Status = types.ComponentOperational
+
+	rows, err := i.Context.Db.Query(`select name, kind, status, detail, block, heartbeat_at from components order by kind, name`)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item      types.Component
+			heartbeat time.Time
+		)
+
+		if err := rows.Scan(&item.Name, &item.Kind, &item.Status, &item.Detail, &item.Block, &heartbeat); err != nil {
+			return &response, err
+		}
+
+		if time.Since(heartbeat) > heartbeatTimeout {
+			item.Status, item.Detail = types.ComponentDown, fmt.Sprintf("no heartbeat since %v", heartbeat.UTC().Format(time.RFC3339))
+		}
+		item.HeartbeatAt = heartbeat.UTC().Format(time.RFC3339)
+
+		if severity[item.GetStatus()] > severity[response.GetStatus()] {
+			response.Status = item.GetStatus()
+		}
+
+		response.Components = append(response.Components, &item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return &response, err
+	}
+
+	// The incidents are shown to the users until the operators resolve them, the latest ones first.
+	incidents, err := i.Context.Db.Query(`select id, component, severity, title, text, create_at from incidents where status = $1 order by create_at desc`, true)
+	if err != nil {
+		return &response, err
+	}
+	defer incidents.Close()
+
+	for incidents.Next() {
+
+		var (
+			item   types.Incident
+			create time.Time
+		)
+
+		if err := incidents.Scan(&item.Id, &item.Component, &item.Severity, &item.Title, &item.Text, &create); err != nil {
+			return &response, err
+		}
+		item.Status = true
+		item.CreateAt = create.UTC().Format(time.RFC3339)
+
+		if severity[item.GetSeverity()] > severity[response.GetStatus()] {
+			response.Status = item.GetSeverity()
+		}
+
+		response.Incidents = append(response.Incidents, &item)
+	}
+
+	if err := incidents.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
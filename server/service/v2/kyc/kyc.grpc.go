@@ -20,12 +20,7 @@ func (s *Service) SetCanceled(ctx context.Context, _ *pbkyc.SetRequestCanceled)
 		response pbkyc.ResponseCanceled
 	)
 
-	// This code checks for a valid authentication and if there is an error with the authentication it will return an error
-	// response. It is used to ensure that only authorized users can access the requested resource.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code is updating the KYC (Know Your Customer) table in a database. The code is updating the secret, type, and
 	// process fields of the KYC table, where the user_id field matches the one given in the parameters. The purpose of
@@ -114,12 +109,7 @@ func (s *Service) SetProcess(ctx context.Context, req *pbkyc.SetRequestProcess)
 		response pbkyc.ResponseProcess
 	)
 
-	// This code checks for a valid authentication and if there is an error with the authentication it will return an error
-	// response. It is used to ensure that only authorized users can access the requested resource.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// The purpose of this code is to create a Service object that uses the context stored in the variable e. The Service
 	// object is then assigned to the variable migrate.
@@ -2,30 +2,88 @@ package provider
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
+	"github.com/cryptogateway/backend-envoys/assets/common/metrics"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"github.com/cryptogateway/backend-envoys/assets/common/report"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
 	"google.golang.org/grpc/status"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
+	"time"
+)
+
+// warningRatio - The share of a limit after which the user is warned that the limit is approaching, the warning is given before
+// the hard rejection, so that active traders are not surprised by it.
+const warningRatio = 0.8
+
+// maxAddresses - The maximum number of the deposit addresses that a user can generate on a platform, the archived addresses are
+// counted too, since the deposits to them are still credited and the scanners keep looking for them.
+const maxAddresses = 10
+
+// rollingScript - The script updates the minute bucket of the rolling 24h statistics of a pair atomically, so that the concurrent
+// trades of the same pair do not overwrite each other. A bucket is stored as "open,high,low,close,base,quote,count", the
+// bucket that has just left the window is removed, and the key expires if the pair is not traded for a whole day.
+var rollingScript = redis.NewScript(`
+local price, quantity = tonumber(ARGV[2]), tonumber(ARGV[3])
+local bucket = redis.call('HGET', KEYS[1], ARGV[1])
+local open, high, low, base, quote, count = price, price, price, 0, 0, 0
+if bucket then
+	local v = {}
+	for s in string.gmatch(bucket, '[^,]+') do v[#v + 1] = tonumber(s) end
+	open, high, low, base, quote, count = v[1], math.max(v[2], price), math.min(v[3], price), v[5], v[6], v[7]
+end
+base, quote, count = base + quantity, quote + price * quantity, count + 1
+redis.call('HSET', KEYS[1], ARGV[1], string.format('%.17g,%.17g,%.17g,%.17g,%.17g,%.17g,%d', open, high, low, price, base, quote, count))
+redis.call('HDEL', KEYS[1], ARGV[4])
+redis.call('EXPIRE', KEYS[1], 90000)
+return count
+`)
+
+var (
+	// placed and matched - The metrics of the trading: the orders placed by their types and sides, and the trades matched by the
+	// types of the orders.
+	placed  = metrics.NewCounter("orders_placed_total", "The number of the placed orders.", "type", "assigning")
+	matched = metrics.NewCounter("trades_matched_total", "The number of the matched trades.", "type")
 )
 
 type Service struct {
 	Context *assets.Context
 }
 
-// Initialization - The code initializes a Service object and runs six concurrent functions: chain(), price(), market().
+// Initialization - The code initializes a Service object and runs five concurrent functions: chain(), price(), market(), snapshot(), backfill().
 func (a *Service) Initialization() {
 	go a.chain()
 	go a.price()
 	go a.market()
+	go a.snapshot()
+	go a.backfill()
+	go a.support()
+	go a.report()
+	go a.breaker()
+	go a.delisting()
+	go a.rule()
+	go a.session()
+	go a.reconciliation()
+	go a.partition()
+	go a.heartbeat()
+	go a.surveillance()
+	go a.paper()
 }
 
 // queryRatio - This function is used to calculate the ratio of a given base and quote. It takes in two strings, base and quote, as
@@ -58,32 +116,297 @@ func (a *Service) queryRatio(base, quote string) (ratio float64, ok bool) {
 // error occurs, the ok boolean is returned as false, otherwise it is returned as true.
 func (a *Service) queryPrice(base, quote string) (price float64, ok bool) {
 
+	// The price is read with the prepared statement, it is one of the hot path queries of the matching.
+	statement, err := a.Context.Statement("select price from pairs where base_unit = $1 and quote_unit = $2")
+	if err != nil {
+		return price, ok
+	}
+
 	// This code is used to query and retrieve a price from a database. The "if err" statement is used to check for any
 	// errors that may occur during the query and retrieve process. If an error is encountered, the code will return the price and ok.
-	if err := a.Context.Db.QueryRow("select price from pairs where base_unit = $1 and quote_unit = $2", base, quote).Scan(&price); err != nil {
+	if err := statement.QueryRow(base, quote).Scan(&price); err != nil {
 		return price, ok
 	}
 
 	return price, true
 }
 
+// QueryValuation - This function returns the price of the given symbol expressed in the valuation unit (usd). The price is first
+// looked up directly, and then through the reverse pair, if neither of them exists, the symbol is valued at zero.
+func (a *Service) QueryValuation(symbol, unit string) float64 {
+
+	// The valuation unit is the base of the valuation itself, so its price is always equal to one.
+	if symbol == unit {
+		return 1
+	}
+
+	// This code requests the price of the symbol in the valuation unit, the GetPrice function checks both the direct and
+	// the reverse pairs.
+	price, err := a.GetPrice(context.Background(), &pbprovider.GetRequestPrice{BaseUnit: symbol, QuoteUnit: unit})
+	if err != nil {
+		return 0
+	}
+
+	return price.GetPrice()
+}
+
+// queryVersion - This function returns the version of the candles of the given pair, the version is increased with every new tick
+// of the pair and is a part of the cache keys of the candles.
+func (a *Service) queryVersion(base, quote string) int64 {
+	version, _ := a.Context.RedisClient.Get(context.Background(), fmt.Sprintf("ticker:version:%v:%v", base, quote)).Int64()
+	return version
+}
+
+// writeVersion - This function increases the version of the candles of the given pair, so that the candles cached under the previous
+// version are not used anymore, they expire on their own.
+func (a *Service) writeVersion(base, quote string) {
+	a.Context.RedisClient.Incr(context.Background(), fmt.Sprintf("ticker:version:%v:%v", base, quote))
+}
+
+// queryCache - This function reads the value stored in redis under the given key into the given structure, it returns false if the
+// value does not exist or cannot be decoded.
+func (a *Service) queryCache(key string, value interface{}) bool {
+
+	// This code reads the raw value from redis, a missing key is reported as an error by the client.
+	data, err := a.Context.RedisClient.Get(context.Background(), key).Bytes()
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(data, value) == nil
+}
+
+// writeCache - This function stores the given structure in redis under the given key for the given time, the errors are only
+// logged, since the cache is not required for the request to succeed.
+func (a *Service) writeCache(key string, value interface{}, expiration time.Duration) {
+
+	// The structure is encoded into json, the same format in which it is read back by the queryCache function.
+	data, err := json.Marshal(value)
+	if a.Context.Debug(err) {
+		return
+	}
+
+	a.Context.Debug(a.Context.RedisClient.Set(context.Background(), key, data, expiration).Err())
+}
+
+// queryRolling - This function returns the rolling 24h statistics of the given pair, aggregated from the minute buckets written by the
+// writeRolling function. Only the buckets of the last 24 hours are taken, so the statistics are always calculated over the
+// rolling window, without scanning the trades table.
+func (a *Service) queryRolling(base, quote string) (*types.Ticker24H, error) {
+
+	var (
+		ticker = types.Ticker24H{BaseUnit: base, QuoteUnit: quote, Time: time.Now().Unix()}
+		first  int64
+		last   int64
+	)
+
+	// This code reads all the minute buckets of the pair in a single request, a missing key means that the pair has not
+	// been traded for a whole day.
+	buckets, err := a.Context.RedisClient.HGetAll(context.Background(), fmt.Sprintf("ticker:24h:%v:%v", base, quote)).Result()
+	if err != nil {
+		return &ticker, err
+	}
+
+	// The purpose of this code is to skip the buckets that are older than the window, they are removed on the next trades.
+	since := time.Now().Add(-24*time.Hour).Unix() / 60
+	for key, bucket := range buckets {
+
+		var (
+			minute                                   int64
+			open, high, low, close, volume, turnover float64
+			count                                    int64
+		)
+
+		if minute, err = strconv.ParseInt(key, 10, 64); err != nil || minute <= since {
+			continue
+		}
+
+		if _, err := fmt.Sscanf(bucket, "%g,%g,%g,%g,%g,%g,%d", &open, &high, &low, &close, &volume, &turnover, &count); a.Context.Debug(err) {
+			continue
+		}
+
+		// The open price is taken from the oldest bucket of the window and the last price from the newest one, the highs and
+		// lows are compared, and the volumes are summed up.
+		if first == 0 || minute < first {
+			first, ticker.Open = minute, open
+		}
+		if last == 0 || minute > last {
+			last, ticker.Last = minute, close
+		}
+		if ticker.High == 0 || high > ticker.High {
+			ticker.High = high
+		}
+		if ticker.Low == 0 || low < ticker.Low {
+			ticker.Low = low
+		}
+
+		ticker.BaseVolume = decimal.New(ticker.BaseVolume).Add(volume).Float()
+		ticker.QuoteVolume = decimal.New(ticker.QuoteVolume).Add(turnover).Float()
+		ticker.Count += count
+	}
+
+	// This code calculates the price change in percent over the window and the average price weighted by the volume.
+	if ticker.Open > 0 {
+		ticker.ChangePercent = decimal.New(ticker.Last).Sub(ticker.Open).Div(ticker.Open).Mul(100).Round(2).Float()
+	}
+	if ticker.BaseVolume > 0 {
+		ticker.WeightedPrice = decimal.New(ticker.QuoteVolume).Div(ticker.BaseVolume).Round(8).Float()
+	}
+
+	return &ticker, nil
+}
+
+// queryMarkets - This function builds the summary of all the active pairs: the last price, the 24h change and volume, the best bid
+// and ask of the order book, and the spark-line made of the hourly closes of the last day. The summary is built by the
+// price replay and cached, so the clients get all the markets in one call.
+func (a *Service) queryMarkets() (*pbprovider.ResponseMarket, error) {
+
+	var (
+		response = pbprovider.ResponseMarket{Time: time.Now().Unix()}
+	)
+
+	// This code queries all the active pairs, the summary is collected for each of them.
+	rows, err := a.Context.Db.Query(`select base_unit, quote_unit, price, type from pairs where status = $1 order by id`, true)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Summary
+		)
+
+		if err := rows.Scan(&item.BaseUnit, &item.QuoteUnit, &item.Price, &item.Type); err != nil {
+			return &response, err
+		}
+
+		// The 24h change and volumes are taken from the rolling statistics of the pair, the last price of the trades takes
+		// precedence over the price of the pair.
+		if rolling, err := a.queryRolling(item.GetBaseUnit(), item.GetQuoteUnit()); err == nil {
+			if rolling.GetLast() > 0 {
+				item.Price = rolling.GetLast()
+			}
+			item.ChangePercent = rolling.GetChangePercent()
+			item.Volume = rolling.GetBaseVolume()
+			item.QuoteVolume = rolling.GetQuoteVolume()
+		}
+
+		// This code requests the best bid and the best ask of the order book, that is the highest pending buy price and the
+		// lowest pending sell price of the pair.
+		_ = a.Context.Db.QueryRow("select coalesce(max(price), 0) from orders where assigning = $1 and base_unit = $2 and quote_unit = $3 and status = $4 and type = $5", types.AssigningBuy, item.GetBaseUnit(), item.GetQuoteUnit(), types.StatusPending, item.GetType()).Scan(&item.Bid)
+		_ = a.Context.Db.QueryRow("select coalesce(min(price), 0) from orders where assigning = $1 and base_unit = $2 and quote_unit = $3 and status = $4 and type = $5", types.AssigningSell, item.GetBaseUnit(), item.GetQuoteUnit(), types.StatusPending, item.GetType()).Scan(&item.Ask)
+
+		// The spark-line is made of the hourly closes of the last day, from the oldest to the newest.
+		ticker, err := a.GetTicker(context.Background(), &pbprovider.GetRequestTicker{BaseUnit: item.GetBaseUnit(), QuoteUnit: item.GetQuoteUnit(), Resolution: "1h", Limit: 24})
+		if err != nil {
+			return &response, err
+		}
+		for i := len(ticker.GetFields()) - 1; i >= 0; i-- {
+			item.Spark = append(item.Spark, ticker.Fields[i].GetClose())
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, rows.Err()
+}
+
+// queryDepth - This function returns the depth of the order book of the pair: the pending orders of each side summed by their
+// prices into the levels, the best levels first and at most the limit of them per side. The positive step groups the
+// prices into the levels of the step, the bids are rounded down to the step and the asks up, so that a level never
+// looks better than the orders in it. The grouping is done by the database in the exact numbers, so the thin clients
+// do not pull the thousands of the raw levels.
+func (a *Service) queryDepth(base, quote, _type string, step float64, limit int) (*types.Depth, error) {
+
+	var (
+		depth = types.Depth{BaseUnit: base, QuoteUnit: quote, Step: step, Time: time.Now().Unix()}
+	)
+
+	for _, side := range []struct {
+		assigning, order, round string
+		levels                  *[]*types.Level
+	}{
+		{assigning: types.AssigningBuy, order: "desc", round: "floor", levels: &depth.Bids},
+		{assigning: types.AssigningSell, order: "asc", round: "ceil", levels: &depth.Asks},
+	} {
+
+		var (
+			builder = query.NewBuilder(side.assigning, base, quote, types.StatusPending, _type)
+			level   = "price"
+		)
+
+		if step > 0 {
+			level = fmt.Sprintf("(%[1]s(price::numeric / %[2]s::numeric) * %[2]s::numeric)::float8", side.round, builder.Bind(step))
+		}
+
+		rows, err := a.Context.Reader().Query(fmt.Sprintf("select %[1]s as level, sum(value), count(*) from orders where assigning = $1 and base_unit = $2 and quote_unit = $3 and status = $4 and type = $5 group by level order by level %[2]s limit %[3]s", level, side.order, builder.Bind(limit)), builder.Params()...)
+		if err != nil {
+			return &depth, err
+		}
+
+		for rows.Next() {
+
+			var (
+				item types.Level
+			)
+
+			if err := rows.Scan(&item.Price, &item.Quantity, &item.Count); err != nil {
+				_ = rows.Close()
+				return &depth, err
+			}
+
+			*side.levels = append(*side.levels, &item)
+		}
+
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return &depth, err
+		}
+	}
+
+	return &depth, nil
+}
+
+// writeMarkets - This function rebuilds the summary of all the markets and stores it in the cache, it is called by the price replay
+// every time the prices of the pairs are updated.
+func (a *Service) writeMarkets() {
+
+	markets, err := a.queryMarkets()
+	if a.Context.Debug(err) {
+		return
+	}
+
+	// The summary is kept a little longer than the interval of the price replay, so that it does not expire between two updates.
+	a.writeCache("markets:summary", markets, 2*time.Minute)
+}
+
+// writeRolling - This function adds the trade to the minute bucket of the rolling 24h statistics of the pair, the statistics are
+// maintained incrementally with every trade, so they never have to be recalculated from the whole history.
+func (a *Service) writeRolling(base, quote string, price, quantity float64) error {
+
+	// The minute of the trade is the field of the bucket, and the minute that has left the window is removed in the same step.
+	minute := time.Now().Unix() / 60
+	return rollingScript.Run(context.Background(), a.Context.RedisClient, []string{fmt.Sprintf("ticker:24h:%v:%v", base, quote)}, minute, price, quantity, minute-24*60).Err()
+}
+
 // querySum - The purpose of this code is to calculate the final value of a given value after subtracting fees. It queries the
 // database for the corresponding currency's fees_trade and fees_discount columns, and checks the status of an order
-// based on an id. If the order is a maker order, the discount is subtracted from the fees. Finally, the actual value
-// after subtracting fees and the rounded value after subtracting fees are returned.
-func (a *Service) querySum(id int64, symbol string, value float64) (b, f float64, m bool, err error) {
+// based on an id. If the order is a maker order, the discount is subtracted from the fees. Finally, the value after
+// subtracting the fee and the fee are returned, both as the decimals.
+func (a *Service) querySum(id int64, symbol string, value *decimal.Float) (b, f *decimal.Float, m bool, err error) {
 
-	// The purpose of this code is to declare three variables of different types: d is a float64, m is a boolean, and s is a
-	// types.Status. This can be used to assign values to these variables and use them in your program.
+	// The purpose of this code is to declare the rates of the fee and of the discount of the maker, read as the decimal
+	// strings of their numeric columns, and the status of the order.
 	var (
-		d float64
-		s string
+		fees, discount, s string
 	)
 
 	// This code is used to query a database for a particular record associated with the given symbol. It then scans the
 	// result and stores the values of the fees_trade and fees_discount columns in the variables fees and discount
 	// respectively. If an error occurs during the query, it returns the balance and fees variables.
-	if err := a.Context.Db.QueryRow("select fees_trade, fees_discount from assets where symbol = $1", symbol).Scan(&f, &d); err != nil {
+	if err := a.Context.Db.QueryRow("select fees_trade::text, fees_discount::text from assets where symbol = $1", symbol).Scan(&fees, &discount); err != nil {
 		return b, f, m, err
 	}
 
@@ -94,20 +417,22 @@ func (a *Service) querySum(id int64, symbol string, value float64) (b, f float64
 	}
 
 	// This if statement assigns the boolean value true to the variable m if the variable s is equal to the constant
-	// types.Status_PENDING. This can be used to evaluate a condition or determine if a specific value is present in a given set.
+	// types.StatusPending. This can be used to evaluate a condition or determine if a specific value is present in a given set.
 	if s == types.StatusPending {
 		m = true
 	}
 
-	// This code is checking if the variable "maker" is true, and if it is, it is subtracting the value of "discount" from
-	// "fees" and storing the result in "fees" as a float. This is likely being done to calculate a discounted fee for a maker order.
+	// The rate of the maker is the rate of the fee less the discount of the maker.
+	rate := decimal.New(fees)
 	if m {
-		f = decimal.New(f).Sub(d).Float()
+		rate = rate.Sub(discount)
 	}
 
-	// This code is used to calculate the final value of a given value after subtracting fees. The two return values
-	// represent the actual value after subtracting fees and the rounded value after subtracting fees.
-	return decimal.New(value).Sub(decimal.New(decimal.New(value).Mul(f).Float()).Div(100).Float()).Float(), decimal.New(value).Sub(decimal.New(value).Sub(decimal.New(decimal.New(value).Mul(f).Float()).Div(100).Float()).Float()).Float(), m, nil
+	// The fee is computed in decimals and is never converted to the float, so the value credited and the fee charged add
+	// up to the value of the trade exactly.
+	f = value.Mul(rate).Div(100)
+
+	return value.Sub(f), f, m, nil
 }
 
 // queryMarket - This function is used to get the market price for a given base and quote currency. It takes in the base, quote,
@@ -177,7 +502,7 @@ func (a *Service) queryQuantity(assigning string, quantity, price float64, cross
 	if cross {
 
 		// The purpose of this code is to calculate the quantity of an item by dividing it by its price. This switch statement
-		// checks the assigning value to make sure it is set to "BUY", and then uses the decimal.New() method to divide the
+		// checks the assigning value to make sure it is set to "buy", and then uses the decimal.New() method to divide the
 		// quantity by the price and convert it to a float.
 		switch assigning {
 		case types.AssigningBuy:
@@ -189,7 +514,7 @@ func (a *Service) queryQuantity(assigning string, quantity, price float64, cross
 	} else {
 
 		// This switch statement is used to determine the quantity of a purchase. In this case, if the assigning variable is
-		// set to types.Assigning_BUY, then the quantity will be multiplied by the price to determine the total cost of the
+		// set to types.AssigningBuy, then the quantity will be multiplied by the price to determine the total cost of the
 		// purchase.
 		switch assigning {
 		case types.AssigningSell:
@@ -296,6 +621,91 @@ func (a *Service) queryValidatePair(base, quote, _type string) error {
 	return nil
 }
 
+// queryBand - This function protects the pair from the orders placed far away from the market. The order is rejected if the
+// trading of the pair is halted by the circuit breaker, or if its price deviates from the reference price by more than the
+// band of the pair in percent. The reference price is the last trade price, the index price of the pair is used before
+// the first trade. A band equal to zero disables the check.
+func (a *Service) queryBand(order *types.Order) error {
+
+	var (
+		pair types.Pair
+	)
+
+	// The cross orders are placed on the spot pairs, so the configuration of the spot pair is used for them.
+	_type := order.GetType()
+	if _type == types.TypeCross {
+		_type = types.TypeSpot
+	}
+
+	if err := a.Context.Db.QueryRow("select price, band, halted from pairs where base_unit = $1 and quote_unit = $2 and type = $3", order.GetBaseUnit(), order.GetQuoteUnit(), _type).Scan(&pair.Price, &pair.Band, &pair.Halted); err != nil {
+		return status.Errorf(11585, "this pair %v-%v does not exist", order.GetBaseUnit(), order.GetQuoteUnit())
+	}
+
+	// This code rejects the orders of a halted pair, the trading is resumed after the cool-down or by the operators.
+	if pair.GetHalted() {
+		return status.Errorf(11590, "trading of the pair %v-%v is temporarily halted", order.GetBaseUnit(), order.GetQuoteUnit())
+	}
+
+	if pair.GetBand() <= 0 {
+		return nil
+	}
+
+	// The last trade price takes precedence over the index price of the pair.
+	if rolling, err := a.queryRolling(order.GetBaseUnit(), order.GetQuoteUnit()); err == nil && rolling.GetLast() > 0 {
+		pair.Price = rolling.GetLast()
+	}
+
+	if pair.GetPrice() <= 0 {
+		return nil
+	}
+
+	// This code calculates the deviation of the order price from the reference price in percent and compares it with the band.
+	if deviation := math.Abs(decimal.New(order.GetPrice()).Sub(pair.GetPrice()).Div(pair.GetPrice()).Mul(100).Float()); deviation > pair.GetBand() {
+		return status.Errorf(11591, "the price %v deviates from the reference price %v by more than %v%%", order.GetPrice(), pair.GetPrice(), pair.GetBand())
+	}
+
+	return nil
+}
+
+// queryFilter - This function checks the order against the trading filters of the pair: the value of the order in the quote unit
+// must reach the minimum notional, and the price and the quantity of a limit order must follow the price step (tick
+// size) and the quantity step (lot size). The quantity of a market order is derived from the book, so the steps are not
+// applied to it. A filter equal to zero is disabled.
+func (a *Service) queryFilter(order *types.Order) error {
+
+	var (
+		pair types.Pair
+	)
+
+	// The cross orders are placed on the spot pairs, so the configuration of the spot pair is used for them.
+	_type := order.GetType()
+	if _type == types.TypeCross {
+		_type = types.TypeSpot
+	}
+
+	if err := a.Context.Db.QueryRow("select min_notional, price_step, quantity_step from pairs where base_unit = $1 and quote_unit = $2 and type = $3", order.GetBaseUnit(), order.GetQuoteUnit(), _type).Scan(&pair.MinNotional, &pair.PriceStep, &pair.QuantityStep); err != nil {
+		return status.Errorf(11585, "this pair %v-%v does not exist", order.GetBaseUnit(), order.GetQuoteUnit())
+	}
+
+	if notional := decimal.New(order.GetQuantity()).Mul(order.GetPrice()).Float(); notional < pair.GetMinNotional() {
+		return status.Errorf(11625, "the value of the order %v is below the minimum notional %v %v", notional, pair.GetMinNotional(), order.GetQuoteUnit())
+	}
+
+	if order.GetTrading() != types.TradingLimit {
+		return nil
+	}
+
+	if !help.Step(order.GetPrice(), pair.GetPriceStep()) {
+		return status.Errorf(11626, "the price %v must be a multiple of the price step %v", order.GetPrice(), pair.GetPriceStep())
+	}
+
+	if !help.Step(order.GetQuantity(), pair.GetQuantityStep()) {
+		return status.Errorf(11627, "the quantity %v must be a multiple of the quantity step %v", order.GetQuantity(), pair.GetQuantityStep())
+	}
+
+	return nil
+}
+
 // queryOrder - This function is used to retrieve an order from a database by its ID. It takes an int64 (id) as a parameter and
 // returns a pointer to a "types.Order" type. It uses the "QueryRow" method of the database to scan the selected row
 // into the "order" variable and then returns the pointer to the order.
@@ -305,10 +715,16 @@ func (a *Service) queryOrder(id int64) *types.Order {
 		order types.Order
 	)
 
+	// The order is read with the prepared statement, it is read for every trade of the matching.
+	statement, err := a.Context.Statement("select id, uid, value, quantity, price, assigning, user_id, base_unit, quote_unit, status, create_at from orders where id = $1")
+	if err != nil {
+		return &order
+	}
+
 	// This code is used to query a database for a single row of data matching the specified criteria (in this case, the "id
 	// = $1" condition) and then assign the returned values to the specified variables (in this case, the fields of the
 	// "order" struct). This allows the program to retrieve data from the database and store it in a convenient and organized format.
-	_ = a.Context.Db.QueryRow("select id, value, quantity, price, assigning, user_id, base_unit, quote_unit, status, create_at from orders where id = $1", id).Scan(&order.Id, &order.Value, &order.Quantity, &order.Price, &order.Assigning, &order.UserId, &order.BaseUnit, &order.QuoteUnit, &order.Status, &order.CreateAt)
+	_ = statement.QueryRow(id).Scan(&order.Id, &order.Uid, &order.Value, &order.Quantity, &order.Price, &order.Assigning, &order.UserId, &order.BaseUnit, &order.QuoteUnit, &order.Status, &order.CreateAt)
 	return &order
 }
 
@@ -351,560 +767,2416 @@ func (a *Service) writeAsset(symbol, _type string, userId int64, error bool) err
 	return nil
 }
 
-// writeOrder - This function is used to set an order in the database. It takes in a pointer to a types.Order which contains the
-// order's details, and inserts the data into the 'orders' table. It then returns the id of the newly created order and any potential errors.
-func (a *Service) writeOrder(order *types.Order) (id int64, err error) {
+// WriteAsset - This function creates the balance of the asset for the user when it does not exist yet, it is used when the
+// balance of a user is credited by another service, for example by an internal transfer from another user.
+func (a *Service) WriteAsset(symbol, _type string, userId int64) error {
+	return a.writeAsset(symbol, _type, userId, false)
+}
+
+// writeOrder - This function is used to set an order in the database. It takes in the transaction the order is written by and a
+// pointer to a types.Order which contains the order's details, and inserts the data into the 'orders' table. It then returns the id of the newly created order and any potential errors.
+func (a *Service) writeOrder(tx *sql.Tx, order *types.Order) (id int64, err error) {
+
+	if len(order.GetStatus()) == 0 {
+		order.Status = types.StatusPending
+	}
 
-	if err := a.Context.Db.QueryRow("insert into orders (assigning, base_unit, quote_unit, price, value, quantity, user_id, type, trading) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id", order.GetAssigning(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetPrice(), order.GetQuantity(), order.GetValue(), order.GetUserId(), order.GetType(), order.GetTrading()).Scan(&id); err != nil {
+	if err := tx.QueryRow("insert into orders (assigning, base_unit, quote_unit, price, value, quantity, user_id, type, trading, status, client_id) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id", order.GetAssigning(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetPrice(), order.GetQuantity(), order.GetValue(), order.GetUserId(), order.GetType(), order.GetTrading(), order.GetStatus(), order.GetClientId()).Scan(&id); err != nil {
 		return id, err
 	}
 
 	return id, nil
 }
 
-// writeTrade - The purpose of this code is to set a trade by converting a given value to a decimal number multiplied by a given
-// price, get the sum of a given order, symbol, and value, insert the data into a database, update the "fees_charges"
-// column in the "currencies" table in a database, and publish a particular order to an exchange.
-func (a *Service) writeTrade(id int64, symbol string, value, price float64, convert bool) (float64, error) {
+// writeJournal - This function appends the command that changed the book to the journal of the book: the kind of the command, the
+// order it was applied to, the resting order of a match, and the price and the value of the order or of the match. The
+// journal is append-only, the state of the orders and their fills is rebuilt from it by the ReplayJournal function.
+func (a *Service) writeJournal(kind string, order *types.Order, counter int64, price, value float64) error {
 
-	// The purpose of this code is to retrieve an order from a database, given its ID. The variable 'order' will store the
-	// order object that is returned from the queryOrder() method.
-	order := a.queryOrder(id)
-	order.Value = value
+	// The command is written with the prepared statement, it is written for every placement and every trade of the matching.
+	statement, err := a.Context.Statement(`insert into journal (kind, order_id, counter_id, assigning, base_unit, quote_unit, type, user_id, price, value, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)
+	if err != nil {
+		return err
+	}
 
-	// This code is used to convert a given value to a decimal number multiplied by a given price. The result is then stored
-	// as a floating point number. This is likely used for some kind of financial calculation or to convert a given value to
-	// a currency amount.
-	if convert {
-		value = decimal.New(value).Mul(price).Float()
+	if _, err := statement.Exec(kind, order.GetId(), counter, order.GetAssigning(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetType(), order.GetUserId(), price, value, order.GetStatus()); err != nil {
+		return err
 	}
 
-	// This code is attempting to get the sum of a given order, symbol and value. The variables s and f are used to store
-	// the sum and any error encountered, respectively. The if statement checks for any errors that may have occurred and
-	// returns 0 and the error if one is encountered.
-	s, f, maker, err := a.querySum(id, symbol, value)
-	if err != nil {
-		return 0, err
+	return nil
+}
+
+// writePlace - This function places the order: it validates the order against the delisting, the circuit breaker, the filters
+// of the pair and the balance of the user, writes the order with the hold of its funds and matches the order against the
+// book. The order and its hold are written by one transaction which locks the balance, as by the writeReplace function,
+// so that an order which can not be covered by the available balance never reaches the book. The funds held are returned,
+// in the quote unit for the buy orders and in the base unit for the sell orders. It is used by the orders of the users
+// and by the automatic rules of the users alike. The placement is recorded in the trace of the context by its phases:
+// the validation, the hold of the funds and the matching.
+func (a *Service) writePlace(ctx context.Context, order *types.Order) (quantity float64, err error) {
+
+	var (
+		balance, held   float64
+		symbol, receive string
+	)
+
+	ctx, span := trace.Start(ctx, "order.place", trace.KindInternal, trace.String("order.pair", order.GetBaseUnit()+"/"+order.GetQuoteUnit()), trace.String("order.assigning", order.GetAssigning()), trace.String("order.type", order.GetType()), trace.String("order.trading", order.GetTrading()))
+	defer func() { span.Set(trace.Int("order.id", order.GetId())); span.End(err) }()
+
+	_, validate := trace.Start(ctx, "order.validate", trace.KindInternal)
+	defer func() { validate.End(err) }()
+
+	// The switch statement is used to evaluate the value of the expression "order.GetAssigning()", the funds of the buy
+	// order are held in the quote unit and those of the sell order in the base unit, the order receives the other unit.
+	switch order.GetAssigning() {
+	case types.AssigningBuy:
+		symbol, receive = order.GetQuoteUnit(), order.GetBaseUnit()
+	case types.AssigningSell:
+		symbol, receive = order.GetBaseUnit(), order.GetQuoteUnit()
+	default:
+		return quantity, status.Error(11588, "invalid assigning trade position")
 	}
 
-	// This code is used to calculate the fee for an order based on the assigned type. If the order is assigned to be a
-	// SELL, the fee is calculated by dividing the fee (f) by the price. If the order is assigned to be something else, the
-	// fee is simply set to be f.
-	if order.GetAssigning() == types.AssigningSell {
-		order.Fees = decimal.New(f).Div(price).Float()
-	} else {
-		order.Fees = f
+	// This code rejects the orders on the pairs of the assets which are being delisted.
+	if err := a.queryListed(order); err != nil {
+		return quantity, err
 	}
 
-	// This code is used to insert data into the "transfers" table in a database using the parameters provided in the array
-	// "param". The code first checks for any errors in the insertion process, and if there are any, it will return an error.
-	if _, err := a.Context.Db.Exec(`insert into trades (order_id, assigning, user_id, base_unit, quote_unit, quantity, fees, price, maker) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`, order.GetId(), order.GetAssigning(), order.GetUserId(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetValue(), order.GetFees(), price, maker); err != nil {
-		return 0, err
+	// This code checks the order against the circuit breaker of the pair, the orders of a halted pair and the orders priced
+	// outside the band around the reference price are rejected.
+	if err := a.queryBand(order); err != nil {
+		return quantity, err
 	}
 
-	// This statement is checking to see if the value of the parameter at index i in the param array is greater than 0. If
-	// it is, then the code within the if statement will be executed. This is likely being used to check if a fee is
-	// associated with the parameter at index i.
-	if f > 0 {
+	// This code checks the order against the minimum notional and the step sizes of the pair.
+	if err := a.queryFilter(order); err != nil {
+		return quantity, err
+	}
 
-		// This code is updating the "fees_charges" column in the "currencies" table in a database. The "symbol" and
-		// "fee" are parameters that are passed into the statement. If an error occurs during the
-		// execution of the statement, the function will return the error.
-		if _, err := a.Context.Db.Exec("update assets set fees_charges = fees_charges + $2 where symbol = $1;", symbol, f); err != nil {
-			return 0, err
-		}
+	// The orders on the stocks are accepted within the trading hours of the market zone of the stock, outside of them the
+	// orders are either rejected or queued until the opening of the market.
+	queue, err := a.queryHours(order)
+	if err != nil {
+		return quantity, err
 	}
 
-	// The purpose of the code snippet is to publish a particular order to an exchange with the routing key "order/status".
-	// The if statement checks for any errors encountered while publishing the order, and returns an error if one occurs.
-	if err := a.Context.Publish(a.queryOrder(order.GetId()), "exchange", "order/status"); err != nil {
-		return 0, err
+	if queue {
+		order.Status = types.StatusQueue
 	}
 
-	return s, nil
-}
+	// This code is checking for an error in the queryValidateOrder() function and if one is found, it returns an error response
+	// and calls the Context.Error() method with the error. The quantity variable is used to store the result of queryValidateOrder(), which is used to complete the order.
+	quantity, err = a.queryValidateOrder(order)
+	if err != nil {
+		return quantity, err
+	}
+	validate.End(nil)
 
-// QueryPair - This function is used to get a specific pair from the database, based on the id and status passed as arguments. The
-// function returns a pointer to a 'types.Pair' struct and an error if any. It prepares a query to select the specified
-// pair from the database, based on the given id and status. It then scans the results and stores them in the struct, and
-// finally returns the struct and an error if any.
-func (a *Service) QueryPair(id int64, _type string, status bool) (*types.Pair, error) {
+	// The balance of the unit the order receives is created when the user does not have it yet.
+	if err := a.writeAsset(receive, order.GetType(), order.GetUserId(), false); err != nil {
+		return quantity, err
+	}
 
-	var (
-		chain types.Pair
-		maps  []string
-	)
+	// The hold of the funds is recorded until the order reaches the book, the matching is recorded by a span of its own.
+	_, hold := trace.Start(ctx, "order.hold", trace.KindInternal)
+	defer func() { hold.End(err) }()
 
-	// The purpose of this code is to append a string to a list of maps if a certain condition is true. In this case, if the
-	// variable "status" is true, the string "and status = %v" with "true" as the placeholder value is added to the list of maps.
-	if status {
-		maps = append(maps, fmt.Sprintf("and status = %v", true))
+	tx, err := a.Context.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return quantity, err
 	}
+	defer tx.Rollback()
 
-	// Check if type is not empty then append a query to maps with the value of.
-	if len(_type) > 0 {
-		maps = append(maps, fmt.Sprintf("and type = '%v'", _type))
+	// The balance is locked while the holds are summed up, as by the WriteHold function, the order is written only when its
+	// funds can be held, and it is rolled back together with the hold otherwise.
+	if err := tx.QueryRow("select value from balances where symbol = $1 and user_id = $2 and type = $3 for update", symbol, order.GetUserId(), order.GetType()).Scan(&balance); err != nil && err != sql.ErrNoRows {
+		return quantity, err
 	}
 
-	// This code is used to query a database and retrieve information about a pair with a specified id. The query is formed
-	// using the fmt.Sprintf() function, and it is a combination of a string and the id parameter. The retrieved information
-	// is then assigned to the chain struct. Finally, the code returns the chain struct and an error if it fails.
-	if err := a.Context.Db.QueryRow(fmt.Sprintf("select id, base_unit, quote_unit, price, base_decimal, quote_decimal, status from pairs where id = %[1]d %[2]s", id, strings.Join(maps, " "))).Scan(
-		&chain.Id,
-		&chain.BaseUnit,
-		&chain.QuoteUnit,
-		&chain.Price,
-		&chain.BaseDecimal,
-		&chain.QuoteDecimal,
-		&chain.Status,
-	); err != nil {
-		return &chain, err
+	if err := tx.QueryRow("select coalesce(sum(value), 0) from holds where symbol = $1 and user_id = $2 and type = $3", symbol, order.GetUserId(), order.GetType()).Scan(&held); err != nil {
+		return quantity, err
 	}
 
-	return &chain, nil
-}
+	if err := queryAvailable(symbol, balance, held, quantity); err != nil {
+		return quantity, err
+	}
 
-// QueryAddress - This function is used to get the address associated with a userId, symbol, platform and protocol. It does this by
-// querying the assets and wallets tables in the database for a matching userId, symbol and platform, and
-// returns the address associated with the query if one is found.
-func (a *Service) QueryAddress(userId int64, platform string) (address string) {
+	// This is a conditional statement used to set a new order and check for any errors that might occur, the order is written
+	// by the transaction of its hold.
+	if order.Id, err = a.writeOrder(tx, order); err != nil {
+		return quantity, err
+	}
 
-	// This statement is used to query a database to get an address associated with a user, platform and symbol.
-	// The purpose of using `coalesce` is to return a blank string if the address is null. The purpose of using `QueryRow`
-	// is to limit the query to a single row. The purpose of using `Scan` is to store the result of the query into the `address` variable.
-	_ = a.Context.Db.QueryRow("select coalesce(w.address, '') from balances a inner join wallets w on w.platform = $1 and w.user_id = a.user_id where a.user_id = $2 and a.type = $3", platform, userId, types.TypeSpot).Scan(&address)
-	return address
-}
+	if _, err := tx.Exec("insert into holds (user_id, symbol, type, value, reference, reference_id) values ($1, $2, $3, $4, $5, $6)", order.GetUserId(), symbol, order.GetType(), quantity, types.HoldOrder, order.GetId()); err != nil {
+		return quantity, err
+	}
 
-// QueryBalance - This function is used to query the balance of a user's assets by symbol. It takes a symbol and userID as parameters
-// and queries the assets table in the database for the balance associated with that symbol and userID, then returns the balance.
-func (a *Service) QueryBalance(symbol, _type string, userId int64) (balance float64) {
+	if err := tx.Commit(); err != nil {
+		return quantity, err
+	}
+	placed.Inc(order.GetType(), order.GetAssigning())
+	hold.End(nil)
 
-	// This line of code is used to retrieve the balance from the assets table in a database. It takes in two parameters
-	// (symbol and userId) and uses them to query the database. The result is then stored in the variable balance.
-	_ = a.Context.Db.QueryRow("select value as balance from balances where symbol = $1 and user_id = $2 and type = $3", symbol, userId, _type).Scan(&balance)
-	return balance
-}
+	// The placement is journaled once the order is in the book or queued for it, before it is matched.
+	a.Context.Debug(a.writeJournal(types.JournalPlace, order, 0, order.GetPrice(), order.GetValue()))
 
-// QueryAsset - This function is used to retrieve asset information from a database. It takes a currency symbol and a status
-// boolean as arguments. It then queries the database to retrieve information about the currency and stores it in the
-// 'response' variable. It then checks for the existence of the asset icon and stores the result in the 'icon' field
-// of the 'response' variable. Finally, it returns the currency and an error value, if any.
-func (a *Service) QueryAsset(symbol string, status bool) (*types.Asset, error) {
+	if !queue {
+
+		assigning := types.AssigningBuy
+		if order.GetAssigning() == types.AssigningBuy {
+			assigning = types.AssigningSell
+		}
+
+		_, match := trace.Start(ctx, "order.match", trace.KindInternal)
+		a.trade(order, assigning)
+		match.End(nil)
+	}
+
+	return quantity, nil
+}
+
+// queryResting - This function locks the resting order in the transaction of its amendment and returns its remaining value as the
+// exact decimal of the numeric column, the value is bound back as it is read, not as the float of the order. The
+// amendment fails when the order was filled or cancelled since it was read, so that the value filled in the meantime is
+// never given back.
+func (a *Service) queryResting(tx *sql.Tx, order *types.Order) (string, error) {
 
 	var (
-		response types.Asset
-		maps     []string
-		storage  []string
-		chains   []byte
+		value string
 	)
 
-	// The purpose of this code is to append an item to a list of maps if a certain condition is met. In this case, if the
-	// "status" variable is true, a string will be appended to the list of maps.
-	if status {
-		maps = append(maps, fmt.Sprintf("and status = %v", true))
+	if err := tx.QueryRow("select value::text from orders where id = $1 and status in ($2, $3) for update", order.GetId(), types.StatusPending, types.StatusQueue).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return "", status.Error(11807, "the order was filled or cancelled while it was amended, please try again")
+		}
+		return "", err
 	}
 
-	// This code is performing a query of a database table called "currencies" and scanning the results into a response
-	// object. The query is using the symbol parameter to filter the results and strings.Join(maps, " ") to join any
-	// additional parameters. If the query fails, an error is returned.
-	if err := a.Context.Db.QueryRow(fmt.Sprintf(`select id, name, symbol, min_withdraw, max_withdraw, min_trade, max_trade, fees_trade, fees_discount, fees_charges, fees_costs, marker, status, "group", type, create_at, chains from assets where symbol = '%v' %s`, symbol, strings.Join(maps, " "))).Scan(
-		&response.Id,
-		&response.Name,
-		&response.Symbol,
-		&response.MinWithdraw,
-		&response.MaxWithdraw,
-		&response.MinTrade,
-		&response.MaxTrade,
-		&response.FeesTrade,
-		&response.FeesDiscount,
-		&response.FeesCharges,
-		&response.FeesCosts,
-		&response.Marker,
-		&response.Status,
-		&response.Group,
-		&response.Type,
-		&response.CreateAt,
-		&chains,
-	); err != nil {
-		return &response, err
+	if decimal.New(value).Value() != order.GetValue() {
+		return "", status.Error(11807, "the order was filled or cancelled while it was amended, please try again")
 	}
 
-	// The purpose of the code is to add a string to a storage slice. The string is made up of elements from the
-	// e.Context.StoragePath, the word "static", the word "icon", and a string created from the response.GetSymbol()
-	// function. The ... in the code indicates that the elements of the slice are being "unpacked" into to append() call.
-	storage = append(storage, []string{a.Context.StoragePath, "static", "icon", fmt.Sprintf("%v.png", response.GetSymbol())}...)
+	return value, nil
+}
 
-	// This statement is checking to see if a file at the given filepath exists. If it does, then the response.Icon will be
-	// set to true. This statement is used in order to prevent the creation of unnecessary files.
-	if _, err := os.Stat(filepath.Join(storage...)); !errors.Is(err, os.ErrNotExist) {
-		response.Icon = true
+// writeReduce - This function reduces the remaining value of the resting order in place, the order keeps its id and so its priority
+// in the book. The funds of the reduced value are released from the hold of the order, the buy order held them at its
+// own price. The reduction is journaled as the adjustment of the order and fails when the order was filled or cancelled
+// since it was read, so that the value filled in the meantime is never given back.
+func (a *Service) writeReduce(order *types.Order, value float64) error {
+
+	// The reduced order must still meet the minimum notional and the quantity step of the pair.
+	if err := a.queryFilter(&types.Order{BaseUnit: order.GetBaseUnit(), QuoteUnit: order.GetQuoteUnit(), Type: order.GetType(), Trading: order.GetTrading(), Price: order.GetPrice(), Quantity: value}); err != nil {
+		return err
 	}
 
-	// The purpose of this code is to unmarshal a json object into a response object. This is done using the
-	// json.Unmarshal() function. The function takes the json object (chains) and a reference to the response.Fields
-	// object. If there is an error, it will be returned with the error context.
-	if err := json.Unmarshal(chains, &response.Fields); err != nil {
-		return &response, err
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := a.queryResting(tx, order)
+	if err != nil {
+		return err
 	}
 
-	return &response, nil
+	// The reduction is taken from the exact remaining value of the order, so that no dust of the float is left in the hold.
+	reduced, release := queryReduce(order.GetAssigning(), current, value, order.GetPrice())
+
+	result, err := tx.Exec("update orders set value = $2, quantity = quantity - $3 where id = $1 and value = $4::numeric and status in ($5, $6)", order.GetId(), value, reduced.String(), current, types.StatusPending, types.StatusQueue)
+	if err != nil {
+		return err
+	}
+
+	if count, _ := result.RowsAffected(); count == 0 {
+		return status.Error(11807, "the order was filled or cancelled while it was amended, please try again")
+	}
+
+	if _, err := tx.Exec("update holds set value = greatest(value - $3, 0) where reference = $1 and reference_id = $2", types.HoldOrder, order.GetId(), release.String()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	order.Quantity, order.Value = decimal.New(order.GetQuantity()).Sub(reduced.Float()).Float(), value
+	a.Context.Debug(a.writeJournal(types.JournalAdjust, order, 0, order.GetPrice(), order.GetValue()))
+
+	return nil
 }
 
-// QueryChain - This function is used to query a row from a database table "chains" with the given id and status. It then scans the
-// row into a types.Chain struct. If there is an error, it returns the struct with an error. Otherwise, it returns the
-// struct with no error.
-func (a *Service) QueryChain(id int64, status bool) (*types.Chain, error) {
+// queryReduce - This function returns the value the resting order is reduced by, from its exact remaining value to the new value,
+// and the funds the reduction releases from the hold of the order: the sell order held the base value itself, the buy
+// order held the quote value at its own price.
+func queryReduce(assigning, current string, value, price float64) (reduced, release *decimal.Float) {
+
+	reduced = decimal.New(current).Sub(value)
+	if assigning == types.AssigningBuy {
+		return reduced, reduced.Mul(price)
+	}
+
+	return reduced, reduced
+}
+
+// writeReplace - This function replaces the resting order by the new order with the price and the value, as one step: the resting
+// order is cancelled, its hold is moved to the new order and the new order is placed at the end of the queue of its
+// price, then matched like any placed order. The client id of the resting order moves to the new one. The new order is
+// validated before the resting order is touched, and the funds are checked with the hold of the resting order counted
+// as available, so that the replacement either happens as a whole or not at all. The new order is returned.
+func (a *Service) writeReplace(ctx context.Context, order *types.Order, price, value float64) (*types.Order, error) {
 
 	var (
-		chain types.Chain
-		maps  []string
+		balance, held float64
+		replace       = types.Order{
+			UserId:    order.GetUserId(),
+			BaseUnit:  order.GetBaseUnit(),
+			QuoteUnit: order.GetQuoteUnit(),
+			Assigning: order.GetAssigning(),
+			Trading:   order.GetTrading(),
+			Type:      order.GetType(),
+			ClientId:  order.GetClientId(),
+			Price:     price,
+			Quantity:  value,
+			Value:     value,
+			Status:    types.StatusPending,
+			CreateAt:  time.Now().UTC().Format(time.RFC3339),
+		}
 	)
 
-	// The purpose of this code is to add the string "and status = true" to the maps slice, if the status variable is set to true.
-	if status {
-		maps = append(maps, fmt.Sprintf("and status = %v", true))
+	if err := a.queryListed(&replace); err != nil {
+		return nil, err
 	}
 
-	// This code is used to query a database for a row of data which matches the given id. The query is built by joining the
-	// strings in the maps array and is passed to the QueryRow method. The data is then scanned into the chain object and
-	// returned. If there is an error, it will be returned instead.
-	if err := a.Context.Db.QueryRow(fmt.Sprintf("select id, name, rpc, block, network, explorer_link, platform, confirmation, time_withdraw, fees, tag, parent_symbol, decimals, status from chains where id = %[1]d %[2]s", id, strings.Join(maps, " "))).Scan(
-		&chain.Id,
-		&chain.Name,
-		&chain.Rpc,
-		&chain.Block,
-		&chain.Network,
-		&chain.ExplorerLink,
-		&chain.Platform,
-		&chain.Confirmation,
-		&chain.TimeWithdraw,
-		&chain.Fees,
-		&chain.Tag,
-		&chain.ParentSymbol,
-		&chain.Decimals,
-		&chain.Status,
-	); err != nil {
-		return &chain, errors.New("chain not found or chain network off")
+	if err := a.queryBand(&replace); err != nil {
+		return nil, err
 	}
 
-	return &chain, nil
+	if err := a.queryFilter(&replace); err != nil {
+		return nil, err
+	}
+
+	queue, err := a.queryHours(&replace)
+	if err != nil {
+		return nil, err
+	}
+
+	if queue {
+		replace.Status = types.StatusQueue
+	}
+
+	quantity, err := a.queryValidateOrder(&replace)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol := replace.GetBaseUnit()
+	if replace.GetAssigning() == types.AssigningBuy {
+		symbol = replace.GetQuoteUnit()
+	}
+
+	tx, err := a.Context.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The balance is locked while the holds are summed up, as by the WriteHold function, the hold of the resting order is
+	// left out of the sum, it is moved to the new order.
+	if err := tx.QueryRow("select value from balances where symbol = $1 and user_id = $2 and type = $3 for update", symbol, replace.GetUserId(), replace.GetType()).Scan(&balance); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err := tx.QueryRow("select coalesce(sum(value), 0) from holds where symbol = $1 and user_id = $2 and type = $3 and not (reference = $4 and reference_id = $5)", symbol, replace.GetUserId(), replace.GetType(), types.HoldOrder, order.GetId()).Scan(&held); err != nil {
+		return nil, err
+	}
+
+	if err := queryAvailable(symbol, balance, held, quantity); err != nil {
+		return nil, err
+	}
+
+	current, err := a.queryResting(tx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec("update orders set status = $3, client_id = '' where id = $1 and value = $2::numeric and status in ($4, $5)", order.GetId(), current, types.StatusCancel, types.StatusPending, types.StatusQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	if count, _ := result.RowsAffected(); count == 0 {
+		return nil, status.Error(11807, "the order was filled or cancelled while it was amended, please try again")
+	}
+
+	if _, err := tx.Exec("delete from holds where reference = $1 and reference_id = $2", types.HoldOrder, order.GetId()); err != nil {
+		return nil, err
+	}
+
+	if replace.Id, err = a.writeOrder(tx, &replace); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("insert into holds (user_id, symbol, type, value, reference, reference_id) values ($1, $2, $3, $4, $5, $6)", replace.GetUserId(), symbol, replace.GetType(), quantity, types.HoldOrder, replace.GetId()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	placed.Inc(replace.GetType(), replace.GetAssigning())
+
+	// The replacement is journaled as the cancellation of the resting order and the placement of the new one.
+	order.Status = types.StatusCancel
+	a.Context.Debug(a.writeJournal(types.JournalCancel, order, 0, order.GetPrice(), order.GetValue()))
+	a.Context.Debug(a.writeJournal(types.JournalPlace, &replace, 0, replace.GetPrice(), replace.GetValue()))
+
+	if !queue {
+
+		assigning := types.AssigningBuy
+		if replace.GetAssigning() == types.AssigningBuy {
+			assigning = types.AssigningSell
+		}
+		a.trade(&replace, assigning)
+	}
+
+	return &replace, nil
 }
 
-// QueryContractById - This function is used to retrieve a contract from a database by its ID. It queries the database for the contract with
-// the specified ID, and then scans the query result into the fields of the pbspot.Contract struct. The function then
-// returns the contract and any errors that may have occurred.
-func (a *Service) QueryContractById(id int64) (*types.Contract, error) {
+// writeCancelAll - This function cancels all the resting and the queued orders of the user, of the pair and of the side if they are
+// given, and releases their holds. The orders are cancelled and their holds released by one statement, so that either all
+// of them are cancelled or none, and no order is matched halfway through. The cancellations are journaled and published
+// one by one afterwards. The simulated orders of the users who trade on the paper are cancelled on the paper instead.
+func (a *Service) writeCancelAll(userId int64, base, quote, assigning string) (fields []*types.Order, err error) {
+
+	if a.queryPaper(userId) {
+
+		rows, err := a.Context.Db.Query("select id from paper_orders where user_id = $1 and status = $2 and ($3 = '' or base_unit = $3) and ($4 = '' or quote_unit = $4) and ($5 = '' or assigning = $5) order by id", userId, types.StatusPending, base, quote, assigning)
+		if err != nil {
+			return fields, err
+		}
+		defer rows.Close()
+
+		var (
+			ids []int64
+		)
+
+		for rows.Next() {
+
+			var (
+				id int64
+			)
+
+			if err := rows.Scan(&id); err != nil {
+				return fields, err
+			}
+			ids = append(ids, id)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fields, err
+		}
+
+		for _, id := range ids {
+
+			item, err := a.writePaperCancel(userId, id)
+			if err != nil {
+				return fields, err
+			}
+			fields = append(fields, item)
+		}
+
+		return fields, nil
+	}
+
+	rows, err := a.Context.Db.Query(`with cancelled as (update orders set status = $2 where user_id = $1 and status in ($3, $4) and ($5 = '' or base_unit = $5) and ($6 = '' or quote_unit = $6) and ($7 = '' or assigning = $7) returning id, uid, value, quantity, price, assigning, trading, base_unit, quote_unit, user_id, type, status, client_id, create_at), released as (delete from holds where reference = $8 and reference_id in (select id from cancelled)) select id, uid, value, quantity, price, assigning, trading, base_unit, quote_unit, user_id, type, status, client_id, create_at from cancelled order by id`, userId, types.StatusCancel, types.StatusPending, types.StatusQueue, base, quote, assigning, types.HoldOrder)
+	if err != nil {
+		return fields, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Order
+		)
+
+		if err := rows.Scan(&item.Id, &item.Uid, &item.Value, &item.Quantity, &item.Price, &item.Assigning, &item.Trading, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.Type, &item.Status, &item.ClientId, &item.CreateAt); err != nil {
+			return fields, err
+		}
+
+		fields = append(fields, &item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fields, err
+	}
+
+	for _, item := range fields {
+		a.Context.Debug(a.writeJournal(types.JournalCancel, item, 0, item.GetPrice(), item.GetValue()))
+		a.Context.Debug(a.Context.Publish(item, "exchange", "order/cancel"))
+	}
+
+	return fields, nil
+}
+
+// writeTrade - The purpose of this code is to set a trade by converting a given value to a decimal number multiplied by a given
+// price, get the sum of a given order, symbol, and value, insert the data into a database, update the "fees_charges"
+// column in the "currencies" table in a database, and publish a particular order to an exchange. The value credited to the
+// balance is returned as the decimal.
+func (a *Service) writeTrade(id int64, symbol string, value, price float64, convert bool) (*decimal.Float, error) {
+
+	// The purpose of this code is to retrieve an order from a database, given its ID. The variable 'order' will store the
+	// order object that is returned from the queryOrder() method.
+	order := a.queryOrder(id)
+	order.Value = value
+
+	// This code is used to convert a given value to a decimal number multiplied by a given price. The result is then stored
+	// as a floating point number. This is likely used for some kind of financial calculation or to convert a given value to
+	// a currency amount.
+	amount := decimal.New(value)
+	if convert {
+		amount = amount.Mul(price)
+	}
+
+	// This code is attempting to get the sum of a given order, symbol and value. The variables s and f are used to store
+	// the sum and any error encountered, respectively. The if statement checks for any errors that may have occurred and
+	// returns the error if one is encountered.
+	s, f, maker, err := a.querySum(id, symbol, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	// This code is used to calculate the fee for an order based on the assigned type. If the order is assigned to be a
+	// SELL, the fee is calculated by dividing the fee (f) by the price. If the order is assigned to be something else, the
+	// fee is simply set to be f. The fee is recorded with the trade as the decimal, the order carries its float.
+	fees := f
+	if order.GetAssigning() == types.AssigningSell {
+		fees = f.Div(price)
+	}
+	order.Fees = fees.Float()
+
+	// The trade and the fees are written with the prepared statements, they are executed for both the orders of every trade.
+	statement, err := a.Context.Statement(`insert into trades (order_id, assigning, user_id, base_unit, quote_unit, quantity, fees, price, maker) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id, uid, create_at`)
+	if err != nil {
+		return nil, err
+	}
 
+	// The public trade of the tape is the trade of the order of the taker, without the user and the fees, which are not public.
 	var (
-		contract types.Contract
+		trade = types.Trade{BaseUnit: order.GetBaseUnit(), QuoteUnit: order.GetQuoteUnit(), Price: price, Quantity: order.GetValue(), Assigning: order.GetAssigning()}
 	)
 
-	// This code is a SQL query used to retrieve data from a database. The purpose of the query is to select data from the
-	// contracts and chains tables for a specific contract with a given ID. The query will return information such as the
-	// symbol, chain ID, address, fees withdraw, protocol, decimals, and platform of the contract. The query uses the Scan()
-	// method to store the retrieved data in the contract variable. The if statement is used to check for errors and return
-	// the contract along with an error if one occurs.
-	if err := a.Context.Db.QueryRow(`select c.id, c.symbol, c.chain_id, c.address, c.fees, c.protocol, c.decimals, n.platform from contracts c inner join chains n on n.id = c.chain_id where c.id = $1`, id).Scan(&contract.Id, &contract.Symbol, &contract.ChainId, &contract.Address, &contract.Fees, &contract.Protocol, &contract.Decimals, &contract.Platform); err != nil {
-		return &contract, err
+	// This code is used to insert data into the "transfers" table in a database using the parameters provided in the array
+	// "param". The code first checks for any errors in the insertion process, and if there are any, it will return an error.
+	if err := statement.QueryRow(order.GetId(), order.GetAssigning(), order.GetUserId(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetValue(), fees.String(), price, maker).Scan(&trade.Id, &trade.Uid, &trade.CreateAt); err != nil {
+		return nil, err
 	}
 
-	return &contract, nil
+	// Both of the orders of a trade are written, the trade is counted once, by the order of the maker, and published to the
+	// tape once, by the order of the taker.
+	if maker {
+		matched.Inc(order.GetType())
+	} else if err := a.Context.Publish(&trade, "exchange", fmt.Sprintf("trade/tape:%v", order.GetType())); err != nil {
+		return nil, err
+	}
+
+	// This statement is checking to see if the value of the parameter at index i in the param array is greater than 0. If
+	// it is, then the code within the if statement will be executed. This is likely being used to check if a fee is
+	// associated with the parameter at index i.
+	if f.Sign() > 0 {
+
+		// This code is updating the "fees_charges" column in the "currencies" table in a database. The "symbol" and
+		// "fee" are parameters that are passed into the statement. If an error occurs during the
+		// execution of the statement, the function will return the error.
+		statement, err := a.Context.Statement("update assets set fees_charges = fees_charges + $2 where symbol = $1")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := statement.Exec(symbol, f.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	// The purpose of the code snippet is to publish a particular order to an exchange with the routing key "order/status".
+	// The if statement checks for any errors encountered while publishing the order, and returns an error if one occurs.
+	if err := a.Context.Publish(a.queryOrder(order.GetId()), "exchange", "order/status"); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// QueryPair - This function is used to get a specific pair from the database, based on the id and status passed as arguments. The
+// function returns a pointer to a 'types.Pair' struct and an error if any. It prepares a query to select the specified
+// pair from the database, based on the given id and status. It then scans the results and stores them in the struct, and
+// finally returns the struct and an error if any.
+func (a *Service) QueryPair(id int64, _type string, status bool) (*types.Pair, error) {
+
+	var (
+		chain   types.Pair
+		builder = query.NewBuilder(id)
+	)
+
+	// The purpose of this code is to add the condition of the status to the query if the variable "status" is true.
+	if status {
+		builder.Where("status = ?", true)
+	}
+
+	// Check if type is not empty then add the condition of the type to the query, the type is bound as the parameter.
+	if len(_type) > 0 {
+		builder.Where("type = ?", _type)
+	}
+
+	// The pair is read with the prepared statement, the text of the query depends only on the conditions that are set, so
+	// every variant of it is prepared once.
+	statement, err := a.Context.Statement(fmt.Sprintf("select id, base_unit, quote_unit, price, base_decimal, quote_decimal, type, status, min_notional, price_step, quantity_step, band, halt_percent, halt_window, cooldown, halted, coalesce(halt_until::text, '') from pairs where id = $1 %s", builder.And()))
+	if err != nil {
+		return &chain, err
+	}
+
+	// This code is used to query a database and retrieve information about a pair with a specified id. The retrieved
+	// information is then assigned to the chain struct. Finally, the code returns the chain struct and an error if it fails.
+	if err := statement.QueryRow(builder.Params()...).Scan(
+		&chain.Id,
+		&chain.BaseUnit,
+		&chain.QuoteUnit,
+		&chain.Price,
+		&chain.BaseDecimal,
+		&chain.QuoteDecimal,
+		&chain.Type,
+		&chain.Status,
+		&chain.MinNotional,
+		&chain.PriceStep,
+		&chain.QuantityStep,
+		&chain.Band,
+		&chain.HaltPercent,
+		&chain.HaltWindow,
+		&chain.Cooldown,
+		&chain.Halted,
+		&chain.HaltUntil,
+	); err != nil {
+		return &chain, err
+	}
+
+	return &chain, nil
+}
+
+// QueryAddress - This function is used to get the address associated with a userId, symbol, platform and protocol. It does this by
+// querying the assets and wallets tables in the database for a matching userId, symbol and platform, and
+// returns the address associated with the query if one is found. When the user has several addresses on the platform,
+// the latest address that is not archived is the current deposit address.
+func (a *Service) QueryAddress(userId int64, platform string) (address string) {
+
+	// This statement is used to query a database to get an address associated with a user, platform and symbol.
+	// The purpose of using `coalesce` is to return a blank string if the address is null. The purpose of using `QueryRow`
+	// is to limit the query to a single row. The purpose of using `Scan` is to store the result of the query into the `address` variable.
+	_ = a.Context.Db.QueryRow("select coalesce(w.address, '') from wallets w where w.platform = $1 and w.user_id = $2 and w.archived = $4 and exists(select value from balances where user_id = w.user_id and type = $3) order by w.derivation desc limit 1", platform, userId, types.TypeSpot, false).Scan(&address)
+	return address
+}
+
+// QueryAddresses - This function returns all deposit addresses of the user on the platform, both the current and the archived
+// ones, in the order in which they were generated.
+func (a *Service) QueryAddresses(userId int64, platform string) (wallets []*types.Wallet, err error) {
+
+	rows, err := a.Context.Db.Query("select id, user_id, address, platform, derivation, archived, create_at from wallets where user_id = $1 and platform = $2 order by derivation", userId, platform)
+	if err != nil {
+		return wallets, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Wallet
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.Address, &item.Platform, &item.Derivation, &item.Archived, &item.CreateAt); err != nil {
+			return wallets, err
+		}
+
+		wallets = append(wallets, &item)
+	}
+
+	return wallets, nil
+}
+
+// QueryDerivation - This function returns the index with which the deposit address was derived from the seed of the user, it is
+// needed to derive the private key that signs the transfers from the address. The addresses created before the rotation
+// of the addresses have the index 0.
+func (a *Service) QueryDerivation(address, platform string) (derivation uint32) {
+	_ = a.Context.Db.QueryRow("select derivation from wallets where address = $1 and platform = $2", address, platform).Scan(&derivation)
+	return derivation
+}
+
+// queryDerive - This function derives the deposit address of the user with the index on the platform from the secret of the
+// exchange and the entropy of the user. The address is derived by the adapter of the platform, the platforms without
+// an adapter, such as Bitcoin, by the keypair of the platform.
+func (a *Service) queryDerive(entropy []byte, name string, index uint32) (address string, err error) {
+
+	secret := fmt.Sprintf("%v-&*39~763@)", a.Context.Secrets[1])
+
+	if adapter, ok := platform.Lookup(name); ok {
+		address, _, err = adapter.Derive(secret, entropy, index)
+		return address, err
+	}
+
+	var cross keypair.CrossChain
+	address, _, err = cross.Derive(secret, entropy, name, index)
+
+	return address, err
+}
+
+// QueryBalance - This function is used to query the available balance of a user's assets by symbol. It takes a symbol and userID as
+// parameters and queries the balances table in the database for the balance associated with that symbol and userID, less
+// the funds held by the open orders and the unsent withdrawals, then returns the balance.
+func (a *Service) QueryBalance(symbol, _type string, userId int64) (balance float64) {
+
+	// This line of code is used to retrieve the balance from the assets table in a database. It takes in two parameters
+	// (symbol and userId) and uses them to query the database. The result is then stored in the variable balance.
+	_ = a.Context.Db.QueryRow("select b.value - coalesce((select sum(h.value) from holds h where h.symbol = b.symbol and h.user_id = b.user_id and h.type = b.type), 0) as balance from balances b where b.symbol = $1 and b.user_id = $2 and b.type = $3", symbol, userId, _type).Scan(&balance)
+	return balance
+}
+
+// QueryAsset - This function is used to retrieve asset information from a database. It takes a currency symbol and a status
+// boolean as arguments. It then queries the database to retrieve information about the currency and stores it in the
+// 'response' variable. It then checks for the existence of the asset icon and stores the result in the 'icon' field
+// of the 'response' variable. Finally, it returns the currency and an error value, if any.
+func (a *Service) QueryAsset(symbol string, status bool) (*types.Asset, error) {
+
+	var (
+		response types.Asset
+		storage  []string
+	)
+
+	// The asset is read with the prepared statement, the symbol is bound as the parameter, and the disabled assets are
+	// skipped by the second parameter when only the enabled ones are asked for.
+	statement, err := a.Context.Statement(`select id, name, symbol, min_withdraw, max_withdraw, min_trade, max_trade, fees_trade, fees_discount, fees_charges, fees_costs, marker, status, "group", type, zone, create_at from assets where symbol = $1 and (not $2 or status)`)
+	if err != nil {
+		return &response, err
+	}
+
+	// This code is performing a query of a database table called "currencies" and scanning the results into a response
+	// object. If the query fails, an error is returned.
+	if err := statement.QueryRow(symbol, status).Scan(
+		&response.Id,
+		&response.Name,
+		&response.Symbol,
+		&response.MinWithdraw,
+		&response.MaxWithdraw,
+		&response.MinTrade,
+		&response.MaxTrade,
+		&response.FeesTrade,
+		&response.FeesDiscount,
+		&response.FeesCharges,
+		&response.FeesCosts,
+		&response.Marker,
+		&response.Status,
+		&response.Group,
+		&response.Type,
+		&response.Zone,
+		&response.CreateAt,
+	); err != nil {
+		return &response, err
+	}
+
+	// The purpose of the code is to add a string to a storage slice. The string is made up of elements from the
+	// e.Context.StoragePath, the word "static", the word "icon", and a string created from the response.GetSymbol()
+	// function. The ... in the code indicates that the elements of the slice are being "unpacked" into to append() call.
+	storage = append(storage, []string{a.Context.StoragePath, "static", "icon", fmt.Sprintf("%v.png", response.GetSymbol())}...)
+
+	// This statement is checking to see if a file at the given filepath exists. If it does, then the response.Icon will be
+	// set to true. This statement is used in order to prevent the creation of unnecessary files.
+	if _, err := os.Stat(filepath.Join(storage...)); !errors.Is(err, os.ErrNotExist) {
+		response.Icon = true
+	}
+
+	// The ids of the chains the asset is available on are collected into the response.Fields, in the order the chains were added.
+	rows, err := a.Context.Db.Query("select chain_id from asset_chains where symbol = $1 order by id", response.GetSymbol())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	response.Fields = make([]int64, 0)
+	for rows.Next() {
+
+		var (
+			id int64
+		)
+
+		if err := rows.Scan(&id); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, id)
+	}
+
+	return &response, rows.Err()
+}
+
+// QueryAssetChain - This function returns the configuration of the asset on the chain: whether the deposits and the withdrawals
+// are enabled, the minimum withdrawal and the withdrawal fee which override the values of the asset and of the chain when
+// they are set. An error is returned when the asset is not available on the chain.
+func (a *Service) QueryAssetChain(symbol string, chainId int64) (*types.AssetChain, error) {
+
+	var (
+		item types.AssetChain
+	)
+
+	if err := a.Context.Db.QueryRow("select id, symbol, chain_id, deposit, withdraw, min_withdraw, fees, hold, create_at from asset_chains where symbol = $1 and chain_id = $2", symbol, chainId).Scan(&item.Id, &item.Symbol, &item.ChainId, &item.Deposit, &item.Withdraw, &item.MinWithdraw, &item.Fees, &item.Hold, &item.CreateAt); err != nil {
+		return &item, err
+	}
+
+	return &item, nil
+}
+
+// QueryChain - This function is used to query a row from a database table "chains" with the given id and status. It then scans the
+// row into a types.Chain struct. If there is an error, it returns the struct with an error. Otherwise, it returns the
+// struct with no error.
+func (a *Service) QueryChain(id int64, status bool) (*types.Chain, error) {
+
+	var (
+		chain types.Chain
+	)
+
+	// The chain is read with the prepared statement, the disabled chains are skipped by the second parameter when only the
+	// enabled ones are asked for.
+	statement, err := a.Context.Statement("select id, name, rpc, block, network, explorer_link, platform, confirmation, time_withdraw, fees, tag, parent_symbol, decimals, status, stream, sla, price_cap, workers from chains where id = $1 and (not $2 or status)")
+	if err != nil {
+		return &chain, err
+	}
+
+	// This code is used to query a database for a row of data which matches the given id. The data is then scanned into
+	// the chain object and returned. If there is an error, it will be returned instead.
+	if err := statement.QueryRow(id, status).Scan(
+		&chain.Id,
+		&chain.Name,
+		&chain.Rpc,
+		&chain.Block,
+		&chain.Network,
+		&chain.ExplorerLink,
+		&chain.Platform,
+		&chain.Confirmation,
+		&chain.TimeWithdraw,
+		&chain.Fees,
+		&chain.Tag,
+		&chain.ParentSymbol,
+		&chain.Decimals,
+		&chain.Status,
+		&chain.Stream,
+		&chain.Sla,
+		&chain.PriceCap,
+		&chain.Workers,
+	); err != nil {
+		return &chain, errors.New("chain not found or chain network off")
+	}
+
+	return &chain, nil
+}
+
+// QueryContractById - This function is used to retrieve a contract from a database by its ID. It queries the database for the contract with
+// the specified ID, and then scans the query result into the fields of the pbspot.Contract struct. The function then
+// returns the contract and any errors that may have occurred.
+func (a *Service) QueryContractById(id int64) (*types.Contract, error) {
+
+	var (
+		contract types.Contract
+	)
+
+	// This code is a SQL query used to retrieve data from a database. The purpose of the query is to select data from the
+	// contracts and chains tables for a specific contract with a given ID. The query will return information such as the
+	// symbol, chain ID, address, fees withdraw, protocol, decimals, and platform of the contract. The query uses the Scan()
+	// method to store the retrieved data in the contract variable. The if statement is used to check for errors and return
+	// the contract along with an error if one occurs.
+	if err := a.Context.Db.QueryRow(`select c.id, c.symbol, c.chain_id, c.address, c.fees, c.protocol, c.decimals, n.platform from contracts c inner join chains n on n.id = c.chain_id where c.id = $1`, id).Scan(&contract.Id, &contract.Symbol, &contract.ChainId, &contract.Address, &contract.Fees, &contract.Protocol, &contract.Decimals, &contract.Platform); err != nil {
+		return &contract, err
+	}
+
+	return &contract, nil
+}
+
+// QueryContract - This function retrieves a contract from the database based on a given symbol and chain ID. It then sets the fields of
+// a types.Contract struct with the values from the database and returns it. Any errors encountered while retrieving the
+// contract are returned as the second value.
+func (a *Service) QueryContract(symbol string, cid int64) (*types.Contract, error) {
+
+	var (
+		contract types.Contract
+	)
+
+	// This code is checking the database for a contract with the specified symbol and chain ID and then storing the results
+	// of the query in a contract struct. If the query fails, to err is returned.
+	if err := a.Context.Db.QueryRow(`select id, address, fees, protocol, decimals from contracts where symbol = $1 and chain_id = $2`, symbol, cid).Scan(&contract.Id, &contract.Address, &contract.Fees, &contract.Protocol, &contract.Decimals); err != nil {
+		return &contract, err
+	}
+
+	return &contract, nil
+}
+
+// QueryReserve - This function is used to get the total reserve for a given symbol, platform, and protocol from a database. It takes
+// three parameters (symbol, platform, and protocol) and uses a SQL query to get the sum of the values from the reserves
+// table where the symbol, platform, and protocol match the provided parameters. Finally, it returns the total reserve as a float64.
+func (a *Service) QueryReserve(symbol, platform, protocol string) (reserve float64) {
+
+	if len(protocol) == 0 {
+		protocol = types.ProtocolMainnet
+	}
+
+	// The purpose of this code is to query a database for the sum of values from a specific set of reserves (symbol,
+	// platform, and protocol) and store the result in the reserve variable.
+	_ = a.Context.Db.QueryRow(`select sum(value) from reserves where symbol = $1 and platform = $2 and protocol = $3`, symbol, platform, protocol).Scan(&reserve)
+	return reserve
+}
+
+// QueryReverse - This code is used to get the reverse of a certain user's address for a certain platform and symbol from a database. It
+// takes in the userId, address, symbol, and platform as parameters, and returns the reverse value stored in the database.
+func (a *Service) QueryReverse(userId int64, address, symbol, platform string) (reverse float64) {
+
+	// The purpose of this code is to query a database for the sum of values from a specific set of reserves (symbol,
+	// platform, and protocol) and store the result in the reserve variable.
+	_ = a.Context.Db.QueryRow(`select reverse from reserves where user_id = $1 and address = $2 and symbol = $3 and platform = $4 and protocol = $5`, userId, address, symbol, platform, types.ProtocolMainnet).Scan(&reverse)
+	return reverse
+}
+
+// QueryWarning - This function checks how close the given value is to the given limit, if the value reaches the warning ratio of the
+// limit, the warning is published to the user as a notification event and returned, so that it can also be included in
+// the response. If the limit is not set or the value is far from it, the function returns nil.
+func (a *Service) QueryWarning(userId int64, limit, symbol string, value, max float64) *types.Warning {
+
+	// This code checks that the limit is set and that the value has reached the warning ratio of it.
+	if max <= 0 || value < decimal.New(max).Mul(warningRatio).Float() {
+		return nil
+	}
+
+	// The purpose of this code is to build the warning, the ratio shows the share of the limit that has been used.
+	warning := types.Warning{
+		UserId: userId,
+		Limit:  limit,
+		Symbol: symbol,
+		Value:  value,
+		Max:    max,
+		Ratio:  decimal.New(value).Div(max).Float(),
+	}
+
+	// The warning is published to the exchange, so that the user receives it as a notification even if the response is
+	// not inspected, an error of the publishing does not affect the request itself.
+	a.Context.Debug(a.Context.Publish(&warning, "exchange", "account/limit-warning"))
+
+	return &warning
+}
+
+// WriteSupport - This function opens a support alert for the event of an account that is marked as priority (institutional), for example
+// a failed withdrawal or a stuck deposit. The alert gets a deadline within the service level agreement and is published
+// to the priority channel of the operators, the events of the regular accounts and the repeated events are skipped.
+func (a *Service) WriteSupport(userId int64, kind string, reference int64) error {
+
+	var (
+		priority bool
+		item     types.Support
+	)
+
+	// This code checks that the account is marked as priority, the alerts are opened only for these accounts.
+	if err := a.Context.Db.QueryRow("select priority from accounts where id = $1", userId).Scan(&priority); err != nil || !priority {
+		return err
+	}
+
+	// The alert is opened only once for the same event, the deadline is counted from now by the service level agreement.
+	if err := a.Context.Db.QueryRow(`insert into support (user_id, kind, reference, status, deadline) values ($1, $2, $3, $4, now() + make_interval(mins => $5)) on conflict (kind, reference) do nothing returning id, deadline, create_at`, userId, kind, reference, types.StatusPending, a.querySla()).Scan(&item.Id, &item.Deadline, &item.CreateAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	item.UserId = userId
+	item.Kind = kind
+	item.Reference = reference
+	item.Status = types.StatusPending
+
+	return a.Context.Publish(&item, "exchange", "support/priority")
+}
+
+// querySla - This function returns the service level agreement of the support alerts in minutes, half an hour is used if it is not set.
+func (a *Service) querySla() int {
+	if a.Context.SupportSla <= 0 {
+		return 30
+	}
+	return a.Context.SupportSla
+}
+
+// queryReport - This function returns the schema of the report of the given jurisdiction from the configuration.
+func (a *Service) queryReport(jurisdiction string) (*assets.Report, error) {
+	for _, schema := range a.Context.Reports {
+		if schema.Jurisdiction == jurisdiction {
+			return schema, nil
+		}
+	}
+	return nil, status.Error(11713, "the report of the jurisdiction is not configured")
+}
+
+// writeDelivery - This function delivers the stored report to the sftp server of the jurisdiction and records the result of the
+// delivery, a failed delivery is retried by the report replay.
+func (a *Service) writeDelivery(schema *assets.Report, id int64, name string, data []byte) (string, error) {
+
+	// The status of the report is failed until the report has been delivered, the error of the delivery is kept for the operators.
+	var (
+		state   = types.StatusFilled
+		message string
+	)
+
+	if schema.Sftp == nil {
+		state, message = types.StatusFailed, "the sftp server of the jurisdiction is not configured"
+	} else if err := schema.Sftp.Deliver(name, data); err != nil {
+		state, message = types.StatusFailed, err.Error()
+	}
+
+	if _, err := a.Context.Db.Exec("update reports set status = $1, error = $2 where id = $3;", state, message, id); err != nil {
+		return state, err
+	}
+
+	return state, nil
+}
+
+// WriteReport - This function generates the end-of-day transaction report of the given jurisdiction and day from the trades, in
+// the format and with the fields of the schema of the regulator, stores it and delivers it. Every call adds a new version
+// of the report, the first version is made of new records and the next versions resubmit the day as corrections.
+func (a *Service) WriteReport(jurisdiction, day string) (*types.Report, error) {
+
+	var (
+		item = types.Report{
+			Jurisdiction: jurisdiction,
+			Day:          day,
+		}
+		records []map[string]string
+	)
+
+	// This code checks that the day is a valid date and that the jurisdiction has a schema in the configuration.
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return &item, status.Error(11712, "invalid day of the report")
+	}
+
+	schema, err := a.queryReport(jurisdiction)
+	if err != nil {
+		return &item, err
+	}
+
+	// The version of the report follows the last version of the same day, a version above the first one is a correction.
+	if err := a.Context.Db.QueryRow("select coalesce(max(version), 0) + 1 from reports where jurisdiction = $1 and day = $2", jurisdiction, day).Scan(&item.Version); err != nil {
+		return &item, err
+	}
+
+	action := report.ActionNew
+	if item.GetVersion() > 1 {
+		action = report.ActionCorrection
+	}
+
+	// This code queries the trades of the day, the supply records of the market makers are not trades and are not reported.
+	rows, err := a.Context.Reader().Query(`select uid, user_id, order_id, base_unit, quote_unit, price, quantity, assigning, fees, maker, create_at from trades where create_at >= $1::date and create_at < $1::date + 1 and assigning <> $2 order by id`, day, types.AssigningSupply)
+	if err != nil {
+		return &item, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			trade   types.Trade
+			orderId int64
+			create  time.Time
+		)
+
+		if err := rows.Scan(&trade.Uid, &trade.UserId, &orderId, &trade.BaseUnit, &trade.QuoteUnit, &trade.Price, &trade.Quantity, &trade.Assigning, &trade.Fees, &trade.Maker, &create); err != nil {
+			return &item, err
+		}
+
+		// The record holds all the fields a schema can refer to, the fields of the schema select and rename them.
+		records = append(records, map[string]string{
+			"uid":          trade.GetUid(),
+			"user_id":      strconv.FormatInt(trade.GetUserId(), 10),
+			"order_id":     strconv.FormatInt(orderId, 10),
+			"base_unit":    trade.GetBaseUnit(),
+			"quote_unit":   trade.GetQuoteUnit(),
+			"price":        strconv.FormatFloat(trade.GetPrice(), 'f', -1, 64),
+			"quantity":     strconv.FormatFloat(trade.GetQuantity(), 'f', -1, 64),
+			"assigning":    trade.GetAssigning(),
+			"fees":         strconv.FormatFloat(trade.GetFees(), 'f', -1, 64),
+			"maker":        strconv.FormatBool(trade.GetMaker()),
+			"create_at":    create.UTC().Format(time.RFC3339),
+			"action":       action,
+			"version":      strconv.Itoa(int(item.GetVersion())),
+			"jurisdiction": jurisdiction,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return &item, err
+	}
+
+	data, err := report.Encode(schema.Format, schema.Fields, records)
+	if err != nil {
+		return &item, err
+	}
+
+	item.Name = report.Name(jurisdiction, day, item.GetVersion(), schema.Format)
+	item.Count = int32(len(records))
+
+	// The report is stored before the delivery, so that the same file can be delivered again if the delivery fails.
+	if err := a.Context.Db.QueryRow("insert into reports (jurisdiction, day, version, name, count, data) values ($1, $2, $3, $4, $5, $6) returning id, create_at", jurisdiction, day, item.GetVersion(), item.GetName(), item.GetCount(), data).Scan(&item.Id, &item.CreateAt); err != nil {
+		return &item, err
+	}
+
+	if item.Status, err = a.writeDelivery(schema, item.GetId(), item.GetName(), data); err != nil {
+		return &item, err
+	}
+
+	return &item, nil
+}
+
+// WriteInvalidate - This function invalidates the cached data of the pair after its configuration has been changed by the operators:
+// the summary of the markets is removed, so that it is rebuilt with the new configuration, and the version of the candles
+// is increased, so that the candles cached before the change are not served anymore.
+func (a *Service) WriteInvalidate(base, quote string) {
+	a.Context.Debug(a.Context.RedisClient.Del(context.Background(), "markets:summary").Err())
+	a.writeVersion(base, quote)
+}
+
+// QueryDelisting - This function returns the delisting of the asset which is in progress, an asset is being delisted from the
+// moment its delisting is scheduled until its residual balances are converted into the quote asset. An error is returned
+// when the asset is not being delisted.
+func (a *Service) QueryDelisting(symbol string) (*types.Delisting, error) {
+
+	var (
+		item          types.Delisting
+		cancelAt      time.Time
+		withdrawUntil time.Time
+	)
+
+	if err := a.Context.Db.QueryRow("select id, symbol, quote_unit, price, cancel_at, withdraw_until, status from delistings where symbol = $1 and status <> $2 order by id desc limit 1", symbol, types.DelistingConverted).Scan(&item.Id, &item.Symbol, &item.QuoteUnit, &item.Price, &cancelAt, &withdrawUntil, &item.Status); err != nil {
+		return &item, err
+	}
+	item.CancelAt, item.WithdrawUntil = cancelAt.UTC().Format(time.RFC3339), withdrawUntil.UTC().Format(time.RFC3339)
+
+	return &item, nil
+}
+
+// QueryZone - This function returns the market zone with its trading hours and its coming holidays, the session the market is
+// in at the moment and the time of the next opening of the regular session.
+func (a *Service) QueryZone(name string) (*types.Zone, error) {
+
+	var (
+		item     types.Zone
+		days     pq.Int32Array
+		holidays []help.Holiday
+	)
+
+	if err := a.Context.Db.QueryRow("select id, name, timezone, pre_open, open, close, post_close, days, queue, status, create_at from zones where name = $1", name).Scan(&item.Id, &item.Name, &item.Timezone, &item.PreOpen, &item.Open, &item.Close, &item.PostClose, &days, &item.Queue, &item.Status, &item.CreateAt); err != nil {
+		return &item, err
+	}
+	item.Days = days
+
+	// The holidays are taken from the day before, since the local date of the zone may be behind the date of the server.
+	rows, err := a.Context.Db.Query("select id, zone, to_char(date, 'YYYY-MM-DD'), close, title from holidays where zone = $1 and date >= current_date - 1 order by date", name)
+	if err != nil {
+		return &item, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			holiday types.Holiday
+		)
+
+		if err := rows.Scan(&holiday.Id, &holiday.Zone, &holiday.Date, &holiday.Close, &holiday.Title); err != nil {
+			return &item, err
+		}
+
+		item.Holidays = append(item.Holidays, &holiday)
+		holidays = append(holidays, help.Holiday{Date: holiday.GetDate(), Close: holiday.GetClose()})
+	}
+
+	location, err := time.LoadLocation(item.GetTimezone())
+	if err != nil {
+		return &item, err
+	}
+
+	hours := help.Hours{
+		Location:  location,
+		PreOpen:   item.GetPreOpen(),
+		Open:      item.GetOpen(),
+		Close:     item.GetClose(),
+		PostClose: item.GetPostClose(),
+		Days:      item.GetDays(),
+	}
+
+	if item.Session, err = help.Session(time.Now(), hours, holidays); err != nil {
+		return &item, err
+	}
+
+	if next, err := help.NextOpen(time.Now(), hours, holidays); err == nil {
+		item.NextOpen = next.UTC().Format(time.RFC3339)
+	}
+
+	return &item, nil
+}
+
+// queryHours - This function checks the order on a stock against the trading hours of the market zone of the stock. The orders
+// are accepted in the regular session, and in the pre-market and the post-market when the order is flagged for the
+// extended hours. Outside of them the order is queued until the opening of the regular session when the zone queues the
+// orders, and rejected otherwise. The stocks without a zone, and the zones which are turned off, are traded around the clock.
+func (a *Service) queryHours(order *types.Order) (queue bool, err error) {
+
+	var (
+		name string
+	)
+
+	if order.GetType() != types.TypeStock {
+		return false, nil
+	}
+
+	if _ = a.Context.Db.QueryRow("select zone from assets where symbol = $1", order.GetBaseUnit()).Scan(&name); len(name) == 0 {
+		return false, nil
+	}
+
+	zone, err := a.QueryZone(name)
+	if errors.Is(err, sql.ErrNoRows) || !zone.GetStatus() {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	switch zone.GetSession() {
+	case help.SessionRegular:
+		return false, nil
+	case help.SessionPre, help.SessionPost:
+		if order.GetExtended() {
+			return false, nil
+		}
+	}
+
+	if zone.GetQueue() {
+		return true, nil
+	}
+
+	return false, status.Errorf(11700, "the market %v is closed, the regular session opens at %v", zone.GetName(), zone.GetNextOpen())
+}
+
+// queryListed - This function rejects the orders on the pairs whose base or quote asset is being delisted, the new orders are
+// blocked from the moment the delisting is scheduled.
+func (a *Service) queryListed(order *types.Order) error {
+
+	for _, symbol := range []string{order.GetBaseUnit(), order.GetQuoteUnit()} {
+		if _, err := a.QueryDelisting(symbol); err == nil {
+			return status.Errorf(11634, "the asset %v is being delisted, new orders are not accepted", symbol)
+		}
+	}
+
+	return nil
+}
+
+// writeSuspend - This function suspends the trading of an asset being delisted at the scheduled time: the pending orders on its
+// pairs are cancelled and the reserved amounts are returned to the balances, the same way as when the users cancel them,
+// and the pairs are disabled.
+func (a *Service) writeSuspend(delisting *types.Delisting) error {
+
+	var (
+		orders []*types.Order
+	)
+
+	rows, err := a.Context.Db.Query("update orders set status = $1 where status = $2 and (base_unit = $3 or quote_unit = $3) returning id, uid, value, quantity, price, assigning, base_unit, quote_unit, user_id, type, create_at", types.StatusCancel, types.StatusPending, delisting.GetSymbol())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Order
+		)
+
+		if err := rows.Scan(&item.Id, &item.Uid, &item.Value, &item.Quantity, &item.Price, &item.Assigning, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.Type, &item.CreateAt); err != nil {
+			return err
+		}
+		item.Status = types.StatusCancel
+
+		orders = append(orders, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range orders {
+
+		a.Context.Debug(a.writeJournal(types.JournalCancel, item, 0, item.GetPrice(), item.GetValue()))
+
+		// The funds held by the cancelled orders become available again.
+		if err := a.ReleaseHold(types.HoldOrder, item.GetId()); a.Context.Debug(err) {
+			continue
+		}
+
+		a.Context.Debug(a.Context.Publish(item, "exchange", "order/cancel"))
+	}
+
+	pairs, err := a.Context.Db.Query("update pairs set status = false where base_unit = $1 or quote_unit = $1 returning base_unit, quote_unit", delisting.GetSymbol())
+	if err != nil {
+		return err
+	}
+	defer pairs.Close()
+
+	for pairs.Next() {
+
+		var (
+			item types.Pair
+		)
+
+		if err := pairs.Scan(&item.BaseUnit, &item.QuoteUnit); err != nil {
+			return err
+		}
+
+		a.WriteInvalidate(item.GetBaseUnit(), item.GetQuoteUnit())
+	}
+
+	if _, err := a.Context.Db.Exec("update delistings set status = $2 where id = $1", delisting.GetId(), types.DelistingSuspended); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeConvert - This function converts the residual balances of a delisted asset into the quote asset at the end of the grace
+// period for the withdrawals. The price of the conversion is the price fixed by the operators, or the last price of the
+// pair when it is not fixed. Every conversion is recorded for the audit and the asset is disabled at the end.
+func (a *Service) writeConvert(delisting *types.Delisting) error {
+
+	var (
+		conversions []*types.Conversion
+	)
+
+	price := delisting.GetPrice()
+	if price <= 0 {
+		price, _ = a.queryPrice(delisting.GetSymbol(), delisting.GetQuoteUnit())
+	}
+
+	if price <= 0 {
+		return status.Errorf(11635, "there is no price to convert %v into %v, the price of the conversion must be set", delisting.GetSymbol(), delisting.GetQuoteUnit())
+	}
+
+	rows, err := a.Context.Db.Query("select user_id, type, value from balances where symbol = $1 and value > 0", delisting.GetSymbol())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		item := types.Conversion{
+			DelistingId: delisting.GetId(),
+			Symbol:      delisting.GetSymbol(),
+			QuoteUnit:   delisting.GetQuoteUnit(),
+			Price:       price,
+		}
+
+		if err := rows.Scan(&item.UserId, &item.Type, &item.Value); err != nil {
+			return err
+		}
+		item.Converted = decimal.New(item.GetValue()).Mul(price).Float()
+
+		conversions = append(conversions, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range conversions {
+
+		// The balance of the quote asset is created when the user does not have it yet.
+		if err := a.writeAsset(item.GetQuoteUnit(), item.GetType(), item.GetUserId(), false); a.Context.Debug(err) {
+			continue
+		}
+
+		if err := a.WriteBalance(item.GetSymbol(), item.GetType(), item.GetUserId(), decimal.New(item.GetValue()), types.BalanceMinus); a.Context.Debug(err) {
+			continue
+		}
+
+		if err := a.WriteBalance(item.GetQuoteUnit(), item.GetType(), item.GetUserId(), decimal.New(item.GetConverted()), types.BalancePlus); a.Context.Debug(err) {
+			continue
+		}
+
+		if err := a.Context.Db.QueryRow("insert into conversions (delisting_id, user_id, symbol, type, value, quote_unit, price, converted) values ($1, $2, $3, $4, $5, $6, $7, $8) returning id, create_at", item.GetDelistingId(), item.GetUserId(), item.GetSymbol(), item.GetType(), item.GetValue(), item.GetQuoteUnit(), item.GetPrice(), item.GetConverted()).Scan(&item.Id, &item.CreateAt); a.Context.Debug(err) {
+			continue
+		}
+
+		a.Context.Debug(a.Context.Publish(item, "exchange", "asset/conversion"))
+	}
+
+	if _, err := a.Context.Db.Exec("update assets set status = false where symbol = $1", delisting.GetSymbol()); err != nil {
+		return err
+	}
+
+	if _, err := a.Context.Db.Exec("update delistings set status = $2 where id = $1", delisting.GetId(), types.DelistingConverted); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// QueryIdentifier - This function resolves the external identifier (uuid) of a row into its internal id. The orders, trades and
+// transactions are exposed to the clients by their uuid, so that the sequential ids do not leak the volume of the
+// exchange, while the internal ids are still used for all the relations between the tables.
+func (a *Service) QueryIdentifier(table, uid string) (id int64, err error) {
+
+	// This code checks that the identifier is a valid uuid, so that a malformed value is rejected before the query is made.
+	if _, err := uuid.FromString(uid); err != nil {
+		return id, status.Error(10542, "invalid identifier")
+	}
+
+	// This code requests the internal id of the row with the given uuid, the table name is never taken from the request.
+	if err := a.Context.Db.QueryRow(fmt.Sprintf("select id from %s where uid = $1", table), uid).Scan(&id); err != nil {
+		return id, status.Error(10543, "the row with the given identifier was not found")
+	}
+
+	return id, nil
+}
+
+// QueryGaps - This function detects the gaps in the candles of the given pair. A gap is a period of the given resolution in which
+// trades were executed, but no ticks were written into the ohlcv table, for example because the service was restarted.
+// The function returns the start time of every such period in unix seconds.
+func (a *Service) QueryGaps(base, quote, resolution string, from, to int64) (gaps []int64, err error) {
+
+	// This query groups the trades and the trade ticks of the pair into buckets of the given resolution, and returns the
+	// buckets that exist only on the trades side. The supply ticks are excluded, since they are written by the market
+	// replay and do not reflect the executed trades.
+	rows, err := a.Context.Db.Query(fmt.Sprintf(`select extract(epoch from g.bucket)::bigint from (select time_bucket('%[1]s', t.create_at) as bucket from trades as t where t.base_unit = $1 and t.quote_unit = $2 and t.assigning = $3 and t.create_at >= to_timestamp($5) and t.create_at < to_timestamp($6) group by bucket except select time_bucket('%[1]s', o.create_at) as bucket from ohlcv as o where o.base_unit = $1 and o.quote_unit = $2 and o.assigning <> $4 and o.create_at >= to_timestamp($5) and o.create_at < to_timestamp($6) group by bucket) as g order by g.bucket`, help.Resolution(resolution)), base, quote, types.AssigningBuy, types.AssigningSupply, from, to)
+	if err != nil {
+		return gaps, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			gap int64
+		)
+
+		if err := rows.Scan(&gap); err != nil {
+			return gaps, err
+		}
+
+		gaps = append(gaps, gap)
+	}
+
+	return gaps, rows.Err()
+}
+
+// WriteBackfill - This function repairs the gaps in the candles of the given pair, it rebuilds the missing ticks of the ohlcv table
+// from the trades table for every gap found by QueryGaps in the given range. Each executed trade is written into the
+// trades table once per side, so only the buy side is taken to avoid counting the volume twice. The function returns
+// the number of ticks written.
+func (a *Service) WriteBackfill(base, quote, resolution string, from, to int64) (count int64, err error) {
+
+	// The purpose of this code is to find the gaps first, so that the periods which already have their ticks are not touched.
+	gaps, err := a.QueryGaps(base, quote, resolution, from, to)
+	if err != nil {
+		return count, err
+	}
+
+	for _, gap := range gaps {
+
+		// This query copies the trades of the gap period into the ohlcv table, the ticks keep the original time of the trade,
+		// so that the rebuilt candles are the same as if they had been written live.
+		result, err := a.Context.Db.Exec(fmt.Sprintf(`insert into ohlcv (assigning, base_unit, quote_unit, price, quantity, create_at) select t.assigning, t.base_unit, t.quote_unit, t.price, t.quantity, t.create_at from trades as t where t.base_unit = $1 and t.quote_unit = $2 and t.assigning = $3 and t.create_at >= to_timestamp($4) and t.create_at < to_timestamp($4) + '%s'::interval on conflict do nothing`, help.Resolution(resolution)), base, quote, types.AssigningBuy, gap)
+		if err != nil {
+			return count, err
+		}
+
+		if affected, err := result.RowsAffected(); err == nil {
+			count += affected
+		}
+	}
+
+	return count, nil
+}
+
+// WriteBalance - This function is used to update the balance of a user in a database. Depending on the cross parameter, either the
+// balance is increased (types.BalancePlus) or decreased (types.BalanceMinus) by a given quantity. The balance is
+// updated in the assets table of the database, using a query. Finally, an error is returned if an error occurred during the update.
+// The quantity is the decimal and is bound as its decimal string, so the numeric column is changed by it exactly. The
+// amounts of the messages of the api are still the doubles, they are turned into the decimals once, where they enter.
+func (a *Service) WriteBalance(symbol, _type string, userId int64, quantity *decimal.Float, cross string) error {
+
+	switch cross {
+	case types.BalancePlus:
+
+		// The code above is an if statement that is used to update the balance of an asset with a given symbol and user_id in
+		// a database. The statement executes an update query, passing in the values of symbol, quantity, and userId as
+		// parameters to the query. If the query fails to execute, the if statement will return an error.
+		if _, err := a.Context.Db.Exec("update balances set value = value + $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity.String(), userId, _type); err != nil {
+			return err
+		}
+		break
+	case types.BalanceMinus:
+
+		// This code is used to update the balance of a user's assets in a database. The code updates the user's balance by
+		// subtracting the quantity given. The values being used to update the balance are stored in variables, and are passed
+		// into the code as parameters ($1, $2, and $3). The code also checks for errors and returns an error if one is found.
+		if _, err := a.Context.Db.Exec("update balances set value = value - $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity.String(), userId, _type); err != nil {
+			return err
+		}
+		break
+	}
+
+	return nil
+}
+
+// WriteHold - This function holds the value of the balance of the user for the order or the withdrawal of the reference, the held
+// funds stay on the balance but are no longer available. The balance is locked while the holds of it are summed up, so
+// that the holds placed at once can not take more than the available balance.
+func (a *Service) WriteHold(symbol, _type string, userId int64, value float64, reference string, referenceId int64) error {
+
+	var (
+		balance, held float64
+	)
+
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("select value from balances where symbol = $1 and user_id = $2 and type = $3 for update", symbol, userId, _type).Scan(&balance); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err := tx.QueryRow("select coalesce(sum(value), 0) from holds where symbol = $1 and user_id = $2 and type = $3", symbol, userId, _type).Scan(&held); err != nil {
+		return err
+	}
+
+	if err := queryAvailable(symbol, balance, held, value); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("insert into holds (user_id, symbol, type, value, reference, reference_id) values ($1, $2, $3, $4, $5, $6)", userId, symbol, _type, value, reference, referenceId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queryAvailable - This function checks that the value can be held on the balance next to the values which are already held on it,
+// the value must be positive and must not exceed the balance less the holds.
+func queryAvailable(symbol string, balance, held, value float64) error {
+
+	if value <= 0 || decimal.New(balance).Sub(held).Float() < value {
+		return status.Errorf(11714, "there are not enough available funds on the %v balance, %v of it is held by the open orders and withdrawals", symbol, held)
+	}
+
+	return nil
+}
+
+// ReleaseHold - This function removes the hold of the reference, the funds held by the cancelled order or withdrawal become
+// available again. The balance itself is not changed.
+func (a *Service) ReleaseHold(reference string, referenceId int64) error {
+
+	if _, err := a.Context.Db.Exec("delete from holds where reference = $1 and reference_id = $2", reference, referenceId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SettleHold - This function spends the whole hold of the reference, the balance is debited by the held value and the hold is
+// removed. It is used when the withdrawal is sent.
+func (a *Service) SettleHold(reference string, referenceId int64) error {
+
+	var (
+		userId        int64
+		symbol, _type string
+		value         float64
+	)
+
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("delete from holds where reference = $1 and reference_id = $2 returning user_id, symbol, type, value", reference, referenceId).Scan(&userId, &symbol, &_type, &value); err != nil {
+
+		// The withdrawals created before the holds were introduced have nothing to settle.
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	if _, err := tx.Exec("update balances set value = value - $1 where symbol = $2 and user_id = $3 and type = $4", value, symbol, userId, _type); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// writeSettle - This function spends the funds of the order filled by the value at the price of the trade. The balance is debited
+// by the value the order pays, and the hold of the order is reduced by the value it reserved for the fill: the buy order
+// reserved the quote value at its own price, so the difference to the lower price of the trade becomes available again.
+// The rest of the hold is released once the order is filled.
+func (a *Service) writeSettle(order *types.Order, value, price float64, filled bool) error {
+
+	// The amounts are passed to the numeric columns as decimal strings, so the products of the value and the prices are not
+	// rounded to the float on the way.
+	var (
+		symbol, spent, release = order.GetBaseUnit(), decimal.New(value).String(), decimal.New(value).String()
+	)
+
+	if order.GetAssigning() == types.AssigningBuy {
+		symbol, spent, release = order.GetQuoteUnit(), decimal.New(value).Mul(price).String(), decimal.New(value).Mul(order.GetPrice()).String()
+	}
+
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("update balances set value = value - $1 where symbol = $2 and user_id = $3 and type = $4", spent, symbol, order.GetUserId(), order.GetType()); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("update holds set value = greatest(value - $3, 0) where reference = $1 and reference_id = $2", types.HoldOrder, order.GetId(), release); err != nil {
+		return err
+	}
+
+	if filled {
+		if _, err := tx.Exec("delete from holds where reference = $1 and reference_id = $2", types.HoldOrder, order.GetId()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// WriteTransaction - The purpose of this code is to set the transaction of a service. It checks if a transaction exists, then generates a
+// unique identifier if it does not. It then inserts the transaction information into a database table, and sets the
+// chain associated with the transaction. It then sets the chain.RPC and chainId values to empty strings and zero
+// respectively. It then checks if the transaction has a parent, and if it does, it updates the allocation and status of
+// the transaction and its parent in the database. Finally, it returns the transaction if the operation was successful, or nil if not.
+func (a *Service) WriteTransaction(transaction *types.Transaction) (*types.Transaction, error) {
+
+	// The purpose of the code snippet is to declare two variables, exist and err. exist is of type bool and err is of type
+	// error. This is typically used when programming to indicate the existence of an error or to store the value of an error.
+	var (
+		exist bool
+		err   error
+	)
+
+	// The purpose of this code is to check if the transaction exists in e.getTransactionExist(transaction.GetHash()). If
+	// the transaction does not exist (i.e. !exist is true) then the code will execute the statements that follow.
+	if _ = a.Context.Db.QueryRow("select exists(select id from transactions where hash = $1)::bool", transaction.GetHash()).Scan(&exist); !exist {
+
+		// This code is used to generate a unique identifier (in this case a UUID) for a transaction if it doesn't already have
+		// one. This UUID can be used to identify the transaction uniquely and ensure that it is not a duplicate of another transaction.
+		if len(transaction.GetHash()) == 0 {
+			transaction.Hash = uuid.NewV1().String()
+		}
+
+		// This code is a SQL query to insert transaction information into a database table called "transactions". It is
+		// assigning values to each of the 13 columns in the table, and then returning the id, CreateAt, and Status columns in
+		// the same row. It is then using the Scan() function to assign the returned values to the transaction object. The
+		// transaction whose hash was recorded in the meantime, by another scanner of the chain, is not recorded again and is
+		// returned without its id.
+		if err := a.Context.Db.QueryRow(`insert into transactions (symbol, hash, value, fees, confirmation, "to", block, chain_id, user_id, assignment, "group", platform, protocol, allocation, parent, block_hash, "from") values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) on conflict (hash) do nothing returning id, uid, create_at, status;`,
+			transaction.GetSymbol(),
+			transaction.GetHash(),
+			transaction.GetValue(),
+			transaction.GetFees(),
+			transaction.GetConfirmation(),
+			transaction.GetTo(),
+			transaction.GetBlock(),
+			transaction.GetChainId(),
+			transaction.GetUserId(),
+			transaction.GetAssignment(),
+			transaction.GetGroup(),
+			transaction.GetPlatform(),
+			transaction.GetProtocol(),
+			transaction.GetAllocation(),
+			transaction.GetParent(),
+			transaction.GetBlockHash(),
+			transaction.GetFrom(),
+		).Scan(&transaction.Id, &transaction.Uid, &transaction.CreateAt, &transaction.Status); errors.Is(err, sql.ErrNoRows) {
+			return transaction, nil
+		} else if err != nil {
+			return transaction, err
+		}
+
+		// This code is getting the chain associated with the transaction. The first line is getting the chain, and the second
+		// line checks if there has been an error. If there is an error, the code returns nil and the error.
+		transaction.Chain, err = a.QueryChain(transaction.GetChainId(), false)
+		if err != nil {
+			return transaction, err
+		}
+
+		// This code sets the Chain.Rpc and ChainId values of the transaction variable to empty strings and zero respectively.
+		// This is likely used to reset the transaction variable to its default values.
+		transaction.Chain.Rpc, transaction.ChainId = "", 0
+
+		return transaction, nil
+	} else {
+
+		// This code is used to update the status of a transaction in a database. The first `if` statement checks if the
+		// transaction has a parent. If it does, the code will execute two `Exec` commands in order to update the allocation
+		// and status of the transaction and its parent in the database.
+		if _ = a.Context.Db.QueryRow("select exists(select id from transactions where hash = $1 and allocation = $2)::bool", transaction.GetHash(), types.AllocationInternal).Scan(&exist); exist {
+
+			// This code is used to update a transaction in a database. It sets the assignment to DEPOSIT and the status to
+			// PENDING by using the hash of the transaction as an identifier. The if statement is used to check for any errors
+			// that may occur while executing the query. If an error occurs, the transaction is returned without any changes.
+			if _, err := a.Context.Db.Exec("update transactions set assignment = $1, status = $2 where hash = $3;", types.AssignmentDeposit, types.StatusPending, transaction.GetHash()); err != nil {
+				return transaction, err
+			}
+
+		}
+	}
+
+	return transaction, nil
+}
+
+// WriteReserve - This function is used to set a reserve for a user in a database. It takes the userId, address, symbol, value,
+// platform, protocol, and cross as parameters. It first checks if the reserve already exists in the database. If it
+// does, it updates it depending on the value of "cross." If the reserve does not exist, it inserts a new row into the database.
+func (a *Service) WriteReserve(userId int64, address, symbol string, value float64, platform, protocol, cross string) error {
+
+	if len(protocol) == 0 {
+		protocol = types.ProtocolMainnet
+	}
+
+	// This code is querying a database for a specific set of information. The code is using placeholders ($1, $2, etc.) to
+	// make the query more secure by preventing SQL injection. The row variable is the result of the query, and the defer
+	// statement ensures that the connection to the database is closed after the query has finished. Finally, the if
+	// statement is used to check for any errors that might occur during the query.
+	row, err := a.Context.Db.Query("select id from reserves where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5", userId, symbol, platform, protocol, address)
+	if err != nil {
+		return err
+	}
+	defer row.Close()
+
+	// The purpose of this statement is to check if there is a row available to be read from a database. If so, the code
+	// within the if block will run. If not, it will skip the code within the if block.
+	if row.Next() {
+
+		switch cross {
+		case types.BalancePlus:
+
+			// This code is updating a database table called "reserves" with values provided by the user. The code first checks to
+			// see if the update is successful, and if it fails, it returns an error.
+			if _, err := a.Context.Db.Exec("update reserves set value = value + $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, protocol, address, value); err != nil {
+				return err
+			}
+			break
+		case types.BalanceMinus:
+
+			// This code is updating a database table called "reserves" with values provided by the user. The code first checks to
+			// see if the update is successful, and if it fails, it returns an error.
+			if _, err := a.Context.Db.Exec("update reserves set value = value - $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, protocol, address, value); err != nil {
+				return err
+			}
+			break
+		}
+
+		return nil
+	}
+
+	// This code is performing an SQL query to insert data into a database table called "reserves". The data being inserted
+	// consists of user_id, symbol, platform, protocol, address, and value. If there is an error in executing the query, the
+	// function will return the error.
+	if _, err = a.Context.Db.Exec("insert into reserves (user_id, symbol, platform, protocol, address, value) values ($1, $2, $3, $4, $5, $6)", userId, symbol, platform, protocol, address, value); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WriteReserveLock - This function is used to update a record in the 'reserves' table in a database. It sets the 'lock' column to 'true'
+// for a record that matches the given userId, symbol, platform and protocol. This will help ensure that only one process
+// can access this record in the table at any given time, allowing for concurrent access to the table without conflicts.
+func (a *Service) WriteReserveLock(userId int64, symbol, platform, protocol string) error {
+
+	if len(protocol) == 0 {
+		protocol = types.ProtocolMainnet
+	}
+
+	// This code is used to update the "lock" field in the "reserves" table in the database. The specific record to be
+	// updated is identified using the userId, symbol, platform, and protocol values provided as arguments to the Exec()
+	// function. If the update operation is not successful, an error is returned.
+	if _, err := a.Context.Db.Exec("update reserves set lock = $5 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4;", userId, symbol, platform, protocol, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteReserveUnlock - The purpose of this function is to update the "lock" field of the "reserves" table in the database to false, where the
+// user_id, symbol, platform, and protocol fields all match the given parameters. This is likely used to allow users to
+// access the reserves for a given symbol on a given platform and protocol.
+func (a *Service) WriteReserveUnlock(userId int64, symbol, platform, protocol string) error {
+
+	if len(protocol) == 0 {
+		protocol = types.ProtocolMainnet
+	}
+
+	// This code is part of an if statement and is used to update a database table. The code is used to update a "reserves"
+	// table in the database, setting the "lock" field to false where the user ID, symbol, platform, and protocol all match
+	// the given parameters. If any errors occur while executing the query, the code will return an error.
+	if _, err := a.Context.Db.Exec("update reserves set lock = $5 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4;", userId, symbol, platform, protocol, false); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteReverse - The purpose of this code is to query a database for a specific set of information, insert data into a database table,
+// and update the value of an existing record in the database. The code uses SQL queries to perform these operations and
+// also checks for errors that may occur in the process.
+func (a *Service) WriteReverse(userId int64, address, symbol string, value float64, platform, cross string) error {
+
+	// This code is querying a database for a specific set of information. The code is using placeholders ($1, $2, etc.) to
+	// make the query more secure by preventing SQL injection. The row variable is the result of the query, and the defer
+	// statement ensures that the connection to the database is closed after the query has finished. Finally, the if
+	// statement is used to check for any errors that might occur during the query.
+	row, err := a.Context.Db.Query("select id from reserves where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5", userId, symbol, platform, types.ProtocolMainnet, address)
+	if err != nil {
+		return err
+	}
+	defer row.Close()
+
+	// The purpose of this statement is to check if there is a row available to be read from a database. If so, the code
+	// within the if block will run. If not, it will skip the code within the if block.
+	if row.Next() {
+
+		switch cross {
+		case types.BalancePlus:
+
+			// This code is updating a database entry with the given parameters. The specific entry is the 'reverse' field of the
+			// 'reserves' table, and the update is an increase by the amount of 'value'. The other parameters (userId, symbol,
+			// platform, protocol, address) are used to specify which entry should be updated. If an error is encountered, the
+			// code will return an error.
+			if _, err := a.Context.Db.Exec("update reserves set reverse = reverse + $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, types.ProtocolMainnet, address, value); err != nil {
+				return err
+			}
+			break
+		case types.BalanceMinus:
+
+			// This code is updating a database entry with the given parameters. The specific entry is the 'reverse' field of the
+			// 'reserves' table, and the update is an increase by the amount of 'value'. The other parameters (userId, symbol,
+			// platform, protocol, address) are used to specify which entry should be updated. If an error is encountered, the
+			// code will return an error.
+			if _, err := a.Context.Db.Exec("update reserves set reverse = reverse - $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, types.ProtocolMainnet, address, value); err != nil {
+				return err
+			}
+			break
+		}
+
+		return nil
+	}
+
+	// This code is performing an SQL query to insert data into a database table called "reserves". The data being inserted
+	// consists of user_id, symbol, platform, protocol, address, and value. If there is an error in executing the query, the
+	// function will return the error.
+	if _, err = a.Context.Db.Exec("insert into reserves (user_id, symbol, platform, protocol, address, reverse) values ($1, $2, $3, $4, $5, $6)", userId, symbol, platform, types.ProtocolMainnet, address, value); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// queryRoute - This function finds the spot pair the rule converts the symbol into the target through: the symbol is sold on the
+// pair symbol/target, or the target is bought with the symbol on the pair target/symbol.
+func (a *Service) queryRoute(symbol, target string) (base, quote, assigning string, err error) {
+
+	if err := a.queryValidatePair(symbol, target, types.TypeSpot); err == nil {
+		return symbol, target, types.AssigningSell, nil
+	}
+
+	if err := a.queryValidatePair(target, symbol, types.TypeSpot); err == nil {
+		return target, symbol, types.AssigningBuy, nil
+	}
+
+	return base, quote, assigning, status.Errorf(11672, "there is no pair to convert %v into %v", symbol, target)
+}
+
+// writeRule - This function executes the rule: the value of the symbol is converted into the target of the rule by a market
+// order placed on behalf of the user, exactly as if the user placed it. The execution is recorded in the history of the
+// rule whatever its result is, the reference is the id of the deposit that triggered the rule.
+func (a *Service) writeRule(rule *types.Rule, value float64, reference int64) (*types.RuleExecution, error) {
+
+	var (
+		order     types.Order
+		execution = types.RuleExecution{
+			RuleId:    rule.GetId(),
+			Reference: reference,
+			Value:     value,
+			Status:    types.StatusFilled,
+		}
+	)
+
+	err := func() error {
+
+		// The conversions of the rules are the orders of the user, the restrictions of the account apply to them.
+		if err := a.Context.Restricted(rule.GetUserId(), types.ActionTrade); err != nil {
+			return err
+		}
+
+		base, quote, assigning, err := a.queryRoute(rule.GetSymbol(), rule.GetTarget())
+		if err != nil {
+			return err
+		}
+
+		order.Type = types.TypeSpot
+		order.Trading = types.TradingMarket
+		order.UserId = rule.GetUserId()
+		order.BaseUnit = base
+		order.QuoteUnit = quote
+		order.Assigning = assigning
+		order.Status = types.StatusPending
+		order.CreateAt = time.Now().UTC().Format(time.RFC3339)
+
+		if order.Price = a.queryMarket(base, quote, types.TypeSpot, assigning, 0); order.GetPrice() <= 0 {
+			return status.Errorf(11673, "there is no market price of the pair %v-%v", base, quote)
+		}
+
+		// The value of the rule is in the symbol, it is the base unit of the sell orders and the quote unit of the buy orders.
+		order.Quantity, order.Value = value, value
+		if assigning == types.AssigningBuy {
+			order.Quantity, order.Value = decimal.New(value).Div(order.GetPrice()).Float(), decimal.New(value).Div(order.GetPrice()).Float()
+		}
+
+		if _, err := a.writePlace(context.Background(), &order); err != nil {
+			return err
+		}
+
+		return nil
+	}()
+
+	if err != nil {
+		execution.Status, execution.Error = types.StatusFailed, err.Error()
+	}
+	execution.OrderId = order.GetId()
+
+	if err := a.Context.Db.QueryRow("insert into rule_executions (rule_id, user_id, order_id, reference, value, status, error) values ($1, $2, $3, $4, $5, $6, $7) returning id, create_at", execution.GetRuleId(), rule.GetUserId(), execution.GetOrderId(), execution.GetReference(), execution.GetValue(), execution.GetStatus(), execution.GetError()).Scan(&execution.Id, &execution.CreateAt); err != nil {
+		return &execution, err
+	}
+
+	return &execution, nil
+}
+
+// queryRamp - This function reports whether the deposits of the symbol are converted by the ramp: the ramp is configured and the
+// symbol is one of the stablecoins of the ramp.
+func (a *Service) queryRamp(symbol string) bool {
+
+	if a.Context.Ramp == nil {
+		return false
+	}
+
+	for _, item := range a.Context.Ramp.Symbols {
+		if item == symbol {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteRamp - This function converts the credited deposit of a stablecoin into the currency the user chose for the ramp. The
+// conversion is a market order placed on behalf of the user, exactly as if the user placed it, which is matched only
+// within the slippage of the user from the price of the pair: the part of the deposit that is not matched within it is
+// cancelled and stays on the balance in the stablecoin. Every deposit is converted once, the conversion is recorded with
+// the order, the prices and the filled value whatever its result is. The deposits of the users who did not opt in and of
+// the other symbols are left as they are.
+func (a *Service) WriteRamp(deposit *types.Transaction) error {
+
+	var (
+		ramp       types.Ramp
+		order      types.Order
+		conversion = types.RampConversion{
+			DepositId: deposit.GetId(),
+			Symbol:    deposit.GetSymbol(),
+			Value:     deposit.GetValue(),
+			Status:    types.StatusFilled,
+		}
+	)
+
+	if !a.queryRamp(deposit.GetSymbol()) {
+		return nil
+	}
+
+	if err := a.Context.Db.QueryRow("select target, slippage from ramps where user_id = $1 and status = $2", deposit.GetUserId(), true).Scan(&ramp.Target, &ramp.Slippage); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	if ramp.GetTarget() == deposit.GetSymbol() {
+		return nil
+	}
+
+	if ramp.GetSlippage() <= 0 || ramp.GetSlippage() > a.Context.Ramp.Slippage {
+		ramp.Slippage = a.Context.Ramp.Slippage
+	}
+	conversion.Target = ramp.GetTarget()
+
+	// The deposit is claimed by its conversion first, the deposit credited again by another service is not converted twice.
+	if err := a.Context.Db.QueryRow("insert into ramp_conversions (user_id, deposit_id, symbol, target, value, status) values ($1, $2, $3, $4, $5, $6) on conflict (deposit_id) do nothing returning id", deposit.GetUserId(), conversion.GetDepositId(), conversion.GetSymbol(), conversion.GetTarget(), conversion.GetValue(), types.StatusPending).Scan(&conversion.Id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	err := func() error {
+
+		// The conversions of the ramp are the orders of the user, the restrictions of the account apply to them.
+		if err := a.Context.Restricted(deposit.GetUserId(), types.ActionTrade); err != nil {
+			return err
+		}
+
+		base, quote, assigning, err := a.queryRoute(deposit.GetSymbol(), ramp.GetTarget())
+		if err != nil {
+			return err
+		}
+
+		price, ok := a.queryPrice(base, quote)
+		if !ok || price <= 0 {
+			return status.Errorf(11673, "there is no market price of the pair %v-%v", base, quote)
+		}
+		conversion.Reference = price
+
+		// The order buys no higher and sells no lower than the price of the pair moved by the slippage, the resting orders
+		// beyond the limit are not matched.
+		conversion.Limit = decimal.New(price).Mul(decimal.New(100).Sub(ramp.GetSlippage()).Float()).Div(100).Float()
+		if assigning == types.AssigningBuy {
+			conversion.Limit = decimal.New(price).Mul(decimal.New(100).Add(ramp.GetSlippage()).Float()).Div(100).Float()
+		}
+
+		order.Type = types.TypeSpot
+		order.Trading = types.TradingMarket
+		order.UserId = deposit.GetUserId()
+		order.BaseUnit = base
+		order.QuoteUnit = quote
+		order.Assigning = assigning
+		order.Price = conversion.GetLimit()
+		order.Status = types.StatusPending
+		order.CreateAt = time.Now().UTC().Format(time.RFC3339)
+
+		// The value of the deposit is in the stablecoin, it is the base unit of the sell orders and the quote unit of the buy orders.
+		order.Quantity, order.Value = deposit.GetValue(), deposit.GetValue()
+		if assigning == types.AssigningBuy {
+			order.Quantity, order.Value = decimal.New(deposit.GetValue()).Div(order.GetPrice()).Float(), decimal.New(deposit.GetValue()).Div(order.GetPrice()).Float()
+		}
+
+		if _, err := a.writePlace(context.Background(), &order); err != nil {
+			return err
+		}
+
+		// The part of the order that was not matched within the limit does not rest in the book, it is cancelled and its funds
+		// are released back to the balance of the user.
+		if row := a.queryOrder(order.GetId()); row.GetStatus() == types.StatusPending {
+
+			if _, err := a.Context.Db.Exec("update orders set status = $2 where id = $1 and status = $3", row.GetId(), types.StatusCancel, types.StatusPending); err != nil {
+				return err
+			}
+			row.Type = order.GetType()
+			row.Status = types.StatusCancel
+
+			a.Context.Debug(a.writeJournal(types.JournalCancel, row, 0, row.GetPrice(), row.GetValue()))
+
+			if err := a.ReleaseHold(types.HoldOrder, row.GetId()); err != nil {
+				return err
+			}
+
+			if err := a.Context.Publish(row, "exchange", "order/cancel"); err != nil {
+				return err
+			}
+		}
+
+		// The filled value of the deposit and the average price are those of the trades of the order.
+		var (
+			quantity, value float64
+		)
+
+		if err := a.Context.Db.QueryRow("select coalesce(sum(quantity), 0), coalesce(sum(quantity * price), 0), coalesce(sum(fees), 0) from trades where order_id = $1 and user_id = $2", order.GetId(), order.GetUserId()).Scan(&quantity, &value, &conversion.Fees); err != nil {
+			return err
+		}
+
+		if quantity > 0 {
+			conversion.Price = decimal.New(value).Div(quantity).Float()
+		}
+
+		conversion.Filled = quantity
+		if assigning == types.AssigningBuy {
+			conversion.Filled = value
+		}
+
+		if conversion.GetFilled() == 0 {
+			return status.Errorf(11775, "there are no orders of the pair %v-%v within %v%% of the price %v", base, quote, ramp.GetSlippage(), price)
+		}
+
+		return nil
+	}()
+
+	if err != nil {
+		conversion.Status, conversion.Error = types.StatusFailed, err.Error()
+	}
+	conversion.OrderId = order.GetId()
+
+	if _, err := a.Context.Db.Exec(`update ramp_conversions set order_id = $2, reference = $3, "limit" = $4, filled = $5, price = $6, fees = $7, status = $8, error = $9 where id = $1`, conversion.GetId(), conversion.GetOrderId(), conversion.GetReference(), conversion.GetLimit(), conversion.GetFilled(), conversion.GetPrice(), conversion.GetFees(), conversion.GetStatus(), conversion.GetError()); err != nil {
+		return err
+	}
+
+	return a.Context.Publish(&conversion, "exchange", "ramp/status")
+}
+
+// writeCopy - This function mirrors the spot order of the lead trader to the followers of the lead trader. The share of the
+// balance the lead trader committed to the order is committed by every follower, scaled by the ratio of the follower and
+// capped by the maximum share of the balance of the follower. The limit orders are mirrored at the price of the lead
+// trader, the market orders at the current market price. Every mirror is recorded in the history of the follower.
+func (a *Service) writeCopy(lead *types.Order, funds, balance float64) {
+
+	if balance <= 0 || funds <= 0 {
+		return
+	}
+
+	share := decimal.New(funds).Div(balance).Float()
+	if share > 1 {
+		share = 1
+	}
+
+	spent := lead.GetBaseUnit()
+	if lead.GetAssigning() == types.AssigningBuy {
+		spent = lead.GetQuoteUnit()
+	}
+
+	rows, err := a.Context.Db.Query("select id, user_id, ratio, maximum from copies where lead_id = $1 and status = $2 order by id", lead.GetUserId(), true)
+	if a.Context.Debug(err) {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			id, userId     int64
+			ratio, maximum float64
+			order          types.Order
+		)
+
+		if err := rows.Scan(&id, &userId, &ratio, &maximum); a.Context.Debug(err) {
+			continue
+		}
+
+		available := a.QueryBalance(spent, types.TypeSpot, userId)
+
+		value := decimal.New(available).Mul(share).Mul(ratio).Div(100).Float()
+		if limit := decimal.New(available).Mul(maximum).Div(100).Float(); value > limit {
+			value = limit
+		}
+
+		err := func() error {
+
+			// The accounts blocked by the administrators do not trade, not even by the orders mirrored to them.
+			var (
+				active bool
+			)
+
+			if _ = a.Context.Db.QueryRow("select status from accounts where id = $1", userId).Scan(&active); !active {
+				return status.Error(748990, "your account and assets have been blocked, please contact technical support for any questions")
+			}
+
+			// The restrictions of the account apply to the mirrored orders as to the orders of the follower.
+			if err := a.Context.Restricted(userId, types.ActionTrade); err != nil {
+				return err
+			}
+
+			order.Type = types.TypeSpot
+			order.Trading = lead.GetTrading()
+			order.UserId = userId
+			order.BaseUnit = lead.GetBaseUnit()
+			order.QuoteUnit = lead.GetQuoteUnit()
+			order.Assigning = lead.GetAssigning()
+			order.Status = types.StatusPending
+			order.CreateAt = time.Now().UTC().Format(time.RFC3339)
+
+			if order.Price = lead.GetPrice(); order.GetTrading() == types.TradingMarket {
+				order.Price = a.queryMarket(order.GetBaseUnit(), order.GetQuoteUnit(), types.TypeSpot, order.GetAssigning(), lead.GetPrice())
+			}
+
+			if order.GetPrice() <= 0 {
+				return status.Errorf(11673, "there is no market price of the pair %v-%v", order.GetBaseUnit(), order.GetQuoteUnit())
+			}
+
+			// The value is in the currency the funds are taken from, it is the quote unit of the buy orders.
+			order.Quantity, order.Value = value, value
+			if order.GetAssigning() == types.AssigningBuy {
+				order.Quantity, order.Value = decimal.New(value).Div(order.GetPrice()).Float(), decimal.New(value).Div(order.GetPrice()).Float()
+			}
+
+			if _, err := a.writePlace(context.Background(), &order); err != nil {
+				return err
+			}
+
+			return nil
+		}()
+
+		var (
+			result, message = types.StatusFilled, ""
+		)
+
+		if err != nil {
+			result, message = types.StatusFailed, err.Error()
+		}
+
+		if _, err := a.Context.Db.Exec("insert into copy_orders (copy_id, lead_order_id, order_id, user_id, value, status, error) values ($1, $2, $3, $4, $5, $6, $7)", id, lead.GetId(), order.GetId(), userId, value, result, message); a.Context.Debug(err) {
+			continue
+		}
+	}
 }
 
-// QueryContract - This function retrieves a contract from the database based on a given symbol and chain ID. It then sets the fields of
-// a types.Contract struct with the values from the database and returns it. Any errors encountered while retrieving the
-// contract are returned as the second value.
-func (a *Service) QueryContract(symbol string, cid int64) (*types.Contract, error) {
+// queryLocked - This function returns the funds of the user which are held by the open orders and the withdrawals which have
+// not been sent yet, by the sub-wallet and the symbol.
+func (a *Service) queryLocked(userId int64) (map[string]map[string]float64, error) {
 
 	var (
-		contract types.Contract
+		locked = make(map[string]map[string]float64)
 	)
 
-	// This code is checking the database for a contract with the specified symbol and chain ID and then storing the results
-	// of the query in a contract struct. If the query fails, to err is returned.
-	if err := a.Context.Db.QueryRow(`select id, address, fees, protocol, decimals from contracts where symbol = $1 and chain_id = $2`, symbol, cid).Scan(&contract.Id, &contract.Address, &contract.Fees, &contract.Protocol, &contract.Decimals); err != nil {
-		return &contract, err
+	rows, err := a.Context.Db.Query("select type, symbol, sum(value) from holds where user_id = $1 group by type, symbol", userId)
+	if err != nil {
+		return locked, err
 	}
+	defer rows.Close()
 
-	return &contract, nil
-}
-
-// QueryReserve - This function is used to get the total reserve for a given symbol, platform, and protocol from a database. It takes
-// three parameters (symbol, platform, and protocol) and uses a SQL query to get the sum of the values from the reserves
-// table where the symbol, platform, and protocol match the provided parameters. Finally, it returns the total reserve as a float64.
-func (a *Service) QueryReserve(symbol, platform, protocol string) (reserve float64) {
+	for rows.Next() {
 
-	if len(protocol) == 0 {
-		protocol = types.ProtocolMainnet
-	}
+		var (
+			_type, symbol string
+			value         float64
+		)
 
-	// The purpose of this code is to query a database for the sum of values from a specific set of reserves (symbol,
-	// platform, and protocol) and store the result in the reserve variable.
-	_ = a.Context.Db.QueryRow(`select sum(value) from reserves where symbol = $1 and platform = $2 and protocol = $3`, symbol, platform, protocol).Scan(&reserve)
-	return reserve
-}
+		if err := rows.Scan(&_type, &symbol, &value); err != nil {
+			return locked, err
+		}
 
-// QueryReverse - This code is used to get the reverse of a certain user's address for a certain platform and symbol from a database. It
-// takes in the userId, address, symbol, and platform as parameters, and returns the reverse value stored in the database.
-func (a *Service) QueryReverse(userId int64, address, symbol, platform string) (reverse float64) {
+		if _, ok := locked[_type]; !ok {
+			locked[_type] = make(map[string]float64)
+		}
+		locked[_type][symbol] = value
+	}
 
-	// The purpose of this code is to query a database for the sum of values from a specific set of reserves (symbol,
-	// platform, and protocol) and store the result in the reserve variable.
-	_ = a.Context.Db.QueryRow(`select reverse from reserves where user_id = $1 and address = $2 and symbol = $3 and platform = $4 and protocol = $5`, userId, address, symbol, platform, types.ProtocolMainnet).Scan(&reverse)
-	return reverse
+	return locked, rows.Err()
 }
 
-// WriteBalance - This function is used to update the balance of a user in a database. Depending on the cross parameter, either the
-// balance is increased (types.Balance_PLUS) or decreased (types.Balance_MINUS) by a given quantity. The balance is
-// updated in the assets table of the database, using a query. Finally, an error is returned if an error occurred during the update.
-func (a *Service) WriteBalance(symbol, _type string, userId int64, quantity float64, cross string) error {
-
-	switch cross {
-	case types.BalancePlus:
-
-		// The code above is an if statement that is used to update the balance of an asset with a given symbol and user_id in
-		// a database. The statement executes an update query, passing in the values of symbol, quantity, and userId as
-		// parameters to the query. If the query fails to execute, the if statement will return an error.
-		if _, err := a.Context.Db.Exec("update balances set value = value + $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity, userId, _type); err != nil {
-			return err
-		}
-		break
-	case types.BalanceMinus:
+// queryPaper - This function reports whether the user trades on the paper, the orders of such users are simulated and never reach
+// the order book. The paper trading is off for every user when it is not available.
+func (a *Service) queryPaper(userId int64) (paper bool) {
 
-		// This code is used to update the balance of a user's assets in a database. The code updates the user's balance by
-		// subtracting the quantity given. The values being used to update the balance are stored in variables, and are passed
-		// into the code as parameters ($1, $2, and $3). The code also checks for errors and returns an error if one is found.
-		if _, err := a.Context.Db.Exec("update balances set value = value - $2 where symbol = $1 and user_id = $3 and type = $4;", symbol, quantity, userId, _type); err != nil {
-			return err
-		}
-		break
+	if a.Context.Paper == nil {
+		return paper
 	}
 
-	return nil
+	_ = a.Context.Db.QueryRow("select status from papers where user_id = $1", userId).Scan(&paper)
+
+	return paper
 }
 
-// WriteTransaction - The purpose of this code is to set the transaction of a service. It checks if a transaction exists, then generates a
-// unique identifier if it does not. It then inserts the transaction information into a database table, and sets the
-// chain associated with the transaction. It then sets the chain.RPC and chainId values to empty strings and zero
-// respectively. It then checks if the transaction has a parent, and if it does, it updates the allocation and status of
-// the transaction and its parent in the database. Finally, it returns the transaction if the operation was successful, or nil if not.
-func (a *Service) WriteTransaction(transaction *types.Transaction) (*types.Transaction, error) {
+// writePaper - This function places the simulated order of the user. The funds of the order are taken from the paper balance of
+// the user, the market orders and the limit orders whose price the price of the pair already crossed are filled at the
+// price of the pair at once, the other limit orders wait in the pending status for the paper replay. The order book,
+// the balances, the holds and the reserves of the exchange are never touched.
+func (a *Service) writePaper(req *pbprovider.SetRequestOrder, userId int64) (*types.Order, error) {
 
-	// The purpose of the code snippet is to declare two variables, exist and err. exist is of type bool and err is of type
-	// error. This is typically used when programming to indicate the existence of an error or to store the value of an error.
-	var (
-		exist bool
-		err   error
-	)
+	price, ok := a.queryPrice(req.GetBaseUnit(), req.GetQuoteUnit())
+	if !ok || price <= 0 {
+		return nil, status.Errorf(11794, "the price of the pair %v/%v is not available", req.GetBaseUnit(), req.GetQuoteUnit())
+	}
 
-	// The purpose of this code is to check if the transaction exists in e.getTransactionExist(transaction.GetHash()). If
-	// the transaction does not exist (i.e. !exist is true) then the code will execute the statements that follow.
-	if _ = a.Context.Db.QueryRow("select exists(select id from transactions where hash = $1)::bool", transaction.GetHash()).Scan(&exist); !exist {
+	order := types.Order{
+		UserId:    userId,
+		BaseUnit:  req.GetBaseUnit(),
+		QuoteUnit: req.GetQuoteUnit(),
+		Assigning: req.GetAssigning(),
+		Trading:   req.GetTrading(),
+		Type:      req.GetType(),
+		Price:     req.GetPrice(),
+		Quantity:  req.GetQuantity(),
+		Value:     req.GetQuantity(),
+		Status:    types.StatusPending,
+		Paper:     true,
+	}
 
-		// This code is used to generate a unique identifier (in this case a UUID) for a transaction if it doesn't already have
-		// one. This UUID can be used to identify the transaction uniquely and ensure that it is not a duplicate of another transaction.
-		if len(transaction.GetHash()) == 0 {
-			transaction.Hash = uuid.NewV1().String()
+	// The market orders take the price of the pair, the quantity of the market buy order is the value of the quote unit as
+	// it is for the orders of the order book.
+	if order.GetTrading() == types.TradingMarket {
+		order.Price = price
+		if order.GetAssigning() == types.AssigningBuy {
+			order.Quantity, order.Value = decimal.New(req.GetQuantity()).Div(price).Float(), decimal.New(req.GetQuantity()).Div(price).Float()
 		}
+	}
 
-		// This code is a SQL query to insert transaction information into a database table called "transactions". It is
-		// assigning values to each of the 13 columns in the table, and then returning the id, CreateAt, and Status columns in
-		// the same row. It is then using the Scan() function to assign the returned values to the transaction object.
-		if err := a.Context.Db.QueryRow(`insert into transactions (symbol, hash, value, fees, confirmation, "to", block, chain_id, user_id, assignment, "group", platform, protocol, allocation, parent) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) returning id, create_at, status;`,
-			transaction.GetSymbol(),
-			transaction.GetHash(),
-			transaction.GetValue(),
-			transaction.GetFees(),
-			transaction.GetConfirmation(),
-			transaction.GetTo(),
-			transaction.GetBlock(),
-			transaction.GetChainId(),
-			transaction.GetUserId(),
-			transaction.GetAssignment(),
-			transaction.GetGroup(),
-			transaction.GetPlatform(),
-			transaction.GetProtocol(),
-			transaction.GetAllocation(),
-			transaction.GetParent(),
-		).Scan(&transaction.Id, &transaction.CreateAt, &transaction.Status); err != nil {
-			return transaction, err
-		}
+	if order.GetPrice() <= 0 || order.GetQuantity() <= 0 {
+		return nil, status.Error(11795, "the price and the quantity of the order must be greater than zero")
+	}
 
-		// This code is getting the chain associated with the transaction. The first line is getting the chain, and the second
-		// line checks if there has been an error. If there is an error, the code returns nil and the error.
-		transaction.Chain, err = a.QueryChain(transaction.GetChainId(), false)
-		if err != nil {
-			return transaction, err
-		}
+	symbol, value := order.GetBaseUnit(), decimal.New(order.GetQuantity()).String()
+	if order.GetAssigning() == types.AssigningBuy {
+		symbol, value = order.GetQuoteUnit(), decimal.New(order.GetQuantity()).Mul(order.GetPrice()).String()
+	}
 
-		// This code sets the Chain.Rpc and ChainId values of the transaction variable to empty strings and zero respectively.
-		// This is likely used to reset the transaction variable to its default values.
-		transaction.Chain.Rpc, transaction.ChainId = "", 0
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-		return transaction, nil
-	} else {
+	result, err := tx.Exec("update paper_balances set value = value - $3 where user_id = $1 and symbol = $2 and value >= $3", userId, symbol, value)
+	if err != nil {
+		return nil, err
+	}
 
-		// This code is used to update the status of a transaction in a database. The first `if` statement checks if the
-		// transaction has a parent. If it does, the code will execute two `Exec` commands in order to update the allocation
-		// and status of the transaction and its parent in the database.
-		if _ = a.Context.Db.QueryRow("select exists(select id from transactions where hash = $1 and allocation = $2)::bool", transaction.GetHash(), types.AllocationInternal).Scan(&exist); exist {
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, status.Errorf(11796, "there are not enough funds on the paper balance of %v", symbol)
+	}
 
-			// This code is used to update a transaction in a database. It sets the assignment to DEPOSIT and the status to
-			// PENDING by using the hash of the transaction as an identifier. The if statement is used to check for any errors
-			// that may occur while executing the query. If an error occurs, the transaction is returned without any changes.
-			if _, err := a.Context.Db.Exec("update transactions set assignment = $1, status = $2 where hash = $3;", types.AssignmentDeposit, types.StatusPending, transaction.GetHash()); err != nil {
-				return transaction, err
-			}
+	if err := tx.QueryRow("insert into paper_orders (user_id, base_unit, quote_unit, assigning, trading, type, price, quantity, value) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id, create_at", order.GetUserId(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetAssigning(), order.GetTrading(), order.GetType(), order.GetPrice(), order.GetQuantity(), order.GetValue()).Scan(&order.Id, &order.CreateAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
+	if order.GetTrading() == types.TradingMarket || (order.GetAssigning() == types.AssigningBuy && price <= order.GetPrice()) || (order.GetAssigning() == types.AssigningSell && price >= order.GetPrice()) {
+		if err := a.writePaperFill(&order, price); err != nil {
+			return nil, err
 		}
 	}
 
-	return transaction, nil
+	return &order, nil
 }
 
-// WriteReserve - This function is used to set a reserve for a user in a database. It takes the userId, address, symbol, value,
-// platform, protocol, and cross as parameters. It first checks if the reserve already exists in the database. If it
-// does, it updates it depending on the value of "cross." If the reserve does not exist, it inserts a new row into the database.
-func (a *Service) WriteReserve(userId int64, address, symbol string, value float64, platform, protocol, cross string) error {
+// writePaperFill - This function fills the simulated order at the price: the bought asset less the fees of the asset is credited to
+// the paper balance of the user, and the buy order filled below its own price gets the difference of the quote unit it
+// reserved back. The order that is no longer pending, cancelled or filled meanwhile, is left as it is.
+func (a *Service) writePaperFill(order *types.Order, price float64) error {
 
-	if len(protocol) == 0 {
-		protocol = types.ProtocolMainnet
+	var (
+		fees float64
+	)
+
+	symbol, value := order.GetQuoteUnit(), decimal.New(order.GetQuantity()).Mul(price)
+	if order.GetAssigning() == types.AssigningBuy {
+		symbol, value = order.GetBaseUnit(), decimal.New(order.GetQuantity())
 	}
 
-	// This code is querying a database for a specific set of information. The code is using placeholders ($1, $2, etc.) to
-	// make the query more secure by preventing SQL injection. The row variable is the result of the query, and the defer
-	// statement ensures that the connection to the database is closed after the query has finished. Finally, the if
-	// statement is used to check for any errors that might occur during the query.
-	row, err := a.Context.Db.Query("select id from reserves where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5", userId, symbol, platform, protocol, address)
+	_ = a.Context.Db.QueryRow("select fees_trade from assets where symbol = $1", symbol).Scan(&fees)
+	charged := value.Mul(fees).Div(100)
+
+	tx, err := a.Context.Db.Begin()
 	if err != nil {
 		return err
 	}
-	defer row.Close()
+	defer tx.Rollback()
 
-	// The purpose of this statement is to check if there is a row available to be read from a database. If so, the code
-	// within the if block will run. If not, it will skip the code within the if block.
-	if row.Next() {
+	result, err := tx.Exec("update paper_orders set status = $3, price = $2, fees = $4 where id = $1 and status = $5", order.GetId(), price, types.StatusFilled, charged.String(), types.StatusPending)
+	if err != nil {
+		return err
+	}
 
-		switch cross {
-		case types.BalancePlus:
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
 
-			// This code is updating a database table called "reserves" with values provided by the user. The code first checks to
-			// see if the update is successful, and if it fails, it returns an error.
-			if _, err := a.Context.Db.Exec("update reserves set value = value + $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, protocol, address, value); err != nil {
-				return err
-			}
-			break
-		case types.BalanceMinus:
+	if _, err := tx.Exec("insert into paper_balances (user_id, symbol, value) values ($1, $2, $3) on conflict (user_id, symbol) do update set value = paper_balances.value + excluded.value", order.GetUserId(), symbol, value.Sub(charged).String()); err != nil {
+		return err
+	}
 
-			// This code is updating a database table called "reserves" with values provided by the user. The code first checks to
-			// see if the update is successful, and if it fails, it returns an error.
-			if _, err := a.Context.Db.Exec("update reserves set value = value - $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, protocol, address, value); err != nil {
-				return err
-			}
-			break
+	if order.GetAssigning() == types.AssigningBuy && price < order.GetPrice() {
+		if _, err := tx.Exec("update paper_balances set value = value + $3 where user_id = $1 and symbol = $2", order.GetUserId(), order.GetQuoteUnit(), decimal.New(order.GetQuantity()).Mul(decimal.New(order.GetPrice()).Sub(price)).String()); err != nil {
+			return err
 		}
-
-		return nil
 	}
 
-	// This code is performing an SQL query to insert data into a database table called "reserves". The data being inserted
-	// consists of user_id, symbol, platform, protocol, address, and value. If there is an error in executing the query, the
-	// function will return the error.
-	if _, err = a.Context.Db.Exec("insert into reserves (user_id, symbol, platform, protocol, address, value) values ($1, $2, $3, $4, $5, $6)", userId, symbol, platform, protocol, address, value); err != nil {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
-	return nil
+	order.Status, order.Price, order.Fees = types.StatusFilled, price, charged.Float()
+
+	return a.Context.Publish(order, "exchange", "paper/status")
 }
 
-// WriteReserveLock - This function is used to update a record in the 'reserves' table in a database. It sets the 'lock' column to 'true'
-// for a record that matches the given userId, symbol, platform and protocol. This will help ensure that only one process
-// can access this record in the table at any given time, allowing for concurrent access to the table without conflicts.
-func (a *Service) WriteReserveLock(userId int64, symbol, platform, protocol string) error {
+// writePaperCancel - This function cancels the pending simulated order of the user, the funds the order took are returned to the
+// paper balance of the user.
+func (a *Service) writePaperCancel(userId, id int64) (*types.Order, error) {
 
-	if len(protocol) == 0 {
-		protocol = types.ProtocolMainnet
-	}
+	var (
+		item = types.Order{Paper: true}
+	)
 
-	// This code is used to update the "lock" field in the "reserves" table in the database. The specific record to be
-	// updated is identified using the userId, symbol, platform, and protocol values provided as arguments to the Exec()
-	// function. If the update operation is not successful, an error is returned.
-	if _, err := a.Context.Db.Exec("update reserves set lock = $5 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4;", userId, symbol, platform, protocol, true); err != nil {
-		return err
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
+	defer tx.Rollback()
 
-// WriteReserveUnlock - The purpose of this function is to update the "lock" field of the "reserves" table in the database to false, where the
-// user_id, symbol, platform, and protocol fields all match the given parameters. This is likely used to allow users to
-// access the reserves for a given symbol on a given platform and protocol.
-func (a *Service) WriteReserveUnlock(userId int64, symbol, platform, protocol string) error {
+	if err := tx.QueryRow("update paper_orders set status = $3 where id = $1 and user_id = $2 and status = $4 returning id, user_id, base_unit, quote_unit, assigning, trading, type, price, quantity, value, status, create_at", id, userId, types.StatusCancel, types.StatusPending).Scan(&item.Id, &item.UserId, &item.BaseUnit, &item.QuoteUnit, &item.Assigning, &item.Trading, &item.Type, &item.Price, &item.Quantity, &item.Value, &item.Status, &item.CreateAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(11538, "the requested order does not exist")
+		}
+		return nil, err
+	}
 
-	if len(protocol) == 0 {
-		protocol = types.ProtocolMainnet
+	symbol, value := item.GetBaseUnit(), decimal.New(item.GetQuantity()).String()
+	if item.GetAssigning() == types.AssigningBuy {
+		symbol, value = item.GetQuoteUnit(), decimal.New(item.GetQuantity()).Mul(item.GetPrice()).String()
 	}
 
-	// This code is part of an if statement and is used to update a database table. The code is used to update a "reserves"
-	// table in the database, setting the "lock" field to false where the user ID, symbol, platform, and protocol all match
-	// the given parameters. If any errors occur while executing the query, the code will return an error.
-	if _, err := a.Context.Db.Exec("update reserves set lock = $5 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4;", userId, symbol, platform, protocol, false); err != nil {
-		return err
+	if _, err := tx.Exec("insert into paper_balances (user_id, symbol, value) values ($1, $2, $3) on conflict (user_id, symbol) do update set value = paper_balances.value + excluded.value", userId, symbol, value); err != nil {
+		return nil, err
 	}
-	return nil
+
+	return &item, tx.Commit()
 }
 
-// WriteReverse - The purpose of this code is to query a database for a specific set of information, insert data into a database table,
-// and update the value of an existing record in the database. The code uses SQL queries to perform these operations and
-// also checks for errors that may occur in the process.
-func (a *Service) WriteReverse(userId int64, address, symbol string, value float64, platform, cross string) error {
+// writePaperReset - This function starts the paper trading of the user over: the simulated orders are removed and the paper
+// balances are set to the balances of the configuration. The paper trading of the user is turned on by it the first time.
+func (a *Service) writePaperReset(userId int64) error {
 
-	// This code is querying a database for a specific set of information. The code is using placeholders ($1, $2, etc.) to
-	// make the query more secure by preventing SQL injection. The row variable is the result of the query, and the defer
-	// statement ensures that the connection to the database is closed after the query has finished. Finally, the if
-	// statement is used to check for any errors that might occur during the query.
-	row, err := a.Context.Db.Query("select id from reserves where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5", userId, symbol, platform, types.ProtocolMainnet, address)
+	tx, err := a.Context.Db.Begin()
 	if err != nil {
 		return err
 	}
-	defer row.Close()
-
-	// The purpose of this statement is to check if there is a row available to be read from a database. If so, the code
-	// within the if block will run. If not, it will skip the code within the if block.
-	if row.Next() {
+	defer tx.Rollback()
 
-		switch cross {
-		case types.BalancePlus:
+	if _, err := tx.Exec("delete from paper_orders where user_id = $1", userId); err != nil {
+		return err
+	}
 
-			// This code is updating a database entry with the given parameters. The specific entry is the 'reverse' field of the
-			// 'reserves' table, and the update is an increase by the amount of 'value'. The other parameters (userId, symbol,
-			// platform, protocol, address) are used to specify which entry should be updated. If an error is encountered, the
-			// code will return an error.
-			if _, err := a.Context.Db.Exec("update reserves set reverse = reverse + $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, types.ProtocolMainnet, address, value); err != nil {
-				return err
-			}
-			break
-		case types.BalanceMinus:
+	if _, err := tx.Exec("delete from paper_balances where user_id = $1", userId); err != nil {
+		return err
+	}
 
-			// This code is updating a database entry with the given parameters. The specific entry is the 'reverse' field of the
-			// 'reserves' table, and the update is an increase by the amount of 'value'. The other parameters (userId, symbol,
-			// platform, protocol, address) are used to specify which entry should be updated. If an error is encountered, the
-			// code will return an error.
-			if _, err := a.Context.Db.Exec("update reserves set reverse = reverse - $6 where user_id = $1 and symbol = $2 and platform = $3 and protocol = $4 and address = $5;", userId, symbol, platform, types.ProtocolMainnet, address, value); err != nil {
-				return err
-			}
-			break
+	for symbol, value := range a.Context.Paper.Balances {
+		if _, err := tx.Exec("insert into paper_balances (user_id, symbol, value) values ($1, $2, $3)", userId, symbol, decimal.New(value).String()); err != nil {
+			return err
 		}
-
-		return nil
 	}
 
-	// This code is performing an SQL query to insert data into a database table called "reserves". The data being inserted
-	// consists of user_id, symbol, platform, protocol, address, and value. If there is an error in executing the query, the
-	// function will return the error.
-	if _, err = a.Context.Db.Exec("insert into reserves (user_id, symbol, platform, protocol, address, reverse) values ($1, $2, $3, $4, $5, $6)", userId, symbol, platform, types.ProtocolMainnet, address, value); err != nil {
+	if _, err := tx.Exec("insert into papers (user_id) values ($1) on conflict (user_id) do update set reset_at = now()", userId); err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
@@ -2,16 +2,21 @@ package provider
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/backtest"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
-	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
+	"github.com/cryptogateway/backend-envoys/assets/common/indicator"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/go-redis/redis/v8"
 	"google.golang.org/grpc/status"
 )
 
@@ -67,25 +72,36 @@ func (a *Service) SetOrder(ctx context.Context, req *pbprovider.SetRequestOrder)
 	var (
 		response pbprovider.ResponseOrder
 		order    types.Order
+		err      error
 	)
 
-	// Validates the type of the request and returns an error if it is invalid.
-	if err := types.Type(req.GetType()); err != nil {
+	// The type, the side and the trading of the order are brought to the constants of the enums of the registry of the types
+	// package for the spot and the stock orders alike, the names of the former enums of pbspot, such as "BUY", are taken as
+	// the constants they stand for, and an error is returned when the value stands for none of them.
+	if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
 		return &response, err
 	}
 
-	// This code snippet checks if the request is authenticated by calling the Auth() method on the Context object. If the
-	// authentication fails, the code returns an error.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
+	if req.Assigning, err = types.Normalize(types.EnumAssigning, req.GetAssigning()); err != nil {
+		return &response, err
+	}
+
+	if req.Trading, err = types.Normalize(types.EnumTrading, req.GetTrading()); err != nil {
 		return &response, err
 	}
 
+	auth := a.Context.User(ctx)
+
 	// Validate that the requested base and quote units and type are valid for the given configuration before proceeding with the request.
 	if err := a.queryValidatePair(req.GetBaseUnit(), req.GetQuoteUnit(), req.GetType()); err != nil {
 		return &response, err
 	}
 
+	// The trading can be suspended by the operators for the whole exchange, for the pair or for either of its currencies.
+	if err := a.Context.Feature(types.FeatureTrading, assets.Scope{Kind: types.ScopePair, Target: fmt.Sprintf("%v/%v", req.GetBaseUnit(), req.GetQuoteUnit())}, assets.Scope{Kind: types.ScopeCurrency, Target: req.GetBaseUnit()}, assets.Scope{Kind: types.ScopeCurrency, Target: req.GetQuoteUnit()}); err != nil {
+		return &response, err
+	}
+
 	// The purpose of this code is to create a Service object that uses the context stored in the variable e. The Service
 	// object is then assigned to the variable migrate.
 	_account := account.Service{
@@ -106,6 +122,38 @@ func (a *Service) SetOrder(ctx context.Context, req *pbprovider.SetRequestOrder)
 		return &response, status.Error(748990, "your account and assets have been blocked, please contact technical support for any questions")
 	}
 
+	// The orders of the users who trade on the paper are simulated, they are filled at the prices of the pairs on the paper
+	// balances and never reach the order book.
+	if a.queryPaper(user.GetId()) {
+
+		order, err := a.writePaper(req, user.GetId())
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = append(response.Fields, order)
+
+		return &response, nil
+	}
+
+	// The client id is the id the user gives to the order himself, it is unique among the orders of the user, so that the
+	// order can be found by it later, also by the support.
+	if len(req.GetClientId()) > 0 {
+
+		if len(req.GetClientId()) > 64 || strings.TrimSpace(req.GetClientId()) != req.GetClientId() {
+			return &response, status.Error(11719, "the client id of the order must be at most 64 characters long without the spaces around it")
+		}
+
+		var (
+			exist bool
+		)
+
+		if _ = a.Context.Db.QueryRow("select exists(select id from orders where user_id = $1 and client_id = $2)", user.GetId(), req.GetClientId()).Scan(&exist); exist {
+			return &response, status.Errorf(11720, "the client id %v is already used by another order", req.GetClientId())
+		}
+
+		order.ClientId = req.GetClientId()
+	}
+
 	// This is setting the order quantity and value based on the request quantity and price.
 	// The request quantity is used to set the order quantity, order type, and the order value is calculated by multiplying the request quantity by the request price.
 	order.Quantity = req.GetQuantity()
@@ -146,63 +194,46 @@ func (a *Service) SetOrder(ctx context.Context, req *pbprovider.SetRequestOrder)
 	order.QuoteUnit = req.GetQuoteUnit()
 	order.Assigning = req.GetAssigning()
 	order.Trading = req.GetTrading()
+	order.Extended = req.GetExtended()
 	order.Status = types.StatusPending
 	order.CreateAt = time.Now().UTC().Format(time.RFC3339)
 
-	// This code is checking for an error in the queryValidateOrder() function and if one is found, it returns an error response
-	// and calls the Context.Error() method with the error. The quantity variable is used to store the result of queryValidateOrder(), which is used to complete the order.
-	quantity, err := a.queryValidateOrder(&order)
-	if err != nil {
-		return &response, err
+	// The balance the funds of the order are taken from is read before the order is placed, the orders of the lead traders
+	// are mirrored to their followers by the same share of the balance.
+	spent := order.GetBaseUnit()
+	if order.GetAssigning() == types.AssigningBuy {
+		spent = order.GetQuoteUnit()
 	}
+	balance := a.QueryBalance(spent, order.GetType(), order.GetUserId())
 
-	// This is a conditional statement used to set a new order and check for any errors that might occur. If an error is
-	// encountered, the statement will return a response and an Error context to indicate that an error has occurred.
-	if order.Id, err = a.writeOrder(&order); err != nil {
+	// This code validates the order, writes it, takes the funds of the order from the balance of the user and matches it.
+	quantity, err := a.writePlace(ctx, &order)
+	if err != nil {
 		return &response, err
 	}
 
-	// The switch statement is used to evaluate the value of the expression "order.GetAssigning()" and execute the
-	// corresponding case statement. It is a type of conditional statement that allows a program to make decisions based on different conditions.
-	switch order.GetAssigning() {
-	case types.AssigningBuy:
-
-		// This code snippet is likely a part of a function that processes an order. The purpose of the code is to use the
-		// function "writeAsset()" to set the base unit and user ID of the order to false. If an error occurs during the process,
-		// the code will return the response and an error message.
-		if err := a.writeAsset(order.GetBaseUnit(), order.GetType(), order.GetUserId(), false); err != nil {
-			return &response, err
-		}
-
-		// This code is checking the balance of a user and attempting to subtract the specified quantity from it. If the
-		// operation is successful, it will continue with the program. If an error occurs, it will return an error response.
-		if err := a.WriteBalance(order.GetQuoteUnit(), order.GetType(), order.GetUserId(), quantity, types.BalanceMinus); err != nil {
-			return &response, err
-		}
-
-		a.trade(&order, types.AssigningSell)
-
-		break
-	case types.AssigningSell:
-
-		// This code snippet is likely a part of a function that processes an order. The purpose of the code is to use the
-		// function "writeAsset()" to set the base unit and user ID of the order to false. If an error occurs during the process,
-		// the code will return the response and an error message.
-		if err := a.writeAsset(order.GetQuoteUnit(), order.GetType(), order.GetUserId(), false); err != nil {
-			return &response, err
-		}
+	if order.GetType() == types.TypeSpot {
+		go a.writeCopy(&types.Order{
+			Id:        order.GetId(),
+			UserId:    order.GetUserId(),
+			BaseUnit:  order.GetBaseUnit(),
+			QuoteUnit: order.GetQuoteUnit(),
+			Assigning: order.GetAssigning(),
+			Trading:   order.GetTrading(),
+			Price:     order.GetPrice(),
+		}, quantity, balance)
+	}
 
-		// This code is checking the balance of a user and attempting to subtract the specified quantity from it. If the
-		// operation is successful, it will continue with the program. If an error occurs, it will return an error response.
-		if err := a.WriteBalance(order.GetBaseUnit(), order.GetType(), order.GetUserId(), quantity, types.BalanceMinus); err != nil {
-			return &response, err
+	// This code checks how close the order is to the maximum trading amount of the asset, the amount is counted in the
+	// quote unit for the buy orders and in the base unit for the sell orders, in the same way as it is validated.
+	symbol := order.GetBaseUnit()
+	if order.GetAssigning() == types.AssigningBuy {
+		symbol = order.GetQuoteUnit()
+	}
+	if _, max, _ := a.queryRange(symbol, quantity); max > 0 {
+		if warning := a.QueryWarning(order.GetUserId(), types.LimitTrade, symbol, quantity, max); warning != nil {
+			response.Warnings = append(response.Warnings, warning)
 		}
-
-		a.trade(&order, types.AssigningBuy)
-
-		break
-	default:
-		return &response, status.Error(11588, "invalid assigning trade position")
 	}
 
 	// This statement is used to append an element to the "Fields" slice of the "response" struct. The element being
@@ -222,21 +253,21 @@ func (a *Service) GetMarkers(_ context.Context, req *pbprovider.GetRequestMarker
 	// process data in a specific way.
 	var (
 		response pbprovider.ResponseMarker
-		maps     []string
+		builder  = query.NewBuilder()
 	)
 
-	// This code checks the request type and adds the appropriate condition to the map for further query.
+	// This code checks the request type and adds the appropriate condition to the query.
 	// If the request type is of type spot, the marker is set to true and type to the request type. Else, the 'group' is set to the action group.
 	if req.GetType() == types.TypeSpot || req.GetType() == types.TypeCross {
-		maps = append(maps, fmt.Sprintf(`where marker = %[2]v and type = '%[1]v'`, types.TypeSpot, true))
+		builder.Where("marker = ? and type = ?", true, types.TypeSpot)
 	} else {
-		maps = append(maps, fmt.Sprintf(`where "group" = '%[1]v'`, types.GroupFiat))
+		builder.Where(`"group" = ?`, types.GroupFiat)
 	}
 
 	// This code is querying a database for a certain symbol from the currencies table. The purpose of the code is to query
 	// the database and check for an error. If an error is present, it will return an error response. If no error is
 	// present, the rows will be closed.
-	rows, err := a.Context.Db.Query(fmt.Sprintf(`select symbol from assets %s`, strings.Join(maps, " ")))
+	rows, err := a.Context.Db.Query(fmt.Sprintf(`select symbol from assets %s`, builder.Clause()), builder.Params()...)
 	if err != nil {
 		return &response, err
 	}
@@ -269,27 +300,27 @@ func (a *Service) GetMarkers(_ context.Context, req *pbprovider.GetRequestMarker
 // into the database. It then sets the success to true and returns the response and no error.
 func (a *Service) SetAsset(ctx context.Context, req *pbprovider.SetRequestAsset) (*pbprovider.ResponseAsset, error) {
 
-	// Response and cross are used to store various variables used in pbprovider.ResponseAsset and keypair.CrossChain respectively.
+	// Response is used to store various variables used in pbprovider.ResponseAsset.
 	var (
 		response pbprovider.ResponseAsset
-		cross    keypair.CrossChain
+		err      error
 	)
 
-	// The purpose of this code is to retrieve the authentication information associated with the given context (ctx). If
-	// there is an error with retrieving the authentication information, then the error is returned.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
+	auth := a.Context.User(ctx)
+
+	// The group and the type of the request are brought to the constants of the enums of the registry, an error is returned
+	// when they stand for none of them.
+	if req.Group, err = types.Normalize(types.EnumGroup, req.GetGroup()); err != nil {
 		return &response, err
 	}
 
-	// Validate the Group field in the request and return an error if.
-	if err := types.Group(req.GetGroup()); err != nil {
+	if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
 		return &response, err
 	}
 
-	// Validates the request type and returns an error if there is an issue.
-	if err := types.Type(req.GetType()); err != nil {
-		return &response, err
+	// The deposits of an asset being delisted are disabled, so no new balances and deposit addresses are created for it.
+	if _, err := a.QueryDelisting(req.GetSymbol()); err == nil {
+		return &response, status.Errorf(11636, "the asset %v is being delisted, deposits are disabled", req.GetSymbol())
 	}
 
 	// CheckBalance attempts to detect if a balance entry exists for the given symbol, user_id and type, and returns the result in the Success field of the response.
@@ -318,7 +349,7 @@ func (a *Service) SetAsset(ctx context.Context, req *pbprovider.SetRequestAsset)
 
 		// The code is attempting to create a new address using a secret, entropy, and platform. If there is an error, the
 		// function will return the response and an error.
-		if response.Address, _, err = cross.New(fmt.Sprintf("%v-&*39~763@)", a.Context.Secrets[1]), entropy, req.GetPlatform()); err != nil {
+		if response.Address, err = a.queryDerive(entropy, req.GetPlatform(), 0); err != nil {
 			return &response, err
 		}
 
@@ -329,9 +360,13 @@ func (a *Service) SetAsset(ctx context.Context, req *pbprovider.SetRequestAsset)
 			// This code is performing an SQL INSERT statement to add a new record to the 'wallets' table. The values being
 			// inserted are the address, platform, and user_id from the request parameters. The query is then
 			// executed and if there is an error, an error message is returned.
-			if _, err = a.Context.Db.Exec("insert into wallets (address, platform, user_id) values ($1, $2, $3)", response.GetAddress(), req.GetPlatform(), auth); err != nil {
+			if _, err = a.Context.Db.Exec("insert into wallets (address, platform, user_id) values ($1, $2, $3) on conflict do nothing", response.GetAddress(), req.GetPlatform(), auth); err != nil {
 				return &response, err
 			}
+		} else {
+
+			// The user may have rotated the deposit address, the current address is returned instead of the first one.
+			response.Address = address
 		}
 	}
 
@@ -350,6 +385,121 @@ func (a *Service) SetAsset(ctx context.Context, req *pbprovider.SetRequestAsset)
 	return &response, nil
 }
 
+// GetAddresses - This method returns the registry of the deposit addresses of the user on the platform: the current address and
+// the archived ones, the deposits to all of them are credited to the balance of the user.
+func (a *Service) GetAddresses(ctx context.Context, req *pbprovider.GetRequestAddresses) (*pbprovider.ResponseAddress, error) {
+
+	var (
+		response pbprovider.ResponseAddress
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if err := types.Platform(req.GetPlatform()); err != nil {
+		return &response, err
+	}
+
+	if response.Fields, err = a.QueryAddresses(auth, req.GetPlatform()); err != nil {
+		return &response, err
+	}
+	response.Address = a.QueryAddress(auth, req.GetPlatform())
+
+	return &response, nil
+}
+
+// SetAddress - This method generates a new deposit address of the user on the platform, or archives (restores) the address
+// given by the id. The new address is derived from the seed of the user with the next index, it becomes the current deposit
+// address, the previous addresses stay in the registry and the deposits to them are still credited. At least one address
+// of the platform has to stay active.
+func (a *Service) SetAddress(ctx context.Context, req *pbprovider.SetRequestAddress) (*pbprovider.ResponseAddress, error) {
+
+	var (
+		response pbprovider.ResponseAddress
+	)
+
+	auth := a.Context.User(ctx)
+
+	if err := types.Platform(req.GetPlatform()); err != nil {
+		return &response, err
+	}
+
+	wallets, err := a.QueryAddresses(auth, req.GetPlatform())
+	if err != nil {
+		return &response, err
+	}
+
+	// The first address of the platform is created together with the first asset of the platform by SetAsset.
+	if len(wallets) == 0 {
+		return &response, status.Errorf(11651, "there is no deposit address on the platform %v yet", req.GetPlatform())
+	}
+
+	if req.GetId() > 0 {
+
+		var (
+			active int
+			exist  bool
+		)
+
+		for _, wallet := range wallets {
+			if !wallet.GetArchived() {
+				active++
+			}
+			if wallet.GetId() == req.GetId() {
+				exist = true
+			}
+		}
+
+		if !exist {
+			return &response, status.Errorf(11652, "the address by id %v was not found", req.GetId())
+		}
+
+		if req.GetArchived() && active <= 1 {
+			return &response, status.Error(11653, "the last active deposit address cannot be archived")
+		}
+
+		if _, err := a.Context.Db.Exec("update wallets set archived = $3 where id = $1 and user_id = $2;", req.GetId(), auth, req.GetArchived()); err != nil {
+			return &response, err
+		}
+
+	} else {
+
+		if len(wallets) >= maxAddresses {
+			return &response, status.Errorf(11654, "the limit of %v deposit addresses on the platform %v has been reached", maxAddresses, req.GetPlatform())
+		}
+
+		// Service account is a Service struct used by account package to store context.
+		_account := account.Service{
+			Context: a.Context,
+		}
+
+		entropy, err := _account.QueryEntropy(auth)
+		if err != nil {
+			return &response, err
+		}
+
+		// The index of the new address follows the largest index of the addresses of the user on the platform.
+		derivation := uint32(wallets[len(wallets)-1].GetDerivation()) + 1
+
+		address, err := a.queryDerive(entropy, req.GetPlatform(), derivation)
+		if err != nil {
+			return &response, err
+		}
+
+		if _, err := a.Context.Db.Exec("insert into wallets (address, platform, user_id, derivation) values ($1, $2, $3, $4)", address, req.GetPlatform(), auth, derivation); err != nil {
+			return &response, err
+		}
+	}
+
+	if response.Fields, err = a.QueryAddresses(auth, req.GetPlatform()); err != nil {
+		return &response, err
+	}
+	response.Address = a.QueryAddress(auth, req.GetPlatform())
+	response.Success = true
+
+	return &response, nil
+}
+
 // GetAsset - This function is used to get an asset from a database and retrieve related information, such as the balance, volume,
 // and fees associated with it. It also gets information about the chains associated with the asset, such as the
 // reserves, address, existence, and contract. Finally, it returns the response asset which contains all the gathered information.
@@ -359,17 +509,13 @@ func (a *Service) GetAsset(ctx context.Context, req *pbprovider.GetRequestAsset)
 	// is typically used to store the response of an API request. This allows the response to be accessed and manipulated by the code.
 	var (
 		response pbprovider.ResponseAsset
+		err      error
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := a.Context.User(ctx)
 
-	// Validates the request type and returns an error if there is an issue.
-	if err := types.Type(req.GetType()); err != nil {
+	// The type of the request is brought to the constant of the enum of the registry, an error is returned if there is an issue.
+	if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
 		return &response, err
 	}
 
@@ -431,6 +577,20 @@ func (a *Service) GetAsset(ctx context.Context, req *pbprovider.GetRequestAsset)
 					chain.Contract = contract
 				}
 
+				// The configuration of the asset on the chain tells the clients whether the deposits and the withdrawals are enabled,
+				// the minimum withdrawal and the fee override the values of the asset and of the chain when they are set.
+				if network, err := a.QueryAssetChain(row.GetSymbol(), chain.GetId()); err == nil {
+					chain.Deposit, chain.Withdraw, chain.MinWithdraw = network.GetDeposit(), network.GetWithdraw(), row.GetMinWithdraw()
+
+					if network.GetMinWithdraw() > 0 {
+						chain.MinWithdraw = network.GetMinWithdraw()
+					}
+
+					if network.GetFees() > 0 {
+						chain.Fees = network.GetFees()
+					}
+				}
+
 				// The purpose of this code is to set the reserve of the chain to the reserve of the asset that is requested from the
 				// symbol, platform, and protocol. The code is retrieving the reserve of the asset in order to set the reserve of the chain.
 				chain.Reserve = a.QueryReserve(req.GetSymbol(), chain.GetPlatform(), chain.Contract.GetProtocol())
@@ -483,19 +643,19 @@ func (a *Service) GetAssets(ctx context.Context, req *pbprovider.GetRequestAsset
 	// can then be used in the program to store a response asset from the pbprovider API.
 	var (
 		response pbprovider.ResponseAsset
-		maps     []string
+		builder  = query.NewBuilder()
 	)
 
 	// Generate a condition based on the group and type given in the request.
 	// If the group is specified, set the condition to where "group" = <group>.
 	// Otherwise, set the condition to where type = <type>..
 	if len(req.GetGroup()) > 0 {
-		maps = append(maps, fmt.Sprintf(`where "group" = '%v'`, req.GetGroup()))
+		builder.Where(`"group" = ?`, req.GetGroup())
 	} else {
 
 		// If the request type is "Margin", set the request type to "Spot".
 		if req.GetType() == types.TypeSpot || req.GetType() == types.TypeCross {
-			maps = append(maps, fmt.Sprintf(`where type = '%v'`, types.TypeSpot))
+			builder.Where("type = ?", types.TypeSpot)
 		}
 	}
 
@@ -503,7 +663,7 @@ func (a *Service) GetAssets(ctx context.Context, req *pbprovider.GetRequestAsset
 	// purpose of the code is to retrieve the information from the table currencies and store them in the variables rows and
 	// err. If there is an error, the code will return the response and an error message. Finally, the defer rows.Close()
 	// will close the rows of information when the function is finished executing.
-	rows, err := a.Context.Db.Query(fmt.Sprintf(`select id, name, symbol, status from assets %s`, strings.Join(maps, " ")))
+	rows, err := a.Context.Db.Query(fmt.Sprintf(`select id, name, symbol, status from assets %s`, builder.Clause()), builder.Params()...)
 	if err != nil {
 		return &response, err
 	}
@@ -567,7 +727,7 @@ func (a *Service) GetPairs(_ context.Context, req *pbprovider.GetRequestPairs) (
 	// This code is used to query the database for information from the pairs table where either the base_unit or the
 	// quote_unit is equal to the value in the req.GetSymbol() variable. The purpose of this code is to retrieve data from
 	// the database and store it in the response variable. The defer rows.Close() statement ensures that the rows are closed once the function is completed.
-	rows, err := a.Context.Db.Query("select id, base_unit, quote_unit, base_decimal, quote_decimal, type, status from pairs where type = $1 and (base_unit = $2 or quote_unit = $2)", req.GetType(), req.GetSymbol())
+	rows, err := a.Context.Db.Query("select id, base_unit, quote_unit, base_decimal, quote_decimal, type, status, min_notional, price_step, quantity_step from pairs where type = $1 and (base_unit = $2 or quote_unit = $2)", req.GetType(), req.GetSymbol())
 	if err != nil {
 		return &response, err
 	}
@@ -585,8 +745,9 @@ func (a *Service) GetPairs(_ context.Context, req *pbprovider.GetRequestPairs) (
 		)
 
 		// This is an if statement which is used to assign the scanned rows from the database to the corresponding variables.
-		// If an error occurs while scanning the rows, the statement will return an error as part of the response.
-		if err := rows.Scan(&pair.Id, &pair.BaseUnit, &pair.QuoteUnit, &pair.BaseDecimal, &pair.QuoteDecimal, &pair.Type, &pair.Status); err != nil {
+		// If an error occurs while scanning the rows, the statement will return an error as part of the response. The trading
+		// filters are returned as well, so that the clients can validate the orders before they are placed.
+		if err := rows.Scan(&pair.Id, &pair.BaseUnit, &pair.QuoteUnit, &pair.BaseDecimal, &pair.QuoteDecimal, &pair.Type, &pair.Status, &pair.MinNotional, &pair.PriceStep, &pair.QuantityStep); err != nil {
 			return &response, err
 		}
 
@@ -653,7 +814,7 @@ func (a *Service) GetPair(_ context.Context, req *pbprovider.GetRequestPair) (*p
 	// This code is querying a database for a specific row in the table. The query is looking for a row with the specified
 	// base_unit and quote_unit from the 'parameters' req.GetBaseUnit() and req.GetQuoteUnit(). If an error occurs, the error.
 	// Finally, the row is closed with the defer keyword so that it is properly released back to the server.
-	row, err := a.Context.Db.Query(`select id, base_unit, quote_unit, price, base_decimal, quote_decimal, status from pairs where base_unit = $1 and quote_unit = $2`, req.GetBaseUnit(), req.GetQuoteUnit())
+	row, err := a.Context.Db.Query(`select id, base_unit, quote_unit, price, base_decimal, quote_decimal, status, min_notional, price_step, quantity_step from pairs where base_unit = $1 and quote_unit = $2`, req.GetBaseUnit(), req.GetQuoteUnit())
 	if err != nil {
 		return &response, err
 	}
@@ -673,7 +834,7 @@ func (a *Service) GetPair(_ context.Context, req *pbprovider.GetRequestPair) (*p
 		// scan each row of the retrieved data and store the relevant information into a structure called "pair", which likely
 		// holds data regarding currency pairs. The "if" statement is a check to make sure that the data was successfully read
 		// and stored into the structure, and if not, it will return an error.
-		if err := row.Scan(&pair.Id, &pair.BaseUnit, &pair.QuoteUnit, &pair.Price, &pair.BaseDecimal, &pair.QuoteDecimal, &pair.Status); err != nil {
+		if err := row.Scan(&pair.Id, &pair.BaseUnit, &pair.QuoteUnit, &pair.Price, &pair.BaseDecimal, &pair.QuoteDecimal, &pair.Status, &pair.MinNotional, &pair.PriceStep, &pair.QuantityStep); err != nil {
 			return &response, err
 		}
 
@@ -708,13 +869,13 @@ func (a *Service) GetPair(_ context.Context, req *pbprovider.GetRequestPair) (*p
 // time range, perform calculations on the data, and store the results in an array.
 func (a *Service) GetTicker(_ context.Context, req *pbprovider.GetRequestTicker) (*pbprovider.ResponseTicker, error) {
 
-	// The purpose of this code is to create three variables with zero values: response, limit and maps. The response
-	// variable is of type pbprovider.ResponseTicker, the limit variable is of type string, and the maps variable is of type
-	// slice of strings.
+	// The purpose of this code is to create the variables of the ticker: the response, the builder of the conditions of the
+	// query, with the pair bound as its first two parameters, and the key of the cache.
 	var (
 		response pbprovider.ResponseTicker
 		limit    string
-		maps     []string
+		builder  = query.NewBuilder(req.GetBaseUnit(), req.GetQuoteUnit())
+		cache    string
 	)
 
 	// This code checks if the limit of the request is set to 0. If it is, then it sets the limit to 30. This is likely done
@@ -723,25 +884,37 @@ func (a *Service) GetTicker(_ context.Context, req *pbprovider.GetRequestTicker)
 		req.Limit = 500
 	}
 
+	// The candles of the higher timeframes (an hour and more) are aggregated from a large number of ticks, so their result is
+	// cached in redis. The key contains the version of the pair, which is increased by every new tick, so that a cached
+	// result is never older than the last tick of the pair.
+	if help.Period(req.GetResolution()) >= time.Hour {
+		cache = fmt.Sprintf("ticker:%v:%v:%v:%v:%v:%v", req.GetBaseUnit(), req.GetQuoteUnit(), help.Resolution(req.GetResolution()), req.GetTo(), req.GetLimit(), a.queryVersion(req.GetBaseUnit(), req.GetQuoteUnit()))
+		if a.queryCache(cache, &response) {
+			return &response, nil
+		}
+	}
+
 	// This code is used to set a limit to the request. It checks if req.GetLimit() is greater than 0. If so, it sets the
 	// limit variable to a string with the limit set to that amount. This is likely used to set a limit on the amount of
 	// data that will be returned in the response.
 	if req.GetLimit() > 0 {
-		limit = fmt.Sprintf("limit %d", req.GetLimit())
+		limit = fmt.Sprintf("limit %s", builder.Bind(req.GetLimit()))
 	}
 
-	// This code is checking to see if the "From" and "To" values in the request are greater than 0. If they are, a
-	// formatted string will be appended to the "maps" array containing a timestamp that is less than the "To" value in the
-	// request. This code is likely used to filter a query based on a time range.
+	// This code is checking to see if the "To" value in the request is greater than 0. If it is, the condition of a timestamp
+	// that is less than the "To" value in the request is added to the query. This code is used to filter a query based on a time range.
 	if req.GetTo() > 0 {
-		maps = append(maps, fmt.Sprintf(`and to_char(o.create_at::timestamp, 'yyyy-mm-dd hh24:mi:ss') < to_char(to_timestamp(%[1]d), 'yyyy-mm-dd hh24:mi:ss')`, req.GetTo()))
+		builder.Where("date_trunc('second', o.create_at) < to_timestamp(?)", req.GetTo())
 	}
 
-	// This code is used to query the database to return OHLC (open-high-low-close) data. The SQL query is using the
-	// fmt.Sprintf function to substitute the variables (req.GetBaseUnit(), req.GetQuoteUnit(), strings.Join(maps, " "),
-	// help.Resolution(req.GetResolution()), limit) into the query. The query is then executed, and the results are stored
-	// in the rows variable. Finally, the rows variable is closed at the end of the code.
-	rows, err := a.Context.Db.Query(fmt.Sprintf("select extract(epoch from time_bucket('%[4]s', o.create_at))::integer buckettime, first(o.price, o.create_at) as open, last(o.price, o.create_at) as close, first(o.price, o.price) as low, last(o.price, o.price) as high, sum(o.quantity) as volume, avg(o.price) as avg_price, o.base_unit, o.quote_unit from ohlcv as o where o.base_unit = '%[1]s' and o.quote_unit = '%[2]s' %[3]s group by buckettime, o.base_unit, o.quote_unit order by buckettime desc %[5]s", req.GetBaseUnit(), req.GetQuoteUnit(), strings.Join(maps, " "), help.Resolution(req.GetResolution()), limit))
+	// The candles of the whole hours and days are aggregated from the continuous aggregates of the ticks, so that the wide
+	// buckets are not recalculated from every tick of their period.
+	source, columns := query.Candles(help.Period(req.GetResolution()))
+
+	// This code is used to query the database to return OHLC (open-high-low-close) data. The pair, the time range, the width
+	// of the buckets and the limit are all bound as the parameters of the query. The query is then executed, and the results
+	// are stored in the rows variable. Finally, the rows variable is closed at the end of the code.
+	rows, err := a.Context.Reader().Query(fmt.Sprintf("select extract(epoch from time_bucket(%[3]s::interval, o.create_at))::integer buckettime, %[5]s, o.base_unit, o.quote_unit from %[4]s as o where o.base_unit = $1 and o.quote_unit = $2 %[1]s group by buckettime, o.base_unit, o.quote_unit order by buckettime desc %[2]s", builder.And(), limit, builder.Bind(help.Resolution(req.GetResolution())), source, columns), builder.Params()...)
 	if err != nil {
 		return &response, err
 	}
@@ -779,7 +952,7 @@ func (a *Service) GetTicker(_ context.Context, req *pbprovider.GetRequestTicker)
 	// This code is used to fetch and analyze data from a database. It uses the QueryRow() method to retrieve data from the
 	// database and then scan it into the stats variable. The code is specifically used to get the count, volume, low, high,
 	// first and last values from the trades table for a given base unit and quote unit.
-	_ = a.Context.Db.QueryRow(fmt.Sprintf(`select count(*) as count, sum(h24.quantity) as volume, first(h24.price, h24.price) as low, last(h24.price, h24.price) as high, first(h24.price, h24.create_at) as first, last(h24.price, h24.create_at) as last from ohlcv as h24 where h24.create_at > now()::timestamp - '24 hours'::interval and h24.base_unit = '%[1]s' and h24.quote_unit = '%[2]s'`, req.GetBaseUnit(), req.GetQuoteUnit())).Scan(&stats.Count, &stats.Volume, &stats.Low, &stats.High, &stats.First, &stats.Last)
+	_ = a.Context.Reader().QueryRow(`select count(*) as count, sum(h24.quantity) as volume, first(h24.price, h24.price) as low, last(h24.price, h24.price) as high, first(h24.price, h24.create_at) as first, last(h24.price, h24.create_at) as last from ohlcv as h24 where h24.create_at > now()::timestamp - '24 hours'::interval and h24.base_unit = $1 and h24.quote_unit = $2`, req.GetBaseUnit(), req.GetQuoteUnit()).Scan(&stats.Count, &stats.Volume, &stats.Low, &stats.High, &stats.First, &stats.Last)
 
 	// This code checks if the length of the 'response.Fields' array is greater than 1. If so, it assigns the 'Close' value
 	// of the second element in the 'response.Fields' array to the 'Previous' field of the 'stats' object.
@@ -791,564 +964,2009 @@ func (a *Service) GetTicker(_ context.Context, req *pbprovider.GetRequestTicker)
 	//the response object to access the data stored in the stats variable.
 	response.Stats = &stats
 
+	// The aggregated candles of the higher timeframes are stored in the cache for the next requests.
+	if len(cache) > 0 {
+		a.writeCache(cache, &response, time.Minute)
+	}
+
 	return &response, nil
 }
 
-// SetTicker - The purpose of this code is to retrieve two candles with a given resolution from a spot exchange, add a new row to a
-// database table, publish a message to an exchange on a specific topic, and append the returned values to a response array.
-func (a *Service) SetTicker(_ context.Context, req *pbprovider.SetRequestTicker) (*pbprovider.ResponseTicker, error) {
+// GetMarkets - This function returns the summary of every active pair in one call: the last price, the 24h change and volume, the
+// best bid and ask, and the spark-line of the last day. The summary is served from the cache refreshed by the price
+// replay, and it is built on the spot only if the cache is empty, for example right after the start of the service.
+func (a *Service) GetMarkets(_ context.Context, _ *pbprovider.GetRequestMarkets) (*pbprovider.ResponseMarket, error) {
 
-	// The purpose of this code is to declare a variable called 'response' of type 'pbprovider.Response'. This variable will be
-	// used to store data that is returned from a function call involving the 'pbprovider' library.
+	// The purpose of this code is to declare the response variable of type pbprovider.ResponseMarket.
 	var (
-		response pbprovider.ResponseTicker
+		response pbprovider.ResponseMarket
 	)
 
-	// This code is checking if the key provided in the request (req.GetKey()) matches the secret stored in the context
-	// (s.Context.Secrets[2]). If the keys don't match, the code is returning an error with the code 654333 and the message
-	// "the access key is incorrect". This is likely part of an authorization process to make sure only authorized users are
-	// able to access a certain resource.
-	if req.GetKey() != a.Context.Secrets[2] {
-		return &response, status.Error(654333, "the access key is incorrect")
+	// This code returns the cached summary, if it exists.
+	if a.queryCache("markets:summary", &response) {
+		return &response, nil
 	}
 
-	// This piece of code is inserting data into a database table. The purpose of this code is to add a new row to the
-	// "ohlcv" table, based on the values stored in the params array. The five columns in the table are assigning,
-	// base_unit, quote_unit, price, and quantity, and each of these is being populated with the corresponding value from
-	// the params array. The code then checks for any errors that may have occurred while executing the query and returns if any are found.
-	if _, err := a.Context.Db.Exec(`insert into ohlcv (assigning, base_unit, quote_unit, price, quantity) values ($1, $2, $3, $4, $5)`, req.GetAssigning(), req.GetBaseUnit(), req.GetQuoteUnit(), req.GetPrice(), req.GetValue()); a.Context.Debug(err) {
+	// The summary is built and stored in the cache for the next requests.
+	markets, err := a.queryMarkets()
+	if err != nil {
 		return &response, err
 	}
+	a.writeCache("markets:summary", markets, 2*time.Minute)
 
-	// The for loop is used to iterate through each element in the Depth() array. The underscore is used to assign the index
-	// number to a variable that is not used in the loop. The interval variable is used to access the contents of each
-	// element in the Depth() array.
-	for _, interval := range help.Depth() {
+	return markets, nil
+}
+
+// GetTicker24H - This function returns the rolling 24h statistics of a pair: the open, high, low and last prices, the base and quote
+// volumes, the price change in percent and the average price weighted by the volume. If the pair is not passed in the
+// request, the statistics of all the active pairs are returned. The statistics are maintained incrementally with every
+// trade, so the request does not scan the trades table.
+func (a *Service) GetTicker24H(_ context.Context, req *pbprovider.GetRequestTicker24H) (*pbprovider.ResponseTicker24H, error) {
+
+	// The purpose of this code is to declare the response variable of type pbprovider.ResponseTicker24H.
+	var (
+		response pbprovider.ResponseTicker24H
+	)
+
+	// This code returns the statistics of a single pair, if the pair is passed in the request.
+	if len(req.GetBaseUnit()) > 0 && len(req.GetQuoteUnit()) > 0 {
 
-		// This code is used to retrieve two candles with a given resolution from a spot exchange. The purpose of the migrate,
-		// err := a.GetTicker() line is to make a request to the spot exchange using the BaseUnit, QuoteUnit, Limit, and
-		// Resolution parameters provided. The if err != nil { return err } line is used to check if there was an error with
-		// the request and return that error if necessary.
-		migrate, err := a.GetTicker(context.Background(), &pbprovider.GetRequestTicker{BaseUnit: req.GetBaseUnit(), QuoteUnit: req.GetQuoteUnit(), Limit: 2, Resolution: interval})
+		ticker, err := a.queryRolling(req.GetBaseUnit(), req.GetQuoteUnit())
 		if err != nil {
 			return &response, err
 		}
+		response.Fields = append(response.Fields, ticker)
 
-		// This code is used to publish a message to an exchange on a specific topic. The message is "migrate" and the topic is
-		// "trade/ticker:interval". The purpose of this code is to send a message to the exchange,
-		// action based on the message. The if statement is used to check for any errors that may occur during the publishing
-		// of the message. If an error is encountered, it will be returned.
-		if err := a.Context.Publish(migrate, "exchange", fmt.Sprintf("trade/ticker:%v", interval)); err != nil {
-			return &response, err
-		}
+		return &response, nil
+	}
 
-		// The purpose of this statement is to append the values of the migrate.Fields array to the response.Fields array. This
-		// statement essentially adds the values of migrate.Fields array to the existing values in response.Fields array.
-		response.Fields = append(response.Fields, migrate.Fields...)
+	// This code queries the base and quote units of all the active pairs, and collects the statistics of each of them.
+	rows, err := a.Context.Db.Query(`select base_unit, quote_unit from pairs where status = $1 order by id`, true)
+	if err != nil {
+		return &response, err
 	}
+	defer rows.Close()
 
-	return &response, nil
-}
+	for rows.Next() {
 
-// GetPrice - This function is part of a service that is used to get the price of a given asset. It takes in a context and a
-// GetRequestPriceManual request object as parameters. The purpose of the function is to get the price of the asset from
-// the given base and quote units, then return a ResponsePrice object. It also checks if the price can be obtained from
-// the quote and base units in the opposite order. If so, the price is then calculated by taking the inverse of the price
-// and rounding it to 8 decimal places. Finally, the ResponsePrice object is returned.
-func (a *Service) GetPrice(_ context.Context, req *pbprovider.GetRequestPrice) (*pbprovider.ResponsePrice, error) {
+		var (
+			item types.Pair
+		)
 
-	// The code above declares two variables: response of type pbprovider.ResponsePrice and ok of type bool. The purpose of this
-	// code is to create two variables that can be used in the code that follows.
-	var (
-		response pbprovider.ResponsePrice
-		ok       bool
-	)
+		if err := rows.Scan(&item.BaseUnit, &item.QuoteUnit); err != nil {
+			return &response, err
+		}
 
-	// The purpose of this code is to check whether the price of a certain item has been successfully retrieved, and if so,
-	// return the response and a nil (no error) value. The line starts by attempting to get the price of the item based on
-	// the given base and quote units, and then assigns the response and the boolean value of ok to the variables'
-	// response.Price and ok, respectively. Finally, the code returns the response and a nil value if ok is true.
-	if response.Price, ok = a.queryPrice(req.GetBaseUnit(), req.GetQuoteUnit()); ok {
-		return &response, nil
+		ticker, err := a.queryRolling(item.GetBaseUnit(), item.GetQuoteUnit())
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = append(response.Fields, ticker)
 	}
 
-	// This code is checking if the price of a product or service can be obtained given the quote and base units. If the
-	// price can be obtained, the response will be rounded to 8 decimal places and stored in the response.Price variable.
-	if response.Price, ok = a.queryPrice(req.GetQuoteUnit(), req.GetBaseUnit()); ok {
-		response.Price = decimal.New(decimal.New(1).Div(response.Price).Float()).Round(8).Float()
+	if err = rows.Err(); err != nil {
+		return &response, err
 	}
 
 	return &response, nil
 }
 
-// GetOrders - This function is used to get orders from the database based on the parameters provided. It uses the req
-// *pbprovider.GetRequestOrders which contains parameters such as the limit and page, assigning, owner, user_id, status
-// and base_unit and quote_unit to query the database. The function then uses the query results to build a response which
-// is a *pbprovider.ResponseOrder. This includes the count and volume of the query results, as well as a list of all the orders that match the query criteria.
-func (a *Service) GetOrders(ctx context.Context, req *pbprovider.GetRequestOrders) (*pbprovider.ResponseOrder, error) {
+// GetDepth - This function returns the depth of the order book of the pair, the pending orders summed by their prices into the
+// levels of the bids and the asks, the best levels first. The pair is a spot one unless the type is given, 50 levels per
+// side are returned by default and 500 at most. The step groups the prices into the wider levels on the server.
+func (a *Service) GetDepth(_ context.Context, req *pbprovider.GetRequestDepth) (*pbprovider.ResponseDepth, error) {
 
-	// The purpose of this is to declare two variables: response and maps. The variable response is of type
-	// pbprovider.ResponseOrder, while the variable maps is of type string array.
+	// The purpose of this code is to declare the response variable of type pbprovider.ResponseDepth.
 	var (
-		response pbprovider.ResponseOrder
-		maps     []string
+		response pbprovider.ResponseDepth
+		err      error
 	)
 
-	// This code checks if the limit of the request is set to 0. If it is, then it sets the limit to 30. This is likely done
-	// so that a request has a sensible limit, even if one wasn't specified.
-	if req.GetLimit() == 0 {
-		req.Limit = 30
+	if len(req.GetType()) == 0 {
+		req.Type = types.TypeSpot
 	}
 
-	// The purpose of this switch statement is to generate a SQL query with the correct assignment clause. Depending on
-	// the value of req.GetAssigning(), the maps slice will be appended with the corresponding formatted string.
-	switch req.GetAssigning() {
-	case types.AssigningBuy:
-		maps = append(maps, fmt.Sprintf("where assigning = '%v'", types.AssigningBuy))
-	case types.AssigningSell:
-		maps = append(maps, fmt.Sprintf("where assigning = '%v'", types.AssigningSell))
-	default:
-		maps = append(maps, fmt.Sprintf("where (assigning = '%v' or assigning = '%v')", types.AssigningBuy, types.AssigningSell))
+	if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
+		return &response, err
 	}
 
-	// Append type to maps array if the length of req.GetType() is greater than 0.
-	if len(req.GetType()) > 0 {
+	if err := a.queryValidatePair(req.GetBaseUnit(), req.GetQuoteUnit(), req.GetType()); err != nil {
+		return &response, err
+	}
+
+	// The step of the grouping is a multiple of the price step of the pair, such as 0.1, 1 or 10, so that every level is made
+	// of the whole price steps.
+	if req.GetStep() != 0 {
 
-		// Checks that the request contains valid type and returns a response and an error if it does.
-		if err := types.Type(req.GetType()); err != nil {
+		var (
+			priceStep float64
+		)
+
+		if err := a.Context.Db.QueryRow("select price_step from pairs where base_unit = $1 and quote_unit = $2 and type = $3", req.GetBaseUnit(), req.GetQuoteUnit(), req.GetType()).Scan(&priceStep); err != nil {
 			return &response, err
 		}
 
-		maps = append(maps, fmt.Sprintf("and type = '%v'", req.GetType()))
+		if req.GetStep() < 0 || req.GetStep() < priceStep || !help.Step(req.GetStep(), priceStep) {
+			return &response, status.Errorf(11767, "the step %v of the depth must be a positive multiple of the price step %v", req.GetStep(), priceStep)
+		}
+	}
+
+	switch {
+	case req.GetLimit() <= 0:
+		req.Limit = 50
+	case req.GetLimit() > 500:
+		req.Limit = 500
 	}
 
-	// This checks to see if the request (req) has an owner. If it does, the code after this statement will be executed.
-	if req.GetOwner() {
+	depth, err := a.queryDepth(req.GetBaseUnit(), req.GetQuoteUnit(), req.GetType(), req.GetStep(), int(req.GetLimit()))
+	if err != nil {
+		return &response, err
+	}
+	response.Fields = append(response.Fields, depth)
 
-		// This code is used to check the authentication of the user. The auth variable is used to store the authentication
-		// credentials of the user, and the err variable is used to store any errors that might occur during the authentication
-		// process. If an error occurs, the response and error is returned.
-		auth, err := a.Context.Auth(ctx)
-		if err != nil {
-			return &response, err
-		}
+	return &response, nil
+}
 
-		// The purpose of this code is to append a formatted string to a slice of strings (maps). The string will include the
-		// value of the auth variable and will be of the format "and user_id = '%v'", where %v is a placeholder for the value of auth.
-		maps = append(maps, fmt.Sprintf("and user_id = '%v'", auth))
+// GetBootstrap - This function returns everything the trading screen of the pair needs when it is opened, in one call instead of
+// many: the pair, its latest 100 trades, the depth of its book and its 24h statistics, and for the signed in user the
+// open orders of the pair and the balances of its two assets. The anonymous request gets the public part only, the
+// updates that follow are read from the broker.
+func (a *Service) GetBootstrap(ctx context.Context, req *pbprovider.GetRequestBootstrap) (*pbprovider.ResponseBootstrap, error) {
 
-		//	The code snippet is most likely within an if statement, and the purpose of the else if statement is to check if the
-		//	user ID of the request is greater than 0. This could be used to check if the user is logged in or has an active
-		//	session before performing a certain action.
-	} else if req.GetUserId() > 0 {
+	// The purpose of this code is to declare the response variable of type pbprovider.ResponseBootstrap.
+	var (
+		response = pbprovider.ResponseBootstrap{Time: time.Now().Unix()}
+		id       int64
+		err      error
+	)
 
-		//This code is appending a string to a slice of strings (maps) which includes a formatted string containing the user
-		//ID from a request object (req). This is likely part of an SQL query being built, with the user ID being used to filter the results.
-		maps = append(maps, fmt.Sprintf("and user_id = '%v'", req.GetUserId()))
+	if len(req.GetType()) == 0 {
+		req.Type = types.TypeSpot
 	}
 
-	// The purpose of this switch statement is to add a condition to a query string based on the status of the request
-	// (req.GetStatus()). Depending on the value of the status, a string is added to the maps slice using the fmt.Sprintf()
-	// function. This string contains a condition that will be used in the query string.
-	switch req.GetStatus() {
-	case types.StatusFilled:
-		maps = append(maps, fmt.Sprintf("and status = '%v'", types.StatusFilled))
-	case types.StatusPending:
-		maps = append(maps, fmt.Sprintf("and status = '%v'", types.StatusPending))
-	case types.StatusCancel:
-		maps = append(maps, fmt.Sprintf("and status = '%v'", types.StatusCancel))
+	if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
+		return &response, err
 	}
 
-	// This code checks if the length of the base unit and the quote unit in the request are greater than 0. If they are, it
-	// appends a string to the maps variable which includes a formatted SQL query containing the base and quote unit. This
-	// is likely part of a larger SQL query used to search for data in a database.
-	if len(req.GetBaseUnit()) > 0 && len(req.GetQuoteUnit()) > 0 {
-		maps = append(maps, fmt.Sprintf("and base_unit = '%v' and quote_unit = '%v'", req.GetBaseUnit(), req.GetQuoteUnit()))
+	if err := a.Context.Db.QueryRow("select id from pairs where base_unit = $1 and quote_unit = $2 and type = $3", req.GetBaseUnit(), req.GetQuoteUnit(), req.GetType()).Scan(&id); err != nil {
+		return &response, status.Errorf(11585, "this pair %v-%v does not exist", req.GetBaseUnit(), req.GetQuoteUnit())
 	}
 
-	// The purpose of this code is to query the database to count the number of orders and total value of the orders in the
-	// database. It then stores the count and volume in the response variable.
-	_ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count, sum(value) as volume from orders %s", strings.Join(maps, " "))).Scan(&response.Count, &response.Volume)
-
-	// This statement is testing if the response from a user has a count that is greater than 0. If the response has a count
-	// greater than 0, then something else will occur.
-	if response.GetCount() > 0 {
+	pair, err := a.QueryPair(id, req.GetType(), false)
+	if err != nil {
+		return &response, err
+	}
+	response.Pair = pair
 
-		// This code is used to calculate the offset for a page of results in a request. It calculates the offset by
-		// multiplying the limit (number of results per page) by the page number. If the page number is greater than 0, then
-		// the offset is recalculated by multiplying the limit by one minus the page number.
-		offset := req.GetLimit() * req.GetPage()
-		if req.GetPage() > 0 {
-			offset = req.GetLimit() * (req.GetPage() - 1)
-		}
+	tape, err := a.GetTape(ctx, &pbprovider.GetRequestTape{BaseUnit: pair.GetBaseUnit(), QuoteUnit: pair.GetQuoteUnit(), Type: pair.GetType(), Limit: 100})
+	if err != nil {
+		return &response, err
+	}
+	response.Trades = tape.GetFields()
 
-		// This code is used to perform a SQL query on a database. It is used to select certain columns from the orders table
-		// and to order them by the id in descending order. The limit and offset parameters are used to limit the number of
-		// rows returned and to specify where in the result set to start returning rows from. The strings.Join function is used to join the "maps" parameter which is an array of strings.
-		rows, err := a.Context.Db.Query(fmt.Sprintf("select id, assigning, price, value, quantity, base_unit, quote_unit, user_id, create_at, type, status from orders %s order by id desc limit %d offset %d", strings.Join(maps, " "), req.GetLimit(), offset))
+	response.Depth, err = a.queryDepth(pair.GetBaseUnit(), pair.GetQuoteUnit(), pair.GetType(), 0, 50)
+	if err != nil {
+		return &response, err
+	}
+
+	response.Ticker, err = a.queryRolling(pair.GetBaseUnit(), pair.GetQuoteUnit())
+	if err != nil {
+		return &response, err
+	}
+
+	// The private part is returned to the signed in user only, the request without the token is not an error here.
+	if auth, err := a.Context.Auth(ctx); err == nil {
+
+		orders, err := a.GetOrders(ctx, &pbprovider.GetRequestOrders{Owner: true, Status: types.StatusPending, Type: pair.GetType(), BaseUnit: pair.GetBaseUnit(), QuoteUnit: pair.GetQuoteUnit(), Limit: 100})
+		if err != nil {
+			return &response, err
+		}
+		response.Orders = orders.GetFields()
+
+		for _, symbol := range []string{pair.GetBaseUnit(), pair.GetQuoteUnit()} {
+			response.Balances = append(response.Balances, &types.Asset{Symbol: symbol, Type: pair.GetType(), Balance: a.QueryBalance(symbol, pair.GetType(), auth)})
+		}
+	}
+
+	return &response, nil
+}
+
+// GetIndicators - This function calculates the common technical indicators (sma, ema, vwap, rsi and bollinger bands) of a pair on
+// the server side, so that the lightweight clients do not have to pull the full history of the candles. The candles are
+// requested with an extra period of history, so that the first returned points are already calculated, and the values
+// of every indicator are returned in chronological order, aligned with the times of the candles.
+func (a *Service) GetIndicators(ctx context.Context, req *pbprovider.GetRequestIndicators) (*pbprovider.ResponseIndicator, error) {
+
+	// The purpose of this code is to declare the response variable and the series of the candles used by the indicators.
+	var (
+		response                            pbprovider.ResponseIndicator
+		highs, lows, closes, volumes, times []float64
+	)
+
+	// This code sets the defaults of the request: a hundred points, the period of fourteen candles and the bands of two
+	// standard deviations, which are the usual settings of these indicators.
+	if req.GetLimit() == 0 || req.GetLimit() > 500 {
+		req.Limit = 100
+	}
+	if req.GetPeriod() <= 0 || req.GetPeriod() > 200 {
+		req.Period = 14
+	}
+	if req.GetDeviation() <= 0 {
+		req.Deviation = 2
+	}
+	if len(req.GetIndicators()) == 0 {
+		req.Indicators = []string{types.IndicatorSma, types.IndicatorEma, types.IndicatorVwap, types.IndicatorRsi, types.IndicatorBollinger}
+	}
+
+	// Validates the names of the indicators and returns an error if one of them is unknown.
+	for _, name := range req.GetIndicators() {
+		if err := types.Indicator(name); err != nil {
+			return &response, status.Error(11640, err.Error())
+		}
+	}
+
+	// This code requests the candles of the pair, with one more period of history, so that the indicators are warmed up.
+	ticker, err := a.GetTicker(ctx, &pbprovider.GetRequestTicker{BaseUnit: req.GetBaseUnit(), QuoteUnit: req.GetQuoteUnit(), Resolution: req.GetResolution(), To: req.GetTo(), Limit: req.GetLimit() + req.GetPeriod()})
+	if err != nil {
+		return &response, err
+	}
+
+	// The candles are returned from the newest to the oldest, so they are reversed into chronological order.
+	for i := len(ticker.GetFields()) - 1; i >= 0; i-- {
+		highs = append(highs, ticker.Fields[i].GetHigh())
+		lows = append(lows, ticker.Fields[i].GetLow())
+		closes = append(closes, ticker.Fields[i].GetClose())
+		volumes = append(volumes, ticker.Fields[i].GetVolume())
+		times = append(times, float64(ticker.Fields[i].GetTime()))
+	}
+
+	// The warm-up candles are cut off from the result, only the requested number of points is returned.
+	offset := len(closes) - int(req.GetLimit())
+	if offset < 0 {
+		offset = 0
+	}
+	for _, t := range times[offset:] {
+		response.Times = append(response.Times, int64(t))
+	}
+
+	// This code calculates every requested indicator, the bollinger bands are returned as three separate series.
+	period := int(req.GetPeriod())
+	for _, name := range req.GetIndicators() {
+		switch name {
+		case types.IndicatorSma:
+			response.Fields = append(response.Fields, &pbprovider.Indicator{Name: name, Values: indicator.Sma(closes, period)[offset:]})
+		case types.IndicatorEma:
+			response.Fields = append(response.Fields, &pbprovider.Indicator{Name: name, Values: indicator.Ema(closes, period)[offset:]})
+		case types.IndicatorVwap:
+			response.Fields = append(response.Fields, &pbprovider.Indicator{Name: name, Values: indicator.Vwap(highs, lows, closes, volumes)[offset:]})
+		case types.IndicatorRsi:
+			response.Fields = append(response.Fields, &pbprovider.Indicator{Name: name, Values: indicator.Rsi(closes, period)[offset:]})
+		case types.IndicatorBollinger:
+			upper, middle, lower := indicator.Bollinger(closes, period, req.GetDeviation())
+			response.Fields = append(response.Fields, &pbprovider.Indicator{Name: name + "_upper", Values: upper[offset:]}, &pbprovider.Indicator{Name: name + "_middle", Values: middle[offset:]}, &pbprovider.Indicator{Name: name + "_lower", Values: lower[offset:]})
+		}
+	}
+
+	return &response, nil
+}
+
+// GetBacktest - This function tests the strategy of the user against the candles of the pair the backend keeps: the position is
+// opened when all the conditions of the entry hold and closed when all the conditions of the exit hold, or at the stop
+// loss and the take profit. The fees of the trades are those of the assets of the pair. The trades, the equity curve with
+// a point per candle and the summary of the test are returned, the candles are read up to the time to, the limit is the
+// number of the candles and the candles before the time from are left out.
+func (a *Service) GetBacktest(ctx context.Context, req *pbprovider.GetRequestBacktest) (*pbprovider.ResponseBacktest, error) {
+
+	var (
+		response pbprovider.ResponseBacktest
+		candles  []backtest.Candle
+		entry    []backtest.Condition
+		exit     []backtest.Condition
+		buy      float64
+		sell     float64
+	)
+
+	if req.GetLimit() == 0 || req.GetLimit() > 5000 {
+		req.Limit = 500
+	}
+	if req.GetCapital() == 0 {
+		req.Capital = 1000
+	}
+
+	ticker, err := a.GetTicker(ctx, &pbprovider.GetRequestTicker{BaseUnit: req.GetBaseUnit(), QuoteUnit: req.GetQuoteUnit(), Resolution: req.GetResolution(), To: req.GetTo(), Limit: req.GetLimit()})
+	if err != nil {
+		return &response, err
+	}
+
+	// The candles are returned from the newest to the oldest, so they are reversed into chronological order.
+	for i := len(ticker.GetFields()) - 1; i >= 0; i-- {
+		if item := ticker.Fields[i]; item.GetTime() >= req.GetFrom() {
+			candles = append(candles, backtest.Candle{Time: item.GetTime(), Open: item.GetOpen(), High: item.GetHigh(), Low: item.GetLow(), Close: item.GetClose(), Volume: item.GetVolume()})
+		}
+	}
+
+	for _, item := range req.GetEntry() {
+		entry = append(entry, backtest.Condition{Left: item.GetLeft(), Operator: item.GetOperator(), Right: item.GetRight()})
+	}
+	for _, item := range req.GetExit() {
+		exit = append(exit, backtest.Condition{Left: item.GetLeft(), Operator: item.GetOperator(), Right: item.GetRight()})
+	}
+
+	// The buy is charged the fees of the base asset it receives and the sell the fees of the quote asset.
+	_ = a.Context.Db.QueryRow("select fees_trade from assets where symbol = $1", req.GetBaseUnit()).Scan(&buy)
+	_ = a.Context.Db.QueryRow("select fees_trade from assets where symbol = $1", req.GetQuoteUnit()).Scan(&sell)
+
+	result, err := backtest.Run(candles, backtest.Rules{Entry: entry, Exit: exit, StopLoss: req.GetStopLoss(), TakeProfit: req.GetTakeProfit(), Capital: req.GetCapital(), BuyFees: buy, SellFees: sell})
+	if err != nil {
+		return &response, status.Error(11797, err.Error())
+	}
+
+	for _, item := range result.Trades {
+		response.Fields = append(response.Fields, &pbprovider.BacktestTrade{EntryTime: item.EntryTime, ExitTime: item.ExitTime, EntryPrice: item.EntryPrice, ExitPrice: item.ExitPrice, Quantity: item.Quantity, Profit: item.Profit, Percent: item.Return, Reason: item.Reason})
+	}
+
+	for _, item := range result.Equity {
+		response.Equity = append(response.Equity, &pbprovider.Equity{Time: item.Time, Value: item.Equity})
+	}
+
+	response.Summary = &pbprovider.BacktestSummary{
+		Trades:       int32(result.Summary.Trades),
+		Wins:         int32(result.Summary.Wins),
+		Losses:       int32(result.Summary.Losses),
+		WinRate:      result.Summary.WinRate,
+		Percent:      result.Summary.Return,
+		Drawdown:     result.Summary.Drawdown,
+		ProfitFactor: result.Summary.ProfitFactor,
+		Equity:       result.Summary.Equity,
+		Capital:      req.GetCapital(),
+		BuyFees:      buy,
+		SellFees:     sell,
+	}
+
+	return &response, nil
+}
+
+// SetTicker - The purpose of this code is to add the tick of the trade to the database table of the candles and to publish the
+// updated candles of every interval to the exchange, throttled by the configuration. The candles are read and published
+// by the throttle, so the response does not carry them, the clients read them by the GetTicker function.
+func (a *Service) SetTicker(_ context.Context, req *pbprovider.SetRequestTicker) (*pbprovider.ResponseTicker, error) {
+
+	// The purpose of this code is to declare a variable called 'response' of type 'pbprovider.Response'. This variable will be
+	// used to store data that is returned from a function call involving the 'pbprovider' library.
+	var (
+		response pbprovider.ResponseTicker
+	)
+
+	// This code is checking if the key provided in the request (req.GetKey()) matches the secret stored in the context
+	// (s.Context.Secrets[2]). If the keys don't match, the code is returning an error with the code 654333 and the message
+	// "the access key is incorrect". This is likely part of an authorization process to make sure only authorized users are
+	// able to access a certain resource.
+	if req.GetKey() != a.Context.Secrets[2] {
+		return &response, status.Error(654333, "the access key is incorrect")
+	}
+
+	// This piece of code is inserting data into a database table. The purpose of this code is to add a new row to the
+	// "ohlcv" table, based on the values stored in the params array. The five columns in the table are assigning,
+	// base_unit, quote_unit, price, and quantity, and each of these is being populated with the corresponding value from
+	// the params array. The code then checks for any errors that may have occurred while executing the query and returns if any are found.
+	if _, err := a.Context.Db.Exec(`insert into ohlcv (assigning, base_unit, quote_unit, price, quantity) values ($1, $2, $3, $4, $5)`, req.GetAssigning(), req.GetBaseUnit(), req.GetQuoteUnit(), req.GetPrice(), req.GetValue()); a.Context.Debug(err) {
+		return &response, err
+	}
+
+	// The new tick changes the candles of the pair, so the version of the pair is increased and the cached candles of the
+	// higher timeframes are no longer used.
+	a.writeVersion(req.GetBaseUnit(), req.GetQuoteUnit())
+
+	// The ticks of the executed trades are added to the rolling 24h statistics of the pair, and the updated statistics are
+	// published to the exchange, the supply ticks of the market replay are not trades and are skipped.
+	if req.GetAssigning() != types.AssigningSupply {
+
+		if err := a.writeRolling(req.GetBaseUnit(), req.GetQuoteUnit(), req.GetPrice(), req.GetValue()); a.Context.Debug(err) {
+			return &response, err
+		}
+
+		ticker, err := a.queryRolling(req.GetBaseUnit(), req.GetQuoteUnit())
+		if a.Context.Debug(err) {
+			return &response, err
+		}
+
+		if err := a.Context.Publish(ticker, "exchange", "trade/ticker-24h"); err != nil {
+			return &response, err
+		}
+	}
+
+	// The candles of every interval are published throttled, the trades of a busy pair are coalesced into at most one
+	// publishing of the candle per the throttle, and the candle that has just opened is published at once, so that the
+	// candle that has closed is never held back.
+	for _, interval := range help.Depth() {
+
+		var (
+			base, quote, resolution = req.GetBaseUnit(), req.GetQuoteUnit(), interval
+			window                  = time.Now().UTC().Truncate(help.Period(interval)).Unix()
+		)
+
+		a.Context.Throttle(fmt.Sprintf("ticker:%v:%v:%v", base, quote, resolution), window, func() error {
+
+			// The two latest candles are read when the candle is published rather than when the trade is made, so that the
+			// coalesced trades are all in the published candle.
+			migrate, err := a.GetTicker(context.Background(), &pbprovider.GetRequestTicker{BaseUnit: base, QuoteUnit: quote, Limit: 2, Resolution: resolution})
+			if err != nil {
+				return err
+			}
+
+			return a.Context.Publish(migrate, "exchange", fmt.Sprintf("trade/ticker:%v", resolution))
+		})
+	}
+
+	return &response, nil
+}
+
+// GetPrice - This function is part of a service that is used to get the price of a given asset. It takes in a context and a
+// GetRequestPriceManual request object as parameters. The purpose of the function is to get the price of the asset from
+// the given base and quote units, then return a ResponsePrice object. It also checks if the price can be obtained from
+// the quote and base units in the opposite order. If so, the price is then calculated by taking the inverse of the price
+// and rounding it to 8 decimal places. Finally, the ResponsePrice object is returned.
+func (a *Service) GetPrice(_ context.Context, req *pbprovider.GetRequestPrice) (*pbprovider.ResponsePrice, error) {
+
+	// The code above declares two variables: response of type pbprovider.ResponsePrice and ok of type bool. The purpose of this
+	// code is to create two variables that can be used in the code that follows.
+	var (
+		response pbprovider.ResponsePrice
+		ok       bool
+	)
+
+	// The purpose of this code is to check whether the price of a certain item has been successfully retrieved, and if so,
+	// return the response and a nil (no error) value. The line starts by attempting to get the price of the item based on
+	// the given base and quote units, and then assigns the response and the boolean value of ok to the variables'
+	// response.Price and ok, respectively. Finally, the code returns the response and a nil value if ok is true.
+	if response.Price, ok = a.queryPrice(req.GetBaseUnit(), req.GetQuoteUnit()); ok {
+		return &response, nil
+	}
+
+	// This code is checking if the price of a product or service can be obtained given the quote and base units. If the
+	// price can be obtained, the response will be rounded to 8 decimal places and stored in the response.Price variable.
+	if response.Price, ok = a.queryPrice(req.GetQuoteUnit(), req.GetBaseUnit()); ok {
+		response.Price = decimal.New(decimal.New(1).Div(response.Price).Float()).Round(8).Float()
+	}
+
+	return &response, nil
+}
+
+// GetOrders - This function is used to get orders from the database based on the parameters provided. It uses the req
+// *pbprovider.GetRequestOrders which contains parameters such as the limit and the cursor, assigning, owner, user_id, status,
+// base_unit and quote_unit and the date range to query the database, the orders are sorted by the id, the price, the value
+// or the date of the order. The function then uses the query results to build a response which is a *pbprovider.ResponseOrder.
+// This includes the count and volume of the query results, the list of the orders of the page and the cursor of the next page.
+func (a *Service) GetOrders(ctx context.Context, req *pbprovider.GetRequestOrders) (*pbprovider.ResponseOrder, error) {
+
+	var (
+		response pbprovider.ResponseOrder
+		orders   []*types.Order
+	)
+
+	page, err := query.NewPage(req.GetLimit(), req.GetSort(), req.GetOrder(), req.GetCursor(), "id", "price", "value", "create_at")
+	if err != nil {
+		return &response, err
+	}
+	page.Offset(req.GetPage())
+
+	// This code is used to filter the orders by the side of the order, the orders of both sides are returned by default.
+	if assigning, err := types.Normalize(types.EnumAssigning, req.GetAssigning()); err == nil {
+		page.Where("assigning = ?", assigning)
+	}
+
+	// This code checks if the type of the order is set, and if it is, it checks that the type is correct.
+	if len(req.GetType()) > 0 {
+
+		if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
+			return &response, err
+		}
+
+		page.Where("type = ?", req.GetType())
+	}
+
+	// The owner reads his own orders, the orders of another user are read by the user id.
+	if req.GetOwner() {
+
+		auth, err := a.Context.Auth(ctx)
+		if err != nil {
+			return &response, err
+		}
+
+		page.Where("user_id = ?", auth)
+
+	} else if req.GetUserId() > 0 {
+		page.Where("user_id = ?", req.GetUserId())
+	}
+
+	if len(req.GetStatus()) > 0 {
+
+		if req.Status, err = types.Normalize(types.EnumStatus, req.GetStatus()); err != nil {
+			return &response, err
+		}
+
+		page.Where("status = ?", req.GetStatus())
+	}
+
+	if len(req.GetBaseUnit()) > 0 && len(req.GetQuoteUnit()) > 0 {
+		page.Where("base_unit = ? and quote_unit = ?", req.GetBaseUnit(), req.GetQuoteUnit())
+	}
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	// The count and the volume are of all the orders of the filters, not only of the page.
+	where, params := page.Filter()
+	_ = a.Context.Reader().QueryRow(fmt.Sprintf("select count(*) as count, coalesce(sum(value), 0) as volume from orders %s", where), params...).Scan(&response.Count, &response.Volume)
+
+	if response.GetCount() > 0 {
+
+		statement, params := page.Query("id, uid, assigning, price, value, quantity, base_unit, quote_unit, user_id, create_at, type, status, client_id", "orders")
+		rows, err := a.Context.Reader().Query(statement, params...)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Order
+			)
+
+			if err = rows.Scan(&item.Id, &item.Uid, &item.Assigning, &item.Price, &item.Value, &item.Quantity, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.CreateAt, &item.Type, &item.Status, &item.ClientId); err != nil {
+				return &response, err
+			}
+
+			orders = append(orders, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+
+		response.Fields, response.Cursor = query.Cut(page, orders)
+	}
+
+	return &response, nil
+}
+
+// GetTrades - This function is a method of the Service type. It is used to get a list of trades from a database. It takes a
+// context and a request object as parameters. The request object contains information about the requested trades, such
+// as the limit and the cursor, the order ID, the pair, the side and the date range, and whether the request should only
+// return the trades of the user. The trades of all the orders are only returned to their owner. The function then queries
+// the database for the requested trades, and returns a ResponseTrade object with the trades and the cursor of the next page.
+func (a *Service) GetTrades(ctx context.Context, req *pbprovider.GetRequestTrades) (*pbprovider.ResponseTrade, error) {
+
+	var (
+		response pbprovider.ResponseTrade
+		trades   []*types.Trade
+	)
+
+	page, err := query.NewPage(req.GetLimit(), req.GetSort(), req.GetOrder(), req.GetCursor(), "id", "price", "quantity", "create_at")
+	if err != nil {
+		return &response, err
+	}
+
+	if assigning, err := types.Normalize(types.EnumAssigning, req.GetAssigning()); err == nil {
+		page.Where("assigning = ?", assigning)
+	}
+
+	// This code is used to get the id of the order by its uid.
+	if len(req.GetOrderUid()) > 0 {
+		id, err := a.QueryIdentifier("orders", req.GetOrderUid())
+		if err != nil {
+			return &response, err
+		}
+		req.OrderId = id
+	}
+
+	if req.GetOrderId() > 0 || !req.GetOwner() {
+		page.Where("order_id = ?", req.GetOrderId())
+	}
+
+	if req.GetOwner() {
+
+		auth, err := a.Context.Auth(ctx)
+		if err != nil {
+			return &response, err
+		}
+
+		page.Where("user_id = ?", auth)
+	}
+
+	if len(req.GetBaseUnit()) > 0 && len(req.GetQuoteUnit()) > 0 {
+		page.Where("base_unit = ? and quote_unit = ?", req.GetBaseUnit(), req.GetQuoteUnit())
+	}
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	statement, params := page.Query("id, uid, user_id, base_unit, quote_unit, price, quantity, assigning, fees, maker, create_at", "trades")
+	rows, err := a.Context.Reader().Query(statement, params...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Trade
+		)
+
+		if err = rows.Scan(&item.Id, &item.Uid, &item.UserId, &item.BaseUnit, &item.QuoteUnit, &item.Price, &item.Quantity, &item.Assigning, &item.Fees, &item.Maker, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		trades = append(trades, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	response.Fields, response.Cursor = query.Cut(page, trades)
+
+	return &response, nil
+}
+
+// GetTape - This function returns the tape of the public trades of the pair, the newest first, paged by the cursor like the other
+// lists. Both of the orders of a trade are written to the trades, the trade is read once, by the order of the taker, whose
+// side is the side of the trade, and without the user and the fees, which are not public. The trades that follow are
+// published to the "trade/tape:<type>" channel of the broker as they are made, so the tape is continued from the broker.
+func (a *Service) GetTape(_ context.Context, req *pbprovider.GetRequestTape) (*pbprovider.ResponseTrade, error) {
+
+	var (
+		response pbprovider.ResponseTrade
+		trades   []*types.Trade
+		err      error
+	)
+
+	if len(req.GetType()) == 0 {
+		req.Type = types.TypeSpot
+	}
+
+	if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
+		return &response, err
+	}
+
+	if err := a.queryValidatePair(req.GetBaseUnit(), req.GetQuoteUnit(), req.GetType()); err != nil {
+		return &response, err
+	}
+
+	page, err := query.NewPage(req.GetLimit(), "id", req.GetOrder(), req.GetCursor(), "id")
+	if err != nil {
+		return &response, err
+	}
+
+	page.Where("base_unit = ? and quote_unit = ?", req.GetBaseUnit(), req.GetQuoteUnit())
+	page.Where("maker = ?", false)
+	page.Where("exists (select 1 from orders o where o.id = trades.order_id and o.type = ?)", req.GetType())
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	statement, params := page.Query("id, uid, base_unit, quote_unit, price, quantity, assigning, create_at", "trades")
+	rows, err := a.Context.Reader().Query(statement, params...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Trade
+		)
+
+		if err = rows.Scan(&item.Id, &item.Uid, &item.BaseUnit, &item.QuoteUnit, &item.Price, &item.Quantity, &item.Assigning, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		trades = append(trades, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	response.Fields, response.Cursor = query.Cut(page, trades)
+
+	return &response, nil
+}
+
+// GetHistory - This function returns the history of the orders and the trades of the user in one list, the spot and the stock ones
+// alike, so that the user trading both does not have to read two lists and merge them. The entries are filtered by their
+// kind, the type, the pair, the side, the status and the date range, and are paged by the cursor like the other lists.
+// The id of the entry is the id of the list, the ids of the order and of the trade are returned with it, and the assets
+// of the pairs of the page are returned with their names, groups, types and zones.
+func (a *Service) GetHistory(ctx context.Context, req *pbprovider.GetRequestHistory) (*pbprovider.ResponseHistory, error) {
+
+	var (
+		response pbprovider.ResponseHistory
+		entries  []*types.History
+		symbols  []string
+	)
+
+	page, err := query.NewPage(req.GetLimit(), req.GetSort(), req.GetOrder(), req.GetCursor(), "create_at", "id", "price", "quantity")
+	if err != nil {
+		return &response, err
+	}
+
+	auth := a.Context.User(ctx)
+
+	// The orders and the trades of the user are read from the shared tables as one list: the ids of the orders are even and the
+	// ids of the trades are odd, so that the entries have the ids of their own the cursor is kept by. The type of the trade
+	// is the type of its order, the trades of the futures and the supply of the market makers are not the trades of the orders.
+	history := fmt.Sprintf(`(select o.id * 2 as id, %[2]s as kind, o.type, o.id as order_id, 0 as trade_id, o.uid::text as uid, o.base_unit, o.quote_unit, o.assigning, o.trading, o.status, o.price, o.quantity, o.value, 0::double precision as fees, false as maker, o.create_at from orders o where o.user_id = %[1]s
+		union all
+		select t.id * 2 + 1, %[3]s, o.type, t.order_id, t.id, t.uid::text, t.base_unit, t.quote_unit, t.assigning, o.trading, %[4]s, t.price, t.quantity, t.quantity * t.price, t.fees, t.maker, t.create_at from trades t join orders o on o.id = t.order_id and o.user_id = t.user_id where t.user_id = %[1]s and t.assigning in (%[5]s, %[6]s)) as history`,
+		page.Bind(auth), page.Bind(types.HistoryOrder), page.Bind(types.HistoryTrade), page.Bind(types.StatusFilled), page.Bind(types.AssigningBuy), page.Bind(types.AssigningSell))
+
+	if len(req.GetKind()) > 0 {
+
+		if err := types.HistoryKind(req.GetKind()); err != nil {
+			return &response, err
+		}
+
+		page.Where("kind = ?", req.GetKind())
+	}
+
+	if len(req.GetType()) > 0 {
+
+		if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
+			return &response, err
+		}
+
+		page.Where("type = ?", req.GetType())
+	}
+
+	if assigning, err := types.Normalize(types.EnumAssigning, req.GetAssigning()); err == nil {
+		page.Where("assigning = ?", assigning)
+	}
+
+	if len(req.GetStatus()) > 0 {
+
+		if req.Status, err = types.Normalize(types.EnumStatus, req.GetStatus()); err != nil {
+			return &response, err
+		}
+
+		page.Where("status = ?", req.GetStatus())
+	}
+
+	if len(req.GetBaseUnit()) > 0 && len(req.GetQuoteUnit()) > 0 {
+		page.Where("base_unit = ? and quote_unit = ?", req.GetBaseUnit(), req.GetQuoteUnit())
+	}
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	// The count is of all the entries of the filters, not only of the page.
+	where, params := page.Filter()
+	_ = a.Context.Reader().QueryRow(fmt.Sprintf("select count(*) as count from %s %s", history, where), params...).Scan(&response.Count)
+
+	if response.GetCount() == 0 {
+		return &response, nil
+	}
+
+	statement, params := page.Query("id, kind, type, order_id, trade_id, uid, base_unit, quote_unit, assigning, trading, status, price, quantity, value, fees, maker, create_at", history)
+	rows, err := a.Context.Reader().Query(statement, params...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.History
+		)
+
+		if err = rows.Scan(&item.Id, &item.Kind, &item.Type, &item.OrderId, &item.TradeId, &item.Uid, &item.BaseUnit, &item.QuoteUnit, &item.Assigning, &item.Trading, &item.Status, &item.Price, &item.Quantity, &item.Value, &item.Fees, &item.Maker, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		entries = append(entries, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	response.Fields, response.Cursor = query.Cut(page, entries)
+
+	// The assets of the pairs of the page are returned once each, the entries refer to them by their symbols.
+	for _, item := range response.GetFields() {
+		for _, symbol := range []string{item.GetBaseUnit(), item.GetQuoteUnit()} {
+			if !help.IndexOf(symbols, symbol) {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+
+	for _, symbol := range symbols {
+
+		asset, err := a.QueryAsset(symbol, false)
+		if err != nil {
+			continue
+		}
+
+		response.Assets = append(response.Assets, asset)
+	}
+
+	return &response, nil
+}
+
+// GetTransactions - This function is a method in a service struct used to get a list of transactions and associated data from a database.
+// The function takes a context.Context and a *pbprovider.GetRequestTransactions as parameters. The function returns a
+// response of type *pbprovider.ResponseTransaction and an error. The function filters the transactions of the user by
+// the assignment, the symbol, the status, the chain, the platform, the protocol and the date range, and sorts them by the
+// id, the value or the date. The function then queries for the number of transactions, their totals and the transactions
+// of the page with the links to the block explorer, and returns them with the cursor of the next page.
+func (a *Service) GetTransactions(ctx context.Context, req *pbprovider.GetRequestTransactions) (*pbprovider.ResponseTransaction, error) {
+
+	var (
+		response     pbprovider.ResponseTransaction
+		transactions []*types.Transaction
+	)
+
+	page, err := query.NewPage(req.GetLimit(), req.GetSort(), req.GetOrder(), req.GetCursor(), "id", "value", "create_at")
+	if err != nil {
+		return &response, err
+	}
+	page.Offset(req.GetPage())
+
+	if assignment, err := types.Normalize(types.EnumAssignment, req.GetAssignment()); err == nil {
+		page.Where("assignment = ?", assignment)
+	} else {
+		page.Where("assignment in (?, ?)", types.AssignmentWithdrawal, types.AssignmentDeposit)
+	}
+
+	if len(req.GetSymbol()) > 0 {
+		page.Where("symbol = ?", req.GetSymbol())
+	}
+
+	if len(req.GetStatus()) > 0 {
+		if req.Status, err = types.Normalize(types.EnumStatus, req.GetStatus()); err != nil {
+			return &response, err
+		}
+	}
+
+	// The transactions of the reserves are internal, they are never shown to the users, the status is compared once it is
+	// brought to its constant.
+	if len(req.GetStatus()) > 0 && req.GetStatus() != types.StatusReserve {
+		page.Where("status = ?", req.GetStatus())
+	} else {
+		page.Where("status != ?", types.StatusReserve)
+	}
+
+	// This code is used to get the id of the transaction by its uid.
+	if len(req.GetUid()) > 0 {
+		id, err := a.QueryIdentifier("transactions", req.GetUid())
+		if err != nil {
+			return &response, err
+		}
+		req.Id = id
+	}
+
+	if req.GetId() > 0 {
+		page.Where("id = ?", req.GetId())
+	}
+
+	// The transactions are filtered by the chain, the platform and the protocol they were sent on, so that the user finds the
+	// deposit of the network without scrolling through all of them.
+	if req.GetChainId() > 0 {
+		page.Where("chain_id = ?", req.GetChainId())
+	}
+
+	if len(req.GetPlatform()) > 0 {
+
+		if err := types.Platform(req.GetPlatform()); err != nil {
+			return &response, err
+		}
+
+		page.Where("platform = ?", req.GetPlatform())
+	}
+
+	if len(req.GetProtocol()) > 0 {
+
+		if err := types.Protocol(req.GetProtocol()); err != nil {
+			return &response, err
+		}
+
+		page.Where("protocol = ?", req.GetProtocol())
+	}
+
+	if err := page.Range("create_at", req.GetFrom(), req.GetTo()); err != nil {
+		return &response, err
+	}
+
+	auth := a.Context.User(ctx)
+
+	page.Where("user_id = ?", auth)
+
+	where, params := page.Filter()
+	_ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from transactions %s", where), params...).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		// The totals are of all the transactions of the filters, not only of the page, per the asset and the direction, since
+		// the values of the different assets can not be summed.
+		totals, err := a.Context.Db.Query(fmt.Sprintf("select symbol, assignment, count(*), coalesce(sum(value), 0), coalesce(sum(fees), 0) from transactions %s group by symbol, assignment order by symbol, assignment", where), params...)
+		if err != nil {
+			return &response, err
+		}
+		defer totals.Close()
+
+		for totals.Next() {
+
+			var (
+				item pbprovider.TransactionTotal
+			)
+
+			if err := totals.Scan(&item.Symbol, &item.Assignment, &item.Count, &item.Value, &item.Fees); err != nil {
+				return &response, err
+			}
+
+			response.Totals = append(response.Totals, &item)
+		}
+
+		if err = totals.Err(); err != nil {
+			return &response, err
+		}
+
+		statement, params := page.Query(`id, uid, symbol, hash, value, price, fees, confirmation, "to", chain_id, user_id, assignment, "group", platform, protocol, status, error, create_at`, "transactions")
+		rows, err := a.Context.Db.Query(statement, params...)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Transaction
+			)
+
+			if err = rows.Scan(
+				&item.Id,
+				&item.Uid,
+				&item.Symbol,
+				&item.Hash,
+				&item.Value,
+				&item.Price,
+				&item.Fees,
+				&item.Confirmation,
+				&item.To,
+				&item.ChainId,
+				&item.UserId,
+				&item.Assignment,
+				&item.Group,
+				&item.Platform,
+				&item.Protocol,
+				&item.Status,
+				&item.Error,
+				&item.CreateAt,
+			); err != nil {
+				return &response, err
+			}
+
+			// This code is retrieving the chain from the given chain ID and storing it in the item.Chain variable. It then checks
+			// if an error occurred while doing so and if there was an error, it will return a nil value and the error.
+			item.Chain, err = a.QueryChain(item.GetChainId(), false)
+			if err != nil {
+				return nil, err
+			}
+
+			// The link to the transaction in the block explorer of the chain, the transactions not sent yet have no hash and no link.
+			item.Explorer = help.Explorer(item.Chain.GetExplorerLink(), item.GetHash())
+
+			// This code checks the protocol associated with the item. If the protocol is not equal to the mainnet protocol, then
+			// the fees associated with the item are multiplied by the item's price, and the result is stored as a float.
+			if item.GetProtocol() != types.ProtocolMainnet {
+				item.Fees = decimal.New(item.GetFees()).Mul(item.GetPrice()).Float()
+			}
+
+			transactions = append(transactions, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+
+		response.Fields, response.Cursor = query.Cut(page, transactions)
+	}
+
+	return &response, nil
+}
+
+// GetSnapshots - This function returns the time series of the account value of the authorized user, recorded by the snapshot
+// job. The series can be filtered by resolution (hour or day) and by a time range, the points are returned in
+// chronological order so that they can be drawn as an equity curve.
+func (a *Service) GetSnapshots(ctx context.Context, req *pbprovider.GetRequestSnapshots) (*pbprovider.ResponseSnapshot, error) {
+
+	// The purpose of this code is to declare the response variable.
+	var (
+		response pbprovider.ResponseSnapshot
+	)
+
+	auth := a.Context.User(ctx)
+
+	// The daily resolution is used by default, since it is available for the whole history of the account.
+	if len(req.GetResolution()) == 0 {
+		req.Resolution = types.SnapshotDay
+	}
+
+	// Validates the resolution of the request and returns an error if it is invalid.
+	if err := types.Snapshot(req.GetResolution()); err != nil {
+		return &response, err
+	}
+
+	// This code sets a sensible default limit on the number of points, a year of daily snapshots.
+	if req.GetLimit() == 0 {
+		req.Limit = 365
+	}
+
+	// These conditions filter the snapshots by the time range passed in the request, in unix seconds.
+	builder := query.NewBuilder(auth, req.GetResolution())
+	if req.GetFrom() > 0 {
+		builder.Where("create_at >= to_timestamp(?)", req.GetFrom())
+	}
+	if req.GetTo() > 0 {
+		builder.Where("create_at < to_timestamp(?)", req.GetTo())
+	}
+
+	// This query selects the latest snapshots of the user, they are reversed below into chronological order.
+	rows, err := a.Context.Db.Query(fmt.Sprintf(`select value, unit, resolution, extract(epoch from create_at)::integer from snapshots where user_id = $1 and resolution = $2 %s order by create_at desc limit %s`, builder.And(), builder.Bind(req.GetLimit())), builder.Params()...)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item pbprovider.Snapshot
+		)
+
+		if err := rows.Scan(&item.Value, &item.Unit, &item.Resolution, &item.Time); err != nil {
+			return &response, err
+		}
+
+		// The item is prepended to the list, so that the points go in chronological order.
+		response.Fields = append([]*pbprovider.Snapshot{&item}, response.Fields...)
+	}
+
+	if err = rows.Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// CancelOrder - This function is used to cancel an order in a spot trading system. It takes in a context and a request object, and
+// returns a response object and an error. It checks the status of the order, updates the order status to "CANCEL",
+// updates the balance, and publishes a message.
+func (a *Service) CancelOrder(ctx context.Context, req *pbprovider.CancelRequestOrder) (*pbprovider.ResponseOrder, error) {
+
+	// The purpose of this code is to declare a variable named response of type pbprovider.ResponseOrder. This is a type used to
+	// store information about a response to an order, such as the status, order ID, and other details.
+	var (
+		response pbprovider.ResponseOrder
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	// The users who trade on the paper cancel their simulated orders.
+	if a.queryPaper(auth) {
+
+		item, err := a.writePaperCancel(auth, req.GetId())
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = append(response.Fields, item)
+		response.Success = true
+
+		return &response, nil
+	}
+
+	// The order can also be passed by its external identifier, in this case it is resolved into the internal id of the order.
+	if len(req.GetUid()) > 0 {
+		if req.Id, err = a.QueryIdentifier("orders", req.GetUid()); err != nil {
+			return &response, err
+		}
+	}
+
+	// This query is used to fetch data from the orders table in the database. The query is parameterized to ensure that
+	// only the desired records are returned. The parameters are the status, id, and user_id. The query also includes an
+	// order by clause to ensure that the data is returned in a specific order. The data is then stored in the row variable
+	// and the defer statement is used to close the row when the query is finished.
+	row, err := a.Context.Db.Query(`select id, uid, value, quantity, price, assigning, base_unit, quote_unit, user_id, type, create_at from orders where id = $1 and status in ($2, $4) and user_id = $3 order by id`, req.GetId(), types.StatusPending, auth, types.StatusQueue)
+	if err != nil {
+		return &response, err
+	}
+	defer row.Close()
+
+	// The purpose of the following code is to check if there is a row available for retrieving data from. The `row.Next()`
+	// method returns a boolean value indicating whether there is a row available. If the result is true, it means that a
+	// row is available and can be used to retrieve data.
+	if row.Next() {
+
+		// The purpose of the 'var' statement is to declare a new variable, in this case "item", which is of type
+		// "types.Order". This allows the program to use the variable "item" to store values of type "types.Order", such as
+		// orders placed on an online store.
+		var (
+			item types.Order
+		)
+
+		// This code is used to scan the row of a database table and assign the values to the relevant variables. The if
+		// statement checks for any errors that may occur during the scanning process, and if an error is found, it will return an error response.
+		if err = row.Scan(&item.Id, &item.Uid, &item.Value, &item.Quantity, &item.Price, &item.Assigning, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.Type, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		tx, err := a.Context.Db.BeginTx(ctx, nil)
+		if err != nil {
+			return &response, err
+		}
+		defer tx.Rollback()
+
+		// The purpose of the code is to update the status of an order for a particular user in the database. The order is
+		// cancelled only while it is resting or queued, the order which was filled or cancelled since it was read is left
+		// as it is, so that its funds are never released twice.
+		result, err := tx.Exec("update orders set status = $3 where id = $1 and user_id = $2 and status in ($4, $5)", item.GetId(), item.GetUserId(), types.StatusCancel, types.StatusPending, types.StatusQueue)
+		if err != nil {
+			return &response, err
+		}
+
+		if count, _ := result.RowsAffected(); count == 0 {
+			return &response, status.Error(11538, "the requested order was filled or cancelled in the meantime")
+		}
+
+		// The funds held by the order become available again by the same transaction, as by the writeCancelAll function,
+		// the buy orders held the quote asset and the sell orders the base asset.
+		if _, err := tx.Exec("delete from holds where reference = $1 and reference_id = $2", types.HoldOrder, item.GetId()); err != nil {
+			return &response, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return &response, err
+		}
+		item.Status = types.StatusCancel
+
+		// The cancellation is journaled, the replay of the journal closes the order at the same point.
+		a.Context.Debug(a.writeJournal(types.JournalCancel, &item, 0, item.GetPrice(), item.GetValue()))
+
+		// This code is intended to publish an item to an exchange with the routing key "order/cancel". If any errors occur
+		// while attempting to publish the item, the error is returned and the response is returned.
+		if err := a.Context.Publish(&item, "exchange", "order/cancel"); err != nil {
+			return &response, err
+		}
+
+	} else {
+		return &response, status.Error(11538, "the requested order does not exist")
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// CancelAllOrders - This method cancels all the resting and the queued orders of the user at once, of the pair and of the side if
+// they are given. The orders are cancelled as a whole, their funds become available again, and the cancelled orders are
+// returned.
+func (a *Service) CancelAllOrders(ctx context.Context, req *pbprovider.CancelRequestAllOrders) (*pbprovider.ResponseOrder, error) {
+
+	var (
+		response pbprovider.ResponseOrder
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	// The pair is given by both of its units, the side on its own.
+	if (len(req.GetBaseUnit()) == 0) != (len(req.GetQuoteUnit()) == 0) {
+		return &response, status.Error(11809, "the pair of the orders is given by both the base unit and the quote unit")
+	}
+
+	if len(req.GetAssigning()) > 0 {
+		if req.Assigning, err = types.Normalize(types.EnumAssigning, req.GetAssigning()); err != nil {
+			return &response, err
+		}
+	}
+
+	if response.Fields, err = a.writeCancelAll(auth, req.GetBaseUnit(), req.GetQuoteUnit(), req.GetAssigning()); err != nil {
+		return &response, err
+	}
+	response.Count = int32(len(response.GetFields()))
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetKillSwitch - This method returns whether the kill switch of the user is on and the time it expires at.
+func (a *Service) GetKillSwitch(ctx context.Context, _ *pbprovider.GetRequestKillSwitch) (*pbprovider.ResponseKillSwitch, error) {
+
+	var (
+		response pbprovider.ResponseKillSwitch
+	)
+
+	auth := a.Context.User(ctx)
+
+	expire, err := a.Context.RedisClient.Get(context.Background(), assets.KillKey(auth)).Result()
+	if err != nil && err != redis.Nil {
+		return &response, err
+	}
+	response.Status, response.ExpireAt = err == nil, expire
+
+	return &response, nil
+}
+
+// SetKillSwitch - This method turns the kill switch of the user on or off. The kill switch cancels all the orders of the user and
+// stops the trading of the account for the period in minutes, an hour by default and a week at most: the orders, their
+// amendments, the automatic rules and the copies of the lead traders are refused until the kill switch expires or is
+// turned off. The trading is stopped before the orders are cancelled, so that no order is placed in between.
+func (a *Service) SetKillSwitch(ctx context.Context, req *pbprovider.SetRequestKillSwitch) (*pbprovider.ResponseKillSwitch, error) {
+
+	var (
+		response pbprovider.ResponseKillSwitch
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if !req.GetStatus() {
+
+		if err := a.Context.RedisClient.Del(context.Background(), assets.KillKey(auth)).Err(); err != nil {
+			return &response, err
+		}
+		response.Success = true
+
+		return &response, nil
+	}
+
+	period := time.Duration(req.GetPeriod()) * time.Minute
+	if period == 0 {
+		period = time.Hour
+	}
+
+	if period < time.Minute || period > 7*24*time.Hour {
+		return &response, status.Error(11810, "the period of the kill switch must be from a minute to a week")
+	}
+
+	expire := time.Now().UTC().Add(period).Format(time.RFC3339)
+	if err := a.Context.RedisClient.Set(context.Background(), assets.KillKey(auth), expire, period).Err(); err != nil {
+		return &response, err
+	}
+
+	if response.Fields, err = a.writeCancelAll(auth, "", "", ""); err != nil {
+		return &response, err
+	}
+
+	response.Status, response.ExpireAt = true, expire
+	response.Count = int32(len(response.GetFields()))
+	response.Success = true
+
+	return &response, nil
+}
+
+// AmendOrder - This method amends the price or the quantity of the resting limit order of the user, the quantity is the new remaining
+// quantity of the order and the zero price or quantity keeps the current one. The order whose quantity is only reduced is
+// amended in place and keeps its priority in the book, the order whose price changes or whose quantity grows is replaced
+// by a new order at the end of the queue, as one step with the cancellation. The holds of the funds follow the order, and
+// the "order/amended" event is published with the id of the amended order and the order as it is after the amendment.
+func (a *Service) AmendOrder(ctx context.Context, req *pbprovider.AmendRequestOrder) (*pbprovider.ResponseOrder, error) {
+
+	var (
+		response pbprovider.ResponseOrder
+		item     types.Order
+		err      error
+	)
+
+	auth := a.Context.User(ctx)
+
+	if a.queryPaper(auth) {
+		return &response, status.Error(11803, "the simulated orders can not be amended, please cancel the order and place it again")
+	}
+
+	if req.GetPrice() < 0 || req.GetQuantity() < 0 {
+		return &response, status.Error(11805, "the price and the quantity of the amendment must not be negative")
+	}
+
+	// The order can also be passed by its external identifier, in this case it is resolved into the internal id of the order.
+	if len(req.GetUid()) > 0 {
+		if req.Id, err = a.QueryIdentifier("orders", req.GetUid()); err != nil {
+			return &response, err
+		}
+	}
+
+	if err := a.Context.Db.QueryRow(`select id, uid, value, quantity, price, assigning, trading, base_unit, quote_unit, user_id, type, status, client_id, create_at from orders where id = $1 and status in ($2, $4) and user_id = $3`, req.GetId(), types.StatusPending, auth, types.StatusQueue).Scan(&item.Id, &item.Uid, &item.Value, &item.Quantity, &item.Price, &item.Assigning, &item.Trading, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.Type, &item.Status, &item.ClientId, &item.CreateAt); err != nil {
+		return &response, status.Error(11538, "the requested order does not exist")
+	}
+
+	if item.GetTrading() != types.TradingLimit {
+		return &response, status.Error(11804, "only the limit orders can be amended")
+	}
+
+	// The amendment is trading, it is suspended together with the placing of the orders.
+	if err := a.Context.Feature(types.FeatureTrading, assets.Scope{Kind: types.ScopePair, Target: fmt.Sprintf("%v/%v", item.GetBaseUnit(), item.GetQuoteUnit())}, assets.Scope{Kind: types.ScopeCurrency, Target: item.GetBaseUnit()}, assets.Scope{Kind: types.ScopeCurrency, Target: item.GetQuoteUnit()}); err != nil {
+		return &response, err
+	}
+
+	price, quantity := req.GetPrice(), req.GetQuantity()
+	if price == 0 {
+		price = item.GetPrice()
+	}
+	if quantity == 0 {
+		quantity = item.GetValue()
+	}
+
+	if price == item.GetPrice() && quantity == item.GetValue() {
+		return &response, status.Error(11806, "the amendment changes neither the price nor the quantity of the order")
+	}
+
+	amendment := types.Amendment{
+		Id:     item.GetId(),
+		UserId: item.GetUserId(),
+	}
+
+	if price == item.GetPrice() && quantity < item.GetValue() {
+
+		if err := a.writeReduce(&item, quantity); err != nil {
+			return &response, err
+		}
+		amendment.Order = a.queryOrder(item.GetId())
+
+	} else {
+
+		replace, err := a.writeReplace(ctx, &item, price, quantity)
+		if err != nil {
+			return &response, err
+		}
+		amendment.Order, amendment.Replaced = a.queryOrder(replace.GetId()), true
+	}
+	amendment.Order.Type, amendment.Order.Trading, amendment.Order.ClientId = item.GetType(), item.GetTrading(), item.GetClientId()
+
+	if err := a.Context.Publish(&amendment, "exchange", "order/amended"); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, amendment.GetOrder())
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetRules - This method returns the automatic rules of the user.
+func (a *Service) GetRules(ctx context.Context, _ *pbprovider.GetRequestRules) (*pbprovider.ResponseRule, error) {
+
+	var (
+		response pbprovider.ResponseRule
+	)
+
+	auth := a.Context.User(ctx)
+
+	rows, err := a.Context.Db.Query("select id, user_id, kind, symbol, target, threshold, percent, status, create_at from rules where user_id = $1 order by id", auth)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Rule
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.Kind, &item.Symbol, &item.Target, &item.Threshold, &item.Percent, &item.Status, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, nil
+}
+
+// SetRule - This method creates or updates the automatic rule of the user. A sweep rule converts the balance of the symbol above
+// the threshold into the target, a deposit rule converts the percent of every following deposit of the symbol into the
+// target. The conversions are market orders placed by the background worker on behalf of the user, so there has to be a
+// pair between the symbol and the target.
+func (a *Service) SetRule(ctx context.Context, req *pbprovider.SetRequestRule) (*pbprovider.ResponseRule, error) {
+
+	var (
+		response pbprovider.ResponseRule
+		count    int
+	)
+
+	auth := a.Context.User(ctx)
+
+	item := req.GetRule()
+	if item == nil {
+		return &response, status.Error(11671, "the rule is not set")
+	}
+
+	if err := types.RuleKind(item.GetKind()); err != nil {
+		return &response, status.Errorf(11671, "the kind %v of the rule is not correct", item.GetKind())
+	}
+
+	if item.GetSymbol() == item.GetTarget() {
+		return &response, status.Error(11671, "the symbol and the target of the rule must be different")
+	}
+
+	if _, _, _, err := a.queryRoute(item.GetSymbol(), item.GetTarget()); err != nil {
+		return &response, err
+	}
+
+	switch item.GetKind() {
+	case types.RuleSweep:
+		if item.GetThreshold() < 0 {
+			return &response, status.Error(11674, "the threshold of the rule must not be negative")
+		}
+		item.Percent = 0
+	case types.RuleDeposit:
+		if item.GetPercent() <= 0 || item.GetPercent() > 100 {
+			return &response, status.Error(11674, "the percent of the rule must be greater than 0 and not greater than 100")
+		}
+		item.Threshold = 0
+	}
+
+	item.UserId = auth
+
+	if item.GetId() > 0 {
+
+		result, err := a.Context.Db.Exec("update rules set kind = $3, symbol = $4, target = $5, threshold = $6, percent = $7, status = $8 where id = $1 and user_id = $2", item.GetId(), item.GetUserId(), item.GetKind(), item.GetSymbol(), item.GetTarget(), item.GetThreshold(), item.GetPercent(), item.GetStatus())
+		if err != nil {
+			return &response, err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return &response, status.Errorf(11675, "the rule %v is not found", item.GetId())
+		}
+
+	} else {
+
+		if _ = a.Context.Db.QueryRow("select count(*) from rules where user_id = $1", auth).Scan(&count); count >= 20 {
+			return &response, status.Error(11676, "you can have at most 20 rules")
+		}
+
+		// The deposit rules apply only to the deposits credited after the rule is created, the cursor starts at the last one.
+		if err := a.Context.Db.QueryRow("insert into rules (user_id, kind, symbol, target, threshold, percent, cursor, status) values ($1, $2, $3, $4, $5, $6, (select coalesce(max(id), 0) from transactions where user_id = $1 and assignment = $7), $8) returning id, create_at", item.GetUserId(), item.GetKind(), item.GetSymbol(), item.GetTarget(), item.GetThreshold(), item.GetPercent(), types.AssignmentDeposit, item.GetStatus()).Scan(&item.Id, &item.CreateAt); err != nil {
+			return &response, err
+		}
+	}
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// DeleteRule - This method deletes the automatic rule of the user, the history of its executions is kept.
+func (a *Service) DeleteRule(ctx context.Context, req *pbprovider.DeleteRequestRule) (*pbprovider.ResponseRule, error) {
+
+	var (
+		response pbprovider.ResponseRule
+	)
+
+	auth := a.Context.User(ctx)
+
+	result, err := a.Context.Db.Exec("delete from rules where id = $1 and user_id = $2", req.GetId(), auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Errorf(11675, "the rule %v is not found", req.GetId())
+	}
+
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetRuleExecutions - This method returns the history of the executions of the rule of the user, the latest executions first.
+// Every execution refers to the market order it placed, the failed executions hold the error.
+func (a *Service) GetRuleExecutions(ctx context.Context, req *pbprovider.GetRequestRuleExecutions) (*pbprovider.ResponseRuleExecution, error) {
+
+	var (
+		response pbprovider.ResponseRuleExecution
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	_ = a.Context.Db.QueryRow("select count(*) as count from rule_executions where rule_id = $1 and user_id = $2", req.GetId(), auth).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := a.Context.Db.Query("select id, rule_id, order_id, reference, value, status, error, create_at from rule_executions where rule_id = $1 and user_id = $2 order by id desc limit $3 offset $4", req.GetId(), auth, req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.RuleExecution
+			)
+
+			if err := rows.Scan(&item.Id, &item.RuleId, &item.OrderId, &item.Reference, &item.Value, &item.Status, &item.Error, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	return &response, nil
+}
+
+// GetRamp - This method returns the ramp of the user: the currency the deposits of the stablecoins are converted into, the
+// slippage of the conversions and whether the ramp is on, together with the stablecoins the exchange converts and the
+// largest slippage allowed.
+func (a *Service) GetRamp(ctx context.Context, _ *pbprovider.GetRequestRamp) (*pbprovider.ResponseRamp, error) {
+
+	var (
+		response pbprovider.ResponseRamp
+		item     types.Ramp
+	)
+
+	if a.Context.Ramp == nil {
+		return &response, status.Error(11776, "the conversion of the deposits is not available")
+	}
+
+	auth := a.Context.User(ctx)
+
+	if err := a.Context.Db.QueryRow("select user_id, target, slippage, status, create_at from ramps where user_id = $1", auth).Scan(&item.UserId, &item.Target, &item.Slippage, &item.Status, &item.CreateAt); err != nil && err != sql.ErrNoRows {
+		return &response, err
+	}
+
+	response.Ramp = &item
+	response.Symbols, response.Slippage = a.Context.Ramp.Symbols, a.Context.Ramp.Slippage
+
+	return &response, nil
+}
+
+// SetRamp - This method opts the user in to the ramp, or out of it with the status off. The deposits of the stablecoins credited
+// from then on are converted into the target at the price of the pair, within the slippage of the user, zero is the
+// slippage of the exchange. There has to be a pair between at least one of the stablecoins and the target.
+func (a *Service) SetRamp(ctx context.Context, req *pbprovider.SetRequestRamp) (*pbprovider.ResponseRamp, error) {
+
+	var (
+		response pbprovider.ResponseRamp
+		route    bool
+	)
+
+	if a.Context.Ramp == nil {
+		return &response, status.Error(11776, "the conversion of the deposits is not available")
+	}
+
+	auth := a.Context.User(ctx)
+
+	item := req.GetRamp()
+	if item == nil {
+		return &response, status.Error(11777, "the ramp is not set")
+	}
+
+	if _, err := a.QueryAsset(item.GetTarget(), true); err != nil {
+		return &response, status.Errorf(11779, "the currency %v is not available", item.GetTarget())
+	}
+
+	if item.GetSlippage() < 0 || item.GetSlippage() > a.Context.Ramp.Slippage {
+		return &response, status.Errorf(11778, "the slippage of the conversion must not be negative and not greater than %v percent", a.Context.Ramp.Slippage)
+	}
+
+	for _, symbol := range a.Context.Ramp.Symbols {
+		if _, _, _, err := a.queryRoute(symbol, item.GetTarget()); err == nil || symbol == item.GetTarget() {
+			route = true
+		}
+	}
+
+	if !route {
+		return &response, status.Errorf(11672, "there is no pair to convert %v into %v", strings.Join(a.Context.Ramp.Symbols, ", "), item.GetTarget())
+	}
+
+	item.UserId = auth
+
+	if err := a.Context.Db.QueryRow("insert into ramps (user_id, target, slippage, status) values ($1, $2, $3, $4) on conflict (user_id) do update set target = excluded.target, slippage = excluded.slippage, status = excluded.status returning create_at", item.GetUserId(), item.GetTarget(), item.GetSlippage(), item.GetStatus()).Scan(&item.CreateAt); err != nil {
+		return &response, err
+	}
+
+	response.Ramp = item
+	response.Symbols, response.Slippage = a.Context.Ramp.Symbols, a.Context.Ramp.Slippage
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetRampConversions - This method returns the conversions of the deposits of the user by the ramp, the latest conversions first.
+// Every conversion refers to the deposit and to the market order it placed, the failed conversions hold the error.
+func (a *Service) GetRampConversions(ctx context.Context, req *pbprovider.GetRequestRampConversions) (*pbprovider.ResponseRampConversion, error) {
+
+	var (
+		response pbprovider.ResponseRampConversion
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := a.Context.User(ctx)
+
+	_ = a.Context.Db.QueryRow("select count(*) as count from ramp_conversions where user_id = $1", auth).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := a.Context.Db.Query(`select id, deposit_id, order_id, symbol, target, value, reference, "limit", filled, price, fees, status, error, create_at from ramp_conversions where user_id = $1 order by id desc limit $2 offset $3`, auth, req.GetLimit(), offset)
 		if err != nil {
 			return &response, err
 		}
 		defer rows.Close()
 
-		// The for loop is used to iterate through the rows of the result set. The rows.Next() command will return true if the
-		// iteration is successful and false if the iteration has reached the end of the result set. The loop will continue to
-		// execute until the rows.Next() returns false.
 		for rows.Next() {
 
-			// The purpose of the above code is to declare a variable called item with the type types.Order. This allows the
-			// program to create an object of type types.Order and assign it to the item variable.
 			var (
-				item types.Order
+				item types.RampConversion
 			)
 
-			// This code is scanning the rows returned from a database query and assigning the values to the variables in the item
-			// struct. If an error is encountered during the scanning process, an error is returned.
-			if err = rows.Scan(&item.Id, &item.Assigning, &item.Price, &item.Value, &item.Quantity, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.CreateAt, &item.Type, &item.Status); err != nil {
+			if err := rows.Scan(&item.Id, &item.DepositId, &item.OrderId, &item.Symbol, &item.Target, &item.Value, &item.Reference, &item.Limit, &item.Filled, &item.Price, &item.Fees, &item.Status, &item.Error, &item.CreateAt); err != nil {
 				return &response, err
 			}
 
-			// The purpose of this statement is to add an item to the existing list of fields in a response object. The
-			// response.Fields list is appended with the item, which is passed as an argument to the append function.
 			response.Fields = append(response.Fields, &item)
 		}
-
-		// This code checks for an error in the rows object. If an error is found, the function will return a response and an error message.
-		if err = rows.Err(); err != nil {
-			return &response, err
-		}
 	}
 
 	return &response, nil
 }
 
-// GetTrades - This function is a method of the Service type. It is used to get a list of transfers from a database. It takes a
-// context and a request object as parameters. The request object contains information about the requested transfers,
-// such as the limit, order ID and whether the request should only return transfers for a specific user. The function
-// then queries the database for the requested transfers, and returns a ResponseTransfer object containing the relevant transfers.
-func (a *Service) GetTrades(ctx context.Context, req *pbprovider.GetRequestTrades) (*pbprovider.ResponseTrade, error) {
+// GetPaper - This method returns the paper trading of the user: whether it is on, the paper balances with the funds the pending
+// simulated orders took, and the simulated orders, the latest orders first.
+func (a *Service) GetPaper(ctx context.Context, req *pbprovider.GetRequestPaper) (*pbprovider.ResponsePaper, error) {
 
-	// The purpose of this code is to declare two variables: a variable called "response" of type "pbprovider.ResponseTrade"
-	// and a variable called "maps" of type "string slice". This allows the program to store values in these two variables
-	// and access them throughout the code.
 	var (
-		response pbprovider.ResponseTrade
-		maps     []string
+		response pbprovider.ResponsePaper
+		paper    types.Paper
 	)
 
-	// This code checks if the request's limit is 0. If it is, it sets the request's limit to 30. This is likely done to
-	// ensure that the request is not given an unlimited amount of data, which could cause performance issues.
-	if req.GetLimit() == 0 {
-		req.Limit = 30
+	if a.Context.Paper == nil {
+		return &response, status.Error(11793, "the paper trading is not available")
 	}
 
-	// This code is used to generate a query for a database. The purpose of the switch statement is to determine the value
-	// of the assigning parameter and then generate the appropriate query based on the value. For example, if the value of
-	// req.GetAssigning() is types.AssigningBuy, then the query generated will be "where assigning =
-	// types.AssigningBuy". If the value of req.GetAssigning() is not specified, then the query generated will be "where
-	// (assigning = types.AssigningBuy or assigning = types.AssigningSell)".
-	switch req.GetAssigning() {
-	case types.AssigningBuy:
-		maps = append(maps, fmt.Sprintf("where assigning = '%v'", types.AssigningBuy))
-	case types.AssigningSell:
-		maps = append(maps, fmt.Sprintf("where assigning = '%v'", types.AssigningSell))
-	default:
-		maps = append(maps, fmt.Sprintf("where (assigning = '%v' or assigning = '%v')", types.AssigningBuy, types.AssigningSell))
+	if req.GetLimit() == 0 {
+		req.Limit = 30
 	}
 
-	// This line of code is adding a string to a slice of strings (maps) which contains a formatted variable
-	// (req.GetOrderId()). The purpose of this code is to add a condition to a SQL query which includes the value of the
-	// req.GetOrderId() variable.
-	maps = append(maps, fmt.Sprintf("and order_id = '%v'", req.GetOrderId()))
-
-	// The "if req.GetOwner()" statement is checking if a request has an owner associated with it. If it does, the code
-	// inside the if statement will execute. If not, it will skip over it.
-	if req.GetOwner() {
-
-		// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-		// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-		auth, err := a.Context.Auth(ctx)
-		if err != nil {
-			return &response, err
-		}
+	auth := a.Context.User(ctx)
 
-		// The purpose of this code is to create a map which contains a key-value pair of "user_id" and the value of the
-		// variable "auth". The variable "maps" is a slice of type string, and the function "append" is used to append the map created by the fmt.Sprintf to the slice.
-		maps = append(maps, fmt.Sprintf("and user_id = '%v'", auth))
+	if err := a.Context.Db.QueryRow("select user_id, status, reset_at, create_at from papers where user_id = $1", auth).Scan(&paper.UserId, &paper.Status, &paper.ResetAt, &paper.CreateAt); err != nil && err != sql.ErrNoRows {
+		return &response, err
 	}
+	response.Paper = &paper
 
-	// This code is used to query the table 'transfers' with the given parameters. It uses a fmt.Sprintf statement to format the
-	// query string with the given parameters, then it uses the a.Context.Db.Query() to execute the query and store the
-	// results into the rows variable. If an error occurs, it returns an error response. Finally, it closes the rows variable.
-	rows, err := a.Context.Db.Query(fmt.Sprintf("select id, user_id, base_unit, quote_unit, price, quantity, assigning, fees, maker, create_at from trades %s order by id desc limit %d", strings.Join(maps, " "), req.GetLimit()))
+	rows, err := a.Context.Db.Query(`select b.symbol, b.value, coalesce(sum(case when o.assigning = $2 then o.quantity * o.price else o.quantity end), 0) from paper_balances b left join paper_orders o on o.user_id = b.user_id and o.status = $3 and b.symbol = case when o.assigning = $2 then o.quote_unit else o.base_unit end where b.user_id = $1 group by b.symbol, b.value order by b.symbol`, auth, types.AssigningBuy, types.StatusPending)
 	if err != nil {
 		return &response, err
 	}
 	defer rows.Close()
 
-	// The for loop is used to iterate through the rows in a database. The rows.Next() statement is used to move to the next
-	// row in the result set. This loop allows you to access the data in each row and process it as needed.
 	for rows.Next() {
 
-		// The purpose of this code is to declare a variable called item, and assign it to a value of type types.Trade.
-		// This is used in programming to store data in the form of a variable and access it at a later time.
 		var (
-			item types.Trade
+			item pbprovider.Holding
 		)
 
-		// This code is part of a function that retrieves data from a database. The purpose of the if statement is to scan the
-		// rows of the database and assign each row's values to the corresponding variables. If an error occurs while scanning
-		// the rows, the function will return an error.
-		if err = rows.Scan(&item.Id, &item.UserId, &item.BaseUnit, &item.QuoteUnit, &item.Price, &item.Quantity, &item.Assigning, &item.Fees, &item.Maker, &item.CreateAt); err != nil {
+		if err := rows.Scan(&item.Symbol, &item.Available, &item.Locked); err != nil {
 			return &response, err
 		}
+		item.Type, item.Total = types.TypeSpot, decimal.New(item.GetAvailable()).Add(item.GetLocked()).Float()
 
-		// This statement is appending a new item to the Fields array of the response object. The purpose of this statement is
-		// to add a new item to the response's Fields array.
-		response.Fields = append(response.Fields, &item)
+		response.Balances = append(response.Balances, &item)
 	}
 
-	// This code is checking for an error in the rows object. If there is an error, the code will return an empty response
-	// and an error object. This is likely part of a larger function that is retrieving data from a database and returning
-	// it in a response. The if statement is making sure that the query was successful and that the response is valid.
-	if err = rows.Err(); err != nil {
-		return &response, err
+	_ = a.Context.Db.QueryRow("select count(*) as count from paper_orders where user_id = $1", auth).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := a.Context.Db.Query(`select id, user_id, base_unit, quote_unit, assigning, trading, type, price, quantity, value, fees, status, create_at from paper_orders where user_id = $1 order by id desc limit $2 offset $3`, auth, req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item = types.Order{Paper: true}
+			)
+
+			if err := rows.Scan(&item.Id, &item.UserId, &item.BaseUnit, &item.QuoteUnit, &item.Assigning, &item.Trading, &item.Type, &item.Price, &item.Quantity, &item.Value, &item.Fees, &item.Status, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
 	}
 
 	return &response, nil
 }
 
-// GetTransactions - This function is a method in a service struct used to get a list of transactions and associated data from a database.
-// The function takes a context.Context and a *pbprovider.GetRequestTransactions as parameters. The function returns a
-// response of type *pbprovider.ResponseTransaction and an error. The function sets a default value of 30 for the limit if it
-// is set to 0 and then uses a switch statement to filter out the transactions by type. The function also filters by
-// symbol and status. The function then queries for the number of transactions and their associated data and returns the results.
-func (a *Service) GetTransactions(ctx context.Context, req *pbprovider.GetRequestTransactions) (*pbprovider.ResponseTransaction, error) {
+// SetPaper - This method turns the paper trading of the user on, or off with the status off. While it is on, the orders the user
+// places and cancels are the simulated orders. The paper balances are set to the balances of the configuration when the
+// paper trading is turned on the first time, they are kept when it is turned off.
+func (a *Service) SetPaper(ctx context.Context, req *pbprovider.SetRequestPaper) (*pbprovider.ResponsePaper, error) {
 
-	// The purpose of the code snippet is to declare two variables, response and maps, of types pbprovider.ResponseTransaction and []string respectively.
 	var (
-		response pbprovider.ResponseTransaction
-		maps     []string
+		response pbprovider.ResponsePaper
+		exist    bool
 	)
 
-	// The purpose of this code is to set a default limit value if the limit value requested (req.GetLimit()) is equal to
-	// zero. In this case, the default limit value is set to 30.
-	if req.GetLimit() == 0 {
-		req.Limit = 30
+	if a.Context.Paper == nil {
+		return &response, status.Error(11793, "the paper trading is not available")
 	}
 
-	// This switch statement is used to create a query condition based on the transaction type. Depending on the transaction
-	// type, the query string will be amended to include the appropriate condition. If the transaction type is deposit, the
-	// query string will include the condition where assignment = types.AssignmentDeposit. If the transaction type is withdraws,
-	// the query string will include the condition where assignment = types.AssignmentWithdrawal. If the transaction type is
-	// neither deposit nor withdraws, the query string will include the condition where (assignment = types.AssignmentWithdrawal or assignment = types.AssignmentDeposit).
-	switch req.GetAssignment() {
-	case types.AssignmentDeposit:
-		maps = append(maps, fmt.Sprintf("where assignment = '%v'", types.AssignmentDeposit))
-	case types.AssignmentWithdrawal:
-		maps = append(maps, fmt.Sprintf("where assignment = '%v'", types.AssignmentWithdrawal))
-	default:
-		maps = append(maps, fmt.Sprintf("where (assignment = '%v' or assignment = '%v')", types.AssignmentWithdrawal, types.AssignmentDeposit))
+	auth := a.Context.User(ctx)
+
+	if _ = a.Context.Db.QueryRow("select exists(select user_id from papers where user_id = $1)", auth).Scan(&exist); !exist {
+		if err := a.writePaperReset(auth); err != nil {
+			return &response, err
+		}
 	}
 
-	// This code is checking the length of the request's symbol and, if greater than zero, appending a string to the maps
-	// variable. The string contains the symbol from the request. This allows the code to filter out requests that do not
-	// have a symbol provided and process only those that do.
-	if len(req.GetSymbol()) > 0 {
-		maps = append(maps, fmt.Sprintf("and symbol = '%v'", req.GetSymbol()))
+	paper := types.Paper{
+		UserId: auth,
+		Status: req.GetStatus(),
 	}
 
-	// This line of code is appending a string to the maps variable. The string is formatted using the fmt.Sprintf()
-	// function, and is used to set the status of the variable to a specific value (in this case, types.StatusReserve).
-	// This is likely being used to filter out specific values from a list or array of values.
-	maps = append(maps, fmt.Sprintf("and status != '%v'", types.StatusReserve))
+	if err := a.Context.Db.QueryRow("update papers set status = $2 where user_id = $1 returning reset_at, create_at", paper.GetUserId(), paper.GetStatus()).Scan(&paper.ResetAt, &paper.CreateAt); err != nil {
+		return &response, err
+	}
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := a.Context.Auth(ctx)
-	if err != nil {
+	response.Paper = &paper
+	response.Success = true
+
+	return &response, nil
+}
+
+// ResetPaper - This method starts the paper trading of the user over, the simulated orders are removed and the paper balances are
+// set to the balances of the configuration.
+func (a *Service) ResetPaper(ctx context.Context, _ *pbprovider.ResetRequestPaper) (*pbprovider.ResponsePaper, error) {
+
+	var (
+		response pbprovider.ResponsePaper
+		paper    types.Paper
+	)
+
+	if a.Context.Paper == nil {
+		return &response, status.Error(11793, "the paper trading is not available")
+	}
+
+	auth := a.Context.User(ctx)
+
+	if err := a.writePaperReset(auth); err != nil {
 		return &response, err
 	}
 
-	// This line of code is appending a string to the maps slice. The string is formatted with the auth variable. The
-	// purpose of this line of code is to add a key-value pair to the maps slice, where the key is "user_id" and the value
-	// is the auth variable.
-	maps = append(maps, fmt.Sprintf("and user_id = %v", auth))
+	if err := a.Context.Db.QueryRow("select user_id, status, reset_at, create_at from papers where user_id = $1", auth).Scan(&paper.UserId, &paper.Status, &paper.ResetAt, &paper.CreateAt); err != nil {
+		return &response, err
+	}
 
-	// The purpose of this code is to query a database and retrieve a count of the transactions that meet the criteria
-	// specified in the maps variable. The result of the query is then stored in the response.Count variable.
-	_ = a.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from transactions %s", strings.Join(maps, " "))).Scan(&response.Count)
+	response.Paper = &paper
+	response.Success = true
 
-	// The purpose of this statement is to check if the response from a particular operation contains at least one element.
-	// If the response has more than one element, the condition will evaluate to true; otherwise, it will evaluate to false.
-	if response.GetCount() > 0 {
+	return &response, nil
+}
 
-		// This code is used to calculate the offset for pagination. It takes the limit and page from the request and
-		// multiplies them together. If the page is greater than 0 (so the first page), it subtracts one from the page before
-		// multiplying. This is because the offset for the first page is 0, not the limit.
-		offset := req.GetLimit() * req.GetPage()
-		if req.GetPage() > 0 {
-			offset = req.GetLimit() * (req.GetPage() - 1)
-		}
+// SetFaucet - This method credits the test amount of the symbol to the spot balance of the user in the sandbox, at most once in
+// the interval of the faucet. The faucet is not available outside of the sandbox, the sandbox never runs on the main
+// networks of the chains.
+func (a *Service) SetFaucet(ctx context.Context, req *pbprovider.SetRequestFaucet) (*pbprovider.ResponseFaucet, error) {
 
-		// This code is used to query data from a database. The fmt.Sprintf function is used to build an SQL query string. The
-		// query string includes fields from the transactions table, a WHERE clause generated from the maps variable, a limit
-		// (req.GetLimit()), and an offset (offset). The rows, err variable is used to execute the query and return the
-		// results. To defer rows.Close() statement is used to ensure that the database connection is closed when the query is done.
-		rows, err := a.Context.Db.Query(fmt.Sprintf(`select id, symbol, hash, value, price, fees, confirmation, "to", chain_id, user_id, assignment, "group", platform, protocol, status, error, create_at from transactions %s order by id desc limit %d offset %d`, strings.Join(maps, " "), req.GetLimit(), offset))
-		if err != nil {
-			return &response, err
-		}
-		defer rows.Close()
+	var (
+		response pbprovider.ResponseFaucet
+	)
 
-		// The for loop is used to iterate through the rows returned from a database query. The rows.Next() method is used to
-		// read the next row from the query result. The for loop allows the programmer to loop through each row of the query
-		// result and perform an action on the data returned.
-		for rows.Next() {
+	if a.Context.Sandbox == nil {
+		return &response, status.Error(11798, "the faucet is available in the sandbox only")
+	}
 
-			// The purpose of this variable declaration is to declare a variable named "item" of type "types.Transaction". This
-			// variable can then be used to store and manipulate data of type "types.Transaction".
-			var (
-				item types.Transaction
-			)
+	auth := a.Context.User(ctx)
 
-			// This code is used to scan the rows of a database table and assign the values to the corresponding fields of the
-			// item struct. The if statement is used to check for any errors that occur while scanning the rows. If an error is
-			// encountered, the error is passed to the Error function and the response is returned.
-			if err = rows.Scan(
-				&item.Id,
-				&item.Symbol,
-				&item.Hash,
-				&item.Value,
-				&item.Price,
-				&item.Fees,
-				&item.Confirmation,
-				&item.To,
-				&item.ChainId,
-				&item.UserId,
-				&item.Assignment,
-				&item.Group,
-				&item.Platform,
-				&item.Protocol,
-				&item.Status,
-				&item.Error,
-				&item.CreateAt,
-			); err != nil {
-				return &response, err
-			}
+	value, ok := a.Context.Sandbox.Faucet[req.GetSymbol()]
+	if !ok {
+		return &response, status.Errorf(11799, "the faucet does not give %v", req.GetSymbol())
+	}
 
-			// This code is retrieving the chain from the given chain ID and storing it in the item.Chain variable. It then checks
-			// if an error occurred while doing so and if there was an error, it will return a nil value and the error.
-			item.Chain, err = a.QueryChain(item.GetChainId(), false)
-			if err != nil {
-				return nil, err
-			}
+	if _, err := a.QueryAsset(req.GetSymbol(), true); err != nil {
+		return &response, status.Errorf(11779, "the currency %v is not available", req.GetSymbol())
+	}
 
-			// This code checks the protocol associated with the item. If the protocol is not equal to the mainnet protocol, then
-			// the fees associated with the item are multiplied by the item's price, and the result is stored as a float.
-			if item.GetProtocol() != types.ProtocolMainnet {
-				item.Fees = decimal.New(item.GetFees()).Mul(item.GetPrice()).Float()
-			}
+	// The interval of the faucet is counted by the key of the user and the symbol, the interval of zero takes a day.
+	interval := time.Duration(a.Context.Sandbox.Interval) * time.Hour
+	if interval == 0 {
+		interval = 24 * time.Hour
+	}
 
-			// This statement is appending an item to the Fields slice of the response variable. This adds the item to the end of
-			// the slice, increasing its length by one. The purpose of this statement is to add an item to the Fields slice.
-			response.Fields = append(response.Fields, &item)
-		}
+	key := fmt.Sprintf("faucet:%v:%v", auth, req.GetSymbol())
+	if ok, err := a.Context.RedisClient.SetNX(context.Background(), key, time.Now().UTC().Format(time.RFC3339), interval).Result(); err != nil {
+		return &response, err
+	} else if !ok {
+		ttl, _ := a.Context.RedisClient.TTL(context.Background(), key).Result()
+		return &response, status.Errorf(11800, "the faucet has already given you %v, it gives it again in %v", req.GetSymbol(), ttl.Round(time.Minute))
+	}
 
-		// This is a check to make sure that the operation on the rows was successful. If it was not successful, it returns an error.
-		if err = rows.Err(); err != nil {
-			return &response, err
-		}
+	if err := a.WriteAsset(req.GetSymbol(), types.TypeSpot, auth); err != nil {
+		return &response, err
 	}
 
+	if err := a.WriteBalance(req.GetSymbol(), types.TypeSpot, auth, decimal.New(value), types.BalancePlus); err != nil {
+		a.Context.RedisClient.Del(context.Background(), key)
+		return &response, err
+	}
+
+	response.Symbol, response.Value = req.GetSymbol(), value
+	response.Success = true
+
 	return &response, nil
 }
 
-// CancelOrder - This function is used to cancel an order in a spot trading system. It takes in a context and a request object, and
-// returns a response object and an error. It checks the status of the order, updates the order status to "CANCEL",
-// updates the balance, and publishes a message.
-func (a *Service) CancelOrder(ctx context.Context, req *pbprovider.CancelRequestOrder) (*pbprovider.ResponseOrder, error) {
+// GetWallet - This function returns all the holdings of the user across the spot and the stock sub-wallets in one shape: the
+// available balance, the funds locked by the open orders and the pending withdrawals, and the value of the holding in the
+// valuation unit (usd by default), together with the total value of the wallet.
+func (a *Service) GetWallet(ctx context.Context, req *pbprovider.GetRequestWallet) (*pbprovider.ResponseWallet, error) {
 
-	// The purpose of this code is to declare a variable named response of type pbprovider.ResponseOrder. This is a type used to
-	// store information about a response to an order, such as the status, order ID, and other details.
 	var (
-		response pbprovider.ResponseOrder
+		response pbprovider.ResponseWallet
+		prices   = make(map[string]float64)
+		err      error
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := a.Context.Auth(ctx)
+	if len(req.GetUnit()) == 0 {
+		req.Unit = "usd"
+	}
+	response.Unit = req.GetUnit()
+
+	if len(req.GetType()) > 0 {
+		if req.Type, err = types.Normalize(types.EnumType, req.GetType()); err != nil {
+			return &response, err
+		}
+	}
+
+	auth := a.Context.User(ctx)
+
+	locked, err := a.queryLocked(auth)
 	if err != nil {
 		return &response, err
 	}
 
-	// This query is used to fetch data from the orders table in the database. The query is parameterized to ensure that
-	// only the desired records are returned. The parameters are the status, id, and user_id. The query also includes an
-	// order by clause to ensure that the data is returned in a specific order. The data is then stored in the row variable
-	// and the defer statement is used to close the row when the query is finished.
-	row, err := a.Context.Db.Query(`select id, value, quantity, price, assigning, base_unit, quote_unit, user_id, type, create_at from orders where id = $1 and status = $2 and user_id = $3 order by id`, req.GetId(), types.StatusPending, auth)
+	rows, err := a.Context.Db.Query("select symbol, type, value from balances where user_id = $1 and ($2 = '' or type = $2) order by type, symbol", auth, req.GetType())
 	if err != nil {
 		return &response, err
 	}
-	defer row.Close()
+	defer rows.Close()
 
-	// The purpose of the following code is to check if there is a row available for retrieving data from. The `row.Next()`
-	// method returns a boolean value indicating whether there is a row available. If the result is true, it means that a
-	// row is available and can be used to retrieve data.
-	if row.Next() {
+	for rows.Next() {
 
-		// The purpose of the 'var' statement is to declare a new variable, in this case "item", which is of type
-		// "types.Order". This allows the program to use the variable "item" to store values of type "types.Order", such as
-		// orders placed on an online store.
 		var (
-			item types.Order
+			item pbprovider.Holding
 		)
 
-		// This code is used to scan the row of a database table and assign the values to the relevant variables. The if
-		// statement checks for any errors that may occur during the scanning process, and if an error is found, it will return an error response.
-		if err = row.Scan(&item.Id, &item.Value, &item.Quantity, &item.Price, &item.Assigning, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.Type, &item.CreateAt); err != nil {
+		if err := rows.Scan(&item.Symbol, &item.Type, &item.Available); err != nil {
 			return &response, err
 		}
+		item.Locked = locked[item.GetType()][item.GetSymbol()]
 
-		// The purpose of the code is to update the status of an order for a particular user in the database. It takes three
-		// parameters: the ID of the order, the user ID, and the new status for the order. If the execution of the SQL query
-		// fails, an error is returned.
-		if _, err := a.Context.Db.Exec("update orders set status = $3 where id = $1 and user_id = $2;", item.GetId(), item.GetUserId(), types.StatusCancel); err != nil {
-			return &response, err
+		// The empty balances are left out, they are created for every asset the user has ever traded.
+		if item.GetAvailable() == 0 && item.GetLocked() == 0 {
+			continue
 		}
+		item.Total, item.Available = item.GetAvailable(), decimal.New(item.GetAvailable()).Sub(item.GetLocked()).Float()
 
-		// The switch statement is used to compare the value of a variable (in this case, item.Assigning) to a list of possible
-		// values. If the value matches one of the values in the list, a specific action will be executed.
-		switch item.Assigning {
-		case types.AssigningBuy:
+		// This code requests the price of the symbol if it has not been requested yet.
+		if _, ok := prices[item.GetSymbol()]; !ok {
+			prices[item.GetSymbol()] = a.QueryValuation(item.GetSymbol(), req.GetUnit())
+		}
+		item.Price = prices[item.GetSymbol()]
+		item.Value = decimal.New(item.GetTotal()).Mul(item.GetPrice()).Float()
 
-			// This code is setting the balance of a user for a given item. It is using the item's quote unit, user id, value and
-			// price to calculate the new balance and then updating the balance using the types.Balance_PLUS parameter. If there
-			// is an error setting the balance, an error is returned.
-			if err := a.WriteBalance(item.GetQuoteUnit(), item.GetType(), item.GetUserId(), decimal.New(item.GetValue()).Mul(item.GetPrice()).Float(), types.BalancePlus); err != nil {
-				return &response, err
-			}
+		response.Value = decimal.New(response.GetValue()).Add(item.GetValue()).Float()
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, rows.Err()
+}
 
-			break
-		case types.AssigningSell:
+// SetWalletTransfer - This function moves the funds of the user between the spot and the stock sub-wallets. Only the currencies
+// the stocks are traded for can be moved, the shares themselves stay in the stock sub-wallet. The balances are changed in
+// one database transaction and the transfer is recorded.
+func (a *Service) SetWalletTransfer(ctx context.Context, req *pbprovider.SetRequestWalletTransfer) (*pbprovider.ResponseWalletTransfer, error) {
 
-			// This code is used to set a balance for a user in a particular base unit. The "if err" statement is used to check if
-			// there is an error when setting the balance. If there is an error, the code will return an error message.
-			if err := a.WriteBalance(item.GetBaseUnit(), item.GetType(), item.GetUserId(), item.GetValue(), types.BalancePlus); err != nil {
-				return &response, err
-			}
+	var (
+		response pbprovider.ResponseWalletTransfer
+		count    int
+	)
 
-			break
-		}
+	auth := a.Context.User(ctx)
 
-		// This code is intended to publish an item to an exchange with the routing key "order/cancel". If any errors occur
-		// while attempting to publish the item, the error is returned and the response is returned.
-		if err := a.Context.Publish(&item, "exchange", "order/cancel"); err != nil {
-			return &response, err
-		}
+	_account := account.Service{
+		Context: a.Context,
+	}
 
-	} else {
-		return &response, status.Error(11538, "the requested order does not exist")
+	user, err := _account.QueryUser(auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if !user.GetStatus() {
+		return &response, status.Error(748990, "your account and assets have been blocked, please contact technical support for any questions")
+	}
+
+	if !help.IndexOf([]string{types.TypeSpot, types.TypeStock}, req.GetSource()) || !help.IndexOf([]string{types.TypeSpot, types.TypeStock}, req.GetTarget()) || req.GetSource() == req.GetTarget() {
+		return &response, status.Error(11708, "the funds are moved between the spot and the stock sub-wallets")
+	}
+
+	if req.GetValue() <= 0 {
+		return &response, status.Error(11709, "the value of the transfer must be greater than zero")
+	}
+
+	if _ = a.Context.Db.QueryRow("select count(*) from pairs where quote_unit = $1 and type = $2", req.GetSymbol(), types.TypeStock).Scan(&count); count == 0 {
+		return &response, status.Errorf(11710, "the %v is not a currency the stocks are traded for", req.GetSymbol())
+	}
+
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return &response, err
+	}
+	defer tx.Rollback()
+
+	// The balance is debited only when its available part covers the value of the transfer, so that the concurrent transfers
+	// do not take it below zero and the funds held by the orders and withdrawals stay on it.
+	if result, err := tx.Exec("update balances b set value = b.value - $1 where b.symbol = $2 and b.user_id = $3 and b.type = $4 and b.value - coalesce((select sum(h.value) from holds h where h.symbol = b.symbol and h.user_id = b.user_id and h.type = b.type), 0) >= $1", req.GetValue(), req.GetSymbol(), auth, req.GetSource()); err != nil {
+		return &response, err
+	} else if affected, _ := result.RowsAffected(); affected == 0 {
+		return &response, status.Error(11711, "there are not enough funds on the balance of the sub-wallet")
+	}
+
+	// The user who has never held the currency in the target sub-wallet has no balance of it yet.
+	if _, err := tx.Exec("insert into balances (user_id, symbol, type) select $1, $2, $3 where not exists (select id from balances where user_id = $1 and symbol = $2 and type = $3)", auth, req.GetSymbol(), req.GetTarget()); err != nil {
+		return &response, err
+	}
+
+	if _, err := tx.Exec("update balances set value = value + $1 where symbol = $2 and user_id = $3 and type = $4", req.GetValue(), req.GetSymbol(), auth, req.GetTarget()); err != nil {
+		return &response, err
+	}
+
+	if _, err := tx.Exec("insert into wallet_transfers (user_id, symbol, source, target, value) values ($1, $2, $3, $4, $5)", auth, req.GetSymbol(), req.GetSource(), req.GetTarget(), req.GetValue()); err != nil {
+		return &response, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &response, err
 	}
 	response.Success = true
 
@@ -2,7 +2,11 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/cryptogateway/backend-envoys/assets/common/matcher"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/types"
@@ -10,7 +14,7 @@ import (
 )
 
 // trade - This function is used to replay a trade init. It takes an order and a side (BID or ASK) as parameters. It then queries
-// the database for orders with the same base unit, quote unit and user ID, and with a status of "PENDING". It then
+// the database for orders with the same base unit, quote unit and user ID, and with a status of "pending". It then
 // iterates through the results and checks if the order's price is higher than the item's price for a BID position and
 // lower for an ASK position. If this is the case, it calls the replayTradeProcess() function. Finally, it logs any matches or failed matches.
 func (a *Service) trade(order *types.Order, assigning string) {
@@ -21,6 +25,22 @@ func (a *Service) trade(order *types.Order, assigning string) {
 		return
 	}
 
+	// In the shadow mode the book and the fills of the live engine are recorded while the order is matched, and compared
+	// with the fills of the candidate engine when the matching is over. The margin orders are not matched by the live
+	// engine yet, so they are left out of the comparison.
+	var (
+		book   []matcher.Order
+		live   []matcher.Fill
+		canary = a.Context.Canary && order.GetType() != types.TypeCross
+	)
+
+	if canary {
+		value := order.GetValue()
+		defer func() {
+			a.writeCanary(order, value, book, live)
+		}()
+	}
+
 	// This code is querying the "orders" table in a database for data that matches the given parameters. It is using the
 	// parameters given to query for a specific set of data from the "orders" table. It is using the $1, $2, $3, $4, $5 and
 	// $6 to represent the given parameters. The query is also ordering the results by the "id" column. It is checking for
@@ -47,6 +67,10 @@ func (a *Service) trade(order *types.Order, assigning string) {
 			return
 		}
 
+		if canary {
+			book = append(book, matcher.Order{Id: item.GetId(), Price: item.GetPrice(), Value: item.GetValue()})
+		}
+
 		// Check if the order is in a pending status and update the order value accordingly.
 		if row := a.queryOrder(order.GetId()); row.GetStatus() == types.StatusPending {
 			order.Value = row.GetValue()
@@ -110,6 +134,20 @@ func (a *Service) trade(order *types.Order, assigning string) {
 				return
 			}
 		}
+
+		// The fill of the live engine is the value the resting order has lost while it was matched, it is executed at the
+		// price of the incoming buy order or at the price of the resting buy order.
+		if canary {
+			if value := decimal.New(item.GetValue()).Sub(a.queryOrder(item.GetId()).GetValue()).Float(); value > 0 {
+
+				price := order.GetPrice()
+				if assigning == types.AssigningBuy {
+					price = item.GetPrice()
+				}
+
+				live = append(live, matcher.Fill{Id: item.GetId(), Price: price, Value: value})
+			}
+		}
 	}
 
 	// The purpose of this code is to check for errors when using the rows.Err() function. It returns an error if there is
@@ -119,6 +157,44 @@ func (a *Service) trade(order *types.Order, assigning string) {
 	}
 }
 
+// writeCanary - This function matches the incoming order by the candidate engine against the book the live engine has seen, and
+// compares the fills of both engines. The divergences are logged and written to the database, so that the operators can
+// review them before the candidate engine replaces the live one. Nothing is written when both engines agree.
+func (a *Service) writeCanary(order *types.Order, value float64, book []matcher.Order, live []matcher.Fill) {
+
+	shadow := matcher.Match(order.GetAssigning() == types.AssigningBuy, order.GetPrice(), value, book)
+
+	divergences := matcher.Diff(live, shadow)
+	if len(divergences) == 0 {
+		return
+	}
+
+	reason := strings.Join(divergences, "; ")
+	a.Context.Logger.Warnf("[CANARY]: order ID: %v, %v", order.GetId(), reason)
+
+	// The empty lists are written as json arrays rather than null.
+	if live == nil {
+		live = []matcher.Fill{}
+	}
+	if shadow == nil {
+		shadow = []matcher.Fill{}
+	}
+
+	l, err := json.Marshal(live)
+	if a.Context.Debug(err) {
+		return
+	}
+
+	s, err := json.Marshal(shadow)
+	if a.Context.Debug(err) {
+		return
+	}
+
+	if _, err := a.Context.Db.Exec("insert into divergences (order_id, assigning, base_unit, quote_unit, type, price, value, live, shadow, reason) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", order.GetId(), order.GetAssigning(), order.GetBaseUnit(), order.GetQuoteUnit(), order.GetType(), order.GetPrice(), value, l, s, reason); a.Context.Debug(err) {
+		return
+	}
+}
+
 // defaultProcess - This function is used to replay a trade process. It updates two orders with different amounts to determine the result
 // of a trade. It updates the order status in the database with pending in to filled, updates the balance by adding the
 // amount of the order to the balance, and sends a mail. In addition, it logs information about the trade.
@@ -172,6 +248,11 @@ func (a *Service) defaultProcess(assigning string, params ...*types.Order) {
 				return
 			}
 
+			// The filled value is spent from the balance and released from the hold of the order.
+			if err := a.writeSettle(params[i], params[instance].GetValue(), price, value == 0); a.Context.Debug(err) {
+				return
+			}
+
 			if value == 0 {
 
 				// This code is performing an update on the orders table in a database. It is setting the status of the order with the
@@ -185,6 +266,9 @@ func (a *Service) defaultProcess(assigning string, params ...*types.Order) {
 			}
 		}
 
+		// The match is journaled with the incoming order, the resting order, the price and the value it was executed with.
+		a.Context.Debug(a.writeJournal(types.JournalMatch, params[0], params[1].GetId(), price, params[instance].GetValue()))
+
 		switch params[1].GetAssigning() {
 		case types.AssigningBuy:
 
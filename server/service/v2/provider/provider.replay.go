@@ -2,11 +2,22 @@ package provider
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
 	"github.com/cryptogateway/backend-envoys/assets/common/help"
 	"github.com/cryptogateway/backend-envoys/assets/common/marketplace"
+	"github.com/cryptogateway/backend-envoys/assets/common/matcher"
+	"github.com/cryptogateway/backend-envoys/assets/common/surveillance"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 	"github.com/svarlamov/goyhfin"
+	"math"
+	"sort"
 	"strings"
 	"time"
 )
@@ -230,5 +241,1189 @@ func (a *Service) price() {
 				}
 			}
 		}()
+
+		// The summary of the markets is refreshed with the new prices, so that the clients get the markets from the cache.
+		a.writeMarkets()
+	}
+}
+
+// snapshot - This function records the total value of every user account at a specific time interval, so that the history of the
+// account value can be charted. Hourly snapshots are written every hour and kept for thirty days, daily snapshots are
+// written once a day at midnight and kept forever.
+func (a *Service) snapshot() {
+
+	// The code creates a ticker that triggers every hour and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Hour * 1)
+	for range ticker.C {
+
+		// The purpose of this code is to write the hourly snapshot on every tick, and the daily snapshot on the first tick
+		// after midnight.
+		resolutions := []string{types.SnapshotHour}
+		if time.Now().Hour() == 0 {
+			resolutions = append(resolutions, types.SnapshotDay)
+		}
+
+		func() {
+
+			// The purpose of this code is to declare the variables used to aggregate the account value of every user, and to
+			// cache the price of every symbol, so that each price is requested only once per snapshot.
+			var (
+				values = make(map[int64]float64)
+				prices = make(map[string]float64)
+				unit   = "usd"
+			)
+
+			// This code queries all the non-empty balances, together with the balances of the external addresses watched by the
+			// users, the value of each balance is converted into the valuation unit and summed up per user.
+			rows, err := a.Context.Db.Query(`select user_id, symbol, value from balances where value > 0 union all select user_id, symbol, balance from watches where balance > 0`)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					userId int64
+					symbol string
+					value  float64
+				)
+
+				if err := rows.Scan(&userId, &symbol, &value); a.Context.Debug(err) {
+					continue
+				}
+
+				// This code requests the price of the symbol if it has not been requested yet during this snapshot.
+				if _, ok := prices[symbol]; !ok {
+					prices[symbol] = a.QueryValuation(symbol, unit)
+				}
+
+				values[userId] = decimal.New(values[userId]).Add(decimal.New(value).Mul(prices[symbol]).Float()).Float()
+			}
+
+			// The purpose of this code is to insert the aggregated account values into the snapshots table, one row per user
+			// and resolution.
+			for userId, value := range values {
+				for _, resolution := range resolutions {
+					if _, err := a.Context.Db.Exec(`insert into snapshots (user_id, value, unit, resolution) values ($1, $2, $3, $4)`, userId, value, unit, resolution); a.Context.Debug(err) {
+						continue
+					}
+				}
+			}
+
+			// This code removes the hourly snapshots older than thirty days, the daily snapshots are enough for the long history.
+			if _, err := a.Context.Db.Exec(`delete from snapshots where resolution = $1 and create_at < now()::timestamp - '30 days'::interval`, types.SnapshotHour); a.Context.Debug(err) {
+				return
+			}
+		}()
+	}
+}
+
+// backfill - This function repairs the candles of all active pairs at a specific time interval. Every hour it looks for the
+// minute periods of the last day in which trades were executed but no ticks were written, and rebuilds them from the
+// trades table, so that the gaps caused by restarts of the service are closed automatically.
+func (a *Service) backfill() {
+
+	// The code creates a ticker that triggers every hour and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Hour * 1)
+	for range ticker.C {
+
+		func() {
+
+			// This code queries the base and quote units of all active pairs, the gaps are detected and repaired for each of them.
+			rows, err := a.Context.Db.Query(`select base_unit, quote_unit from pairs where status = $1 order by id`, true)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.Pair
+				)
+
+				if err := rows.Scan(&item.BaseUnit, &item.QuoteUnit); a.Context.Debug(err) {
+					continue
+				}
+
+				// The repair covers the last day with the minute resolution, the smallest one the candles are built with.
+				if _, err := a.WriteBackfill(item.GetBaseUnit(), item.GetQuoteUnit(), "60", time.Now().Add(-24*time.Hour).Unix(), time.Now().Unix()); a.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+	}
+}
+
+// support - This function tracks the support alerts of the priority accounts at a specific time interval. It opens the alerts for
+// the deposits that are pending for longer than expected, closes the alerts of the deposits that have been processed in the
+// meantime, and escalates the alerts that have not been resolved within the service level agreement.
+func (a *Service) support() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		func() {
+
+			// The purpose of this code is to bring the stuck period into a sensible value, an hour is used if it is not set.
+			stuck := a.Context.SupportStuck
+			if stuck <= 0 {
+				stuck = 60
+			}
+
+			// This code queries the deposits of the priority accounts that are still pending after the stuck period.
+			rows, err := a.Context.Db.Query(`select t.id, t.user_id from transactions t inner join accounts a on a.id = t.user_id where a.priority = $1 and t.status = $2 and t.assignment = $3 and t.create_at < now() - make_interval(mins => $4)`, true, types.StatusPending, types.AssignmentDeposit, stuck)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.Transaction
+				)
+
+				if err := rows.Scan(&item.Id, &item.UserId); a.Context.Debug(err) {
+					continue
+				}
+
+				// The alert is opened only once for the same deposit, the repeated calls are skipped by the provider.
+				if err := a.WriteSupport(item.GetUserId(), types.SupportDeposit, item.GetId()); a.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+
+		// This code resolves the alerts of the deposits that are no longer pending, they do not require the attention of the operators anymore.
+		if _, err := a.Context.Db.Exec(`update support set status = $1, resolve_at = now() where kind = $2 and status = $3 and reference in (select id from transactions where status <> $3)`, types.StatusFilled, types.SupportDeposit, types.StatusPending); a.Context.Debug(err) {
+			continue
+		}
+
+		func() {
+
+			// This code escalates the alerts whose deadline has passed, the level is raised and a new deadline is set by the service level agreement.
+			rows, err := a.Context.Db.Query(`update support set level = level + 1, deadline = now() + make_interval(mins => $1) where status = $2 and deadline < now() returning id, user_id, kind, reference, status, level, deadline, create_at`, a.querySla(), types.StatusPending)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.Support
+				)
+
+				if err := rows.Scan(&item.Id, &item.UserId, &item.Kind, &item.Reference, &item.Status, &item.Level, &item.Deadline, &item.CreateAt); a.Context.Debug(err) {
+					continue
+				}
+				item.Breached = true
+
+				// The escalated alert is published to the escalation channel of the operators.
+				if err := a.Context.Publish(&item, "exchange", "support/escalation"); a.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+	}
+}
+
+// report - This function generates the end-of-day transaction reports of the configured jurisdictions at a specific time interval.
+// The report of the previous day is generated once for each jurisdiction, and the reports whose delivery has failed are
+// delivered again until the sftp server of the regulator accepts them.
+func (a *Service) report() {
+
+	// The code creates a ticker that triggers every hour and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Hour * 1)
+	for range ticker.C {
+
+		// The day of the report is the previous day, its trades are complete after midnight.
+		day := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+
+		for _, schema := range a.Context.Reports {
+
+			var (
+				exist bool
+			)
+
+			// This code checks whether the report of the day has already been generated, the corrections are made only on request.
+			if err := a.Context.Db.QueryRow("select exists(select id from reports where jurisdiction = $1 and day = $2)", schema.Jurisdiction, day).Scan(&exist); a.Context.Debug(err) || exist {
+				continue
+			}
+
+			if _, err := a.WriteReport(schema.Jurisdiction, day); a.Context.Debug(err) {
+				continue
+			}
+		}
+
+		func() {
+
+			// This code queries the reports whose delivery has failed, the stored file is delivered again as it is.
+			rows, err := a.Context.Db.Query(`select id, jurisdiction, name, data from reports where status = $1 order by id`, types.StatusFailed)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.Report
+					data []byte
+				)
+
+				if err := rows.Scan(&item.Id, &item.Jurisdiction, &item.Name, &data); a.Context.Debug(err) {
+					continue
+				}
+
+				// The report of a jurisdiction that has been removed from the configuration stays failed.
+				schema, err := a.queryReport(item.GetJurisdiction())
+				if err != nil {
+					continue
+				}
+
+				if _, err := a.writeDelivery(schema, item.GetId(), item.GetName(), data); a.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+	}
+}
+
+// breaker - This function is the circuit breaker of the pairs, it runs at a specific time interval. The trading of a pair is
+// halted when its price moves by more than the halt percent within the halt window, and it is resumed automatically after
+// the cool-down. The pairs halted by the operators without a deadline are resumed only by the operators.
+func (a *Service) breaker() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		func() {
+
+			// This code resumes the pairs whose cool-down has passed and notifies the clients about it.
+			rows, err := a.Context.Db.Query(`update pairs set halted = false, halt_until = null where halted = true and halt_until < now() returning id, base_unit, quote_unit, type`)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.Pair
+				)
+
+				if err := rows.Scan(&item.Id, &item.BaseUnit, &item.QuoteUnit, &item.Type); a.Context.Debug(err) {
+					continue
+				}
+
+				if err := a.Context.Publish(&item, "exchange", "trade/resume"); a.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+
+		func() {
+
+			// This code queries the active pairs that have the circuit breaker configured and are not halted yet.
+			rows, err := a.Context.Db.Query(`select id, base_unit, quote_unit, type, halt_percent, halt_window, cooldown from pairs where status = $1 and halted = false and halt_percent > 0 and halt_window > 0`, true)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item     types.Pair
+					high     float64
+					low      float64
+					duration int32
+				)
+
+				if err := rows.Scan(&item.Id, &item.BaseUnit, &item.QuoteUnit, &item.Type, &item.HaltPercent, &item.HaltWindow, &item.Cooldown); a.Context.Debug(err) {
+					continue
+				}
+
+				// The move of the price is the range between the highest and the lowest trade price within the window, so that
+				// both a rally and a crash trigger the breaker.
+				if err := a.Context.Db.QueryRow(`select coalesce(max(price), 0), coalesce(min(price), 0) from trades where base_unit = $1 and quote_unit = $2 and assigning <> $3 and create_at > now() - make_interval(mins => $4)`, item.GetBaseUnit(), item.GetQuoteUnit(), types.AssigningSupply, item.GetHaltWindow()).Scan(&high, &low); a.Context.Debug(err) {
+					continue
+				}
+
+				if low <= 0 || decimal.New(high).Sub(low).Div(low).Mul(100).Float() < item.GetHaltPercent() {
+					continue
+				}
+
+				// The cool-down defaults to a quarter of an hour, if it is not configured for the pair.
+				if duration = item.GetCooldown(); duration <= 0 {
+					duration = 15
+				}
+
+				if err := a.Context.Db.QueryRow(`update pairs set halted = true, halt_until = now() + make_interval(mins => $1) where id = $2 returning halted, halt_until`, duration, item.GetId()).Scan(&item.Halted, &item.HaltUntil); a.Context.Debug(err) {
+					continue
+				}
+
+				if err := a.Context.Publish(&item, "exchange", "trade/halt"); a.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+	}
+}
+
+// delisting - This function moves the delistings through their stages: at the scheduled time the trading of the asset is
+// suspended and its pending orders are cancelled, and at the end of the grace period for the withdrawals its residual
+// balances are converted into the quote asset. It is repeated every minute.
+func (a *Service) delisting() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		func() {
+
+			var (
+				delistings []*types.Delisting
+			)
+
+			rows, err := a.Context.Db.Query(`select id, symbol, quote_unit, price, status from delistings where (status = $1 and cancel_at <= now()) or (status = $2 and withdraw_until <= now()) order by id`, types.DelistingScheduled, types.DelistingSuspended)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.Delisting
+				)
+
+				if err := rows.Scan(&item.Id, &item.Symbol, &item.QuoteUnit, &item.Price, &item.Status); a.Context.Debug(err) {
+					return
+				}
+
+				delistings = append(delistings, &item)
+			}
+
+			for _, item := range delistings {
+
+				switch item.GetStatus() {
+				case types.DelistingScheduled:
+					if a.Context.Debug(a.writeSuspend(item)) {
+						continue
+					}
+					item.Status = types.DelistingSuspended
+				case types.DelistingSuspended:
+					if a.Context.Debug(a.writeConvert(item)) {
+						continue
+					}
+					item.Status = types.DelistingConverted
+				}
+
+				// The clients are notified about every stage of the delisting.
+				a.Context.Debug(a.Context.Publish(item, "exchange", "asset/delisting"))
+			}
+		}()
+	}
+}
+
+// paper - This function fills the pending simulated limit orders every ten seconds, the orders are filled at their own price once
+// the price of the pair crosses it: the buy orders when the price falls to their price, the sell orders when it rises.
+func (a *Service) paper() {
+
+	ticker := time.NewTicker(time.Second * 10)
+	for range ticker.C {
+
+		if a.Context.Paper == nil {
+			continue
+		}
+
+		func() {
+
+			var (
+				orders []*types.Order
+			)
+
+			rows, err := a.Context.Db.Query(`select o.id, o.user_id, o.base_unit, o.quote_unit, o.assigning, o.trading, o.type, o.price, o.quantity, o.value, o.create_at from paper_orders o inner join pairs p on p.base_unit = o.base_unit and p.quote_unit = o.quote_unit where o.status = $1 and ((o.assigning = $2 and p.price <= o.price) or (o.assigning = $3 and p.price >= o.price)) order by o.id`, types.StatusPending, types.AssigningBuy, types.AssigningSell)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item = types.Order{Paper: true}
+				)
+
+				if err := rows.Scan(&item.Id, &item.UserId, &item.BaseUnit, &item.QuoteUnit, &item.Assigning, &item.Trading, &item.Type, &item.Price, &item.Quantity, &item.Value, &item.CreateAt); a.Context.Debug(err) {
+					return
+				}
+
+				orders = append(orders, &item)
+			}
+
+			for _, item := range orders {
+				a.Context.Debug(a.writePaperFill(item, item.GetPrice()))
+			}
+		}()
+	}
+}
+
+// rule - This function evaluates the automatic rules of the users every minute. A sweep rule converts the part of the balance
+// of the symbol above the threshold of the rule into the target, so that the profits accumulated on top of the kept amount
+// are moved into the target, e.g. a stablecoin. A deposit rule converts the percent of every deposit of the symbol
+// credited after the rule was created into the target.
+func (a *Service) rule() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		func() {
+
+			rows, err := a.Context.Db.Query("select id, user_id, kind, symbol, target, threshold, percent, cursor from rules where status = $1 order by id", true)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item   types.Rule
+					cursor int64
+				)
+
+				if err := rows.Scan(&item.Id, &item.UserId, &item.Kind, &item.Symbol, &item.Target, &item.Threshold, &item.Percent, &cursor); a.Context.Debug(err) {
+					continue
+				}
+
+				switch item.GetKind() {
+				case types.RuleSweep:
+
+					value := decimal.New(a.QueryBalance(item.GetSymbol(), types.TypeSpot, item.GetUserId())).Sub(item.GetThreshold()).Float()
+					if value <= 0 {
+						continue
+					}
+
+					// A sweep that keeps failing, e.g. because the excess is below the minimum notional of the pair, is retried once an
+					// hour and not every minute, so that the history of the rule is not flooded with the same error.
+					var (
+						failed bool
+					)
+
+					_ = a.Context.Db.QueryRow("select status = $2 and create_at > now() - interval '1 hour' from rule_executions where rule_id = $1 order by id desc limit 1", item.GetId(), types.StatusFailed).Scan(&failed)
+
+					if failed {
+						continue
+					}
+
+					if _, err := a.writeRule(&item, value, 0); a.Context.Debug(err) {
+						continue
+					}
+
+				case types.RuleDeposit:
+
+					deposits, err := a.Context.Db.Query("select id, value from transactions where user_id = $1 and symbol = $2 and assignment = $3 and status = $4 and id > $5 order by id", item.GetUserId(), item.GetSymbol(), types.AssignmentDeposit, types.StatusFilled, cursor)
+					if a.Context.Debug(err) {
+						continue
+					}
+
+					for deposits.Next() {
+
+						var (
+							deposit types.Transaction
+						)
+
+						if err := deposits.Scan(&deposit.Id, &deposit.Value); a.Context.Debug(err) {
+							break
+						}
+
+						// The cursor is moved before the execution, so that a deposit is never converted twice, a failed conversion is
+						// left in the history of the rule.
+						if _, err := a.Context.Db.Exec("update rules set cursor = $2 where id = $1", item.GetId(), deposit.GetId()); a.Context.Debug(err) {
+							break
+						}
+
+						if _, err := a.writeRule(&item, decimal.New(deposit.GetValue()).Mul(item.GetPercent()).Div(100).Float(), deposit.GetId()); a.Context.Debug(err) {
+							continue
+						}
+					}
+					deposits.Close()
+				}
+			}
+		}()
+	}
+}
+
+// session - This function releases the orders queued outside of the trading hours into the book, once the regular session of
+// the market zone of their stock opens. The orders are matched in the order they were queued. It is repeated every minute.
+func (a *Service) session() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		func() {
+
+			var (
+				zones []string
+			)
+
+			rows, err := a.Context.Db.Query(`select distinct a.zone from orders o inner join assets a on a.symbol = o.base_unit where o.status = $1 and o.type = $2`, types.StatusQueue, types.TypeStock)
+			if a.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					name string
+				)
+
+				if err := rows.Scan(&name); a.Context.Debug(err) {
+					return
+				}
+
+				zones = append(zones, name)
+			}
+
+			for _, name := range zones {
+
+				// The orders of the zones which have been removed or turned off meanwhile are released at once.
+				if zone, err := a.QueryZone(name); err == nil && zone.GetStatus() && zone.GetSession() != help.SessionRegular {
+					continue
+				}
+
+				a.Context.Debug(a.writeRelease(name))
+			}
+		}()
+	}
+}
+
+// writeRelease - This function moves the queued orders on the stocks of the market zone into the book and matches them.
+func (a *Service) writeRelease(zone string) error {
+
+	var (
+		orders []*types.Order
+	)
+
+	rows, err := a.Context.Db.Query(`update orders set status = $1 where status = $2 and type = $3 and base_unit in (select symbol from assets where zone = $4) returning id, uid, value, quantity, price, assigning, base_unit, quote_unit, user_id, type, trading, create_at`, types.StatusPending, types.StatusQueue, types.TypeStock, zone)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Order
+		)
+
+		if err := rows.Scan(&item.Id, &item.Uid, &item.Value, &item.Quantity, &item.Price, &item.Assigning, &item.BaseUnit, &item.QuoteUnit, &item.UserId, &item.Type, &item.Trading, &item.CreateAt); err != nil {
+			return err
+		}
+		item.Status = types.StatusPending
+
+		orders = append(orders, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].GetId() < orders[j].GetId()
+	})
+
+	for _, item := range orders {
+
+		// The release is journaled before the order is matched, the queued orders are matched only once they are released.
+		a.Context.Debug(a.writeJournal(types.JournalRelease, item, 0, item.GetPrice(), item.GetValue()))
+
+		switch item.GetAssigning() {
+		case types.AssigningBuy:
+			a.trade(item, types.AssigningSell)
+		case types.AssigningSell:
+			a.trade(item, types.AssigningBuy)
+		}
+	}
+
+	return nil
+}
+
+// ledger - The spot balances of the users recomputed from the history: the credited deposits and the sent withdrawals, the trades
+// of the spot orders less their fees (the fees of the trades are kept in the base unit), the distributions, the
+// commissions of the brokers, the transfers between the sub-wallets and the conversions of the delisted assets.
+const ledger = `select user_id, symbol, sum(value) as value from (
+		select user_id, symbol, value from transactions where assignment = $2 and status = $4
+		union all
+		select user_id, symbol, -value from transactions where assignment = $3 and status = $4 and parent = 0
+		union all
+		select t.user_id, t.base_unit, case when t.assigning = $5 then t.quantity - t.fees else -t.quantity end from trades t inner join orders o on o.id = t.order_id where o.type = $1
+		union all
+		select t.user_id, t.quote_unit, case when t.assigning = $5 then -t.quantity * t.price else (t.quantity - t.fees) * t.price end from trades t inner join orders o on o.id = t.order_id where o.type = $1
+		union all
+		select r.user_id, d.symbol, r.value from distribution_records r inner join distributions d on d.id = r.distribution_id where r.status = $4
+		union all
+		select user_id, symbol, value from commissions
+		union all
+		select user_id, symbol, case when target = $1 then value else -value end from wallet_transfers where source = $1 or target = $1
+		union all
+		select user_id, symbol, -value from conversions where type = $1
+		union all
+		select user_id, quote_unit, converted from conversions where type = $1
+	) l group by user_id, symbol`
+
+// reconciliation - This function reconciles the balances of the users every hour. The spot balances are compared with the balances
+// recomputed from the history, and all the balances are checked not to be negative or held beyond themselves. The trades
+// are written to the history and to the balances one after another, so a discrepancy is acted upon only when the same
+// discrepancy is found by two runs in a row.
+func (a *Service) reconciliation() {
+
+	var (
+		observed = make(map[string]float64)
+	)
+
+	// The code creates a ticker that triggers every hour and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Hour * 1)
+	for range ticker.C {
+
+		items, err := a.queryReconciliation()
+		if a.Context.Debug(err) {
+			continue
+		}
+
+		found := make(map[string]float64)
+		for _, item := range items {
+
+			key := fmt.Sprintf("%v:%v:%v:%v", item.GetUserId(), item.GetSymbol(), item.GetType(), item.GetKind())
+			found[key] = item.GetDifference()
+
+			if difference, ok := observed[key]; !ok || difference != item.GetDifference() {
+				continue
+			}
+
+			a.Context.Debug(a.writeReconciliation(item))
+		}
+		observed = found
+	}
+}
+
+// queryReconciliation - This function returns the discrepancies of the balances: the spot balances which differ from the ledger by
+// more than the epsilon, and the balances which are negative or whose available part is negative.
+func (a *Service) queryReconciliation() (items []*types.Reconciliation, err error) {
+
+	var (
+		epsilon float64
+	)
+
+	if a.Context.Reconciliation != nil {
+		epsilon = a.Context.Reconciliation.Epsilon
+	}
+
+	rows, err := a.Context.Db.Query(`select coalesce(b.user_id, l.user_id), coalesce(b.symbol, l.symbol), coalesce(b.value, 0), coalesce(l.value, 0) from (select user_id, symbol, value from balances where type = $1) b full join (`+ledger+`) l on l.user_id = b.user_id and l.symbol = b.symbol where abs(coalesce(b.value, 0) - coalesce(l.value, 0)) > $6`, types.TypeSpot, types.AssignmentDeposit, types.AssignmentWithdrawal, types.StatusFilled, types.AssigningBuy, epsilon)
+	if err != nil {
+		return items, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item = types.Reconciliation{
+				Type: types.TypeSpot,
+				Kind: types.ReconcileMismatch,
+			}
+		)
+
+		if err := rows.Scan(&item.UserId, &item.Symbol, &item.Balance, &item.Expected); err != nil {
+			return items, err
+		}
+		item.Difference = decimal.New(item.GetBalance()).Sub(item.GetExpected()).Float()
+
+		items = append(items, &item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return items, err
+	}
+
+	// The expected value of the negative balance is the part of it held by the orders and withdrawals, the difference is the
+	// available balance, which must not be negative either.
+	negatives, err := a.Context.Db.Query(`select user_id, symbol, type, value, held from (select b.user_id, b.symbol, b.type, b.value, coalesce((select sum(h.value) from holds h where h.symbol = b.symbol and h.user_id = b.user_id and h.type = b.type), 0) as held from balances b) n where value < 0 or value - held < -$1`, epsilon)
+	if err != nil {
+		return items, err
+	}
+	defer negatives.Close()
+
+	for negatives.Next() {
+
+		var (
+			item = types.Reconciliation{
+				Kind: types.ReconcileNegative,
+			}
+		)
+
+		if err := negatives.Scan(&item.UserId, &item.Symbol, &item.Type, &item.Balance, &item.Expected); err != nil {
+			return items, err
+		}
+		item.Difference = decimal.New(item.GetBalance()).Sub(item.GetExpected()).Float()
+
+		items = append(items, &item)
+	}
+
+	return items, negatives.Err()
+}
+
+// writeReconciliation - This function records the discrepancy of the balance. The drift of the spot balance within the configured
+// drift is corrected at once, the other discrepancies are raised to the operators. The discrepancy already raised with
+// the same difference is not raised again.
+func (a *Service) writeReconciliation(item *types.Reconciliation) error {
+
+	var (
+		difference float64
+		drift      float64
+	)
+
+	if err := a.Context.Db.QueryRow("select difference from reconciliations where user_id = $1 and symbol = $2 and type = $3 and kind = $4 and status = $5 order by id desc limit 1", item.GetUserId(), item.GetSymbol(), item.GetType(), item.GetKind(), types.StatusPending).Scan(&difference); err == nil && difference == item.GetDifference() {
+		return nil
+	}
+
+	if a.Context.Reconciliation != nil {
+		drift = a.Context.Reconciliation.Drift
+	}
+
+	tx, err := a.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	item.Status = types.StatusPending
+	if item.GetKind() == types.ReconcileMismatch && math.Abs(item.GetDifference()) <= drift {
+
+		// The balance which is missing while the ledger holds a drift of it is created before it is corrected.
+		if _, err := tx.Exec("insert into balances (user_id, symbol, type) select $1, $2, $3 where not exists (select id from balances where user_id = $1 and symbol = $2 and type = $3)", item.GetUserId(), item.GetSymbol(), item.GetType()); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("update balances set value = value - $1 where symbol = $2 and user_id = $3 and type = $4", item.GetDifference(), item.GetSymbol(), item.GetUserId(), item.GetType()); err != nil {
+			return err
+		}
+		item.Status = types.StatusFilled
+	}
+
+	if err := tx.QueryRow("insert into reconciliations (user_id, symbol, type, kind, balance, expected, difference, status) values ($1, $2, $3, $4, $5, $6, $7, $8) returning id, create_at", item.GetUserId(), item.GetSymbol(), item.GetType(), item.GetKind(), item.GetBalance(), item.GetExpected(), item.GetDifference(), item.GetStatus()).Scan(&item.Id, &item.CreateAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if item.GetStatus() == types.StatusFilled {
+		a.Context.Logger.Infof("[RECONCILIATION]: corrected %v %v balance of the user %v by %v", item.GetType(), item.GetSymbol(), item.GetUserId(), -item.GetDifference())
+		return nil
+	}
+
+	a.Context.Logger.Warnf("[RECONCILIATION]: %v %v %v balance of the user %v, balance: %v, expected: %v", item.GetKind(), item.GetType(), item.GetSymbol(), item.GetUserId(), item.GetBalance(), item.GetExpected())
+
+	return a.Context.Publish(item, "exchange", "support/reconciliation")
+}
+
+// partition - This function applies the policies of the Partitions of the configuration to the hypertables and the continuous
+// aggregates of the database: the size of the new chunks, the compression and the retention of the old chunks. The policies
+// are applied when the service starts, and are checked every minute, so that the policies changed by a reload of the
+// configuration are applied again. The policies that failed are applied again on the next check.
+func (a *Service) partition() {
+
+	var (
+		applied string
+	)
+
+	// The code creates a ticker that triggers every minute, the first check is made at once.
+	ticker := time.NewTicker(time.Minute * 1)
+	for ; true; <-ticker.C {
+
+		a.Context.Mutex.Lock()
+		partitions := a.Context.Partitions
+		a.Context.Mutex.Unlock()
+
+		// The policies are compared with the ones applied the last time, so that the jobs of the database are not
+		// recreated every minute.
+		marshal, err := json.Marshal(partitions)
+		if a.Context.Debug(err) || string(marshal) == applied {
+			continue
+		}
+
+		failed := false
+		for _, partition := range partitions {
+			if err := a.writePartition(partition); a.Context.Debug(err) {
+				failed = true
+			}
+		}
+
+		if !failed {
+			applied = string(marshal)
+		}
+	}
+}
+
+// writePartition - This function applies the policies of a single hypertable or continuous aggregate. The previous policies of the
+// compression and of the retention are removed first, since the database keeps one policy of each kind per table, and the
+// new ones are added only when they are set. The table is bound as a parameter and cast to its identifier by the database.
+func (a *Service) writePartition(partition *assets.Partition) error {
+
+	if partition.Chunk > 0 {
+		if _, err := a.Context.Db.Exec(`select set_chunk_time_interval($1::regclass, make_interval(days => $2))`, partition.Table, partition.Chunk); err != nil {
+			return err
+		}
+	}
+
+	if _, err := a.Context.Db.Exec(`select remove_compression_policy($1::regclass, if_exists => true)`, partition.Table); err != nil {
+		return err
+	}
+
+	if partition.Compress > 0 {
+		if _, err := a.Context.Db.Exec(`select add_compression_policy($1::regclass, make_interval(days => $2))`, partition.Table, partition.Compress); err != nil {
+			return err
+		}
+	}
+
+	if _, err := a.Context.Db.Exec(`select remove_retention_policy($1::regclass, if_exists => true)`, partition.Table); err != nil {
+		return err
+	}
+
+	if partition.Retain > 0 {
+		if _, err := a.Context.Db.Exec(`select add_retention_policy($1::regclass, make_interval(days => $2))`, partition.Table, partition.Retain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// surveillance - This function analyzes the trading of the window configured for the market surveillance once per window: the
+// matches of the same account or of the linked accounts, the large orders cancelled without a fill while the account
+// trades on the other side of the book, and the pumps driven by the few accounts. The cases flagged are queued for the
+// review of the compliance officers with the snapshot of the trades and the orders. Without the thresholds configured
+// the trading is not analyzed.
+func (a *Service) surveillance() {
+
+	var (
+		last time.Time
+	)
+
+	// The code creates a ticker that triggers every minute, the trading is analyzed when the window has passed since the last run.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		thresholds := a.Context.Surveillance
+		if thresholds == nil {
+			continue
+		}
+
+		window := time.Duration(thresholds.Window) * time.Minute
+		if time.Since(last) < window {
+			continue
+		}
+
+		// The runs analyze the consecutive windows, the first run after the start the window before it.
+		from := last
+		if from.IsZero() {
+			from = time.Now().Add(-window)
+		}
+		last = time.Now()
+
+		fills, orders, err := a.querySurveillance(from)
+		if a.Context.Debug(err) {
+			continue
+		}
+
+		links, err := a.queryLinks(fills, thresholds.Linkage)
+		if a.Context.Debug(err) {
+			continue
+		}
+
+		cases := surveillance.Wash(fills, links)
+		cases = append(cases, surveillance.Spoof(orders, fills, *thresholds)...)
+		cases = append(cases, surveillance.Pump(fills, *thresholds)...)
+
+		for _, item := range cases {
+			a.Context.Debug(a.writeSurveillance(item))
+		}
+	}
+}
+
+// querySurveillance - This function returns the matches and the orders of the journal since the time: the matches with the account of
+// the resting order, and the orders placed with the part of them filled and the time they were cancelled at.
+func (a *Service) querySurveillance(from time.Time) (fills []surveillance.Fill, orders []surveillance.Order, err error) {
+
+	rows, err := a.Context.Db.Query(`select j.order_id, j.counter_id, j.user_id, o.user_id, j.assigning, j.base_unit, j.quote_unit, j.price, j.value, j.create_at from journal j inner join orders o on o.id = j.counter_id where j.kind = $1 and j.create_at > $2 order by j.id`, types.JournalMatch, from)
+	if err != nil {
+		return fills, orders, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item surveillance.Fill
+		)
+
+		if err := rows.Scan(&item.OrderId, &item.CounterId, &item.UserId, &item.CounterUser, &item.Assigning, &item.BaseUnit, &item.QuoteUnit, &item.Price, &item.Value, &item.CreateAt); err != nil {
+			return fills, orders, err
+		}
+
+		fills = append(fills, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fills, orders, err
+	}
+
+	// The part of the cancelled order filled is the value it was placed with less the value it was cancelled with.
+	rows, err = a.Context.Db.Query(`select p.order_id, p.user_id, p.assigning, p.base_unit, p.quote_unit, p.price, p.value, coalesce(p.value - c.value, 0), p.create_at, c.create_at from journal p left join journal c on c.order_id = p.order_id and c.kind = $2 where p.kind = $1 and p.create_at > $3 order by p.id`, types.JournalPlace, types.JournalCancel, from)
+	if err != nil {
+		return fills, orders, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item     surveillance.Order
+			cancelAt sql.NullTime
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.Assigning, &item.BaseUnit, &item.QuoteUnit, &item.Price, &item.Quantity, &item.Filled, &item.CreateAt, &cancelAt); err != nil {
+			return fills, orders, err
+		}
+		item.CancelAt = cancelAt.Time
+
+		orders = append(orders, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fills, orders, err
+	}
+
+	return fills, orders, nil
+}
+
+// queryLinks - This function returns the links of the accounts that traded in the matches, the accounts signed in from the same
+// address within the days of the linkage. Without the days of the linkage the accounts are not linked.
+func (a *Service) queryLinks(fills []surveillance.Fill, linkage int) (surveillance.Links, error) {
+
+	var (
+		links = make(surveillance.Links)
+		users []int64
+	)
+
+	if linkage == 0 || len(fills) == 0 {
+		return links, nil
+	}
+
+	for _, fill := range fills {
+		users = append(users, fill.UserId, fill.CounterUser)
+	}
+
+	rows, err := a.Context.Db.Query(`select distinct a.user_id, b.user_id from actions a inner join actions b on b.ip = a.ip and b.user_id <> a.user_id and b.create_at > now() - $2 * interval '1 day' where a.user_id = any($1) and b.user_id = any($1) and a.ip <> '' and a.create_at > now() - $2 * interval '1 day'`, pq.Array(users), linkage)
+	if err != nil {
+		return links, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			user, linked int64
+		)
+
+		if err := rows.Scan(&user, &linked); err != nil {
+			return links, err
+		}
+
+		links.Link(user, linked)
+	}
+
+	return links, rows.Err()
+}
+
+// writeSurveillance - This function queues the case for the review of the compliance officers with the snapshot of the evidence,
+// the case already queued by the previous run is not queued again.
+func (a *Service) writeSurveillance(item *surveillance.Case) error {
+
+	evidence, err := json.Marshal(item.Evidence)
+	if err != nil {
+		return err
+	}
+
+	related := item.Related
+	if related == nil {
+		related = []int64{}
+	}
+
+	if _, err := a.Context.Db.Exec("insert into surveillance_cases (kind, user_id, related, base_unit, quote_unit, score, evidence, fingerprint) values ($1, $2, $3, $4, $5, $6, $7, $8) on conflict (fingerprint) do nothing", item.Kind, item.UserId, pq.Array(related), item.BaseUnit, item.QuoteUnit, item.Score, evidence, item.Key()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// heartbeat - This function reports the health of the matching engine to the status page every minute. The engine is down when
+// the book can not be read, and degraded when a book of a pair is crossed, that is when the highest pending buy order is at
+// or above the lowest pending sell order of the same pair, which the engine would have matched if it was keeping up.
+func (a *Service) heartbeat() {
+
+	// The code creates a ticker that triggers every minute, the first report is made at once.
+	ticker := time.NewTicker(time.Minute * 1)
+	for ; true; <-ticker.C {
+
+		var (
+			crossed []string
+		)
+
+		rows, err := a.Context.Db.Query(`select b.base_unit, b.quote_unit from orders b inner join orders s on s.base_unit = b.base_unit and s.quote_unit = b.quote_unit and s.type = b.type and s.assigning = $2 and s.status = $3 where b.assigning = $1 and b.status = $3 group by b.base_unit, b.quote_unit, b.type having max(b.price) >= min(s.price)`, types.AssigningBuy, types.AssigningSell, types.StatusPending)
+		if a.Context.Debug(err) {
+			a.Context.Heartbeat("matcher", types.ComponentMatcher, types.ComponentDown, "the book can not be read", 0)
+			continue
+		}
+
+		for rows.Next() {
+
+			var (
+				base, quote string
+			)
+
+			if err := rows.Scan(&base, &quote); a.Context.Debug(err) {
+				continue
+			}
+			crossed = append(crossed, fmt.Sprintf("%v/%v", strings.ToUpper(base), strings.ToUpper(quote)))
+		}
+		_ = rows.Close()
+
+		if len(crossed) > 0 {
+			a.Context.Heartbeat("matcher", types.ComponentMatcher, types.ComponentDegraded, fmt.Sprintf("the books of %v are crossed", strings.Join(crossed, ", ")), 0)
+		} else {
+			a.Context.Heartbeat("matcher", types.ComponentMatcher, types.ComponentOperational, "", 0)
+		}
+	}
+}
+
+// ReplayJournal - This function rebuilds the state of the orders from the journal of the book and compares it with the orders and
+// the trades of the database, it is used to audit the disputed fills and to recover the orders after a corruption. Every
+// command of the journal is applied in the order it was written, the commands that contradict the state rebuilt so far are
+// reported and skipped. The orders whose remaining value or status differ from the journal are reported, and corrected
+// from the journal when the repair is set, the orders removed together with their pairs are not compared. The number of the
+// trades of every order is compared with the number of its fills, the trades are only reported, they are never rewritten.
+func (a *Service) ReplayJournal(repair bool) (*matcher.Ledger, []string, error) {
+
+	var (
+		ledger        = matcher.NewLedger()
+		placed        = make(map[int64]time.Time)
+		discrepancies []string
+	)
+
+	rows, err := a.Context.Db.Query(`select id, kind, order_id, counter_id, assigning, price, value, status, create_at from journal order by id`)
+	if err != nil {
+		return ledger, discrepancies, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			id, order, counter  int64
+			kind, assigning, st string
+			price, value        float64
+			create              time.Time
+		)
+
+		if err := rows.Scan(&id, &kind, &order, &counter, &assigning, &price, &value, &st, &create); err != nil {
+			return ledger, discrepancies, err
+		}
+
+		switch kind {
+		case types.JournalPlace:
+			if err = ledger.Place(order, assigning == types.AssigningBuy, price, value, st == types.StatusQueue); err == nil {
+				placed[order] = create
+			}
+		case types.JournalRelease:
+			err = ledger.Release(order)
+		case types.JournalCancel:
+			err = ledger.Cancel(order)
+		case types.JournalAdjust:
+			err = ledger.Adjust(order, price, value)
+		case types.JournalMatch:
+			err = ledger.Match(order, counter, price, value)
+		default:
+			err = fmt.Errorf("the kind %v of the command is not known", kind)
+		}
+
+		if err != nil {
+			discrepancies = append(discrepancies, fmt.Sprintf("journal %d: %v", id, err))
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return ledger, discrepancies, err
+	}
+
+	for _, position := range ledger.Positions() {
+
+		var (
+			value  float64
+			st     string
+			trades int
+		)
+
+		// The status the order must have by the journal: the cancelled orders are cancelled even when they were partially
+		// filled, the orders with no remaining value are filled, the others are queued or in the book.
+		expected := types.StatusPending
+		switch {
+		case position.Cancelled:
+			expected = types.StatusCancel
+		case position.Value == 0:
+			expected = types.StatusFilled
+		case position.Queued:
+			expected = types.StatusQueue
+		}
+
+		if err := a.Context.Db.QueryRow(`select value, status from orders where id = $1`, position.Id).Scan(&value, &st); errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return ledger, discrepancies, err
+		}
+
+		if math.Abs(value-position.Value) > 1e-9 || st != expected {
+			discrepancies = append(discrepancies, fmt.Sprintf("order %d: the value %v and the status %v, the journal has the value %v and the status %v", position.Id, value, st, position.Value, expected))
+
+			if repair {
+				if _, err := a.Context.Db.Exec(`update orders set value = $2, status = $3 where id = $1`, position.Id, position.Value, expected); err != nil {
+					return ledger, discrepancies, err
+				}
+			}
+		}
+
+		// The trades written before the order was placed in the journal, by the orders of the book the journal started with,
+		// have no fills in the journal and are not counted.
+		if err := a.Context.Db.QueryRow(`select count(*) from trades where order_id = $1 and create_at >= $2`, position.Id, placed[position.Id]).Scan(&trades); err != nil {
+			return ledger, discrepancies, err
+		}
+
+		if trades != len(position.Fills) {
+			discrepancies = append(discrepancies, fmt.Sprintf("order %d: %d trades, the journal has %d fills", position.Id, trades, len(position.Fills)))
+		}
 	}
+
+	return ledger, discrepancies, nil
 }
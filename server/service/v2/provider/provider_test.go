@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+)
+
+func TestReduce(t *testing.T) {
+	tests := []struct {
+		name      string
+		assigning string
+		current   string
+		value     float64
+		price     float64
+		reduced   string
+		release   string
+	}{
+		{name: "buy", assigning: types.AssigningBuy, current: "0.5", value: 0.2, price: 20000, reduced: "0.3", release: "6000"},
+		{name: "sell", assigning: types.AssigningSell, current: "0.5", value: 0.2, price: 20000, reduced: "0.3", release: "0.3"},
+		{name: "exact", assigning: types.AssigningBuy, current: "0.300000000000000001", value: 0.1, price: 3, reduced: "0.200000000000000001", release: "0.600000000000000003"},
+		{name: "unchanged", assigning: types.AssigningSell, current: "1", value: 1, price: 10, reduced: "0", release: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reduced, release := queryReduce(tt.assigning, tt.current, tt.value, tt.price)
+			if !reduced.Equal(decimal.New(tt.reduced).Decimal) {
+				t.Errorf("reduced = %v, want %v", reduced.String(), tt.reduced)
+			}
+			if !release.Equal(decimal.New(tt.release).Decimal) {
+				t.Errorf("release = %v, want %v", release.String(), tt.release)
+			}
+		})
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	tests := []struct {
+		name                 string
+		balance, held, value float64
+		wantErr              bool
+	}{
+		{name: "available", balance: 100, held: 40, value: 60},
+		{name: "held", balance: 100, held: 40, value: 60.000001, wantErr: true},
+		{name: "empty", balance: 0, held: 0, value: 1, wantErr: true},
+		{name: "zero", balance: 100, held: 0, value: 0, wantErr: true},
+		{name: "negative", balance: 100, held: 0, value: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queryAvailable("usdt", tt.balance, tt.held, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("queryAvailable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && status.Code(err) != 11714 {
+				t.Errorf("queryAvailable() code = %v, want 11714", status.Code(err))
+			}
+		})
+	}
+}
@@ -1,465 +1,115 @@
 package spot
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/cryptogateway/backend-envoys/assets/blockchain"
-	"github.com/cryptogateway/backend-envoys/assets/common/address"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
-	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
 	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
+	"github.com/cryptogateway/backend-envoys/assets/common/webhook"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
 	"github.com/cryptogateway/backend-envoys/server/types"
-	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/crypto"
 	"math/big"
-	"strconv"
-	"strings"
+	"sync"
+	"time"
 )
 
-// ethereum - This code is part of a Service object in the code which handles Ethereum deposits. The purpose of this code is to
-// update the Ethereum block height and scan the new block for any transactions that involve deposits. It will then
-// publish the deposit transaction to an exchange. It also includes error handling, such as attempting to debug any
-// errors that occur throughout the process.
-func (e *Service) ethereum(chain *types.Chain) {
+// WriteWebhook - This function records the transfers of the chain reported by the webhook of a third-party node provider, as the
+// scanner records the transfers of the blocks it reads: the transfers of the watched addresses, both the deposits and the
+// withdrawals, are recorded for their users and the transfers to the wallets of the exchange are recorded as pending
+// deposits. The transfers already recorded by the scanner or by an earlier event are recorded once, by their hashes.
+func (e *Service) WriteWebhook(chainId int64, transfers []*webhook.Transfer) error {
 
-	// The purpose of this code is to use to defer keyword to recover from a panic. It does this by catching the panic with
-	// the recover() function, and then using the e.Context.Debug() function to log the recovered panic. If the panic is
-	// successfully recovered, the code will return.
-	defer func() {
-		if r := recover(); e.Context.Debug(r) {
-			return
-		}
-	}()
-
-	// This code is used to establish a connection between a client and a blockchain platform. The first line is creating a
-	// new client connection to the blockchain platform, and the second line is checking for any errors that may have
-	// occurred during the connection. If an error is found, the code will exit and not continue.
-	client, err := blockchain.Dial(chain.GetRpc(), chain.GetPlatform())
-	if err != nil { // No debug....
-		return
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
 	}
 
-	// This code is checking if an error occurred when the client tried to retrieve a block by number from the blockchain.
-	// If an error did occur, the code returns without doing anything else.
-	blockBy, err := client.BlockByNumber(chain.GetBlock())
-	if err != nil { // No debug....
-		return
+	chain, err := _provider.QueryChain(chainId, true)
+	if err != nil {
+		return err
 	}
 
-	// This code is looping through the transactions of a block, where blockBy is the block that the transactions belong to.
-	// The underscore is a special character that is used when you don't care about the index of the loop. It is commonly
-	// used when you only need the value of the array.
-	for _, tx := range blockBy.Transactions {
-
-		var (
-			item types.Transaction
-		)
-
-		// The switch statement is used to execute one statement from multiple conditions. In this example, the value of the
-		// variable tx.Type is compared against a number of possible values and the corresponding statement is executed. This
-		// can be useful when there are multiple possible outcomes and each one requires a different action.
-		switch tx.Type {
-		case blockchain.TypeInternal:
-
-			// This code is setting the quantity to the value of the tx.Value field, which is a hexadecimal string prefixed with
-			// 0x. The SetString method of the big.Int type is used to convert the hexadecimal string to a big.Int type, which is
-			// then assigned to the quantity variable.
-			quantity := new(big.Int)
-			quantity.SetString(strings.TrimPrefix(tx.Value, "0x"), 16)
-
-			// This code is checking if the value of the quantity is greater than zero. If the value of quantity is greater than
-			// zero, the code assigns the quantity to a decimal.New object and sets the Floating to 18.
-			if value := decimal.New(quantity).Floating(18); value > 0 {
-
-				// The purpose of this statement is to convert the "To" address of the transaction (tx.To) into a hexadecimal string
-				// and assign it to the "To" field of the item object.
-				item.To = address.New(tx.To).Hex()
-
-				// This code is executing a query to determine if the user ID associated with the address and platform exists. If the
-				// user ID is greater than 0, the code sets the symbol, chain ID, platform, financial type, transaction type, value,
-				// hash and block of the item.
-				if _ = e.Context.Db.QueryRow("select user_id from wallets where address = $1 and platform = $2", item.GetTo(), chain.GetPlatform()).Scan(&item.UserId); item.GetUserId() > 0 {
-
-					// This code is setting properties of an item object. Specifically, it is setting the symbol of a parent chain, the
-					// id of a chain, the platform, the financial type, the transaction type, the value, the hash, and the block.
-					item.Symbol = chain.GetParentSymbol()
-					item.ChainId = chain.GetId()
-					item.Platform = chain.GetPlatform()
-					item.Protocol = types.ProtocolMainnet
-					item.Group = types.GroupCrypto
-					item.Allocation = types.AllocationExternal
-					item.Assignment = types.AssignmentDeposit
-					item.Value = value
-					item.Hash = tx.Hash
-					item.Block = chain.GetBlock()
-				}
-			}
-
-			break
-		case blockchain.TypeContract:
-
-			var (
-				contract types.Contract
-			)
-
-			// This code is attempting to retrieve logs associated with a transaction using the client's LogByTx method. It is
-			// checking for any errors that occur during the retrieval process. If an error occurs, it will return without doing
-			// anything else.
-			logs, err := client.LogByTx(tx.Hash)
-			if err != nil {
-				return
-			}
-
-			// This is an if statement that is checking if the Data field of the logs variable is not nil. If it is not nil, then
-			// it will execute the code in the if statement.
-			if logs.Data != nil {
-
-				// This code is checking a database table for a particular address and storing the corresponding symbol, protocol,
-				// and decimals values in the contract object. If the query does not return a result, the "continue" statement will
-				// prevent any further processing, and move on to the next iteration of the loop.
-				if err := e.Context.Db.QueryRow("select symbol, protocol, decimals from contracts where lower(address) = $1", address.New(tx.To).Hex()).Scan(&contract.Symbol, &contract.Protocol, &contract.Decimals); err != nil { // No debug....
-					continue
-				}
-
-				// The purpose of the above code is to check if the length of the "Topics" field in the "logs" object is equal to 3.
-				// If it is, the code inside the if statement will be executed.
-				if len(logs.Topics) == 3 {
-
-					// The purpose of the code is to generate a cryptographic hash of the string "Transfer(address,address,uint256)".
-					// This hash can be used to verify the authenticity of the string or data.
-					transfer := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
-
-					// Checking the method itself.
-					switch logs.Topics[0] {
-					case transfer.Hex():
-
-						// This code is part of a loop that is attempting to access metadata from a blockchain. The purpose of this
-						// specific code snippet is to parse a JSON object stored in the blockchain's main metadata using an ABI
-						// (Application Binary Interface) and store it in the tokenAbi variable, so the data can be accessed later. If any
-						// errors are encountered in the process, the code will skip the current iteration and continue with the loop.
-						tokenAbi, err := abi.JSON(strings.NewReader(blockchain.MainMetaData.ABI))
-						if e.Context.Debug(err) {
-							continue
-						}
-
-						// The purpose of this code is to unpack a "Transfer" event from the "logs.Data" using the "tokenAbi" ABI. If an
-						// error occurs, the code will continue on to the next line.
-						instance, err := tokenAbi.Unpack("Transfer", logs.Data)
-						if e.Context.Debug(err) {
-							continue
-						}
-
-						// The code is checking that the element at the index 0 of the instance array is of type *big.Int. If it is, it
-						// assigns the element to the number variable and sets ok to true, otherwise ok is set to false.
-						if number, ok := instance[0].(*big.Int); ok {
-
-							// The if statement is used to check if the value of the decimal.New(number).Floating(contract.GetDecimals()) is
-							// greater than 0. If it is, then the code inside the block will be executed.
-							if value := decimal.New(number).Floating(contract.GetDecimals()); value > 0 {
-
-								// item.To is a variable used to store the address of the recipient of a transaction. The purpose of the code is
-								// to convert the address stored in logs.Topics[2] (which is a string) to a hexadecimal value and store it in the
-								// item.To variable.
-								item.To = address.New(logs.Topics[2].(string)).Hex()
-
-								// This code is querying a database to locate a user ID associated with a wallet address, platform, and protocol.
-								// If a user ID is found and is greater than 0, then the item associated with that user is set to various values,
-								// such as symbol, protocol, chain ID, platform, financial type, transaction type, value, hash, and block.
-								if _ = e.Context.Db.QueryRow("select user_id from wallets where address = $1 and platform = $2", item.GetTo(), chain.GetPlatform()).Scan(&item.UserId); item.GetUserId() > 0 {
-
-									// This code is setting properties of an item object. Specifically, it is setting the symbol of a parent chain, the
-									// id of a chain, the platform, the financial type, the transaction type, the value, the hash, and the block.
-									item.Symbol = contract.GetSymbol()
-									item.Protocol = contract.GetProtocol()
-									item.ChainId = chain.GetId()
-									item.Platform = chain.GetPlatform()
-									item.Group = types.GroupCrypto
-									item.Allocation = types.AllocationExternal
-									item.Assignment = types.AssignmentDeposit
-									item.Value = value
-									item.Hash = tx.Hash
-									item.Block = chain.GetBlock()
-								}
-							}
-						}
-					}
-				}
-			}
-			break
-		}
-
-		// The purpose of this code is to check if the value of the item is greater than 0. If the value is greater than 0,
-		// then the code inside the if statement will be executed.
-		if item.GetValue() > 0 {
-
-			// Creates a service provider to be used in the given context, providing the necessary services for the application.
-			_provider := provider.Service{
-				Context: e.Context,
-			}
-
-			// This code is setting up a transaction and checking for errors. If an error is encountered, the code will return and
-			// stop further execution. This is a way to make sure that the transaction is handled correctly, and that any
-			// potential errors are addressed.
-			transaction, err := _provider.WriteTransaction(&item)
-			if e.Context.Debug(err) {
-				return
-			}
-
-			// The purpose of this code is to publish a transaction to an exchange, with a routing key of "deposit/open" and
-			// "deposit/status". If there is an error, the code will print out the error and return.
-			if err := e.Context.Publish(transaction, "exchange", "deposit/open", "deposit/status"); e.Context.Debug(err) {
-				return
-			}
-		}
+	adapter, ok := platform.Lookup(chain.GetPlatform())
+	if !ok {
+		return fmt.Errorf("the transfers of the platform %v are not recorded", chain.GetPlatform())
 	}
 
-	// This code is updating a database with the new block information. The if statement is used to check for any errors
-	// that may occur during the database update, and the e.Context.Debug(err) will log any errors that occur. If an error
-	// is encountered, the return statement will be executed, causing the code to exit without updating the database.
-	if _, err := e.Context.Db.Exec("update chains set block = $1 where id = $2;", chain.GetBlock()+1, chain.GetId()); e.Context.Debug(err) {
-		return
+	if !e.writeDeposits(chain, e.queryDeposits(chain, adapter, transfers)) {
+		return errors.New("the deposits of the webhook are not recorded")
 	}
 
-	// This statement assigns the block associated with the provided chain ID to the 'block' element of the 'e' object. This
-	// statement is used to store the block in the e object, so that it can be accessed later.
-	e.block[chain.GetId()] = chain.GetBlock()
-
-	// The purpose of e.done(chain.GetId()) is to execute the callback function associated with the e.done() method once the
-	// chain.GetId() method has completed. This allows the code to wait for the chain.GetId() method to fully complete
-	// before executing any further code.
-	e.done(chain.GetId())
+	return nil
 }
 
-// tron - The purpose of this code is to deposit cryptocurrency on a blockchain. It checks the block number and goes through the
-// list of transactions on the blockchain to find deposits. It then checks the type of transaction and parses the data to
-// check if the deposit is valid. If it is valid, it sets up the transaction and publishes it. Finally, it updates the
-// block number so the next deposit can be checked.
-func (e *Service) tron(chain *types.Chain) {
-
-	// The purpose of this code is to handle a panic (or run-time error) that may occur during execution. The defer keyword
-	// is used to ensure that the function is run even if the code panics. The recover() function returns the value that was
-	// passed to the panic() function, which is then passed to the Context.Debug() function for logging. If the
-	// Context.Debug() function returns true, the function will return without further execution. This allows the code to
-	// continue running without crashing.
-	defer func() {
-		if r := recover(); e.Context.Debug(r) {
-			return
-		}
-	}()
+// queryDeposits - This function returns the deposits among the transfers of the chain, read from its blocks by the scanner or
+// reported by a webhook: the transfers of the coin and of the listed tokens to the wallets of the exchange. The
+// transfers of the watched addresses are recorded for their users on the way.
+func (e *Service) queryDeposits(chain *types.Chain, adapter platform.Platform, transfers []*platform.Transfer) (deposits []*types.Transaction) {
 
-	// This code is establishing a connection to a blockchain platform using the RPC protocol. The first line creates a
-	// client connection to the blockchain using the RPC URL and platform credentials. The second line checks for any errors
-	// that may have occurred during the connection process. If there is an error, the function will terminate.
-	client, err := blockchain.Dial(chain.GetRpc(), chain.GetPlatform())
-	if err != nil { // No debug....
-		return
-	}
-
-	// This code is using the function BlockByNumber() from the client library to get a block from the blockchain. The
-	// function returns a BlockBy object and an error. If an error is returned, the code will not continue and instead
-	// return. This ensures that errors are not ignored and the program does not crash.
-	blockBy, err := client.BlockByNumber(chain.GetBlock())
-	if err != nil { // No debug....
-		return
-	}
-
-	// The purpose of the above code is to loop through all the transactions in the blockBy object and perform operations on
-	// each transaction. The underscore character is a blank identifier which is used when the loop variable will not be used.
-	for _, tx := range blockBy.Transactions {
+	for _, transfer := range transfers {
 
 		var (
-			item types.Transaction
+			item     types.Transaction
+			contract types.Contract
 		)
 
-		// The switch statement is used to execute a block of code based on the value of a certain expression. In this case,
-		// the value of the expression is the tx.Type variable. Depending on the value of tx.Type, different blocks of code can be executed.
-		switch tx.Type {
-		case blockchain.TypeInternal: // TRX parse transfer coin.
+		// The addresses are brought into the form the wallets and the contracts of the platform are recorded in, the coin of
+		// the chain is the transfer without the contract.
+		from, to := adapter.Format(transfer.From), adapter.Format(transfer.To)
 
-			// This code is attempting to convert the "tx.Value" string into a float64 type, which is saved in the variable
-			// "value". If the conversion results in an error, the e.Context.Debug(err) function is used to log the error and return.
-			value, err := strconv.ParseFloat(tx.Value, 64)
-			if e.Context.Debug(err) {
-				return
-			}
+		if len(transfer.Contract) > 0 {
 
-			// This is an if statement, which is a type of conditional statement. It checks to see if the value is greater than
-			// zero. If it is, the code within the statement will be executed. If not, the code will be skipped.
-			if value > 0 {
-
-				// The purpose of this code is to convert the To address from a transaction (tx) to a Base58 encoded address. This is
-				// a standard format for sending and receiving cryptocurrency, and is used to ensure that the address is valid and
-				// can be used for the intended purpose.
-				item.To = address.New(tx.To).Base58()
-
-				// This code is querying the wallets table to find the user_id associated with a particular address, platform, and
-				// item. If the user_id is successfully found, it then sets the symbol, chain id, platform, financial type,
-				// transaction type, value, hash, and block associated with the item.
-				if _ = e.Context.Db.QueryRow("select user_id from wallets where address = $1 and platform = $2", item.GetTo(), chain.GetPlatform()).Scan(&item.UserId); item.GetUserId() > 0 {
-
-					// This code is setting properties of an item object. Specifically, it is setting the symbol of a parent chain, the
-					// id of a chain, the platform, the financial type, the transaction type, the value, the hash, and the block.
-					item.Symbol = chain.GetParentSymbol()
-					item.ChainId = chain.GetId()
-					item.Platform = chain.GetPlatform()
-					item.Protocol = types.ProtocolMainnet
-					item.Group = types.GroupCrypto
-					item.Allocation = types.AllocationExternal
-					item.Assignment = types.AssignmentDeposit
-					item.Value = decimal.New(value).Floating(6)
-					item.Hash = tx.Hash
-					item.Block = chain.GetBlock()
-				}
+			// The tokens that are not listed are not deposits.
+			if err := e.Context.Db.QueryRow("select symbol, protocol, decimals from contracts where address = $1 or lower(address) = $1", adapter.Format(transfer.Contract)).Scan(&contract.Symbol, &contract.Protocol, &contract.Decimals); err != nil { // No debug....
+				continue
 			}
 
-			break
-		case blockchain.TypeContract: // Smart contract trigger transfer token.
-
-			var (
-				contract types.Contract
-			)
-
-			// This code is retrieving logs associated with the given transaction, indicated by the tx.Hash parameter. It is using
-			// the client's LogByTx method to achieve this and is checking for an error. If an error is encountered, the function is returned.
-			logs, err := client.LogByTx(tx.Hash)
-			if err != nil {
-				return
-			}
-
-			// This statement is checking if the variable logs.Data is not equal to nil (null). If it is not equal to nil, then
-			// some code will be executed. This is a common way of checking if a variable is initialized or not.
-			if logs.Data != nil {
-
-				// This piece of code is attempting to query the database to find the symbol, protocol, and decimals of a given
-				// contract address. If it is unable to find the information, the code will skip the rest of the loop and continue on
-				// with the next iteration.
-				if err := e.Context.Db.QueryRow("select symbol, protocol, decimals from contracts where address = $1", address.New(tx.To).Base58()).Scan(&contract.Symbol, &contract.Protocol, &contract.Decimals); err != nil { // No debug....
-					continue
-				}
-
-				// This is an if statement which checks the length of the "logs.Topics" array. If the length of the array is equal to
-				// 3, then the code within the if statement will be executed.
-				if len(logs.Topics) == 3 {
-
-					// The purpose of the code snippet is to calculate the Keccak-256 hash of the string
-					// "Transfer(address,address,uint256)". Keccak-256 is a cryptographic hash algorithm used to generate a unique,
-					// fixed-size 256-bit (32-byte) hash. The hash is used as a unique identifier for the string, which can be used to
-					// verify its integrity.
-					transfers := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
-
-					// This code is checking to see if the first element in the "logs.Topics" array is equal to the result of the
-					// "strings.TrimPrefix" function applied to the "transfers.Hex()" function.
-					// The "strings.TrimPrefix" function removes the "0x" prefix from the string returned by the "transfers.Hex()" function.
-					if logs.Topics[0] == strings.TrimPrefix(transfers.Hex(), "0x") {
-
-						// This code is attempting to read the ABI of a blockchain. The tokenAbi variable stores the ABI from the
-						// blockchain's MainMetaData. The ABI is read in from a string and parsed as a JSON object. If an error occurs
-						// while parsing the ABI, the error is logged and the code continues.
-						tokenAbi, err := abi.JSON(strings.NewReader(blockchain.MainMetaData.ABI))
-						if e.Context.Debug(err) {
-							continue
-						}
-
-						// This code snippet is attempting to unpack a log from a smart contract using an ABI (Application Binary
-						// Interface). The instance variable will contain the output of the unpacking while err will contain any errors
-						// that occur during the unpacking process. The if statement then checks for any errors that have occurred, and if
-						// there are any it will skip the rest of the code and continue.
-						instance, err := tokenAbi.Unpack("Transfer", logs.Data)
-						if e.Context.Debug(err) {
-							continue
-						}
-
-						// This is an example of type assertion. It is used to check if the type of instance[0] is a *big.Int, and if it
-						// is, it checks if the Int64() of the *big.Int is greater than 0. If both of these conditions are true, then the
-						// if statement is executed.
-						if number, ok := instance[0].(*big.Int); ok || number.Int64() > 0 {
-
-							// The purpose of this code is to convert a number from a decimal to a floating point value. The value is then
-							// compared to 0 to determine if it is greater than 0 or not. If it is greater than 0, a certain action is taken.
-							if value := decimal.New(number).Floating(contract.GetDecimals()); value > 0 {
-
-								// This code is used to convert a given log's topics element at index 2 from its original form (a string) into a
-								// Base58 encoded version. This is often used when dealing with cryptographic addresses, as Base58 is a format
-								// commonly used to represent them.
-								item.To = address.New(logs.Topics[2].(string)).Base58()
-
-								// This code is querying a database to find the user_id associated with a particular address, platform, and
-								// protocol in order to update the item with symbol, protocol, chain id, platform, financial type, transaction
-								// type, value, hash and block. The if statement is used to check if the user_id is greater than 0, indicating
-								// that the query was successful and the item can be updated.
-								if _ = e.Context.Db.QueryRow("select user_id from wallets where address = $1 and platform = $2", item.GetTo(), chain.GetPlatform()).Scan(&item.UserId); item.GetUserId() > 0 {
-
-									// This code is setting properties of an item object. Specifically, it is setting the symbol of a parent chain, the
-									// id of a chain, the platform, the financial type, the transaction type, the value, the hash, and the block.
-									item.Symbol = contract.GetSymbol()
-									item.Protocol = contract.GetProtocol()
-									item.ChainId = chain.GetId()
-									item.Platform = chain.GetPlatform()
-									item.Group = types.GroupCrypto
-									item.Allocation = types.AllocationExternal
-									item.Assignment = types.AssignmentDeposit
-									item.Value = value
-									item.Hash = tx.Hash
-									item.Block = chain.GetBlock()
-								}
-							}
-						}
-					}
-				}
-			}
-			break
+		} else {
+			contract.Symbol, contract.Protocol, contract.Decimals = chain.GetParentSymbol(), types.ProtocolMainnet, chain.GetDecimals()
 		}
 
-		// The purpose of this code is to check if the value of the item is greater than 0. If it is, then the code inside the
-		// if statement will be executed.
-		if item.GetValue() > 0 {
-
-			// Creates a service provider to be used in the given context, providing the necessary services for the application.
-			_provider := provider.Service{
-				Context: e.Context,
-			}
-
-			// This code is setting up a transaction for an item, and then checking for any errors that may occur during that
-			// transaction. If an error is detected, the code will return and terminate the transaction.
-			transaction, err := _provider.WriteTransaction(&item)
-			if e.Context.Debug(err) {
-				return
-			}
-
-			// This code is intended to publish a transaction to an exchange using the "deposit/open" and "deposit/status" routing
-			// keys. The if statement is checking for an error during the publishing process and returning if one is found. The
-			// e.Context.Debug call is used to log the error for further investigation.
-			if err := e.Context.Publish(transaction, "exchange", "deposit/open", "deposit/status"); e.Context.Debug(err) {
-				return
-			}
+		value := decimal.New(transfer.Amount).Floating(contract.GetDecimals())
+		if value <= 0 {
+			continue
 		}
-	}
 
-	// This code is used to update the block number associated with a particular chain ID in a database. The first line of
-	// code, `if _, err := e.Context.Db.Exec("update chains set block = $1 where id = $2;", chain.GetBlock()+1,
-	// chain.GetId()); e.Context.Debug(err) {`, attempts to execute an SQL statement that updates the block number for the
-	// associated ID. If an error occurs, the error is logged and the code returns.
-	if _, err := e.Context.Db.Exec("update chains set block = $1 where id = $2;", chain.GetBlock()+1, chain.GetId()); e.Context.Debug(err) {
-		return
+		// The transfer is also recorded for the external addresses that are watched by the users.
+		e.watch(chain, transfer.Block, transfer.Hash, from, to, contract.GetSymbol(), value)
+
+		// The transfer to the wallet of a user of the exchange is the deposit of the user.
+		if _ = e.Context.Db.QueryRow("select user_id from wallets where address = $1 and platform = $2", to, chain.GetPlatform()).Scan(&item.UserId); item.GetUserId() > 0 {
+
+			item.Symbol = contract.GetSymbol()
+			item.Protocol = contract.GetProtocol()
+			item.ChainId = chain.GetId()
+			item.Platform = chain.GetPlatform()
+			item.Group = types.GroupCrypto
+			item.Allocation = types.AllocationExternal
+			item.Assignment = types.AssignmentDeposit
+			item.Value = value
+			item.Hash = transfer.Hash
+			item.From = from
+			item.To = to
+			item.Block = transfer.Block
+			item.BlockHash = transfer.BlockHash
+
+			deposits = append(deposits, &item)
+		}
 	}
 
-	// This line of code is used to store a block in a blockchain. The block[chain.GetId()] is used to refer to a specific
-	// block in the chain, and the chain.GetBlock() is used to retrieve this block from the chain. This line of code is used
-	// to ensure that the retrieved block is stored in the blockchain, so that it can be used later.
-	e.block[chain.GetId()] = chain.GetBlock()
-
-	// e.done(chain.GetId()) is a function used to retrieve the ID of a completed chain. It is used to get the ID of a chain
-	// after it has been processed and completed. This is useful when tracking the progress of a chain or when performing
-	// operations on a specific chain.
-	e.done(chain.GetId())
+	return deposits
 }
 
 // transfer - This function is used in a blockchain application to transfer Ethereum. It performs a variety of actions such as
 // dialing the correct RPC, creating a keypair and a private key, estimating the gas for the transaction, setting a
 // reserve account for the funds being transferred, and setting the reserve account to unlock. Finally, it publishes the
-// transaction to the exchange and sends out an email notification.
-func (e *Service) transfer(userId, txId int64, symbol string, to string, value, price float64, protocol string, chain *types.Chain, allocation string) {
+// transaction to the exchange and sends out an email notification. The funds are sent from the address of the reserve, which
+// can be any of the deposit addresses of the user, its private key is derived with the index of the address.
+func (e *Service) transfer(userId, txId int64, address, symbol string, to string, value, price float64, protocol string, chain *types.Chain, allocation string) {
 
 	//This code is used to handle the panic situations in a program. The defer statement ensures that the function
 	//following it will be executed either when the function returns normally or when the function panics. In this code,
@@ -471,14 +121,13 @@ func (e *Service) transfer(userId, txId int64, symbol string, to string, value,
 	}()
 
 	// The code snippet creates several variables that are used later in the program. The variables are of various types,
-	// such as keypair.CrossChain, query.Migrate, float64, blockchain.Transfer, and big.Int. These variables are used to
-	// store data that will be needed throughout the program, such as fees, convert, transfer, and wei.
+	// such as float64, string and big.Int. These variables are used to store data that will be needed throughout the
+	// program, such as fees, convert, the contract of the token and the amount that is sent.
 	var (
-		cross                  keypair.CrossChain
 		fees, charges, convert float64
 		repayment              bool
-		transfer               *blockchain.Transfer
-		wei                    *big.Int
+		contract               string
+		amount                 *big.Int
 	)
 
 	// Creates a service provider to be used in the given context, providing the necessary services for the application.
@@ -486,157 +135,101 @@ func (e *Service) transfer(userId, txId int64, symbol string, to string, value,
 		Context: e.Context,
 	}
 
-	// Service is a struct which holds the context to be used by the account operations.
-	_account := account.Service{
-		Context: e.Context,
-	}
-
-	// This code is establishing a connection between a client and a blockchain. The blockchain.Dial() function is used to
-	// create a new connection and returns a client instance and an error. The chain.GetRpc() and chain.GetPlatform()
-	// functions are used to get the URL and platform (e.g. Ethereum) to which the client should connect. The if statement
-	// is then used to check for any errors and if there is an error, then the code will return.
-	client, err := blockchain.Dial(chain.GetRpc(), chain.GetPlatform())
-	if e.Context.Debug(err) {
+	// The transfer is built, signed and sent by the adapter of the platform of the chain.
+	adapter, ok := platform.Lookup(chain.GetPlatform())
+	if !ok {
+		e.Context.Debug(fmt.Errorf("the transfers of the platform %v are not sent", chain.GetPlatform()))
 		return
 	}
 
-	// The purpose of this code is to retrieve entropy for a given userId and then check for errors using the
-	// Context.Debug() function. If an error is found, the function returns.
-	entropy, err := _account.QueryEntropy(userId)
-	if e.Context.Debug(err) {
-		return
-	}
-
-	// This code is creating a new owner object using the cross package. The fmt package is used to format the
-	// e.Context.Secrets[1] into a specific string format. The entropy and chain.GetPlatform() parameters are also passed to
-	// the cross.New function. The code is checking for any errors with the new owner object and if there is an error, the code is returning.
-	owner, private, err := cross.New(fmt.Sprintf("%v-&*39~763@)", e.Context.Secrets[1]), entropy, chain.GetPlatform())
+	// This code is establishing a connection between a client and the node of the chain. The adapter.Dial() function is
+	// used to create a new connection and returns a client instance and an error. If there is an error, then the code will return.
+	client, err := adapter.Dial(chain.GetRpc())
 	if e.Context.Debug(err) {
 		return
 	}
 
-	// This code is used to convert a hexadecimal-encoded private key into an ECDSA (Elliptic Curve Digital Signature
-	// Algorithm) private key. The strings.TrimPrefix function is used to remove the "0x" prefix from the private key string
-	// before it is converted. If there is an error during the conversion, to err variable is set to indicate this and the
-	// code execution is stopped by returning from the function.
-	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(private, "0x"))
+	// The key of the reserve the funds are sent from, the transfer is signed with it.
+	key, err := e.queryKey(chain, adapter, userId, txId, address)
 	if e.Context.Debug(err) {
 		return
 	}
-
-	// client.Private(privateKey) is used to create a new account associated with the provided private key. This is used to
-	// access the account using the private key to sign transactions and messages from the client.
-	client.Private(privateKey)
-
-	// This line of code sets the network of the client to the network of the chain. This allows the client to communicate
-	// with the chain and make requests to it.
-	client.Network(chain.GetNetwork())
+	owner := key.Address
 
 	// This is a conditional statement that checks if the protocol is equal to the constant Protocol_MAINNET which is
 	// defined in the types package. If the protocol is equal to Protocol_MAINNET, then the code inside the if statement will be executed.
 	if protocol == types.ProtocolMainnet {
 
-		// The purpose of this code is to create a Transfer variable which will store information about a transaction on a
-		// blockchain. The variable will store the address of the recipient (To), the amount of gas required for the
-		// transaction (Gas), and the value of the transaction in the smallest denomination of the cryptocurrency (Value).
-		transfer = &blockchain.Transfer{
-			To:    to,
-			Value: decimal.New(value).Integer(chain.GetDecimals()),
-		}
-
-		// EstimateGas is a function used to estimate the gas required to execute a given transaction. The code snippet is
-		// attempting to estimate the gas required for the transaction "transfer" and checks for errors. If an error is found,
-		// it will be logged in the context and the function will return.
-		estimate, err := client.EstimateGas(transfer)
+		// The cost of the transfer of the coin is estimated with the value of the transaction in the smallest denomination of
+		// the cryptocurrency. If an error is found, it will be logged in the context and the function will return.
+		estimate, err := client.Estimate(key, to, "", decimal.New(value).Integer(chain.GetDecimals()))
 		if e.Context.Debug(err) {
 			return
 		}
 
-		// The purpose of this code is to create a new decimal value with 18 decimal places of precision, based on the
+		// The purpose of this code is to create a new decimal value with the decimal places of the chain, based on the
 		// `estimate` value that was provided. It is done using the `decimal.New()` and `Floating()` functions from the decimal library.
 		fees = decimal.New(estimate).Floating(chain.GetDecimals())
 
-		// This code is used to calculate the number of wei (a unit of account used in the Ethereum blockchain) associated with
-		// a transaction. If the user subscribes, the amount of wei is calculated by subtracting the fees from the value. If
-		// the user does not subscribe, the amount of wei is simply the value.
+		// This code is used to calculate the amount associated with a transaction. If the withdrawal is external, the amount
+		// is calculated by subtracting the fees from the value. If the withdrawal is internal, the amount is simply the value.
 		switch allocation {
 		case types.AllocationExternal:
-			wei = decimal.New(decimal.New(value).Sub(fees).Float()).Integer(chain.GetDecimals())
+			amount = decimal.New(decimal.New(value).Sub(fees).Float()).Integer(chain.GetDecimals())
 		case types.AllocationInternal:
-			wei = decimal.New(value).Integer(chain.GetDecimals())
+			amount = decimal.New(value).Integer(chain.GetDecimals())
 		}
 
-		// The purpose of this line of code is to assign a value of wei to the transfer variable. Wei is a unit of measurement
-		// used in the Ethereum blockchain to represent a tiny fraction of Ether (the cryptocurrency).
-		transfer.Value = wei
 	} else {
 
-		// This code is checking for errors from the getContract() function, which is presumably retrieving a contract from a
+		// This code is checking for errors from the QueryContract() function, which is retrieving a contract from a
 		// chain. If the function returns an error, the code will print the error and then exit the function.
-		contract, err := _provider.QueryContract(symbol, chain.GetId())
+		token, err := _provider.QueryContract(symbol, chain.GetId())
 		if e.Context.Debug(err) {
 			return
 		}
+		contract = token.GetAddress()
 
-		// This code is used to send a given amount of tokens to a given address. The data variable is used to store the
-		// transaction data, which is calculated by converting the given value to an integer, taking into account the decimal
-		// places of the contract. To err variable is used to store any errors that occur in the process. If an error occurs, the function returns.
-		data, err := client.Data(to, decimal.New(value).Integer(contract.GetDecimals()).Bytes())
-		if err != nil {
-			return
-		}
-
-		// The purpose of the above code is to create a blockchain transfer with the given contract address, gas value, and
-		// data. The transfer will be used to execute a transaction on the blockchain.
-		transfer = &blockchain.Transfer{
-			Contract: contract.GetAddress(),
-			Data:     data,
-		}
-
-		// EstimateGas is used to estimate the gas required for a given transaction. In this example, the estimate is stored in
-		// the variable estimate and then an error check is done with the Context.Debug function. If there is an error, the function returns.
-		estimate, err := client.EstimateGas(transfer)
+		// The cost of the transfer of the token is estimated with the given value converted to an integer, taking into
+		// account the decimal places of the contract. If there is an error, the function returns.
+		estimate, err := client.Estimate(key, to, contract, decimal.New(value).Integer(token.GetDecimals()))
 		if e.Context.Debug(err) {
 			return
 		}
 
-		// The purpose of this line of code is to convert a decimal value to a floating-point value with 18 decimal places. The
-		// decimal.New() function takes an estimate as an argument and the Floating() method is used to convert the value to a
-		// floating-point type with 18 decimal places.
+		// The purpose of this line of code is to convert the estimate to a floating-point value with the decimal places of
+		// the chain.
 		fees = decimal.New(estimate).Floating(chain.GetDecimals())
 
 		// The purpose of this code is to calculate the total cost of a product by multiplying the fees and the price together,
 		// and then converting the result to a floating-point number.
 		convert = decimal.New(fees).Mul(price).Float()
 
-		// This code is used to transfer a given value from one address to another, using the client Data() method. The value
-		// is first converted from a decimal to a float and then from a float to an integer, before being sent to the contract
-		// in the form of bytes. If there is an error, the code returns.
-		data, err = client.Data(to, decimal.New(decimal.New(value).Sub(convert).Float()).Integer(contract.GetDecimals()).Bytes())
-		if err != nil {
-			return
-		}
-
-		// The purpose of the statement "transfer.Data = data" is to assign the value of the variable "data" to the "Data"
-		// property of the "transfer" object.
-		transfer.Data = data
+		// The fees converted to the token are deducted from the amount of the token that is sent.
+		amount = decimal.New(decimal.New(value).Sub(convert).Float()).Integer(token.GetDecimals())
 	}
 
-	// This code is used to transfer funds from one account to another. The first line creates a hash which is used to
-	// identify the transfer. The second line checks for errors with the transfer. If there is an error, the function will
-	// return and the transfer will not be completed.
-	hash, err := client.Transfer(transfer)
-	if e.transferError(txId, userId, symbol, chain.GetPlatform(), protocol, err) {
-		return
+	// This code is used to transfer funds from one account to another. The transfer is signed and broadcast by the client
+	// and the hash of the transaction is returned. If there is an error, the function will return and the transfer will
+	// not be completed.
+	// The broadcast is recorded as a trace of its own, the requests of the node are its children.
+	ctx, span := trace.Start(context.Background(), "withdrawal.broadcast", trace.KindInternal, trace.Int("transaction.id", txId), trace.String("transaction.symbol", symbol), trace.String("transaction.platform", chain.GetPlatform()))
+
+	start := time.Now()
+	hash, err := client.Send(ctx, key, to, contract, amount)
+
+	// The duration of the broadcast is observed by the result of it, the failed broadcasts are often the timeouts of the nodes.
+	result := "success"
+	if err != nil {
+		result = "failed"
 	}
+	broadcast.Observe(time.Since(start).Seconds(), chain.GetPlatform(), result)
 
-	// The purpose of this code is to check for any errors that may have occurred in the client.Transaction() function, and
-	// if an error is found, to transfer it to the e.transferError() function for further processing. The e.transferError()
-	// function takes several parameters such as txId, userId, symbol, chain.GetPlatform(), and protocol, which are all
-	// required to process the error. If an error is found, the return statement will stop the code from further execution.
-	if err = client.Transaction(); e.transferError(txId, userId, symbol, chain.GetPlatform(), protocol, err) {
+	if e.transferError(txId, userId, symbol, chain.GetPlatform(), protocol, err) {
+		span.End(err)
 		return
 	}
+	span.End(nil)
 
 	// This is an if statement used to determine which protocol should be used. In this case, it is checking if the
 	// protocol is set to the mainnet protocol. If it is, then the code within the statement will be executed. If it is
@@ -709,7 +302,9 @@ func (e *Service) transfer(userId, txId int64, symbol string, to string, value,
 	// This code is executing an SQL statement to update the transactions table. It is setting the repayment, fees, hash, and status
 	// of a transaction with a specific ID. The e.Context.Debug(err) line is used to check for any errors that may have
 	// occurred during the update and, if any errors are found, the function will return.
-	if _, err := e.Context.Db.Exec("update transactions set repayment = $5, fees = $4, hash = $3, status = $2 where id = $1;", txId, types.StatusFilled, hash, fees, repayment); e.Context.Debug(err) {
+	// The time of the broadcast is kept until the withdrawal is mined, the withdrawal that waits longer than the sla of the
+	// chain is sent again at a higher price of the gas.
+	if _, err := e.Context.Db.Exec("update transactions set repayment = $5, fees = $4, hash = $3, status = $2, broadcast_at = now() where id = $1;", txId, types.StatusFilled, hash, fees, repayment); e.Context.Debug(err) {
 		return
 	}
 
@@ -717,6 +312,11 @@ func (e *Service) transfer(userId, txId int64, symbol string, to string, value,
 	// If it is, then some additional code will be executed.
 	if allocation == types.AllocationExternal {
 
+		// The withdrawal has left the exchange, the quantity held for it is spent from the balance of the user.
+		if err := _provider.SettleHold(types.HoldWithdrawal, txId); e.Context.Debug(err) {
+			return
+		}
+
 		// This piece of code is used to publish a transaction message on a message broker. The message contains the
 		// transaction ID, fees, and hash. The message is sent to the exchange topic with the label "withdraw/status". The code
 		// also checks for an error and returns if there is one.
@@ -784,8 +384,731 @@ func (e *Service) transferError(id, userId int64, symbol, platform, protocol str
 			return true
 		}
 
+		// The failed withdrawal of a priority account is routed to the operators as a support alert.
+		if err := _provider.WriteSupport(userId, types.SupportWithdrawal, id); e.Context.Debug(err) {
+			return true
+		}
+
 		return true
 	}
 
 	return false
 }
+
+// queryKey - This function returns the key of the address of the reserve of the user on the chain, the transfers of the reserve
+// are signed with it. The key is derived from the secret of the exchange and the entropy of the user, the transfers of
+// the chain with an external signing service configured are signed by the service, the request and the response of every
+// signature are written to the audit of the signatures with the transaction. The other chains fall back to the software
+// signer, the transfers are signed with the derived key in the memory of the process.
+func (e *Service) queryKey(chain *types.Chain, adapter platform.Platform, userId, txId int64, address string) (*platform.Key, error) {
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	// Service is a struct which holds the context to be used by the account operations.
+	_account := account.Service{
+		Context: e.Context,
+	}
+
+	entropy, err := _account.QueryEntropy(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, private, err := adapter.Derive(fmt.Sprintf("%v-&*39~763@)", e.Context.Secrets[1]), entropy, _provider.QueryDerivation(address, chain.GetPlatform()))
+	if err != nil {
+		return nil, err
+	}
+
+	key := &platform.Key{
+		Address: owner,
+		Private: private,
+		Network: chain.GetNetwork(),
+	}
+
+	if signer := e.Context.Signer(chain.GetId()); signer != nil {
+		key.Signer = &platform.Signer{
+			Endpoint: signer.Endpoint,
+			Token:    signer.Token,
+			Audit: func(request, response []byte, err error) {
+				e.writeSignature(chain.GetId(), txId, owner, signer.Endpoint, request, response, err)
+			},
+		}
+	}
+
+	return key, nil
+}
+
+// announced - The type announced struct is the head of the chain streamed by the subscription of the chain and the time it was
+// announced at, the head is trusted while it is fresh.
+type announced struct {
+	head *platform.Head
+	at   time.Time
+}
+
+// stale - The time the head streamed by the subscription of the chain is trusted for, the scanner of the chain whose subscription
+// was silent longer asks the node for the head again, as it does without the subscription.
+const stale = time.Minute
+
+// follow - This function subscribes to the heads of the chain with the websocket endpoint of the stream of the chain, once per
+// chain, when the adapter of the platform of the chain is the subscriber. The lost subscription is dialed again after
+// a pause with the stream the chain has then, the scanner of the chain polls the head of the chain meanwhile. The chain
+// that was turned off or lost its stream is no longer followed.
+func (e *Service) follow(chain *types.Chain) {
+
+	if len(chain.GetStream()) == 0 {
+		return
+	}
+
+	adapter, ok := platform.Lookup(chain.GetPlatform())
+	if !ok {
+		return
+	}
+
+	subscriber, ok := adapter.(platform.Subscriber)
+	if !ok {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.following[chain.GetId()] {
+		return
+	}
+	e.following[chain.GetId()] = true
+
+	go func(id int64, name, stream string) {
+
+		// Creates a service provider to be used in the given context, providing the necessary services for the application.
+		_provider := provider.Service{
+			Context: e.Context,
+		}
+
+		for len(stream) > 0 {
+
+			heads, errs := make(chan *platform.Head, 16), make(chan error, 1)
+			go func() {
+				errs <- subscriber.Subscribe(context.Background(), stream, heads)
+			}()
+
+		subscription:
+			for {
+				select {
+				case item := <-heads:
+					e.announce(id, item)
+				case err := <-errs:
+					e.Context.Logger.Warnf("[STREAM]: the subscription of the chain %v is lost, the chain is polled: %v", name, err)
+					break subscription
+				}
+			}
+
+			e.mutex.Lock()
+			delete(e.heads, id)
+			e.mutex.Unlock()
+
+			time.Sleep(10 * time.Second)
+
+			// The subscription is dialed again with the stream of the chain, the chain that was turned off is not followed.
+			if chain, err := _provider.QueryChain(id, true); err == nil {
+				stream = chain.GetStream()
+			} else {
+				stream = ""
+			}
+		}
+
+		e.mutex.Lock()
+		delete(e.following, id)
+		e.mutex.Unlock()
+
+	}(chain.GetId(), chain.GetName(), chain.GetStream())
+}
+
+// announce - This function records the head of the chain announced by the subscription, the head of the block the node announced
+// again after the reorganization of the chain replaces the higher head.
+func (e *Service) announce(id int64, head *platform.Head) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.heads[id] = &announced{head: head, at: time.Now()}
+}
+
+// queryHead - This function returns the head of the chain: the head streamed by the subscription of the chain while it is fresh,
+// or else the head asked from the node of the chain, as the scanner of the chain without the subscription does.
+func (e *Service) queryHead(chain *types.Chain) (head int64, streamed bool, err error) {
+
+	e.mutex.Lock()
+	item, ok := e.heads[chain.GetId()]
+	e.mutex.Unlock()
+
+	if ok && time.Since(item.at) < stale {
+		return item.head.Number, true, nil
+	}
+
+	client, err := platform.Dial(chain.GetRpc(), chain.GetPlatform())
+	if err != nil {
+		return 0, false, err
+	}
+
+	head, err = client.Head()
+	return head, false, err
+}
+
+const (
+	// probeTimeout - The time the endpoint of the chain is given to report its head, the endpoint that does not answer in time is
+	// unhealthy for the check.
+	probeTimeout = 10 * time.Second
+
+	// headTolerance - The number of the blocks the endpoint may be behind the highest head of the pool of its chain and still be
+	// healthy, the nodes do not import the blocks at the same moment.
+	headTolerance = 3
+)
+
+// failover - This function checks the endpoints of the pools of the chains and switches the chains whose node failed or fell
+// behind to the healthy endpoint with the lowest latency. The endpoints are not switched in the sandbox mode, whose
+// chains are connected to the nodes of the testnets by the configuration.
+func (e *Service) failover() {
+
+	defer func() {
+		if r := recover(); e.Context.Debug(r) {
+			return
+		}
+	}()
+
+	if e.Context.Sandbox != nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second * 15)
+	for range ticker.C {
+
+		rows, err := e.Context.Db.Query("select id, name, rpc, platform from chains where status = true")
+		if e.Context.Debug(err) {
+			continue
+		}
+
+		var (
+			chains []*types.Chain
+		)
+
+		for rows.Next() {
+
+			var (
+				item types.Chain
+			)
+
+			if err = rows.Scan(&item.Id, &item.Name, &item.Rpc, &item.Platform); e.Context.Debug(err) {
+				continue
+			}
+
+			chains = append(chains, &item)
+		}
+		_ = rows.Close()
+
+		for _, chain := range chains {
+			e.elect(chain, e.probe(chain))
+		}
+	}
+}
+
+// probe - This function asks every enabled endpoint of the pool of the chain for its head at the same time, and records the head,
+// the latency of the answer and the failures in a row of every endpoint. The healthy endpoints are those that answered
+// in time and are no more than the tolerance behind the highest head of the pool.
+func (e *Service) probe(chain *types.Chain) (endpoints []*types.Endpoint) {
+
+	rows, err := e.Context.Db.Query("select id, rpc from chain_endpoints where chain_id = $1 and status = true order by id", chain.GetId())
+	if e.Context.Debug(err) {
+		return nil
+	}
+
+	for rows.Next() {
+
+		var (
+			item = types.Endpoint{ChainId: chain.GetId(), Status: true}
+		)
+
+		if err = rows.Scan(&item.Id, &item.Rpc); e.Context.Debug(err) {
+			continue
+		}
+		item.Active = item.GetRpc() == chain.GetRpc()
+
+		endpoints = append(endpoints, &item)
+	}
+	_ = rows.Close()
+
+	var (
+		wg   sync.WaitGroup
+		high int64
+	)
+
+	for _, item := range endpoints {
+		wg.Add(1)
+
+		go func(item *types.Endpoint) {
+			defer wg.Done()
+
+			type answer struct {
+				head int64
+				err  error
+			}
+
+			start, result := time.Now(), make(chan answer, 1)
+			go func() {
+				client, err := platform.Dial(item.GetRpc(), chain.GetPlatform())
+				if err != nil {
+					result <- answer{err: err}
+					return
+				}
+				head, err := client.Head()
+				result <- answer{head: head, err: err}
+			}()
+
+			select {
+			case reply := <-result:
+				item.Head, item.Latency = reply.head, time.Since(start).Milliseconds()
+				if reply.err != nil {
+					item.Error = reply.err.Error()
+				}
+			case <-time.After(probeTimeout):
+				item.Error = fmt.Sprintf("the endpoint did not answer in %v", probeTimeout)
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	for _, item := range endpoints {
+		if len(item.GetError()) == 0 && item.GetHead() > high {
+			high = item.GetHead()
+		}
+	}
+
+	for _, item := range endpoints {
+
+		item.Healthy = len(item.GetError()) == 0 && item.GetHead() >= high-headTolerance
+		if !item.GetHealthy() && len(item.GetError()) == 0 {
+			item.Error = fmt.Sprintf("the endpoint is %v blocks behind the head %v", high-item.GetHead(), high)
+		}
+
+		if _, err := e.Context.Db.Exec("update chain_endpoints set healthy = $2, head = $3, latency = $4, failures = case when $2 then 0 else failures + 1 end, error = $5, check_at = now() where id = $1", item.GetId(), item.GetHealthy(), item.GetHead(), item.GetLatency(), item.GetError()); e.Context.Debug(err) {
+			continue
+		}
+	}
+
+	return endpoints
+}
+
+// elect - This function keeps the chain on its endpoint while the endpoint is healthy, and otherwise switches the chain to the
+// healthy endpoint with the lowest latency, so that the chains do not flap between the endpoints that are all healthy.
+// The operators are alerted of the switch, and of the chain none of whose endpoints is healthy.
+func (e *Service) elect(chain *types.Chain, endpoints []*types.Endpoint) {
+
+	var (
+		elected *types.Endpoint
+	)
+
+	for _, item := range endpoints {
+
+		if !item.GetHealthy() {
+			continue
+		}
+
+		if item.GetActive() {
+			return
+		}
+
+		if elected == nil || item.GetLatency() < elected.GetLatency() {
+			elected = item
+		}
+	}
+
+	if elected == nil {
+		if len(endpoints) > 0 {
+			e.heartbeat(chain, types.ComponentDegraded, "none of the endpoints of the chain is healthy", 0)
+		}
+		return
+	}
+
+	if _, err := e.Context.Db.Exec("update chains set rpc = $2 where id = $1 and rpc = $3", chain.GetId(), elected.GetRpc(), chain.GetRpc()); e.Context.Debug(err) {
+		return
+	}
+	elected.Active = true
+
+	e.Context.Logger.Warnf("[FAILOVER]: the chain %v is switched from the endpoint %v to the endpoint %v", chain.GetName(), chain.GetRpc(), elected.GetRpc())
+	e.heartbeat(chain, types.ComponentDegraded, fmt.Sprintf("the chain is switched to the endpoint %v", elected.GetId()), 0)
+
+	if err := e.Context.Publish(elected, "exchange", "support/failover"); e.Context.Debug(err) {
+		return
+	}
+}
+
+// broadcasted - The type broadcasted struct is the withdrawal sent to the chain and not mined yet: the hashes of its transactions,
+// the one it waits with and those it was sent with before, any of which may be mined, the kind of the replacement each
+// of them was sent by, and whether it waits longer than the sla of its chain.
+type broadcasted struct {
+	item   types.Transaction
+	hashes map[string]string
+	stuck  bool
+}
+
+// replacement - This function watches the withdrawals sent to the chains with the sla until they are mined. The withdrawal that
+// waits longer than the sla of its chain is stuck: the operators are alerted of it once, and it is sent again with the
+// same nonce at a price of the gas an eighth higher, up to the price cap of the chain. The replacements and the
+// cancellations the operators asked for are sent as well.
+func (e *Service) replacement() {
+
+	defer func() {
+		if r := recover(); e.Context.Debug(r) {
+			return
+		}
+	}()
+
+	var (
+		reported = make(map[int64]bool)
+	)
+
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		rows, err := e.Context.Db.Query(`select t.id, t.hash, t.chain_id, t.user_id, t.symbol, t.value, t.broadcast_at < now() - make_interval(mins => c.sla) from transactions t inner join chains c on c.id = t.chain_id where t.broadcast_at is not null and t.assignment = $1 and t.status = $2 and c.sla > 0 order by t.chain_id, t.id`, types.AssignmentWithdrawal, types.StatusFilled)
+		if e.Context.Debug(err) {
+			continue
+		}
+
+		var (
+			waiting []*broadcasted
+		)
+
+		for rows.Next() {
+
+			var (
+				item = broadcasted{hashes: make(map[string]string)}
+			)
+
+			if err = rows.Scan(&item.item.Id, &item.item.Hash, &item.item.ChainId, &item.item.UserId, &item.item.Symbol, &item.item.Value, &item.stuck); e.Context.Debug(err) {
+				continue
+			}
+			item.hashes[item.item.GetHash()] = ""
+
+			waiting = append(waiting, &item)
+		}
+		_ = rows.Close()
+
+		for _, item := range waiting {
+
+			// The transactions the withdrawal was sent with before are mined in place of the last one, when their price was enough.
+			if rows, err := e.Context.Db.Query("select hash, replacement, kind from replacements where transaction_id = $1 and status = $2", item.item.GetId(), types.StatusProcessing); !e.Context.Debug(err) {
+				for rows.Next() {
+					var hash, replacement, kind string
+					if err = rows.Scan(&hash, &replacement, &kind); e.Context.Debug(err) {
+						continue
+					}
+					if _, ok := item.hashes[hash]; !ok {
+						item.hashes[hash] = ""
+					}
+					item.hashes[replacement] = kind
+				}
+				_ = rows.Close()
+			}
+
+			e.unstick(item, reported)
+		}
+
+		e.replace()
+	}
+}
+
+// unstick - This function checks whether any transaction of the withdrawal was mined, the withdrawal is then no longer watched.
+// The withdrawal whose transaction was not mined within the sla of its chain is alerted of and sent again at a higher
+// price, as long as the price cap of the chain allows it.
+func (e *Service) unstick(item *broadcasted, reported map[int64]bool) {
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	chain, err := _provider.QueryChain(item.item.GetChainId(), true)
+	if e.Context.Debug(err) {
+		return
+	}
+
+	client, err := platform.Dial(chain.GetRpc(), chain.GetPlatform())
+	if e.Context.Debug(err) {
+		return
+	}
+
+	for hash, kind := range item.hashes {
+
+		// The transaction of the platform whose chains reorganize is mined once it is in a block of the canonical chain, the
+		// transactions of the other platforms once they are confirmed.
+		mined := false
+		if canonical, ok := client.(platform.Canonical); ok {
+			block, err := canonical.Locate(hash)
+			mined = err == nil && block > 0
+		} else {
+			mined = client.Status(hash)
+		}
+
+		if mined {
+			delete(reported, item.item.GetId())
+			e.writeMined(item, hash, kind)
+			return
+		}
+	}
+
+	if !item.stuck {
+		return
+	}
+
+	if !reported[item.item.GetId()] {
+		reported[item.item.GetId()] = true
+
+		e.Context.Logger.Warnf("[STUCK]: the withdrawal %v of %v %v on the chain %v waits to be mined longer than %v minutes with the transaction %v", item.item.GetId(), item.item.GetValue(), item.item.GetSymbol(), chain.GetName(), chain.GetSla(), item.item.GetHash())
+		e.heartbeat(chain, types.ComponentDegraded, fmt.Sprintf("the withdrawal %v waits to be mined longer than %v minutes", item.item.GetId(), chain.GetSla()), 0)
+
+		if err := e.Context.Publish(&item.item, "exchange", "support/stuck"); e.Context.Debug(err) {
+			return
+		}
+
+		if err := _provider.WriteSupport(item.item.GetUserId(), types.SupportStuck, item.item.GetId()); e.Context.Debug(err) {
+			return
+		}
+	}
+
+	// The withdrawals of the chain without the price cap are only reported, the operators replace or cancel them.
+	if chain.GetPriceCap() <= 0 {
+		return
+	}
+
+	replacer, ok := client.(platform.Replacer)
+	if !ok {
+		return
+	}
+
+	_, price, waiting, err := replacer.Pending(item.item.GetHash())
+	if e.Context.Debug(err) || !waiting {
+		return
+	}
+
+	// The node replaces the pending transaction with the one whose price is at least a tenth higher, the price is raised by an
+	// eighth, and to the cap at most. The withdrawal at the cap waits for the operators.
+	next, ceiling := bump(price), decimal.New(chain.GetPriceCap()).Integer(9)
+	if next.Cmp(ceiling) > 0 {
+		next = ceiling
+	}
+
+	if new(big.Int).Mul(next, big.NewInt(10)).Cmp(new(big.Int).Mul(price, big.NewInt(11))) < 0 {
+		return
+	}
+
+	e.writeReplacement(chain, replacer, &types.Replacement{
+		TransactionId: item.item.GetId(),
+		ChainId:       chain.GetId(),
+		Kind:          types.ReplacementBump,
+		Hash:          item.item.GetHash(),
+		Price:         decimal.New(next).Floating(9),
+		Reason:        fmt.Sprintf("the withdrawal waits to be mined longer than %v minutes", chain.GetSla()),
+	})
+}
+
+// bump - This function returns the price of the gas an eighth higher than the price, the node accepts the replacement whose price
+// is at least a tenth higher.
+func bump(price *big.Int) *big.Int {
+	return new(big.Int).Add(new(big.Int).Div(new(big.Int).Mul(price, big.NewInt(9)), big.NewInt(8)), big.NewInt(1))
+}
+
+// replace - This function sends the replacements and the cancellations of the stuck withdrawals the operators asked for, in the
+// order they were asked for. The replacement without the price is sent at the price an eighth higher than the price of
+// the transaction it replaces, the price cap of the chain does not limit the operators.
+func (e *Service) replace() {
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	rows, err := e.Context.Db.Query("select r.id, r.transaction_id, r.chain_id, r.kind, t.hash, r.price, r.user_id, r.reason from replacements r inner join transactions t on t.id = r.transaction_id where r.status = $1 order by r.id", types.StatusPending)
+	if e.Context.Debug(err) {
+		return
+	}
+
+	var (
+		requested []*types.Replacement
+	)
+
+	for rows.Next() {
+
+		var (
+			item types.Replacement
+		)
+
+		if err = rows.Scan(&item.Id, &item.TransactionId, &item.ChainId, &item.Kind, &item.Hash, &item.Price, &item.UserId, &item.Reason); e.Context.Debug(err) {
+			continue
+		}
+
+		requested = append(requested, &item)
+	}
+	_ = rows.Close()
+
+	for _, item := range requested {
+
+		chain, err := _provider.QueryChain(item.GetChainId(), true)
+		if err != nil {
+			e.writeReplaced(item, "", err)
+			continue
+		}
+
+		client, err := platform.Dial(chain.GetRpc(), chain.GetPlatform())
+		if err != nil {
+			e.writeReplaced(item, "", err)
+			continue
+		}
+
+		replacer, ok := client.(platform.Replacer)
+		if !ok {
+			e.writeReplaced(item, "", fmt.Errorf("the transactions of the platform %v are not replaced", chain.GetPlatform()))
+			continue
+		}
+
+		if item.GetPrice() <= 0 {
+
+			_, price, _, err := replacer.Pending(item.GetHash())
+			if err != nil {
+				e.writeReplaced(item, "", err)
+				continue
+			}
+			item.Price = decimal.New(bump(price)).Floating(9)
+		}
+
+		e.writeReplacement(chain, replacer, item)
+	}
+}
+
+// writeReplacement - This function sends the replacement of the transaction the withdrawal waits with, signed with the key of the
+// reserve that sent the withdrawal, and records it: the withdrawal then waits with the transaction of the replacement, and
+// the time of its broadcast starts again. The replacement that is not sent is recorded with the error.
+func (e *Service) writeReplacement(chain *types.Chain, replacer platform.Replacer, item *types.Replacement) {
+
+	adapter, ok := platform.Lookup(chain.GetPlatform())
+	if !ok {
+		e.writeReplaced(item, "", fmt.Errorf("the transfers of the platform %v are not sent", chain.GetPlatform()))
+		return
+	}
+
+	from, _, waiting, err := replacer.Pending(item.GetHash())
+	if err != nil {
+		e.writeReplaced(item, "", err)
+		return
+	}
+
+	if !waiting {
+		e.writeReplaced(item, "", fmt.Errorf("the transaction %v is already mined", item.GetHash()))
+		return
+	}
+
+	var (
+		userId int64
+	)
+
+	// The reserve that sent the withdrawal signs its replacement, the nonce of the transaction is the nonce of its address.
+	if err := e.Context.Db.QueryRow("select user_id from reserves where address = $1 and platform = $2 limit 1", adapter.Format(from), chain.GetPlatform()).Scan(&userId); err != nil {
+		e.writeReplaced(item, "", fmt.Errorf("the reserve of the address %v is not found", from))
+		return
+	}
+
+	key, err := e.queryKey(chain, adapter, userId, item.GetTransactionId(), from)
+	if err != nil {
+		e.writeReplaced(item, "", err)
+		return
+	}
+
+	ctx, span := trace.Start(context.Background(), "withdrawal.replace", trace.KindInternal, trace.Int("transaction.id", item.GetTransactionId()), trace.String("replacement.kind", item.GetKind()), trace.String("transaction.platform", chain.GetPlatform()))
+
+	hash, err := replacer.Replace(ctx, key, item.GetHash(), decimal.New(item.GetPrice()).Integer(9), item.GetKind() == types.ReplacementCancel)
+	span.End(err)
+
+	e.writeReplaced(item, hash, err)
+}
+
+// writeReplaced - This function records the replacement sent with the hash, or not sent with the error. The replacement sent by
+// the exchange itself is recorded when it is sent, the one the operators asked for was recorded when they asked for it.
+func (e *Service) writeReplaced(item *types.Replacement, hash string, err error) {
+
+	item.Replacement, item.Status = hash, types.StatusProcessing
+	if err != nil {
+		item.Error, item.Status = err.Error(), types.StatusFailed
+	}
+
+	if item.GetId() > 0 {
+		if _, err := e.Context.Db.Exec("update replacements set replacement = $2, price = $3, error = $4, status = $5, hash = $6, update_at = now() where id = $1", item.GetId(), item.GetReplacement(), item.GetPrice(), item.GetError(), item.GetStatus(), item.GetHash()); e.Context.Debug(err) {
+			return
+		}
+	} else {
+		if err := e.Context.Db.QueryRow("insert into replacements (transaction_id, chain_id, kind, hash, replacement, price, reason, error, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id", item.GetTransactionId(), item.GetChainId(), item.GetKind(), item.GetHash(), item.GetReplacement(), item.GetPrice(), item.GetReason(), item.GetError(), item.GetStatus()).Scan(&item.Id); e.Context.Debug(err) {
+			return
+		}
+	}
+
+	if err != nil {
+		e.Context.Logger.Warnf("[STUCK]: the %v of the transaction %v of the withdrawal %v is not sent: %v", item.GetKind(), item.GetHash(), item.GetTransactionId(), err)
+		return
+	}
+
+	if _, err := e.Context.Db.Exec("update transactions set hash = $2, broadcast_at = now() where id = $1", item.GetTransactionId(), hash); e.Context.Debug(err) {
+		return
+	}
+
+	if err := e.Context.Publish(item, "exchange", "support/stuck"); e.Context.Debug(err) {
+		return
+	}
+}
+
+// writeMined - This function records the transaction of the withdrawal that was mined: the withdrawal is no longer watched, its
+// hash is the hash of the mined transaction, and the replacements of the withdrawal are filled or cancelled by whether
+// they were mined. The withdrawal whose cancellation was mined did not leave the exchange, it is failed and its value is
+// returned to the balance of the user.
+func (e *Service) writeMined(item *broadcasted, hash, kind string) {
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	if _, err := e.Context.Db.Exec("update replacements set status = case when replacement = $2 then $3 else $4 end, update_at = now() where transaction_id = $1 and status = $5", item.item.GetId(), hash, types.StatusFilled, types.StatusCancel, types.StatusProcessing); e.Context.Debug(err) {
+		return
+	}
+
+	if kind != types.ReplacementCancel {
+		if _, err := e.Context.Db.Exec("update transactions set hash = $2, broadcast_at = null where id = $1", item.item.GetId(), hash); e.Context.Debug(err) {
+			return
+		}
+		return
+	}
+
+	// The cancellation is recorded once, the value of the withdrawal is returned by the one that failed it.
+	result, err := e.Context.Db.Exec("update transactions set hash = $2, broadcast_at = null, status = $3, error = $4 where id = $1 and status = $5", item.item.GetId(), hash, types.StatusFailed, "the withdrawal was cancelled", types.StatusFilled)
+	if e.Context.Debug(err) {
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return
+	}
+
+	if err := _provider.WriteBalance(item.item.GetSymbol(), types.TypeSpot, item.item.GetUserId(), decimal.New(item.item.GetValue()), types.BalancePlus); e.Context.Debug(err) {
+		return
+	}
+
+	e.Context.Logger.Warnf("[STUCK]: the withdrawal %v of %v %v was cancelled by the transaction %v, the value is returned to the user %v and the reserve is left to the operators", item.item.GetId(), item.item.GetValue(), item.item.GetSymbol(), hash, item.item.GetUserId())
+
+	if err := e.Context.Publish(&types.Transaction{
+		Id:     item.item.GetId(),
+		Hash:   hash,
+		Status: types.StatusFailed,
+		Error:  "the withdrawal was cancelled",
+	}, "exchange", "withdraw/status"); e.Context.Debug(err) {
+		return
+	}
+}
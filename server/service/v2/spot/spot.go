@@ -1,20 +1,66 @@
 package spot
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/address"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
-	"google.golang.org/grpc/status"
+	"github.com/cryptogateway/backend-envoys/assets/common/i18n"
+	"github.com/cryptogateway/backend-envoys/assets/common/metrics"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
+	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbspot"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
 )
 
-// Service - The purpose of the Service struct is to store data related to a service, such as the Context, run and wait maps, and
-// the block map. The Context is a pointer to an assets Context, which contains information about the service. The run
-// and wait maps are booleans that indicate whether the service is running or waiting for an action. The block map is an
-// integer that stores the block number associated with a particular service.
+// maxWatches - The maximum number of the external addresses that a user can watch, the balance of every address is requested
+// from the node of its chain.
+const maxWatches = 20
+
+// reorgDepth - The number of the blocks the credited deposits are checked against the canonical chain for, the chains are not
+// reorganized deeper than that, and the reverted deposits are located again within it.
+const reorgDepth = 128
+
+// errNode - The error of the block that is not read because the node of the chain does not answer, the scanner reports the chain
+// as down with it.
+var errNode = errors.New("the node of the chain does not answer")
+
+var (
+	// detected and broadcast - The metrics of the chains: the deposits detected by the scanners by their platforms, the lag of
+	// the scanners, the coin of the gas reserves and their top-ups, and the duration of the broadcast of the withdrawals to
+	// the nodes by their platforms and results.
+	detected  = metrics.NewCounter("deposits_detected_total", "The number of the deposits detected by the scanners of the chains.", "platform", "symbol")
+	lag       = metrics.NewGauge("chain_scanner_lag_blocks", "The number of the blocks the scanners of the chains are behind the heads of the chains.", "chain")
+	fuel      = metrics.NewGauge("gas_reserve_balance", "The balance of the coin of the gas reserves of the chains.", "chain")
+	topups    = metrics.NewCounter("gas_topups_total", "The number of the top-ups of the hot wallets of the tokens from the gas reserves.", "chain")
+	broadcast = metrics.NewHistogram("withdrawal_broadcast_seconds", "The duration of the broadcast of the withdrawals to the nodes.", metrics.Buckets, "platform", "status")
+)
+
+// Service - The purpose of the Service struct is to store data related to a service, such as the Context and the run and wait maps.
+// The Context is a pointer to an assets Context, which contains information about the service. The run and wait maps are
+// booleans that indicate whether the scanner of a chain is running or waiting for an action. The block the scanner of
+// a chain reads next is the cursor kept with the chain in the database, so the scanning resumes where it stopped. The
+// heads are the heads of the chains streamed by their subscriptions, the following are the chains subscribed to, both
+// are guarded by the mutex, since the subscriptions run next to the scanners.
 type Service struct {
 	Context *assets.Context
 
 	run, wait map[int64]bool
-	block     map[int64]int64
+
+	mutex     sync.Mutex
+	heads     map[int64]*announced
+	following map[int64]bool
 }
 
 // Initialization - The code initializes a Service object and runs six concurrent functions: deposit(), withdrawal(), and reward().
@@ -22,6 +68,14 @@ func (e *Service) Initialization() {
 	go e.deposit()
 	go e.withdrawal()
 	go e.reward()
+	go e.balance()
+	go e.oracle()
+	go e.failover()
+	go e.replacement()
+	go e.distribution()
+	go e.competition()
+	go e.gas()
+	go e.rebalance()
 }
 
 // queryValidateWithdraw - This function is used to validate a withdrawal request. It checks to make sure that the requested withdrawal amount is
@@ -40,30 +94,214 @@ func (e *Service) queryValidateWithdrawal(quantity, reserve, balance, max, min,
 	// This code is used to check if the claimed amount is greater than the reserve. If it is, it will return an error
 	// message with status code 47784.
 	if quantity > reserve {
-		return status.Errorf(47784, "the claimed amount %v is greater than the reserve %v itself", quantity, reserve)
+		return i18n.Errorf(47784, "the claimed amount %v is greater than the reserve %v itself", quantity, reserve)
 	}
 
 	// This code checks if the requested quantity is more than the available balance. If it is greater than the balance, it
 	// returns an error message with the status code 48584. This prevents users from spending more money than they have.
 	if quantity > balance {
-		return status.Errorf(48584, "the claimed amount %v is more than what you have on your balance %v", quantity, balance)
+		return i18n.Errorf(48584, "the claimed amount %v is more than what you have on your balance %v", quantity, balance)
 	}
 
 	// This code checks if the quantity is less than the proportion and, if it is, it returns an error indicating that the
 	// withdrawal amount must not be less than the minimum amount.
 	if quantity < proportion {
-		return status.Errorf(48880, "the withdrawal amount %v must not be less than the minimum amount: %v", quantity, proportion)
+		return i18n.Errorf(48880, "the withdrawal amount %v must not be less than the minimum amount: %v", quantity, proportion)
 	}
 
 	// This code is used to check if the quantity declared for withdrawal is greater than the maximum allowed. If it is, an
 	// error is returned with an appropriate error message.
 	if quantity > max {
-		return status.Errorf(70083, "the amount %v declared for withdrawal should not be more than allowed %v", quantity, max)
+		return i18n.Errorf(70083, "the amount %v declared for withdrawal should not be more than allowed %v", quantity, max)
 	}
 
 	return nil
 }
 
+// queryHold - This function returns the reason the confirmed deposit is held for the review of the operators, or an empty string
+// if the deposit can be credited. The deposit is held when the operators have put it on hold while it was pending, when
+// it reaches the hold value of the asset on the chain, or when the screening service scores its risk at least the
+// configured score. A deposit the screening service could not score is held as well, and so is the deposit suspended
+// by the switches of the operators. The deposit from a verified source is held from the hold value multiplied by the
+// multiplier of the sources.
+func (e *Service) queryHold(item *types.Transaction, network *types.AssetChain) string {
+
+	if len(item.GetHold()) > 0 {
+		return item.GetHold()
+	}
+
+	// The deposits suspended by the operators for the whole exchange, for the asset or for the chain are held until the
+	// operators release them.
+	if err := e.Context.Feature(types.FeatureDeposit, assets.Scope{Kind: types.ScopeCurrency, Target: item.GetSymbol()}, assets.Scope{Kind: types.ScopeChain, Target: strconv.FormatInt(item.GetChainId(), 10)}); err != nil {
+		return types.HoldSuspended
+	}
+
+	hold := network.GetHold()
+	if item.GetVerified() && e.Context.Sources != nil {
+		hold = decimal.New(hold).Mul(e.Context.Sources.Multiplier).Float()
+	}
+
+	if hold > 0 && item.GetValue() >= hold {
+		return types.HoldAmount
+	}
+
+	if e.Context.Screening != nil && len(e.Context.Screening.Endpoint) > 0 {
+		if score, err := e.queryScreening(item); e.Context.Debug(err) || score >= e.Context.Screening.Score {
+			return types.HoldScreening
+		}
+	}
+
+	return ""
+}
+
+// querySource - This function reports whether the deposit comes from a verified source of the user, the external address on the
+// platform of the deposit the user proved to own and the compliance officers did not revoke.
+func (e *Service) querySource(item *types.Transaction) (verified bool) {
+
+	adapter, ok := platform.Lookup(item.GetPlatform())
+	if !ok || len(item.GetFrom()) == 0 {
+		return false
+	}
+
+	_ = e.Context.Db.QueryRow("select exists(select 1 from sources where user_id = $1 and platform = $2 and address = $3 and status = true)", item.GetUserId(), item.GetPlatform(), adapter.Format(item.GetFrom())).Scan(&verified)
+
+	return verified
+}
+
+// sourceMessage - This function returns the message the user signs by the wallet of the address to prove the ownership of it, the
+// message names the account the address becomes the source of and the nonce, so that it is signed for this claim only.
+func sourceMessage(userId int64, address, nonce string) string {
+	return fmt.Sprintf("I own the address %v and claim it as the verified source of the deposits to the account %v.\n\nNonce: %v", address, userId, nonce)
+}
+
+// queryScreening - This function requests the risk score of the deposit from the screening service, the service receives the
+// sender, the deposit address and the transaction of the deposit.
+func (e *Service) queryScreening(item *types.Transaction) (score float64, err error) {
+
+	serialize, err := json.Marshal(map[string]interface{}{
+		"platform": item.GetPlatform(),
+		"symbol":   item.GetSymbol(),
+		"hash":     item.GetHash(),
+		"from":     item.GetFrom(),
+		"to":       item.GetTo(),
+		"value":    item.GetValue(),
+	})
+	if err != nil {
+		return score, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.Context.Screening.Endpoint, bytes.NewBuffer(serialize))
+	if err != nil {
+		return score, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", e.Context.Screening.Token))
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return score, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return score, fmt.Errorf("the screening service responded with the status %v", resp.StatusCode)
+	}
+
+	var result struct {
+		Score float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return score, err
+	}
+
+	return result.Score, nil
+}
+
+// queryConfirmation - This function returns the number of the confirmations the deposit of the value has to wait for. The tiers
+// of the currency override the confirmation count of the chain: the tier with the highest value the deposit reaches is
+// taken, the tier of the chain takes precedence over the tier of every chain (the chain id 0) of the same value.
+func (e *Service) queryConfirmation(symbol string, chainId int64, value float64, confirmation int64) int64 {
+
+	var (
+		tier int64
+	)
+
+	if err := e.Context.Db.QueryRow("select confirmation from confirmations where symbol = $1 and (chain_id = $2 or chain_id = 0) and value <= $3 order by value desc, chain_id desc limit 1", symbol, chainId, value).Scan(&tier); err != nil {
+		return confirmation
+	}
+
+	return tier
+}
+
+// queryEstimate - This function returns the withdrawal fee estimated by the fee oracle for the chain in the units of the parent
+// asset of the chain, the cost of a token transfer is returned for the protocols other than mainnet. The estimates older
+// than ten minutes are ignored, since the oracle is not able to reach the node of the chain.
+func (e *Service) queryEstimate(chainId int64, protocol string) (fees float64, ok bool) {
+
+	column := "mainnet"
+	if protocol != types.ProtocolMainnet {
+		column = "token"
+	}
+
+	if err := e.Context.Db.QueryRow(fmt.Sprintf("select %v from fee_estimates where chain_id = $1 and create_at > now() - interval '10 minutes'", column), chainId).Scan(&fees); err != nil {
+		return 0, false
+	}
+
+	return fees, fees > 0
+}
+
+// queryFees - This function calculates the fee of the withdrawal of the asset on the chain. The static fee of the chain (or of
+// the contract for the tokens) is raised to the fee estimated by the fee oracle when the network is more expensive, the
+// override of the fee set for the asset on the chain takes precedence over both. The fee is returned both in the units of
+// the asset, which is charged to the user, and in the units of the parent asset, which is paid to the network.
+func (e *Service) queryFees(symbol string, chain *types.Chain, contract *types.Contract, network *types.AssetChain) (*types.FeeEstimate, error) {
+
+	var (
+		estimate = types.FeeEstimate{
+			ChainId:      chain.GetId(),
+			Symbol:       symbol,
+			ParentSymbol: chain.GetParentSymbol(),
+			Protocol:     contract.GetProtocol(),
+			NetworkFees:  chain.GetFees(),
+		}
+	)
+
+	// The fee of the token transfer is paid in the parent asset of the chain, it is converted into the units of the token by
+	// the price of the token.
+	if contract.GetProtocol() != types.ProtocolMainnet {
+
+		price, err := pbprovider.NewApiClient(e.Context.GrpcClient).GetPrice(context.Background(), &pbprovider.GetRequestPrice{
+			BaseUnit:  chain.GetParentSymbol(),
+			QuoteUnit: symbol,
+		})
+		if err != nil {
+			return &estimate, err
+		}
+		estimate.Price, estimate.NetworkFees = price.GetPrice(), contract.GetFees()
+	}
+
+	if fees, ok := e.queryEstimate(chain.GetId(), contract.GetProtocol()); ok && fees > estimate.NetworkFees {
+		estimate.NetworkFees, estimate.Dynamic = fees, true
+	}
+
+	estimate.Fees = estimate.NetworkFees
+	if contract.GetProtocol() != types.ProtocolMainnet {
+		estimate.Fees = decimal.New(estimate.NetworkFees).Mul(estimate.GetPrice()).Float()
+	}
+
+	// The withdrawal fee can be overridden for the asset on the chain, the override is set in the units of the asset, so for
+	// the tokens it is converted back into the parent asset of the chain, in which the fee of the transaction is recorded.
+	if network.GetFees() > 0 {
+		estimate.Fees, estimate.NetworkFees, estimate.Dynamic = network.GetFees(), network.GetFees(), false
+
+		if contract.GetProtocol() != types.ProtocolMainnet && estimate.GetPrice() > 0 {
+			estimate.NetworkFees = decimal.New(network.GetFees()).Div(estimate.GetPrice()).Float()
+		}
+	}
+
+	return &estimate, nil
+}
+
 // queryValidateInternal - This function is used to check if a given address is an internal asset. It queries the database to see if the address
 // exists in the wallets table, and if it does, it returns an error indicating that the address is an internal asset and
 // that another address should be used.
@@ -80,15 +318,707 @@ func (e *Service) queryValidateInternal(address string) error {
 	// This code is checking to see if an address exists, and if it does, it will return an error message. The error message
 	// tells the user that they cannot use the address as it is internal, and they should use another address.
 	if exist {
-		return status.Errorf(717883, "you cannot use this address %v, this address is internal, please use another address", address)
+		return i18n.Errorf(717883, "you cannot use this address %v, this address is internal, please use another address", address)
+	}
+
+	return nil
+}
+
+// queryInternal - This function returns the user to whom the deposit address belongs, when the address is a deposit address
+// (current or archived) of the exchange, otherwise 0 is returned.
+func (e *Service) queryInternal(address, platform string) (userId int64) {
+	_ = e.Context.Db.QueryRow("select user_id from wallets where lower(address) = lower($1) and platform = $2", address, platform).Scan(&userId)
+	return userId
+}
+
+// writeSignature - This function writes the request sent to the external signer of the hot wallet and the response (or the error)
+// returned by it to the audit of the signatures, the transfer id links the signature to the withdrawal it was requested for.
+func (e *Service) writeSignature(chainId, transferId int64, address, endpoint string, request, response []byte, err error) {
+
+	var (
+		message string
+	)
+
+	if err != nil {
+		message = err.Error()
+	}
+
+	if _, err := e.Context.Db.Exec("insert into signatures (chain_id, transfer_id, address, endpoint, request, response, error) values ($1, $2, $3, $4, $5, $6, $7)", chainId, transferId, address, endpoint, string(request), string(response), message); e.Context.Debug(err) {
+		return
+	}
+}
+
+// writeInternal - This function settles the withdrawal to the deposit address of another user of the exchange without a transfer
+// on the chain. The balance of the sender is already debited, the balance of the recipient is credited with the whole
+// quantity, both transactions are recorded with the internal allocation and the filled status, the deposit refers to the
+// withdrawal as its parent.
+func (e *Service) writeInternal(userId, recipient int64, req *pbspot.SetRequestWithdrawal, chain *types.Chain, protocol, group string) error {
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	withdrawal, err := _provider.WriteTransaction(&types.Transaction{
+		Symbol:     req.GetSymbol(),
+		Value:      req.GetQuantity(),
+		To:         req.GetAddress(),
+		ChainId:    chain.GetId(),
+		UserId:     userId,
+		Platform:   req.GetPlatform(),
+		Protocol:   protocol,
+		Allocation: types.AllocationInternal,
+		Assignment: types.AssignmentWithdrawal,
+		Group:      group,
+	})
+	if err != nil {
+		return err
+	}
+
+	deposit, err := _provider.WriteTransaction(&types.Transaction{
+		Symbol:     req.GetSymbol(),
+		Value:      req.GetQuantity(),
+		To:         req.GetAddress(),
+		ChainId:    chain.GetId(),
+		UserId:     recipient,
+		Platform:   req.GetPlatform(),
+		Protocol:   protocol,
+		Parent:     withdrawal.GetId(),
+		Allocation: types.AllocationInternal,
+		Assignment: types.AssignmentDeposit,
+		Group:      group,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := e.Context.Db.Exec("update transactions set status = $3 where id in ($1, $2);", withdrawal.GetId(), deposit.GetId(), types.StatusFilled); err != nil {
+		return err
+	}
+
+	// The recipient may hold no balance of the asset yet, the balance is created before it is credited.
+	if err := _provider.WriteAsset(req.GetSymbol(), types.TypeSpot, recipient); err != nil {
+		return err
+	}
+
+	if err := _provider.WriteBalance(req.GetSymbol(), types.TypeSpot, recipient, decimal.New(req.GetQuantity()), types.BalancePlus); err != nil {
+		return err
+	}
+
+	withdrawal.Status = types.StatusFilled
+	if err := e.Context.Publish(withdrawal, "exchange", "withdraw/status"); err != nil {
+		return err
+	}
+
+	deposit.Hook, deposit.Status = true, types.StatusFilled
+	if err := e.Context.Publish(deposit, "exchange", "deposit/open", "deposit/status"); err != nil {
+		return err
+	}
+
+	_query := query.Migrate{
+		Context: e.Context,
+	}
+
+	go _query.SendMail(userId, "withdrawal", req.GetQuantity(), req.GetSymbol())
+
+	return nil
+}
+
+// queryValidateDestination - This function blocks the destinations of the withdrawals that are valid addresses, but to which the
+// funds would be lost: the zero address of the platform and the token contracts registered on the exchange, the tokens sent
+// to the contract of the token itself are not credited to anybody.
+func (e *Service) queryValidateDestination(src string) error {
+
+	var (
+		exist bool
+	)
+
+	// The decoded Tron address contains the 0x41 prefix, the rest of the bytes of the zero address are zeros.
+	decode := address.New(src)
+	if len(decode) == 21 && decode[0] == 0x41 {
+		decode = decode[1:]
+	}
+
+	if len(decode) > 0 && len(bytes.TrimLeft(decode, "\x00")) == 0 {
+		return i18n.Errorf(11656, "the address %v is the zero address, the funds sent to it are burned", src)
+	}
+
+	_ = e.Context.Db.QueryRow("select exists(select id from contracts where lower(address) = lower($1))::bool", src).Scan(&exist)
+
+	if exist {
+		return i18n.Errorf(11657, "the address %v is a token contract, the funds sent to it are lost, please use the address of your wallet", src)
 	}
 
 	return nil
 }
 
+// heartbeat - This function reports the health of the scanner of the chain to the status page, the component is named by the
+// chain. The block is the last block scanned, the zero block keeps the block reported before.
+func (e *Service) heartbeat(chain *types.Chain, status, detail string, block int64) {
+	e.Context.Heartbeat(fmt.Sprintf("chain/%v", chain.GetName()), types.ComponentChain, status, detail, block)
+}
+
+// writeCursor - This function moves the cursor of the scanner of the chain past the scanned block. The cursor is moved only from
+// the block that was scanned, so that the scanner which read the block again after a restart does not move it back, and
+// the transactions of the block are recorded once, by their hashes, however many times the block is read.
+func (e *Service) writeCursor(chain *types.Chain) bool {
+
+	if _, err := e.Context.Db.Exec("update chains set block = $1 where id = $2 and block <= $3", chain.GetBlock()+1, chain.GetId(), chain.GetBlock()); e.Context.Debug(err) {
+		return false
+	}
+
+	// The block is scanned, the scanner of the chain is reported with it to the status page.
+	e.heartbeat(chain, types.ComponentOperational, "", chain.GetBlock())
+	e.done(chain.GetId())
+
+	return true
+}
+
+// writeDeposits - This function records the deposits read from a block of the chain, in the order they were found, and publishes
+// the new pending deposits with the number of the confirmations they wait for. The deposit whose hash is already recorded,
+// by an earlier pass over the block or by another scanner, is not published again, so that it is detected once. It
+// reports whether the deposits of the block were recorded, the block whose deposits were not is read again.
+func (e *Service) writeDeposits(chain *types.Chain, deposits []*types.Transaction) bool {
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	for _, item := range deposits {
+
+		transaction, err := _provider.WriteTransaction(item)
+		if e.Context.Debug(err) {
+			return false
+		}
+
+		if transaction.GetId() == 0 {
+			continue
+		}
+		detected.Inc(chain.GetPlatform(), transaction.GetSymbol())
+
+		// The pending deposit is published with the number of the confirmations it waits for.
+		transaction.Required = e.queryConfirmation(transaction.GetSymbol(), chain.GetId(), transaction.GetValue(), chain.GetConfirmation())
+		transaction.Remaining = transaction.GetRequired()
+
+		if err := e.Context.Publish(transaction, "exchange", "deposit/open", "deposit/status"); e.Context.Debug(err) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeCredit - This function credits the confirmed deposit to the spot balance of the user. The deposit is claimed in the same
+// database transaction as the balance, its status is moved from pending to filled only once, so that the deposit is
+// credited once even when two services confirm it at the same time or the service stops between the credit and the
+// status. It reports whether the deposit was credited, the deposit claimed before is not.
+func (e *Service) writeCredit(ctx context.Context, item *types.Transaction) (bool, error) {
+
+	tx, err := e.Context.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "update transactions set status = $2 where id = $1 and status = $3", item.GetId(), types.StatusFilled, types.StatusPending)
+	if err != nil {
+		return false, err
+	}
+
+	if claimed, err := result.RowsAffected(); err != nil || claimed == 0 {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update balances set value = value + $1 where symbol = $2 and user_id = $3 and type = $4;", item.GetValue(), item.GetSymbol(), item.GetUserId(), types.TypeSpot); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// queryCoin - This function returns the balance of the coin of the chain at the address, read from the node of the chain.
+func (e *Service) queryCoin(client platform.Client, chain *types.Chain, address string) (float64, error) {
+
+	balance, err := client.BalanceAt(address)
+	if err != nil {
+		return 0, err
+	}
+
+	return decimal.New(balance).Floating(chain.GetDecimals()), nil
+}
+
+// writeGas - This function tops up the hot wallet with the coin of the chain from the gas reserve. The top-up is the move of the
+// coin of the gas reserve to the hot wallet, like the top-up of the fees of a token withdrawal.
+func (e *Service) writeGas(chain *types.Chain, address, to string, value float64) error {
+
+	var (
+		userId int64
+	)
+
+	if err := e.Context.Db.QueryRow("select user_id from reserves where address = $1 and symbol = $2 and platform = $3 and protocol = $4 and lock = $5", address, chain.GetParentSymbol(), chain.GetPlatform(), types.ProtocolMainnet, false).Scan(&userId); err != nil {
+		return fmt.Errorf("the gas reserve %v is not available: %v", address, err)
+	}
+
+	_, err := e.writeMove(chain, userId, address, to, chain.GetParentSymbol(), types.ProtocolMainnet, value, nil)
+	return err
+}
+
+// writeMove - This function moves the funds of the reserve of the exchange address of the user to another exchange address of the
+// chain. The move is the internal withdrawal of the owner of the recipient address, it is sent at once from the address
+// and is locked with its reserve until it is sent, and when it arrives it is added to the reserve of the recipient address
+// instead of being credited to a balance. The record hook is called with the id of the transaction of the move before it is sent.
+func (e *Service) writeMove(chain *types.Chain, userId int64, address, to, symbol, protocol string, value float64, record func(id int64) error) (int64, error) {
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	transaction, err := _provider.WriteTransaction(&types.Transaction{
+		Symbol:     symbol,
+		Block:      chain.GetBlock(),
+		ChainId:    chain.GetId(),
+		Platform:   chain.GetPlatform(),
+		Value:      value,
+		UserId:     e.queryInternal(to, chain.GetPlatform()),
+		To:         to,
+		Allocation: types.AllocationInternal,
+		Protocol:   protocol,
+		Assignment: types.AssignmentWithdrawal,
+		Group:      types.GroupCrypto,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The move is taken out of the pending withdrawals at once, so that it is sent from the address of the move only.
+	if _, err := e.Context.Db.Exec("update transactions set status = $2 where id = $1;", transaction.GetId(), types.StatusProcessing); err != nil {
+		return 0, err
+	}
+
+	if record != nil {
+		if err := record(transaction.GetId()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := _provider.WriteReserveLock(userId, symbol, chain.GetPlatform(), protocol); err != nil {
+		return 0, err
+	}
+
+	e.transfer(userId, transaction.GetId(), address, symbol, to, value, 0, protocol, chain, types.AllocationInternal)
+
+	return transaction.GetId(), nil
+}
+
+// writeRebalance - This function records the move of the reserves of the currency, the move that waits for the approval of the
+// operators is also sent to them as a support alert.
+func (e *Service) writeRebalance(item *types.Rebalance) error {
+
+	if err := e.Context.Db.QueryRow(`insert into rebalances (kind, symbol, platform, protocol, from_platform, from_protocol, chain_id, user_id, "from", "to", value, shortage, bridge_id, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) returning id, create_at`,
+		item.GetKind(),
+		item.GetSymbol(),
+		item.GetPlatform(),
+		item.GetProtocol(),
+		item.GetFromPlatform(),
+		item.GetFromProtocol(),
+		item.GetChainId(),
+		item.GetUserId(),
+		item.GetFrom(),
+		item.GetTo(),
+		item.GetValue(),
+		item.GetShortage(),
+		item.GetBridgeId(),
+		item.GetStatus(),
+	).Scan(&item.Id, &item.CreateAt); err != nil {
+		return err
+	}
+
+	if item.GetStatus() != types.StatusReview {
+		return nil
+	}
+
+	e.Context.Logger.Warnf("[REBALANCE]: the withdrawals of %v on %v/%v are short of %v, the move %v of %v from %v/%v waits for the approval", item.GetSymbol(), item.GetPlatform(), item.GetProtocol(), item.GetShortage(), item.GetId(), item.GetValue(), item.GetFromPlatform(), item.GetFromProtocol())
+
+	return e.Context.Publish(item, "exchange", "support/rebalance")
+}
+
+// writeHead - This function records the head of the chain and the lag of its scanner, the number of the blocks between the head and
+// the last block scanned, to the metrics and with the chain, where the operators read it, together with whether the head
+// was streamed by the subscription of the chain.
+func (e *Service) writeHead(chain *types.Chain, head int64, streamed bool) {
+
+	behind := head - (chain.GetBlock() - 1)
+	if behind < 0 {
+		behind = 0
+	}
+	lag.Set(float64(behind), chain.GetName())
+
+	if _, err := e.Context.Db.Exec("update chains set head = $1, scan_at = now(), streamed = $3 where id = $2", head, chain.GetId(), streamed); e.Context.Debug(err) {
+		return
+	}
+}
+
 // done - This function is used to mark an item with a given ID as done. The wait map is a collection of items with an
 // associated boolean value indicating whether it is done or not. The function sets the value of the item with the given
 // ID to true, thus marking it as done.
 func (e *Service) done(id int64) {
 	e.wait[id] = true
 }
+
+// queryWatchAddress - This function validates the external address that a user wants to watch and brings it into the form in which
+// the chain scanners compare the addresses, the form the adapter of the platform records the addresses in: the lower case
+// hexadecimal form for Ethereum and the Base58 form for Tron.
+func (e *Service) queryWatchAddress(name, src string) (string, error) {
+
+	adapter, ok := platform.Lookup(name)
+	if !ok {
+		return "", i18n.Messagef("11595.platform", 11595, "the addresses of the platform %v cannot be watched", name)
+	}
+
+	// This code validates the address, the malformed addresses and the addresses of the other platforms are not watched.
+	if err := adapter.Validate(src); err != nil {
+		return "", i18n.Errorf(11595, "invalid address %v", src)
+	}
+
+	return adapter.Format(src), nil
+}
+
+// querySourceAddress - This function validates the external address claimed as the verified source and returns it in the form of
+// the platform, the addresses of the platforms whose wallets do not sign the messages and the addresses of the exchange
+// are not claimed.
+func (e *Service) querySourceAddress(name, src string) (string, error) {
+
+	adapter, ok := platform.Lookup(name)
+	if _, verifier := adapter.(platform.Verifier); !ok || !verifier {
+		return "", i18n.Errorf(11788, "the addresses of the platform %v can not be verified", name)
+	}
+
+	if err := adapter.Validate(src); err != nil {
+		return "", i18n.Errorf(11595, "invalid address %v", src)
+	}
+
+	address := adapter.Format(src)
+	if err := e.queryValidateInternal(address); err != nil {
+		return "", err
+	}
+
+	return address, nil
+}
+
+// watch - This function records a transfer found by the chain scanners for the external addresses watched by the users, both the
+// incoming and the outgoing transfers are recorded, and the users are notified about them. The watched addresses are
+// read-only, the transfers never change the balances of the users on the exchange.
+func (e *Service) watch(chain *types.Chain, block int64, hash, from, to, symbol string, value float64) {
+
+	// This code queries the watches of the chain whose address is the sender or the recipient of the transfer.
+	rows, err := e.Context.Db.Query(`select id, user_id, address from watches where chain_id = $1 and address in ($2, $3)`, chain.GetId(), from, to)
+	if e.Context.Debug(err) {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item    types.Transaction
+			watchId int64
+			watched string
+		)
+
+		if err := rows.Scan(&watchId, &item.UserId, &watched); e.Context.Debug(err) {
+			continue
+		}
+
+		item.Hash = hash
+		item.Symbol = symbol
+		item.From = from
+		item.To = to
+		item.Value = value
+		item.Block = block
+		item.ChainId = chain.GetId()
+		item.Platform = chain.GetPlatform()
+
+		// The transfer to the watched address is a deposit of it, the transfer from the watched address is a withdrawal.
+		item.Assignment = types.AssignmentDeposit
+		if watched == from {
+			item.Assignment = types.AssignmentWithdrawal
+		}
+
+		// The transfer is recorded only once, for example if the scanner goes through the same block again.
+		if err := e.Context.Db.QueryRow(`insert into watch_transactions (watch_id, user_id, hash, symbol, "from", "to", value, block, assignment) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) on conflict (watch_id, hash, symbol) do nothing returning id, create_at`, watchId, item.GetUserId(), item.GetHash(), item.GetSymbol(), item.GetFrom(), item.GetTo(), item.GetValue(), item.GetBlock(), item.GetAssignment()).Scan(&item.Id, &item.CreateAt); err != nil {
+			continue
+		}
+
+		if err := e.Context.Publish(&item, "exchange", "account/watch"); e.Context.Debug(err) {
+			continue
+		}
+	}
+}
+
+// writeReorg - This function handles the deposits of the block of the chain that was replaced by the reorganization of the chain,
+// the deposits are located again on the canonical chain one by one. The deposit whose transaction was mined again in
+// another block waits for its confirmations from that block, the pending or held deposit whose transaction is no longer
+// mined is reverted with the orphaned status, and the deposit that was credited already is reported to the operators,
+// the balance of the user is left to them. The cursor of the scanner is moved back to the replaced block, so that the
+// canonical blocks are read. It reports whether the block was handled, the block is checked again by the next pass.
+func (e *Service) writeReorg(chain *types.Chain, canonical platform.Canonical, block int64, hash, replaced string) bool {
+
+	var (
+		items []*types.Transaction
+	)
+
+	rows, err := e.Context.Db.Query(`select id, hash, user_id, symbol, value, status from transactions where chain_id = $1 and assignment = $2 and block = $3 and block_hash = $4 and status = any($5)`, chain.GetId(), types.AssignmentDeposit, block, hash, pq.Array([]string{types.StatusPending, types.StatusHold, types.StatusFilled}))
+	if e.Context.Debug(err) {
+		return false
+	}
+
+	for rows.Next() {
+
+		var (
+			item types.Transaction
+		)
+
+		if err := rows.Scan(&item.Id, &item.Hash, &item.UserId, &item.Symbol, &item.Value, &item.Status); e.Context.Debug(err) {
+			rows.Close()
+			return false
+		}
+		items = append(items, &item)
+	}
+	rows.Close()
+
+	for _, item := range items {
+
+		reorg := types.Reorg{
+			ChainId:       chain.GetId(),
+			TransactionId: item.GetId(),
+			UserId:        item.GetUserId(),
+			Symbol:        item.GetSymbol(),
+			Value:         item.GetValue(),
+			Block:         block,
+			Hash:          hash,
+			Canonical:     replaced,
+		}
+
+		number, err := canonical.Locate(item.GetHash())
+		if err != nil { // No debug....
+			return false
+		}
+
+		switch {
+		case number > 0:
+
+			// The transaction was mined again, the deposit waits for its confirmations from the new block.
+			relocated, err := canonical.Hash(number)
+			if err != nil { // No debug....
+				return false
+			}
+
+			if _, err := e.Context.Db.Exec("update transactions set block = $2, block_hash = $3, confirmation = 0 where id = $1", item.GetId(), number, relocated); e.Context.Debug(err) {
+				return false
+			}
+			reorg.Relocated, reorg.Status = number, types.ReorgLocated
+
+		case item.GetStatus() == types.StatusFilled:
+
+			// The credited deposit is no longer tracked, the operators decide on the balance of the user.
+			if _, err := e.Context.Db.Exec("update transactions set block_hash = '' where id = $1", item.GetId()); e.Context.Debug(err) {
+				return false
+			}
+			reorg.Status = types.ReorgOrphaned
+
+		default:
+
+			// The pending deposit is reverted, it is located again while the chain may still mine its transaction.
+			if _, err := e.Context.Db.Exec("update transactions set status = $2, error = $3 where id = $1 and status = $4", item.GetId(), types.StatusOrphaned, "the block of the deposit was replaced by the reorganization of the chain", item.GetStatus()); e.Context.Debug(err) {
+				return false
+			}
+			reorg.Status = types.ReorgReverted
+
+			item.Status = types.StatusOrphaned
+			e.Context.Debug(e.Context.Publish(item, "exchange", "deposit/status"))
+		}
+
+		if err := e.Context.Db.QueryRow("insert into reorgs (chain_id, transaction_id, user_id, symbol, value, block, hash, canonical, relocated, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id, create_at", reorg.GetChainId(), reorg.GetTransactionId(), reorg.GetUserId(), reorg.GetSymbol(), reorg.GetValue(), reorg.GetBlock(), reorg.GetHash(), reorg.GetCanonical(), reorg.GetRelocated(), reorg.GetStatus()).Scan(&reorg.Id, &reorg.CreateAt); e.Context.Debug(err) {
+			return false
+		}
+
+		// The operators are alerted of the credited deposit that was orphaned, the balance of the user may have to be corrected.
+		if reorg.GetStatus() == types.ReorgOrphaned {
+
+			e.Context.Logger.Warnf("[REORG]: the credited deposit %v of %v %v of the user %v on the chain %v was orphaned by the reorganization of the block %v", item.GetId(), item.GetValue(), item.GetSymbol(), item.GetUserId(), chain.GetName(), block)
+			e.heartbeat(chain, types.ComponentDegraded, fmt.Sprintf("the credited deposit %v was orphaned by the reorganization of the block %v", item.GetId(), block), 0)
+
+			if err := e.Context.Publish(&reorg, "exchange", "support/reorg"); e.Context.Debug(err) {
+				return false
+			}
+		}
+	}
+
+	// The canonical blocks from the replaced block are read again, the deposits recorded before are recorded once, by their hashes.
+	if _, err := e.Context.Db.Exec("update chains set block = $2 where id = $1 and block > $2", chain.GetId(), block); e.Context.Debug(err) {
+		return false
+	}
+
+	if chain.GetBlock() > block {
+		chain.Block = block
+	}
+
+	return true
+}
+
+// writeDistribution - This function credits the next batch of the pending records of the distribution to the spot balances of
+// the users in one database transaction, and returns the number of the credited records. When no pending record is
+// left, the distribution is marked as filled and its final progress is published.
+func (e *Service) writeDistribution(item *types.Distribution, limit int) (count int, err error) {
+
+	tx, err := e.Context.Db.Begin()
+	if err != nil {
+		return count, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("select id, user_id, value from distribution_records where distribution_id = $1 and status = $2 order by id limit $3 for update", item.GetId(), types.StatusPending, limit)
+	if err != nil {
+		return count, err
+	}
+
+	var (
+		records []*types.DistributionRecord
+	)
+
+	for rows.Next() {
+
+		var (
+			record types.DistributionRecord
+		)
+
+		if err := rows.Scan(&record.Id, &record.UserId, &record.Value); err != nil {
+			rows.Close()
+			return count, err
+		}
+
+		records = append(records, &record)
+	}
+	rows.Close()
+
+	for _, record := range records {
+
+		// The users that have never held the currency have no balance of it yet.
+		if _, err := tx.Exec("insert into balances (user_id, symbol, type) select $1, $2, $3 where not exists (select id from balances where user_id = $1 and symbol = $2 and type = $3)", record.GetUserId(), item.GetSymbol(), types.TypeSpot); err != nil {
+			return count, err
+		}
+
+		if _, err := tx.Exec("update balances set value = value + $1 where symbol = $2 and user_id = $3 and type = $4", record.GetValue(), item.GetSymbol(), record.GetUserId(), types.TypeSpot); err != nil {
+			return count, err
+		}
+
+		if _, err := tx.Exec("update distribution_records set status = $2 where id = $1", record.GetId(), types.StatusFilled); err != nil {
+			return count, err
+		}
+	}
+
+	if len(records) == 0 {
+		item.Status = types.StatusFilled
+	}
+
+	if err := tx.QueryRow("update distributions set processed = processed + $2, status = $3 where id = $1 returning count, processed", item.GetId(), len(records), item.GetStatus()).Scan(&item.Count, &item.Processed); err != nil {
+		return count, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return count, err
+	}
+
+	// The progress is published after every batch, so that the administrators follow the distribution without polling.
+	if err := e.Context.Publish(item, "exchange", "distribution/status"); err != nil {
+		return len(records), err
+	}
+
+	return len(records), nil
+}
+
+// writeRanking - This function computes the rankings of the competition from the trades of the users on the pairs of the
+// competition within its period. The volume is the traded value in the quote currency, the profit and loss of every trade
+// is marked to the last price of the pair within the period, so that the open positions count as well. The places are
+// assigned by the metric of the competition, the ties are resolved by the id of the user.
+func (e *Service) writeRanking(item *types.Competition) error {
+
+	var (
+		order = "volume"
+	)
+
+	if item.GetMetric() == types.MetricPnl {
+		order = "pnl"
+	}
+
+	tx, err := e.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("delete from rankings where competition_id = $1", item.GetId()); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`with trade as (
+		select t.user_id, t.base_unit, t.quote_unit, t.assigning, t.price, t.quantity, t.create_at, t.id from trades t inner join pairs p on p.base_unit = t.base_unit and p.quote_unit = t.quote_unit where p.id = any($2) and t.create_at >= $3 and t.create_at < $4
+	), last as (
+		select distinct on (base_unit, quote_unit) base_unit, quote_unit, price from trade order by base_unit, quote_unit, create_at desc, id desc
+	), summary as (
+		select t.user_id, sum(t.quantity * t.price) as volume, sum(case when t.assigning = $5 then t.quantity * (l.price - t.price) else t.quantity * (t.price - l.price) end) as pnl from trade t inner join last l on l.base_unit = t.base_unit and l.quote_unit = t.quote_unit group by t.user_id
+	)
+	insert into rankings (competition_id, user_id, rank, volume, pnl) select $1, user_id, row_number() over (order by %[1]s desc, user_id), volume, pnl from summary`, order), item.GetId(), pq.Array(item.GetPairs()), item.GetStartAt(), item.GetFinishAt(), types.AssigningBuy); err != nil {
+		return err
+	}
+
+	if err := tx.QueryRow("update competitions set compute_at = now() where id = $1 returning compute_at", item.GetId()).Scan(&item.ComputeAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// writePrizes - This function finishes the competition: the shares of the prize pool are assigned to the places of the final
+// rankings, and the prizes are handed over to a distribution, the balances of the winners are credited by the background
+// job of the distributions. Only the users with a positive result receive a prize, the unassigned shares stay with the
+// exchange.
+func (e *Service) writePrizes(item *types.Competition) error {
+
+	var (
+		result = "volume"
+	)
+
+	if item.GetMetric() == types.MetricPnl {
+		result = "pnl"
+	}
+
+	tx, err := e.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for rank, share := range item.GetPrizes() {
+		if _, err := tx.Exec(fmt.Sprintf("update rankings set prize = $3 where competition_id = $1 and rank = $2 and %[1]s > 0", result), item.GetId(), rank+1, decimal.New(item.GetPrize()).Mul(share).Div(100).Float()); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.QueryRow("insert into distributions (symbol, status, user_id) values ($1, $2, 0) returning id", item.GetSymbol(), types.StatusPending).Scan(&item.DistributionId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("insert into distribution_records (distribution_id, user_id, value) select $1, user_id, prize from rankings where competition_id = $2 and prize > 0", item.GetDistributionId(), item.GetId()); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("update competitions set status = $2, distribution_id = $3 where id = $1", item.GetId(), types.StatusFilled, item.GetDistributionId()); err != nil {
+		return err
+	}
+	item.Status = types.StatusFilled
+
+	return tx.Commit()
+}
@@ -2,16 +2,21 @@ package spot
 
 import (
 	"context"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
-	"github.com/cryptogateway/backend-envoys/assets/common/keypair"
-	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
+	"github.com/cryptogateway/backend-envoys/assets/common/i18n"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbspot"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
 	"github.com/pquerna/otp/totp"
-	"google.golang.org/grpc/status"
+	uuid "github.com/satori/go.uuid"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // SetWithdraw - This code is a function written in the Go programming language.
@@ -27,12 +32,7 @@ func (e *Service) SetWithdraw(ctx context.Context, req *pbspot.SetRequestWithdra
 		fees     float64
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// Service account is a service that provides access to the account api. It provides methods to create, update, and delete accounts.
 	_account := account.Service{
@@ -69,19 +69,30 @@ func (e *Service) SetWithdraw(ctx context.Context, req *pbspot.SetRequestWithdra
 	// the user's status is not valid, the code returns an error message to the user, indicating that their account and
 	// assets have been blocked and that they should contact technical support for any questions.
 	if !user.GetStatus() {
-		return &response, status.Error(748990, "your account and assets have been blocked, please contact technical support for any questions")
+		return &response, i18n.Error(748990, "your account and assets have been blocked, please contact technical support for any questions")
 	}
 
 	// This code is checking to make sure that the address provided in the request is a valid crypto address for the
-	// specified platform. If the address is not valid, the error is returned to the caller.
-	if err := keypair.ValidateCryptoAddress(req.GetAddress(), req.GetPlatform()); err != nil {
+	// specified platform, the address is validated by the adapter of the platform. If the address is not valid, the error
+	// is returned to the caller.
+	adapter, ok := platform.Lookup(req.GetPlatform())
+	if !ok {
+		return &response, i18n.Errorf(10789, "cryptocurrency not available: %s", req.GetPlatform())
+	}
+
+	if err := adapter.Validate(req.GetAddress()); err != nil {
 		return &response, err
 	}
 
-	// This code is part of an error handling process. The if statement checks to see if the validateInternalAsset method
-	// returns an error when given the address from the request. If it does return an error, the code will return the
-	// response and log the error using the Context.Error() method.
-	if err := e.queryValidateInternal(req.GetAddress()); err != nil {
+	// The withdrawals to the deposit addresses of the other users of the exchange are settled internally, without a transfer
+	// on the chain, the withdrawals to the own addresses of the user are rejected.
+	recipient := e.queryInternal(req.GetAddress(), req.GetPlatform())
+	if recipient == auth {
+		return &response, i18n.Error(758690, "your cannot send from an address to the same address")
+	}
+
+	// This code blocks the zero address and the addresses of the token contracts, the funds sent to them are lost.
+	if err := e.queryValidateDestination(req.GetAddress()); err != nil {
 		return &response, err
 	}
 
@@ -94,15 +105,49 @@ func (e *Service) SetWithdraw(ctx context.Context, req *pbspot.SetRequestWithdra
 	// returns an error message indicating the chain array by the specified ID is currently unavailable.
 	chain, err := _provider.QueryChain(req.GetId(), true)
 	if err != nil {
-		return &response, status.Errorf(11584, "the chain array by id %v is currently unavailable", req.GetId())
+		return &response, i18n.Errorf(11584, "the chain array by id %v is currently unavailable", req.GetId())
+	}
+
+	// The address is validated for the platform of the request, a chain of another platform means that the token would be
+	// sent to the wrong network.
+	if chain.GetPlatform() != req.GetPlatform() {
+		return &response, i18n.Errorf(11655, "the chain %v belongs to the %v network, not to the %v network", chain.GetName(), chain.GetPlatform(), req.GetPlatform())
 	}
 
 	// This code is used to get the currency of a request. It checks if the currency is available in the request and if it
-	// is not available, it returns an error message (status.Errorf(10029, "the currency requested array by id %v is
+	// is not available, it returns an error message (i18n.Errorf(10029, "the currency requested array by id %v is
 	// currently unavailable", req.GetSymbol())).
 	currency, err := _provider.QueryAsset(req.GetSymbol(), false)
 	if err != nil {
-		return &response, status.Errorf(10029, "the asset requested array by id %v is currently unavailable", req.GetSymbol())
+		return &response, i18n.Errorf(10029, "the asset requested array by id %v is currently unavailable", req.GetSymbol())
+	}
+
+	// This code checks that the asset is available on the chain and that its withdrawals are enabled on it, the minimum
+	// withdrawal of the asset can be overridden for the chain.
+	network, err := _provider.QueryAssetChain(req.GetSymbol(), chain.GetId())
+	if err != nil {
+		return &response, i18n.Errorf(11645, "the asset %v is not available on the chain %v", req.GetSymbol(), chain.GetName())
+	}
+
+	if !network.GetWithdraw() {
+		return &response, i18n.Errorf(11646, "the withdrawals of %v on the chain %v are temporarily disabled", req.GetSymbol(), chain.GetName())
+	}
+
+	// The withdrawals can be suspended by the operators for the whole exchange, for the asset or for the chain.
+	if err := e.Context.Feature(types.FeatureWithdrawal, assets.Scope{Kind: types.ScopeCurrency, Target: req.GetSymbol()}, assets.Scope{Kind: types.ScopeChain, Target: strconv.FormatInt(chain.GetId(), 10)}); err != nil {
+		return &response, err
+	}
+
+	if network.GetMinWithdraw() > 0 {
+		currency.MinWithdraw = network.GetMinWithdraw()
+	}
+
+	// The withdrawals of an asset being delisted are allowed until the end of the grace period, after it the residual
+	// balances are converted into the quote asset.
+	if delisting, err := _provider.QueryDelisting(req.GetSymbol()); err == nil {
+		if until, err := time.Parse(time.RFC3339, delisting.GetWithdrawUntil()); err == nil && time.Now().After(until) {
+			return &response, i18n.Errorf(11637, "the asset %v has been delisted, the grace period for the withdrawals ended at %v", req.GetSymbol(), delisting.GetWithdrawUntil())
+		}
 	}
 
 	// The purpose of the code above is to retrieve a contract from a blockchain given a symbol and chain ID. It does this
@@ -123,13 +168,13 @@ func (e *Service) SetWithdraw(ctx context.Context, req *pbspot.SetRequestWithdra
 	// The purpose of the code snippet is to ensure that the email code provided is 6 numbers long. If it is not, an error
 	// with the code 16763 will be returned.
 	if len(req.GetEmailCode()) != 6 {
-		return &response, status.Error(16763, "the code must be 6 numbers")
+		return &response, i18n.Error(16763, "the code must be 6 numbers")
 	}
 
 	// This if statement is used to check if the security code provided by the user matches the security code associated
 	// with the user's email address. If the code is incorrect or empty, an error is returned.
 	if secure != req.GetEmailCode() || secure == "" {
-		return &response, status.Errorf(58990, "security code %v is incorrect", req.GetEmailCode())
+		return &response, i18n.Errorf(58990, "security code %v is incorrect", req.GetEmailCode())
 	}
 
 	// The purpose of this statement is to check if the user has enabled two-factor authentication. If the user has enabled
@@ -139,85 +184,81 @@ func (e *Service) SetWithdraw(ctx context.Context, req *pbspot.SetRequestWithdra
 		// The purpose of this code is to verify a two-factor authentication (2FA) code. The code is compared to a user's 2FA
 		// secret, and if it does not match, an error is returned.
 		if !totp.Validate(req.GetFactorCode(), user.GetFactorSecret()) {
-			return &response, status.Error(115654, "invalid 2fa secure code")
+			return &response, i18n.Error(115654, "invalid 2fa secure code")
 		}
 	}
 
-	// This code checks to see if the protocol used by the contract is not the mainnet protocol. This is important to ensure
-	// that the contract uses the correct protocol, as different protocols have different rules and requirements.
-	if contract.GetProtocol() != types.ProtocolMainnet {
-
-		// This code is used to get the price of a given asset. It takes two parameters, the base unit and the quote unit, and
-		// requests the price of the asset. The GetPrice() function returns the price and an error if one is encountered, which
-		// is then checked and handled.
-		price, err := pbprovider.NewApiClient(e.Context.GrpcClient).GetPrice(context.Background(), &pbprovider.GetRequestPrice{
-			BaseUnit:  chain.GetParentSymbol(),
-			QuoteUnit: req.GetSymbol(),
-		})
-		if err != nil {
-			return &response, err
-		}
-
-		// The purpose of this line of code is to assign the value returned by the GetPrice() function to the req.Price
-		// variable. This variable may be used later in the program to calculate the total cost of a purchase, or to determine
-		// the cost of an individual item.
-		req.Price = price.GetPrice()
-
-		// The purpose of this statement is to assign the value returned from the GetFees() method of the contract
-		// object to the GetFees property of the chain object.
-		chain.Fees = contract.GetFees()
-
-		// The purpose of this statement is to calculate the fees that are associated with a withdrawal request. The statement
-		// uses the GetFeesWithdraw() and GetPrice() functions to retrieve the fee rate and the price of the request,
-		// respectively. It then uses decimal.New to create a new decimal object and multiply it by the price to get the fees
-		// associated with the withdrawal request. Finally, it uses the Float() function to convert the resulting decimal
-		// object into a float value for further calculations.
-		fees = decimal.New(contract.GetFees()).Mul(req.GetPrice()).Float()
+	// The fee of the withdrawal is calculated from the static fees, the fee oracle and the override of the asset on the chain,
+	// the fee in the units of the asset is charged to the user, the fee in the parent asset is recorded with the transaction.
+	estimate, err := e.queryFees(req.GetSymbol(), chain, contract, network)
+	if err != nil {
+		return &response, err
+	}
+	fees, chain.Fees = estimate.GetFees(), estimate.GetNetworkFees()
 
-	} else {
+	if contract.GetProtocol() != types.ProtocolMainnet {
+		req.Price = estimate.GetPrice()
+	}
 
-		// The purpose of this line of code is to get the fee associated with withdrawing funds from a chain, such as a
-		// blockchain. This line of code calls the GetFeesWithdraw() method, which retrieves the fee associated with
-		// withdrawing funds from the chain.
-		fees = chain.GetFees()
+	// The internal transfer does not touch the reserves on the chain and does not pay the network, so the whole quantity is
+	// credited to the recipient and no fee is charged.
+	reserve := _provider.QueryReserve(req.GetSymbol(), req.GetPlatform(), contract.GetProtocol())
+	if recipient > 0 {
+		fees, chain.Fees, reserve = 0, 0, req.GetQuantity()
 	}
 
 	// This code is checking if any errors arise when withdrawing a certain quantity of a certain currency from a certain
 	// platform or protocol. If an error occurs, the code returns an error response.
-	if err := e.queryValidateWithdrawal(req.GetQuantity(), _provider.QueryReserve(req.GetSymbol(), req.GetPlatform(), contract.GetProtocol()), _provider.QueryBalance(req.GetSymbol(), types.TypeSpot, auth), currency.GetMaxWithdraw(), currency.GetMinWithdraw(), fees); err != nil {
+	if err := e.queryValidateWithdrawal(req.GetQuantity(), reserve, _provider.QueryBalance(req.GetSymbol(), types.TypeSpot, auth), currency.GetMaxWithdraw(), currency.GetMinWithdraw(), fees); err != nil {
 		return &response, err
 	}
 
 	// This if statement is checking to see if the address given by the request is the same as the address that it is attempting to send the request to.
 	// If they are the same, the code will return an error indicating that the user cannot send from an address to the same address.
 	if address := _provider.QueryAddress(auth, req.GetPlatform()); address == strings.ToLower(req.GetAddress()) {
-		return &response, status.Error(758690, "your cannot send from an address to the same address")
+		return &response, i18n.Error(758690, "your cannot send from an address to the same address")
 	}
 
-	// This code is checking for an error when attempting to set a balance for a symbol with a given quantity. If there is
-	// an error, the program will debug the error and return the response and an error.
-	if err := _provider.WriteBalance(req.GetSymbol(), types.TypeSpot, auth, req.GetQuantity(), types.BalanceMinus); e.Context.Debug(err) {
-		return &response, err
-	}
+	if recipient > 0 {
+
+		// The internal transfer is recorded as a filled withdrawal of the sender and a filled deposit of the recipient, the
+		// balance of the sender is debited and the balance of the recipient is credited at once.
+		if err := _provider.WriteBalance(req.GetSymbol(), types.TypeSpot, auth, decimal.New(req.GetQuantity()), types.BalanceMinus); e.Context.Debug(err) {
+			return &response, err
+		}
 
-	// This code snippet is used to insert data into the 'transactions' table in a database. The code is using the Exec
-	// method of the database to execute an SQL insert statement. The values of the transaction being inserted are provided
-	// as parameters in the insert statement. Finally, the code checks for any errors that may have occurred during the
-	// insertion, and returns an appropriate response.
-	if _, err := e.Context.Db.Exec(`insert into transactions (symbol, value, price, "to", chain_id, platform, protocol, fees, user_id, assignment, "group") values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
-		req.GetSymbol(),
-		req.GetQuantity(),
-		req.GetPrice(),
-		req.GetAddress(),
-		req.GetId(),
-		req.GetPlatform(),
-		contract.GetProtocol(),
-		chain.GetFees(),
-		auth,
-		types.AssignmentWithdrawal,
-		currency.GetGroup(),
-	); err != nil {
-		return &response, status.Error(554322, "transaction hash is already in the list, please contact support")
+		if err := e.writeInternal(auth, recipient, req, chain, contract.GetProtocol(), currency.GetGroup()); err != nil {
+			return &response, err
+		}
+
+	} else {
+
+		var (
+			id int64
+		)
+
+		if err := e.Context.Db.QueryRow(`insert into transactions (symbol, value, price, "to", chain_id, platform, protocol, fees, user_id, assignment, "group") values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id`,
+			req.GetSymbol(),
+			req.GetQuantity(),
+			req.GetPrice(),
+			req.GetAddress(),
+			req.GetId(),
+			req.GetPlatform(),
+			contract.GetProtocol(),
+			chain.GetFees(),
+			auth,
+			types.AssignmentWithdrawal,
+			currency.GetGroup(),
+		).Scan(&id); err != nil {
+			return &response, i18n.Error(554322, "transaction hash is already in the list, please contact support")
+		}
+
+		// The quantity of the withdrawal is held on the balance until the withdrawal is sent or cancelled, the withdrawal
+		// which can not be covered by the available balance is removed before it is sent.
+		if err := _provider.WriteHold(req.GetSymbol(), types.TypeSpot, auth, req.GetQuantity(), types.HoldWithdrawal, id); err != nil {
+			_, _ = e.Context.Db.Exec("delete from transactions where id = $1", id)
+			return &response, err
+		}
 	}
 
 	// This code checks if an error occurs when the setSecure function is called. If an error occurs, it returns an error
@@ -227,6 +268,50 @@ func (e *Service) SetWithdraw(ctx context.Context, req *pbspot.SetRequestWithdra
 	}
 	response.Success = true
 
+	// This code checks how close the withdrawal is to the maximum withdrawal amount of the asset, and adds a warning to the
+	// response if the limit is approaching.
+	if warning := _provider.QueryWarning(auth, types.LimitWithdraw, req.GetSymbol(), req.GetQuantity(), currency.GetMaxWithdraw()); warning != nil {
+		response.Warnings = append(response.Warnings, warning)
+	}
+
+	return &response, nil
+}
+
+// GetWithdrawFee - This method returns the fee of the withdrawal of the asset on the chain, calculated in the same way as it is
+// charged by SetWithdraw, so that the users see the fee before confirming the withdrawal. The dynamic flag of the response
+// shows that the fee comes from the fee oracle, because the network is more expensive than the static fee.
+func (e *Service) GetWithdrawFee(_ context.Context, req *pbspot.GetRequestWithdrawFee) (*pbspot.ResponseWithdrawFee, error) {
+
+	var (
+		response pbspot.ResponseWithdrawFee
+	)
+
+	// provide is used to create a Service provider with the given Context.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	chain, err := _provider.QueryChain(req.GetId(), true)
+	if err != nil {
+		return &response, i18n.Errorf(11584, "the chain array by id %v is currently unavailable", req.GetId())
+	}
+
+	network, err := _provider.QueryAssetChain(req.GetSymbol(), chain.GetId())
+	if err != nil {
+		return &response, i18n.Errorf(11645, "the asset %v is not available on the chain %v", req.GetSymbol(), chain.GetName())
+	}
+
+	contract, _ := _provider.QueryContract(req.GetSymbol(), chain.GetId())
+	if len(contract.GetProtocol()) == 0 {
+		contract.Protocol = types.ProtocolMainnet
+	}
+
+	estimate, err := e.queryFees(req.GetSymbol(), chain, contract, network)
+	if err != nil {
+		return &response, err
+	}
+	response.Fields = append(response.Fields, estimate)
+
 	return &response, nil
 }
 
@@ -239,25 +324,28 @@ func (e *Service) CancelWithdraw(ctx context.Context, req *pbspot.CancelRequestW
 	// used to store a response from a withdrawal request.
 	var (
 		response pbspot.ResponseWithdrawal
+		err      error
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := e.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := e.Context.User(ctx)
 
 	// Creates a service provider to be used in the given context, providing the necessary services for the application.
 	_provider := provider.Service{
 		Context: e.Context,
 	}
 
+	// The withdrawal can also be passed by its external identifier, in this case it is resolved into the internal id of the transaction.
+	if len(req.GetUid()) > 0 {
+		if req.Id, err = _provider.QueryIdentifier("transactions", req.GetUid()); err != nil {
+			return &response, err
+		}
+	}
+
 	// This query is used to select the specified row from the transactions table based on the given parameters: id, status
 	// and user_id. The purpose of this query is to retrieve the specified row from the database for further processing,
 	// such as updating the status of the transaction or displaying the information to the user. The row is then closed,
 	// which releases any resources associated with the query.
-	row, err := e.Context.Db.Query(`select id, user_id, symbol, value from transactions where id = $1 and status = $2 and user_id = $3 or id = $1 and status = $4 and user_id = $3 order by id`, req.GetId(), types.StatusPending, auth, types.StatusFailed)
+	row, err := e.Context.Db.Query(`select id, uid, user_id, symbol, value from transactions where id = $1 and status = $2 and user_id = $3 or id = $1 and status = $4 and user_id = $3 order by id`, req.GetId(), types.StatusPending, auth, types.StatusFailed)
 	if err != nil {
 		return &response, err
 	}
@@ -277,7 +365,7 @@ func (e *Service) CancelWithdraw(ctx context.Context, req *pbspot.CancelRequestW
 		// This code is used to scan the rows of a database query and assign values to item.Id, item.UserId, item.Symbol and
 		// item.Value variables. If an error occurs while scanning the rows, the error is returned in the response and the
 		// function returns an error.
-		if err = row.Scan(&item.Id, &item.UserId, &item.Symbol, &item.Value); err != nil {
+		if err = row.Scan(&item.Id, &item.Uid, &item.UserId, &item.Symbol, &item.Value); err != nil {
 			return &response, err
 		}
 
@@ -287,9 +375,8 @@ func (e *Service) CancelWithdraw(ctx context.Context, req *pbspot.CancelRequestW
 			return &response, err
 		}
 
-		// This code is checking for an error when setting a balance for a user's account. If an error occurs, it will log the
-		// error and return an error response.
-		if err := _provider.WriteBalance(item.GetSymbol(), types.TypeSpot, item.GetUserId(), item.GetValue(), types.BalancePlus); e.Context.Debug(err) {
+		// The quantity held for the withdrawal becomes available again.
+		if err := _provider.ReleaseHold(types.HoldWithdrawal, item.GetId()); e.Context.Debug(err) {
 			return &response, err
 		}
 
@@ -298,3 +385,387 @@ func (e *Service) CancelWithdraw(ctx context.Context, req *pbspot.CancelRequestW
 
 	return &response, nil
 }
+
+// SetWatch - This function registers an external address of the given chain to be watched by the user. The watched address is
+// read-only: the exchange does not hold its keys, the chain scanners only track its transfers and balance, which are
+// included in the portfolio of the user. Registering the same address again updates its label.
+func (e *Service) SetWatch(ctx context.Context, req *pbspot.SetRequestWatch) (*pbspot.ResponseWatch, error) {
+
+	// The purpose of this code is to declare the response of the function and the number of the addresses already watched by the user.
+	var (
+		response pbspot.ResponseWatch
+		count    int
+	)
+
+	auth := e.Context.User(ctx)
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	// This code requests the chain of the address, the addresses can be watched only on the active chains.
+	chain, err := _provider.QueryChain(req.GetChainId(), true)
+	if err != nil {
+		return &response, i18n.Error(11598, "the chain was not found")
+	}
+
+	address, err := e.queryWatchAddress(chain.GetPlatform(), req.GetAddress())
+	if err != nil {
+		return &response, err
+	}
+
+	// The addresses of the exchange are not external, their deposits are already a part of the balances of the users.
+	if err := e.queryValidateInternal(address); err != nil {
+		return &response, err
+	}
+
+	// This code limits the number of the watched addresses of a user, every address is requested from the node of the chain.
+	if _ = e.Context.Db.QueryRow("select count(*) from watches where user_id = $1 and not (chain_id = $2 and address = $3)", auth, chain.GetId(), address).Scan(&count); count >= maxWatches {
+		return &response, i18n.Errorf(11597, "you cannot watch more than %d addresses", maxWatches)
+	}
+
+	if _, err := e.Context.Db.Exec("insert into watches (user_id, chain_id, address, platform, label, symbol) values ($1, $2, $3, $4, $5, $6) on conflict (user_id, chain_id, address) do update set label = excluded.label", auth, chain.GetId(), address, chain.GetPlatform(), req.GetLabel(), chain.GetParentSymbol()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetWatches - This function returns the external addresses watched by the user with their last known balances, and the valuation
+// of every balance and of all of them together in the valuation unit (usd).
+func (e *Service) GetWatches(ctx context.Context, _ *pbspot.GetRequestWatches) (*pbspot.ResponseWatch, error) {
+
+	// The purpose of this code is to declare the response of the function and the cache of the prices of the symbols.
+	var (
+		response pbspot.ResponseWatch
+		prices   = make(map[string]float64)
+	)
+
+	auth := e.Context.User(ctx)
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	rows, err := e.Context.Db.Query("select id, user_id, chain_id, address, platform, label, symbol, balance, create_at from watches where user_id = $1 order by id", auth)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Watch
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.ChainId, &item.Address, &item.Platform, &item.Label, &item.Symbol, &item.Balance, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		// This code requests the price of the symbol if it has not been requested yet for this response.
+		if _, ok := prices[item.GetSymbol()]; !ok {
+			prices[item.GetSymbol()] = _provider.QueryValuation(item.GetSymbol(), "usd")
+		}
+
+		item.Valuation = decimal.New(item.GetBalance()).Mul(prices[item.GetSymbol()]).Float()
+		response.Valuation = decimal.New(response.GetValuation()).Add(item.GetValuation()).Float()
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, rows.Err()
+}
+
+// DeleteWatch - This function stops watching the external address of the user, the recorded transfers of the address are removed with it.
+func (e *Service) DeleteWatch(ctx context.Context, req *pbspot.DeleteRequestWatch) (*pbspot.ResponseWatch, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbspot.ResponseWatch
+	)
+
+	auth := e.Context.User(ctx)
+
+	if _, err := e.Context.Db.Exec("delete from watches where id = $1 and user_id = $2", req.GetId(), auth); err != nil {
+		return &response, err
+	}
+
+	if _, err := e.Context.Db.Exec("delete from watch_transactions where watch_id = $1 and user_id = $2", req.GetId(), auth); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetWatchTransactions - This function returns the transfers recorded for the external addresses watched by the user, the newest
+// transfers come first. The transfers of a single watched address are returned if its id is passed.
+func (e *Service) GetWatchTransactions(ctx context.Context, req *pbspot.GetRequestWatchTransactions) (*pbspot.ResponseWatchTransactions, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbspot.ResponseWatchTransactions
+	)
+
+	auth := e.Context.User(ctx)
+
+	// The limit of the page defaults to thirty transfers.
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	if _ = e.Context.Db.QueryRow("select count(*) from watch_transactions where user_id = $1 and ($2 = 0 or watch_id = $2)", auth, req.GetId()).Scan(&response.Count); response.GetCount() > 0 {
+
+		// This code calculates the offset of the requested page of the results.
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query(`select id, user_id, hash, symbol, "from", "to", value, block, assignment, create_at from watch_transactions where user_id = $1 and ($2 = 0 or watch_id = $2) order by id desc limit $3 offset $4`, auth, req.GetId(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Transaction
+			)
+
+			if err := rows.Scan(&item.Id, &item.UserId, &item.Hash, &item.Symbol, &item.From, &item.To, &item.Value, &item.Block, &item.Assignment, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err := rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// GetSourceMessage - This function returns the message the user signs by the wallet of the external address of the chain to prove
+// the ownership of the address, the message is accepted for ten minutes by SetSource.
+func (e *Service) GetSourceMessage(ctx context.Context, req *pbspot.GetRequestSourceMessage) (*pbspot.ResponseSource, error) {
+
+	var (
+		response pbspot.ResponseSource
+	)
+
+	auth := e.Context.User(ctx)
+
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	chain, err := _provider.QueryChain(req.GetChainId(), true)
+	if err != nil {
+		return &response, i18n.Error(11598, "the chain was not found")
+	}
+
+	address, err := e.querySourceAddress(chain.GetPlatform(), req.GetAddress())
+	if err != nil {
+		return &response, err
+	}
+
+	response.Message = sourceMessage(auth, address, uuid.NewV4().String())
+
+	if err := e.Context.RedisClient.Set(context.Background(), fmt.Sprintf("source:message:%v:%v:%v", auth, chain.GetPlatform(), address), response.GetMessage(), 10*time.Minute).Err(); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// SetSource - This function records the external address as the verified source of the deposits of the user: the signature of the
+// message returned by GetSourceMessage is verified with the address by the adapter of the platform, and the message is
+// accepted once. The address of a platform is the source of one user, the deposits from it are marked as the deposits from
+// a verified source, which the compliance officers rely on for the travel rule, and are held from the larger amount.
+func (e *Service) SetSource(ctx context.Context, req *pbspot.SetRequestSource) (*pbspot.ResponseSource, error) {
+
+	var (
+		response pbspot.ResponseSource
+		owner    int64
+	)
+
+	auth := e.Context.User(ctx)
+
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	chain, err := _provider.QueryChain(req.GetChainId(), true)
+	if err != nil {
+		return &response, i18n.Error(11598, "the chain was not found")
+	}
+
+	address, err := e.querySourceAddress(chain.GetPlatform(), req.GetAddress())
+	if err != nil {
+		return &response, err
+	}
+
+	key := fmt.Sprintf("source:message:%v:%v:%v", auth, chain.GetPlatform(), address)
+
+	message, err := e.Context.RedisClient.Get(context.Background(), key).Result()
+	if err != nil {
+		return &response, i18n.Errorf(11789, "request the message of the address %v first, the message expires in ten minutes", address)
+	}
+
+	adapter, _ := platform.Lookup(chain.GetPlatform())
+	if err := adapter.(platform.Verifier).Verify(address, message, req.GetSignature()); err != nil {
+		return &response, i18n.Errorf(11790, "the signature does not prove the ownership of the address %v", address)
+	}
+	e.Context.RedisClient.Del(context.Background(), key)
+
+	if err := e.Context.Db.QueryRow("insert into sources (user_id, chain_id, platform, address, message, signature) values ($1, $2, $3, $4, $5, $6) on conflict (platform, address) do update set message = excluded.message, signature = excluded.signature where sources.user_id = excluded.user_id returning user_id", auth, chain.GetId(), chain.GetPlatform(), address, message, req.GetSignature()).Scan(&owner); err != nil || owner != auth {
+		return &response, i18n.Errorf(11791, "the address %v is the verified source of another account", address)
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetSources - This function returns the verified sources of the user, those revoked by the compliance officers with them.
+func (e *Service) GetSources(ctx context.Context, _ *pbspot.GetRequestSources) (*pbspot.ResponseSource, error) {
+
+	var (
+		response pbspot.ResponseSource
+	)
+
+	auth := e.Context.User(ctx)
+
+	rows, err := e.Context.Db.Query("select id, user_id, chain_id, platform, address, status, create_at from sources where user_id = $1 order by id", auth)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Source
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.ChainId, &item.Platform, &item.Address, &item.Status, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, rows.Err()
+}
+
+// DeleteSource - This function removes the verified source of the user, the source revoked by the compliance officers stays with the
+// account, so that it can not be claimed again.
+func (e *Service) DeleteSource(ctx context.Context, req *pbspot.DeleteRequestSource) (*pbspot.ResponseSource, error) {
+
+	var (
+		response pbspot.ResponseSource
+	)
+
+	auth := e.Context.User(ctx)
+
+	if _, err := e.Context.Db.Exec("delete from sources where id = $1 and user_id = $2 and status = true", req.GetId(), auth); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetCompetitions - This method returns the trading competitions, optionally only the competitions of the status: pending for
+// the upcoming competitions, processing for the running ones and filled for the finished ones.
+func (e *Service) GetCompetitions(_ context.Context, req *pbspot.GetRequestCompetitions) (*pbspot.ResponseCompetition, error) {
+
+	var (
+		response pbspot.ResponseCompetition
+	)
+
+	rows, err := e.Context.Db.Query("select id, title, metric, pairs, quote, symbol, prize, prizes, start_at, finish_at, status, coalesce(compute_at::text, ''), create_at from competitions where $1 = '' or status = $1 order by start_at desc limit 50", req.GetStatus())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Competition
+		)
+
+		if err := rows.Scan(&item.Id, &item.Title, &item.Metric, pq.Array(&item.Pairs), &item.Quote, &item.Symbol, &item.Prize, pq.Array(&item.Prizes), &item.StartAt, &item.FinishAt, &item.Status, &item.ComputeAt, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, nil
+}
+
+// GetLeaderboard - This method returns the leaderboard of the competition as it was computed last. The ids of the users are not
+// disclosed, only the authorized user receives its own place in the own field.
+func (e *Service) GetLeaderboard(ctx context.Context, req *pbspot.GetRequestLeaderboard) (*pbspot.ResponseLeaderboard, error) {
+
+	var (
+		response pbspot.ResponseLeaderboard
+	)
+
+	if req.GetLimit() == 0 || req.GetLimit() > 100 {
+		req.Limit = 100
+	}
+
+	_ = e.Context.Db.QueryRow("select count(*) as count from rankings where competition_id = $1", req.GetId()).Scan(&response.Count)
+
+	if response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := e.Context.Db.Query("select competition_id, rank, volume, pnl, prize from rankings where competition_id = $1 order by rank limit $2 offset $3", req.GetId(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Ranking
+			)
+
+			if err := rows.Scan(&item.CompetitionId, &item.Rank, &item.Volume, &item.Pnl, &item.Prize); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	// The leaderboard is public, the place of the user is only looked up when the request is authorized.
+	if auth, err := e.Context.Auth(ctx); err == nil {
+
+		var (
+			item types.Ranking
+		)
+
+		if err := e.Context.Db.QueryRow("select competition_id, user_id, rank, volume, pnl, prize from rankings where competition_id = $1 and user_id = $2", req.GetId(), auth).Scan(&item.CompetitionId, &item.UserId, &item.Rank, &item.Volume, &item.Pnl, &item.Prize); err == nil {
+			item.Own = true
+			response.Own = &item
+		}
+	}
+
+	return &response, nil
+}
@@ -2,11 +2,18 @@ package spot
 
 import (
 	"context"
-	"github.com/cryptogateway/backend-envoys/assets/blockchain"
+	"errors"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
 	"github.com/cryptogateway/backend-envoys/assets/common/decimal"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"github.com/cryptogateway/backend-envoys/assets/common/trace"
+	"github.com/cryptogateway/backend-envoys/assets/platform"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbprovider"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
 	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
+	"sync"
 	"time"
 )
 
@@ -15,10 +22,11 @@ import (
 // that it sleeps for 1 Second and replays the confirmation deposits.
 func (e *Service) deposit() {
 
-	// e.run, e.wait, and e.block are all maps in the program. The purpose of these maps is to store boolean, boolean, and
-	// int64 values respectively. These values can be referenced and modified by their associated key which is an int64
-	// value. The maps allow the program to store and access the values quickly and easily.
-	e.run, e.wait, e.block = make(map[int64]bool), make(map[int64]bool), make(map[int64]int64)
+	// e.run and e.wait are maps in the program. The purpose of these maps is to store boolean values that can be referenced and
+	// modified by their associated key which is an int64 value. The maps allow the program to store and access the values
+	// quickly and easily.
+	e.run, e.wait = make(map[int64]bool), make(map[int64]bool)
+	e.heads, e.following = make(map[int64]*announced), make(map[int64]bool)
 
 	for {
 
@@ -35,7 +43,7 @@ func (e *Service) deposit() {
 			// confirmation and parent_symbol fields from each row where the status field is true. The purpose of this code is to
 			// query the database for records with a true status and get the associated fields for each. The Context.Debug()
 			// function is used to check for errors, and the defer rows.Close() statement is used to close the rows object when the function is complete.
-			rows, err := e.Context.Db.Query("select id, rpc, platform, block, network, confirmation, parent_symbol from chains where status = $1", true)
+			rows, err := e.Context.Db.Query("select id, name, rpc, platform, block, network, confirmation, parent_symbol, stream, workers from chains where status = $1", true)
 			if e.Context.Debug(err) {
 				return
 			}
@@ -49,7 +57,7 @@ func (e *Service) deposit() {
 				// This code snippet is checking for an error while scanning the row of data and continuing if there is an error. The
 				// purpose of the if statement is to ensure that the data is scanned correctly and that the program can continue if
 				// there is an error.
-				if err := rows.Scan(&chain.Id, &chain.Rpc, &chain.Platform, &chain.Block, &chain.Network, &chain.Confirmation, &chain.ParentSymbol); e.Context.Debug(err) {
+				if err := rows.Scan(&chain.Id, &chain.Name, &chain.Rpc, &chain.Platform, &chain.Block, &chain.Network, &chain.Confirmation, &chain.ParentSymbol, &chain.Stream, &chain.Workers); e.Context.Debug(err) {
 					continue
 				}
 
@@ -60,12 +68,6 @@ func (e *Service) deposit() {
 					chain.Block = 1
 				}
 
-				// This if block is used to check if the block in the chain exists in the e.block map and if it is equal to the
-				// chain's GetBlock() method. If either of these conditions are not met, the loop will continue.
-				if block, ok := e.block[chain.GetId()]; !ok && block == chain.GetBlock() {
-					continue
-				}
-
 				// This code is checking to see if a given chain is running. If it is running, it will set the wait value for that
 				// chain to false. If it is not running, it will set the run value for that chain to true.
 				if e.run[chain.GetId()] {
@@ -81,22 +83,15 @@ func (e *Service) deposit() {
 					e.run[chain.GetId()] = true
 				}
 
-				// This switch statement is used to differentiate between two different blockchain platforms, Ethereum and Tron. It
-				// will allow the code to take different actions depending on which platform the chain is connected to.
-				switch chain.GetPlatform() {
-				case types.PlatformEthereum:
+				// The heads of the chain with the stream are followed by the subscription, the scanner of the chain reads the
+				// block as soon as it is announced.
+				e.follow(&chain)
 
-					// The purpose of this statement is to deposit Ethereum into a blockchain. It is used to send the Ethereum to the
-					// chain and to store it securely.
-					e.ethereum(&chain)
-					break
-				case types.PlatformTron:
+				// The blocks of the chain are scanned from the cursor of the chain, in a batch when the scanner is behind.
+				e.scan(&chain)
 
-					// The purpose of this code is to deposit Tron (a cryptocurrency) on a blockchain platform. It is used to transfer
-					// funds from one account to another and keep a record of the transaction on the blockchain.
-					e.tron(&chain)
-					break
-				}
+				// The deposits of the chain that are not final are checked against the canonical chain before they are confirmed.
+				e.reorg(&chain)
 
 				time.Sleep(1 * time.Second)
 			}
@@ -108,6 +103,329 @@ func (e *Service) deposit() {
 	}
 }
 
+// reorg - This function checks the deposits of the chain that are not final against the canonical chain, when the client of the
+// platform of the chain is canonical: the pending and the held deposits, and the deposits credited within the depth of
+// the reorganizations. The hash of the canonical block is asked once per block of the deposits, the deposits of the block
+// that was replaced are located again by the writeReorg function, and the reverted deposits within the depth are located
+// again, since their transactions may be mined again later.
+func (e *Service) reorg(chain *types.Chain) {
+
+	adapter, ok := platform.Lookup(chain.GetPlatform())
+	if !ok {
+		return
+	}
+
+	client, err := adapter.Dial(chain.GetRpc())
+	if err != nil { // No debug....
+		return
+	}
+
+	canonical, ok := client.(platform.Canonical)
+	if !ok {
+		return
+	}
+
+	type block struct {
+		number int64
+		hash   string
+	}
+
+	var (
+		blocks []block
+	)
+
+	// The blocks are read before the node is asked, so that the connection of the database is not held by the requests.
+	rows, err := e.Context.Db.Query(`select distinct block, block_hash from transactions where chain_id = $1 and assignment = $2 and block_hash <> '' and (status = any($3) or (status = $4 and block > $5))`, chain.GetId(), types.AssignmentDeposit, pq.Array([]string{types.StatusPending, types.StatusHold}), types.StatusFilled, chain.GetBlock()-reorgDepth)
+	if e.Context.Debug(err) {
+		return
+	}
+
+	for rows.Next() {
+
+		var (
+			item block
+		)
+
+		if err := rows.Scan(&item.number, &item.hash); e.Context.Debug(err) {
+			rows.Close()
+			return
+		}
+		blocks = append(blocks, item)
+	}
+	rows.Close()
+
+	for _, item := range blocks {
+
+		hash, err := canonical.Hash(item.number)
+		if err != nil { // No debug....
+			return
+		}
+
+		if hash == item.hash {
+			continue
+		}
+
+		e.Context.Logger.Warnf("[REORG]: the block %v of the chain %v was replaced, %v is now %v", item.number, chain.GetName(), item.hash, hash)
+
+		if !e.writeReorg(chain, canonical, item.number, item.hash, hash) {
+			return
+		}
+	}
+
+	e.relocate(chain, canonical)
+}
+
+// relocate - This function locates again the transactions of the deposits the reorganizations of the chain reverted within the
+// depth of the reorganizations, the deposit whose transaction was mined again is pending again and waits for its
+// confirmations from its new block.
+func (e *Service) relocate(chain *types.Chain, canonical platform.Canonical) {
+
+	var (
+		items []*types.Transaction
+	)
+
+	rows, err := e.Context.Db.Query(`select id, hash, user_id, symbol, value, block, block_hash from transactions where chain_id = $1 and assignment = $2 and status = $3 and block > $4`, chain.GetId(), types.AssignmentDeposit, types.StatusOrphaned, chain.GetBlock()-reorgDepth)
+	if e.Context.Debug(err) {
+		return
+	}
+
+	for rows.Next() {
+
+		var (
+			item types.Transaction
+		)
+
+		if err := rows.Scan(&item.Id, &item.Hash, &item.UserId, &item.Symbol, &item.Value, &item.Block, &item.BlockHash); e.Context.Debug(err) {
+			rows.Close()
+			return
+		}
+		items = append(items, &item)
+	}
+	rows.Close()
+
+	for _, item := range items {
+
+		number, err := canonical.Locate(item.GetHash())
+		if err != nil || number == 0 { // No debug....
+			continue
+		}
+
+		hash, err := canonical.Hash(number)
+		if err != nil { // No debug....
+			continue
+		}
+
+		if _, err := e.Context.Db.Exec("update transactions set status = $2, block = $3, block_hash = $4, confirmation = 0, error = '' where id = $1 and status = $5", item.GetId(), types.StatusPending, number, hash, types.StatusOrphaned); e.Context.Debug(err) {
+			return
+		}
+
+		if _, err := e.Context.Db.Exec("insert into reorgs (chain_id, transaction_id, user_id, symbol, value, block, hash, canonical, relocated, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", chain.GetId(), item.GetId(), item.GetUserId(), item.GetSymbol(), item.GetValue(), item.GetBlock(), item.GetBlockHash(), "", number, types.ReorgLocated); e.Context.Debug(err) {
+			return
+		}
+
+		item.Status, item.Block, item.BlockHash = types.StatusPending, number, hash
+		e.Context.Debug(e.Context.Publish(item, "exchange", "deposit/status"))
+	}
+}
+
+// scan - This function scans the blocks of the chain from the cursor of the chain. The workers of the chain read as many blocks
+// at the same time, up to the head of the chain, and when the scanner is behind the head by the catch-up of the
+// configuration, after the service was down for hours, they read the batch of the blocks at the rate of the
+// configuration, so that the scanner catches up without flooding the node. The blocks are read in parallel, but their
+// deposits are recorded and the cursor is moved in the order of the blocks, the scanning stops at the first block that
+// is not read or recorded, it is read again by the next pass. The chain reported by the webhooks of a provider alone is
+// not scanned. The scanner of the chain whose head is streamed by the subscription does not ask the node for the head,
+// and does not read the block that was not announced yet.
+func (e *Service) scan(chain *types.Chain) {
+
+	var (
+		batch   int64 = 1
+		workers int64 = 1
+		pause   time.Duration
+		hook    = e.Context.Webhook(chain.GetId())
+	)
+
+	// The workers of the chain are no more than the scanners of the configuration read at the same time.
+	if config := e.Context.Scanner; config != nil && config.Workers > 1 {
+		workers = int64(chain.GetWorkers())
+		if workers > int64(config.Workers) {
+			workers = int64(config.Workers)
+		}
+		if workers < 1 {
+			workers = 1
+		}
+	}
+
+	// The head of the chain is asked for the lag of the scanner, the scanner of the chain whose node does not report the
+	// head scans one block per pass, as it did before.
+	if head, streamed, err := e.queryHead(chain); err == nil {
+
+		e.writeHead(chain, head, streamed)
+
+		// The chain whose transfers are reported by the webhooks of a provider instead is not scanned, its cursor only
+		// follows the head of the chain, so that the deposits of the webhooks are confirmed.
+		if hook != nil && !hook.Scan {
+			chain.Block = head
+			e.writeCursor(chain)
+			return
+		}
+
+		// The block that was not announced by the subscription is not mined yet, the node is not asked for it.
+		if streamed && head < chain.GetBlock() {
+			return
+		}
+
+		// The blocks up to the head are read by the workers, one block is asked for when the scanner is at the head.
+		if ready := head - chain.GetBlock() + 1; ready > 1 {
+			batch = ready
+			if batch > workers {
+				batch = workers
+			}
+		}
+
+		if config := e.Context.Scanner; config != nil && config.Batch > 1 && head-chain.GetBlock() >= int64(config.Catchup) {
+			batch = int64(config.Batch)
+			if config.Rate > 0 {
+				pause = time.Second / time.Duration(config.Rate)
+			}
+		}
+	}
+
+	if hook != nil && !hook.Scan {
+		return
+	}
+
+	for offset := int64(0); offset < batch; offset += workers {
+
+		var (
+			size = workers
+			wg   sync.WaitGroup
+		)
+
+		if size > batch-offset {
+			size = batch - offset
+		}
+
+		// The blocks of the range are read by the workers at the same time, every worker dials the node of its own.
+		blocks := make([]block, size)
+		for i := range blocks {
+			wg.Add(1)
+			go func(item *block, number int64) {
+				defer wg.Done()
+				item.deposits, item.err = e.read(chain, number)
+			}(&blocks[i], chain.GetBlock()+int64(i))
+		}
+		wg.Wait()
+
+		// The deposits of the blocks are recorded in the order of the blocks, the cursor of the scanner is moved past each
+		// block once its deposits are recorded, so that it never passes a block that was not.
+		for _, item := range blocks {
+
+			if item.err != nil {
+				if errors.Is(item.err, errNode) {
+					e.heartbeat(chain, types.ComponentDown, item.err.Error(), 0)
+				} else {
+					e.heartbeat(chain, types.ComponentOperational, item.err.Error(), 0)
+				}
+				return
+			}
+
+			if !e.writeDeposits(chain, item.deposits) || !e.writeCursor(chain) {
+				return
+			}
+			chain.Block++
+		}
+
+		if batch > 1 {
+			time.Sleep(pause * time.Duration(size))
+		}
+	}
+}
+
+// block - The type block struct holds the deposits a worker of the scanner read from a block of the chain, or the error the block
+// was not read with, until the deposits are recorded in the order of the blocks.
+type block struct {
+	deposits []*types.Transaction
+	err      error
+}
+
+// read - This function reads the transfers of the block of the chain with the number with the adapter of the platform of the
+// chain and returns the deposits among them.
+func (e *Service) read(chain *types.Chain, number int64) ([]*types.Transaction, error) {
+
+	adapter, ok := platform.Lookup(chain.GetPlatform())
+	if !ok {
+		return nil, fmt.Errorf("the blocks of the platform %v are not scanned", chain.GetPlatform())
+	}
+
+	// The block that is not read because the node does not answer is reported as the chain that is down.
+	client, err := adapter.Dial(chain.GetRpc())
+	if err != nil { // No debug....
+		return nil, errNode
+	}
+
+	// The clients of the platforms that find the transfers by the accounts they touch are handed the wallets of the platform
+	// and the tokens of the chain first.
+	if tracker, ok := client.(platform.Tracker); ok {
+
+		addresses, contracts, err := e.queryTrack(chain)
+		if err != nil {
+			return nil, err
+		}
+		tracker.Track(addresses, contracts)
+	}
+
+	transfers, err := client.Scan(number)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.queryDeposits(chain, adapter, transfers), nil
+}
+
+// queryTrack - This function returns the addresses of the wallets of the platform of the chain and the addresses of the contracts
+// of the tokens of the chain, the accounts the clients of the trackers find the transfers by.
+func (e *Service) queryTrack(chain *types.Chain) (addresses, contracts []string, err error) {
+
+	rows, err := e.Context.Db.Query("select address from wallets where platform = $1", chain.GetPlatform())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			address string
+		)
+
+		if err := rows.Scan(&address); err != nil {
+			return nil, nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	rows, err = e.Context.Db.Query("select address from contracts where chain_id = $1", chain.GetId())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			address string
+		)
+
+		if err := rows.Scan(&address); err != nil {
+			return nil, nil, err
+		}
+		contracts = append(contracts, address)
+	}
+
+	return addresses, contracts, nil
+}
+
 // withdrawal - This function is used to replay pending withdraw transactions. It checks for transactions with a status of pending, a
 // transaction type of withdraws, and a financial type of crypto in the database. It then loops through these
 // transactions and attempts to transfer the funds. It also handles cases where there are fees to be paid, by attempting
@@ -140,7 +458,7 @@ func (e *Service) withdrawal() {
 			// query the database, passing in the parameters as variables. The query will return rows, which are stored in the
 			// rows variable. The error from the query is stored in the err variable, and an error is printed out if err is not
 			// nil. The rows returned by the query are then closed when the function is finished executing.
-			rows, err := e.Context.Db.Query(`select id, symbol, "to", chain_id, fees, value, price, platform, protocol, allocation from transactions where status = $1 and assignment = $2 and "group" = $3`, types.StatusPending, types.AssignmentWithdrawal, types.GroupCrypto)
+			rows, err := e.Context.Db.Query(`select id, symbol, "to", chain_id, fees, value, price, platform, protocol, allocation, user_id from transactions where status = $1 and assignment = $2 and "group" = $3`, types.StatusPending, types.AssignmentWithdrawal, types.GroupCrypto)
 			if e.Context.Debug(err) {
 				return
 			}
@@ -160,10 +478,16 @@ func (e *Service) withdrawal() {
 				// This code is used to scan a row of data from a database and store each of the values in variables. The if
 				// statement checks for an error while scanning and logs the error with the context.Debug() method. If an error
 				// occurs, the loop will continue, otherwise the values are stored in the variables.
-				if err := rows.Scan(&item.Id, &item.Symbol, &item.To, &item.ChainId, &item.Fees, &item.Value, &item.Price, &item.Platform, &item.Protocol, &item.Allocation); e.Context.Debug(err) {
+				if err := rows.Scan(&item.Id, &item.Symbol, &item.To, &item.ChainId, &item.Fees, &item.Value, &item.Price, &item.Platform, &item.Protocol, &item.Allocation, &item.UserId); e.Context.Debug(err) {
 					return
 				}
 
+				// The withdrawals of the accounts restricted after they were requested are held in the pending status until the
+				// restriction is lifted or expires.
+				if err := e.Context.Restricted(item.GetUserId(), types.ActionWithdraw); err != nil {
+					continue
+				}
+
 				// This code is setting up a chain and checking for errors. If an error is encountered, the code will continue on
 				// without executing the rest of the code. This allows the code to continue running in the event of an error.
 				chain, err := _provider.QueryChain(item.GetChainId(), true)
@@ -171,6 +495,12 @@ func (e *Service) withdrawal() {
 					return
 				}
 
+				// The withdrawals are held in the pending status while they are disabled for the asset on the chain, they are
+				// processed as soon as the operators enable them again.
+				if network, err := _provider.QueryAssetChain(item.GetSymbol(), item.GetChainId()); err != nil || !network.GetWithdraw() {
+					continue
+				}
+
 				// This if statement is used to check if the item's protocol is set to mainnet. Mainnet is the original and most
 				// widely used network for transactions to take place on. If the item's protocol is set to mainnet, then the code
 				// inside the if statement will execute.
@@ -180,7 +510,7 @@ func (e *Service) withdrawal() {
 					// This code is checking to see if the query returns a row with a value greater than 0. The query is looking for a
 					// specific combination of values in the reserves table that match the item values passed in. The code is searching
 					// for a row with a value greater than 0 and if one is found, it stores the value and user_id in the reserve object.
-					if _ = e.Context.Db.QueryRow("select value, user_id from reserves where symbol = $1 and value >= $2 and platform = $3 and protocol = $4 and lock = $5", item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), false).Scan(&reserve.Value, &reserve.UserId); reserve.GetValue() > 0 {
+					if _ = e.Context.Db.QueryRow("select value, address, user_id from reserves where symbol = $1 and value >= $2 and platform = $3 and protocol = $4 and lock = $5", item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), false).Scan(&reserve.Value, &reserve.To, &reserve.UserId); reserve.GetValue() > 0 {
 
 						// This piece of code is used to publish a transaction message on a message broker. The message contains the
 						// transaction ID, fees, and hash. The message is sent to the exchange topic with the label "withdraw/status". The code
@@ -210,7 +540,7 @@ func (e *Service) withdrawal() {
 						// transfer function are used to identify the user, item, symbol, recipient, value, price, and protocol. The chain
 						// and pbspot.Allocation_EXTERNAL parameters are used to specify which blockchain the transfer should take place on
 						// and to specify the allocation type.
-						e.transfer(reserve.GetUserId(), item.GetId(), item.GetSymbol(), item.GetTo(), item.GetValue(), 0, item.GetProtocol(), chain, item.GetAllocation())
+						e.transfer(reserve.GetUserId(), item.GetId(), reserve.GetTo(), item.GetSymbol(), item.GetTo(), item.GetValue(), 0, item.GetProtocol(), chain, item.GetAllocation())
 					}
 
 				} else {
@@ -219,7 +549,7 @@ func (e *Service) withdrawal() {
 					// by its platform, as well as by protocol, symbol, and number of funds.
 					// This code is part of a transaction process. The purpose of the code is to find funds in a reserve asset to use
 					// for a transaction, and to find funds in a reserve asset to use for a fee. If the fee is not found, the transaction is reversed. The code is also responsible for setting locks on the funds in the reserve asset to prevent them from being used for another transaction.
-					if _ = e.Context.Db.QueryRow("select a.value, a.user_id from reserves a inner join reserves b on case when b.user_id = a.user_id then b.user_id = a.user_id and b.symbol = $6 and b.platform = a.platform and b.protocol = $7 and b.value >= $5 and b.lock = $8 end where a.symbol = $1 and a.value >= $2 and a.platform = $3 and a.protocol = $4 and a.lock = $8", item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), item.GetFees(), chain.GetParentSymbol(), types.ProtocolMainnet, false).Scan(&reserve.Value, &reserve.UserId); reserve.GetValue() > 0 {
+					if _ = e.Context.Db.QueryRow("select a.value, a.address, a.user_id from reserves a inner join reserves b on case when b.user_id = a.user_id then b.user_id = a.user_id and b.address = a.address and b.symbol = $6 and b.platform = a.platform and b.protocol = $7 and b.value >= $5 and b.lock = $8 end where a.symbol = $1 and a.value >= $2 and a.platform = $3 and a.protocol = $4 and a.lock = $8", item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), item.GetFees(), chain.GetParentSymbol(), types.ProtocolMainnet, false).Scan(&reserve.Value, &reserve.To, &reserve.UserId); reserve.GetValue() > 0 {
 
 						// This piece of code is used to publish a transaction message on a message broker. The message contains the
 						// transaction ID, fees, and hash. The message is sent to the exchange topic with the label "withdraw/status". The code
@@ -247,7 +577,7 @@ func (e *Service) withdrawal() {
 						// transfer function are used to identify the user, item, symbol, recipient, value, price, and protocol. The chain
 						// and pbspot.Allocation_EXTERNAL parameters are used to specify which blockchain the transfer should take place on
 						// and to specify the allocation type.
-						e.transfer(reserve.GetUserId(), item.GetId(), item.GetSymbol(), item.GetTo(), item.GetValue(), item.GetPrice(), item.GetProtocol(), chain, types.AllocationExternal)
+						e.transfer(reserve.GetUserId(), item.GetId(), reserve.GetTo(), item.GetSymbol(), item.GetTo(), item.GetValue(), item.GetPrice(), item.GetProtocol(), chain, types.AllocationExternal)
 
 					} else {
 
@@ -267,6 +597,23 @@ func (e *Service) withdrawal() {
 				}
 			}
 		}()
+
+		// The processor of the withdrawals is reported to the status page, it is degraded while the withdrawals are waiting
+		// for the reserves longer than half an hour.
+		var (
+			stuck int
+		)
+
+		if err := e.Context.Db.QueryRow(`select count(*) from transactions where status = $1 and assignment = $2 and "group" = $3 and create_at < now() - interval '30 minutes'`, types.StatusPending, types.AssignmentWithdrawal, types.GroupCrypto).Scan(&stuck); e.Context.Debug(err) {
+			e.Context.Heartbeat("withdrawal", types.ComponentWithdrawal, types.ComponentDown, "the pending withdrawals can not be read", 0)
+			continue
+		}
+
+		if stuck > 0 {
+			e.Context.Heartbeat("withdrawal", types.ComponentWithdrawal, types.ComponentDegraded, fmt.Sprintf("%d withdrawals are pending for more than 30 minutes", stuck), 0)
+		} else {
+			e.Context.Heartbeat("withdrawal", types.ComponentWithdrawal, types.ComponentOperational, "", 0)
+		}
 	}
 }
 
@@ -394,92 +741,514 @@ func (e *Service) reward() {
 	}
 }
 
-// confirmation - This function is used to check the status of pending deposits. It queries the database for transactions with a status
-// of PENDING and tx type of DEPOSIT. It then checks the status of the hash associated with the transaction on the
-// relevant blockchain. If the status is successful, the deposit is credited to the local wallet address and the status
-// of the transaction is changed to FILLED. If the status is unsuccessful, the status is changed to FAILED. If the number
-// of confirmations is not yet met, the number of confirmations is updated in the database.
-func (e *Service) confirmation() {
+// gas - This function watches the coin of the hot wallets of the tokens of the chains with a gas reserve, every minute. The hot
+// wallet whose coin for the gas of Ethereum or the energy of Tron falls below the threshold of the chain is topped up
+// from the gas reserve of the chain to the target, before its token withdrawals fail for the lack of the fees, and the
+// operators are alerted once when the gas reserve runs low or cannot top up the hot wallets, until it is refilled.
+func (e *Service) gas() {
 
-	// Creates a new API client to interact with the Provider API.
-	migrate := pbprovider.NewApiClient(e.Context.GrpcClient)
+	defer func() {
+		if r := recover(); e.Context.Debug(r) {
+			return
+		}
+	}()
+
+	var (
+		low = make(map[int64]bool)
+	)
+
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+		for _, config := range e.Context.Gas {
+			e.refuel(config, low)
+		}
+	}
+}
+
+// refuel - This function tops up the hot wallets of the tokens of the chain whose coin is below the threshold of the gas reserve of
+// the chain, and reports the gas reserve to the metrics, to the status page and, when it runs low, to the operators.
+func (e *Service) refuel(config *assets.Gas, low map[int64]bool) {
+
+	var (
+		addresses []string
+		short     bool
+	)
 
 	// Creates a service provider to be used in the given context, providing the necessary services for the application.
 	_provider := provider.Service{
 		Context: e.Context,
 	}
 
-	// This code is performing a SQL query to select information from a database. The purpose is to select a specific set of
-	// information from the database based on the parameters of the query. The query is selecting the fields' id, hash,
-	// symbol, "to", fees, chain_id, user_id, value, confirmation, block, platform, protocol, and create_at where the status
-	// is equal to pbspot.Status_PENDING and the assignment is equal to pbspot.TxType_DEPOSIT. The code also checks for an error and closes the rows when finished.
-	rows, err := e.Context.Db.Query(`select id, hash, symbol, "to", fees, chain_id, user_id, value, confirmation, block, platform, protocol, allocation, parent, create_at from transactions where status = $1 and assignment = $2`, types.StatusPending, types.AssignmentDeposit)
+	chain, err := _provider.QueryChain(config.ChainId, true)
+	if err != nil {
+		return
+	}
+
+	client, err := platform.Dial(chain.GetRpc(), chain.GetPlatform())
+	if e.Context.Debug(err) {
+		return
+	}
+
+	balance, err := e.queryCoin(client, chain, config.Reserve)
+	if e.Context.Debug(err) {
+		return
+	}
+	fuel.Set(balance, chain.GetName())
+
+	// The hot wallets of the tokens are the addresses that hold the reserves of the tokens of the chain, the gas reserve
+	// itself is not topped up.
+	rows, err := e.Context.Db.Query(`select distinct r.address from reserves r where r.platform = $1 and r.protocol != $2 and r.value > 0 and r.address != $3 and exists (select 1 from contracts c where c.symbol = r.symbol and c.chain_id = $4)`, chain.GetPlatform(), types.ProtocolMainnet, config.Reserve, chain.GetId())
 	if e.Context.Debug(err) {
 		return
 	}
-	defer rows.Close()
 
-	// The purpose of the for loop is to iterate through each row of a result set from a database query. The rows.Next()
-	// function is used to move to the next row in the result set.
 	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); e.Context.Debug(err) {
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	rows.Close()
+
+	item := types.GasReserve{
+		ChainId: chain.GetId(),
+		Name:    chain.GetName(),
+		Address: config.Reserve,
+		Symbol:  chain.GetParentSymbol(),
+		Alert:   config.Alert,
+	}
+
+	for _, address := range addresses {
 
-		// The above code is declaring a variable called "item" of type "types.Transaction". This means that the variable
-		// "item" will be used to store information related to a types transaction.
 		var (
-			item types.Transaction
+			pending bool
 		)
 
-		// This code is part of a loop that is iterating over results from a database query. The purpose of the code is to scan
-		// each row of the query result into their corresponding variables. If an error is encountered while scanning, the loop
-		// continues to the next row. The e.Context.Debug() function logs the error but does not cause the program to stop.
-		if err := rows.Scan(&item.Id, &item.Hash, &item.Symbol, &item.To, &item.Fees, &item.ChainId, &item.UserId, &item.Value, &item.Confirmation, &item.Block, &item.Platform, &item.Protocol, &item.Allocation, &item.Parent, &item.CreateAt); e.Context.Debug(err) {
-			return
+		coin, err := e.queryCoin(client, chain, address)
+		if err != nil || coin >= config.Threshold {
+			continue
 		}
 
-		// The purpose of this code is to get a chain from the "e" object, using the item's chain ID. If an error occurs, the
-		// function will return, and the error will be printed if debugging is enabled.
-		chain, err := _provider.QueryChain(item.GetChainId(), true)
-		if e.Context.Debug(err) {
-			return
+		// The hot wallet that waits for the top-up that was sent before is not topped up again.
+		if _ = e.Context.Db.QueryRow(`select exists(select id from transactions where "to" = $1 and symbol = $2 and chain_id = $3 and allocation = $4 and status in ($5, $6))::bool`, address, chain.GetParentSymbol(), chain.GetId(), types.AllocationInternal, types.StatusPending, types.StatusProcessing).Scan(&pending); pending {
+			continue
 		}
 
-		// This code is used to connect to a blockchain using the GetRpc and GetPlatform methods of the chain object. The
-		// client object is used to make requests to the blockchain and the err object will be used to check for any errors
-		// that occurred in the process. If an error is encountered, the code will return to avoid any further issues.
-		client, err := blockchain.Dial(chain.GetRpc(), chain.GetPlatform())
-		if e.Context.Debug(err) {
-			return
+		value := decimal.New(config.Target).Sub(coin).Float()
+		if value > balance {
+			item.Required = decimal.New(item.GetRequired()).Add(value).Float()
+			short = true
+			continue
 		}
 
-		// This code is part of a deposit process. The purpose of this code is to check a deposit's status, which is tracked
-		// using the client.Status(item.Hash) function. If the deposit is confirmed, the code credits the new deposit to the
-		// local wallet address, updates the deposits pending status to success status, and publishes the status to the
-		// exchange. If the deposit is not confirmed, it updates the confirmation number in the database. If the deposit fails, it updates the status in the database and publishes the status to the exchange.
-		if client.Status(item.Hash) {
+		if err := e.writeGas(chain, config.Reserve, address, value); e.Context.Debug(err) {
+			short = true
+			continue
+		}
 
-			// The purpose of this code is to check if the difference between the current block and the item block is greater than
-			// or equal to the confirmation number of the chain and if the item confirmation is greater than or equal to the
-			// chain's confirmation number. If both conditions are true, then the subsequent code will execute.
-			if (chain.GetBlock()-item.GetBlock()) >= chain.GetConfirmation() && item.GetConfirmation() >= chain.GetConfirmation() {
+		balance = decimal.New(balance).Sub(value).Float()
+		topups.Inc(chain.GetName())
+	}
+	item.Balance = balance
 
-				// The purpose of this code is to get the price of a requested symbol given a base unit. It uses the GetPrice method
-				// from the e object to get the price, and if the GetPrice method returns an error, the Context.Error() method
-				// handles the error. The code also checks that the protocol is MAINNET before attempting to get the price. If the
-				// price is greater than 0, the chain fees are set using the contract's fees and the price.
-				if item.GetProtocol() != types.ProtocolMainnet {
+	if balance >= config.Alert && !short {
+		low[chain.GetId()] = false
+		e.Context.Heartbeat(fmt.Sprintf("gas/%v", chain.GetName()), types.ComponentWithdrawal, types.ComponentOperational, "", 0)
+		return
+	}
 
-					// This code is used to get a contract from the Ethereum network. The contract is retrieved using the item's symbol
-					// and chain ID. If there is an error, the Context.Debug() function will be used to return an error message.
-					contract, err := _provider.QueryContract(item.GetSymbol(), item.GetChainId())
-					if e.Context.Debug(err) {
-						return
-					}
+	e.Context.Heartbeat(fmt.Sprintf("gas/%v", chain.GetName()), types.ComponentWithdrawal, types.ComponentDegraded, fmt.Sprintf("the gas reserve holds %v %v", balance, chain.GetParentSymbol()), 0)
 
-					// This code is used to get the price of a requested symbol given a base unit. It uses the GetPrice method from the e
-					// object and passes in a context.Background() and a GetRequestPrice object containing the base unit and the
-					// requested symbol. If the GetPrice method returns an error, the error is returned in the response and the Context.Error() method handles the error.
-					price, err := migrate.GetPrice(context.Background(), &pbprovider.GetRequestPrice{BaseUnit: chain.GetParentSymbol(), QuoteUnit: item.GetSymbol()})
+	// The operators are alerted once, when the gas reserve runs low, and again after it was refilled and ran low again.
+	if low[chain.GetId()] {
+		return
+	}
+	low[chain.GetId()] = true
+
+	e.Context.Logger.Warnf("[GAS]: the gas reserve %v of the chain %v holds %v %v, the hot wallets are short of %v %v", config.Reserve, chain.GetName(), balance, chain.GetParentSymbol(), item.GetRequired(), chain.GetParentSymbol())
+
+	if err := e.Context.Publish(&item, "exchange", "support/gas"); e.Context.Debug(err) {
+		return
+	}
+}
+
+// rebalance - This function rebalances the reserves the withdrawals are sent from, every minute. The withdrawals of a currency
+// that wait longer than the delay because no reserve of their platform holds their value are covered by a move of the
+// reserves: from the other exchange addresses of the platform to its largest reserve, or else from another platform
+// through a bridge configured by the operators. The approved moves between the addresses are sent, and the sent moves are
+// closed when their transfers arrive or fail.
+func (e *Service) rebalance() {
+
+	defer func() {
+		if r := recover(); e.Context.Debug(r) {
+			return
+		}
+	}()
+
+	var (
+		short = make(map[string]bool)
+	)
+
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		config := e.Context.Rebalance
+		if config == nil {
+			continue
+		}
+
+		e.propose(config, short)
+		e.move()
+	}
+}
+
+// propose - This function proposes the moves of the reserves for the withdrawals that no reserve covers, one round of the moves
+// at a time per currency, platform and protocol, and alerts the operators once when no reserve of the exchange can cover
+// them, until they are covered.
+func (e *Service) propose(config *assets.Rebalance, short map[string]bool) {
+
+	var (
+		waiting []*types.Transaction
+		status  = types.StatusReview
+	)
+
+	if config.Execute {
+		status = types.StatusPending
+	}
+
+	// The largest waiting withdrawal of every currency, platform and protocol is the one the reserve has to cover, the
+	// withdrawals waiting for the fees of the tokens are covered by the reward replay.
+	rows, err := e.Context.Db.Query(`select distinct on (symbol, platform, protocol) symbol, platform, protocol, chain_id, value from transactions where status = $1 and assignment = $2 and "group" = $3 and allocation = $4 and create_at < now() - make_interval(mins => $5) order by symbol, platform, protocol, value desc`, types.StatusPending, types.AssignmentWithdrawal, types.GroupCrypto, types.AllocationExternal, config.Delay)
+	if e.Context.Debug(err) {
+		return
+	}
+
+	for rows.Next() {
+
+		var (
+			item types.Transaction
+		)
+
+		if err := rows.Scan(&item.Symbol, &item.Platform, &item.Protocol, &item.ChainId, &item.Value); e.Context.Debug(err) {
+			continue
+		}
+		waiting = append(waiting, &item)
+	}
+	rows.Close()
+
+	for _, item := range waiting {
+
+		var (
+			key   = fmt.Sprintf("%v/%v/%v", item.GetSymbol(), item.GetPlatform(), item.GetProtocol())
+			open  bool
+			top   types.Transaction
+			moves []*types.Rebalance
+		)
+
+		// The currency whose moves were proposed or sent waits for them.
+		if _ = e.Context.Db.QueryRow(`select exists(select id from rebalances where symbol = $1 and platform = $2 and protocol = $3 and status in ($4, $5, $6))::bool`, item.GetSymbol(), item.GetPlatform(), item.GetProtocol(), types.StatusReview, types.StatusPending, types.StatusProcessing).Scan(&open); open {
+			continue
+		}
+
+		// The largest reserve is the recipient of the moves, the withdrawal it already covers waits only for its lock.
+		_ = e.Context.Db.QueryRow("select user_id, address, value from reserves where symbol = $1 and platform = $2 and protocol = $3 order by value desc limit 1", item.GetSymbol(), item.GetPlatform(), item.GetProtocol()).Scan(&top.UserId, &top.To, &top.Value)
+		if top.GetValue() >= item.GetValue() {
+			short[key] = false
+			continue
+		}
+
+		shortage := decimal.New(item.GetValue()).Sub(top.GetValue()).Float()
+
+		// The other reserves of the platform are moved to the largest one, the largest of them first, until it covers the
+		// withdrawal. The moves are proposed only if the reserves of the platform cover the shortage together.
+		if len(top.GetTo()) > 0 {
+
+			rest := shortage
+
+			rows, err := e.Context.Db.Query("select user_id, address, value from reserves where symbol = $1 and platform = $2 and protocol = $3 and address != $4 and value > 0 and lock = $5 order by value desc", item.GetSymbol(), item.GetPlatform(), item.GetProtocol(), top.GetTo(), false)
+			if e.Context.Debug(err) {
+				continue
+			}
+
+			for rest > 0 && rows.Next() {
+
+				var (
+					source types.Transaction
+				)
+
+				if err := rows.Scan(&source.UserId, &source.To, &source.Value); e.Context.Debug(err) {
+					break
+				}
+
+				value := source.GetValue()
+				if value > rest {
+					value = rest
+				}
+				rest = decimal.New(rest).Sub(value).Float()
+
+				moves = append(moves, &types.Rebalance{
+					Kind:         types.RebalanceTransfer,
+					Symbol:       item.GetSymbol(),
+					Platform:     item.GetPlatform(),
+					Protocol:     item.GetProtocol(),
+					FromPlatform: item.GetPlatform(),
+					FromProtocol: item.GetProtocol(),
+					ChainId:      item.GetChainId(),
+					UserId:       source.GetUserId(),
+					From:         source.GetTo(),
+					To:           top.GetTo(),
+					Value:        value,
+					Shortage:     shortage,
+					Status:       status,
+				})
+			}
+			rows.Close()
+
+			if rest > 0 {
+				moves = nil
+			}
+		}
+
+		// The platform that cannot cover the withdrawal is covered from another platform through a bridge, with the largest
+		// reserve of the currency on the source of the bridge that covers the shortage and the fees of the bridge. The moves
+		// through the bridges are carried out by the operators.
+		if len(moves) == 0 {
+
+			var (
+				bridge types.Bridge
+				source types.Transaction
+			)
+
+			if _ = e.Context.Db.QueryRow("select b.id, b.platform, b.protocol, b.fees, r.user_id, r.address from bridges b inner join reserves r on r.symbol = b.symbol and r.platform = b.platform and r.protocol = b.protocol and r.lock = $5 and r.value >= $4 + b.fees where b.symbol = $1 and b.to_platform = $2 and b.to_protocol = $3 and b.status = $6 order by r.value desc limit 1", item.GetSymbol(), item.GetPlatform(), item.GetProtocol(), shortage, false, true).Scan(&bridge.Id, &bridge.Platform, &bridge.Protocol, &bridge.Fees, &source.UserId, &source.To); bridge.GetId() > 0 {
+				moves = append(moves, &types.Rebalance{
+					Kind:         types.RebalanceBridge,
+					Symbol:       item.GetSymbol(),
+					Platform:     item.GetPlatform(),
+					Protocol:     item.GetProtocol(),
+					FromPlatform: bridge.GetPlatform(),
+					FromProtocol: bridge.GetProtocol(),
+					ChainId:      item.GetChainId(),
+					UserId:       source.GetUserId(),
+					From:         source.GetTo(),
+					To:           top.GetTo(),
+					Value:        decimal.New(shortage).Add(bridge.GetFees()).Float(),
+					Shortage:     shortage,
+					BridgeId:     bridge.GetId(),
+					Status:       types.StatusReview,
+				})
+			}
+		}
+
+		if len(moves) > 0 {
+			short[key] = false
+
+			for _, move := range moves {
+				if err := e.writeRebalance(move); e.Context.Debug(err) {
+					break
+				}
+			}
+			continue
+		}
+
+		// The operators are alerted once, when the reserves of the exchange cannot cover the withdrawals, and again after they
+		// were covered and ran short again.
+		if short[key] {
+			continue
+		}
+		short[key] = true
+
+		e.Context.Logger.Warnf("[REBALANCE]: the withdrawals of %v on %v/%v are short of %v, no reserve of the exchange can cover them", item.GetSymbol(), item.GetPlatform(), item.GetProtocol(), shortage)
+
+		if err := e.Context.Publish(&types.Rebalance{
+			Symbol:   item.GetSymbol(),
+			Platform: item.GetPlatform(),
+			Protocol: item.GetProtocol(),
+			ChainId:  item.GetChainId(),
+			Shortage: shortage,
+			Status:   types.StatusFailed,
+		}, "exchange", "support/rebalance"); e.Context.Debug(err) {
+			return
+		}
+	}
+}
+
+// move - This function sends the approved moves of the reserves between the exchange addresses and closes the sent moves: the
+// move is filled when its transfer arrives to the reserve of the recipient address and failed when the transfer fails.
+func (e *Service) move() {
+
+	var (
+		approved []*types.Rebalance
+	)
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	rows, err := e.Context.Db.Query(`select id, chain_id, user_id, symbol, protocol, "from", "to", value from rebalances where kind = $1 and status = $2 order by id`, types.RebalanceTransfer, types.StatusPending)
+	if e.Context.Debug(err) {
+		return
+	}
+
+	for rows.Next() {
+
+		var (
+			item types.Rebalance
+		)
+
+		if err := rows.Scan(&item.Id, &item.ChainId, &item.UserId, &item.Symbol, &item.Protocol, &item.From, &item.To, &item.Value); e.Context.Debug(err) {
+			continue
+		}
+		approved = append(approved, &item)
+	}
+	rows.Close()
+
+	for _, item := range approved {
+
+		var (
+			value float64
+		)
+
+		chain, err := _provider.QueryChain(item.GetChainId(), true)
+		if e.Context.Debug(err) {
+			continue
+		}
+
+		// The move is claimed first and only if it is still approved, so that it is sent once.
+		result, err := e.Context.Db.Exec("update rebalances set status = $3, update_at = now() where id = $1 and status = $2", item.GetId(), types.StatusPending, types.StatusProcessing)
+		if e.Context.Debug(err) {
+			continue
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			continue
+		}
+
+		// The reserve that was spent or locked by the withdrawals since the move was proposed no longer covers it.
+		if _ = e.Context.Db.QueryRow("select value from reserves where user_id = $1 and address = $2 and symbol = $3 and platform = $4 and protocol = $5 and lock = $6", item.GetUserId(), item.GetFrom(), item.GetSymbol(), chain.GetPlatform(), item.GetProtocol(), false).Scan(&value); value < item.GetValue() {
+			err = fmt.Errorf("the reserve %v holds %v %v, the move needs %v", item.GetFrom(), value, item.GetSymbol(), item.GetValue())
+		} else {
+			_, err = e.writeMove(chain, item.GetUserId(), item.GetFrom(), item.GetTo(), item.GetSymbol(), item.GetProtocol(), item.GetValue(), func(id int64) error {
+				_, err := e.Context.Db.Exec("update rebalances set transaction_id = $2 where id = $1", item.GetId(), id)
+				return err
+			})
+		}
+
+		if err != nil {
+			if _, err := e.Context.Db.Exec("update rebalances set status = $2, error = $3, update_at = now() where id = $1", item.GetId(), types.StatusFailed, err.Error()); e.Context.Debug(err) {
+				continue
+			}
+		}
+	}
+
+	// The transfer of the move arrives to the recipient address as the internal deposit, which is added to its reserve.
+	if _, err := e.Context.Db.Exec(`update rebalances r set status = case when t.status = $1 then $2 else $3 end, hash = t.hash, error = coalesce(t.error, ''), update_at = now() from transactions t where t.id = r.transaction_id and r.status = $4 and t.status in ($1, $3)`, types.StatusReserve, types.StatusFilled, types.StatusFailed, types.StatusProcessing); e.Context.Debug(err) {
+		return
+	}
+}
+
+// confirmation - This function is used to check the status of pending deposits. It queries the database for transactions with a status
+// of PENDING and tx type of DEPOSIT. It then checks the status of the hash associated with the transaction on the
+// relevant blockchain. If the status is successful, the deposit is credited to the local wallet address and the status
+// of the transaction is changed to FILLED. If the status is unsuccessful, the status is changed to FAILED. If the number
+// of confirmations is not yet met, the number of confirmations is updated in the database.
+func (e *Service) confirmation() {
+
+	// Creates a new API client to interact with the Provider API.
+	migrate := pbprovider.NewApiClient(e.Context.GrpcClient)
+
+	// Creates a service provider to be used in the given context, providing the necessary services for the application.
+	_provider := provider.Service{
+		Context: e.Context,
+	}
+
+	// This code is performing a SQL query to select information from a database. The purpose is to select a specific set of
+	// information from the database based on the parameters of the query. The query is selecting the fields' id, hash,
+	// symbol, "to", fees, chain_id, user_id, value, confirmation, block, platform, protocol, and create_at where the status
+	// is equal to types.StatusPending and the assignment is equal to types.AssignmentDeposit. The code also checks for an error and closes the rows when finished.
+	rows, err := e.Context.Db.Query(`select id, hash, symbol, "to", fees, chain_id, user_id, value, confirmation, block, platform, protocol, allocation, parent, "from", hold, create_at from transactions where status = $1 and assignment = $2`, types.StatusPending, types.AssignmentDeposit)
+	if e.Context.Debug(err) {
+		return
+	}
+	defer rows.Close()
+
+	// The purpose of the for loop is to iterate through each row of a result set from a database query. The rows.Next()
+	// function is used to move to the next row in the result set.
+	for rows.Next() {
+
+		// The above code is declaring a variable called "item" of type "types.Transaction". This means that the variable
+		// "item" will be used to store information related to a types transaction.
+		var (
+			item types.Transaction
+		)
+
+		// This code is part of a loop that is iterating over results from a database query. The purpose of the code is to scan
+		// each row of the query result into their corresponding variables. If an error is encountered while scanning, the loop
+		// continues to the next row. The e.Context.Debug() function logs the error but does not cause the program to stop.
+		if err := rows.Scan(&item.Id, &item.Hash, &item.Symbol, &item.To, &item.Fees, &item.ChainId, &item.UserId, &item.Value, &item.Confirmation, &item.Block, &item.Platform, &item.Protocol, &item.Allocation, &item.Parent, &item.From, &item.Hold, &item.CreateAt); e.Context.Debug(err) {
+			return
+		}
+
+		// The purpose of this code is to get a chain from the "e" object, using the item's chain ID. If an error occurs, the
+		// function will return, and the error will be printed if debugging is enabled.
+		chain, err := _provider.QueryChain(item.GetChainId(), true)
+		if e.Context.Debug(err) {
+			return
+		}
+
+		// This code is used to connect to a blockchain using the GetRpc and GetPlatform methods of the chain object. The
+		// client object is used to make requests to the blockchain and the err object will be used to check for any errors
+		// that occurred in the process. If an error is encountered, the code will return to avoid any further issues.
+		client, err := platform.Dial(chain.GetRpc(), chain.GetPlatform())
+		if e.Context.Debug(err) {
+			return
+		}
+
+		// This code is part of a deposit process. The purpose of this code is to check a deposit's status, which is tracked
+		// using the client.Status(item.Hash) function. If the deposit is confirmed, the code credits the new deposit to the
+		// local wallet address, updates the deposits pending status to success status, and publishes the status to the
+		// exchange. If the deposit is not confirmed, it updates the confirmation number in the database. If the deposit fails, it updates the status in the database and publishes the status to the exchange.
+		if client.Status(item.Hash) {
+
+			// The number of the confirmations the deposit waits for depends on the currency and on the size of the deposit, the
+			// confirmation count of the chain is used when no tier of the currency applies.
+			item.Required = e.queryConfirmation(item.GetSymbol(), item.GetChainId(), item.GetValue(), chain.GetConfirmation())
+
+			// The purpose of this code is to check if the difference between the current block and the item block is greater than
+			// or equal to the required confirmation number and if the item confirmation is greater than or equal to the
+			// required confirmation number. If both conditions are true, then the subsequent code will execute.
+			if (chain.GetBlock()-item.GetBlock()) >= item.GetRequired() && item.GetConfirmation() >= item.GetRequired() {
+
+				// The credit of the deposit is recorded as a trace of its own, from the price of the fees to the published status,
+				// the errors of the credit end the span with the error.
+				ctx, span := trace.Start(context.Background(), "deposit.credit", trace.KindInternal, trace.Int("transaction.id", item.GetId()), trace.String("transaction.symbol", item.GetSymbol()), trace.String("transaction.platform", item.GetPlatform()))
+				debug := func(err error) bool {
 					if e.Context.Debug(err) {
+						span.End(err)
+						return true
+					}
+					return false
+				}
+
+				// The purpose of this code is to get the price of a requested symbol given a base unit. It uses the GetPrice method
+				// from the e object to get the price, and if the GetPrice method returns an error, the Context.Error() method
+				// handles the error. The code also checks that the protocol is MAINNET before attempting to get the price. If the
+				// price is greater than 0, the chain fees are set using the contract's fees and the price.
+				if item.GetProtocol() != types.ProtocolMainnet {
+
+					// This code is used to get a contract from the Ethereum network. The contract is retrieved using the item's symbol
+					// and chain ID. If there is an error, the Context.Debug() function will be used to return an error message.
+					contract, err := _provider.QueryContract(item.GetSymbol(), item.GetChainId())
+					if debug(err) {
+						return
+					}
+
+					// This code is used to get the price of a requested symbol given a base unit. It uses the GetPrice method from the e
+					// object and passes in a context.Background() and a GetRequestPrice object containing the base unit and the
+					// requested symbol. If the GetPrice method returns an error, the error is returned in the response and the Context.Error() method handles the error.
+					price, err := migrate.GetPrice(ctx, &pbprovider.GetRequestPrice{BaseUnit: chain.GetParentSymbol(), QuoteUnit: item.GetSymbol()})
+					if debug(err) {
 						return
 					}
 
@@ -495,37 +1264,97 @@ func (e *Service) confirmation() {
 				// true, then the code inside the if statement will be executed.
 				if item.GetValue() > chain.GetFees() && item.GetAllocation() != types.AllocationInternal {
 
+					// The deposits of an asset being delisted or disabled on the chain are not credited, the deposit is locked, so that
+					// the operators can return it to the sender.
+					network, err := _provider.QueryAssetChain(item.GetSymbol(), item.GetChainId())
+					if _, delisting := _provider.QueryDelisting(item.GetSymbol()); delisting == nil || err != nil || !network.GetDeposit() {
+
+						if _, err := e.Context.Db.ExecContext(ctx, "update transactions set status = $2 where id = $1;", item.GetId(), types.StatusLock); debug(err) {
+							return
+						}
+
+						item.Status = types.StatusLock
+						if err := e.Context.PublishContext(ctx, &item, "exchange", "deposit/status"); debug(err) {
+							return
+						}
+
+						span.End(nil)
+						continue
+					}
+
+					// The deposit from the address the user proved to own is marked as the deposit from a verified source, the mark is
+					// the record of the source for the travel rule.
+					if item.Verified = e.querySource(&item); item.GetVerified() {
+						if _, err := e.Context.Db.ExecContext(ctx, "update transactions set verified = $2 where id = $1;", item.GetId(), true); debug(err) {
+							return
+						}
+					}
+
+					// The deposits held for the review are not credited, the funds are shown to the user as pending until the
+					// operators release the deposit to the balance or return it to the sender.
+					if reason := e.queryHold(&item, network); len(reason) > 0 {
+
+						if _, err := e.Context.Db.ExecContext(ctx, "update transactions set status = $2, hold = $3 where id = $1;", item.GetId(), types.StatusHold, reason); debug(err) {
+							return
+						}
+
+						item.Status = types.StatusHold
+						item.Hold = reason
+
+						if err := e.Context.PublishContext(ctx, &item, "exchange", "deposit/status"); debug(err) {
+							return
+						}
+
+						_query := query.Migrate{
+							Context: e.Context,
+						}
+
+						go _query.SendMail(item.GetUserId(), "deposit_hold", item.GetValue(), item.GetSymbol())
+
+						span.End(nil)
+						continue
+					}
+
 					// Crediting a new deposit to the local wallet address.
-					// This code is updating the balance of an asset with a given symbol and user ID. The purpose is to update the
-					// balance with a given value (item.GetValue()) for the user and symbol combination. The code is using the Exec
-					// function on the database object and passing in the appropriate values. If there is an error, the code continues.
-					if _, err := e.Context.Db.Exec("update balances set value = value + $1 where symbol = $2 and user_id = $3 and type = $4;", item.GetValue(), item.GetSymbol(), item.GetUserId(), types.TypeSpot); e.Context.Debug(err) {
+					// The deposit is claimed with the credit of the balance of the user, the deposit that was claimed before, by
+					// another service or by an earlier pass, is not credited again.
+					credited, err := e.writeCredit(ctx, &item)
+					if debug(err) {
 						return
 					}
 
+					if !credited {
+						span.End(nil)
+						continue
+					}
+
 					item.Hook = true
 					item.Status = types.StatusFilled
 
 					// This code is from a function that is publishing a message to an exchange with a certain routing key.  The purpose
 					// of this code is to attempt to publish the message to the exchange.  If an error is encountered, the context debug
 					// method is called with the error and the function returns.
-					if err := e.Context.Publish(&item, "exchange", "deposit/open", "deposit/status"); e.Context.Debug(err) {
+					if err := e.Context.PublishContext(ctx, &item, "exchange", "deposit/open", "deposit/status"); debug(err) {
 						return
 					}
 
+					// The deposit of a stablecoin is converted into the currency of the balance of the user who opted in to the ramp,
+					// the failed conversion leaves the deposit in the stablecoin.
+					debug(_provider.WriteRamp(&item))
+
 				} else {
 
 					// This code is updating the records in the transactions table in the database. The values being changed are the
 					// allocation and status, and the specific record being updated is determined by the ID which is passed in as the
 					// third parameter (parent). If the operation is successful, it will return the transaction, otherwise it will return nil.
-					if _, err := e.Context.Db.Exec("update transactions set allocation = $1, status = $2 where id = $3;", types.AllocationExternal, types.StatusPending, item.GetParent()); e.Context.Debug(err) {
+					if _, err := e.Context.Db.ExecContext(ctx, "update transactions set allocation = $1, status = $2 where id = $3;", types.AllocationExternal, types.StatusPending, item.GetParent()); debug(err) {
 						return
 					}
 
 					// This code is setting up a reverse balance change in a database, and is checking for errors while doing so. The if
 					// statement is checking to see if the setReverse() function returns an error, and if it does, it prints the error
 					// to the debug log and returns. If the setReverse() function does not return an error, the code continues to execute.
-					if err := _provider.WriteReverse(item.GetUserId(), item.GetTo(), item.GetSymbol(), item.GetValue(), item.GetPlatform(), types.BalancePlus); e.Context.Debug(err) {
+					if err := _provider.WriteReverse(item.GetUserId(), item.GetTo(), item.GetSymbol(), item.GetValue(), item.GetPlatform(), types.BalancePlus); debug(err) {
 						return
 					}
 
@@ -534,16 +1363,17 @@ func (e *Service) confirmation() {
 
 				// The purpose of this code is to set a reserve for a specified user, symbol, value, platform, and protocol. If an
 				// error occurs, the code will continue to execute. The e.Context.Debug(err) line logs the error for debugging purposes.
-				if err := _provider.WriteReserve(item.GetUserId(), item.GetTo(), item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), types.BalancePlus); e.Context.Debug(err) {
+				if err := _provider.WriteReserve(item.GetUserId(), item.GetTo(), item.GetSymbol(), item.GetValue(), item.GetPlatform(), item.GetProtocol(), types.BalancePlus); debug(err) {
 					return
 				}
 
 				// This code is part of a loop, and it is used to update the status of a transaction in a database. The first two
 				// arguments in the Exec() function are the ID and status of the transaction. The third argument is a function that
 				// will debug any error that may occur during execution. If an error occurs, the code will skip the current iteration of the loop and continue on to the next one.
-				if _, err := e.Context.Db.Exec("update transactions set status = $2 where id = $1;", item.GetId(), item.GetStatus()); e.Context.Debug(err) {
+				if _, err := e.Context.Db.ExecContext(ctx, "update transactions set status = $2 where id = $1;", item.GetId(), item.GetStatus()); debug(err) {
 					return
 				}
+				span.End(nil)
 
 			} else {
 
@@ -553,12 +1383,26 @@ func (e *Service) confirmation() {
 				if _, err := e.Context.Db.Exec("update transactions set confirmation = $2 where id = $1;", item.GetId(), chain.GetBlock()-item.GetBlock()); e.Context.Debug(err) {
 					return
 				}
+
+				// The progress of the pending deposit is published with every new confirmation, the remaining confirmations let
+				// the clients show how long the deposit still waits.
+				if confirmation := chain.GetBlock() - item.GetBlock(); confirmation != item.GetConfirmation() {
+
+					item.Confirmation = confirmation
+					if item.Remaining = item.GetRequired() - confirmation; item.Remaining < 0 {
+						item.Remaining = 0
+					}
+
+					if err := e.Context.Publish(&item, "exchange", "deposit/status"); e.Context.Debug(err) {
+						return
+					}
+				}
 			}
 
 		} else {
 
 			// The item.Hook = true statement is used to indicate that an item has been hooked, meaning that it has been linked or
-			// attached to something else. The item.Status = types.Status_FAILED statement is used to set the status of the item
+			// attached to something else. The item.Status = types.StatusFailed statement is used to set the status of the item
 			// to "Failed", which indicates that the item has not been successful in performing its intended task.
 			item.Hook = true
 			item.Status = types.StatusFailed
@@ -578,3 +1422,226 @@ func (e *Service) confirmation() {
 		}
 	}
 }
+
+// balance - This function refreshes the balances of the external addresses watched by the users at a specific time interval. The
+// balance of the native coin of the chain is requested from the node of the chain, the watched balances are included in
+// the valuation of the portfolio of the users by the snapshots.
+func (e *Service) balance() {
+
+	// The code creates a ticker that triggers every ten minutes and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 10)
+	for range ticker.C {
+
+		func() {
+
+			// The purpose of this code is to keep one client per chain during the refresh, so that every node is dialed only once.
+			var (
+				clients = make(map[int64]platform.Client)
+			)
+
+			// This code queries the watched addresses together with the chains they belong to, the inactive chains are skipped.
+			rows, err := e.Context.Db.Query(`select w.id, w.address, c.id, c.rpc, c.platform, c.parent_symbol, c.decimals from watches w inner join chains c on c.id = w.chain_id where c.status = $1`, true)
+			if e.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item  types.Watch
+					chain types.Chain
+				)
+
+				if err := rows.Scan(&item.Id, &item.Address, &chain.Id, &chain.Rpc, &chain.Platform, &chain.ParentSymbol, &chain.Decimals); e.Context.Debug(err) {
+					continue
+				}
+
+				client, ok := clients[chain.GetId()]
+				if !ok {
+					if client, err = platform.Dial(chain.GetRpc(), chain.GetPlatform()); err != nil { // No debug....
+						continue
+					}
+					clients[chain.GetId()] = client
+				}
+
+				balance, err := client.BalanceAt(item.GetAddress())
+				if err != nil { // No debug....
+					continue
+				}
+
+				// The balance is converted from the smallest units of the coin with the decimals of the chain.
+				if _, err := e.Context.Db.Exec("update watches set symbol = $1, balance = $2 where id = $3;", chain.GetParentSymbol(), decimal.New(balance).Floating(chain.GetDecimals()), item.GetId()); e.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+	}
+}
+
+// oracle - This function refreshes the withdrawal fees estimated by the fee oracle for the active chains every minute. The
+// prices of the network resources are requested from the node of the chain, and the cost of a native and of a token
+// transfer is recorded in the units of the parent asset of the chain, the withdrawals charge it when it is above the static
+// fee of the chain or of the contract.
+func (e *Service) oracle() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		func() {
+
+			rows, err := e.Context.Db.Query("select id, rpc, platform, decimals from chains where status = $1", true)
+			if e.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					chain types.Chain
+				)
+
+				if err := rows.Scan(&chain.Id, &chain.Rpc, &chain.Platform, &chain.Decimals); e.Context.Debug(err) {
+					continue
+				}
+
+				client, err := platform.Dial(chain.GetRpc(), chain.GetPlatform())
+				if err != nil { // No debug....
+					continue
+				}
+
+				coin, token, err := client.Fees()
+				if err != nil { // No debug....
+					continue
+				}
+
+				if _, err := e.Context.Db.Exec("insert into fee_estimates (chain_id, mainnet, token, create_at) values ($1, $2, $3, now()) on conflict (chain_id) do update set mainnet = excluded.mainnet, token = excluded.token, create_at = excluded.create_at;",
+					chain.GetId(),
+					decimal.New(coin).Floating(chain.GetDecimals()),
+					decimal.New(token).Floating(chain.GetDecimals()),
+				); e.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+	}
+}
+
+// distribution - This function processes the distributions of the currencies created by the administrators. The holders of the
+// filter of a pending distribution are recorded first, then the records are credited to the spot balances of the users
+// in batches, every batch in one database transaction together with the progress of the distribution, so that a restart
+// never credits a record twice.
+func (e *Service) distribution() {
+
+	// The code creates a ticker that triggers every ten seconds and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Second * 10)
+	for range ticker.C {
+
+		func() {
+
+			rows, err := e.Context.Db.Query("select id, symbol, value, holder, minimum, status from distributions where status = $1 or status = $2 order by id", types.StatusPending, types.StatusProcessing)
+			if e.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.Distribution
+				)
+
+				if err := rows.Scan(&item.Id, &item.Symbol, &item.Value, &item.Holder, &item.Minimum, &item.Status); e.Context.Debug(err) {
+					continue
+				}
+
+				if item.GetStatus() == types.StatusPending {
+
+					// The holders are the users with at least the minimum on the spot balance of the holder currency at the moment the
+					// distribution starts.
+					if len(item.GetHolder()) > 0 {
+						if _, err := e.Context.Db.Exec("insert into distribution_records (distribution_id, user_id, value) select $1, user_id, $2 from balances where symbol = $3 and type = $4 and value > 0 and value >= $5 on conflict (distribution_id, user_id) do nothing", item.GetId(), item.GetValue(), item.GetHolder(), types.TypeSpot, item.GetMinimum()); e.Context.Debug(err) {
+							continue
+						}
+					}
+
+					if _, err := e.Context.Db.Exec("update distributions set status = $2, count = (select count(*) from distribution_records where distribution_id = $1) where id = $1", item.GetId(), types.StatusProcessing); e.Context.Debug(err) {
+						continue
+					}
+					item.Status = types.StatusProcessing
+				}
+
+				for {
+
+					count, err := e.writeDistribution(&item, 500)
+					if e.Context.Debug(err) || count == 0 {
+						break
+					}
+				}
+			}
+		}()
+	}
+}
+
+// competition - This function runs the trading competitions: the competitions are started at their start, the rankings of the
+// running competitions are computed once a day, and at the finish the final rankings are computed and the prizes are
+// distributed to the winners.
+func (e *Service) competition() {
+
+	// The code creates a ticker that triggers every ten minutes and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 10)
+	for range ticker.C {
+
+		func() {
+
+			rows, err := e.Context.Db.Query("select id, metric, pairs, symbol, prize, prizes, start_at, finish_at, status, start_at <= now(), finish_at <= now(), compute_at is null or compute_at < date_trunc('day', now()) from competitions where status = $1 or status = $2 order by id", types.StatusPending, types.StatusProcessing)
+			if e.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item                     types.Competition
+					started, finished, stale bool
+				)
+
+				if err := rows.Scan(&item.Id, &item.Metric, pq.Array(&item.Pairs), &item.Symbol, &item.Prize, pq.Array(&item.Prizes), &item.StartAt, &item.FinishAt, &item.Status, &started, &finished, &stale); e.Context.Debug(err) {
+					continue
+				}
+
+				if !started {
+					continue
+				}
+
+				if item.GetStatus() == types.StatusPending {
+					if _, err := e.Context.Db.Exec("update competitions set status = $2 where id = $1", item.GetId(), types.StatusProcessing); e.Context.Debug(err) {
+						continue
+					}
+					item.Status = types.StatusProcessing
+				}
+
+				if !finished && !stale {
+					continue
+				}
+
+				if err := e.writeRanking(&item); e.Context.Debug(err) {
+					continue
+				}
+
+				if finished {
+					if err := e.writePrizes(&item); e.Context.Debug(err) {
+						continue
+					}
+				}
+
+				if err := e.Context.Publish(&item, "exchange", "competition/status"); e.Context.Debug(err) {
+					continue
+				}
+			}
+		}()
+	}
+}
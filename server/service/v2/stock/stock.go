@@ -2,7 +2,10 @@ package stock
 
 import (
 	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/i18n"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbstock"
+	"github.com/cryptogateway/backend-envoys/server/types"
 )
 
 // Service - The purpose of this code is to create a "Service" struct that contains a pointer to an assets.Context. This allows the
@@ -11,6 +14,12 @@ type Service struct {
 	Context *assets.Context
 }
 
+// Initialization - The code initializes a Service object and runs the settlement of the commissions of the brokers.
+func (s *Service) Initialization() {
+	go s.settlement()
+	go s.corporate()
+}
+
 // queryAgent - This function is used to get an Agent based on the userId provided. It uses a SQL query to search for an Agent with
 // the given userId and returns the Agent's details. It also handles errors in case there is no Agent with the given userId.
 func (s *Service) queryAgent(userId int64) (*pbstock.Agent, error) {
@@ -22,9 +31,147 @@ func (s *Service) queryAgent(userId int64) (*pbstock.Agent, error) {
 	// This block of code is used to query a database and return information based on a userId as an input. The query looks
 	// for a row in the "agents" table that matches the userId. If there is a match, the code will scan the row and store
 	// the values in the "response" variable, which is then returned. If there is no match, an error is returned.
-	if err := s.Context.Db.QueryRow("select a.id, a.user_id, case when a.broker_id > 0 then b.name else a.name end as agent_name, a.broker_id, a.type, a.status, a.create_at from agents a left join agents b on b.id = a.broker_id where a.user_id = $1", userId).Scan(&response.Id, &response.UserId, &response.Name, &response.BrokerId, &response.Type, &response.Status, &response.CreateAt); err != nil {
+	if err := s.Context.Db.QueryRow("select a.id, a.user_id, case when a.broker_id > 0 then b.name else a.name end as agent_name, a.broker_id, a.type, a.status, a.details, a.reason, a.create_at from agents a left join agents b on b.id = a.broker_id where a.user_id = $1", userId).Scan(&response.Id, &response.UserId, &response.Name, &response.BrokerId, &response.Type, &response.Status, &response.Details, &response.Reason, &response.CreateAt); err != nil {
 		return &response, err
 	}
 
 	return &response, nil
 }
+
+// queryLeader - This function reports whether the user is a lead trader that can be followed: the lead traders are the agents
+// and the brokers whose account is approved.
+func (s *Service) queryLeader(userId int64) bool {
+
+	var (
+		exist bool
+	)
+
+	_ = s.Context.Db.QueryRow("select exists(select id from agents where user_id = $1 and status = $2)::bool", userId, types.StatusAccess).Scan(&exist)
+
+	return exist
+}
+
+// queryEarnings - This function returns the commissions of the broker summed per currency by the query.
+func (s *Service) queryEarnings(query string, args ...interface{}) (earnings []*pbstock.Earning, err error) {
+
+	rows, err := s.Context.Db.Query(query, args...)
+	if err != nil {
+		return earnings, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item pbstock.Earning
+		)
+
+		if err := rows.Scan(&item.Symbol, &item.Value); err != nil {
+			return earnings, err
+		}
+
+		earnings = append(earnings, &item)
+	}
+
+	return earnings, nil
+}
+
+// transitions - The changes of the status of the agents every actor is allowed to make. The applicant submits the application
+// for the review, the administrators approve or reject it and block the approved agents, the brokers review the applications
+// of the agents of their brokerage the same way.
+var transitions = map[string]map[string][]string{
+	types.ActorApplicant: {
+		types.StatusPending: {types.StatusReview},
+		types.StatsRejected: {types.StatusReview},
+	},
+	types.ActorAdmin: {
+		types.StatusReview:  {types.StatusAccess, types.StatsRejected},
+		types.StatusAccess:  {types.StatusBlocked},
+		types.StatusBlocked: {types.StatusAccess},
+	},
+	types.ActorBroker: {
+		types.StatusReview:  {types.StatusAccess, types.StatsRejected},
+		types.StatusAccess:  {types.StatusBlocked},
+		types.StatusBlocked: {types.StatusAccess},
+	},
+}
+
+// WriteTransition - This function changes the status of the agent on behalf of the actor, if the transition is allowed for the
+// actor, and records the transition in the audit of the agent together with the reason. The status is read and changed
+// in one database transaction, so that two actors can not change it concurrently.
+func (s *Service) WriteTransition(id int64, _status string, actorId int64, actor, reason string) (*pbstock.Agent, error) {
+
+	var (
+		item pbstock.Agent
+	)
+
+	tx, err := s.Context.Db.Begin()
+	if err != nil {
+		return &item, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("select id, user_id, broker_id, type, status from agents where id = $1 for update", id).Scan(&item.Id, &item.UserId, &item.BrokerId, &item.Type, &item.Status); err != nil {
+		return &item, i18n.Errorf(11683, "the agent %v is not found", id)
+	}
+
+	if !help.IndexOf(transitions[actor][item.GetStatus()], _status) {
+		return &item, i18n.Errorf(11684, "the status of the agent can not be changed from %v to %v", item.GetStatus(), _status)
+	}
+
+	if _, err := tx.Exec("update agents set status = $2, reason = $3 where id = $1", item.GetId(), _status, reason); err != nil {
+		return &item, err
+	}
+
+	if _, err := tx.Exec("insert into agent_transitions (agent_id, previous, status, actor_id, actor, reason) values ($1, $2, $3, $4, $5, $6)", item.GetId(), item.GetStatus(), _status, actorId, actor, reason); err != nil {
+		return &item, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &item, err
+	}
+
+	item.Status, item.Reason = _status, reason
+
+	if err := s.Context.Publish(&item, "exchange", "status/agent"); err != nil {
+		return &item, err
+	}
+
+	return &item, nil
+}
+
+// queryAccess - This function gates the actions of the agents: only the agents whose application is approved and who are not
+// blocked trade for the clients and see the data of the clients.
+func (s *Service) queryAccess(agent *pbstock.Agent) error {
+
+	if agent.GetStatus() != types.StatusAccess {
+		return i18n.Errorf(11685, "your agent account is %v, it is not approved", agent.GetStatus())
+	}
+
+	return nil
+}
+
+// QueryTransitions - This function returns the audit of the changes of the status of the agent, the latest changes first.
+func (s *Service) QueryTransitions(id int64) (transitions []*types.AgentTransition, err error) {
+
+	rows, err := s.Context.Db.Query("select id, agent_id, previous, status, actor_id, actor, reason, create_at from agent_transitions where agent_id = $1 order by id desc", id)
+	if err != nil {
+		return transitions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.AgentTransition
+		)
+
+		if err := rows.Scan(&item.Id, &item.AgentId, &item.Previous, &item.Status, &item.ActorId, &item.Actor, &item.Reason, &item.CreateAt); err != nil {
+			return transitions, err
+		}
+
+		transitions = append(transitions, &item)
+	}
+
+	return transitions, nil
+}
@@ -3,12 +3,15 @@ package stock
 import (
 	"context"
 	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/assets/common/i18n"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
 	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbstock"
 	"github.com/cryptogateway/backend-envoys/server/service/v2/account"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
 	"github.com/cryptogateway/backend-envoys/server/types"
 	"github.com/davecgh/go-spew/spew"
-	"google.golang.org/grpc/status"
-	"strings"
+	"net/http"
 )
 
 // SetAgent - The purpose of this code is to create an agent or broker account in a database. It does this by taking in a request
@@ -21,12 +24,7 @@ func (s *Service) SetAgent(ctx context.Context, req *pbstock.SetRequestAgent) (*
 		agent    pbstock.Agent
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// The purpose of this code is to query a database for a particular user's secure information and store it in the
 	// variable agent.Success. If the query returns an error, the error is returned in response and the context is sent an error message.
@@ -39,33 +37,30 @@ func (s *Service) SetAgent(ctx context.Context, req *pbstock.SetRequestAgent) (*
 	// This code checks to see if an agent has been verified. If the agent has not been verified, it will return an error
 	// message to indicate that the agent has not been KYC verified.
 	if !agent.Success {
-		return &response, status.Error(53678, "you have not been verified KYC")
+		return &response, i18n.Error(53678, "you have not been verified KYC")
 	}
 
-	// The purpose of this switch statement is to assign a value to the "_status" variable based on the type of request
-	// (req.GetType()) that is received. If the request type is "AGENT", then the "_status" variable is set to "PENDING". If
-	// the request type is "BROKER", then the "_status" variable is set to "ACCESS".
+	// The agents and the brokers alike start as a pending application: the applicant uploads the documents and submits the
+	// application, which is approved or rejected by the administrators.
 	switch req.GetType() {
-	case types.UserTypeAgent:
+	case types.UserTypeAgent, types.UserTypeBroker:
 		agent.Status = types.StatusPending
-		agent.Type = types.UserTypeAgent
-	case types.UserTypeBroker:
-		agent.Status = types.StatusAccess
-		agent.Type = types.UserTypeBroker
+		agent.Type = req.GetType()
 	default:
-		return &response, status.Error(678543, "not found type")
+		return &response, i18n.Error(678543, "not found type")
 	}
 
 	// This code is used to insert data into the 'agents' table in a database. The code is also checking for any errors that
 	// may occur during the insertion process, and if an error occurs, it will return an error message.
-	if _, err := s.Context.Db.Exec(`insert into agents (name, broker_id, type, status, user_id) values ($1, $2, $3, $4, $5)`,
+	if _, err := s.Context.Db.Exec(`insert into agents (name, broker_id, type, status, user_id, details) values ($1, $2, $3, $4, $5, $6)`,
 		req.GetName(),
 		req.GetBrokerId(),
 		agent.GetType(),
 		agent.GetStatus(),
 		auth,
+		req.GetDetails(),
 	); err != nil {
-		return &response, status.Error(646788, "you have already created an agent and broker account")
+		return &response, i18n.Error(646788, "you have already created an agent and broker account")
 	}
 
 	// This code is checking to see if an Agent object was returned when calling the queryAgent() function with the auth
@@ -95,12 +90,7 @@ func (s *Service) GetAgent(ctx context.Context, _ *pbstock.GetRequestAgent) (*pb
 		response pbstock.ResponseAgent
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code is checking to see if an Agent object was returned when calling the queryAgent() function with the auth
 	// parameter. If the Id property of the Agent object is greater than 0, then the Agent object is appended to the
@@ -122,7 +112,7 @@ func (s *Service) GetBrokers(_ context.Context, req *pbstock.GetRequestBrokers)
 	// the code to store a value of type 'pbstock.ResponseBroker' in the 'response' variable.
 	var (
 		response pbstock.ResponseBroker
-		maps     []string
+		builder  = query.NewBuilder()
 	)
 
 	// This code checks if the request's limit is 0. If it is, it sets the request's limit to 30. This is likely done to
@@ -131,19 +121,18 @@ func (s *Service) GetBrokers(_ context.Context, req *pbstock.GetRequestBrokers)
 		req.Limit = 30
 	}
 
-	// This code snippet is checking the length of the "req.GetSearch()" variable. If the length is greater than 0, then it
-	// appends a string to the "maps" variable that performs a search for a broker with a given name or ID. If the length of
-	// "req.GetSearch()" is 0, then it appends a string to the "maps" variable that searches for a broker without any search parameters.
+	// This code snippet adds the condition of the brokers to the query, and if the length of the "req.GetSearch()" variable
+	// is greater than 0, the condition that performs a search for a broker with a given name or ID, the search is bound as the parameter.
+	builder.Where("type = ?", types.UserTypeBroker)
 	if len(req.GetSearch()) > 0 {
-		maps = append(maps, fmt.Sprintf("where type = '%[2]v' and (name like %[1]s or id::text like %[1]s)", "'%"+req.GetSearch()+"%'", types.UserTypeBroker))
-	} else {
-		maps = append(maps, fmt.Sprintf("where type = '%[1]v'", types.UserTypeBroker))
+		like := query.Like(req.GetSearch())
+		builder.Where("(name like ? or id::text like ?)", like, like)
 	}
 
 	// The purpose of this code is to query a database for the number of records in a table that match certain criteria
-	// indicated by the 'maps' variable. It then scans the result into a variable called 'response' and checks if the count
+	// indicated by the builder. It then scans the result into a variable called 'response' and checks if the count
 	// is greater than 0. If it is, it will execute some code.
-	if _ = s.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from agents %s", strings.Join(maps, " "))).Scan(&response.Count); response.GetCount() > 0 {
+	if _ = s.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from agents %s", builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
 
 		// This code is setting an offset for a Paginated request. The offset is used to determine the index of the first item
 		// that should be returned. This code is calculating the offset by multiplying the limit (the number of items per page)
@@ -154,11 +143,9 @@ func (s *Service) GetBrokers(_ context.Context, req *pbstock.GetRequestBrokers)
 		}
 
 		// This code is used to query a database to select certain data. The fmt.Sprintf function is used to build a query
-		// string based on the passed in arguments. The strings.Join function is used to create a comma-separated list of items
-		// from a slice of strings (maps). The query string is then used to query the database using the Context.Db.Query
-		// function. The query result is then stored in the rows variable. The rows.Close function is used to close the
-		// database connection when the query is finished.
-		rows, err := s.Context.Db.Query(fmt.Sprintf(`select id, name, user_id, broker_id, type, status from agents %s order by id desc limit %d offset %d`, strings.Join(maps, " "), req.GetLimit(), offset))
+		// string with the conditions of the builder, whose values are passed as the parameters. The query result is then
+		// stored in the rows variable. The rows.Close function is used to close the database connection when the query is finished.
+		rows, err := s.Context.Db.Query(fmt.Sprintf(`select id, name, user_id, broker_id, type, status from agents %s order by id desc limit %d offset %d`, builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -226,20 +213,20 @@ func (s *Service) GetRequests(ctx context.Context, req *pbstock.GetRequestReques
 		req.Limit = 30
 	}
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
 	agent, _ := s.queryAgent(auth)
 
+	// Only the approved brokers see the applications of the agents to their brokerage.
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
+	}
+
 	// The purpose of this code is to query a database and check if the count of agents with a certain status, type, and
 	// broker ID is greater than 0. If the count is greater than 0, then the code will execute the following code block.
-	if _ = s.Context.Db.QueryRow("select count(*) as count from agents where status = $1 and type = $2 and broker_id = $3", types.StatusPending, types.UserTypeAgent, agent.GetId()).Scan(&response.Count); response.GetCount() > 0 {
+	if _ = s.Context.Db.QueryRow("select count(*) as count from agents where status = $1 and type = $2 and broker_id = $3", types.StatusReview, types.UserTypeAgent, agent.GetId()).Scan(&response.Count); response.GetCount() > 0 {
 
 		// This code is setting an offset for a Paginated request. The offset is used to determine the index of the first item
 		// that should be returned. This code is calculating the offset by multiplying the limit (the number of items per page)
@@ -254,7 +241,7 @@ func (s *Service) GetRequests(ctx context.Context, req *pbstock.GetRequestReques
 		// from a slice of strings (maps). The query string is then used to query the database using the Context.Db.Query
 		// function. The query result is then stored in the rows variable. The rows.Close function is used to close the
 		// database connection when the query is finished.
-		rows, err := s.Context.Db.Query(`select a.id, a.user_id, a.broker_id, a.type, a.status, a.create_at, b.secret from agents a left join kyc b on b.user_id = a.user_id  where a.status = $1 and a.type = $2 and a.broker_id = $3 order by a.id desc limit $4 offset $5`, types.StatusPending, types.UserTypeAgent, agent.GetId(), req.GetLimit(), offset)
+		rows, err := s.Context.Db.Query(`select a.id, a.user_id, a.broker_id, a.type, a.status, a.create_at, b.secret from agents a left join kyc b on b.user_id = a.user_id  where a.status = $1 and a.type = $2 and a.broker_id = $3 order by a.id desc limit $4 offset $5`, types.StatusReview, types.UserTypeAgent, agent.GetId(), req.GetLimit(), offset)
 		if err != nil {
 			return &response, err
 		}
@@ -328,12 +315,7 @@ func (s *Service) SetSetting(ctx context.Context, req *pbstock.GetRequestSetting
 		response pbstock.ResponseSetting
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
@@ -342,30 +324,23 @@ func (s *Service) SetSetting(ctx context.Context, req *pbstock.GetRequestSetting
 		return &response, err
 	}
 
-	// This code is checking to see if the agent's ID is greater than 0, and if it is, then it is executing an SQL statement
-	// to update the status of the agent in the database. The statement uses parameters for the ID, broker ID, and status of
-	// the agent. If there is an error, it returns an error response.
-	if agent.GetId() > 0 {
-
-		var (
-			item pbstock.Agent
-		)
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
+	}
 
-		// This if-statement is used to update the status of an agent in a database. It is using the QueryRow method to update
-		// the status of the agent with the given user_id and broker_id. The Scan method is then used to assign each of the
-		// returned values to the respective item fields. If an error occurs, the Error method is called and the response is returned.
-		if err := s.Context.Db.QueryRow("update agents set status = $3 where user_id = $1 and broker_id = $2 returning id, user_id, broker_id, status;", req.GetUserId(), agent.GetId(), req.GetStatus()).Scan(&item.Id, &item.UserId, &item.BrokerId, &item.Status); err != nil {
-			return &response, err
-		}
+	var (
+		id int64
+	)
 
-		// This code checks for any errors when publishing the message to the exchange, and if there is an error, it will return
-		// an error response and log the error.
-		if err := s.Context.Publish(&item, "exchange", "status/agent"); err != nil {
-			return &response, err
-		}
+	// The broker changes the status of the agents of its brokerage only, the change is validated and audited.
+	if err := s.Context.Db.QueryRow("select id from agents where user_id = $1 and broker_id = $2", req.GetUserId(), agent.GetId()).Scan(&id); err != nil {
+		return &response, i18n.Errorf(11683, "the agent %v is not found", req.GetUserId())
+	}
 
-		response.Success = true
+	if _, err := s.WriteTransition(id, req.GetStatus(), auth, types.ActorBroker, ""); err != nil {
+		return &response, err
 	}
+	response.Success = true
 
 	return &response, nil
 }
@@ -382,12 +357,7 @@ func (s *Service) DeleteAgent(ctx context.Context, req *pbstock.GetRequestDelete
 		response pbstock.ResponseAgent
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
@@ -424,12 +394,7 @@ func (s *Service) GetAgents(ctx context.Context, req *pbstock.GetRequestAgents)
 		req.Limit = 30
 	}
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
@@ -438,6 +403,10 @@ func (s *Service) GetAgents(ctx context.Context, req *pbstock.GetRequestAgents)
 		return &response, err
 	}
 
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
+	}
+
 	// This if statement is used to query an SQL database to check if a certain agent is associated with any accounts. The
 	// query is using the ID of the agent and the type of account, and is checking if there is a count of any results
 	// greater than 0. If the count is greater than 0, the code within the if statement will execute.
@@ -515,12 +484,7 @@ func (s *Service) SetBlocked(ctx context.Context, req *pbstock.SetRequestAgentBl
 		_status  string
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
@@ -529,6 +493,10 @@ func (s *Service) SetBlocked(ctx context.Context, req *pbstock.SetRequestAgentBl
 		return &response, err
 	}
 
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
+	}
+
 	// This code is used to query a database for information about a specific agent. The variables req, agent, and pbstock
 	// are passed in to this function as parameters. The code queries the database for the status of the agent from the
 	// given id, broker_id, and type, and stores it in the variable "status". If there is an error with the query, the
@@ -538,13 +506,17 @@ func (s *Service) SetBlocked(ctx context.Context, req *pbstock.SetRequestAgentBl
 	}
 
 	// This code is used to update the status of an agent when given a request. Depending on the initial _status, the code
-	// will either block the agent or give them access.
+	// will either block the agent or give them access, the change is audited.
 	switch _status {
 	case types.StatusBlocked:
-		_, _ = s.Context.Db.Exec("update agents set status = $2 where id = $1", req.GetId(), types.StatusAccess)
+		if _, err := s.WriteTransition(req.GetId(), types.StatusAccess, auth, types.ActorBroker, ""); err != nil {
+			return &response, err
+		}
 		response.Success = true
 	case types.StatusAccess:
-		_, _ = s.Context.Db.Exec("update agents set status = $2 where id = $1", req.GetId(), types.StatusBlocked)
+		if _, err := s.WriteTransition(req.GetId(), types.StatusBlocked, auth, types.ActorBroker, ""); err != nil {
+			return &response, err
+		}
 	}
 
 	return &response, nil
@@ -569,15 +541,10 @@ func (s *Service) SetTransfer(ctx context.Context, req *pbstock.SetRequestTransf
 	// (844532) and a message ("value must not be null") to the requester. This code is useful for validating user input to
 	// make sure it meets certain criteria.
 	if req.GetQuantity() == 0 {
-		return &response, status.Error(844532, "value must not be null")
+		return &response, i18n.Error(844532, "value must not be null")
 	}
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
@@ -587,10 +554,13 @@ func (s *Service) SetTransfer(ctx context.Context, req *pbstock.SetRequestTransf
 	}
 
 	// The purpose of this code is to check the status of the agent and if it is "BLOCKED", then it will return an error
-	// message with a status code of 523217. This can help the application determine if the agent should be allowed to
-	// continue with its current task.
+	// message with a status code of 523217. The agents whose application is not approved do not transfer either.
 	if agent.GetStatus() == types.StatusBlocked {
-		return &response, status.Error(523217, "your asset blocked")
+		return &response, i18n.Error(523217, "your asset blocked")
+	}
+
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
 	}
 
 	// This code is checking the value of the agent's broker ID. If it is set to 0, then the item's status is set to FILLED,
@@ -609,7 +579,7 @@ func (s *Service) SetTransfer(ctx context.Context, req *pbstock.SetRequestTransf
 	// the query are stored in a "row" object and can be accessed using the "row.Close()" function. If an error occurs while
 	// executing the query, the "err" variable is used to return an error message. The "defer row.Close()" statement ensures
 	// that the row object is closed and the connection to the database is properly terminated when the function ends.
-	row, err := s.Context.Db.Query(`select value, symbol from balances where symbol = $1 and type = $2 and user_id = $3`, req.GetSymbol(), types.TypeStock, auth)
+	row, err := s.Context.Db.Query(`select b.value - coalesce((select sum(h.value) from holds h where h.symbol = b.symbol and h.user_id = b.user_id and h.type = b.type), 0), b.symbol from balances b where b.symbol = $1 and b.type = $2 and b.user_id = $3`, req.GetSymbol(), types.TypeStock, auth)
 	if err != nil {
 		return &response, err
 	}
@@ -653,7 +623,7 @@ func (s *Service) SetTransfer(ctx context.Context, req *pbstock.SetRequestTransf
 
 			response.Success = true
 		} else {
-			return &response, status.Error(710076, "you do not have enough funds to withdraw the amount of the asset")
+			return &response, i18n.Error(710076, "you do not have enough funds to withdraw the amount of the asset")
 		}
 
 		response.Fields = append(response.Fields, &item)
@@ -671,11 +641,11 @@ func (s *Service) SetTransfer(ctx context.Context, req *pbstock.SetRequestTransf
 // column's value to the appropriate item.field, and appends the item to the response.Fields array.
 func (s *Service) GetTransfers(ctx context.Context, req *pbstock.GetRequestTransfers) (*pbstock.ResponseTransfer, error) {
 
-	// The purpose of the code snippet above is to declare two variables, response and maps. The variable response is of
-	// type pbstock.ResponseTransfer, while the variable maps is of type string slice.
+	// The purpose of the code snippet above is to declare two variables, response and builder. The variable response is of
+	// type pbstock.ResponseTransfer, while the variable builder holds the conditions of the query.
 	var (
 		response pbstock.ResponseTransfer
-		maps     []string
+		builder  = query.NewBuilder()
 	)
 
 	// The purpose of this code is to set a default limit value if the limit value requested (req.GetLimit()) is equal to
@@ -684,12 +654,7 @@ func (s *Service) GetTransfers(ctx context.Context, req *pbstock.GetRequestTrans
 		req.Limit = 30
 	}
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
@@ -699,7 +664,7 @@ func (s *Service) GetTransfers(ctx context.Context, req *pbstock.GetRequestTrans
 	}
 
 	if req.GetUnshift() {
-		maps = append(maps, fmt.Sprintf("where broker_id = %[1]d", agent.GetId()))
+		builder.Where("broker_id = ?", agent.GetId())
 	} else {
 
 		// This code is used to assign a value to the "Id" field in the "agent" object. If the "GetBrokerId()" method returns a
@@ -711,20 +676,20 @@ func (s *Service) GetTransfers(ctx context.Context, req *pbstock.GetRequestTrans
 			agent.Id = agent.GetBrokerId()
 		}
 
-		maps = append(maps, fmt.Sprintf("where broker_id = %[1]d and user_id = %[2]d", agent.GetId(), auth))
+		builder.Where("broker_id = ? and user_id = ?", agent.GetId(), auth)
 	}
 
-	// This code is checking if the length of the request's symbol is greater than 0. If it is, a new string is appended to
-	// the maps variable with a formatted printf statement using the symbol from the request.
+	// This code is checking if the length of the request's symbol is greater than 0. If it is, the condition of the symbol
+	// is added to the query, the symbol is bound as the parameter.
 	if len(req.GetSymbol()) > 0 {
-		maps = append(maps, fmt.Sprintf("and symbol = '%[1]s'", req.GetSymbol()))
+		builder.Where("symbol = ?", req.GetSymbol())
 	}
 
 	// The code snippet is used to query a database table for a specific condition. The fmt.Sprintf() function is used to
-	// construct a formatted string with the strings.Join() function used to join the maps argument. The query is used to
+	// construct a formatted string with the where clause of the builder. The query is used to
 	// select the count of records from the withdrawals table, which is then stored in the response.Count variable. The
 	// response.GetCount() function is then used to check if the count is greater than 0, which would indicate that the query was successful.
-	if _ = s.Context.Db.QueryRow(fmt.Sprintf(`select count(*) as count from transfer %s`, strings.Join(maps, " "))).Scan(&response.Count); response.GetCount() > 0 {
+	if _ = s.Context.Db.QueryRow(fmt.Sprintf(`select count(*) as count from transfer %s`, builder.Clause()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
 
 		// This code is setting an offset for a Paginated request. The offset is used to determine the index of the first item
 		// that should be returned. This code is calculating the offset by multiplying the limit (the number of items per page)
@@ -739,7 +704,7 @@ func (s *Service) GetTransfers(ctx context.Context, req *pbstock.GetRequestTrans
 		// "broker_id", "status" and "create_at" fields from the "withdraws" and "accounts" tables, while filtering the results
 		// based on the given parameters and conditions. It will also order the results by the "id" field, and limit the
 		// results to the number of records given in the "req.GetLimit()" parameter. Finally, it will offset the results by the given "offset" parameter.
-		rows, err := s.Context.Db.Query(fmt.Sprintf(`select a.id, a.symbol, b.name, a.user_id, a.quantity, a.broker_id, a.status, a.create_at from transfer a inner join accounts b on b.id = a.user_id %[1]s order by a.id desc limit %[2]d offset %[3]d`, strings.Join(maps, " "), req.GetLimit(), offset))
+		rows, err := s.Context.Db.Query(fmt.Sprintf(`select a.id, a.symbol, b.name, a.user_id, a.quantity, a.broker_id, a.status, a.create_at from transfer a inner join accounts b on b.id = a.user_id %[1]s order by a.id desc limit %[2]d offset %[3]d`, builder.Clause(), req.GetLimit(), offset), builder.Params()...)
 		if err != nil {
 			return &response, err
 		}
@@ -797,15 +762,10 @@ func (s *Service) CancelTransfer(ctx context.Context, req *pbstock.CancelRequest
 	var (
 		response pbstock.ResponseTransfer
 		item     pbstock.Transfer
-		maps     []string
+		builder  = query.NewBuilder(req.GetId())
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// The if statement is used to check if the GetUnshift() function returns a value that evaluates to true. If it does,
 	// then the code inside the if statement will be executed.
@@ -818,22 +778,21 @@ func (s *Service) CancelTransfer(ctx context.Context, req *pbstock.CancelRequest
 			return &response, err
 		}
 
-		// This code is checking if the agent's broker ID is equal to 0. If it is, it is appending an additional parameter to a
-		// list of parameters (maps) with the agent's ID. This means that the list of parameters will include the agent's ID if
-		// their broker ID is 0.
+		// This code is checking if the agent's broker ID is equal to 0. If it is, it is adding the condition of the agent's ID
+		// to the query. This means that the transfer must belong to the agent if their broker ID is 0.
 		if agent.GetBrokerId() == 0 {
-			maps = append(maps, fmt.Sprintf("and broker_id = %[1]d", agent.GetId()))
+			builder.Where("broker_id = ?", agent.GetId())
 		}
 
 	} else {
-		maps = append(maps, fmt.Sprintf("and user_id = %[1]d", auth))
+		builder.Where("user_id = ?", auth)
 	}
 
 	// This query is retrieving the value and symbol from the withdrawals table where the ID and user_id match the request ID
 	// and auth variables, respectively. The row and err variables are used to store the results of the query and any
 	// potential errors that may occur. The defer statement is used to ensure that the row.Close() method is called when the
 	// function returns, which will close the row variable and free up any resources that were allocated for the query.
-	row, err := s.Context.Db.Query(fmt.Sprintf(`select quantity, symbol, user_id from transfer where id = %[1]d %[2]s`, req.GetId(), strings.Join(maps, " ")))
+	row, err := s.Context.Db.Query(fmt.Sprintf(`select quantity, symbol, user_id from transfer where id = $1 %s`, builder.And()), builder.Params()...)
 	if err != nil {
 		return &response, err
 	}
@@ -884,12 +843,7 @@ func (s *Service) SetAction(ctx context.Context, req *pbstock.SetRequestAction)
 		item     types.Asset
 	)
 
-	// This code is checking to make sure a valid authentication token is present in the context. If it is not, it returns
-	// an error. This is necessary to ensure that only authorized users are accessing certain resources.
-	auth, err := s.Context.Auth(ctx)
-	if err != nil {
-		return &response, err
-	}
+	auth := s.Context.User(ctx)
 
 	// This code snippet is used to get an agent using the given authentication credentials. If there is an error when
 	// trying to get the agent, the code snippet will return an error to the user.
@@ -901,7 +855,11 @@ func (s *Service) SetAction(ctx context.Context, req *pbstock.SetRequestAction)
 	// This code is checking if the broker ID is greater than 0. If it is, the code returns an error message indicating that
 	// the user is not a broker and is therefore not able to add stock security turnover.
 	if agent.GetBrokerId() > 0 {
-		return &response, status.Error(568904, "you are not a broker to add in stock security turnover")
+		return &response, i18n.Error(568904, "you are not a broker to add in stock security turnover")
+	}
+
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
 	}
 
 	// This code is used to query a database. The purpose of this code is to query a database and select the id and name
@@ -925,8 +883,8 @@ func (s *Service) SetAction(ctx context.Context, req *pbstock.SetRequestAction)
 
 			// The purpose of this code is to query the database for a user's balance on a certain stock or other asset, and then
 			// store the retrieved balance in the item.Balance variable.
-			if _ = s.Context.Db.QueryRow(`select value from balances where symbol = $1 and user_id = $2 and type = $3`, req.GetSymbol(), auth, types.TypeStock).Scan(&item.Balance); item.GetBalance() == 0 {
-				return &response, status.Error(796743, "your asset balance is zero, you cannot withdraw the asset from circulation")
+			if _ = s.Context.Db.QueryRow(`select b.value - coalesce((select sum(h.value) from holds h where h.symbol = b.symbol and h.user_id = b.user_id and h.type = b.type), 0) from balances b where b.symbol = $1 and b.user_id = $2 and b.type = $3`, req.GetSymbol(), auth, types.TypeStock).Scan(&item.Balance); item.GetBalance() == 0 {
+				return &response, i18n.Error(796743, "your asset balance is zero, you cannot withdraw the asset from circulation")
 			}
 
 			// This code is an example of an SQL query that is used to update the balance of a particular asset. The purpose of
@@ -948,7 +906,519 @@ func (s *Service) SetAction(ctx context.Context, req *pbstock.SetRequestAction)
 
 		response.Success = true
 	} else {
-		return &response, status.Error(854333, "the asset is not a stock security, or the asset is temporarily disabled by the administration")
+		return &response, i18n.Error(854333, "the asset is not a stock security, or the asset is temporarily disabled by the administration")
+	}
+
+	return &response, nil
+}
+
+// GetLeaders - This method returns the lead traders that can be followed with the performance statistics of the last 30 days:
+// the number of the followers, the number and the volume of the spot trades in the quote currencies, and the profit and
+// loss of the trades marked to the current prices of the pairs.
+func (s *Service) GetLeaders(_ context.Context, req *pbstock.GetRequestLeaders) (*pbstock.ResponseLeader, error) {
+
+	var (
+		response pbstock.ResponseLeader
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	if _ = s.Context.Db.QueryRow("select count(*) as count from agents where status = $1", types.StatusAccess).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := s.Context.Db.Query(`select a.user_id, case when a.name <> '' then a.name else b.name end as name,
+			(select count(*) from copies c where c.lead_id = a.user_id and c.status = true) as followers,
+			coalesce(t.trades, 0), coalesce(t.volume, 0), coalesce(t.pnl, 0)
+			from agents a inner join accounts b on b.id = a.user_id
+			left join (
+				select t.user_id, count(*) as trades, sum(t.quantity * t.price) as volume, sum(case when t.assigning = $2 then t.quantity * (p.price - t.price) else t.quantity * (t.price - p.price) end) as pnl
+				from trades t inner join pairs p on p.base_unit = t.base_unit and p.quote_unit = t.quote_unit and p.type = $3
+				where t.create_at > now() - interval '30 days' group by t.user_id
+			) t on t.user_id = a.user_id
+			where a.status = $1 order by followers desc, a.id limit $4 offset $5`, types.StatusAccess, types.AssigningBuy, types.TypeSpot, req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item pbstock.Leader
+			)
+
+			if err = rows.Scan(&item.UserId, &item.Name, &item.Followers, &item.Trades, &item.Volume, &item.Pnl); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// SetCopy - This method follows the lead trader or updates the following. The spot orders of the lead trader are mirrored to
+// the follower in proportion to the balances: the follower commits the same share of the balance as the lead trader did,
+// scaled by the ratio in percent. The maximum caps the share of the balance of the follower committed to one order.
+func (s *Service) SetCopy(ctx context.Context, req *pbstock.SetRequestCopy) (*pbstock.ResponseCopy, error) {
+
+	var (
+		response pbstock.ResponseCopy
+		item     pbstock.Copy
+	)
+
+	auth := s.Context.User(ctx)
+
+	if req.GetLeadId() == auth {
+		return &response, i18n.Error(11677, "you can not follow yourself")
+	}
+
+	if !s.queryLeader(req.GetLeadId()) {
+		return &response, i18n.Errorf(11678, "the lead trader %v is not found", req.GetLeadId())
+	}
+
+	if req.GetRatio() <= 0 || req.GetRatio() > 100 {
+		return &response, i18n.Error(11679, "the ratio must be greater than 0 and not greater than 100 percent")
+	}
+
+	if req.GetMaximum() <= 0 || req.GetMaximum() > 100 {
+		return &response, i18n.Messagef("11679.maximum", 11679, "the maximum must be greater than 0 and not greater than 100 percent")
+	}
+
+	if err := s.Context.Db.QueryRow("insert into copies (lead_id, user_id, ratio, maximum, status) values ($1, $2, $3, $4, $5) on conflict (lead_id, user_id) do update set ratio = excluded.ratio, maximum = excluded.maximum, status = excluded.status returning id, lead_id, user_id, ratio, maximum, status, create_at", req.GetLeadId(), auth, req.GetRatio(), req.GetMaximum(), req.GetStatus()).Scan(&item.Id, &item.LeadId, &item.UserId, &item.Ratio, &item.Maximum, &item.Status, &item.CreateAt); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, &item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetCopies - This method returns the lead traders the user follows.
+func (s *Service) GetCopies(ctx context.Context, _ *pbstock.GetRequestCopies) (*pbstock.ResponseCopy, error) {
+
+	var (
+		response pbstock.ResponseCopy
+	)
+
+	auth := s.Context.User(ctx)
+
+	rows, err := s.Context.Db.Query("select c.id, c.lead_id, c.user_id, coalesce(case when a.name <> '' then a.name else b.name end, ''), c.ratio, c.maximum, c.status, c.create_at from copies c left join agents a on a.user_id = c.lead_id left join accounts b on b.id = c.lead_id where c.user_id = $1 order by c.id", auth)
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item pbstock.Copy
+		)
+
+		if err := rows.Scan(&item.Id, &item.LeadId, &item.UserId, &item.Name, &item.Ratio, &item.Maximum, &item.Status, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, nil
+}
+
+// DeleteCopy - This method stops following the lead trader, the orders already mirrored stay in the book.
+func (s *Service) DeleteCopy(ctx context.Context, req *pbstock.DeleteRequestCopy) (*pbstock.ResponseCopy, error) {
+
+	var (
+		response pbstock.ResponseCopy
+	)
+
+	auth := s.Context.User(ctx)
+
+	if _, err := s.Context.Db.Exec("delete from copies where lead_id = $1 and user_id = $2", req.GetLeadId(), auth); err != nil {
+		return &response, err
+	}
+
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetCopyOrders - This method returns the history of the orders of the lead trader mirrored to the user, the failed mirrors hold
+// the error, e.g. when the balance of the follower was not enough.
+func (s *Service) GetCopyOrders(ctx context.Context, req *pbstock.GetRequestCopyOrders) (*pbstock.ResponseCopyOrder, error) {
+
+	var (
+		response pbstock.ResponseCopyOrder
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := s.Context.User(ctx)
+
+	if _ = s.Context.Db.QueryRow("select count(*) as count from copy_orders o inner join copies c on c.id = o.copy_id where c.lead_id = $1 and o.user_id = $2", req.GetLeadId(), auth).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := s.Context.Db.Query("select o.id, o.copy_id, o.lead_order_id, o.order_id, o.value, o.status, o.error, o.create_at from copy_orders o inner join copies c on c.id = o.copy_id where c.lead_id = $1 and o.user_id = $2 order by o.id desc limit $3 offset $4", req.GetLeadId(), auth, req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item pbstock.CopyOrder
+			)
+
+			if err := rows.Scan(&item.Id, &item.CopyId, &item.LeadOrderId, &item.OrderId, &item.Value, &item.Status, &item.Error, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	return &response, nil
+}
+
+// GetClients - This method returns the roster of the clients of the broker: the agents approved by the broker, with the number
+// and the volume of their trades in the quote currencies since they joined the broker, and the commissions the broker has
+// earned on them.
+func (s *Service) GetClients(ctx context.Context, req *pbstock.GetRequestClients) (*pbstock.ResponseClient, error) {
+
+	var (
+		response pbstock.ResponseClient
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := s.Context.User(ctx)
+
+	agent, err := s.queryAgent(auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if agent.GetType() != types.UserTypeBroker {
+		return &response, i18n.Error(11682, "only the brokers have clients")
+	}
+
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
+	}
+
+	if _ = s.Context.Db.QueryRow("select count(*) as count from agents where broker_id = $1 and type = $2 and status = $3", agent.GetId(), types.UserTypeAgent, types.StatusAccess).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := s.Context.Db.Query(`select a.id, a.user_id, b.name, b.email, coalesce(t.trades, 0), coalesce(t.volume, 0), a.create_at from agents a
+			inner join accounts b on b.id = a.user_id
+			left join lateral (select count(*) as trades, sum(quantity * price) as volume from trades where user_id = a.user_id and create_at >= a.create_at) t on true
+			where a.broker_id = $1 and a.type = $2 and a.status = $3 order by a.id desc limit $4 offset $5`, agent.GetId(), types.UserTypeAgent, types.StatusAccess, req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item pbstock.Client
+			)
+
+			if err = rows.Scan(&item.Id, &item.UserId, &item.Name, &item.Email, &item.Trades, &item.Volume, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			if item.Earnings, err = s.queryEarnings("select symbol, sum(value) from commissions where broker_id = $1 and client_id = $2 group by symbol order by symbol", agent.GetId(), item.GetUserId()); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+
+		if err = rows.Err(); err != nil {
+			return &response, err
+		}
+	}
+
+	return &response, nil
+}
+
+// GetEarnings - This method returns the commission rate of the broker and the commissions the broker has earned on all the
+// clients, summed per currency.
+func (s *Service) GetEarnings(ctx context.Context, _ *pbstock.GetRequestEarnings) (*pbstock.ResponseEarning, error) {
+
+	var (
+		response pbstock.ResponseEarning
+	)
+
+	auth := s.Context.User(ctx)
+
+	agent, err := s.queryAgent(auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if agent.GetType() != types.UserTypeBroker {
+		return &response, i18n.Error(11682, "only the brokers have clients")
+	}
+
+	if err := s.queryAccess(agent); err != nil {
+		return &response, err
+	}
+
+	if err := s.Context.Db.QueryRow("select commission from agents where id = $1", agent.GetId()).Scan(&response.Commission); err != nil {
+		return &response, err
+	}
+
+	if response.Fields, err = s.queryEarnings("select symbol, sum(value) from commissions where broker_id = $1 group by symbol order by symbol", agent.GetId()); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// SetDocument - This function uploads a document of the application of the agent, a pdf file or an image up to 5 megabytes. The
+// documents are uploaded while the application is pending or rejected, before the application is submitted for the review.
+func (s *Service) SetDocument(ctx context.Context, req *pbstock.SetRequestDocument) (*pbstock.ResponseDocument, error) {
+
+	var (
+		response pbstock.ResponseDocument
+	)
+
+	auth := s.Context.User(ctx)
+
+	agent, err := s.queryAgent(auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if agent.GetStatus() != types.StatusPending && agent.GetStatus() != types.StatsRejected {
+		return &response, i18n.Errorf(11686, "the documents can not be uploaded while the application is %v", agent.GetStatus())
+	}
+
+	if len(req.GetKind()) == 0 || len(req.GetName()) == 0 || len(req.GetData()) == 0 || len(req.GetData()) > 5<<20 {
+		return &response, i18n.Error(11687, "the document must have a kind, a name and be no larger than 5 MB")
+	}
+
+	// The type of the document is detected from its content rather than trusted from the name of the file.
+	mime := http.DetectContentType(req.GetData())
+	if !help.IndexOf([]string{"application/pdf", "image/png", "image/jpeg"}, mime) {
+		return &response, i18n.Errorf(11688, "the document of the type %v is not supported, only pdf, png and jpeg are", mime)
+	}
+
+	if _, err := s.Context.Db.Exec("insert into agent_documents (agent_id, kind, name, mime, data) values ($1, $2, $3, $4, $5)", agent.GetId(), req.GetKind(), req.GetName(), mime, req.GetData()); err != nil {
+		return &response, err
+	}
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetDocuments - This function returns the documents uploaded with the application of the agent, without the content of the files.
+func (s *Service) GetDocuments(ctx context.Context, _ *pbstock.GetRequestDocuments) (*pbstock.ResponseDocument, error) {
+
+	var (
+		response pbstock.ResponseDocument
+	)
+
+	auth := s.Context.User(ctx)
+
+	agent, err := s.queryAgent(auth)
+	if err != nil {
+		return &response, err
+	}
+
+	rows, err := s.Context.Db.Query("select id, agent_id, kind, name, mime, create_at from agent_documents where agent_id = $1 order by id", agent.GetId())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.AgentDocument
+		)
+
+		if err := rows.Scan(&item.Id, &item.AgentId, &item.Kind, &item.Name, &item.Mime, &item.CreateAt); err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, &item)
+	}
+
+	return &response, nil
+}
+
+// SetApplication - This function submits the application of the agent for the review with the details of the applicant, the
+// application is submitted with at least one document uploaded.
+func (s *Service) SetApplication(ctx context.Context, req *pbstock.SetRequestApplication) (*pbstock.ResponseAgent, error) {
+
+	var (
+		response pbstock.ResponseAgent
+		count    int
+	)
+
+	auth := s.Context.User(ctx)
+
+	agent, err := s.queryAgent(auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if _ = s.Context.Db.QueryRow("select count(*) from agent_documents where agent_id = $1", agent.GetId()).Scan(&count); count == 0 {
+		return &response, i18n.Error(11689, "upload the documents before submitting the application")
+	}
+
+	// The details are written only while the application can be submitted, the transition below validates it once more
+	// under the lock of the row.
+	if len(req.GetDetails()) > 0 {
+		if _, err := s.Context.Db.Exec("update agents set details = $2 where id = $1 and status in ($3, $4)", agent.GetId(), req.GetDetails(), types.StatusPending, types.StatsRejected); err != nil {
+			return &response, err
+		}
+	}
+
+	if _, err := s.WriteTransition(agent.GetId(), types.StatusReview, auth, types.ActorApplicant, ""); err != nil {
+		return &response, err
+	}
+
+	if agent, _ := s.queryAgent(auth); agent.Id > 0 {
+		response.Fields = append(response.Fields, agent)
+	}
+
+	return &response, nil
+}
+
+// GetTransitions - This function returns the audit of the changes of the status of the agent, the latest changes first.
+func (s *Service) GetTransitions(ctx context.Context, _ *pbstock.GetRequestTransitions) (*pbstock.ResponseTransition, error) {
+
+	var (
+		response pbstock.ResponseTransition
+	)
+
+	auth := s.Context.User(ctx)
+
+	agent, err := s.queryAgent(auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if response.Fields, err = s.QueryTransitions(agent.GetId()); err != nil {
+		return &response, err
+	}
+
+	return &response, nil
+}
+
+// GetCorporateActions - This function returns the history of the corporate actions of the user: the position in the stock at the
+// ex date of every dividend, split and change of the symbol, and the cash or the shares received for it.
+func (s *Service) GetCorporateActions(ctx context.Context, req *pbstock.GetRequestCorporateActions) (*pbstock.ResponseCorporateAction, error) {
+
+	var (
+		response pbstock.ResponseCorporateAction
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := s.Context.User(ctx)
+
+	if _ = s.Context.Db.QueryRow("select count(*) as count from entitlements where user_id = $1 and ($2 = '' or symbol = $2 or target = $2)", auth, req.GetSymbol()).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		rows, err := s.Context.Db.Query(`select e.id, e.action_id, e.user_id, a.kind, e.symbol, e.quantity, e.target, e.value, e.status, a.ex_at, a.pay_at, e.create_at from entitlements e inner join corporate_actions a on a.id = e.action_id where e.user_id = $1 and ($2 = '' or e.symbol = $2 or e.target = $2) order by e.id desc limit $3 offset $4`, auth, req.GetSymbol(), req.GetLimit(), offset)
+		if err != nil {
+			return &response, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+
+			var (
+				item types.Entitlement
+			)
+
+			if err := rows.Scan(&item.Id, &item.ActionId, &item.UserId, &item.Kind, &item.Symbol, &item.Quantity, &item.Target, &item.Value, &item.Status, &item.ExAt, &item.PayAt, &item.CreateAt); err != nil {
+				return &response, err
+			}
+
+			response.Fields = append(response.Fields, &item)
+		}
+	}
+
+	return &response, nil
+}
+
+// GetCalendar - This function returns the market calendar of the stocks: the trading hours of the market zones, their coming
+// holidays and half-days, the session every zone is in at the moment and the time of its next opening.
+func (s *Service) GetCalendar(_ context.Context, req *pbstock.GetRequestCalendar) (*pbstock.ResponseCalendar, error) {
+
+	var (
+		response pbstock.ResponseCalendar
+		names    []string
+	)
+
+	rows, err := s.Context.Db.Query("select name from zones where status = $1 and ($2 = '' or name = $2) order by id", true, req.GetZone())
+	if err != nil {
+		return &response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			name string
+		)
+
+		if err := rows.Scan(&name); err != nil {
+			return &response, err
+		}
+
+		names = append(names, name)
+	}
+
+	_provider := provider.Service{
+		Context: s.Context,
+	}
+
+	for _, name := range names {
+
+		zone, err := _provider.QueryZone(name)
+		if err != nil {
+			return &response, err
+		}
+
+		response.Fields = append(response.Fields, zone)
 	}
 
 	return &response, nil
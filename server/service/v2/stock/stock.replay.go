@@ -0,0 +1,290 @@
+package stock
+
+import (
+	"database/sql"
+	"github.com/cryptogateway/backend-envoys/server/service/v2/provider"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"time"
+)
+
+// positions - The positions of the holders of the stock, the shares held by the pending sell orders stay on the balances.
+const positions = `select user_id, value as quantity from balances where symbol = $1 and type = $2 and value > 0`
+
+// settlement - This function settles the commissions of the brokers every ten minutes. Every trade of a client of the broker
+// made since the client joined the broker is settled once: the commission of the broker in percent of the fee of the
+// trade is recorded and credited to the spot balance of the broker, in the currency the fee was charged in, the base
+// unit for the buy trades and the quote unit for the sell trades.
+func (s *Service) settlement() {
+
+	// The code creates a ticker that triggers every ten minutes and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 10)
+	for range ticker.C {
+
+		for {
+
+			count, err := s.writeSettlement(1000)
+			if s.Context.Debug(err) || count == 0 {
+				break
+			}
+		}
+	}
+}
+
+// writeSettlement - This function settles the next batch of the trades of the clients in one database transaction, so that the
+// commissions are recorded and credited together, and returns the number of the settled trades.
+func (s *Service) writeSettlement(limit int) (count int, err error) {
+
+	tx, err := s.Context.Db.Begin()
+	if err != nil {
+		return count, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`insert into commissions (broker_id, user_id, client_id, trade_id, symbol, value)
+		select b.id, b.user_id, a.user_id, t.id, case when t.assigning = $4 then t.base_unit else t.quote_unit end, case when t.assigning = $4 then t.fees else t.fees * t.price end * b.commission / 100
+		from agents a
+			inner join agents b on b.id = a.broker_id and b.type = $2 and b.status = $3 and b.commission > 0
+			inner join trades t on t.user_id = a.user_id and t.create_at >= a.create_at and t.fees > 0
+		where a.type = $1 and a.status = $3 and not exists(select id from commissions c where c.trade_id = t.id)
+		order by t.id limit $5
+		on conflict (trade_id) do nothing
+		returning user_id, symbol, value`, types.UserTypeAgent, types.UserTypeBroker, types.StatusAccess, types.AssigningBuy, limit)
+	if err != nil {
+		return count, err
+	}
+
+	var (
+		credits = make(map[int64]map[string]float64)
+	)
+
+	for rows.Next() {
+
+		var (
+			userId int64
+			symbol string
+			value  float64
+		)
+
+		if err := rows.Scan(&userId, &symbol, &value); err != nil {
+			rows.Close()
+			return count, err
+		}
+
+		if _, ok := credits[userId]; !ok {
+			credits[userId] = make(map[string]float64)
+		}
+		credits[userId][symbol] += value
+		count++
+	}
+	rows.Close()
+
+	for userId, symbols := range credits {
+		for symbol, value := range symbols {
+
+			// The brokers that have never held the currency have no balance of it yet.
+			if _, err := tx.Exec("insert into balances (user_id, symbol, type) select $1, $2, $3 where not exists (select id from balances where user_id = $1 and symbol = $2 and type = $3)", userId, symbol, types.TypeSpot); err != nil {
+				return count, err
+			}
+
+			if _, err := tx.Exec("update balances set value = value + $1 where symbol = $2 and user_id = $3 and type = $4", value, symbol, userId, types.TypeSpot); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	return count, tx.Commit()
+}
+
+// corporate - This function processes the corporate actions of the stock securities every minute. At the ex date the positions
+// of the holders are recorded, the splits and the changes of the symbol are applied to them at once, the dividends are
+// credited at the pay date.
+func (s *Service) corporate() {
+
+	// The code creates a ticker that triggers every minute and runs a loop that executes each time the ticker is triggered.
+	ticker := time.NewTicker(time.Minute * 1)
+	for range ticker.C {
+
+		func() {
+
+			var (
+				actions []*types.CorporateAction
+			)
+
+			rows, err := s.Context.Db.Query(`select id, kind, symbol, currency, value, ratio, target, status from corporate_actions where (status = $1 and ex_at <= now()) or (status = $2 and pay_at <= now()) order by ex_at, id`, types.StatusPending, types.StatusProcessing)
+			if s.Context.Debug(err) {
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+
+				var (
+					item types.CorporateAction
+				)
+
+				if err := rows.Scan(&item.Id, &item.Kind, &item.Symbol, &item.Currency, &item.Value, &item.Ratio, &item.Target, &item.Status); s.Context.Debug(err) {
+					return
+				}
+
+				actions = append(actions, &item)
+			}
+
+			for _, item := range actions {
+
+				if s.Context.Debug(s.writeCorporate(item)) {
+					continue
+				}
+
+				// The changed pairs of the stock are served with the new prices and the new symbol.
+				if item.GetKind() != types.CorporateDividend {
+					symbol := item.GetSymbol()
+					if item.GetKind() == types.CorporateSymbol {
+						symbol = item.GetTarget()
+					}
+					s.writeInvalidate(symbol)
+				}
+
+				// The clients are notified about every stage of the corporate action.
+				s.Context.Debug(s.Context.Publish(item, "exchange", "stock/corporate"))
+			}
+		}()
+	}
+}
+
+// writeCorporate - This function processes the next stage of the corporate action in one database transaction, so that the
+// positions of the holders are recorded and changed together:
+//
+//   - a dividend records the positions and the cash per position at the ex date and credits the cash at the pay date;
+//   - a split multiplies the positions and the pending orders of the stock by the ratio and divides their prices by it;
+//   - a change of the symbol renames the asset, its balances, its pairs and its pending orders.
+func (s *Service) writeCorporate(item *types.CorporateAction) error {
+
+	tx, err := s.Context.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	switch {
+	case item.GetKind() == types.CorporateDividend && item.GetStatus() == types.StatusPending:
+
+		if err := s.writeEntitlements(tx, item, item.GetCurrency(), item.GetValue(), types.StatusPending); err != nil {
+			return err
+		}
+		item.Status = types.StatusProcessing
+
+	case item.GetKind() == types.CorporateDividend && item.GetStatus() == types.StatusProcessing:
+
+		// The holders that have never held the currency of the dividend have no balance of it yet.
+		if _, err := tx.Exec("insert into balances (user_id, symbol, type) select distinct e.user_id, e.target, $3 from entitlements e where e.action_id = $1 and e.status = $2 and not exists (select id from balances b where b.user_id = e.user_id and b.symbol = e.target and b.type = $3)", item.GetId(), types.StatusPending, types.TypeStock); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("update balances b set value = b.value + e.value from entitlements e where e.action_id = $1 and e.status = $2 and b.user_id = e.user_id and b.symbol = e.target and b.type = $3", item.GetId(), types.StatusPending, types.TypeStock); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("update entitlements set status = $2 where action_id = $1 and status = $3", item.GetId(), types.StatusFilled, types.StatusPending); err != nil {
+			return err
+		}
+		item.Status = types.StatusFilled
+
+	case item.GetKind() == types.CorporateSplit:
+
+		if err := s.writeEntitlements(tx, item, item.GetSymbol(), item.GetRatio(), types.StatusFilled); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("update balances set value = value * $3 where symbol = $1 and type = $2", item.GetSymbol(), types.TypeStock, item.GetRatio()); err != nil {
+			return err
+		}
+
+		// The value reserved by the pending buy orders in the quote currency stays the same: the buyers get more shares at
+		// a lower price.
+		if _, err := tx.Exec("update orders set value = value * $4, quantity = quantity * $4, price = price / $4 where base_unit = $1 and type = $2 and status = $3", item.GetSymbol(), types.TypeStock, types.StatusPending, item.GetRatio()); err != nil {
+			return err
+		}
+
+		// The new prices and values of the orders are journaled, so that the replay of the journal of the book applies the split.
+		if _, err := tx.Exec("insert into journal (kind, order_id, assigning, base_unit, quote_unit, type, user_id, price, value, status) select $4, id, assigning, base_unit, quote_unit, type, user_id, price, value, status from orders where base_unit = $1 and type = $2 and status = $3 order by id", item.GetSymbol(), types.TypeStock, types.StatusPending, types.JournalAdjust); err != nil {
+			return err
+		}
+
+		// The sell orders hold the shares, so their holds are multiplied together with the orders.
+		if _, err := tx.Exec("update holds set value = value * $4 where reference = $1 and reference_id in (select id from orders where base_unit = $2 and type = $3 and assigning = $5 and status = $6)", types.HoldOrder, item.GetSymbol(), types.TypeStock, item.GetRatio(), types.AssigningSell, types.StatusPending); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("update pairs set price = price / $3 where base_unit = $1 and type = $2", item.GetSymbol(), types.TypeStock, item.GetRatio()); err != nil {
+			return err
+		}
+		item.Status = types.StatusFilled
+
+	case item.GetKind() == types.CorporateSymbol:
+
+		if err := s.writeEntitlements(tx, item, item.GetTarget(), 1, types.StatusFilled); err != nil {
+			return err
+		}
+
+		for _, statement := range []string{
+			"update assets set symbol = $2 where symbol = $1",
+			"update balances set symbol = $2 where symbol = $1",
+			"update holds set symbol = $2 where symbol = $1",
+			"update pairs set base_unit = $2 where base_unit = $1",
+		} {
+			if _, err := tx.Exec(statement, item.GetSymbol(), item.GetTarget()); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec("update orders set base_unit = $2 where base_unit = $1 and status = $3", item.GetSymbol(), item.GetTarget(), types.StatusPending); err != nil {
+			return err
+		}
+		item.Status = types.StatusFilled
+	}
+
+	if _, err := tx.Exec("update corporate_actions set status = $2 where id = $1", item.GetId(), item.GetStatus()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// writeEntitlements - This function records the positions of the holders of the stock at the ex date of the corporate action,
+// and what every holder receives for the position: the value of the target is the position multiplied by the multiplier.
+func (s *Service) writeEntitlements(tx *sql.Tx, item *types.CorporateAction, target string, multiplier float64, _status string) error {
+
+	if _, err := tx.Exec(`insert into entitlements (action_id, user_id, symbol, quantity, target, value, status) select $3, user_id, $1, quantity, $4, quantity * $5, $6 from (`+positions+`) p on conflict (action_id, user_id) do nothing`, item.GetSymbol(), types.TypeStock, item.GetId(), target, multiplier, _status); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeInvalidate - This function invalidates the cached data of the pairs of the stock after the corporate action has changed
+// their prices or their symbol.
+func (s *Service) writeInvalidate(symbol string) {
+
+	_provider := provider.Service{
+		Context: s.Context,
+	}
+
+	rows, err := s.Context.Db.Query("select base_unit, quote_unit from pairs where base_unit = $1 and type = $2", symbol, types.TypeStock)
+	if s.Context.Debug(err) {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item types.Pair
+		)
+
+		if err := rows.Scan(&item.BaseUnit, &item.QuoteUnit); s.Context.Debug(err) {
+			return
+		}
+
+		_provider.WriteInvalidate(item.GetBaseUnit(), item.GetQuoteUnit())
+	}
+}
@@ -0,0 +1,324 @@
+package ticket
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets"
+	"github.com/cryptogateway/backend-envoys/assets/common/help"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	// maxText - The longest text of a message in characters.
+	maxText = 5000
+
+	// maxAttachments - The most files attached to a message, each of them no larger than maxSize bytes.
+	maxAttachments = 5
+	maxSize        = 5 << 20
+
+	// recent - The number of the recent withdrawals and orders of the user the context of the ticket is taken with.
+	recent = 5
+)
+
+// Service - The Service struct is used to create a structure that holds a pointer to an assets.Context. The support tickets are
+// opened and answered by the users here, and by the staff in the admin service of the accounts, with the same helpers.
+type Service struct {
+	Context *assets.Context
+}
+
+// QueryTicket - This function returns the ticket with its messages and the files attached to them, without the content of the
+// files. The ticket of another user is not found, the staff passes the zero user to find the ticket of any user.
+func (s *Service) QueryTicket(id, userId int64) (*types.Ticket, error) {
+
+	fields, err := s.QueryTickets("select id, user_id, category, subject, status, context, operator_id, update_at, close_at, create_at from tickets where id = $1 and ($2 = 0 or user_id = $2)", id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return nil, status.Errorf(11757, "the ticket %v is not found", id)
+	}
+	item := fields[0]
+
+	rows, err := s.Context.Db.Query("select id, ticket_id, user_id, staff, text, create_at from ticket_messages where ticket_id = $1 order by id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		index = make(map[int64]*types.TicketMessage)
+		ids   []int64
+	)
+
+	for rows.Next() {
+
+		var (
+			message types.TicketMessage
+		)
+
+		if err := rows.Scan(&message.Id, &message.TicketId, &message.UserId, &message.Staff, &message.Text, &message.CreateAt); err != nil {
+			return nil, err
+		}
+
+		item.Messages = append(item.Messages, &message)
+		index[message.GetId()], ids = &message, append(ids, message.GetId())
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return item, nil
+	}
+
+	attachments, err := s.Context.Db.Query("select id, message_id, name, mime, length(data), create_at from ticket_attachments where message_id = any($1) order by id", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer attachments.Close()
+
+	for attachments.Next() {
+
+		var (
+			attachment types.TicketAttachment
+		)
+
+		if err := attachments.Scan(&attachment.Id, &attachment.MessageId, &attachment.Name, &attachment.Mime, &attachment.Size, &attachment.CreateAt); err != nil {
+			return nil, err
+		}
+
+		if message, ok := index[attachment.GetMessageId()]; ok {
+			message.Attachments = append(message.Attachments, &attachment)
+		}
+	}
+
+	if err = attachments.Err(); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// QueryTickets - This function returns the tickets selected by the query without their messages, the query selects the columns
+// of the tickets in the order of the fields of the types.Ticket. The context is the json snapshot of the account taken
+// when the ticket was opened, and the time of the closing is empty while the ticket is not closed.
+func (s *Service) QueryTickets(query string, args ...interface{}) (fields []*types.Ticket, err error) {
+
+	rows, err := s.Context.Db.Query(query, args...)
+	if err != nil {
+		return fields, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			item    types.Ticket
+			closeAt sql.NullString
+		)
+
+		if err := rows.Scan(&item.Id, &item.UserId, &item.Category, &item.Subject, &item.Status, &item.Context, &item.OperatorId, &item.UpdateAt, &closeAt, &item.CreateAt); err != nil {
+			return fields, err
+		}
+		item.CloseAt = closeAt.String
+
+		fields = append(fields, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fields, err
+	}
+
+	return fields, nil
+}
+
+// QueryAttachment - This function returns the file attached to the message of the ticket with its content. The file of the ticket
+// of another user is not found, the staff passes the zero user to find the file of any ticket.
+func (s *Service) QueryAttachment(id, userId int64) (*types.TicketAttachment, error) {
+
+	var (
+		item types.TicketAttachment
+	)
+
+	if err := s.Context.Db.QueryRow("select a.id, a.message_id, a.name, a.mime, length(a.data), a.data, a.create_at from ticket_attachments a inner join ticket_messages m on m.id = a.message_id inner join tickets t on t.id = m.ticket_id where a.id = $1 and ($2 = 0 or t.user_id = $2)", id, userId).Scan(&item.Id, &item.MessageId, &item.Name, &item.Mime, &item.Size, &item.Data, &item.CreateAt); errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(11757, "the attachment %v is not found", id)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// WriteMessage - This function adds the message of the user or of the staff to the ticket with the files attached to it and moves
+// the ticket along its workflow: the reply of the staff answers the ticket and assigns it to the member of the staff,
+// the reply of the user opens it again. The closed ticket takes no more replies. The ticket with the new message is
+// published to the user and to the staff.
+func (s *Service) WriteMessage(ticket *types.Ticket, userId int64, staff bool, text string, attachments []*types.TicketAttachment) (*types.TicketMessage, error) {
+
+	var (
+		message = types.TicketMessage{
+			TicketId: ticket.GetId(),
+			UserId:   userId,
+			Staff:    staff,
+			Text:     text,
+		}
+		next = types.TicketOpen
+	)
+
+	if ticket.GetStatus() == types.TicketClosed {
+		return nil, status.Errorf(11761, "the ticket %v is closed and takes no more replies", ticket.GetId())
+	}
+
+	if err := queryMessage(text, attachments); err != nil {
+		return nil, err
+	}
+
+	if staff {
+		next = types.TicketAnswered
+	}
+
+	tx, err := s.Context.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("insert into ticket_messages (ticket_id, user_id, staff, text) values ($1, $2, $3, $4) returning id, create_at", ticket.GetId(), userId, staff, text).Scan(&message.Id, &message.CreateAt); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range attachments {
+
+		item := types.TicketAttachment{
+			MessageId: message.GetId(),
+			Name:      attachment.GetName(),
+			Mime:      attachment.GetMime(),
+			Size:      int64(len(attachment.GetData())),
+		}
+
+		if err := tx.QueryRow("insert into ticket_attachments (message_id, name, mime, data) values ($1, $2, $3, $4) returning id, create_at", item.GetMessageId(), item.GetName(), item.GetMime(), attachment.GetData()).Scan(&item.Id, &item.CreateAt); err != nil {
+			return nil, err
+		}
+
+		message.Attachments = append(message.Attachments, &item)
+	}
+
+	// The ticket is assigned to the member of the staff who answered it last, the reply of the user keeps the assignment.
+	if err := tx.QueryRow("update tickets set status = $2, operator_id = case when $3 then $4 else operator_id end, update_at = now() where id = $1 and status <> $5 returning status, operator_id, update_at", ticket.GetId(), next, staff, userId, types.TicketClosed).Scan(&ticket.Status, &ticket.OperatorId, &ticket.UpdateAt); errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(11761, "the ticket %v is closed and takes no more replies", ticket.GetId())
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	ticket.Messages = []*types.TicketMessage{&message}
+	s.Publish(ticket, "ticket/message")
+
+	return &message, nil
+}
+
+// queryMessage - This function checks the message before it is written: the text is required and limited in length, and the
+// files attached to it are limited in number and in size and must be pdf, png or jpeg files. The type of every file is
+// set to the one detected from its content.
+func queryMessage(text string, attachments []*types.TicketAttachment) error {
+
+	if count := utf8.RuneCountInString(text); count == 0 || count > maxText {
+		return status.Errorf(11758, "the text of the message is required and must be no longer than %v characters", maxText)
+	}
+
+	if len(attachments) > maxAttachments {
+		return status.Errorf(11759, "no more than %v files can be attached to a message", maxAttachments)
+	}
+
+	for _, attachment := range attachments {
+
+		if len(attachment.GetName()) == 0 || len(attachment.GetData()) == 0 || len(attachment.GetData()) > maxSize {
+			return status.Error(11759, "the attached file must have a name and be no larger than 5 MB")
+		}
+
+		// The type of the file is detected from its content rather than trusted from the name of the file.
+		attachment.Mime = http.DetectContentType(attachment.GetData())
+		if !help.IndexOf([]string{"application/pdf", "image/png", "image/jpeg"}, attachment.GetMime()) {
+			return status.Errorf(11759, "the attached file of the type %v is not supported, only pdf, png and jpeg are", attachment.GetMime())
+		}
+	}
+
+	return nil
+}
+
+// WriteStatus - This function moves the ticket to the status, the closed ticket is closed at the time of the update and the ticket
+// opened again is no longer closed. The ticket is published to the user and to the staff.
+func (s *Service) WriteStatus(ticket *types.Ticket, next string) error {
+
+	var (
+		closeAt sql.NullString
+	)
+
+	if err := s.Context.Db.QueryRow("update tickets set status = $2, close_at = case when $2 = $3 then now() else null end, update_at = now() where id = $1 returning status, update_at, close_at", ticket.GetId(), next, types.TicketClosed).Scan(&ticket.Status, &ticket.UpdateAt, &closeAt); err != nil {
+		return err
+	}
+	ticket.CloseAt = closeAt.String
+	ticket.Messages = nil
+
+	s.Publish(ticket, "ticket/status")
+
+	return nil
+}
+
+// Publish - This function publishes the ticket over the broker: to the user of the ticket on the channel, the events of the users
+// are delivered only to the user they are addressed to, and to the staff on the support channel, which is not delivered
+// to the users. The content of the attached files is never published.
+func (s *Service) Publish(ticket *types.Ticket, channel string) {
+	s.Context.Debug(s.Context.Publish(ticket, "exchange", channel, "support/ticket"))
+}
+
+// queryContext - This function returns the snapshot of the account the ticket is opened with: the recent withdrawals and orders of
+// the user together with the orders and the transactions the user referred to, so that the staff has them at hand. The
+// orders and the transactions referred to must be of the user.
+func (s *Service) queryContext(userId int64, orders, transactions []int64) (string, error) {
+
+	var (
+		found    bool
+		snapshot string
+	)
+
+	if len(orders) > 0 {
+		if err := s.Context.Db.QueryRow("select (select count(*) from orders where id = any($1) and user_id = $2) = (select count(distinct id) from unnest($1::integer[]) id)", pq.Array(orders), userId).Scan(&found); err != nil {
+			return "", err
+		}
+		if !found {
+			return "", status.Error(11760, "the orders the ticket refers to are not found")
+		}
+	}
+
+	if len(transactions) > 0 {
+		if err := s.Context.Db.QueryRow("select (select count(*) from transactions where id = any($1) and user_id = $2) = (select count(distinct id) from unnest($1::integer[]) id)", pq.Array(transactions), userId).Scan(&found); err != nil {
+			return "", err
+		}
+		if !found {
+			return "", status.Error(11760, "the transactions the ticket refers to are not found")
+		}
+	}
+
+	// The snapshot is serialized by the database itself, the recent records are the latest ones, the records referred to are
+	// added to them whatever their age.
+	if err := s.Context.Db.QueryRow(fmt.Sprintf(`select json_build_object(
+		'withdrawals', coalesce((select json_agg(w) from (select id, symbol, value, fees, "to", chain_id, hash, status, error, create_at from transactions where user_id = $1 and assignment = $2 and (id = any($4) or id in (select id from transactions where user_id = $1 and assignment = $2 order by id desc limit %[1]d)) order by id desc) w), '[]'::json),
+		'deposits', coalesce((select json_agg(d) from (select id, symbol, value, chain_id, hash, confirmation, status, create_at from transactions where user_id = $1 and assignment = $3 and id = any($4) order by id desc) d), '[]'::json),
+		'orders', coalesce((select json_agg(o) from (select id, assigning, base_unit, quote_unit, type, trading, price, quantity, value, status, create_at from orders where user_id = $1 and (id = any($5) or id in (select id from orders where user_id = $1 order by id desc limit %[1]d)) order by id desc) o), '[]'::json)
+	)::text`, recent), userId, types.AssignmentWithdrawal, types.AssignmentDeposit, pq.Array(transactions), pq.Array(orders)).Scan(&snapshot); err != nil {
+		return "", err
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,197 @@
+package ticket
+
+import (
+	"context"
+	"fmt"
+	"github.com/cryptogateway/backend-envoys/assets/common/query"
+	"github.com/cryptogateway/backend-envoys/server/proto/v2/pbticket"
+	"github.com/cryptogateway/backend-envoys/server/types"
+	"google.golang.org/grpc/status"
+	"strings"
+	"unicode/utf8"
+)
+
+// GetTickets - This function returns the support tickets of the user without their messages, the tickets with the latest activity
+// first, filtered by the status of the ticket on request.
+func (s *Service) GetTickets(ctx context.Context, req *pbticket.GetRequestTickets) (*pbticket.ResponseTicket, error) {
+
+	// The purpose of this code is to declare the response of the function, the conditions of the query are built on the user below.
+	var (
+		response pbticket.ResponseTicket
+	)
+
+	if req.GetLimit() == 0 {
+		req.Limit = 30
+	}
+
+	auth := s.Context.User(ctx)
+	builder := query.NewBuilder(auth)
+
+	if len(req.GetStatus()) > 0 {
+		if err := types.TicketStatus(req.GetStatus()); err != nil {
+			return &response, err
+		}
+		builder.Where("status = ?", req.GetStatus())
+	}
+
+	if _ = s.Context.Db.QueryRow(fmt.Sprintf("select count(*) as count from tickets where user_id = $1 %s", builder.And()), builder.Params()...).Scan(&response.Count); response.GetCount() > 0 {
+
+		offset := req.GetLimit() * req.GetPage()
+		if req.GetPage() > 0 {
+			offset = req.GetLimit() * (req.GetPage() - 1)
+		}
+
+		fields, err := s.QueryTickets(fmt.Sprintf("select id, user_id, category, subject, status, context, operator_id, update_at, close_at, create_at from tickets where user_id = $1 %s order by update_at desc, id desc limit %d offset %d", builder.And(), req.GetLimit(), offset), builder.Params()...)
+		if err != nil {
+			return &response, err
+		}
+		response.Fields = fields
+	}
+
+	return &response, nil
+}
+
+// GetTicket - This function returns the support ticket of the user with its messages and the list of the files attached to them.
+func (s *Service) GetTicket(ctx context.Context, req *pbticket.GetRequestTicket) (*pbticket.ResponseTicket, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbticket.ResponseTicket
+	)
+
+	auth := s.Context.User(ctx)
+
+	item, err := s.QueryTicket(req.GetId(), auth)
+	if err != nil {
+		return &response, err
+	}
+	response.Fields = append(response.Fields, item)
+
+	return &response, nil
+}
+
+// SetTicket - This function opens the support ticket of the user with the category, the subject and the first message with the
+// files attached to it. The snapshot of the account is taken with the ticket: the recent withdrawals and orders of the
+// user and the orders and the transactions the user referred to, so that the staff does not have to ask for them.
+func (s *Service) SetTicket(ctx context.Context, req *pbticket.SetRequestTicket) (*pbticket.ResponseTicket, error) {
+
+	// The purpose of this code is to declare the response of the function and the ticket that is opened.
+	var (
+		response pbticket.ResponseTicket
+		item     = types.Ticket{
+			Category: req.GetCategory(),
+			Subject:  strings.TrimSpace(req.GetSubject()),
+			Status:   types.TicketOpen,
+		}
+	)
+
+	auth := s.Context.User(ctx)
+	item.UserId = auth
+
+	if err := types.TicketCategory(item.GetCategory()); err != nil {
+		return &response, err
+	}
+
+	if count := utf8.RuneCountInString(item.GetSubject()); count == 0 || count > 200 {
+		return &response, status.Error(11762, "the subject of the ticket is required and must be no longer than 200 characters")
+	}
+
+	// The first message is checked before the ticket is opened, so that the ticket is not left without it.
+	if err := queryMessage(req.GetText(), req.GetAttachments()); err != nil {
+		return &response, err
+	}
+
+	snapshot, err := s.queryContext(auth, req.GetOrders(), req.GetTransactions())
+	if err != nil {
+		return &response, err
+	}
+	item.Context = snapshot
+
+	if err := s.Context.Db.QueryRow("insert into tickets (user_id, category, subject, status, context) values ($1, $2, $3, $4, $5) returning id, update_at, create_at", auth, item.GetCategory(), item.GetSubject(), item.GetStatus(), item.GetContext()).Scan(&item.Id, &item.UpdateAt, &item.CreateAt); err != nil {
+		return &response, err
+	}
+
+	if _, err := s.WriteMessage(&item, auth, false, req.GetText(), req.GetAttachments()); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, &item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// SetMessage - This function adds the reply of the user to the support ticket with the files attached to it, the ticket that was
+// answered or resolved is opened again and waits for the staff. The closed ticket takes no more replies.
+func (s *Service) SetMessage(ctx context.Context, req *pbticket.SetRequestMessage) (*pbticket.ResponseTicket, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbticket.ResponseTicket
+	)
+
+	auth := s.Context.User(ctx)
+
+	item, err := s.QueryTicket(req.GetTicketId(), auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if _, err := s.WriteMessage(item, auth, false, req.GetText(), req.GetAttachments()); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// CloseTicket - This function closes the support ticket of the user, the closed ticket takes no more replies, a new ticket is
+// opened for the new question.
+func (s *Service) CloseTicket(ctx context.Context, req *pbticket.CloseRequestTicket) (*pbticket.ResponseTicket, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbticket.ResponseTicket
+	)
+
+	auth := s.Context.User(ctx)
+
+	item, err := s.QueryTicket(req.GetId(), auth)
+	if err != nil {
+		return &response, err
+	}
+
+	if item.GetStatus() == types.TicketClosed {
+		return &response, status.Errorf(11761, "the ticket %v is already closed", req.GetId())
+	}
+
+	if err := s.WriteStatus(item, types.TicketClosed); err != nil {
+		return &response, err
+	}
+
+	response.Fields = append(response.Fields, item)
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetAttachment - This function returns the file attached to the message of the support ticket of the user with its content.
+func (s *Service) GetAttachment(ctx context.Context, req *pbticket.GetRequestAttachment) (*pbticket.ResponseAttachment, error) {
+
+	// The purpose of this code is to declare the response of the function.
+	var (
+		response pbticket.ResponseAttachment
+	)
+
+	auth := s.Context.User(ctx)
+
+	item, err := s.QueryAttachment(req.GetId(), auth)
+	if err != nil {
+		return &response, err
+	}
+	response.Fields = append(response.Fields, item)
+
+	return &response, nil
+}
@@ -1,6 +1,9 @@
 package types
 
-import "github.com/pkg/errors"
+import (
+	"github.com/cryptogateway/backend-envoys/assets/platform"
+	"github.com/pkg/errors"
+)
 
 const (
 	TypeZero   = ""
@@ -16,6 +19,10 @@ const (
 	UserTypeAgent  = "agent"
 	UserTypeBroker = "broker"
 
+	ActorApplicant = "applicant"
+	ActorBroker    = "broker"
+	ActorAdmin     = "admin"
+
 	AssigningBuy    = "buy"
 	AssigningSell   = "sell"
 	AssigningSupply = "supply"
@@ -36,6 +43,23 @@ const (
 	StatusAccess     = "access"
 	StatsRejected    = "rejected"
 	StatusBlocked    = "blocked"
+	StatusHold       = "hold"
+	StatusReview     = "review"
+	StatusQueue      = "queue"
+	StatusOrphaned   = "orphaned"
+
+	ReorgLocated  = "located"
+	ReorgReverted = "reverted"
+	ReorgOrphaned = "orphaned"
+
+	ReplacementBump    = "bump"
+	ReplacementReplace = "replace"
+	ReplacementCancel  = "cancel"
+
+	HoldAmount    = "amount"
+	HoldScreening = "screening"
+	HoldManual    = "manual"
+	HoldSuspended = "suspended"
 
 	TradingMarket = "market"
 	TradingLimit  = "limit"
@@ -54,6 +78,7 @@ const (
 	PlatformBitcoin    = "bitcoin"
 	PlatformEthereum   = "ethereum"
 	PlatformTron       = "tron"
+	PlatformSolana     = "solana"
 	PlatformVisa       = "visa"
 	PlatformMastercard = "mastercard"
 
@@ -62,6 +87,137 @@ const (
 	BalanceMinus = "minus"
 	BalancePlus  = "plus"
 
+	HoldOrder      = "order"
+	HoldWithdrawal = "withdrawal"
+
+	SnapshotHour = "hour"
+	SnapshotDay  = "day"
+
+	LimitTrade    = "trade"
+	LimitWithdraw = "withdraw"
+
+	SupportWithdrawal = "withdrawal_failed"
+	SupportDeposit    = "deposit_stuck"
+	SupportStuck      = "withdrawal_stuck"
+
+	ReconcileMismatch = "mismatch"
+	ReconcileNegative = "negative"
+
+	EventListing     = "listing"
+	EventDelisting   = "delisting"
+	EventMaintenance = "maintenance"
+	EventAirdrop     = "airdrop"
+
+	RuleSweep   = "sweep"
+	RuleDeposit = "deposit"
+
+	MetricVolume = "volume"
+	MetricPnl    = "pnl"
+
+	CorporateDividend = "dividend"
+	CorporateSplit    = "split"
+	CorporateSymbol   = "symbol"
+
+	DelistingScheduled = "scheduled"
+	DelistingSuspended = "suspended"
+	DelistingConverted = "converted"
+
+	JournalPlace   = "place"
+	JournalRelease = "release"
+	JournalCancel  = "cancel"
+	JournalAdjust  = "adjust"
+	JournalMatch   = "match"
+
+	ComponentOperational = "operational"
+	ComponentDegraded    = "degraded"
+	ComponentDown        = "down"
+
+	ComponentChain      = "chain"
+	ComponentWithdrawal = "withdrawal"
+	ComponentMatcher    = "matcher"
+
+	RebalanceTransfer = "transfer"
+	RebalanceBridge   = "bridge"
+
+	FeatureTrading      = "trading"
+	FeatureDeposit      = "deposit"
+	FeatureWithdrawal   = "withdrawal"
+	FeatureRegistration = "registration"
+
+	ScopeGlobal   = "global"
+	ScopeCurrency = "currency"
+	ScopePair     = "pair"
+	ScopeChain    = "chain"
+
+	AnnouncementMaintenance = "maintenance"
+	AnnouncementListing     = "listing"
+	AnnouncementNews        = "news"
+
+	AudienceAll        = "all"
+	AudienceVerified   = "verified"
+	AudienceUnverified = "unverified"
+	AudiencePriority   = "priority"
+	AudienceUsers      = "users"
+
+	ChannelEmail    = "email"
+	ChannelSms      = "sms"
+	ChannelTelegram = "telegram"
+
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped"
+
+	PrivacyExport  = "export"
+	PrivacyClosure = "closure"
+
+	RestrictionTrade    = "trade_ban"
+	RestrictionWithdraw = "withdraw_ban"
+	RestrictionDeposit  = "deposit_only"
+	RestrictionFrozen   = "frozen"
+
+	ReasonSanctions = "sanctions"
+	ReasonFraud     = "fraud"
+	ReasonAml       = "aml"
+	ReasonKyc       = "kyc"
+	ReasonCourt     = "court_order"
+	ReasonOther     = "other"
+
+	SurveillanceWash  = "wash"
+	SurveillanceSpoof = "spoof"
+	SurveillanceLayer = "layer"
+	SurveillancePump  = "pump"
+
+	CaseConfirm = "confirmed"
+	CaseDismiss = "dismissed"
+
+	TicketAccount      = "account"
+	TicketDeposit      = "deposit"
+	TicketWithdrawal   = "withdrawal"
+	TicketTrading      = "trading"
+	TicketVerification = "verification"
+	TicketOther        = "other"
+
+	TicketOpen     = "open"
+	TicketAnswered = "answered"
+	TicketResolved = "resolved"
+	TicketClosed   = "closed"
+
+	HistoryOrder = "order"
+	HistoryTrade = "trade"
+
+	LetterRedriven = "redriven"
+
+	ActionTrade    = "trade"
+	ActionWithdraw = "withdraw"
+	ActionTransfer = "transfer"
+	ActionDeposit  = "deposit"
+
+	IndicatorSma       = "sma"
+	IndicatorEma       = "ema"
+	IndicatorVwap      = "vwap"
+	IndicatorRsi       = "rsi"
+	IndicatorBollinger = "bollinger"
+
 	TagNone      = "tag_none"
 	TagBitcoin   = "tag_bitcoin"
 	TagEthereum  = "tag_ethereum"
@@ -71,6 +227,7 @@ const (
 	TagCronos    = "tag_cronos"
 	TagFantom    = "tag_fantom"
 	TagAvalanche = "tag_avalanche"
+	TagSolana    = "tag_solana"
 
 	ProtocolMainnet = "mainnet"
 	ProtocolErc20   = "erc20"
@@ -105,6 +262,7 @@ const (
 	ProtocolArc1155 = "arc1155"
 	ProtocolArc998  = "arc998"
 	ProtocolArc223  = "arc223"
+	ProtocolSpl     = "spl"
 )
 
 // Tag - This function is used to check if a given string is a valid tag. It checks if the given string is present in the
@@ -120,6 +278,7 @@ func Tag(request string) error {
 		TagCronos:    true,
 		TagFantom:    true,
 		TagAvalanche: true,
+		TagSolana:    true,
 	}
 	if _, ok := tags[request]; !ok {
 		return errors.New("No such tag exists.")
@@ -128,15 +287,19 @@ func Tag(request string) error {
 }
 
 // Platform - The purpose of this function is to check if a given string (request) is a valid platform. It looks through a map of
-// valid platforms and returns an error if the requested platform does not exist.
+// valid platforms and the platforms of the registered blockchain adapters and returns an error if the requested platform does not exist.
 func Platform(request string) error {
 	platforms := map[string]bool{
 		PlatformBitcoin:    true,
 		PlatformEthereum:   true,
 		PlatformTron:       true,
+		PlatformSolana:     true,
 		PlatformVisa:       true,
 		PlatformMastercard: true,
 	}
+	if _, ok := platform.Lookup(request); ok {
+		return nil
+	}
 	if _, ok := platforms[request]; !ok {
 		return errors.New("No such platform exists.")
 	}
@@ -181,6 +344,7 @@ func Protocol(request string) error {
 		ProtocolArc1155: true,
 		ProtocolArc998:  true,
 		ProtocolArc223:  true,
+		ProtocolSpl:     true,
 	}
 	if _, ok := protocols[request]; !ok {
 		return errors.New("No such protocol exists.")
@@ -188,58 +352,329 @@ func Protocol(request string) error {
 	return nil
 }
 
-// Status - The purpose of this code is to check if the requested status is valid. It does this by creating a map of accepted
-// statuses and using an if statement to check if the requested status is in the map. If it is not, an error is returned.
+// Status - The purpose of this code is to check if the requested status is valid. The statuses are those of the enum of the
+// registry, an error is returned when the requested status is none of them.
 func Status(request string) error {
+	return Valid(EnumStatus, request)
+}
+
+func Type(request string) error {
+	return Valid(EnumType, request)
+}
+
+// Assigning - This function checks if the requested side of the spot and stock orders is valid, the sides are the constants of
+// the types package that took the place of the enums of pbspot.
+func Assigning(request string) error {
+	return Valid(EnumAssigning, request)
+}
+
+// Trading - This function checks if the requested trading of the spot and stock orders, by the market or by the limit, is valid.
+func Trading(request string) error {
+	return Valid(EnumTrading, request)
+}
+
+func Group(request string) error {
+	return Valid(EnumGroup, request)
+}
+
+func Position(request string) error {
+	return Valid(EnumPosition, request)
+}
+
+func Snapshot(request string) error {
+	snapshots := map[string]bool{
+		SnapshotHour: true,
+		SnapshotDay:  true,
+	}
+	if _, ok := snapshots[request]; !ok {
+		return errors.New("Invalid snapshot resolution")
+	}
+	return nil
+}
+
+func RuleKind(request string) error {
+	rules := map[string]bool{
+		RuleSweep:   true,
+		RuleDeposit: true,
+	}
+	if _, ok := rules[request]; !ok {
+		return errors.New("Invalid rule kind")
+	}
+	return nil
+}
+
+func Metric(request string) error {
+	metrics := map[string]bool{
+		MetricVolume: true,
+		MetricPnl:    true,
+	}
+	if _, ok := metrics[request]; !ok {
+		return errors.New("Invalid competition metric")
+	}
+	return nil
+}
+
+func Corporate(request string) error {
+	actions := map[string]bool{
+		CorporateDividend: true,
+		CorporateSplit:    true,
+		CorporateSymbol:   true,
+	}
+	if _, ok := actions[request]; !ok {
+		return errors.New("Invalid corporate action")
+	}
+	return nil
+}
+
+func Indicator(request string) error {
+	indicators := map[string]bool{
+		IndicatorSma:       true,
+		IndicatorEma:       true,
+		IndicatorVwap:      true,
+		IndicatorRsi:       true,
+		IndicatorBollinger: true,
+	}
+	if _, ok := indicators[request]; !ok {
+		return errors.New("Invalid indicator")
+	}
+	return nil
+}
+
+// Severity - The purpose of this code is to check if the requested severity of the incident is valid, an error is returned otherwise.
+func Severity(request string) error {
+	severities := map[string]bool{
+		ComponentDegraded: true,
+		ComponentDown:     true,
+	}
+	if _, ok := severities[request]; !ok {
+		return errors.New("Invalid severity")
+	}
+	return nil
+}
+
+// FeatureName - The purpose of this code is to check if the requested feature of the switches is valid, an error is returned otherwise.
+func FeatureName(request string) error {
+	features := map[string]bool{
+		FeatureTrading:      true,
+		FeatureDeposit:      true,
+		FeatureWithdrawal:   true,
+		FeatureRegistration: true,
+	}
+	if _, ok := features[request]; !ok {
+		return errors.New("Invalid feature")
+	}
+	return nil
+}
+
+// FeatureScope - The purpose of this code is to check if the requested scope of the feature switch is valid, an error is returned otherwise.
+func FeatureScope(request string) error {
+	scopes := map[string]bool{
+		ScopeGlobal:   true,
+		ScopeCurrency: true,
+		ScopePair:     true,
+		ScopeChain:    true,
+	}
+	if _, ok := scopes[request]; !ok {
+		return errors.New("Invalid scope")
+	}
+	return nil
+}
+
+// AnnouncementKind - The purpose of this code is to check if the requested kind of the announcement is valid, an error is returned otherwise.
+func AnnouncementKind(request string) error {
+	kinds := map[string]bool{
+		AnnouncementMaintenance: true,
+		AnnouncementListing:     true,
+		AnnouncementNews:        true,
+	}
+	if _, ok := kinds[request]; !ok {
+		return errors.New("Invalid announcement")
+	}
+	return nil
+}
+
+// Audience - The purpose of this code is to check if the requested audience of the announcement is valid, an error is returned otherwise.
+func Audience(request string) error {
+	audiences := map[string]bool{
+		AudienceAll:        true,
+		AudienceVerified:   true,
+		AudienceUnverified: true,
+		AudiencePriority:   true,
+		AudienceUsers:      true,
+	}
+	if _, ok := audiences[request]; !ok {
+		return errors.New("Invalid audience")
+	}
+	return nil
+}
+
+// Channel - The purpose of this code is to check if the requested delivery channel of the notifications is valid, an error is returned otherwise.
+func Channel(request string) error {
+	channels := map[string]bool{
+		ChannelEmail:    true,
+		ChannelSms:      true,
+		ChannelTelegram: true,
+	}
+	if _, ok := channels[request]; !ok {
+		return errors.New("Invalid channel")
+	}
+	return nil
+}
+
+// PrivacyKind - The purpose of this code is to check if the requested kind of the privacy request is valid, an error is returned otherwise.
+func PrivacyKind(request string) error {
+	kinds := map[string]bool{
+		PrivacyExport:  true,
+		PrivacyClosure: true,
+	}
+	if _, ok := kinds[request]; !ok {
+		return errors.New("Invalid privacy request")
+	}
+	return nil
+}
+
+// RestrictionKind - The purpose of this code is to check if the requested kind of the restriction of the account is valid, an error is returned otherwise.
+func RestrictionKind(request string) error {
+	kinds := map[string]bool{
+		RestrictionTrade:    true,
+		RestrictionWithdraw: true,
+		RestrictionDeposit:  true,
+		RestrictionFrozen:   true,
+	}
+	if _, ok := kinds[request]; !ok {
+		return errors.New("Invalid restriction")
+	}
+	return nil
+}
+
+// RestrictionReason - The purpose of this code is to check if the requested reason code of the restriction is valid, an error is returned otherwise.
+func RestrictionReason(request string) error {
+	reasons := map[string]bool{
+		ReasonSanctions: true,
+		ReasonFraud:     true,
+		ReasonAml:       true,
+		ReasonKyc:       true,
+		ReasonCourt:     true,
+		ReasonOther:     true,
+	}
+	if _, ok := reasons[request]; !ok {
+		return errors.New("Invalid restriction reason")
+	}
+	return nil
+}
+
+// Restricts - This function reports whether the restriction of the kind forbids the action: the trade ban forbids the trading,
+// the withdraw ban the withdrawals, the deposit-only account can only deposit, and the frozen account can do none of them.
+func Restricts(kind, action string) bool {
+	switch kind {
+	case RestrictionTrade:
+		return action == ActionTrade
+	case RestrictionWithdraw:
+		return action == ActionWithdraw
+	case RestrictionDeposit:
+		return action != ActionDeposit
+	case RestrictionFrozen:
+		return true
+	}
+	return false
+}
+
+// SurveillanceKind - The purpose of this code is to check if the requested kind of the case of the market surveillance is valid, an error is returned otherwise.
+func SurveillanceKind(request string) error {
+	kinds := map[string]bool{
+		SurveillanceWash:  true,
+		SurveillanceSpoof: true,
+		SurveillanceLayer: true,
+		SurveillancePump:  true,
+	}
+	if _, ok := kinds[request]; !ok {
+		return errors.New("Invalid surveillance case")
+	}
+	return nil
+}
+
+// CaseStatus - The purpose of this code is to check if the requested status of the case of the market surveillance is valid: the
+// case is in the review until the compliance officer confirms or dismisses it, an error is returned otherwise.
+func CaseStatus(request string) error {
 	statuses := map[string]bool{
-		StatusCancel:     true,
-		StatusFilled:     true,
-		StatusPending:    true,
-		StatusReserve:    true,
-		StatusProcessing: true,
-		StatusFailed:     true,
-		StatusLock:       true,
-		StatusAccess:     true,
-		StatsRejected:    true,
-		StatusBlocked:    true,
+		StatusReview: true,
+		CaseConfirm:  true,
+		CaseDismiss:  true,
 	}
 	if _, ok := statuses[request]; !ok {
-		return errors.New("Invalid status")
+		return errors.New("Invalid case status")
 	}
 	return nil
 }
 
-func Type(request string) error {
-	types := map[string]bool{
-		TypeSpot:  true,
-		TypeStock: true,
-		TypeCross: true,
+// TicketCategory - The purpose of this code is to check if the requested category of the support ticket is valid, an error is returned otherwise.
+func TicketCategory(request string) error {
+	categories := map[string]bool{
+		TicketAccount:      true,
+		TicketDeposit:      true,
+		TicketWithdrawal:   true,
+		TicketTrading:      true,
+		TicketVerification: true,
+		TicketOther:        true,
 	}
-	if _, ok := types[request]; !ok {
-		return errors.New("Invalid type")
+	if _, ok := categories[request]; !ok {
+		return errors.New("Invalid ticket category")
 	}
 	return nil
 }
 
-func Group(request string) error {
-	groups := map[string]bool{
-		GroupAction: true,
-		GroupCrypto: true,
-		GroupFiat:   true,
+// TicketStatus - The purpose of this code is to check if the requested status of the support ticket is valid: the ticket is open
+// while it waits for the staff, answered while it waits for the user, resolved until the user replies again or it is
+// closed, and the closed ticket takes no more replies, an error is returned otherwise.
+func TicketStatus(request string) error {
+	statuses := map[string]bool{
+		TicketOpen:     true,
+		TicketAnswered: true,
+		TicketResolved: true,
+		TicketClosed:   true,
 	}
-	if _, ok := groups[request]; !ok {
-		return errors.New("Invalid group")
+	if _, ok := statuses[request]; !ok {
+		return errors.New("Invalid ticket status")
 	}
 	return nil
 }
 
-func Position(request string) error {
-	positions := map[string]bool{
-		PositionLong:  true,
-		PositionShort: true,
+// HistoryKind - The purpose of this code is to check if the requested kind of the entries of the history of the user, the orders or
+// the trades, is valid, an error is returned otherwise.
+func HistoryKind(request string) error {
+	kinds := map[string]bool{
+		HistoryOrder: true,
+		HistoryTrade: true,
+	}
+	if _, ok := kinds[request]; !ok {
+		return errors.New("Invalid history kind")
+	}
+	return nil
+}
+
+// LetterStatus - The purpose of this code is to check if the requested status of the dead letter is valid: the letter is failed
+// until the operators re-drive it to the broker, an error is returned otherwise.
+func LetterStatus(request string) error {
+	statuses := map[string]bool{
+		StatusFailed:   true,
+		LetterRedriven: true,
+	}
+	if _, ok := statuses[request]; !ok {
+		return errors.New("Invalid letter status")
+	}
+	return nil
+}
+
+// EventKind - The purpose of this code is to check if the requested kind of the calendar event is valid, an error is returned otherwise.
+func EventKind(request string) error {
+	events := map[string]bool{
+		EventListing:     true,
+		EventDelisting:   true,
+		EventMaintenance: true,
+		EventAirdrop:     true,
 	}
-	if _, ok := positions[request]; !ok {
-		return errors.New("Invalid position")
+	if _, ok := events[request]; !ok {
+		return errors.New("Invalid event")
 	}
 	return nil
 }
@@ -0,0 +1,144 @@
+package types
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	EnumAssigning  = "assigning"
+	EnumFuture     = "future"
+	EnumPosition   = "position"
+	EnumTrading    = "trading"
+	EnumType       = "type"
+	EnumBalance    = "balance"
+	EnumCross      = "cross"
+	EnumStatus     = "status"
+	EnumGroup      = "group"
+	EnumAssignment = "assignment"
+)
+
+var (
+	// mutex - The lock of the registry, the enums are registered by the init functions and read by the services.
+	mutex sync.RWMutex
+
+	// enums - The registry of the enums by their names.
+	enums = make(map[string]*Enum)
+)
+
+// Enum - The Enum struct is a concept the services, the proto packages and the tables share, such as the side of the orders or
+// their status: the constants of the package the concept takes, the aliases the older services wrote for them, such as
+// the upper case names of the enums of pbspot, and the error of the value that is none of them. The services of the new
+// modules, such as the margin or the futures, register their concepts here instead of defining the values of their own.
+type Enum struct {
+	Name    string
+	Values  []string
+	Aliases map[string]string
+	Error   string
+}
+
+func init() {
+	Register(Enum{Name: EnumAssigning, Values: []string{AssigningBuy, AssigningSell}, Aliases: map[string]string{"bid": AssigningBuy, "ask": AssigningSell}, Error: "Invalid assigning"})
+	Register(Enum{Name: EnumFuture, Values: []string{AssigningOpen, AssigningClose}, Error: "Invalid assigning"})
+	Register(Enum{Name: EnumPosition, Values: []string{PositionLong, PositionShort}, Error: "Invalid position"})
+	Register(Enum{Name: EnumTrading, Values: []string{TradingMarket, TradingLimit}, Error: "Invalid trading"})
+	Register(Enum{Name: EnumType, Values: []string{TypeSpot, TypeStock, TypeCross}, Error: "Invalid type"})
+	Register(Enum{Name: EnumBalance, Values: []string{TypeSpot, TypeStock, TypeCross, TypeFuture}, Error: "Invalid type"})
+	Register(Enum{Name: EnumCross, Values: []string{BalancePlus, BalanceMinus}, Error: "Invalid balance operation"})
+	Register(Enum{Name: EnumStatus, Values: []string{StatusCancel, StatusFilled, StatusPending, StatusReserve, StatusProcessing, StatusFailed, StatusLock, StatusAccess, StatsRejected, StatusBlocked, StatusHold, StatusReview, StatusQueue}, Error: "Invalid status"})
+	Register(Enum{Name: EnumGroup, Values: []string{GroupAction, GroupCrypto, GroupFiat}, Error: "Invalid group"})
+	Register(Enum{Name: EnumAssignment, Values: []string{AssignmentDeposit, AssignmentWithdrawal}, Aliases: map[string]string{"withdraws": AssignmentWithdrawal}, Error: "Invalid assignment"})
+}
+
+// Register - This function registers the enum under its name, the enum registered later replaces the earlier one.
+func Register(e Enum) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	enums[e.Name] = &e
+}
+
+// Lookup - This function returns the enum with the name, false when the enum is not registered.
+func Lookup(name string) (*Enum, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	e, ok := enums[name]
+	return e, ok
+}
+
+// Enums - This function returns the names of the registered enums in order.
+func Enums() (names []string) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for name := range enums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Valid - This function checks that the value is one of the values of the enum with the name as it is, the requests of the
+// services are checked with it, the values in other forms are brought to the constants by Normalize first.
+func Valid(name, value string) error {
+
+	e, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("the enum %v is not registered", name)
+	}
+
+	for _, item := range e.Values {
+		if item == value {
+			return nil
+		}
+	}
+
+	return errors.New(e.Error)
+}
+
+// Normalize - This function returns the constant of the enum with the name the value stands for: the value in any case and with
+// the spaces around it, such as 'BUY' or 'PENDING' of the enums of pbspot, or the alias of the constant, such as
+// 'WITHDRAWS'. The error of the enum is returned when the value stands for none of its constants.
+func Normalize(name, value string) (string, error) {
+
+	e, ok := Lookup(name)
+	if !ok {
+		return value, fmt.Errorf("the enum %v is not registered", name)
+	}
+
+	value = strings.ToLower(strings.TrimSpace(value))
+	if alias, ok := e.Aliases[value]; ok {
+		value = alias
+	}
+
+	if err := Valid(name, value); err != nil {
+		return value, err
+	}
+
+	return value, nil
+}
+
+// Side - This function converts the assigning and the position of the futures order into the side of the order book the order
+// takes: opening the long and closing the short buy, opening the short and closing the long sell. The trades of the
+// futures are recorded with the side, so that the trades of all the services share the assigning of the spot orders.
+func Side(assigning, position string) (string, error) {
+
+	if err := Valid(EnumFuture, assigning); err != nil {
+		return assigning, err
+	}
+
+	if err := Valid(EnumPosition, position); err != nil {
+		return assigning, err
+	}
+
+	if (assigning == AssigningOpen) == (position == PositionLong) {
+		return AssigningBuy, nil
+	}
+
+	return AssigningSell, nil
+}
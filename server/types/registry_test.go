@@ -0,0 +1,74 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+
+	for _, test := range []struct {
+		name, value, want string
+		fail              bool
+	}{
+		{EnumAssigning, "buy", AssigningBuy, false},
+		{EnumAssigning, "SELL", AssigningSell, false},
+		{EnumAssigning, " Buy ", AssigningBuy, false},
+		{EnumAssigning, "BID", AssigningBuy, false},
+		{EnumAssigning, "ask", AssigningSell, false},
+		{EnumAssigning, "open", "", true},
+		{EnumAssigning, "", "", true},
+		{EnumStatus, "PENDING", StatusPending, false},
+		{EnumStatus, "filled", StatusFilled, false},
+		{EnumStatus, "done", "", true},
+		{EnumType, "STOCK", TypeStock, false},
+		{EnumType, "future", "", true},
+		{EnumBalance, "future", TypeFuture, false},
+		{EnumAssignment, "WITHDRAWS", AssignmentWithdrawal, false},
+		{EnumAssignment, "deposit", AssignmentDeposit, false},
+		{EnumTrading, "MARKET", TradingMarket, false},
+		{EnumPosition, "Long", PositionLong, false},
+		{"margin", "buy", "", true},
+	} {
+
+		value, err := Normalize(test.name, test.value)
+		if test.fail {
+			if err == nil {
+				t.Errorf("Normalize(%q, %q) = %q, want an error", test.name, test.value, value)
+			}
+			continue
+		}
+
+		if err != nil || value != test.want {
+			t.Errorf("Normalize(%q, %q) = %q, %v, want %q", test.name, test.value, value, err, test.want)
+		}
+	}
+}
+
+func TestSide(t *testing.T) {
+
+	for _, test := range []struct {
+		assigning, position, want string
+		fail                      bool
+	}{
+		{AssigningOpen, PositionLong, AssigningBuy, false},
+		{AssigningClose, PositionShort, AssigningBuy, false},
+		{AssigningOpen, PositionShort, AssigningSell, false},
+		{AssigningClose, PositionLong, AssigningSell, false},
+		{AssigningBuy, PositionLong, "", true},
+		{AssigningOpen, "flat", "", true},
+		{"OPEN", PositionLong, "", true},
+	} {
+
+		side, err := Side(test.assigning, test.position)
+		if test.fail {
+			if err == nil {
+				t.Errorf("Side(%q, %q) = %q, want an error", test.assigning, test.position, side)
+			}
+			continue
+		}
+
+		if err != nil || side != test.want {
+			t.Errorf("Side(%q, %q) = %q, %v, want %q", test.assigning, test.position, side, err, test.want)
+		}
+	}
+}